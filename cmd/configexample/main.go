@@ -0,0 +1,20 @@
+// Command configexample writes the example config file of app.LoadConfig, see
+// the go:generate directive in internal/app
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"lovebin/internal/app"
+)
+
+func main() {
+	out := flag.String("o", "config.example.yaml", "file to write")
+	flag.Parse()
+
+	if err := os.WriteFile(*out, app.ExampleConfig(), 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -10,12 +10,11 @@ import (
 	_ "lovebin/docs" // swagger docs
 
 	"lovebin/internal/app"
-	"lovebin/modules/encryption"
-	"lovebin/modules/logger"
-	"lovebin/modules/postgres"
-	"lovebin/modules/s3"
 )
 
+// version is logged with every entry, set at build time with -ldflags "-X main.version=..."
+var version = "dev"
+
 // @title           LoveBin API
 // @version         1.0
 // @description     Сервис обмена фотографиями и видео с шифрованием на стороне клиента
@@ -25,37 +24,20 @@ import (
 // @BasePath  /
 
 // @schemes   http https
+
+// @securityDefinitions.apikey  BearerAuth
+// @in                          header
+// @name                        Authorization
+// @description                 Management token in the form "Bearer <token>"
 func main() {
 	ctx := context.Background()
 
-	// Load configuration from environment
-	cfg := app.Config{
-		Logger: logger.Config{
-			Level: getEnv("LOG_LEVEL", "info"),
-		},
-		Postgres: postgres.Config{
-			Host:     getEnv("POSTGRES_HOST", "localhost"),
-			Port:     getEnv("POSTGRES_PORT", "5432"),
-			User:     getEnv("POSTGRES_USER", "postgres"),
-			Password: getEnv("POSTGRES_PASSWORD", "postgres"),
-			DBName:   getEnv("POSTGRES_DB", "lovebin"),
-			SSLMode:  getEnv("POSTGRES_SSLMODE", "disable"),
-		},
-		S3: s3.Config{
-			Region:          getEnv("S3_REGION", "us-east-1"),
-			Bucket:          getEnv("S3_BUCKET", "lovebin-media"),
-			Endpoint:        getEnv("S3_ENDPOINT", ""),
-			AccessKeyID:     getEnv("S3_ACCESS_KEY_ID", ""),
-			SecretAccessKey: getEnv("S3_SECRET_ACCESS_KEY", ""),
-		},
-		Encryption: encryption.Config{
-			Iterations: 100000,
-		},
-		Server: app.ServerConfig{
-			Port: getEnv("SERVER_PORT", "8080"),
-			Host: getEnv("SERVER_HOST", "0.0.0.0"),
-		},
+	// Load configuration from CONFIG_FILE, if set, and the environment
+	cfg, err := app.LoadConfig(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		panic(err)
 	}
+	cfg.Logger.Version = version
 
 	// Initialize application
 	application, err := app.New(ctx, cfg)
@@ -84,10 +66,3 @@ func main() {
 		panic(err)
 	}
 }
-
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
-	}
-	return defaultValue
-}
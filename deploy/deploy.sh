@@ -65,7 +65,7 @@ sleep 10
 
 # Check health
 echo "Checking service health..."
-if docker exec lovebin-app-prod wget --no-verbose --tries=1 --spider http://localhost:8080/health; then
+if docker exec lovebin-app-prod wget --no-verbose --tries=1 --spider http://localhost:8080/api/v1/health; then
     echo "✓ Application is healthy"
 else
     echo "✗ Application health check failed"
@@ -16,19 +16,75 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/health": {
+        "/api/v1/admin/audit": {
             "get": {
-                "description": "Check if the service is running",
+                "description": "List audit trail entries, newest first. Requires management token.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "health"
+                    "admin"
+                ],
+                "summary": "List audit trail",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only entries for this resource",
+                        "name": "resource_key",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only entries at or after this time",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only entries before this time",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size (default 50, max 500)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Entries to skip",
+                        "name": "offset",
+                        "in": "query"
+                    }
                 ],
-                "summary": "Health check",
                 "responses": {
                     "200": {
                         "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/internal_api.AuditListResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -36,42 +92,138 @@ const docTemplate = `{
                             }
                         }
                     }
-                }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
             }
         },
-        "/media/{key}/download": {
+        "/api/v1/admin/cleanup": {
+            "post": {
+                "description": "Delete expired and viewed resources now instead of waiting for the nightly cleanup. Runs synchronously for up to 5 minutes. Outside of the configured cleanup window (CLEANUP_WINDOW) it answers 409. Requires management token.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Run cleanup",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/internal_api.CleanupResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/admin/cleanup/last-run": {
             "get": {
-                "description": "Download a media file. The file will be deleted after first successful download. Requires encryption key in URL fragment.",
+                "description": "Time and result of the most recent cleanup, nightly or forced, since the server started. Requires management token.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Last cleanup run",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/internal_api.CleanupRunResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/admin/keys/unwrap": {
+            "post": {
+                "description": "Unwrap a key wrapped with AES Key Wrap (RFC 3394) under the server wrapping key (KEY_WRAPPING_KEY). Tampered keys and keys wrapped under another wrapping key are rejected. Requires management token.",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
-                    "application/octet-stream"
+                    "application/json"
                 ],
                 "tags": [
-                    "media"
+                    "admin"
                 ],
-                "summary": "Download media file",
+                "summary": "Unwrap key",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Resource key with encryption key (format: resourceKey#encryptionKey)",
-                        "name": "key",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Password if resource is password protected",
-                        "name": "password",
-                        "in": "query"
+                        "description": "Base64 wrapped key",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_api.UnwrapKeyRequest"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "file"
+                            "$ref": "#/definitions/internal_api.UnwrapKeyResponse"
                         }
                     },
                     "400": {
@@ -92,8 +244,56 @@ const docTemplate = `{
                             }
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/admin/keys/wrap": {
+            "post": {
+                "description": "Wrap a key with AES Key Wrap (RFC 3394) under the server wrapping key (KEY_WRAPPING_KEY) for transport in recovery scenarios. The key is standard base64, a multiple of 8 bytes and at least 16 bytes. Requires management token.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Wrap key",
+                "parameters": [
+                    {
+                        "description": "Base64 key to wrap",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_api.WrapKeyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/internal_api.WrapKeyResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -101,8 +301,8 @@ const docTemplate = `{
                             }
                         }
                     },
-                    "410": {
-                        "description": "Gone",
+                    "401": {
+                        "description": "Unauthorized",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -110,8 +310,8 @@ const docTemplate = `{
                             }
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "503": {
+                        "description": "Service Unavailable",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -119,52 +319,82 @@ const docTemplate = `{
                             }
                         }
                     }
-                }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
             }
         },
-        "/upload": {
+        "/api/v1/admin/migrations/run": {
             "post": {
-                "description": "Upload a media file (photo or video) with optional password protection and expiration time. ExpiresIn supports: duration (1h, 24h, 7d, 2w, 1y) or absolute time (RFC3339, ISO8601, Unix timestamp)",
-                "consumes": [
-                    "multipart/form-data"
-                ],
+                "description": "Apply pending goose migrations in version order, each in its own transaction. Concurrent runs wait for each other. Requires management token.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "media"
+                    "admin"
                 ],
-                "summary": "Upload media file",
-                "parameters": [
-                    {
-                        "type": "file",
-                        "description": "Media file to upload",
-                        "name": "file",
-                        "in": "formData",
-                        "required": true
+                "summary": "Run migrations",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/internal_api.MigrationRunResponse"
+                        }
                     },
-                    {
-                        "type": "string",
-                        "description": "Optional password for access protection",
-                        "name": "password",
-                        "in": "formData"
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
                     },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                },
+                "security": [
                     {
-                        "type": "string",
-                        "description": "Expiration time: duration (1h, 24h, 7d, 2w) or absolute (RFC3339, ISO8601, Unix timestamp). Leave empty for no expiration",
-                        "name": "expires_in",
-                        "in": "formData"
+                        "BearerAuth": []
                     }
+                ]
+            }
+        },
+        "/api/v1/admin/migrations/status": {
+            "get": {
+                "description": "Applied and pending goose migrations of the database. Returns 202 while migrations are pending. Requires management token.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
                 ],
+                "summary": "Migration status",
                 "responses": {
                     "200": {
-                        "description": "OK",
+                        "description": "Database is up to date",
                         "schema": {
-                            "$ref": "#/definitions/internal_api.UploadResponse"
+                            "$ref": "#/definitions/internal_api.MigrationStatusResponse"
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "202": {
+                        "description": "Migrations are pending, run them with POST /api/v1/admin/migrations/run",
+                        "schema": {
+                            "$ref": "#/definitions/internal_api.MigrationStatusResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -181,22 +411,1992 @@ const docTemplate = `{
                             }
                         }
                     }
-                }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
             }
-        }
-    },
+        },
+        "/api/v1/admin/reencrypt/{key}": {
+            "post": {
+                "description": "Re-encrypt a stored resource with a new encryption key. The resource key stays the same, the returned URL contains the new key fragment. Requires management token.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Re-encrypt resource",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Resource key",
+                        "name": "key",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Old key, optional new key and password",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_api.ReencryptRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/internal_api.UploadResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "410": {
+                        "description": "Gone",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/admin/resources/{key}/export": {
+            "get": {
+                "description": "Signed, verifiable export of resource metadata for audit trails, also for expired and viewed resources. The signature is the hex HMAC-SHA256 of {\"resource\":...,\"exported_at\":...} under ADMIN_SIGNING_KEY. Password and encryption key are never included. Requires management token.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Export resource",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Resource key",
+                        "name": "key",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/internal_api.ExportEnvelope"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/admin/resources/{key}/timeline": {
+            "get": {
+                "description": "Events of a resource, oldest first: audit trail entries (upload, download, reencrypt, cleanup, ...), used access codes (access_code_used), expiry notifications (expiry_notified) and, while the record exists, its creation, availability and expiry (created, available, expired). Deleted resources keep their recorded events. Requires management token.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Resource timeline",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Resource key",
+                        "name": "key",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only events at or after this time",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only events before this time",
+                        "name": "to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/internal_api.ResourceTimelineResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/admin/storage": {
+            "get": {
+                "description": "Total size and number of media objects, from a bucket listing reused for STORAGE_USAGE_CACHE_TTL (default 5 minutes). With from and/or to, by_day breaks the bytes down per day of last modification in [from, to); the breakdown lists the bucket on every request. Requires management token.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Storage usage",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Start of the per-day breakdown",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End of the per-day breakdown (exclusive)",
+                        "name": "to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/internal_api.StorageUsageResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/health": {
+            "get": {
+                "description": "Check if the service is running. The s3 block reports the latest background storage check (every S3_HEALTH_CHECK_INTERVAL). A degraded storage still answers 200: the server itself is up and restarting it would not help.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Health check",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/internal_api.HealthResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/media/bulk-check": {
+            "post": {
+                "description": "Check access to up to 50 resource keys at once, e.g. for apps tracking several shared links. Unknown and deleted resources are both reported as {\"active\": false}. Results are cached for 5 seconds.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "media"
+                ],
+                "summary": "Bulk resource status",
+                "parameters": [
+                    {
+                        "description": "Resource keys",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_api.BulkStatusRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/internal_api.BulkStatusResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/media/{key}": {
+            "get": {
+                "description": "JSON counterpart of the /media/{key} view page. Checks access without using up the view. Password protected resources return their metadata without download_url until the correct password (or access code) is passed.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "media"
+                ],
+                "summary": "Media metadata",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Resource key",
+                        "name": "key",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Password or access code of protected resources",
+                        "name": "password",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Encryption key, filename and file_extension of newer resources are only returned with it",
+                        "name": "enc_key",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/internal_api.MediaMetadataResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "410": {
+                        "description": "Gone",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "425": {
+                        "description": "Scheduled resource, available_at tells when it opens",
+                        "schema": {
+                            "$ref": "#/definitions/internal_api.MediaMetadataResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/media/{key}/signed-url": {
+            "post": {
+                "description": "Generate a download URL that stops working after the given TTL (at most 30 days, longer TTLs are capped). The signature is HMAC-SHA256(resourceKey + expires + encKeyBase64), so the encryption key must be passed, but the URL does not carry it: append it as the URL fragment (#key) before sharing. With signed_only the resource only opens with a valid signature from then on, otherwise unsigned links keep working. Requires management token.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "media"
+                ],
+                "summary": "Create signed download URL",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Resource key with encryption key (format: resourceKey#encryptionKey)",
+                        "name": "key",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Encryption key, if not passed in the key",
+                        "name": "enc_key",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Optional TTL in seconds and signed_only",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_api.SignedURLRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/internal_api.SignedURLResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/system/encryption-bench": {
+            "get": {
+                "description": "Encrypts and decrypts random payloads of 1 KB, 100 KB, 1 MB and 10 MB with a password and reports the time of each call, plus one PBKDF2 derivation at the configured iteration count. Sizes above ENCRYPTION_BENCH_MAX_SIZE are skipped. One benchmark runs at a time, concurrent requests get 429. Requires management token.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Encryption benchmark",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/internal_api.EncryptionBenchResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/upload": {
+            "post": {
+                "description": "Upload a media file (photo or video) with optional password protection and expiration time. ExpiresIn supports: duration (1h, 24h, 7d, 2w, 1y) or absolute time (RFC3339, ISO8601, Unix timestamp). Offline verification: manifest_signature is an ECDSA P-256 signature over SHA-256(resource key + content hash + expires at) made with a one-time key, verification_public_key is that key. The resource key is resource_key without the #fragment, the content hash is the lowercase hex SHA-256 of the downloaded file and expires at is expires_in as returned (RFC 3339 in UTC), empty when the file never expires. The key is discarded after signing, so a valid signature proves the file is the one uploaded, e.g. with ` + "`" + `openssl dgst -sha256 -verify pub.der -keyform DER -signature sig.der manifest.txt` + "`" + `.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "media"
+                ],
+                "summary": "Upload media file",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "Media file to upload, after the other fields as it is stored while it arrives",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Optional password for access protection",
+                        "name": "password",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Expiration time: duration (1h, 24h, 7d, 2w) or absolute (RFC3339, ISO8601, Unix timestamp). Leave empty for no expiration",
+                        "name": "expires_in",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Blur strength of the image preview, from 0 (no blur, default) to 1 (maximum blur)",
+                        "name": "blur_intensity",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Skip the view page and redirect straight to download: true or false",
+                        "name": "download_only",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Scheduled reveal: the file cannot be opened before this time (same formats as expires_in, must be before it)",
+                        "name": "available_at",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated ISO 3166-1 alpha-2 country codes allowed to open the file (needs GEOIP_DB_PATH)",
+                        "name": "allowed_countries",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Up to 100 single-use access codes, one per line or comma separated. Each code opens the file once; cannot be combined with password",
+                        "name": "access_codes",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of downloads allowed, 1 (default) to 100; the file is deleted after the last one. With access_codes every code is one download instead",
+                        "name": "max_views",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Optional progress session ID from /upload/init-progress",
+                        "name": "session_id",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA timezone for expires_in values without an offset (default UTC), also accepted as tz query param",
+                        "name": "X-Timezone",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/internal_api.UploadResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/internal_api.ValidationError"
+                        }
+                    },
+                    "413": {
+                        "description": "Body above SERVER_MAX_BODY_BYTES or file above the SERVER_MIME_LIMITS entry of its type, e.g. {\\\"error\\\": \\\"files of type video/* are limited to 524288000 bytes, the request body is 600000000 bytes\\\"}",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Too many uploads from this IP (RATE_LIMIT_UPLOAD_LIMIT per RATE_LIMIT_UPLOAD_WINDOW), retry after the Retry-After seconds",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "503": {
+                        "description": "Too many uploads in progress, retry after the Retry-After seconds",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "507": {
+                        "description": "Uploads from this IP use up their storage quota (MAX_STORAGE_PER_IP_BYTES)",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/upload/schema": {
+            "get": {
+                "description": "JSON Schema (draft 2020-12) of the multipart fields accepted by POST /api/v1/upload, with their validation rules. Form values are sent as strings, numbers and booleans in their text form.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "media"
+                ],
+                "summary": "Upload form schema",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/lovebin_modules_schema.Schema"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/media/bulk-status": {
+            "post": {
+                "description": "Check access to up to 50 resource keys at once, e.g. for apps tracking several shared links. Unknown and deleted resources are both reported as {\"active\": false}. Results are cached for 5 seconds.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "media"
+                ],
+                "summary": "Bulk resource status",
+                "parameters": [
+                    {
+                        "description": "Resource keys",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_api.BulkStatusRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/internal_api.BulkStatusResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/media/{key}": {
+            "get": {
+                "description": "HTML page with preview and download button. Download-only resources redirect (307) to the download endpoint instead.",
+                "produces": [
+                    "text/html"
+                ],
+                "tags": [
+                    "media"
+                ],
+                "summary": "View media page",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Resource key",
+                        "name": "key",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Password for protected resources",
+                        "name": "password",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Encryption key (normally passed in the URL fragment)",
+                        "name": "enc_key",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "View page",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "307": {
+                        "description": "Redirect to /media/{key}/download for download-only resources",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Error page",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "425": {
+                        "description": "Countdown page of a scheduled resource",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/media/{key}/download": {
+            "get": {
+                "description": "Download a media file. Every successful download uses up one of the views set with max_views on upload, the file is deleted after the last one. Requires encryption key in URL fragment. The Content-Type is the one detected on upload, application/octet-stream for files uploaded before types were recorded.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/octet-stream",
+                    "application/zip"
+                ],
+                "tags": [
+                    "media"
+                ],
+                "summary": "Download media file",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Resource key with encryption key (format: resourceKey#encryptionKey)",
+                        "name": "key",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Password if resource is password protected",
+                        "name": "password",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "zip"
+                        ],
+                        "type": "string",
+                        "description": "Set to zip to get a ZIP with the file and a README",
+                        "name": "download_mode",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Expiry of a signed URL (Unix seconds)",
+                        "name": "expires",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Signature of a signed URL, required for signed-only resources",
+                        "name": "sig",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "410": {
+                        "description": "Gone",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "425": {
+                        "description": "Countdown page of a scheduled resource",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many download attempts for this resource (3 per minute when password protected, otherwise 50)",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/media/{key}/presign-download": {
+            "post": {
+                "description": "Consume a view and get a presigned S3 URL of the encrypted object instead of the decrypted file. Scheme aes-256-gcm-sha256: the AES-256-GCM key is SHA-256 of the raw (base64url-decoded) enc_key, the blob is a 12-byte nonce followed by the ciphertext. Scheme aes-256-gcm-sha256-chunked (objects uploaded as a stream) uses the same key on a 7-byte nonce prefix followed by frames of a 4-byte big-endian length and one sealed chunk of up to 64 KiB; the nonce of chunk i is the prefix, i as 4 bytes big endian and 1 for the last chunk or 0. Not available for password-protected resources.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "media"
+                ],
+                "summary": "Presigned encrypted download",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Resource key",
+                        "name": "key",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Access code for resources protected by access codes",
+                        "name": "password",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Expiry of a signed URL (Unix seconds)",
+                        "name": "expires",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Signature of a signed URL, required for signed-only resources",
+                        "name": "sig",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Encryption key from the URL fragment",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_api.PresignDownloadRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/internal_api.PresignDownloadResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "410": {
+                        "description": "Gone",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "425": {
+                        "description": "Too Early",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/my/uploads": {
+            "get": {
+                "description": "List active uploads made from the caller's IP address, newest first. Only the resource keys are returned, links still need the encryption key.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "media"
+                ],
+                "summary": "List my uploads",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of uploads (default and max 50)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/internal_api.RecentUploadsResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Permanently delete all uploads made from the caller's IP address, including already viewed ones.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "media"
+                ],
+                "summary": "Delete my uploads",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/internal_api.DeleteUploadsResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/upload/init-progress": {
+            "post": {
+                "description": "Returns a session_id to send with POST /api/v1/upload (form field and X-Upload-Session header) and to subscribe to via GET /upload/progress/{session_id}. Sessions expire after 5 minutes.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "media"
+                ],
+                "summary": "Create upload progress session",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/internal_api.InitProgressResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/upload/progress/{session_id}": {
+            "get": {
+                "description": "Server-Sent Events stream of upload progress: data: {\"bytes\":N,\"total\":M,\"phase\":P}. Phase \"receive\" counts the request body reaching the server, \"store\" the encrypted file written to storage as it arrives (its size differs from the body, store events start once it is known). The stream ends when the upload completes or the session expires.",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "media"
+                ],
+                "summary": "Stream upload progress",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Progress session ID",
+                        "name": "session_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/internal_api.ProgressEvent"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        }
+    },
     "definitions": {
+        "internal_api.AuditListResponse": {
+            "type": "object",
+            "properties": {
+                "entries": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/lovebin_modules_audit.AuditEntry"
+                    }
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "offset": {
+                    "type": "integer"
+                }
+            }
+        },
+        "internal_api.BulkStatusRequest": {
+            "type": "object",
+            "properties": {
+                "keys": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "internal_api.BulkStatusResponse": {
+            "type": "object",
+            "properties": {
+                "statuses": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/internal_api.ResourceStatusResponse"
+                    }
+                }
+            }
+        },
+        "internal_api.CleanupResponse": {
+            "type": "object",
+            "properties": {
+                "deleted_expired": {
+                    "type": "integer"
+                },
+                "deleted_viewed": {
+                    "type": "integer"
+                },
+                "duration_ms": {
+                    "type": "integer"
+                }
+            }
+        },
+        "internal_api.CleanupRunResponse": {
+            "type": "object",
+            "properties": {
+                "deleted_expired": {
+                    "type": "integer"
+                },
+                "deleted_viewed": {
+                    "type": "integer"
+                },
+                "duration_ms": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "finished_at": {
+                    "$ref": "#/definitions/lovebin_modules_timeparser.UniversalTime"
+                },
+                "started_at": {
+                    "$ref": "#/definitions/lovebin_modules_timeparser.UniversalTime"
+                }
+            }
+        },
+        "internal_api.DeleteUploadsResponse": {
+            "type": "object",
+            "properties": {
+                "deleted": {
+                    "type": "integer"
+                }
+            }
+        },
+        "internal_api.EncryptionBenchResponse": {
+            "type": "object",
+            "properties": {
+                "kdf_iterations": {
+                    "type": "integer"
+                },
+                "kdf_time_ms": {
+                    "description": "KDFTimeMs is one PBKDF2 derivation at the configured iteration count,\nEncrypt and Decrypt each include one derivation",
+                    "type": "number"
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/internal_api.EncryptionBenchResult"
+                    }
+                }
+            }
+        },
+        "internal_api.EncryptionBenchResult": {
+            "type": "object",
+            "properties": {
+                "decrypt_ms": {
+                    "type": "number"
+                },
+                "encrypt_ms": {
+                    "type": "number"
+                },
+                "size_bytes": {
+                    "type": "integer"
+                }
+            }
+        },
+        "internal_api.ExportEnvelope": {
+            "type": "object",
+            "properties": {
+                "exported_at": {
+                    "$ref": "#/definitions/lovebin_modules_timeparser.UniversalTime"
+                },
+                "resource": {
+                    "$ref": "#/definitions/internal_api.ResourceExportJSON"
+                },
+                "signature": {
+                    "type": "string"
+                }
+            }
+        },
+        "internal_api.HealthResponse": {
+            "type": "object",
+            "properties": {
+                "s3": {
+                    "$ref": "#/definitions/internal_api.StorageHealthJSON"
+                },
+                "status": {
+                    "description": "Status is \"ok\", or \"degraded\" when the storage is unreachable",
+                    "type": "string"
+                }
+            }
+        },
+        "internal_api.InitProgressResponse": {
+            "type": "object",
+            "properties": {
+                "session_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "internal_api.MediaMetadataResponse": {
+            "type": "object",
+            "properties": {
+                "available_at": {
+                    "$ref": "#/definitions/lovebin_modules_timeparser.UniversalTime"
+                },
+                "blur_intensity": {
+                    "type": "number"
+                },
+                "download_only": {
+                    "type": "boolean"
+                },
+                "download_url": {
+                    "description": "DownloadURL is set once access is granted. It is relative and carries no\nencryption key, append it as the URL fragment (#key) before downloading.",
+                    "type": "string"
+                },
+                "expires_at": {
+                    "$ref": "#/definitions/lovebin_modules_timeparser.UniversalTime"
+                },
+                "file_extension": {
+                    "type": "string"
+                },
+                "filename": {
+                    "type": "string"
+                },
+                "is_image": {
+                    "type": "boolean"
+                },
+                "requires_password": {
+                    "type": "boolean"
+                },
+                "resource_key": {
+                    "type": "string"
+                },
+                "views_remaining": {
+                    "description": "downloads left, each download uses one",
+                    "type": "integer"
+                }
+            }
+        },
+        "internal_api.MigrationInfo": {
+            "type": "object",
+            "properties": {
+                "applied_at": {
+                    "$ref": "#/definitions/lovebin_modules_timeparser.UniversalTime"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "version": {
+                    "type": "integer"
+                }
+            }
+        },
+        "internal_api.MigrationRunResponse": {
+            "type": "object",
+            "properties": {
+                "applied": {
+                    "description": "migrations applied by this run",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/internal_api.MigrationInfo"
+                    }
+                }
+            }
+        },
+        "internal_api.MigrationStatusResponse": {
+            "type": "object",
+            "properties": {
+                "applied": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/internal_api.MigrationInfo"
+                    }
+                },
+                "pending": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/internal_api.MigrationInfo"
+                    }
+                }
+            }
+        },
+        "internal_api.PresignDownloadRequest": {
+            "type": "object",
+            "properties": {
+                "enc_key": {
+                    "type": "string"
+                }
+            }
+        },
+        "internal_api.PresignDownloadResponse": {
+            "type": "object",
+            "properties": {
+                "expires_at": {
+                    "$ref": "#/definitions/lovebin_modules_timeparser.UniversalTime"
+                },
+                "filename": {
+                    "type": "string"
+                },
+                "scheme": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                },
+                "views_remaining": {
+                    "description": "downloads left after this one",
+                    "type": "integer"
+                }
+            }
+        },
+        "internal_api.ProgressEvent": {
+            "type": "object",
+            "properties": {
+                "bytes": {
+                    "type": "integer"
+                },
+                "phase": {
+                    "description": "ProgressPhaseReceive or ProgressPhaseStore",
+                    "type": "string"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "internal_api.RecentUploadResponse": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "$ref": "#/definitions/lovebin_modules_timeparser.UniversalTime"
+                },
+                "expires_at": {
+                    "$ref": "#/definitions/lovebin_modules_timeparser.UniversalTime"
+                },
+                "filename": {
+                    "type": "string"
+                },
+                "resource_key": {
+                    "type": "string"
+                },
+                "viewed": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "internal_api.RecentUploadsResponse": {
+            "type": "object",
+            "properties": {
+                "uploads": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/internal_api.RecentUploadResponse"
+                    }
+                }
+            }
+        },
+        "internal_api.ReencryptRequest": {
+            "type": "object",
+            "properties": {
+                "enc_key_base64": {
+                    "type": "string"
+                },
+                "new_enc_key_base64": {
+                    "type": "string"
+                },
+                "password": {
+                    "type": "string"
+                }
+            }
+        },
+        "internal_api.ResourceExportJSON": {
+            "type": "object",
+            "properties": {
+                "blur_intensity": {
+                    "type": "number"
+                },
+                "content_hash": {
+                    "description": "hex SHA-256 of the stored encrypted object, empty once deleted",
+                    "type": "string"
+                },
+                "created_at": {
+                    "$ref": "#/definitions/lovebin_modules_timeparser.UniversalTime"
+                },
+                "expires_at": {
+                    "$ref": "#/definitions/lovebin_modules_timeparser.UniversalTime"
+                },
+                "extension": {
+                    "type": "string"
+                },
+                "filename": {
+                    "type": "string"
+                },
+                "has_password": {
+                    "type": "boolean"
+                },
+                "resource_key": {
+                    "type": "string"
+                },
+                "viewed": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "internal_api.ResourceStatusResponse": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "type": "boolean"
+                },
+                "available_at": {
+                    "$ref": "#/definitions/lovebin_modules_timeparser.UniversalTime"
+                },
+                "expired": {
+                    "type": "boolean"
+                },
+                "expires_at": {
+                    "$ref": "#/definitions/lovebin_modules_timeparser.UniversalTime"
+                },
+                "not_yet_available": {
+                    "type": "boolean"
+                },
+                "viewed": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "internal_api.ResourceTimelineResponse": {
+            "type": "object",
+            "properties": {
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/internal_api.TimelineEventJSON"
+                    }
+                },
+                "resource_key": {
+                    "type": "string"
+                }
+            }
+        },
+        "internal_api.SignedURLRequest": {
+            "type": "object",
+            "properties": {
+                "signed_only": {
+                    "description": "from now on the resource only opens with a valid signed URL",
+                    "type": "boolean"
+                },
+                "ttl_seconds": {
+                    "type": "integer"
+                }
+            }
+        },
+        "internal_api.SignedURLResponse": {
+            "type": "object",
+            "properties": {
+                "expires_at": {
+                    "$ref": "#/definitions/lovebin_modules_timeparser.UniversalTime"
+                },
+                "url": {
+                    "description": "without the encryption key, append it as the #fragment",
+                    "type": "string"
+                }
+            }
+        },
+        "internal_api.StorageHealthJSON": {
+            "type": "object",
+            "properties": {
+                "checked_at": {
+                    "$ref": "#/definitions/lovebin_modules_timeparser.UniversalTime"
+                },
+                "healthy": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "internal_api.StorageUsageResponse": {
+            "type": "object",
+            "properties": {
+                "bucket": {
+                    "type": "string"
+                },
+                "by_day": {
+                    "description": "ByDay maps UTC days (YYYY-MM-DD) to the bytes of objects last modified on them,\nonly returned when from or to is given",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer",
+                        "format": "int64"
+                    }
+                },
+                "computed_at": {
+                    "$ref": "#/definitions/lovebin_modules_timeparser.UniversalTime"
+                },
+                "prefix": {
+                    "type": "string"
+                },
+                "total_bytes": {
+                    "type": "integer"
+                },
+                "total_objects": {
+                    "type": "integer"
+                }
+            }
+        },
+        "internal_api.TimelineEventJSON": {
+            "type": "object",
+            "properties": {
+                "at": {
+                    "$ref": "#/definitions/lovebin_modules_timeparser.UniversalTime"
+                },
+                "details": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "event_type": {
+                    "type": "string"
+                }
+            }
+        },
+        "internal_api.UnwrapKeyRequest": {
+            "type": "object",
+            "properties": {
+                "wrapped_key": {
+                    "type": "string"
+                }
+            }
+        },
+        "internal_api.UnwrapKeyResponse": {
+            "type": "object",
+            "properties": {
+                "key": {
+                    "type": "string"
+                }
+            }
+        },
         "internal_api.UploadResponse": {
             "type": "object",
             "properties": {
+                "access_codes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "available_at": {
+                    "$ref": "#/definitions/lovebin_modules_timeparser.UniversalTime"
+                },
+                "download_only": {
+                    "type": "boolean"
+                },
                 "expires_in": {
                     "$ref": "#/definitions/lovebin_modules_timeparser.UniversalTime"
                 },
+                "manifest_signature": {
+                    "description": "ManifestSignature is the base64 ASN.1 ECDSA P-256 signature of the upload manifest",
+                    "type": "string"
+                },
+                "max_views": {
+                    "description": "downloads allowed, one per access code",
+                    "type": "integer"
+                },
                 "resource_key": {
                     "type": "string"
                 },
                 "url": {
                     "type": "string"
+                },
+                "verification_public_key": {
+                    "description": "VerificationPublicKey is the base64 PKIX (DER) public key the manifest is checked with",
+                    "type": "string"
+                }
+            }
+        },
+        "internal_api.ValidationError": {
+            "type": "object",
+            "properties": {
+                "errors": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "internal_api.WrapKeyRequest": {
+            "type": "object",
+            "properties": {
+                "key": {
+                    "type": "string"
+                }
+            }
+        },
+        "internal_api.WrapKeyResponse": {
+            "type": "object",
+            "properties": {
+                "wrapped_key": {
+                    "type": "string"
+                }
+            }
+        },
+        "lovebin_modules_audit.AuditEntry": {
+            "type": "object",
+            "properties": {
+                "at": {
+                    "type": "string"
+                },
+                "details": {
+                    "type": "object",
+                    "additionalProperties": {}
+                },
+                "id": {
+                    "type": "string"
+                },
+                "ip": {
+                    "type": "string"
+                },
+                "operation": {
+                    "$ref": "#/definitions/lovebin_modules_audit.Operation"
+                },
+                "performed_by": {
+                    "type": "string"
+                },
+                "resource_key": {
+                    "type": "string"
+                }
+            }
+        },
+        "lovebin_modules_audit.Operation": {
+            "type": "string",
+            "enum": [
+                "upload",
+                "download",
+                "reencrypt",
+                "cleanup",
+                "orphan_cleanup",
+                "delete_by_owner"
+            ],
+            "x-enum-varnames": [
+                "OpUpload",
+                "OpDownload",
+                "OpReencrypt",
+                "OpCleanup",
+                "OpOrphanCleanup",
+                "OpDeleteByOwner"
+            ]
+        },
+        "lovebin_modules_schema.Schema": {
+            "type": "object",
+            "properties": {
+                "$schema": {
+                    "type": "string"
+                },
+                "additionalProperties": {
+                    "type": "boolean"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "enum": {
+                    "type": "array",
+                    "items": {}
+                },
+                "format": {
+                    "type": "string"
+                },
+                "items": {
+                    "$ref": "#/definitions/lovebin_modules_schema.Schema"
+                },
+                "maxItems": {
+                    "type": "integer"
+                },
+                "maxLength": {
+                    "type": "integer"
+                },
+                "maximum": {
+                    "type": "number"
+                },
+                "minItems": {
+                    "type": "integer"
+                },
+                "minLength": {
+                    "type": "integer"
+                },
+                "minimum": {
+                    "type": "number"
+                },
+                "properties": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/lovebin_modules_schema.Schema"
+                    }
+                },
+                "required": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "type": {
+                    "type": "string"
                 }
             }
         },
@@ -208,6 +2408,14 @@ const docTemplate = `{
                 }
             }
         }
+    },
+    "securityDefinitions": {
+        "BearerAuth": {
+            "description": "Management token in the form \"Bearer \u003ctoken\u003e\"",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
+        }
     }
 }`
 
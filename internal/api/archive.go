@@ -0,0 +1,87 @@
+package api
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	mediaservice "lovebin/internal/services/media-service"
+)
+
+// downloadModeZip bundles the file with a README for recipients
+const downloadModeZip = "zip"
+
+const zipReadmeName = "README.txt"
+
+// zipArchiveName turns a download filename into the archive filename
+func zipArchiveName(downloadFilename string) string {
+	base := strings.TrimSuffix(downloadFilename, path.Ext(downloadFilename))
+	if base == "" {
+		base = downloadFilename
+	}
+	return base + ".zip"
+}
+
+// writeZipArchive writes a ZIP with the downloaded file and a README explaining it
+func writeZipArchive(w io.Writer, downloadFilename string, resp *mediaservice.DownloadResponse) error {
+	zw := zip.NewWriter(w)
+	now := time.Now()
+
+	file, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     downloadFilename,
+		Method:   zip.Deflate,
+		Modified: now,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, resp.Data); err != nil {
+		return err
+	}
+
+	readme, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     zipReadmeName,
+		Method:   zip.Deflate,
+		Modified: now,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(readme, buildZipReadme(downloadFilename, resp, now)); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// buildZipReadme describes what the recipient received (UTF-8, CRLF for Windows editors)
+func buildZipReadme(downloadFilename string, resp *mediaservice.DownloadResponse, downloadedAt time.Time) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Файл из LoveBin\n")
+	fmt.Fprintf(&b, "===============\n\n")
+	fmt.Fprintf(&b, "Вы получили файл: %s\n", downloadFilename)
+	fmt.Fprintf(&b, "Скачан: %s\n\n", downloadedAt.UTC().Format("02.01.2006 15:04 UTC"))
+
+	fmt.Fprintf(&b, "Файл в этом архиве уже расшифрован, дополнительные программы не нужны.\n")
+	if resp.PasswordProtected {
+		fmt.Fprintf(&b, "Он хранился зашифрованным и был защищен паролем отправителя.\n")
+	} else {
+		fmt.Fprintf(&b, "Он хранился зашифрованным ключом из ссылки; ключ передается в части ссылки после \"#\"\n")
+		fmt.Fprintf(&b, "и не сохраняется на сервере, поэтому открыть файл мог только владелец ссылки.\n")
+	}
+
+	if resp.ViewsRemaining > 0 {
+		fmt.Fprintf(&b, "\nОсталось скачиваний по этой ссылке: %d. После последнего файл будет удален с сервера.\n", resp.ViewsRemaining)
+	} else {
+		fmt.Fprintf(&b, "\nЭто было последнее скачивание по ссылке: файл удален с сервера.\n")
+	}
+	if !resp.ExpiresAt.IsZero() {
+		fmt.Fprintf(&b, "Срок действия ссылки истекал %s.\n", resp.ExpiresAt.Time.UTC().Format("02.01.2006 15:04 UTC"))
+	}
+
+	return strings.ReplaceAll(b.String(), "\n", "\r\n")
+}
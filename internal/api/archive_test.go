@@ -0,0 +1,166 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	mediaservice "lovebin/internal/services/media-service"
+	"lovebin/modules/timeparser"
+)
+
+func TestZipArchiveName(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     string
+	}{
+		{"photo.png", "photo.zip"},
+		{"archive.tar.gz", "archive.tar.zip"},
+		{"README", "README.zip"},
+		{".env", ".env.zip"},
+		{"отчет.pdf", "отчет.zip"},
+	}
+	for _, tt := range tests {
+		if got := zipArchiveName(tt.filename); got != tt.want {
+			t.Errorf("zipArchiveName(%q) = %q, want %q", tt.filename, got, tt.want)
+		}
+	}
+}
+
+// readZip returns the files of a ZIP archive by name
+func readZip(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("zip.NewReader: %v", err)
+	}
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", f.Name, err)
+		}
+		content, err := io.ReadAll(r)
+		_ = r.Close()
+		if err != nil {
+			t.Fatalf("read %s: %v", f.Name, err)
+		}
+		files[f.Name] = string(content)
+	}
+	return files
+}
+
+func TestWriteZipArchive(t *testing.T) {
+	var buf bytes.Buffer
+	resp := &mediaservice.DownloadResponse{Data: io.NopCloser(strings.NewReader("file content"))}
+	if err := writeZipArchive(&buf, "notes.txt", resp); err != nil {
+		t.Fatalf("writeZipArchive: %v", err)
+	}
+
+	files := readZip(t, buf.Bytes())
+	if len(files) != 2 {
+		t.Fatalf("archive has %d files, want 2", len(files))
+	}
+	if files["notes.txt"] != "file content" {
+		t.Errorf("notes.txt = %q, want the downloaded content", files["notes.txt"])
+	}
+	readme := files[zipReadmeName]
+	if !strings.Contains(readme, "notes.txt") {
+		t.Errorf("README does not name the file: %q", readme)
+	}
+	if strings.Contains(strings.ReplaceAll(readme, "\r\n", ""), "\n") {
+		t.Error("README has bare LF line endings")
+	}
+}
+
+func TestBuildZipReadme(t *testing.T) {
+	downloadedAt := time.Date(2026, 5, 1, 10, 30, 0, 0, time.UTC)
+	expiresAt := timeparser.UniversalTime{Time: time.Date(2026, 5, 2, 8, 0, 0, 0, time.UTC)}
+
+	tests := []struct {
+		name     string
+		resp     mediaservice.DownloadResponse
+		contains []string
+		excludes []string
+	}{
+		{
+			name:     "last download with URL key",
+			resp:     mediaservice.DownloadResponse{},
+			contains: []string{"Скачан: 01.05.2026 10:30 UTC", "ключом из ссылки", "последнее скачивание"},
+			excludes: []string{"паролем", "Срок действия"},
+		},
+		{
+			name:     "password protected",
+			resp:     mediaservice.DownloadResponse{PasswordProtected: true},
+			contains: []string{"защищен паролем"},
+			excludes: []string{"ключом из ссылки"},
+		},
+		{
+			name:     "views remaining",
+			resp:     mediaservice.DownloadResponse{ViewsRemaining: 2},
+			contains: []string{"Осталось скачиваний по этой ссылке: 2"},
+			excludes: []string{"последнее скачивание"},
+		},
+		{
+			name:     "expiry",
+			resp:     mediaservice.DownloadResponse{ExpiresAt: expiresAt},
+			contains: []string{"Срок действия ссылки истекал 02.05.2026 08:00 UTC"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			readme := buildZipReadme("file.bin", &tt.resp, downloadedAt)
+			if !strings.Contains(readme, "Вы получили файл: file.bin\r\n") {
+				t.Errorf("README does not name the file: %q", readme)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(readme, want) {
+					t.Errorf("README lacks %q:\n%s", want, readme)
+				}
+			}
+			for _, unwanted := range tt.excludes {
+				if strings.Contains(readme, unwanted) {
+					t.Errorf("README contains %q:\n%s", unwanted, readme)
+				}
+			}
+		})
+	}
+}
+
+func TestDownloadMediaFileZipMode(t *testing.T) {
+	h := newTestHandlers(t, Config{})
+	app := fiber.New()
+	app.Get("/media/:key/download", h.DownloadMediaFile)
+
+	resourceKey, encKey := h.upload(t, "zipped content", mediaservice.UploadRequest{Filename: "report.pdf"})
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet,
+		"/media/"+resourceKey+"/download?download_mode=zip&enc_key="+encKey, nil))
+	if err != nil {
+		t.Fatalf("download request: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("status = %d, body %q", resp.StatusCode, body)
+	}
+	if got := resp.Header.Get("Content-Type"); got != "application/zip" {
+		t.Errorf("Content-Type = %q, want application/zip", got)
+	}
+	if got := resp.Header.Get("Content-Disposition"); !strings.Contains(got, "report.zip") {
+		t.Errorf("Content-Disposition = %q, want report.zip", got)
+	}
+
+	files := readZip(t, body)
+	if files["report.pdf"] != "zipped content" {
+		t.Errorf("archived file = %q, want the decrypted content", files["report.pdf"])
+	}
+	if _, ok := files[zipReadmeName]; !ok {
+		t.Error("archive has no README")
+	}
+}
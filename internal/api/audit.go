@@ -0,0 +1,65 @@
+package api
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"lovebin/modules/audit"
+	"lovebin/modules/timeparser"
+)
+
+type AuditListResponse struct {
+	Entries []audit.AuditEntry `json:"entries"`
+	Limit   int                `json:"limit"`
+	Offset  int                `json:"offset"`
+}
+
+// ListAudit returns audit trail entries
+// @Summary      List audit trail
+// @Description  List audit trail entries, newest first. Requires management token.
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param        resource_key  query     string  false  "Only entries for this resource"
+// @Param        from          query     string  false  "Only entries at or after this time"
+// @Param        to            query     string  false  "Only entries before this time"
+// @Param        limit         query     int     false  "Page size (default 50, max 500)"
+// @Param        offset        query     int     false  "Entries to skip"
+// @Success      200           {object}  AuditListResponse
+// @Failure      400           {object}  map[string]string
+// @Failure      401           {object}  map[string]string
+// @Failure      500           {object}  map[string]string
+// @Router       /api/v1/admin/audit [get]
+func (h *Handlers) ListAudit(c *fiber.Ctx) error {
+	from, err := timeparser.ParseUniversalTime(c.Query("from"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid from time"})
+	}
+	to, err := timeparser.ParseUniversalTime(c.Query("to"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid to time"})
+	}
+
+	filter := audit.Filter{
+		ResourceKey: c.Query("resource_key"),
+		From:        from.Time,
+		To:          to.Time,
+		Limit:       c.QueryInt("limit", 50),
+		Offset:      c.QueryInt("offset", 0),
+	}
+
+	entries, err := h.audit.List(c.Context(), filter)
+	if err != nil {
+		h.logger.ErrorCtx(c.Context(), "failed to list audit entries", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to list audit entries"})
+	}
+	if entries == nil {
+		entries = []audit.AuditEntry{}
+	}
+
+	return c.JSON(AuditListResponse{
+		Entries: entries,
+		Limit:   filter.Limit,
+		Offset:  filter.Offset,
+	})
+}
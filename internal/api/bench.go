@@ -0,0 +1,74 @@
+package api
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"lovebin/modules/encryption"
+)
+
+type EncryptionBenchResult struct {
+	SizeBytes int64   `json:"size_bytes"`
+	EncryptMs float64 `json:"encrypt_ms"`
+	DecryptMs float64 `json:"decrypt_ms"`
+}
+
+type EncryptionBenchResponse struct {
+	Results []EncryptionBenchResult `json:"results"`
+	// KDFTimeMs is one PBKDF2 derivation at the configured iteration count,
+	// Encrypt and Decrypt each include one derivation
+	KDFTimeMs     float64 `json:"kdf_time_ms"`
+	KDFIterations int     `json:"kdf_iterations"`
+}
+
+// EncryptionBenchmark times the configured encryption on this machine
+// @Summary      Encryption benchmark
+// @Description  Encrypts and decrypts random payloads of 1 KB, 100 KB, 1 MB and 10 MB with a password and reports the time of each call, plus one PBKDF2 derivation at the configured iteration count. Sizes above ENCRYPTION_BENCH_MAX_SIZE are skipped. One benchmark runs at a time, concurrent requests get 429. Requires management token.
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  EncryptionBenchResponse
+// @Failure      401  {object}  map[string]string
+// @Failure      429  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Failure      503  {object}  map[string]string
+// @Router       /api/v1/system/encryption-bench [get]
+func (h *Handlers) EncryptionBenchmark(c *fiber.Ctx) error {
+	if h.cfg.Encryption == nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "encryption benchmark is not configured"})
+	}
+	if !h.benchRunning.CompareAndSwap(false, true) {
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": "encryption benchmark is already running"})
+	}
+	defer h.benchRunning.Store(false)
+
+	results, err := encryption.BenchmarkEncryption(h.cfg.Encryption, h.cfg.MaxBenchSize)
+	if err != nil {
+		h.logger.ErrorCtx(c.Context(), "encryption benchmark failed", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "encryption benchmark failed"})
+	}
+
+	resp := EncryptionBenchResponse{
+		Results:       make([]EncryptionBenchResult, 0, len(results)),
+		KDFIterations: h.cfg.KDFIterations,
+	}
+	for _, result := range results {
+		resp.Results = append(resp.Results, EncryptionBenchResult{
+			SizeBytes: result.SizeBytes,
+			EncryptMs: milliseconds(result.Encrypt),
+			DecryptMs: milliseconds(result.Decrypt),
+		})
+	}
+	if h.cfg.KDFIterations > 0 {
+		resp.KDFTimeMs = milliseconds(encryption.BenchmarkKDF(h.cfg.KDFIterations))
+	}
+
+	return c.JSON(resp)
+}
+
+// milliseconds returns d as fractional milliseconds
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
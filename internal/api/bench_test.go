@@ -0,0 +1,105 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"lovebin/modules/encryption"
+)
+
+const testAdminToken = "bench-admin-token-0123456789abcdef"
+
+// benchRequest calls the encryption benchmark with the admin token
+func benchRequest(t *testing.T, app *fiber.App, out *EncryptionBenchResponse) int {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/system/encryption-bench", nil)
+	req.Header.Set(fiber.HeaderAuthorization, "Bearer "+testAdminToken)
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	defer resp.Body.Close()
+	if out != nil && resp.StatusCode == fiber.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestEncryptionBenchmark(t *testing.T) {
+	tests := []struct {
+		name          string
+		maxSize       int64
+		kdfIterations int
+		wantSizes     []int64
+	}{
+		{"capped", 100 << 10, 1000, []int64{1 << 10, 100 << 10}},
+		{"smallest only", 1 << 10, 1000, []int64{1 << 10}},
+		{"no kdf iterations", 1 << 10, 0, []int64{1 << 10}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newRoutesApp(t, Config{
+				AdminToken:    testAdminToken,
+				Encryption:    encryption.Init(fastKDF, nil),
+				MaxBenchSize:  tt.maxSize,
+				KDFIterations: tt.kdfIterations,
+			})
+
+			var resp EncryptionBenchResponse
+			if status := benchRequest(t, app, &resp); status != fiber.StatusOK {
+				t.Fatalf("status = %d, want 200", status)
+			}
+			if len(resp.Results) != len(tt.wantSizes) {
+				t.Fatalf("results = %+v, want sizes %v", resp.Results, tt.wantSizes)
+			}
+			for i, result := range resp.Results {
+				if result.SizeBytes != tt.wantSizes[i] || result.EncryptMs <= 0 || result.DecryptMs <= 0 {
+					t.Errorf("result %d = %+v, want %d bytes with both times", i, result, tt.wantSizes[i])
+				}
+			}
+			if resp.KDFIterations != tt.kdfIterations || (resp.KDFTimeMs > 0) != (tt.kdfIterations > 0) {
+				t.Errorf("kdf = %v ms at %d iterations, want %d iterations timed", resp.KDFTimeMs, resp.KDFIterations, tt.kdfIterations)
+			}
+		})
+	}
+}
+
+func TestEncryptionBenchmarkUnavailable(t *testing.T) {
+	app := newRoutesApp(t, Config{AdminToken: testAdminToken})
+	if status := benchRequest(t, app, nil); status != fiber.StatusServiceUnavailable {
+		t.Errorf("status without encryption = %d, want 503", status)
+	}
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/v1/system/encryption-bench", nil))
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusUnauthorized {
+		t.Errorf("status without a token = %d, want 401", resp.StatusCode)
+	}
+}
+
+func TestEncryptionBenchmarkOneAtATime(t *testing.T) {
+	h := newTestHandlers(t, Config{AdminToken: testAdminToken, Encryption: encryption.Init(fastKDF, nil), MaxBenchSize: 1 << 10})
+	app := fiber.New()
+	app.Get("/api/v1/system/encryption-bench", h.EncryptionBenchmark)
+
+	h.benchRunning.Store(true)
+	if status := benchRequest(t, app, nil); status != fiber.StatusTooManyRequests {
+		t.Errorf("status while a benchmark runs = %d, want 429", status)
+	}
+
+	h.benchRunning.Store(false)
+	if status := benchRequest(t, app, nil); status != fiber.StatusOK {
+		t.Errorf("status = %d, want 200", status)
+	}
+	if h.benchRunning.Load() {
+		t.Error("the benchmark is still marked as running")
+	}
+}
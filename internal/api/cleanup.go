@@ -0,0 +1,93 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	mediaservice "lovebin/internal/services/media-service"
+	"lovebin/modules/timeparser"
+)
+
+// adminCleanupTimeout bounds a cleanup triggered through the admin endpoint, like the nightly one
+const adminCleanupTimeout = 5 * time.Minute
+
+type CleanupResponse struct {
+	DeletedExpired int   `json:"deleted_expired"`
+	DeletedViewed  int   `json:"deleted_viewed"`
+	DurationMs     int64 `json:"duration_ms"`
+}
+
+type CleanupRunResponse struct {
+	CleanupResponse
+	StartedAt  timeparser.UniversalTime `json:"started_at"`
+	FinishedAt timeparser.UniversalTime `json:"finished_at"`
+	Error      string                   `json:"error,omitempty"`
+}
+
+// AdminForceCleanup runs the resource cleanup right away
+// @Summary      Run cleanup
+// @Description  Delete expired and viewed resources now instead of waiting for the nightly cleanup. Runs synchronously for up to 5 minutes. Outside of the configured cleanup window (CLEANUP_WINDOW) it answers 409. Requires management token.
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  CleanupResponse
+// @Failure      401  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /api/v1/admin/cleanup [post]
+func (h *Handlers) AdminForceCleanup(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(c.Context(), adminCleanupTimeout)
+	defer cancel()
+
+	started := time.Now()
+	result, err := h.mediaService.CleanupExpiredResources(ctx)
+	if errors.Is(err, mediaservice.ErrOutsideCleanupWindow) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		h.logger.ErrorCtx(c.Context(), "forced cleanup failed", zap.Error(err),
+			zap.Int("deleted_expired", result.DeletedExpired), zap.Int("deleted_viewed", result.DeletedViewed))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cleanup failed"})
+	}
+
+	return c.JSON(CleanupResponse{
+		DeletedExpired: result.DeletedExpired,
+		DeletedViewed:  result.DeletedViewed,
+		DurationMs:     time.Since(started).Milliseconds(),
+	})
+}
+
+// AdminLastCleanup reports the most recent cleanup run
+// @Summary      Last cleanup run
+// @Description  Time and result of the most recent cleanup, nightly or forced, since the server started. Requires management token.
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  CleanupRunResponse
+// @Failure      401  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /api/v1/admin/cleanup/last-run [get]
+func (h *Handlers) AdminLastCleanup(c *fiber.Ctx) error {
+	run, ok := h.mediaService.LastCleanup()
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "no cleanup has run since startup"})
+	}
+
+	resp := CleanupRunResponse{
+		CleanupResponse: CleanupResponse{
+			DeletedExpired: run.Result.DeletedExpired,
+			DeletedViewed:  run.Result.DeletedViewed,
+			DurationMs:     run.FinishedAt.Sub(run.StartedAt).Milliseconds(),
+		},
+		StartedAt:  timeparser.NewUniversalTime(run.StartedAt),
+		FinishedAt: timeparser.NewUniversalTime(run.FinishedAt),
+	}
+	if run.Err != nil {
+		resp.Error = run.Err.Error()
+	}
+	return c.JSON(resp)
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	mediaservice "lovebin/internal/services/media-service"
+)
+
+func TestAdminForceCleanup(t *testing.T) {
+	h := newTestHandlers(t, Config{})
+	app := fiber.New()
+	app.Post("/admin/cleanup", h.AdminForceCleanup)
+	app.Get("/admin/cleanup/last-run", h.AdminLastCleanup)
+
+	kept, _ := h.upload(t, "kept", mediaservice.UploadRequest{})
+	viewed, encKey := h.upload(t, "viewed", mediaservice.UploadRequest{})
+	resp, err := h.media.DownloadMedia(context.Background(), &mediaservice.DownloadRequest{ResourceKey: viewed, EncKeyBase64: encKey})
+	if err != nil {
+		t.Fatalf("DownloadMedia: %v", err)
+	}
+	_, _ = io.Copy(io.Discard, resp.Data)
+	_ = resp.Data.Close()
+
+	lastRun := func() (int, CleanupRunResponse) {
+		t.Helper()
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/admin/cleanup/last-run", nil))
+		if err != nil {
+			t.Fatalf("GET last run: %v", err)
+		}
+		var run CleanupRunResponse
+		if resp.StatusCode == fiber.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(&run); err != nil {
+				t.Fatalf("decode last run: %v", err)
+			}
+		}
+		return resp.StatusCode, run
+	}
+
+	if status, _ := lastRun(); status != fiber.StatusNotFound {
+		t.Errorf("last run before any cleanup status = %d, want 404", status)
+	}
+
+	var cleanup CleanupResponse
+	if status := postJSON(t, app, "/admin/cleanup", "", &cleanup); status != fiber.StatusOK {
+		t.Fatalf("cleanup status = %d, want 200", status)
+	}
+	if cleanup.DeletedExpired != 0 || cleanup.DeletedViewed != 1 || cleanup.DurationMs < 0 {
+		t.Errorf("cleanup = %+v, want one viewed resource deleted", cleanup)
+	}
+	if _, ok := h.storage.Object("", "media/"+viewed); ok {
+		t.Error("viewed object kept after the cleanup")
+	}
+	if _, ok := h.storage.Object("", "media/"+kept); !ok {
+		t.Error("unviewed object deleted by the cleanup")
+	}
+
+	status, run := lastRun()
+	if status != fiber.StatusOK {
+		t.Fatalf("last run status = %d, want 200", status)
+	}
+	if run.DeletedViewed != 1 || run.Error != "" || run.FinishedAt.Before(run.StartedAt.Time) {
+		t.Errorf("last run = %+v, want the forced cleanup", run)
+	}
+}
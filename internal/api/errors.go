@@ -0,0 +1,82 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"lovebin/modules/logger"
+)
+
+// errorCodes are the machine-readable codes of framework-level errors. Other
+// statuses get their upper-cased status text, e.g. METHOD_NOT_ALLOWED.
+var errorCodes = map[int]string{
+	fiber.StatusNotFound:              "ROUTE_NOT_FOUND",
+	fiber.StatusRequestEntityTooLarge: "REQUEST_TOO_LARGE",
+	fiber.StatusTooManyRequests:       "RATE_LIMITED",
+	fiber.StatusInternalServerError:   "INTERNAL_ERROR",
+}
+
+// errorPageMessages are shown on the error page to browsers, other statuses show the error message
+var errorPageMessages = map[int]string{
+	fiber.StatusNotFound:              "Страница не найдена",
+	fiber.StatusRequestEntityTooLarge: "Файл слишком большой",
+	fiber.StatusTooManyRequests:       "Слишком много запросов, попробуйте позже",
+	fiber.StatusInternalServerError:   "Внутренняя ошибка сервера",
+}
+
+// errorCode returns the code of an error status
+func errorCode(status int) string {
+	if code, ok := errorCodes[status]; ok {
+		return code
+	}
+	return strings.ToUpper(strings.ReplaceAll(http.StatusText(status), " ", "_"))
+}
+
+// ErrorHandler is the fiber error handler. It answers errors no handler turned
+// into a response, e.g. unknown routes or oversized bodies, in the shape of
+// PanicRecoveryMiddleware: {"error": {"code", "message", "request_id"}}.
+// Browsers asking for HTML outside of /api get the error page instead.
+// Errors other than *fiber.Error are logged and answered as 500 without details.
+func (h *Handlers) ErrorHandler(c *fiber.Ctx, err error) error {
+	status := fiber.StatusInternalServerError
+	message := "An unexpected error occurred"
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		status = fiberErr.Code
+		message = fiberErr.Message
+	} else {
+		h.logger.ErrorCtx(c.Context(), "unhandled error", zap.Error(err),
+			zap.String("method", c.Method()), zap.String("path", c.Path()))
+	}
+
+	if wantsErrorPage(c) {
+		pageMessage, ok := errorPageMessages[status]
+		if !ok {
+			pageMessage = message
+		}
+		return h.renderErrorStatus(c, status, pageMessage)
+	}
+
+	requestID, _ := c.Locals(logger.RequestIDKey).(string)
+	return c.Status(status).JSON(fiber.Map{
+		"error": fiber.Map{
+			"code":       errorCode(status),
+			"message":    message,
+			"request_id": requestID,
+		},
+	})
+}
+
+// wantsErrorPage reports whether a request is better answered with the HTML error page.
+// API routes always get JSON; elsewhere HTML must be preferred over JSON.
+func wantsErrorPage(c *fiber.Ctx) bool {
+	path := c.Path()
+	if strings.HasPrefix(path, "/api/") {
+		return false
+	}
+	return c.Accepts(fiber.MIMEApplicationJSON, fiber.MIMETextHTML) == fiber.MIMETextHTML
+}
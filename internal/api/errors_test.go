@@ -0,0 +1,149 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"lovebin/modules/logger"
+)
+
+func TestErrorCode(t *testing.T) {
+	tests := []struct {
+		status int
+		want   string
+	}{
+		{fiber.StatusNotFound, "ROUTE_NOT_FOUND"},
+		{fiber.StatusRequestEntityTooLarge, "REQUEST_TOO_LARGE"},
+		{fiber.StatusTooManyRequests, "RATE_LIMITED"},
+		{fiber.StatusInternalServerError, "INTERNAL_ERROR"},
+		{fiber.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED"},
+		{fiber.StatusBadRequest, "BAD_REQUEST"},
+		{fiber.StatusServiceUnavailable, "SERVICE_UNAVAILABLE"},
+	}
+	for _, tt := range tests {
+		if got := errorCode(tt.status); got != tt.want {
+			t.Errorf("errorCode(%d) = %q, want %q", tt.status, got, tt.want)
+		}
+	}
+}
+
+// newErrorApp returns an app answering errors with ErrorHandler
+func newErrorApp(t *testing.T) *fiber.App {
+	t.Helper()
+	h := newTestHandlers(t, Config{})
+	app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler, BodyLimit: 16, DisableStartupMessage: true})
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals(logger.RequestIDKey, "request-1")
+		return c.Next()
+	})
+	app.Get("/page", func(c *fiber.Ctx) error { return c.SendString("page") })
+	app.Post("/upload", func(c *fiber.Ctx) error { return c.SendString("uploaded") })
+	app.Get("/limited", func(c *fiber.Ctx) error { return fiber.ErrTooManyRequests })
+	app.Get("/broken", func(c *fiber.Ctx) error { return errors.New("database password is hunter2") })
+	app.Get("/api/v1/missing-thing", func(c *fiber.Ctx) error { return fiber.ErrNotFound })
+	return app
+}
+
+func TestErrorHandlerJSON(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		body        string
+		accept      string
+		wantStatus  int
+		wantCode    string
+		wantMessage string
+	}{
+		{"unknown route", http.MethodGet, "/missing", "", "application/json", fiber.StatusNotFound, "ROUTE_NOT_FOUND", ""},
+		{"wrong method", http.MethodPost, "/page", "", "application/json", fiber.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", ""},
+		{"rate limited", http.MethodGet, "/limited", "", "application/json", fiber.StatusTooManyRequests, "RATE_LIMITED", ""},
+		{"plain error", http.MethodGet, "/broken", "", "application/json", fiber.StatusInternalServerError, "INTERNAL_ERROR", "An unexpected error occurred"},
+		{"any type", http.MethodGet, "/missing", "", "*/*", fiber.StatusNotFound, "ROUTE_NOT_FOUND", ""},
+		{"no accept header", http.MethodGet, "/missing", "", "", fiber.StatusNotFound, "ROUTE_NOT_FOUND", ""},
+		{"browser on the API", http.MethodGet, "/api/v1/missing-thing", "", "text/html,application/xhtml+xml", fiber.StatusNotFound, "ROUTE_NOT_FOUND", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newErrorApp(t)
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("request: %v", err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d, body %q", resp.StatusCode, tt.wantStatus, body)
+			}
+
+			var got struct {
+				Error struct {
+					Code      string `json:"code"`
+					Message   string `json:"message"`
+					RequestID string `json:"request_id"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal(body, &got); err != nil {
+				t.Fatalf("body %q is not JSON: %v", body, err)
+			}
+			if got.Error.Code != tt.wantCode || got.Error.RequestID != "request-1" || got.Error.Message == "" {
+				t.Errorf("error = %+v, want code %s with a message and the request ID", got.Error, tt.wantCode)
+			}
+			if tt.wantMessage != "" && got.Error.Message != tt.wantMessage {
+				t.Errorf("message = %q, want %q", got.Error.Message, tt.wantMessage)
+			}
+			if strings.Contains(string(body), "hunter2") {
+				t.Errorf("response leaks the error: %s", body)
+			}
+		})
+	}
+}
+
+func TestErrorHandlerBodyTooLarge(t *testing.T) {
+	// app.Test fails on oversized bodies before the error handler runs, so this goes over a socket
+	app := newErrorApp(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go func() { _ = app.Listener(ln) }()
+	defer func() { _ = app.Shutdown() }()
+
+	resp, err := http.Post("http://"+ln.Addr().String()+"/upload", "text/plain", strings.NewReader(strings.Repeat("x", 1024)))
+	if err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != fiber.StatusRequestEntityTooLarge || !strings.Contains(string(body), `"code":"REQUEST_TOO_LARGE"`) {
+		t.Errorf("oversized upload = %d %s, want 413 REQUEST_TOO_LARGE", resp.StatusCode, body)
+	}
+}
+
+func TestErrorHandlerErrorPage(t *testing.T) {
+	app := newErrorApp(t)
+	req := httptest.NewRequest(http.MethodGet, "/missing", nil)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != fiber.StatusNotFound {
+		t.Errorf("status = %d, want 404", resp.StatusCode)
+	}
+	if json.Valid(body) {
+		t.Errorf("browser got JSON: %s", body)
+	}
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	mediaservice "lovebin/internal/services/media-service"
+	"lovebin/modules/timeparser"
+)
+
+// ResourceExportJSON is the exported metadata of a resource, without password or key material
+type ResourceExportJSON struct {
+	ResourceKey   string                   `json:"resource_key"`
+	Filename      *string                  `json:"filename"`
+	Extension     *string                  `json:"extension"`
+	CreatedAt     timeparser.UniversalTime `json:"created_at"`
+	ExpiresAt     timeparser.UniversalTime `json:"expires_at"`
+	Viewed        bool                     `json:"viewed"`
+	HasPassword   bool                     `json:"has_password"`
+	BlurIntensity float64                  `json:"blur_intensity"`
+	ContentHash   string                   `json:"content_hash"` // hex SHA-256 of the stored encrypted object, empty once deleted
+}
+
+// ExportEnvelope is a signed resource export. Signature is the hex
+// HMAC-SHA256, under the admin signing key, of the compact JSON object
+// {"resource":...,"exported_at":...}.
+type ExportEnvelope struct {
+	Resource   ResourceExportJSON       `json:"resource"`
+	ExportedAt timeparser.UniversalTime `json:"exported_at"`
+	Signature  string                   `json:"signature"`
+}
+
+// signedExport is the signed part of an envelope, kept as raw JSON so
+// VerifyExport checks exactly the bytes it was given
+type signedExport struct {
+	Resource   json.RawMessage `json:"resource"`
+	ExportedAt json.RawMessage `json:"exported_at"`
+}
+
+// exportMAC returns the HMAC-SHA256 of the signed part of an envelope
+func exportMAC(resource, exportedAt json.RawMessage, key string) ([]byte, error) {
+	payload, err := json.Marshal(signedExport{Resource: resource, ExportedAt: exportedAt})
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(payload)
+	return mac.Sum(nil), nil
+}
+
+// VerifyExport checks the signature of an envelope returned by the export
+// endpoint. Malformed envelopes are errors, a wrong signature reports false.
+func VerifyExport(envelope []byte, key string) (bool, error) {
+	var parsed struct {
+		signedExport
+		Signature string `json:"signature"`
+	}
+	if err := json.Unmarshal(envelope, &parsed); err != nil {
+		return false, err
+	}
+	if len(parsed.Resource) == 0 || len(parsed.ExportedAt) == 0 || parsed.Signature == "" {
+		return false, errors.New("export envelope is missing resource, exported_at or signature")
+	}
+
+	expected, err := exportMAC(parsed.Resource, parsed.ExportedAt, key)
+	if err != nil {
+		return false, err
+	}
+	actual, err := hex.DecodeString(parsed.Signature)
+	if err != nil {
+		return false, nil
+	}
+	return hmac.Equal(expected, actual), nil
+}
+
+// ExportResource exports the metadata of a resource as a signed envelope
+// @Summary      Export resource
+// @Description  Signed, verifiable export of resource metadata for audit trails, also for expired and viewed resources. The signature is the hex HMAC-SHA256 of {"resource":...,"exported_at":...} under ADMIN_SIGNING_KEY. Password and encryption key are never included. Requires management token.
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param        key  path      string  true  "Resource key"
+// @Success      200  {object}  ExportEnvelope
+// @Failure      401  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Failure      503  {object}  map[string]string
+// @Router       /api/v1/admin/resources/{key}/export [get]
+func (h *Handlers) ExportResource(c *fiber.Ctx) error {
+	if h.cfg.AdminSigningKey == "" {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "resource export is not configured"})
+	}
+
+	export, err := h.mediaService.ExportResource(c.Context(), c.Params("key"))
+	if errors.Is(err, mediaservice.ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "resource not found"})
+	}
+	if err != nil {
+		h.logger.ErrorCtx(c.Context(), "failed to export resource", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to export resource"})
+	}
+
+	envelope := ExportEnvelope{
+		Resource: ResourceExportJSON{
+			ResourceKey:   export.ResourceKey,
+			Filename:      export.Filename,
+			Extension:     export.FileExtension,
+			CreatedAt:     export.CreatedAt,
+			ExpiresAt:     export.ExpiresAt,
+			Viewed:        export.Viewed,
+			HasPassword:   export.HasPassword,
+			BlurIntensity: export.BlurIntensity,
+			ContentHash:   export.ContentHash,
+		},
+		ExportedAt: timeparser.NewUniversalTime(time.Now().Truncate(time.Second)),
+	}
+
+	// The envelope is encoded like the signed part, so VerifyExport sees the same bytes
+	resource, err := json.Marshal(envelope.Resource)
+	if err != nil {
+		return err
+	}
+	exportedAt, err := json.Marshal(envelope.ExportedAt)
+	if err != nil {
+		return err
+	}
+	mac, err := exportMAC(resource, exportedAt, h.cfg.AdminSigningKey)
+	if err != nil {
+		return err
+	}
+	envelope.Signature = hex.EncodeToString(mac)
+
+	return c.JSON(envelope)
+}
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	mediaservice "lovebin/internal/services/media-service"
+)
+
+const testSigningKey = "admin signing key"
+
+// exportResource requests the export of resourceKey and returns the status and body
+func exportResource(t *testing.T, app *fiber.App, resourceKey string) (int, []byte) {
+	t.Helper()
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/admin/resources/"+resourceKey+"/export", nil))
+	if err != nil {
+		t.Fatalf("export request: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, body
+}
+
+func TestExportResource(t *testing.T) {
+	h := newTestHandlers(t, Config{AdminSigningKey: testSigningKey})
+	app := fiber.New()
+	app.Get("/admin/resources/:key/export", h.ExportResource)
+
+	resourceKey, _ := h.upload(t, "exported content", mediaservice.UploadRequest{Filename: "report.pdf", Password: "secret", BlurIntensity: 0.5})
+
+	status, body := exportResource(t, app, resourceKey)
+	if status != fiber.StatusOK {
+		t.Fatalf("status = %d, body %q", status, body)
+	}
+	var envelope ExportEnvelope
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		t.Fatalf("decode envelope: %v", err)
+	}
+	resource := envelope.Resource
+	if resource.ResourceKey != resourceKey {
+		t.Errorf("resource_key = %q, want %q", resource.ResourceKey, resourceKey)
+	}
+	// The filename is sealed under the URL key, which the export never has
+	if resource.Filename != nil || strings.Contains(string(body), "report") {
+		t.Errorf("export reveals the sealed filename: %s", body)
+	}
+	if !resource.HasPassword || resource.BlurIntensity != 0.5 || resource.Viewed {
+		t.Errorf("resource = %+v, want password protected, blurred and not viewed", resource)
+	}
+	stored, _ := h.storage.Object("", "media/"+resourceKey)
+	if sum := sha256.Sum256(stored); resource.ContentHash != hex.EncodeToString(sum[:]) {
+		t.Errorf("content_hash = %q, want the SHA-256 of the stored object", resource.ContentHash)
+	}
+	for _, secret := range []string{"secret", "password_hash", "salt"} {
+		if strings.Contains(string(body), secret) {
+			t.Errorf("export contains %q: %s", secret, body)
+		}
+	}
+
+	if ok, err := VerifyExport(body, testSigningKey); err != nil || !ok {
+		t.Errorf("VerifyExport = %v, %v, want a valid signature", ok, err)
+	}
+
+	// Once the object is deleted the export still works, without a content hash
+	h.storage.Reset()
+	status, body = exportResource(t, app, resourceKey)
+	if status != fiber.StatusOK {
+		t.Fatalf("status after deletion = %d, body %q", status, body)
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil || envelope.Resource.ContentHash != "" {
+		t.Errorf("content_hash after deletion = %q, %v, want empty", envelope.Resource.ContentHash, err)
+	}
+}
+
+func TestExportResourceErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		signingKey string
+		want       int
+	}{
+		{"unknown resource", testSigningKey, fiber.StatusNotFound},
+		{"no signing key", "", fiber.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandlers(t, Config{AdminSigningKey: tt.signingKey})
+			app := fiber.New()
+			app.Get("/admin/resources/:key/export", h.ExportResource)
+			if status, body := exportResource(t, app, "missing"); status != tt.want {
+				t.Errorf("status = %d, want %d, body %q", status, tt.want, body)
+			}
+		})
+	}
+}
+
+func TestVerifyExport(t *testing.T) {
+	h := newTestHandlers(t, Config{AdminSigningKey: testSigningKey})
+	app := fiber.New()
+	app.Get("/admin/resources/:key/export", h.ExportResource)
+	resourceKey, _ := h.upload(t, "content", mediaservice.UploadRequest{Filename: "notes.txt"})
+	status, envelope := exportResource(t, app, resourceKey)
+	if status != fiber.StatusOK {
+		t.Fatalf("status = %d, body %q", status, envelope)
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(envelope, &fields); err != nil {
+		t.Fatalf("decode envelope: %v", err)
+	}
+	indented, _ := json.MarshalIndent(fields, "", "  ")
+
+	tests := []struct {
+		name     string
+		envelope string
+		key      string
+		want     bool
+		wantErr  bool
+	}{
+		{"valid", string(envelope), testSigningKey, true, false},
+		{"re-indented", string(indented), testSigningKey, true, false},
+		{"wrong key", string(envelope), "other key", false, false},
+		{"tampered field", strings.Replace(string(envelope), `"viewed":false`, `"viewed":true`, 1), testSigningKey, false, false},
+		{"signature not hex", strings.Replace(string(envelope), `"signature":"`, `"signature":"zz`, 1), testSigningKey, false, false},
+		{"missing signature", `{"resource":{},"exported_at":"2026-01-01T00:00:00Z"}`, testSigningKey, false, true},
+		{"malformed", `{"resource":`, testSigningKey, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := VerifyExport([]byte(tt.envelope), tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyExport error = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("VerifyExport = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	// fileHeaderPeekSize bounds the start of an upload body read by FileSizeGuard
+	// to find the headers of its file
+	fileHeaderPeekSize = 8 << 10
+
+	// uploadFormAllowance is what the other upload form fields may add to the body,
+	// files at most this much over their limit are rejected by UploadMedia instead
+	uploadFormAllowance = 64 << 10
+)
+
+// FileSizeGuard rejects multipart uploads whose file type has a limit in limits
+// with 413 once Content-Length shows the file cannot fit it, before the body is
+// read. Limits are by MIME type, e.g. "image/png", or by "type/*"; the type is
+// the one declared for the "file" part, found in the first bytes of the body.
+// UploadMedia checks the received file against the same limits.
+func FileSizeGuard(limits map[string]int64) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		length := int64(c.Request().Header.ContentLength())
+		if len(limits) == 0 || length <= 0 {
+			return c.Next()
+		}
+		mediaType, params, err := mime.ParseMediaType(c.Get(fiber.HeaderContentType))
+		if err != nil || mediaType != fiber.MIMEMultipartForm || params["boundary"] == "" {
+			return c.Next()
+		}
+
+		var head []byte
+		if stream := requestBodyStream(c); stream != nil {
+			head = make([]byte, min(length, fileHeaderPeekSize))
+			n, err := io.ReadFull(stream, head)
+			if err != nil && err != io.ErrUnexpectedEOF {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "failed to read request body",
+				})
+			}
+			head = head[:n]
+			// The handler reads the peeked bytes followed by the rest
+			c.Locals(bodyStreamKey, io.MultiReader(bytes.NewReader(head), stream))
+		} else {
+			head = c.Body()[:min(len(c.Body()), fileHeaderPeekSize)]
+		}
+
+		fileType, ok := multipartFileType(head, params["boundary"])
+		if !ok {
+			return c.Next()
+		}
+		if pattern, limit, ok := mimeLimit(limits, fileType); ok && length > limit+uploadFormAllowance {
+			// The unread body would corrupt the next request on a kept-alive connection
+			c.Context().SetConnectionClose()
+			return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+				"error": fmt.Sprintf("%s, the request body is %d bytes", fileTooLargeMessage(pattern, limit), length),
+			})
+		}
+		return c.Next()
+	}
+}
+
+// multipartFileType returns the content type declared for the "file" part when
+// its headers are within head, the start of a multipart body
+func multipartFileType(head []byte, boundary string) (string, bool) {
+	reader := multipart.NewReader(bytes.NewReader(head), boundary)
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			return "", false
+		}
+		if part.FormName() == "file" {
+			return partContentType(part.Header.Get(fiber.HeaderContentType)), true
+		}
+	}
+}
+
+// partContentType is the content type of a form file, octet-stream when it has none
+func partContentType(contentType string) string {
+	if contentType == "" {
+		return fiber.MIMEOctetStream
+	}
+	return contentType
+}
+
+// mimeLimit returns the limit for contentType and the pattern it comes from,
+// the exact type before "type/*"
+func mimeLimit(limits map[string]int64, contentType string) (string, int64, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", 0, false
+	}
+	if limit, ok := limits[mediaType]; ok {
+		return mediaType, limit, true
+	}
+	major, _, _ := strings.Cut(mediaType, "/")
+	if limit, ok := limits[major+"/*"]; ok {
+		return major + "/*", limit, true
+	}
+	return "", 0, false
+}
+
+func fileTooLargeMessage(pattern string, limit int64) string {
+	return fmt.Sprintf("files of type %s are limited to %d bytes", pattern, limit)
+}
@@ -0,0 +1,257 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// multipartForm returns the content of each part of a multipart body by form name
+func multipartForm(t *testing.T, body []byte, boundary string) map[string]string {
+	t.Helper()
+	form := make(map[string]string)
+	reader := multipart.NewReader(bytes.NewReader(body), boundary)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return form
+		}
+		if err != nil {
+			t.Fatalf("read multipart body: %v", err)
+		}
+		content, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatalf("read part %q: %v", part.FormName(), err)
+		}
+		form[part.FormName()] = string(content)
+	}
+}
+
+func TestMIMELimit(t *testing.T) {
+	limits := map[string]int64{"image/*": 10, "image/png": 20, "application/pdf": 30}
+	tests := []struct {
+		name        string
+		contentType string
+		wantPattern string
+		wantLimit   int64
+	}{
+		{"exact type", "application/pdf", "application/pdf", 30},
+		{"pattern", "image/jpeg", "image/*", 10},
+		{"exact type over its pattern", "image/png", "image/png", 20},
+		{"parameters", "image/png; name=cat.png", "image/png", 20},
+		{"upper case", "Image/JPEG", "image/*", 10},
+		{"unlisted type", "text/plain", "", 0},
+		{"unlisted major type", "application/zip", "", 0},
+		{"malformed", "image/", "", 0},
+		{"empty", "", "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pattern, limit, ok := mimeLimit(limits, tt.contentType)
+			if pattern != tt.wantPattern || limit != tt.wantLimit || ok != (tt.wantPattern != "") {
+				t.Errorf("mimeLimit(%q) = %q, %d, %v, want %q, %d", tt.contentType, pattern, limit, ok, tt.wantPattern, tt.wantLimit)
+			}
+		})
+	}
+}
+
+func TestMultipartFileType(t *testing.T) {
+	body := func(t *testing.T, req *http.Request) ([]byte, string) {
+		t.Helper()
+		data, err := io.ReadAll(req.Body)
+		if err != nil {
+			t.Fatalf("read body: %v", err)
+		}
+		_, boundary, _ := strings.Cut(req.Header.Get(fiber.HeaderContentType), "boundary=")
+		return data, boundary
+	}
+
+	withFile, boundary := body(t, uploadRequest(t, "cat.png", "image/png", "png", nil))
+	if got, ok := multipartFileType(withFile, boundary); !ok || got != "image/png" {
+		t.Errorf("multipartFileType = %q, %v, want image/png", got, ok)
+	}
+	// The headers are enough, the content may be cut off
+	if got, ok := multipartFileType(withFile[:bytes.Index(withFile, []byte("png\r\n--"))], boundary); !ok || got != "image/png" {
+		t.Errorf("multipartFileType of the headers only = %q, %v, want image/png", got, ok)
+	}
+	if _, ok := multipartFileType(withFile[:20], boundary); ok {
+		t.Error("multipartFileType found a type in a cut off part header")
+	}
+	if _, ok := multipartFileType(withFile, "other"); ok {
+		t.Error("multipartFileType found a type with another boundary")
+	}
+
+	var form bytes.Buffer
+	form.WriteString("--b\r\nContent-Disposition: form-data; name=\"title\"\r\n\r\nnotes\r\n")
+	form.WriteString("--b\r\nContent-Disposition: form-data; name=\"file\"; filename=\"notes\"\r\n\r\ndata\r\n--b--\r\n")
+	if got, ok := multipartFileType(form.Bytes(), "b"); !ok || got != fiber.MIMEOctetStream {
+		t.Errorf("multipartFileType of a file after a field without a type = %q, %v, want %s", got, ok, fiber.MIMEOctetStream)
+	}
+	if _, ok := multipartFileType([]byte("--b\r\nContent-Disposition: form-data; name=\"title\"\r\n\r\nnotes\r\n--b--\r\n"), "b"); ok {
+		t.Error("multipartFileType found a type in a form without a file")
+	}
+}
+
+func TestFileSizeGuard(t *testing.T) {
+	const limit = 1 << 10
+	limits := map[string]int64{"image/*": limit, "image/png": 1 << 20}
+	tests := []struct {
+		name        string
+		contentType string
+		size        int
+		want        int
+	}{
+		{"under the limit", "image/jpeg", limit, fiber.StatusOK},
+		{"within the form allowance", "image/jpeg", limit + 1, fiber.StatusOK},
+		{"over the limit", "image/jpeg", limit + uploadFormAllowance + 1, fiber.StatusRequestEntityTooLarge},
+		{"exact type over its pattern", "image/png", limit + uploadFormAllowance + 1, fiber.StatusOK},
+		{"unlisted type", "video/mp4", 1 << 20, fiber.StatusOK},
+		{"no type", "", 1 << 20, fiber.StatusOK},
+	}
+	for _, stream := range []bool{false, true} {
+		for _, tt := range tests {
+			name := tt.name
+			if stream {
+				name = "streamed " + name
+			}
+			t.Run(name, func(t *testing.T) {
+				app := fiber.New(fiber.Config{StreamRequestBody: stream, DisableStartupMessage: true})
+				var received []byte
+				app.Post("/upload", FileSizeGuard(limits), func(c *fiber.Ctx) error {
+					if body := requestBodyStream(c); body != nil {
+						received, _ = io.ReadAll(body)
+					} else {
+						received = bytes.Clone(c.Body())
+					}
+					return c.SendStatus(fiber.StatusOK)
+				})
+
+				upload := uploadRequest(t, "file", tt.contentType, strings.Repeat("a", tt.size), map[string]string{"title": "guarded"})
+				sent, err := io.ReadAll(upload.Body)
+				if err != nil {
+					t.Fatalf("read body: %v", err)
+				}
+				req, err := http.NewRequest(http.MethodPost, serve(t, app)+"/upload", bytes.NewReader(sent))
+				if err != nil {
+					t.Fatalf("NewRequest: %v", err)
+				}
+				req.Header.Set(fiber.HeaderContentType, upload.Header.Get(fiber.HeaderContentType))
+				resp, err := http.DefaultClient.Do(req)
+				if err != nil {
+					t.Fatalf("request: %v", err)
+				}
+				defer resp.Body.Close()
+
+				if resp.StatusCode != tt.want {
+					t.Fatalf("status = %d, want %d", resp.StatusCode, tt.want)
+				}
+				if tt.want == fiber.StatusOK {
+					// The bytes read to find the file type are handed on
+					_, boundary, _ := strings.Cut(req.Header.Get(fiber.HeaderContentType), "boundary=")
+					form := multipartForm(t, received, boundary)
+					if form["file"] != strings.Repeat("a", tt.size) || form["title"] != "guarded" {
+						t.Errorf("handler read a form of %d file bytes and title %q", len(form["file"]), form["title"])
+					}
+					return
+				}
+				var body struct {
+					Error string `json:"error"`
+				}
+				if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+					t.Fatalf("decode response: %v", err)
+				}
+				if !strings.Contains(body.Error, fileTooLargeMessage("image/*", limit)) {
+					t.Errorf("error = %q, want it to name the type and its limit", body.Error)
+				}
+				if !resp.Close {
+					t.Error("the connection is kept alive with the body unread")
+				}
+			})
+		}
+	}
+}
+
+func TestFileSizeGuardSkipsOtherRequests(t *testing.T) {
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Post("/upload", FileSizeGuard(map[string]int64{"*/*": 1, "application/octet-stream": 1}), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+	url := serve(t, app)
+
+	tests := []struct {
+		name        string
+		contentType string
+	}{
+		{"not multipart", fiber.MIMEOctetStream},
+		{"no boundary", fiber.MIMEMultipartForm},
+		{"no content type", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, url+"/upload", strings.NewReader(strings.Repeat("a", 100<<10)))
+			if err != nil {
+				t.Fatalf("NewRequest: %v", err)
+			}
+			if tt.contentType != "" {
+				req.Header.Set(fiber.HeaderContentType, tt.contentType)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("request: %v", err)
+			}
+			_ = resp.Body.Close()
+			if resp.StatusCode != fiber.StatusOK {
+				t.Errorf("status = %d, want 200", resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestUploadMIMELimits(t *testing.T) {
+	const limit = 1 << 10
+	app := newRoutesApp(t, Config{MaxUploadSize: 1 << 20, MIMELimits: map[string]int64{"image/*": limit}})
+
+	tests := []struct {
+		name        string
+		contentType string
+		size        int
+		want        int
+		wantError   string
+	}{
+		{"under the limit", "image/jpeg", limit, fiber.StatusOK, ""},
+		{"within the form allowance", "image/jpeg", limit + 1, fiber.StatusRequestEntityTooLarge, "the file is larger"},
+		{"over the limit", "image/jpeg", 100 << 10, fiber.StatusRequestEntityTooLarge, "the request body is"},
+		{"unlisted type", "text/plain", 100 << 10, fiber.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := uploadRequest(t, "file", tt.contentType, strings.Repeat("a", tt.size), nil)
+			req.URL.Path, req.RequestURI = "/api/v1/upload", "/api/v1/upload"
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("upload request: %v", err)
+			}
+			if resp.StatusCode != tt.want {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+			if tt.wantError == "" {
+				return
+			}
+			var body struct {
+				Error string `json:"error"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if !strings.Contains(body.Error, fileTooLargeMessage("image/*", limit)) || !strings.Contains(body.Error, tt.wantError) {
+				t.Errorf("error = %q, want the limit and %q", body.Error, tt.wantError)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// keysApp answers GET /media/:key with the keys getResourceKeyAndEncryptionKey extracts
+func keysApp() *fiber.App {
+	app := fiber.New()
+	app.Get("/media/:key", func(c *fiber.Ctx) error {
+		resourceKey, encKey, err := getResourceKeyAndEncryptionKey(c)
+		if err != nil {
+			return err
+		}
+		return c.JSON(fiber.Map{"resource_key": resourceKey, "enc_key": encKey})
+	})
+	return app
+}
+
+// extractKeys requests the escaped path segment with the query and Referer and
+// returns the status and the extracted keys
+func extractKeys(t *testing.T, app *fiber.App, segment, query, referer string) (int, string, string, error) {
+	t.Helper()
+	// RequestURI goes on the wire as is, malformed escapes included
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RequestURI = "/media/" + segment
+	if query != "" {
+		req.RequestURI += "?" + query
+	}
+	if referer != "" {
+		req.Header.Set("Referer", referer)
+	}
+	resp, err := app.Test(req)
+	if err != nil {
+		return 0, "", "", err
+	}
+	var keys struct {
+		ResourceKey string `json:"resource_key"`
+		EncKey      string `json:"enc_key"`
+	}
+	if resp.StatusCode == fiber.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(&keys); err != nil {
+			t.Fatalf("decode keys: %v", err)
+		}
+	}
+	return resp.StatusCode, keys.ResourceKey, keys.EncKey, nil
+}
+
+func TestGetResourceKeyAndEncryptionKey(t *testing.T) {
+	tests := []struct {
+		name            string
+		segment, query  string
+		referer         string
+		wantStatus      int
+		wantResourceKey string
+		wantEncKey      string
+	}{
+		{"resource key only", "abc_DEF-123", "", "", fiber.StatusOK, "abc_DEF-123", ""},
+		{"fragment", "abc%23enc_KEY", "", "", fiber.StatusOK, "abc", "enc_KEY"},
+		{"empty fragment", "abc%23", "", "", fiber.StatusOK, "abc", ""},
+		{"padded fragment", "abc%23ZW5j=", "", "", fiber.StatusOK, "abc", "ZW5j="},
+		{"query", "abc", "enc_key=enc_KEY", "", fiber.StatusOK, "abc", "enc_KEY"},
+		{"fragment before query", "abc%23frag", "enc_key=query", "", fiber.StatusOK, "abc", "frag"},
+		{"referer fragment", "abc", "", "https://lovebin.example/media/abc#enc_KEY", fiber.StatusOK, "abc", "enc_KEY"},
+		{"referer fragment not a key", "abc", "", "https://lovebin.example/faq#how%20it%20works", fiber.StatusOK, "abc", ""},
+		{"longest key", strings.Repeat("a", maxURLKeyLength), "", "", fiber.StatusOK, strings.Repeat("a", maxURLKeyLength), ""},
+		{"slash", "abc%2Fdef", "", "", fiber.StatusBadRequest, "", ""},
+		{"null byte", "abc%00", "", "", fiber.StatusBadRequest, "", ""},
+		{"double encoded", "abc%2541", "", "", fiber.StatusBadRequest, "", ""},
+		{"bad escape", "abc%zz", "", "", fiber.StatusBadRequest, "", ""},
+		{"too long", strings.Repeat("a", maxURLKeyLength+1), "", "", fiber.StatusBadRequest, "", ""},
+		{"second hash", "abc%23enc%23more", "", "", fiber.StatusBadRequest, "", ""},
+		{"fragment with dot", "abc%23enc.key", "", "", fiber.StatusBadRequest, "", ""},
+		{"query with slash", "abc", "enc_key=enc%2Fkey", "", fiber.StatusBadRequest, "", ""},
+		{"query too long", "abc", "enc_key=" + strings.Repeat("a", maxURLKeyLength+1), "", fiber.StatusBadRequest, "", ""},
+	}
+	app := keysApp()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, resourceKey, encKey, err := extractKeys(t, app, tt.segment, tt.query, tt.referer)
+			if err != nil {
+				t.Fatalf("request: %v", err)
+			}
+			if status != tt.wantStatus || resourceKey != tt.wantResourceKey || encKey != tt.wantEncKey {
+				t.Errorf("got %d %q %q, want %d %q %q", status, resourceKey, encKey, tt.wantStatus, tt.wantResourceKey, tt.wantEncKey)
+			}
+		})
+	}
+}
+
+func FuzzGetResourceKeyAndEncryptionKey(f *testing.F) {
+	for _, seed := range []struct{ key, encKey, referer string }{
+		{"abc", "", ""},
+		{"abc#enc", "", ""},
+		{"abc#", "", ""},
+		{"abc#enc#more", "", ""},
+		{"abc", "enc_KEY", ""},
+		{"abc", "", "https://lovebin.example/media/abc#enc"},
+		{"abc", "", "https://lovebin.example/#"},
+		{"a/b", "", ""},
+		{"%2F", "%2F", ""},
+		{"abc\x00", "", ""},
+		{"отчет", "", ""},
+		{"abc", "a/b", "::not a url"},
+	} {
+		f.Add(seed.key, seed.encKey, seed.referer)
+	}
+
+	app := keysApp()
+	f.Fuzz(func(t *testing.T, key, encKey, referer string) {
+		if key == "" || strings.ContainsAny(referer, "\r\n\x00") {
+			t.Skip()
+		}
+		query := ""
+		if encKey != "" {
+			query = url.Values{"enc_key": {encKey}}.Encode()
+		}
+		status, resourceKey, gotEncKey, err := extractKeys(t, app, url.PathEscape(key), query, referer)
+		if err != nil {
+			t.Skip()
+		}
+
+		switch status {
+		case fiber.StatusOK:
+			if !isURLKey(resourceKey) {
+				t.Errorf("resource key %q accepted from %q", resourceKey, key)
+			}
+			if gotEncKey != "" && !isURLKey(gotEncKey) {
+				t.Errorf("encryption key %q accepted", gotEncKey)
+			}
+		case fiber.StatusBadRequest:
+			// Keys as generated for share URLs are never rejected
+			if isURLKey(key) && (encKey == "" || isURLKey(encKey)) {
+				t.Errorf("key %q with enc_key %q rejected", key, encKey)
+			}
+		default:
+			t.Errorf("status = %d, want 200 or 400", status)
+		}
+	})
+}
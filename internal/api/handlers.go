@@ -1,12 +1,16 @@
 package api
 
 import (
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"html/template"
-	"io"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -14,7 +18,12 @@ import (
 
 	accessservice "lovebin/internal/services/access-service"
 	mediaservice "lovebin/internal/services/media-service"
+	"lovebin/modules/audit"
+	"lovebin/modules/encryption"
 	"lovebin/modules/logger"
+	"lovebin/modules/postgres"
+	"lovebin/modules/ratelimit"
+	"lovebin/modules/s3"
 	"lovebin/modules/timeparser"
 )
 
@@ -22,103 +31,346 @@ type Handlers struct {
 	logger        logger.Logger
 	mediaService  *mediaservice.Service
 	accessService *accessservice.Service
+	audit         audit.AuditReader
+	migrator      *postgres.Migrator
+	cfg           Config
+	progress      *progressTracker
+	templates     *TemplateResolver
+	uploadSlots   chan struct{} // semaphore of concurrent uploads, nil when unlimited
+
+	rateLimiter               ratelimit.Limiter               // per-IP limits of RateLimitPerIP
+	downloadLimiter           *ratelimit.SlidingWindowLimiter // download attempts per resource key
+	protectedDownloadsLimited atomic.Int64
+	openDownloadsLimited      atomic.Int64
+
+	benchRunning atomic.Bool // one encryption benchmark at a time
 }
 
+// Config holds handlers configuration
+type Config struct {
+	AdminToken    string           // bearer token for management routes (empty disables them)
+	SigningSecret string           // HMAC secret for signed download URLs (empty disables them)
+	SignedURLTTL  time.Duration    // default lifetime of signed download URLs
+	RequestDedup  bool             // share responses between concurrent identical view/preview requests
+	MaxUploadSize int64            // upload body limit, also enforced on streamed bodies (0 disables)
+	MIMELimits    map[string]int64 // upload limits by file type, see FileSizeGuard (needs MaxUploadSize)
+	GeoIPEnabled  bool             // country restrictions can be enforced
+
+	KeyWrappingKey  []byte // AES key of /api/v1/admin/keys/wrap and unwrap (nil disables them)
+	AdminSigningKey string // HMAC key of signed resource exports (empty disables them)
+
+	MaxConcurrentUploads int // uploads handled at once, further ones get 503 (0 is unlimited)
+
+	StorageHealth *s3.HealthMonitor // background storage check reported by /api/v1/health (nil omits it)
+	StorageBucket string            // bucket (or Azure container) reported by /api/v1/admin/storage
+
+	DevMode bool // re-read the frontend directory and its templates on every render
+
+	SwaggerEnabled bool // serve Swagger UI on /swagger/ and the OpenAPI spec on /swagger.json
+
+	RateLimiter ratelimit.Limiter // per-IP limits of rate limited routes (nil uses a token bucket)
+
+	UploadRateLimit  int           // uploads allowed per client IP within UploadRateWindow (0 disables the limit)
+	UploadRateWindow time.Duration // window of UploadRateLimit
+
+	Encryption    encryption.Encryption // timed by /api/v1/system/encryption-bench (nil disables it)
+	KDFIterations int                   // PBKDF2 iterations of the benchmark's kdf_time_ms
+	MaxBenchSize  int64                 // largest benchmark payload (default encryption.DefaultMaxBenchSize)
+}
+
+// uploadRetryAfter is the Retry-After value, in seconds, sent when all upload slots are taken
+const uploadRetryAfter = "5"
+
+// Download attempts allowed per resource key within downloadRateWindow, from all IPs together.
+// Protected resources get few attempts against password guessing, the others
+// enough for retries while still slowing down key guessing.
+const (
+	downloadRateWindow         = time.Minute
+	protectedDownloadRateLimit = 3
+	openDownloadRateLimit      = 50
+)
+
 func NewHandlers(
 	logger logger.Logger,
 	mediaService *mediaservice.Service,
 	accessService *accessservice.Service,
+	auditReader audit.AuditReader,
+	migrator *postgres.Migrator,
+	cfg Config,
 ) *Handlers {
-	return &Handlers{
-		logger:        logger,
+	if cfg.SignedURLTTL <= 0 {
+		cfg.SignedURLTTL = time.Hour // default
+	}
+	cfg.SignedURLTTL = min(cfg.SignedURLTTL, maxSignedURLTTL)
+	h := &Handlers{
+		logger:        logger.Child("api"),
 		mediaService:  mediaService,
 		accessService: accessService,
+		audit:         auditReader,
+		migrator:      migrator,
+		cfg:           cfg,
+		progress:      newProgressTracker(),
+		templates:     NewTemplateResolver(logger.Child("templates"), cfg.DevMode),
+
+		rateLimiter:     cfg.RateLimiter,
+		downloadLimiter: ratelimit.NewSlidingWindowLimiter(),
+	}
+	if h.rateLimiter == nil {
+		h.rateLimiter = ratelimit.NewTokenBucketLimiter()
+	}
+	if cfg.MaxConcurrentUploads > 0 {
+		h.uploadSlots = make(chan struct{}, cfg.MaxConcurrentUploads)
+	}
+	return h
+}
+
+// DownloadsRateLimited returns how many downloads of protected and unprotected
+// resources were rejected by the per-resource rate limit
+func (h *Handlers) DownloadsRateLimited() (protected, open int64) {
+	return h.protectedDownloadsLimited.Load(), h.openDownloadsLimited.Load()
+}
+
+// allowDownload applies the per-resource download rate limit. Unknown, expired
+// and viewed resources count as unprotected, CheckAccess rejects them right after.
+func (h *Handlers) allowDownload(c *fiber.Ctx, resourceKey string) bool {
+	access, err := h.accessService.CheckResourceAccess(c.Context(), resourceKey)
+	protected := err == nil && access.IsProtected()
+
+	limit, limited := openDownloadRateLimit, &h.openDownloadsLimited
+	if protected {
+		limit, limited = protectedDownloadRateLimit, &h.protectedDownloadsLimited
+	}
+
+	// Whatever the configured algorithm, bursts of password guesses must not get through
+	decision, _ := h.downloadLimiter.Allow(c.Context(), resourceKey, limit, downloadRateWindow)
+	ok := decision.Allowed
+	if !ok {
+		limited.Add(1)
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(time.Until(decision.Reset).Seconds())+1))
+		h.logger.WarnCtx(c.Context(), "download rate limit reached",
+			zap.String("resource_key", resourceKey), zap.Bool("protected", protected))
+	}
+	return ok
+}
+
+// acquireUploadSlot takes an upload slot without waiting, reporting false when all are taken
+func (h *Handlers) acquireUploadSlot() bool {
+	if h.uploadSlots == nil {
+		return true
+	}
+	select {
+	case h.uploadSlots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (h *Handlers) releaseUploadSlot() {
+	if h.uploadSlots != nil {
+		<-h.uploadSlots
 	}
 }
 
 type UploadRequest struct {
-	Password    string                   `json:"password,omitempty" form:"password"`
-	ExpiresIn   timeparser.UniversalTime `json:"expires_in" form:"expires_in"`
-	BlurEnabled bool                     `json:"blur_enabled" form:"blur_enabled"`
+	Password         string                    `json:"password,omitempty" form:"password"`
+	ExpiresIn        timeparser.UniversalTime  `json:"expires_in" form:"expires_in"`
+	BlurIntensity    float64                   `json:"blur_intensity" form:"blur_intensity"`
+	DownloadOnly     bool                      `json:"download_only" form:"download_only"`
+	AccessCodes      []string                  `json:"access_codes,omitempty" form:"access_codes"`
+	MaxViews         int                       `json:"max_views,omitempty" form:"max_views"`
+	AllowedCountries []string                  `json:"allowed_countries,omitempty" form:"allowed_countries"`
+	AvailableAt      *timeparser.UniversalTime `json:"available_at,omitempty" form:"available_at"`
 }
 
 type UploadResponse struct {
-	ResourceKey string                   `json:"resource_key"`
-	URL         string                   `json:"url"`
-	ExpiresIn   timeparser.UniversalTime `json:"expires_in"`
+	ResourceKey  string                    `json:"resource_key"`
+	URL          string                    `json:"url"`
+	ExpiresIn    timeparser.UniversalTime  `json:"expires_in"`
+	DownloadOnly bool                      `json:"download_only"`
+	AccessCodes  []string                  `json:"access_codes,omitempty"`
+	AvailableAt  *timeparser.UniversalTime `json:"available_at,omitempty"`
+	MaxViews     int                       `json:"max_views,omitempty"` // downloads allowed, one per access code
+	// ManifestSignature is the base64 ASN.1 ECDSA P-256 signature of the upload manifest
+	ManifestSignature string `json:"manifest_signature,omitempty"`
+	// VerificationPublicKey is the base64 PKIX (DER) public key the manifest is checked with
+	VerificationPublicKey string `json:"verification_public_key,omitempty"`
 }
 
 // UploadMedia handles media upload
 // @Summary      Upload media file
-// @Description  Upload a media file (photo or video) with optional password protection and expiration time. ExpiresIn supports: duration (1h, 24h, 7d, 2w, 1y) or absolute time (RFC3339, ISO8601, Unix timestamp)
+// @Description  Upload a media file (photo or video) with optional password protection and expiration time. ExpiresIn supports: duration (1h, 24h, 7d, 2w, 1y) or absolute time (RFC3339, ISO8601, Unix timestamp). Offline verification: manifest_signature is an ECDSA P-256 signature over SHA-256(resource key + content hash + expires at) made with a one-time key, verification_public_key is that key. The resource key is resource_key without the #fragment, the content hash is the lowercase hex SHA-256 of the downloaded file and expires at is expires_in as returned (RFC 3339 in UTC), empty when the file never expires. The key is discarded after signing, so a valid signature proves the file is the one uploaded, e.g. with `openssl dgst -sha256 -verify pub.der -keyform DER -signature sig.der manifest.txt`.
 // @Tags         media
 // @Accept       multipart/form-data
 // @Produce      json
-// @Param        file        formData  file    true   "Media file to upload"
+// @Param        file        formData  file    true   "Media file to upload, after the other fields as it is stored while it arrives"
 // @Param        password    formData  string  false  "Optional password for access protection"
 // @Param        expires_in  formData  string  false  "Expiration time: duration (1h, 24h, 7d, 2w) or absolute (RFC3339, ISO8601, Unix timestamp). Leave empty for no expiration"
+// @Param        blur_intensity  formData  number  false  "Blur strength of the image preview, from 0 (no blur, default) to 1 (maximum blur)"
+// @Param        download_only  formData  string  false  "Skip the view page and redirect straight to download: true or false"
+// @Param        available_at  formData  string  false  "Scheduled reveal: the file cannot be opened before this time (same formats as expires_in, must be before it)"
+// @Param        allowed_countries  formData  string  false  "Comma separated ISO 3166-1 alpha-2 country codes allowed to open the file (needs GEOIP_DB_PATH)"
+// @Param        access_codes  formData  string  false  "Up to 100 single-use access codes, one per line or comma separated. Each code opens the file once; cannot be combined with password"
+// @Param        max_views   formData  integer false  "Number of downloads allowed, 1 (default) to 100; the file is deleted after the last one. With access_codes every code is one download instead"
+// @Param        session_id  formData  string  false  "Optional progress session ID from /upload/init-progress"
+// @Param        X-Timezone  header    string  false  "IANA timezone for expires_in values without an offset (default UTC), also accepted as tz query param"
 // @Success      200  {object}  UploadResponse
-// @Failure      400  {object}  map[string]string
+// @Failure      400  {object}  ValidationError
+// @Failure      413  {object}  map[string]string  "Body above SERVER_MAX_BODY_BYTES or file above the SERVER_MIME_LIMITS entry of its type, e.g. {\"error\": \"files of type video/* are limited to 524288000 bytes, the request body is 600000000 bytes\"}"
+// @Failure      429  {object}  map[string]string  "Too many uploads from this IP (RATE_LIMIT_UPLOAD_LIMIT per RATE_LIMIT_UPLOAD_WINDOW), retry after the Retry-After seconds"
 // @Failure      500  {object}  map[string]string
-// @Router       /upload [post]
+// @Failure      503  {object}  map[string]string  "Too many uploads in progress, retry after the Retry-After seconds"
+// @Failure      507  {object}  map[string]string  "Uploads from this IP use up their storage quota (MAX_STORAGE_PER_IP_BYTES)"
+// @Router       /api/v1/upload [post]
 func (h *Handlers) UploadMedia(c *fiber.Ctx) error {
-	// Get file from multipart form first
-	file, err := c.FormFile("file")
+	// Every upload streams its file to storage through part buffers and a storage
+	// connection, so their number is capped before the body is read
+	if !h.acquireUploadSlot() {
+		// The unread body would corrupt the next request on a kept-alive connection
+		c.Context().SetConnectionClose()
+		c.Set(fiber.HeaderRetryAfter, uploadRetryAfter)
+		if c.Get("HX-Request") == "true" {
+			return h.renderResult(c, false, "", "Сервер сейчас загружает слишком много файлов. Попробуйте через несколько секунд.", timeparser.UniversalTime{})
+		}
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "too many uploads in progress",
+		})
+	}
+	defer h.releaseUploadSlot()
+
+	// Read the fields, the file is stored as it arrives (missing file is reported as a field error)
+	form, err := readUploadForm(c)
+	defer func() {
+		if !form.done {
+			// The unread body would corrupt the next request on a kept-alive connection
+			c.Context().SetConnectionClose()
+		}
+	}()
+	if errors.Is(err, errRequestBodyTooLarge) {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+			"error": "request body too large",
+		})
+	}
+	if errors.Is(err, errUploadFormTooLarge) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
 	if err != nil {
-		return h.renderError(c, "Файл не указан в форме")
-	}
-
-	// Parse form data
-	var req UploadRequest
-	req.Password = c.FormValue("password")
-	expiresInStr := c.FormValue("expires_in")
-	blurEnabledStr := c.FormValue("blur_enabled")
-	req.BlurEnabled = blurEnabledStr == "true"
-
-	// Parse expires_in using universal time parser
-	if expiresInStr != "" {
-		if err := req.ExpiresIn.UnmarshalText([]byte(expiresInStr)); err != nil {
-			// Return HTML error for HTMX
-			if c.Get("HX-Request") == "true" {
-				return h.renderResult(c, false, "", "Неверный формат времени: "+err.Error(), timeparser.UniversalTime{})
-			}
-			return h.renderError(c, "Неверный формат времени: "+err.Error())
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "failed to read upload form",
+		})
+	}
+	file := form.file
+
+	// FileSizeGuard only rejects what Content-Length rules out, the file itself is checked as it is read
+	data := form.data
+	if file != nil {
+		if pattern, limit, ok := mimeLimit(h.cfg.MIMELimits, partContentType(file.Header.Get(fiber.HeaderContentType))); ok {
+			data = &limitReader{r: data, remaining: limit, err: &fileTooLargeError{pattern: pattern, limit: limit}}
 		}
+	}
 
-		// Проверяем, что время в будущем
-		if !req.ExpiresIn.IsZero() && req.ExpiresIn.Time.Before(time.Now().UTC()) {
-			// Return HTML error for HTMX
-			if c.Get("HX-Request") == "true" {
-				return h.renderResult(c, false, "", "Время истечения должно быть в будущем", timeparser.UniversalTime{})
+	// Storing the file is reported until the upload ends. It overlaps with receiving
+	// the body, whose progress stands in while the stored size is unknown.
+	var storeProgress func(uploadedBytes, totalBytes int64)
+	if sessionID := form.value("session_id"); sessionID != "" {
+		defer h.progress.finish(sessionID)
+		if session, ok := h.progress.get(sessionID); ok {
+			storeProgress = func(uploadedBytes, totalBytes int64) {
+				if totalBytes >= 0 {
+					session.publish(ProgressEvent{Bytes: uploadedBytes, Total: totalBytes, Phase: ProgressPhaseStore})
+				}
 			}
-			return h.renderError(c, "Время истечения должно быть в будущем")
 		}
-	} else {
-		req.ExpiresIn = timeparser.NewUniversalTime(time.Now().Add(24 * time.Hour))
 	}
 
-	// Open file
-	src, err := file.Open()
-	if err != nil {
-		h.logger.Error("failed to open file", zap.Error(err))
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "failed to process file",
-		})
+	// Parse and validate form data, collecting all field errors
+	req, verr := validateUploadForm(uploadFormValues{
+		File:             file,
+		Password:         form.value("password"),
+		ExpiresIn:        form.value("expires_in"),
+		AvailableAt:      form.value("available_at"),
+		BlurIntensity:    form.value("blur_intensity"),
+		DownloadOnly:     form.value("download_only"),
+		AccessCodes:      form.value("access_codes"),
+		MaxViews:         form.value("max_views"),
+		AllowedCountries: form.value("allowed_countries"),
+		GeoIPEnabled:     h.cfg.GeoIPEnabled,
+		Location:         requestLocation(c),
+	})
+	if verr.HasErrors() {
+		// Return HTML field errors for HTMX
+		if c.Get("HX-Request") == "true" {
+			return h.renderFieldErrors(c, verr)
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(verr)
 	}
-	defer src.Close()
 
 	// Upload media
 	uploadReq := mediaservice.UploadRequest{
-		Data:        src,
-		Password:    req.Password,
-		ExpiresAt:   req.ExpiresIn,
-		Filename:    file.Filename,
-		BlurEnabled: req.BlurEnabled,
+		Data:             data,
+		Size:             -1,
+		Password:         req.Password,
+		ExpiresAt:        req.ExpiresIn,
+		Filename:         file.Filename,
+		BlurIntensity:    req.BlurIntensity,
+		DownloadOnly:     req.DownloadOnly,
+		UploadIP:         c.IP(),
+		AccessCodes:      req.AccessCodes,
+		MaxViews:         req.MaxViews,
+		AllowedCountries: req.AllowedCountries,
+		AvailableAt:      req.AvailableAt,
+		Progress:         storeProgress,
 	}
 
 	resp, err := h.mediaService.UploadMedia(c.Context(), uploadReq)
+	var tooLarge *fileTooLargeError
+	if errors.As(err, &tooLarge) {
+		if c.Get("HX-Request") == "true" {
+			return h.renderResult(c, false, "", fmt.Sprintf("Файлы типа %s не могут быть больше %d байт.", tooLarge.pattern, tooLarge.limit), timeparser.UniversalTime{})
+		}
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+			"error": tooLarge.Error(),
+		})
+	}
+	if errors.Is(err, errRequestBodyTooLarge) {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+			"error": "request body too large",
+		})
+	}
+	if errors.Is(err, errFieldsAfterFile) {
+		if c.Get("HX-Request") == "true" {
+			return h.renderResult(c, false, "", "Поля формы должны идти перед файлом.", timeparser.UniversalTime{})
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	var weak *mediaservice.WeakPasswordError
+	if errors.As(err, &weak) {
+		verr := weakPasswordError(weak)
+		if c.Get("HX-Request") == "true" {
+			return h.renderFieldErrors(c, verr)
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(verr)
+	}
+	if verr := uploadOptionsError(err); verr != nil {
+		if c.Get("HX-Request") == "true" {
+			return h.renderFieldErrors(c, verr)
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(verr)
+	}
+	if errors.Is(err, mediaservice.ErrStorageQuotaExceeded) {
+		if c.Get("HX-Request") == "true" {
+			return h.renderResult(c, false, "", "Превышен лимит хранилища для вашего IP. Дождитесь, пока старые файлы истекут или будут просмотрены.", timeparser.UniversalTime{})
+		}
+		return c.Status(fiber.StatusInsufficientStorage).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
 	if err != nil {
-		h.logger.Error("failed to upload media", zap.Error(err))
+		h.logger.ErrorCtx(c.Context(), "failed to upload media", zap.Error(err))
 		// Return HTML error for HTMX
 		if c.Get("HX-Request") == "true" {
 			return h.renderResult(c, false, "", "Не удалось загрузить файл. Попробуйте еще раз.", timeparser.UniversalTime{})
@@ -157,13 +409,25 @@ func (h *Handlers) UploadMedia(c *fiber.Ctx) error {
 
 		fullURL := proto + "://" + host + resp.URL
 
-		return h.renderResult(c, true, fullURL, "", req.ExpiresIn)
+		return h.renderResultData(c, resultData{
+			Success:   true,
+			URL:       fullURL,
+			ExpiresIn: req.ExpiresIn,
+			MaxViews:  resp.MaxViews,
+		})
 	}
 
 	return c.JSON(UploadResponse{
-		ResourceKey: resp.ResourceKey,
-		URL:         resp.URL,
-		ExpiresIn:   req.ExpiresIn,
+		ResourceKey:  resp.ResourceKey,
+		URL:          resp.URL,
+		ExpiresIn:    req.ExpiresIn,
+		DownloadOnly: req.DownloadOnly,
+		AccessCodes:  resp.AccessCodes,
+		AvailableAt:  req.AvailableAt,
+		MaxViews:     resp.MaxViews,
+
+		ManifestSignature:     base64.StdEncoding.EncodeToString(resp.ManifestSignature),
+		VerificationPublicKey: base64.StdEncoding.EncodeToString(resp.VerificationPubkey),
 	})
 }
 
@@ -171,13 +435,207 @@ type DownloadRequest struct {
 	Password string `json:"password,omitempty"`
 }
 
+type SignedURLRequest struct {
+	TTLSeconds int64 `json:"ttl_seconds,omitempty"`
+	SignedOnly bool  `json:"signed_only,omitempty"` // from now on the resource only opens with a valid signed URL
+}
+
+type SignedURLResponse struct {
+	URL       string                   `json:"url"` // without the encryption key, append it as the #fragment
+	ExpiresAt timeparser.UniversalTime `json:"expires_at"`
+}
+
+// maxSignedURLTTL caps the lifetime of signed download URLs
+const maxSignedURLTTL = 30 * 24 * time.Hour
+
+// CreateSignedURL generates a time-limited signed download URL
+// @Summary      Create signed download URL
+// @Description  Generate a download URL that stops working after the given TTL (at most 30 days, longer TTLs are capped). The signature is HMAC-SHA256(resourceKey + expires + encKeyBase64), so the encryption key must be passed, but the URL does not carry it: append it as the URL fragment (#key) before sharing. With signed_only the resource only opens with a valid signature from then on, otherwise unsigned links keep working. Requires management token.
+// @Tags         media
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        key      path      string            true  "Resource key with encryption key (format: resourceKey#encryptionKey)"
+// @Param        enc_key  query     string            false "Encryption key, if not passed in the key"
+// @Param        request  body      SignedURLRequest  true  "Optional TTL in seconds and signed_only"
+// @Success      200      {object}  SignedURLResponse
+// @Failure      400      {object}  map[string]string
+// @Failure      401      {object}  map[string]string
+// @Failure      404      {object}  map[string]string
+// @Failure      503      {object}  map[string]string
+// @Router       /api/v1/media/{key}/signed-url [post]
+func (h *Handlers) CreateSignedURL(c *fiber.Ctx) error {
+	if h.cfg.SigningSecret == "" {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "signed URLs are not configured",
+		})
+	}
+
+	resourceKey, encKeyBase64, err := getResourceKeyAndEncryptionKey(c)
+	if err != nil {
+		return err
+	}
+	// The key is signed along with the resource, a signature is only valid with it
+	if encKeyBase64 == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "encryption key is required",
+		})
+	}
+
+	var req SignedURLRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+	if req.TTLSeconds < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ttl_seconds must be positive",
+		})
+	}
+
+	// Links to scheduled resources can be signed in advance
+	switch _, err := h.accessService.CheckResourceAccess(c.Context(), resourceKey); err {
+	case nil, accessservice.ErrNotYetAvailable:
+	case accessservice.ErrNotFound, accessservice.ErrExpired, accessservice.ErrAlreadyViewed:
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "resource not found",
+		})
+	default:
+		h.logger.ErrorCtx(c.Context(), "failed to check resource access", zap.Error(err), zap.String("resource_key", resourceKey))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to create signed URL",
+		})
+	}
+
+	// Otherwise the signature can simply be stripped from the link
+	if req.SignedOnly {
+		if err := h.accessService.RequireSignedURL(c.Context(), resourceKey); err != nil {
+			if err == accessservice.ErrNotFound {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": "resource not found",
+				})
+			}
+			h.logger.ErrorCtx(c.Context(), "failed to require signed URLs", zap.Error(err), zap.String("resource_key", resourceKey))
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to create signed URL",
+			})
+		}
+	}
+
+	ttl := h.cfg.SignedURLTTL
+	if req.TTLSeconds > 0 {
+		// Compared in seconds, the Duration of a huge TTL would overflow
+		ttl = time.Duration(min(req.TTLSeconds, int64(maxSignedURLTTL/time.Second))) * time.Second
+	}
+	expiresAt := time.Now().Add(ttl).Unix()
+	signature := encryption.SignURL(h.cfg.SigningSecret, resourceKey, expiresAt, encKeyBase64)
+
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expiresAt, 10))
+	query.Set("sig", signature)
+
+	return c.JSON(SignedURLResponse{
+		URL:       "/media/" + url.QueryEscape(resourceKey) + "/download?" + query.Encode(),
+		ExpiresAt: timeparser.NewUniversalTime(time.Unix(expiresAt, 0)),
+	})
+}
+
+// verifySignedURL checks the sig and expires query params against the resource
+// and encryption keys. They are required when the resource was made signed-only,
+// otherwise checked if present.
+func (h *Handlers) verifySignedURL(c *fiber.Ctx, resourceKey, encKeyBase64 string, required bool) error {
+	signature := c.Query("sig", "")
+	expiresStr := c.Query("expires", "")
+	if signature == "" && expiresStr == "" && !required {
+		return nil
+	}
+
+	expiresAt, err := strconv.ParseInt(expiresStr, 10, 64)
+	if err != nil || signature == "" || h.cfg.SigningSecret == "" {
+		return errInvalidSignature
+	}
+	if !encryption.VerifyURLSignature(h.cfg.SigningSecret, resourceKey, expiresAt, encKeyBase64, signature) {
+		return errInvalidSignature
+	}
+	// Checked after the signature, so an expiry cannot be probed with a forged one
+	if time.Now().Unix() > expiresAt {
+		return errSignatureExpired
+	}
+
+	return nil
+}
+
+// checkSignedURL is verifySignedURL for a resource whose access info was not loaded yet
+func (h *Handlers) checkSignedURL(c *fiber.Ctx, resourceKey, encKeyBase64 string) error {
+	access, err := h.accessService.CheckResourceAccess(c.Context(), resourceKey)
+	switch err {
+	case nil, accessservice.ErrNotYetAvailable:
+	case accessservice.ErrNotFound, accessservice.ErrExpired, accessservice.ErrAlreadyViewed:
+		// Rejected by the access checks that follow
+		return nil
+	default:
+		// Without the access info a signature is required, so the check fails closed
+		h.logger.ErrorCtx(c.Context(), "failed to check resource access", zap.Error(err), zap.String("resource_key", resourceKey))
+		return h.verifySignedURL(c, resourceKey, encKeyBase64, true)
+	}
+	return h.verifySignedURL(c, resourceKey, encKeyBase64, access.SignedURLRequired)
+}
+
+// signedURLQuery returns the signature params of the request, for links to the
+// download and preview of a resource that was opened with a signed URL
+func signedURLQuery(c *fiber.Ctx) []string {
+	if c.Query("sig", "") == "" {
+		return nil
+	}
+	return []string{
+		"expires=" + url.QueryEscape(c.Query("expires", "")),
+		"sig=" + url.QueryEscape(c.Query("sig", "")),
+	}
+}
+
+// renderSignatureError renders the error page of a rejected signed URL
+func (h *Handlers) renderSignatureError(c *fiber.Ctx, err error) error {
+	if err == errSignatureExpired {
+		return h.renderErrorStatus(c, fiber.StatusGone, "Срок действия ссылки истек")
+	}
+	return h.renderErrorStatus(c, fiber.StatusForbidden, "Недействительная подпись ссылки")
+}
+
+var (
+	errInvalidSignature = fiber.NewError(fiber.StatusForbidden, "invalid URL signature")
+	errSignatureExpired = fiber.NewError(fiber.StatusGone, "signed URL expired")
+)
+
+// TimezoneHeader carries the client's IANA timezone, e.g. "Europe/Moscow"
+const TimezoneHeader = "X-Timezone"
+
+// requestLocation returns the client timezone from the X-Timezone header or tz query param.
+// Missing or unknown timezones fall back to UTC.
+func requestLocation(c *fiber.Ctx) *time.Location {
+	name := c.Get(TimezoneHeader)
+	if name == "" {
+		name = c.Query("tz")
+	}
+	if name == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
 // getResourceKeyAndEncryptionKey extracts resource key and encryption key from request
 // Supports formats:
 // - /media/resourceKey#encKey
 // - /media/resourceKey?password=xxx#encKey (fragment from full URL)
 // - /media/resourceKey?enc_key=xxx (fallback if fragment not available)
 func getResourceKeyAndEncryptionKey(c *fiber.Ctx) (resourceKey string, encKeyBase64 string, err error) {
-	resourceKeyEncoded := c.Params("key")
+	// Fiber strings point into reused request buffers, the key outlives the
+	// request as a cache and repository map key
+	resourceKeyEncoded := strings.Clone(c.Params("key"))
 	if resourceKeyEncoded == "" {
 		return "", "", fiber.NewError(fiber.StatusBadRequest, "Resource key is required")
 	}
@@ -193,26 +651,36 @@ func getResourceKeyAndEncryptionKey(c *fiber.Ctx) (resourceKey string, encKeyBas
 
 	// Try to get encryption key from multiple sources:
 	// 1. From fragment in the resourceKey itself (format: resourceKey#encKey)
-	parts := strings.Split(resourceKey, "#")
-	if len(parts) > 1 {
-		resourceKey = parts[0]
-		encKeyBase64 = parts[1]
+	resourceKey, encKeyBase64, found := strings.Cut(resourceKey, "#")
+
+	// Keys are URL-safe base64, anything else (slashes, null bytes, leftover
+	// percent escapes of double-encoded input) never names a resource
+	if !isURLKey(resourceKey) {
+		return "", "", fiber.NewError(fiber.StatusBadRequest, "Invalid resource key")
+	}
+	if found {
+		if encKeyBase64 != "" && !isURLKey(encKeyBase64) {
+			return "", "", fiber.NewError(fiber.StatusBadRequest, "Invalid encryption key encoding")
+		}
 		return resourceKey, encKeyBase64, nil
 	}
 
 	// 2. From query parameter (if fragment was passed as query param by JavaScript)
 	encKeyBase64 = c.Query("enc_key", "")
 	if encKeyBase64 != "" {
+		if !isURLKey(encKeyBase64) {
+			return "", "", fiber.NewError(fiber.StatusBadRequest, "Invalid encryption key encoding")
+		}
 		return resourceKey, encKeyBase64, nil
 	}
 
-	// 3. Try to extract from Referer header if available (for browser requests with fragment)
+	// 3. Try to extract from Referer header if available (for browser requests with fragment).
+	// The referer may be any page, fragments that are not keys are ignored.
 	referer := c.Get("Referer")
 	if referer != "" {
 		if parsedURL, parseErr := url.Parse(referer); parseErr == nil {
-			if parsedURL.Fragment != "" {
-				encKeyBase64 = parsedURL.Fragment
-				return resourceKey, encKeyBase64, nil
+			if isURLKey(parsedURL.Fragment) {
+				return resourceKey, parsedURL.Fragment, nil
 			}
 		}
 	}
@@ -221,18 +689,48 @@ func getResourceKeyAndEncryptionKey(c *fiber.Ctx) (resourceKey string, encKeyBas
 	fullURL := c.OriginalURL()
 	if fullURL != "" {
 		if parsedURL, parseErr := url.Parse(fullURL); parseErr == nil {
-			if parsedURL.Fragment != "" {
-				encKeyBase64 = parsedURL.Fragment
-				return resourceKey, encKeyBase64, nil
+			if isURLKey(parsedURL.Fragment) {
+				return resourceKey, parsedURL.Fragment, nil
 			}
 		}
 	}
 
 	// Return resourceKey without encryption key (will be handled by service layer)
-	return resourceKey, encKeyBase64, nil
+	return resourceKey, "", nil
+}
+
+// maxURLKeyLength bounds resource and encryption keys taken from a request
+const maxURLKeyLength = 128
+
+// isURLKey reports whether s looks like a key from a share URL: non-empty,
+// at most maxURLKeyLength bytes of URL-safe base64 with optional padding
+func isURLKey(s string) bool {
+	if s == "" || len(s) > maxURLKeyLength {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9', c == '-', c == '_', c == '=':
+		default:
+			return false
+		}
+	}
+	return true
 }
 
 // ViewMedia handles media view page (HTML with preview and download button)
+// @Summary      View media page
+// @Description  HTML page with preview and download button. Download-only resources redirect (307) to the download endpoint instead.
+// @Tags         media
+// @Produce      html
+// @Param        key       path   string  true   "Resource key"
+// @Param        password  query  string  false  "Password for protected resources"
+// @Param        enc_key   query  string  false  "Encryption key (normally passed in the URL fragment)"
+// @Success      200  {string}  string  "View page"
+// @Success      307  {string}  string  "Redirect to /media/{key}/download for download-only resources"
+// @Failure      400  {string}  string  "Error page"
+// @Failure      425  {string}  string  "Countdown page of a scheduled resource"
+// @Router       /media/{key} [get]
 func (h *Handlers) ViewMedia(c *fiber.Ctx) error {
 	resourceKey, encKeyBase64, err := getResourceKeyAndEncryptionKey(c)
 	if err != nil {
@@ -252,20 +750,31 @@ func (h *Handlers) ViewMedia(c *fiber.Ctx) error {
 			return h.renderError(c, "Ресурс истек")
 		case accessservice.ErrAlreadyViewed:
 			return h.renderAlreadyViewed(c)
+		case accessservice.ErrNotYetAvailable:
+			return h.renderNotYetAvailable(c, accessInfo.AvailableAt)
 		default:
 			return h.renderError(c, "Ошибка при проверке доступа")
 		}
 	}
 
+	// Signed URLs carry the key in the fragment, the redirect page passes it on
+	if encKeyBase64 == "" && c.Query("sig", "") != "" {
+		return h.renderKeyRedirect(c)
+	}
+	if err := h.verifySignedURL(c, resourceKey, encKeyBase64, accessInfo.SignedURLRequired); err != nil {
+		return h.renderSignatureError(c, err)
+	}
+
 	// If password is required but not provided, show password modal
-	passwordRequired := accessInfo.PasswordHash != nil && *accessInfo.PasswordHash != ""
+	// Access codes are entered into the same modal as a password
+	passwordRequired := (accessInfo.PasswordHash != nil && *accessInfo.PasswordHash != "") || accessInfo.HasAccessCodes
 	if passwordRequired && password == "" {
 		// Show page with password modal
-		return h.renderViewPageWithPasswordModal(c, resourceKey, resourceKey)
+		return h.renderViewPageWithPasswordModal(c, resourceKey, resourceKey, encKeyBase64)
 	}
 
 	// Verify access with password
-	err = h.accessService.VerifyAccess(c.Context(), resourceKey, password)
+	err = h.accessService.CheckAccess(c.Context(), resourceKey, password)
 	if err != nil {
 		switch err {
 		case accessservice.ErrNotFound:
@@ -274,16 +783,20 @@ func (h *Handlers) ViewMedia(c *fiber.Ctx) error {
 			return h.renderError(c, "Ресурс истек")
 		case accessservice.ErrAlreadyViewed:
 			return h.renderAlreadyViewed(c)
+		case accessservice.ErrCountryBlocked:
+			return h.renderError(c, "Доступ к ресурсу из вашей страны запрещен")
+		case accessservice.ErrNotYetAvailable:
+			return h.renderNotYetAvailable(c, accessInfo.AvailableAt)
 		case accessservice.ErrPasswordRequired, accessservice.ErrInvalidPassword:
 			// Show page with password modal and error
-			return h.renderViewPageWithPasswordModal(c, resourceKey, resourceKey, "Неверный пароль")
+			return h.renderViewPageWithPasswordModal(c, resourceKey, resourceKey, encKeyBase64, "Неверный пароль")
 		default:
 			return h.renderError(c, "Ошибка при проверке доступа")
 		}
 	}
 
 	// Get media info
-	mediaInfo, err := h.mediaService.GetMediaInfo(c.Context(), resourceKey)
+	mediaInfo, err := h.mediaService.GetMediaInfo(c.Context(), resourceKey, encKeyBase64)
 	if err != nil {
 		if err == mediaservice.ErrNotFound {
 			return h.renderError(c, "Ресурс не найден")
@@ -291,8 +804,13 @@ func (h *Handlers) ViewMedia(c *fiber.Ctx) error {
 		return h.renderError(c, "Ошибка при получении информации о ресурсе")
 	}
 
-	// Log blur enabled for debugging
-	h.logger.Info("Media info retrieved", zap.Bool("blur_enabled", mediaInfo.BlurEnabled), zap.String("resource_key", resourceKey))
+	// The filename is encrypted, the key is needed to show it
+	if mediaInfo.MetadataEncrypted != nil && encKeyBase64 == "" {
+		return h.renderKeyRedirect(c)
+	}
+
+	// Log blur intensity for debugging
+	h.logger.InfoCtx(c.Context(), "Media info retrieved", zap.Float64("blur_intensity", mediaInfo.BlurIntensity), zap.String("resource_key", resourceKey))
 
 	// Build download URL with encryption key as query param
 	downloadURL := "/media/" + url.QueryEscape(resourceKey) + "/download"
@@ -303,10 +821,19 @@ func (h *Handlers) ViewMedia(c *fiber.Ctx) error {
 	if encKeyBase64 != "" {
 		queryParams = append(queryParams, "enc_key="+url.QueryEscape(encKeyBase64))
 	}
+	queryParams = append(queryParams, signedURLQuery(c)...)
 	if len(queryParams) > 0 {
 		downloadURL += "?" + strings.Join(queryParams, "&")
 	}
 
+	// Download-only resources skip the view page
+	if mediaInfo.DownloadOnly {
+		if encKeyBase64 == "" {
+			return h.renderKeyRedirect(c)
+		}
+		return c.Redirect(downloadURL, fiber.StatusTemporaryRedirect)
+	}
+
 	// Build preview URL for images (with enc_key as query param, not fragment)
 	previewURL := ""
 	if mediaInfo.IsImage {
@@ -318,6 +845,7 @@ func (h *Handlers) ViewMedia(c *fiber.Ctx) error {
 		if encKeyBase64 != "" {
 			queryParams = append(queryParams, "enc_key="+url.QueryEscape(encKeyBase64))
 		}
+		queryParams = append(queryParams, signedURLQuery(c)...)
 		if len(queryParams) > 0 {
 			previewURL += "?" + strings.Join(queryParams, "&")
 		}
@@ -338,12 +866,22 @@ func (h *Handlers) ViewMedia(c *fiber.Ctx) error {
 	return h.renderViewPage(c, mediaInfo, displayFilename, downloadURL, previewURL, false, "")
 }
 
+// renderKeyRedirect serves the page that reloads the current URL with the key
+// from the URL fragment moved to the enc_key query param
+func (h *Handlers) renderKeyRedirect(c *fiber.Ctx) error {
+	c.Set("Content-Type", "text/html; charset=utf-8")
+	return c.SendFile(filepath.Join(h.templates.Dir(), "download-redirect.html"))
+}
+
 // renderViewPageWithPasswordModal renders the view page with password modal
-func (h *Handlers) renderViewPageWithPasswordModal(c *fiber.Ctx, resourceKey, resourceKeyForCheck string, errorMsg ...string) error {
+func (h *Handlers) renderViewPageWithPasswordModal(c *fiber.Ctx, resourceKey, resourceKeyForCheck, encKeyBase64 string, errorMsg ...string) error {
 	// Get media info (without password check, just to get file info)
 	// Note: GetMediaInfo uses GetMediaResourceByKey which checks viewed=false, so it might fail
 	// We'll try to get basic info, but if it fails, we'll still show the modal
-	mediaInfo, err := h.mediaService.GetMediaInfo(c.Context(), resourceKeyForCheck)
+	mediaInfo, err := h.mediaService.GetMediaInfo(c.Context(), resourceKeyForCheck, encKeyBase64)
+	if err == nil && mediaInfo.MetadataEncrypted != nil && encKeyBase64 == "" {
+		return h.renderKeyRedirect(c)
+	}
 
 	// Build filename for display (use default if we can't get info)
 	displayFilename := "file"
@@ -376,19 +914,25 @@ func (h *Handlers) renderViewPageWithPasswordModal(c *fiber.Ctx, resourceKey, re
 	return h.renderViewPage(c, mediaInfo, displayFilename, "", "", true, errorMessage)
 }
 
-// DownloadMediaFile handles media download (one-time view) - direct file download
+// DownloadMediaFile handles media download (uses up one view) - direct file download
 // @Summary      Download media file
-// @Description  Download a media file. The file will be deleted after first successful download. Requires encryption key in URL fragment.
+// @Description  Download a media file. Every successful download uses up one of the views set with max_views on upload, the file is deleted after the last one. Requires encryption key in URL fragment. The Content-Type is the one detected on upload, application/octet-stream for files uploaded before types were recorded.
 // @Tags         media
 // @Accept       json
-// @Produce      application/octet-stream
+// @Produce      application/octet-stream,application/zip
 // @Param        key       path      string  true   "Resource key with encryption key (format: resourceKey#encryptionKey)"
 // @Param        password  query     string  false  "Password if resource is password protected"
+// @Param        download_mode  query  string  false  "Set to zip to get a ZIP with the file and a README"  Enums(zip)
+// @Param        expires   query     int     false  "Expiry of a signed URL (Unix seconds)"
+// @Param        sig       query     string  false  "Signature of a signed URL, required for signed-only resources"
 // @Success      200       {file}    binary
 // @Failure      400       {object}  map[string]string
 // @Failure      401       {object}  map[string]string
+// @Failure      403       {object}  map[string]string
 // @Failure      404       {object}  map[string]string
 // @Failure      410       {object}  map[string]string
+// @Failure      425       {string}  string  "Countdown page of a scheduled resource"
+// @Failure      429       {string}  string  "Too many download attempts for this resource (3 per minute when password protected, otherwise 50)"
 // @Failure      500       {object}  map[string]string
 // @Router       /media/{key}/download [get]
 func (h *Handlers) DownloadMediaFile(c *fiber.Ctx) error {
@@ -397,11 +941,24 @@ func (h *Handlers) DownloadMediaFile(c *fiber.Ctx) error {
 		return err
 	}
 
+	// Signed URLs carry the key in the fragment, the redirect page passes it on
+	if encKeyBase64 == "" && c.Query("sig", "") != "" {
+		return h.renderKeyRedirect(c)
+	}
+	if err := h.checkSignedURL(c, resourceKey, encKeyBase64); err != nil {
+		return h.renderSignatureError(c, err)
+	}
+
+	if !h.allowDownload(c, resourceKey) {
+		return h.renderErrorStatus(c, fiber.StatusTooManyRequests, "Слишком много попыток скачивания, попробуйте позже")
+	}
+
 	var req DownloadRequest
 	req.Password = c.Query("password", "")
 
-	// Verify access (using only resource key, not encryption key)
-	err = h.accessService.VerifyAccess(c.Context(), resourceKey, req.Password)
+	// Verify access (using only resource key, not encryption key). An access code
+	// is only used up by DownloadMedia, together with the view.
+	err = h.accessService.CheckAccess(c.Context(), resourceKey, req.Password)
 	if err != nil {
 		switch err {
 		case accessservice.ErrNotFound:
@@ -410,6 +967,11 @@ func (h *Handlers) DownloadMediaFile(c *fiber.Ctx) error {
 			return h.renderError(c, "Ресурс истек")
 		case accessservice.ErrAlreadyViewed:
 			return h.renderAlreadyViewed(c)
+		case accessservice.ErrCountryBlocked:
+			return h.renderError(c, "Доступ к ресурсу из вашей страны запрещен")
+		case accessservice.ErrNotYetAvailable:
+			access, _ := h.accessService.CheckResourceAccess(c.Context(), resourceKey)
+			return h.renderNotYetAvailable(c, access.AvailableAt)
 		case accessservice.ErrPasswordRequired, accessservice.ErrInvalidPassword:
 			return h.renderError(c, "Неверный или отсутствующий пароль")
 		default:
@@ -426,12 +988,12 @@ func (h *Handlers) DownloadMediaFile(c *fiber.Ctx) error {
 
 	// Log for debugging
 	if encKeyBase64 == "" {
-		h.logger.Warn("encryption key is empty for download", zap.String("resource_key", resourceKey))
+		h.logger.WarnCtx(c.Context(), "encryption key is empty for download", zap.String("resource_key", resourceKey))
 	}
 
 	resp, err := h.mediaService.DownloadMedia(c.Context(), &downloadReq)
 	if err != nil {
-		h.logger.Error("failed to download media", zap.Error(err))
+		h.logger.ErrorCtx(c.Context(), "failed to download media", zap.Error(err))
 
 		// Handle specific errors
 		switch err {
@@ -439,15 +1001,18 @@ func (h *Handlers) DownloadMediaFile(c *fiber.Ctx) error {
 			return h.renderError(c, "Ресурс не найден")
 		case mediaservice.ErrAlreadyViewed:
 			return h.renderAlreadyViewed(c)
+		case mediaservice.ErrInvalidPassword:
+			return h.renderError(c, "Неверный или отсутствующий пароль")
 		case mediaservice.ErrMissingEncryptionKey, mediaservice.ErrInvalidEncryptionKey:
 			return h.renderError(c, "Неверный или отсутствующий ключ шифрования в URL")
 		case mediaservice.ErrDecryptionFailed:
 			return h.renderError(c, "Ошибка расшифровки - неверный пароль или поврежденные данные")
+		case mediaservice.ErrManifestMismatch:
+			return h.renderError(c, "Содержимое не совпадает с манифестом ресурса - данные повреждены или подменены")
 		default:
 			return h.renderError(c, "Ошибка при загрузке медиа")
 		}
 	}
-	defer resp.Data.Close()
 
 	// Build filename from saved name and extension
 	var downloadFilename string
@@ -461,23 +1026,35 @@ func (h *Handlers) DownloadMediaFile(c *fiber.Ctx) error {
 		downloadFilename = resourceKey
 	}
 
-	// Stream response
-	c.Set("Content-Type", "application/octet-stream")
+	// Bundle the file with a README for recipients
+	if c.Query("download_mode") == downloadModeZip {
+		defer resp.Data.Close()
+		c.Set("Content-Type", "application/zip")
+		c.Set("Content-Disposition", buildContentDisposition(zipArchiveName(downloadFilename)))
+		if err := writeZipArchive(c.Response().BodyWriter(), downloadFilename, resp); err != nil {
+			h.logger.ErrorCtx(c.Context(), "failed to write zip archive", zap.Error(err))
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to stream response",
+			})
+		}
+		return nil
+	}
+
+	// Stream response with the type detected on upload
+	contentType := resp.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	c.Set("Content-Type", contentType)
 	// Set Content-Disposition with filename
 	// Use RFC 5987 format for UTF-8 support (filename* parameter)
 	// This ensures proper encoding for non-ASCII characters (e.g., Russian, Chinese, etc.)
 	disposition := buildContentDisposition(downloadFilename)
 	c.Set("Content-Disposition", disposition)
 
-	_, err = io.Copy(c.Response().BodyWriter(), resp.Data)
-	if err != nil {
-		h.logger.Error("failed to stream response", zap.Error(err))
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "failed to stream response",
-		})
-	}
-
-	return nil
+	// The decrypted size is known, so clients get a Content-Length and a progress bar.
+	// The data is decrypted as it is sent and closed once written.
+	return c.SendStream(resp.Data, int(resp.Size))
 }
 
 // buildContentDisposition builds Content-Disposition header with proper UTF-8 encoding
@@ -503,29 +1080,37 @@ func buildContentDisposition(filename string) string {
 // IndexPage handles the main page
 func (h *Handlers) IndexPage(c *fiber.Ctx) error {
 
-	if _, err := os.Stat(filepath.Join(frontendDir, "index.html")); err == nil {
-		return c.SendFile(filepath.Join(frontendDir, "index.html"))
+	indexPath := filepath.Join(h.templates.Dir(), "index.html")
+	if _, err := os.Stat(indexPath); err == nil {
+		return c.SendFile(indexPath)
 	}
 
 	return c.Status(fiber.StatusInternalServerError).SendString("Frontend file not found. Please ensure you're running from the project root.")
 }
 
-// renderError renders the error template
-func (h *Handlers) renderError(c *fiber.Ctx, errorMsg string) error {
-	var tmpl *template.Template
-	var err error
+// templateFuncs are the functions available to all HTML templates
+var templateFuncs = template.FuncMap{
+	"reltime": func(t timeparser.UniversalTime) string {
+		return t.ToRelative(timeparser.NewUniversalTimeNow())
+	},
+}
 
-	if _, statErr := os.Stat(filepath.Join(frontendDir, "error.html")); statErr == nil {
-		tmpl, err = template.ParseFiles(filepath.Join(frontendDir, "error.html"))
-		if err != nil {
-			h.logger.Error("failed to parse error template", zap.Error(err))
-			return c.Status(fiber.StatusBadRequest).SendString("Template execution error")
-		}
-	}
+// parseTemplate parses the template file at path with templateFuncs
+func parseTemplate(path string) (*template.Template, error) {
+	return template.New(filepath.Base(path)).Funcs(templateFuncs).ParseFiles(path)
+}
 
-	if tmpl == nil {
-		h.logger.Error("failed to parse error template", zap.Error(err), zap.String("frontend_dir", frontendDir))
-		return c.Status(fiber.StatusBadRequest).SendString("Template error")
+// renderError renders the error template with 400 Bad Request
+func (h *Handlers) renderError(c *fiber.Ctx, errorMsg string) error {
+	return h.renderErrorStatus(c, fiber.StatusBadRequest, errorMsg)
+}
+
+// renderErrorStatus renders the error template with the given status
+func (h *Handlers) renderErrorStatus(c *fiber.Ctx, status int, errorMsg string) error {
+	tmpl, err := h.templates.Lookup("error.html")
+	if err != nil {
+		h.logger.ErrorCtx(c.Context(), "failed to parse error template", zap.Error(err))
+		return c.Status(status).SendString("Template error")
 	}
 
 	data := struct {
@@ -536,40 +1121,25 @@ func (h *Handlers) renderError(c *fiber.Ctx, errorMsg string) error {
 
 	var buf strings.Builder
 	if err := tmpl.Execute(&buf, data); err != nil {
-		h.logger.Error("failed to execute error template", zap.Error(err))
-		return c.Status(fiber.StatusBadRequest).SendString("Template execution error")
+		h.logger.ErrorCtx(c.Context(), "failed to execute error template", zap.Error(err))
+		return c.Status(status).SendString("Template execution error")
 	}
 
 	c.Set("Content-Type", "text/html; charset=utf-8")
-	return c.Status(fiber.StatusBadRequest).SendString(buf.String())
+	return c.Status(status).SendString(buf.String())
 }
 
 // renderAlreadyViewed renders the already viewed template
 func (h *Handlers) renderAlreadyViewed(c *fiber.Ctx) error {
-	templatePath := filepath.Join(frontendDir, "already-viewed.html")
-
-	var tmpl *template.Template
-	var err error
-
-	if _, statErr := os.Stat(templatePath); statErr == nil {
-		tmpl, err = template.ParseFiles(templatePath)
-		if err != nil {
-			h.logger.Error("failed to parse already-viewed template", zap.Error(err), zap.String("path", templatePath))
-			return c.Status(fiber.StatusGone).SendString("Template execution error")
-		}
-	} else {
-		h.logger.Error("already-viewed template file not found", zap.Error(statErr), zap.String("path", templatePath), zap.String("frontend_dir", frontendDir))
-		return c.Status(fiber.StatusGone).SendString("Template file not found")
-	}
-
-	if tmpl == nil {
-		h.logger.Error("failed to parse already-viewed template", zap.Error(err), zap.String("frontend_dir", frontendDir), zap.String("path", templatePath))
+	tmpl, err := h.templates.Lookup("already-viewed.html")
+	if err != nil {
+		h.logger.ErrorCtx(c.Context(), "failed to parse already-viewed template", zap.Error(err))
 		return c.Status(fiber.StatusGone).SendString("Template error")
 	}
 
 	var buf strings.Builder
 	if err := tmpl.Execute(&buf, nil); err != nil {
-		h.logger.Error("failed to execute already-viewed template", zap.Error(err))
+		h.logger.ErrorCtx(c.Context(), "failed to execute already-viewed template", zap.Error(err))
 		return c.Status(fiber.StatusGone).SendString("Template execution error")
 	}
 
@@ -577,34 +1147,73 @@ func (h *Handlers) renderAlreadyViewed(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusGone).SendString(buf.String())
 }
 
-// renderResult renders the result template for HTMX
-func (h *Handlers) renderResult(c *fiber.Ctx, success bool, url, errorMsg string, expiresIn timeparser.UniversalTime) error {
-	var tmpl *template.Template
-	var err error
-
-	if _, statErr := os.Stat(filepath.Join(frontendDir, "result.html")); statErr == nil {
-		tmpl, err = template.ParseFiles(filepath.Join(frontendDir, "result.html"))
-		if err != nil {
-			h.logger.Error("failed to parse result template", zap.Error(err))
-			return c.Status(fiber.StatusInternalServerError).SendString("Template execution error")
-		}
+// renderNotYetAvailable renders the countdown page of a scheduled resource
+func (h *Handlers) renderNotYetAvailable(c *fiber.Ctx, availableAt timeparser.UniversalTime) error {
+	tmpl, err := h.templates.Lookup("not-yet-available.html")
+	if err != nil {
+		h.logger.ErrorCtx(c.Context(), "failed to parse not-yet-available template", zap.Error(err))
+		return c.Status(fiber.StatusTooEarly).SendString("Template error")
 	}
 
 	data := struct {
-		Success   bool
-		URL       string
-		Error     string
-		ExpiresIn timeparser.UniversalTime
+		AvailableAt string
 	}{
+		AvailableAt: availableAt.Time.UTC().Format(time.RFC3339),
+	}
+
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, data); err != nil {
+		h.logger.ErrorCtx(c.Context(), "failed to execute not-yet-available template", zap.Error(err))
+		return c.Status(fiber.StatusTooEarly).SendString("Template execution error")
+	}
+
+	c.Set("Content-Type", "text/html; charset=utf-8")
+	return c.Status(fiber.StatusTooEarly).SendString(buf.String())
+}
+
+// resultData is the data passed to the result template
+type resultData struct {
+	Success     bool
+	URL         string
+	Error       string
+	ExpiresIn   timeparser.UniversalTime
+	MaxViews    int                 // downloads the uploaded file allows
+	FormFields  []string            // fields whose inline error slots are refreshed
+	FieldErrors map[string][]string // per-field messages rendered next to inputs
+}
+
+// renderResult renders the result template for HTMX
+func (h *Handlers) renderResult(c *fiber.Ctx, success bool, url, errorMsg string, expiresIn timeparser.UniversalTime) error {
+	return h.renderResultData(c, resultData{
 		Success:   success,
 		URL:       url,
 		Error:     errorMsg,
 		ExpiresIn: expiresIn,
+	})
+}
+
+// renderFieldErrors renders the result template with field-level errors for HTMX
+func (h *Handlers) renderFieldErrors(c *fiber.Ctx, verr *ValidationError) error {
+	return h.renderResultData(c, resultData{
+		Success:     false,
+		Error:       "Проверьте правильность заполнения формы",
+		FieldErrors: verr.Localized(),
+	})
+}
+
+func (h *Handlers) renderResultData(c *fiber.Ctx, data resultData) error {
+	tmpl, err := h.templates.Lookup("result.html")
+	if err != nil {
+		h.logger.ErrorCtx(c.Context(), "failed to parse result template", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).SendString("Template error")
 	}
 
+	// Always refresh every inline error slot so stale messages are cleared
+	data.FormFields = uploadFormFields
+
 	var buf strings.Builder
 	if err := tmpl.Execute(&buf, data); err != nil {
-		h.logger.Error("failed to execute result template", zap.Error(err))
+		h.logger.ErrorCtx(c.Context(), "failed to execute result template", zap.Error(err))
 		return c.Status(fiber.StatusInternalServerError).SendString("Template execution error")
 	}
 
@@ -619,11 +1228,15 @@ func (h *Handlers) PreviewMedia(c *fiber.Ctx) error {
 		return err
 	}
 
+	if err := h.checkSignedURL(c, resourceKey, encKeyBase64); err != nil {
+		return h.renderSignatureError(c, err)
+	}
+
 	var req DownloadRequest
 	req.Password = c.Query("password", "")
 
-	// Verify access
-	err = h.accessService.VerifyAccess(c.Context(), resourceKey, req.Password)
+	// Verify access (access codes are only used up by the download)
+	err = h.accessService.CheckAccess(c.Context(), resourceKey, req.Password)
 	if err != nil {
 		switch err {
 		case accessservice.ErrNotFound:
@@ -632,6 +1245,11 @@ func (h *Handlers) PreviewMedia(c *fiber.Ctx) error {
 			return h.renderError(c, "Ресурс истек")
 		case accessservice.ErrAlreadyViewed:
 			return h.renderAlreadyViewed(c)
+		case accessservice.ErrCountryBlocked:
+			return h.renderError(c, "Доступ к ресурсу из вашей страны запрещен")
+		case accessservice.ErrNotYetAvailable:
+			access, _ := h.accessService.CheckResourceAccess(c.Context(), resourceKey)
+			return h.renderNotYetAvailable(c, access.AvailableAt)
 		case accessservice.ErrPasswordRequired, accessservice.ErrInvalidPassword:
 			return h.renderError(c, "Неверный или отсутствующий пароль")
 		default:
@@ -648,12 +1266,12 @@ func (h *Handlers) PreviewMedia(c *fiber.Ctx) error {
 
 	// Log for debugging
 	if encKeyBase64 == "" {
-		h.logger.Warn("encryption key is empty for preview", zap.String("resource_key", resourceKey))
+		h.logger.WarnCtx(c.Context(), "encryption key is empty for preview", zap.String("resource_key", resourceKey))
 	}
 
 	resp, err := h.mediaService.GetMediaPreview(c.Context(), &previewReq)
 	if err != nil {
-		h.logger.Error("failed to get media preview", zap.Error(err))
+		h.logger.ErrorCtx(c.Context(), "failed to get media preview", zap.Error(err))
 		switch err {
 		case mediaservice.ErrNotFound:
 			return h.renderError(c, "Ресурс не найден")
@@ -663,15 +1281,19 @@ func (h *Handlers) PreviewMedia(c *fiber.Ctx) error {
 			return h.renderError(c, "Неверный или отсутствующий ключ шифрования в URL")
 		case mediaservice.ErrDecryptionFailed:
 			return h.renderError(c, "Ошибка расшифровки - неверный пароль или поврежденные данные")
+		case mediaservice.ErrManifestMismatch:
+			return h.renderError(c, "Содержимое не совпадает с манифестом ресурса - данные повреждены или подменены")
 		default:
 			return h.renderError(c, "Ошибка при получении превью")
 		}
 	}
-	defer resp.Data.Close()
 
-	// Determine content type based on extension
+	// Previews are shown inline, so only image types are served as such. Resources
+	// stored without a content type fall back to the extension.
 	contentType := "application/octet-stream"
-	if resp.FileExtension != nil {
+	if strings.HasPrefix(resp.ContentType, "image/") {
+		contentType = resp.ContentType
+	} else if resp.FileExtension != nil {
 		ext := strings.ToLower(*resp.FileExtension)
 		switch ext {
 		case "jpg", "jpeg":
@@ -696,30 +1318,15 @@ func (h *Handlers) PreviewMedia(c *fiber.Ctx) error {
 	c.Set("Pragma", "no-cache")
 	c.Set("Expires", "0")
 
-	_, err = io.Copy(c.Response().BodyWriter(), resp.Data)
-	if err != nil {
-		h.logger.Error("failed to stream preview", zap.Error(err))
-		return err
-	}
-
-	return nil
+	return c.SendStream(resp.Data, int(resp.Size))
 }
 
 // renderViewPage renders the view page template
 func (h *Handlers) renderViewPage(c *fiber.Ctx, mediaInfo *mediaservice.MediaInfo, displayFilename, downloadURL, previewURL string, showPasswordModal bool, passwordError string) error {
 
-	var tmpl *template.Template
-	var err error
-	if _, err := os.Stat(filepath.Join(frontendDir, "view.html")); err == nil {
-		tmpl, err = template.ParseFiles(filepath.Join(frontendDir, "view.html"))
-		if err != nil {
-			h.logger.Error("failed to parse view template", zap.Error(err))
-			return c.Status(fiber.StatusInternalServerError).SendString("Template execution error")
-		}
-	}
-
-	if tmpl == nil {
-		h.logger.Error("failed to parse view template", zap.Error(err), zap.String("frontend_dir", frontendDir))
+	tmpl, err := h.templates.Lookup("view.html")
+	if err != nil {
+		h.logger.ErrorCtx(c.Context(), "failed to parse view template", zap.Error(err))
 		return c.Status(fiber.StatusInternalServerError).SendString("Template error")
 	}
 
@@ -731,7 +1338,8 @@ func (h *Handlers) renderViewPage(c *fiber.Ctx, mediaInfo *mediaservice.MediaInf
 		ShowPasswordModal bool
 		PasswordError     string
 		ResourceKey       string
-		BlurEnabled       bool
+		BlurIntensity     float64
+		ViewsRemaining    int
 	}{
 		Filename:          displayFilename,
 		IsImage:           mediaInfo.IsImage,
@@ -740,12 +1348,13 @@ func (h *Handlers) renderViewPage(c *fiber.Ctx, mediaInfo *mediaservice.MediaInf
 		ShowPasswordModal: showPasswordModal,
 		PasswordError:     passwordError,
 		ResourceKey:       c.Params("key"),
-		BlurEnabled:       mediaInfo.BlurEnabled,
+		BlurIntensity:     mediaInfo.BlurIntensity,
+		ViewsRemaining:    mediaInfo.ViewsRemaining,
 	}
 
 	var buf strings.Builder
 	if err := tmpl.Execute(&buf, data); err != nil {
-		h.logger.Error("failed to execute view template", zap.Error(err))
+		h.logger.ErrorCtx(c.Context(), "failed to execute view template", zap.Error(err))
 		return c.Status(fiber.StatusInternalServerError).SendString("Template execution error")
 	}
 
@@ -753,15 +1362,276 @@ func (h *Handlers) renderViewPage(c *fiber.Ctx, mediaInfo *mediaservice.MediaInf
 	return c.SendString(buf.String())
 }
 
+type ReencryptRequest struct {
+	EncKeyBase64    string `json:"enc_key_base64"`
+	NewEncKeyBase64 string `json:"new_enc_key_base64,omitempty"`
+	Password        string `json:"password,omitempty"`
+}
+
+// ReencryptResource rotates the encryption key of a stored resource
+// @Summary      Re-encrypt resource
+// @Description  Re-encrypt a stored resource with a new encryption key. The resource key stays the same, the returned URL contains the new key fragment. Requires management token.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        key      path      string            true  "Resource key"
+// @Param        request  body      ReencryptRequest  true  "Old key, optional new key and password"
+// @Success      200      {object}  UploadResponse
+// @Failure      400      {object}  map[string]string
+// @Failure      401      {object}  map[string]string
+// @Failure      404      {object}  map[string]string
+// @Failure      409      {object}  map[string]string
+// @Failure      410      {object}  map[string]string
+// @Failure      500      {object}  map[string]string
+// @Router       /api/v1/admin/reencrypt/{key} [post]
+func (h *Handlers) ReencryptResource(c *fiber.Ctx) error {
+	resourceKey, _, err := getResourceKeyAndEncryptionKey(c)
+	if err != nil {
+		return err
+	}
+
+	var req ReencryptRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	resp, err := h.mediaService.ReencryptResource(c.Context(), &mediaservice.ReencryptRequest{
+		ResourceKey:     resourceKey,
+		Password:        req.Password,
+		EncKeyBase64:    req.EncKeyBase64,
+		NewEncKeyBase64: req.NewEncKeyBase64,
+	})
+	if err != nil {
+		switch err {
+		case mediaservice.ErrNotFound:
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "resource not found"})
+		case mediaservice.ErrExpired, mediaservice.ErrAlreadyViewed:
+			return c.Status(fiber.StatusGone).JSON(fiber.Map{"error": err.Error()})
+		case mediaservice.ErrMissingEncryptionKey, mediaservice.ErrInvalidEncryptionKey:
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		case mediaservice.ErrDecryptionFailed, mediaservice.ErrInvalidPassword:
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid old key or password"})
+		case mediaservice.ErrManifestMismatch:
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+		default:
+			h.logger.ErrorCtx(c.Context(), "failed to re-encrypt resource", zap.Error(err), zap.String("resource_key", resourceKey))
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to re-encrypt resource"})
+		}
+	}
+
+	return c.JSON(UploadResponse{
+		ResourceKey: resp.ResourceKey,
+		URL:         resp.URL,
+	})
+}
+
+type PresignDownloadRequest struct {
+	EncKeyBase64 string `json:"enc_key"`
+}
+
+type PresignDownloadResponse struct {
+	URL       string                   `json:"url"`
+	ExpiresAt timeparser.UniversalTime `json:"expires_at"`
+	Scheme    string                   `json:"scheme"`
+	Filename  string                   `json:"filename"`
+
+	ViewsRemaining int `json:"views_remaining"` // downloads left after this one
+}
+
+// PresignDownload returns a short-lived S3 URL of the encrypted file for in-browser decryption
+// @Summary      Presigned encrypted download
+// @Description  Consume a view and get a presigned S3 URL of the encrypted object instead of the decrypted file. Scheme aes-256-gcm-sha256: the AES-256-GCM key is SHA-256 of the raw (base64url-decoded) enc_key, the blob is a 12-byte nonce followed by the ciphertext. Scheme aes-256-gcm-sha256-chunked (objects uploaded as a stream) uses the same key on a 7-byte nonce prefix followed by frames of a 4-byte big-endian length and one sealed chunk of up to 64 KiB; the nonce of chunk i is the prefix, i as 4 bytes big endian and 1 for the last chunk or 0. Not available for password-protected resources.
+// @Tags         media
+// @Accept       json
+// @Produce      json
+// @Param        key       path      string                  true   "Resource key"
+// @Param        password  query     string                  false  "Access code for resources protected by access codes"
+// @Param        expires   query     int                     false  "Expiry of a signed URL (Unix seconds)"
+// @Param        sig       query     string                  false  "Signature of a signed URL, required for signed-only resources"
+// @Param        request   body      PresignDownloadRequest  true   "Encryption key from the URL fragment"
+// @Success      200       {object}  PresignDownloadResponse
+// @Failure      400       {object}  map[string]string
+// @Failure      401       {object}  map[string]string
+// @Failure      403       {object}  map[string]string
+// @Failure      404       {object}  map[string]string
+// @Failure      409       {object}  map[string]string
+// @Failure      410       {object}  map[string]string
+// @Failure      425       {object}  map[string]string
+// @Router       /media/{key}/presign-download [post]
+func (h *Handlers) PresignDownload(c *fiber.Ctx) error {
+	resourceKey, encKeyBase64, err := getResourceKeyAndEncryptionKey(c)
+	if err != nil {
+		return err
+	}
+
+	var req PresignDownloadRequest
+	if err := c.BodyParser(&req); err != nil && len(c.Body()) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request body"})
+	}
+	if req.EncKeyBase64 != "" {
+		encKeyBase64 = req.EncKeyBase64
+	}
+	password := c.Query("password", "")
+
+	if err := h.checkSignedURL(c, resourceKey, encKeyBase64); err != nil {
+		return err
+	}
+
+	// The access code is only used up by PresignDownload, together with the view
+	if err := h.accessService.CheckAccess(c.Context(), resourceKey, password); err != nil {
+		switch err {
+		case accessservice.ErrNotFound:
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "resource not found"})
+		case accessservice.ErrExpired, accessservice.ErrAlreadyViewed:
+			return c.Status(fiber.StatusGone).JSON(fiber.Map{"error": err.Error()})
+		case accessservice.ErrCountryBlocked:
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+		case accessservice.ErrNotYetAvailable:
+			return c.Status(fiber.StatusTooEarly).JSON(fiber.Map{"error": err.Error()})
+		case accessservice.ErrPasswordRequired, accessservice.ErrInvalidPassword:
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid or missing access code"})
+		default:
+			h.logger.ErrorCtx(c.Context(), "failed to verify access", zap.Error(err))
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to verify access"})
+		}
+	}
+
+	resp, err := h.mediaService.PresignDownload(c.Context(), &mediaservice.DownloadRequest{
+		ResourceKey:  resourceKey,
+		Password:     password,
+		EncKeyBase64: encKeyBase64,
+	})
+	if err != nil {
+		switch err {
+		case mediaservice.ErrMissingEncryptionKey, mediaservice.ErrInvalidEncryptionKey:
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		case mediaservice.ErrInvalidPassword:
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid or missing access code"})
+		case mediaservice.ErrNotFound:
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "resource not found"})
+		case mediaservice.ErrExpired, mediaservice.ErrAlreadyViewed:
+			return c.Status(fiber.StatusGone).JSON(fiber.Map{"error": err.Error()})
+		case mediaservice.ErrPresignUnsupported:
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+		default:
+			h.logger.ErrorCtx(c.Context(), "failed to presign download", zap.Error(err))
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to presign download"})
+		}
+	}
+
+	return c.JSON(PresignDownloadResponse{
+		URL:       resp.URL,
+		ExpiresAt: resp.ExpiresAt,
+		Scheme:    resp.Scheme,
+		Filename:  joinFilename(resp.Filename, resp.FileExtension),
+
+		ViewsRemaining: resp.ViewsRemaining,
+	})
+}
+
+type BulkStatusRequest struct {
+	Keys []string `json:"keys"`
+}
+
+type ResourceStatusResponse struct {
+	Active          bool                      `json:"active"`
+	Viewed          bool                      `json:"viewed,omitempty"`
+	Expired         bool                      `json:"expired,omitempty"`
+	NotYetAvailable bool                      `json:"not_yet_available,omitempty"`
+	ExpiresAt       *timeparser.UniversalTime `json:"expires_at,omitempty"`
+	AvailableAt     *timeparser.UniversalTime `json:"available_at,omitempty"`
+}
+
+type BulkStatusResponse struct {
+	Statuses map[string]ResourceStatusResponse `json:"statuses"`
+}
+
+// BulkStatus reports which of the given resources can be opened now.
+// It serves both /media/bulk-status and /api/v1/media/bulk-check.
+// @Summary      Bulk resource status
+// @Description  Check up to 50 resource keys at once, e.g. for apps tracking several shared links. Unknown and deleted resources are both reported as {"active": false}. Results are cached for 5 seconds.
+// @Tags         media
+// @Accept       json
+// @Produce      json
+// @Param        request  body      BulkStatusRequest  true  "Resource keys"
+// @Success      200      {object}  BulkStatusResponse
+// @Failure      400      {object}  map[string]string
+// @Failure      429      {object}  map[string]string
+// @Failure      500      {object}  map[string]string
+// @Router       /media/bulk-status [post]
+// @Router       /api/v1/media/bulk-check [post]
+func (h *Handlers) BulkStatus(c *fiber.Ctx) error {
+	var req BulkStatusRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+	if len(req.Keys) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "keys are required",
+		})
+	}
+
+	statuses, err := h.accessService.BulkCheckResourceAccess(c.Context(), req.Keys)
+	if err != nil {
+		if errors.Is(err, accessservice.ErrTooManyKeys) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("at most %d keys are allowed", accessservice.MaxBulkCheckKeys),
+			})
+		}
+		h.logger.ErrorCtx(c.Context(), "failed to check resource statuses", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to check statuses"})
+	}
+
+	resp := BulkStatusResponse{Statuses: make(map[string]ResourceStatusResponse, len(statuses))}
+	for key, status := range statuses {
+		resp.Statuses[key] = ResourceStatusResponse{
+			Active:          status.Active,
+			Viewed:          status.Viewed,
+			Expired:         status.Expired,
+			NotYetAvailable: status.NotYetAvailable,
+			ExpiresAt:       status.ExpiresAt,
+			AvailableAt:     status.AvailableAt,
+		}
+	}
+	return c.JSON(resp)
+}
+
+type HealthResponse struct {
+	// Status is "ok", or "degraded" when the storage is unreachable
+	Status string             `json:"status"`
+	S3     *StorageHealthJSON `json:"s3,omitempty"`
+}
+
+type StorageHealthJSON struct {
+	Healthy   bool                      `json:"healthy"`
+	CheckedAt *timeparser.UniversalTime `json:"checked_at,omitempty"`
+}
+
 // HealthCheck handles health check endpoint
 // @Summary      Health check
-// @Description  Check if the service is running
+// @Description  Check if the service is running. The s3 block reports the latest background storage check (every S3_HEALTH_CHECK_INTERVAL). A degraded storage still answers 200: the server itself is up and restarting it would not help.
 // @Tags         health
 // @Produce      json
-// @Success      200  {object}  map[string]string
-// @Router       /health [get]
+// @Success      200  {object}  HealthResponse
+// @Router       /api/v1/health [get]
 func (h *Handlers) HealthCheck(c *fiber.Ctx) error {
-	return c.JSON(fiber.Map{
-		"status": "ok",
-	})
+	resp := HealthResponse{Status: "ok"}
+	if h.cfg.StorageHealth != nil {
+		status := h.cfg.StorageHealth.Status()
+		resp.S3 = &StorageHealthJSON{Healthy: status.Healthy}
+		if !status.CheckedAt.IsZero() {
+			checkedAt := timeparser.NewUniversalTime(status.CheckedAt)
+			resp.S3.CheckedAt = &checkedAt
+		}
+		// The error itself is logged by the monitor, it may name the bucket
+		if !status.Healthy {
+			resp.Status = "degraded"
+		}
+	}
+	return c.JSON(resp)
 }
@@ -0,0 +1,500 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	accessservice "lovebin/internal/services/access-service"
+	accessrepo "lovebin/internal/services/access-service/repository"
+	mediaservice "lovebin/internal/services/media-service"
+	"lovebin/modules/s3"
+	"lovebin/modules/timeparser"
+)
+
+func TestViewMediaDownloadOnlyRedirects(t *testing.T) {
+	h := newTestHandlers(t, Config{})
+	app := fiber.New()
+	app.Get("/media/:key", h.ViewMedia)
+	app.Get("/media/:key/download", h.DownloadMediaFile)
+
+	const content = "download only content"
+	resourceKey, encKey := h.upload(t, content, mediaservice.UploadRequest{Filename: "a.txt", DownloadOnly: true})
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/media/"+resourceKey+"?enc_key="+encKey, nil))
+	if err != nil {
+		t.Fatalf("view request: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusTemporaryRedirect {
+		t.Fatalf("view status = %d, want %d", resp.StatusCode, fiber.StatusTemporaryRedirect)
+	}
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("invalid Location %q: %v", resp.Header.Get("Location"), err)
+	}
+	if want := "/media/" + resourceKey + "/download"; location.Path != want {
+		t.Errorf("redirect path = %q, want %q", location.Path, want)
+	}
+	if got := location.Query().Get("enc_key"); got != encKey {
+		t.Errorf("redirect enc_key = %q, want %q", got, encKey)
+	}
+	if n := h.storage.Calls("Download"); n != 0 {
+		t.Errorf("view page downloaded the object %d times", n)
+	}
+
+	// Following the redirect downloads the file
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, location.String(), nil))
+	if err != nil {
+		t.Fatalf("download request: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("download status = %d, body %q", resp.StatusCode, body)
+	}
+	if string(body) != content {
+		t.Errorf("downloaded %q, want %q", body, content)
+	}
+	if disposition := resp.Header.Get("Content-Disposition"); !strings.Contains(disposition, "a.txt") {
+		t.Errorf("Content-Disposition = %q, want the original filename", disposition)
+	}
+}
+
+func TestViewMediaDownloadOnlyWithoutKey(t *testing.T) {
+	h := newTestHandlers(t, Config{})
+	app := fiber.New()
+	app.Get("/media/:key", h.ViewMedia)
+
+	resourceKey, _ := h.upload(t, "content", mediaservice.UploadRequest{Filename: "a.txt", DownloadOnly: true})
+
+	// The key is in the fragment the server never sees, the page moves it to the query first
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/media/"+resourceKey, nil))
+	if err != nil {
+		t.Fatalf("view request: %v", err)
+	}
+	if resp.StatusCode == fiber.StatusTemporaryRedirect {
+		t.Errorf("redirected to %q without an encryption key", resp.Header.Get("Location"))
+	}
+}
+
+// postJSON sends body as JSON to app and decodes the response into out
+func postJSON(t *testing.T, app *fiber.App, path, body string, out any) int {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("POST %s: %v", path, err)
+	}
+	if out != nil && resp.StatusCode == fiber.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestBulkStatus(t *testing.T) {
+	h := newTestHandlers(t, Config{})
+	app := fiber.New()
+	app.Post("/media/bulk-status", h.BulkStatus)
+
+	past := timeparser.UniversalTime{Time: time.Now().Add(-time.Hour).UTC()}
+	future := timeparser.UniversalTime{Time: time.Now().Add(time.Hour).UTC()}
+	h.access.resources = map[string]accessrepo.ResourceAccess{
+		"active":  {ResourceKey: "active", ExpiresAt: future},
+		"viewed":  {ResourceKey: "viewed", Viewed: true},
+		"expired": {ResourceKey: "expired", ExpiresAt: past},
+		"later":   {ResourceKey: "later", AvailableAt: future},
+	}
+
+	var resp BulkStatusResponse
+	status := postJSON(t, app, "/media/bulk-status",
+		`{"keys": ["active#key", "viewed", "expired", "later", "unknown", "active"]}`, &resp)
+	if status != fiber.StatusOK {
+		t.Fatalf("status = %d, want 200", status)
+	}
+	if h.access.statusLoads != 1 || len(h.access.statusLoaded) != 5 {
+		t.Errorf("statuses loaded %d times for %q, want once for 5 keys", h.access.statusLoads, h.access.statusLoaded)
+	}
+
+	tests := []struct {
+		key  string
+		want ResourceStatusResponse
+	}{
+		{"active#key", ResourceStatusResponse{Active: true, ExpiresAt: &future}},
+		{"active", ResourceStatusResponse{Active: true, ExpiresAt: &future}},
+		{"viewed", ResourceStatusResponse{Viewed: true}},
+		{"expired", ResourceStatusResponse{Expired: true, ExpiresAt: &past}},
+		{"later", ResourceStatusResponse{NotYetAvailable: true, AvailableAt: &future}},
+		// Unknown keys look like deleted ones
+		{"unknown", ResourceStatusResponse{}},
+	}
+	for _, tt := range tests {
+		got, ok := resp.Statuses[tt.key]
+		if !ok {
+			t.Errorf("no status for %q", tt.key)
+			continue
+		}
+		if got.Active != tt.want.Active || got.Viewed != tt.want.Viewed || got.Expired != tt.want.Expired ||
+			got.NotYetAvailable != tt.want.NotYetAvailable ||
+			!sameTime(got.ExpiresAt, tt.want.ExpiresAt) || !sameTime(got.AvailableAt, tt.want.AvailableAt) {
+			t.Errorf("status of %q = %+v, want %+v", tt.key, got, tt.want)
+		}
+	}
+}
+
+// sameTime reports whether a and b are both nil or the same second
+func sameTime(a, b *timeparser.UniversalTime) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Unix() == b.Unix()
+}
+
+func TestBulkStatusRejected(t *testing.T) {
+	h := newTestHandlers(t, Config{})
+	app := fiber.New()
+	app.Post("/media/bulk-status", h.BulkStatus)
+
+	tooMany := make([]string, accessservice.MaxBulkCheckKeys+1)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf("%q", fmt.Sprint("key", i))
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"malformed body", `{"keys": `, fiber.StatusBadRequest},
+		{"no keys", `{"keys": []}`, fiber.StatusBadRequest},
+		{"too many keys", `{"keys": [` + strings.Join(tooMany, ",") + `]}`, fiber.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := postJSON(t, app, "/media/bulk-status", tt.body, nil); status != tt.want {
+				t.Errorf("status = %d, want %d", status, tt.want)
+			}
+		})
+	}
+	if h.access.statusLoads != 0 {
+		t.Errorf("rejected requests loaded statuses %d times", h.access.statusLoads)
+	}
+
+	h.access.err = errors.New("connection reset")
+	if status := postJSON(t, app, "/media/bulk-status", `{"keys": ["key"]}`, nil); status != fiber.StatusInternalServerError {
+		t.Errorf("status on repository failure = %d, want 500", status)
+	}
+}
+
+func TestRequestLocation(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		query  string
+		want   string
+	}{
+		{"none", "", "", "UTC"},
+		{"header", "Europe/Moscow", "", "Europe/Moscow"},
+		{"query", "", "America/New_York", "America/New_York"},
+		{"header wins", "Europe/Moscow", "America/New_York", "Europe/Moscow"},
+		{"unknown", "Mars/Olympus_Mons", "", "UTC"},
+		{"path traversal", "../../etc/passwd", "", "UTC"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			var got string
+			app.Get("/", func(c *fiber.Ctx) error {
+				got = requestLocation(c).String()
+				return nil
+			})
+			req := httptest.NewRequest(http.MethodGet, "/?tz="+url.QueryEscape(tt.query), nil)
+			if tt.header != "" {
+				req.Header.Set(TimezoneHeader, tt.header)
+			}
+			if _, err := app.Test(req); err != nil {
+				t.Fatalf("request: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("requestLocation = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPresignDownload(t *testing.T) {
+	h := newTestHandlers(t, Config{})
+	app := fiber.New()
+	app.Post("/media/:key/presign-download", h.PresignDownload)
+
+	resourceKey, encKey := h.upload(t, "encrypted in the browser", mediaservice.UploadRequest{Filename: "notes.txt"})
+	otherKey, _ := h.upload(t, "other", mediaservice.UploadRequest{})
+
+	tests := []struct {
+		name       string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{"missing key", "/media/" + otherKey + "/presign-download", `{}`, fiber.StatusBadRequest},
+		{"malformed key", "/media/" + otherKey + "/presign-download", `{"enc_key":"abc"}`, fiber.StatusBadRequest},
+		{"invalid body", "/media/" + otherKey + "/presign-download", `{"enc_key":`, fiber.StatusBadRequest},
+		{"unknown resource", "/media/missing/presign-download", `{"enc_key":"` + encKey + `"}`, fiber.StatusNotFound},
+		{"ok", "/media/" + resourceKey + "/presign-download", `{"enc_key":"` + encKey + `"}`, fiber.StatusOK},
+		{"view used up", "/media/" + resourceKey + "/presign-download", `{"enc_key":"` + encKey + `"}`, fiber.StatusGone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp PresignDownloadResponse
+			if status := postJSON(t, app, tt.path, tt.body, &resp); status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", status, tt.wantStatus)
+			}
+			if tt.wantStatus != fiber.StatusOK {
+				return
+			}
+			if !strings.Contains(resp.URL, "/media/"+resourceKey) || resp.Filename != "notes.txt" || resp.ViewsRemaining != 0 {
+				t.Errorf("response = %+v", resp)
+			}
+			if resp.Scheme != mediaservice.PresignScheme && resp.Scheme != mediaservice.PresignSchemeChunked {
+				t.Errorf("Scheme = %q", resp.Scheme)
+			}
+			if resp.ExpiresAt.Before(time.Now()) {
+				t.Errorf("ExpiresAt = %v, want in the future", resp.ExpiresAt)
+			}
+		})
+	}
+}
+
+func TestScheduledResourceIsTooEarly(t *testing.T) {
+	tests := []struct {
+		name    string
+		request func(resourceKey, encKey string) *http.Request
+	}{
+		{"download", func(resourceKey, encKey string) *http.Request {
+			return httptest.NewRequest(http.MethodGet, "/media/"+resourceKey+"/download?enc_key="+encKey, nil)
+		}},
+		{"presign", func(resourceKey, encKey string) *http.Request {
+			req := httptest.NewRequest(http.MethodPost, "/media/"+resourceKey+"/presign-download", strings.NewReader(`{"enc_key":"`+encKey+`"}`))
+			req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+			return req
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandlers(t, Config{})
+			app := fiber.New()
+			app.Get("/media/:key/download", h.DownloadMediaFile)
+			app.Post("/media/:key/presign-download", h.PresignDownload)
+
+			resourceKey, encKey := h.upload(t, "birthday card", mediaservice.UploadRequest{})
+			access := h.access.resources[resourceKey]
+			access.AvailableAt = timeparser.UniversalTime{Time: time.Now().Add(time.Hour).UTC()}
+			h.access.resources[resourceKey] = access
+
+			resp, err := app.Test(tt.request(resourceKey, encKey))
+			if err != nil {
+				t.Fatalf("request: %v", err)
+			}
+			if resp.StatusCode != fiber.StatusTooEarly {
+				t.Fatalf("status before the reveal = %d, want %d", resp.StatusCode, fiber.StatusTooEarly)
+			}
+
+			// Once the time comes the resource opens
+			access.AvailableAt = timeparser.UniversalTime{Time: time.Now().Add(-time.Second).UTC()}
+			h.access.resources[resourceKey] = access
+			resp, err = app.Test(tt.request(resourceKey, encKey))
+			if err != nil {
+				t.Fatalf("request: %v", err)
+			}
+			if resp.StatusCode != fiber.StatusOK {
+				body, _ := io.ReadAll(resp.Body)
+				t.Errorf("status after the reveal = %d, want %d: %s", resp.StatusCode, fiber.StatusOK, body)
+			}
+		})
+	}
+}
+
+func TestDownloadRateLimitPerResource(t *testing.T) {
+	hash := "$2a$10$hash"
+	tests := []struct {
+		name          string
+		access        accessrepo.ResourceAccess
+		limit         int
+		wantProtected int64
+		wantOpen      int64
+	}{
+		{"password protected", accessrepo.ResourceAccess{PasswordHash: &hash}, protectedDownloadRateLimit, 1, 0},
+		{"access codes", accessrepo.ResourceAccess{HasAccessCodes: true}, protectedDownloadRateLimit, 1, 0},
+		{"open", accessrepo.ResourceAccess{}, openDownloadRateLimit, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandlers(t, Config{})
+			app := fiber.New()
+			app.Get("/media/:key/download", h.DownloadMediaFile)
+			tt.access.ResourceKey = "limited"
+			h.access.resources["limited"] = tt.access
+			h.access.resources["other"] = accessrepo.ResourceAccess{ResourceKey: "other", PasswordHash: &hash}
+
+			download := func(resourceKey string) *http.Response {
+				t.Helper()
+				resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/media/"+resourceKey+"/download?enc_key=key&password=guess", nil))
+				if err != nil {
+					t.Fatalf("download request: %v", err)
+				}
+				return resp
+			}
+
+			// Attempts count whether they succeed or not
+			for i := range tt.limit {
+				if resp := download("limited"); resp.StatusCode == fiber.StatusTooManyRequests {
+					t.Fatalf("attempt %d of %d rejected", i+1, tt.limit)
+				}
+			}
+			resp := download("limited")
+			if resp.StatusCode != fiber.StatusTooManyRequests {
+				t.Fatalf("attempt over the limit status = %d, want 429", resp.StatusCode)
+			}
+			if retryAfter, err := strconv.Atoi(resp.Header.Get(fiber.HeaderRetryAfter)); err != nil || retryAfter < 1 || retryAfter > int(downloadRateWindow/time.Second)+1 {
+				t.Errorf("Retry-After = %q, want seconds until the window frees up", resp.Header.Get(fiber.HeaderRetryAfter))
+			}
+
+			// The limit is per resource
+			if resp := download("other"); resp.StatusCode == fiber.StatusTooManyRequests {
+				t.Error("another resource was rate limited")
+			}
+			if protected, open := h.DownloadsRateLimited(); protected != tt.wantProtected || open != tt.wantOpen {
+				t.Errorf("DownloadsRateLimited = %d, %d, want %d, %d", protected, open, tt.wantProtected, tt.wantOpen)
+			}
+		})
+	}
+}
+
+func TestDownloadRateLimitUnknownResource(t *testing.T) {
+	h := newTestHandlers(t, Config{})
+	app := fiber.New()
+	app.Get("/media/:key/download", h.DownloadMediaFile)
+
+	// Unknown keys get the open limit, guessing them is slowed down all the same
+	for range openDownloadRateLimit {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/media/missing/download?enc_key=key", nil))
+		if err != nil {
+			t.Fatalf("download request: %v", err)
+		}
+		if resp.StatusCode == fiber.StatusTooManyRequests {
+			t.Fatal("unknown resource rate limited below the open limit")
+		}
+	}
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/media/missing/download?enc_key=key", nil))
+	if err != nil {
+		t.Fatalf("download request: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusTooManyRequests {
+		t.Errorf("status over the limit = %d, want 429", resp.StatusCode)
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	health := func(t *testing.T, cfg Config) HealthResponse {
+		t.Helper()
+		h := newTestHandlers(t, cfg)
+		app := fiber.New()
+		app.Get("/health", h.HealthCheck)
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/health", nil))
+		if err != nil {
+			t.Fatalf("health request: %v", err)
+		}
+		// A degraded storage still answers 200
+		if resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("status = %d, want 200", resp.StatusCode)
+		}
+		var body HealthResponse
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatalf("decode health: %v", err)
+		}
+		return body
+	}
+
+	t.Run("without monitor", func(t *testing.T) {
+		if body := health(t, Config{}); body.Status != "ok" || body.S3 != nil {
+			t.Errorf("health = %+v, want ok without s3", body)
+		}
+	})
+
+	storage := s3.NewMockS3()
+	monitor, stop := s3.StartHealthMonitor(newTestLogger(t), storage, 10*time.Millisecond)
+	defer stop()
+	waitForStatus := func(healthy bool) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for status := monitor.Status(); status.Healthy != healthy || status.CheckedAt.IsZero(); status = monitor.Status() {
+			if time.Now().After(deadline) {
+				t.Fatalf("storage health = %+v, want healthy %v", status, healthy)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	waitForStatus(true)
+	t.Run("healthy", func(t *testing.T) {
+		body := health(t, Config{StorageHealth: monitor})
+		if body.Status != "ok" || body.S3 == nil || !body.S3.Healthy || body.S3.CheckedAt == nil {
+			t.Errorf("health = %+v, want ok with a healthy s3 check", body)
+		}
+	})
+
+	storage.SetError("HealthCheck", errors.New("bucket media unreachable"))
+	waitForStatus(false)
+	t.Run("degraded", func(t *testing.T) {
+		body := health(t, Config{StorageHealth: monitor})
+		if body.Status != "degraded" || body.S3 == nil || body.S3.Healthy {
+			t.Errorf("health = %+v, want degraded", body)
+		}
+	})
+}
+
+func TestDownloadMediaFileContentType(t *testing.T) {
+	h := newTestHandlers(t, Config{})
+	app := fiber.New()
+	app.Get("/media/:key/download", h.DownloadMediaFile)
+
+	download := func(resourceKey, encKey string) string {
+		t.Helper()
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/media/"+resourceKey+"/download?enc_key="+encKey, nil))
+		if err != nil {
+			t.Fatalf("download request: %v", err)
+		}
+		if resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("download status = %d", resp.StatusCode)
+		}
+		return resp.Header.Get(fiber.HeaderContentType)
+	}
+
+	resourceKey, encKey := h.upload(t, "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR", mediaservice.UploadRequest{Filename: "photo.png"})
+	if got := download(resourceKey, encKey); got != "image/png" {
+		t.Errorf("Content-Type = %q, want image/png", got)
+	}
+
+	// Objects stored before content types were recorded are served as octet-stream
+	resourceKey, encKey = h.upload(t, "plain text", mediaservice.UploadRequest{})
+	stored, _ := h.storage.Object("", "media/"+resourceKey)
+	if _, err := h.storage.Upload(context.Background(), "", "media/"+resourceKey, bytes.NewReader(stored), ""); err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	if got := download(resourceKey, encKey); got != "application/octet-stream" {
+		t.Errorf("Content-Type without a stored type = %q, want application/octet-stream", got)
+	}
+}
@@ -0,0 +1,196 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	accessservice "lovebin/internal/services/access-service"
+	accessrepo "lovebin/internal/services/access-service/repository"
+	mediaservice "lovebin/internal/services/media-service"
+	"lovebin/modules/cache"
+	"lovebin/modules/encryption"
+	"lovebin/modules/logger"
+	"lovebin/modules/s3"
+)
+
+// fakeAccessRepo is an in-memory accessservice.Repository. err, when set, is
+// returned by every method
+type fakeAccessRepo struct {
+	resources    map[string]accessrepo.ResourceAccess
+	err          error
+	statusLoads  int      // calls of GetResourceStatuses
+	statusLoaded []string // keys of the last GetResourceStatuses call
+}
+
+func (r *fakeAccessRepo) VerifyPassword(ctx context.Context, resourceKey string) (string, error) {
+	access, err := r.CheckResourceAccess(ctx, resourceKey)
+	if err != nil || access.PasswordHash == nil {
+		return "", err
+	}
+	return *access.PasswordHash, nil
+}
+
+func (r *fakeAccessRepo) CheckResourceAccess(_ context.Context, resourceKey string) (accessrepo.ResourceAccess, error) {
+	if r.err != nil {
+		return accessrepo.ResourceAccess{}, r.err
+	}
+	access, ok := r.resources[resourceKey]
+	if !ok {
+		return accessrepo.ResourceAccess{}, pgx.ErrNoRows
+	}
+	return access, nil
+}
+
+func (r *fakeAccessRepo) IsAccessCodeUnused(context.Context, string, string) (bool, error) {
+	return false, r.err
+}
+
+func (r *fakeAccessRepo) CountUnusedAccessCodes(context.Context, string) (int, error) {
+	return 0, r.err
+}
+
+func (r *fakeAccessRepo) RequireSignedURL(_ context.Context, resourceKey string) (bool, error) {
+	if r.err != nil {
+		return false, r.err
+	}
+	access, ok := r.resources[resourceKey]
+	if !ok {
+		return false, nil
+	}
+	access.SignedURLRequired = true
+	r.resources[resourceKey] = access
+	return true, nil
+}
+
+func (r *fakeAccessRepo) GetResourceStatuses(_ context.Context, resourceKeys []string) ([]accessrepo.ResourceStatus, error) {
+	r.statusLoads++
+	r.statusLoaded = resourceKeys
+	if r.err != nil {
+		return nil, r.err
+	}
+	var statuses []accessrepo.ResourceStatus
+	for _, resourceKey := range resourceKeys {
+		if access, ok := r.resources[resourceKey]; ok {
+			statuses = append(statuses, accessrepo.ResourceStatus{
+				ResourceKey: access.ResourceKey,
+				ExpiresAt:   access.ExpiresAt,
+				Viewed:      access.Viewed,
+				AvailableAt: access.AvailableAt,
+			})
+		}
+	}
+	return statuses, nil
+}
+
+func newTestLogger(t *testing.T) logger.Logger {
+	t.Helper()
+	l, err := logger.Init(logger.Config{Level: "fatal"})
+	if err != nil {
+		t.Fatalf("logger.Init: %v", err)
+	}
+	return l
+}
+
+// newTestAccessService returns an access service on repo, without postgres, geoip or caching
+func newTestAccessService(t *testing.T, repo accessservice.Repository) *accessservice.Service {
+	t.Helper()
+	return accessservice.NewService(newTestLogger(t), nil, repo, nil, nil)
+}
+
+// inlinePostgres runs WithTx functions directly with a nil transaction, for
+// services whose repository is a mock that ignores it
+type inlinePostgres struct{}
+
+func (inlinePostgres) GetPool() *pgxpool.Pool { return nil }
+
+func (inlinePostgres) WithTx(_ context.Context, fn func(tx pgx.Tx) error) error {
+	return fn(nil)
+}
+
+func (inlinePostgres) Close() {}
+
+func (inlinePostgres) GracefulClose(context.Context) error { return nil }
+
+// testHandlers are Handlers on in-memory media and access repositories and MockS3
+type testHandlers struct {
+	*Handlers
+	media   *mediaservice.Service
+	access  *fakeAccessRepo
+	storage *s3.MockS3
+}
+
+// fastKDF keeps password tests quick, the parameters are not meant to be secure
+var fastKDF = encryption.Config{Argon2id: encryption.Argon2idParams{Time: 1, Memory: 64, Threads: 1}}
+
+func newTestHandlers(t *testing.T, cfg Config) *testHandlers {
+	t.Helper()
+	log := newTestLogger(t)
+	storage := s3.NewMockS3()
+	media := mediaservice.NewService(log, inlinePostgres{}, storage, encryption.Init(fastKDF, nil), nil,
+		mediaservice.NewMockRepository(), cache.NewLRU[string, mediaservice.MediaInfo](0), nil, mediaservice.Config{})
+	access := &fakeAccessRepo{resources: make(map[string]accessrepo.ResourceAccess)}
+	return &testHandlers{
+		Handlers: NewHandlers(log, media, newTestAccessService(t, access), nil, nil, cfg),
+		media:    media,
+		access:   access,
+		storage:  storage,
+	}
+}
+
+// upload stores content through the media service and makes it visible to the
+// access service, it returns the resource key and the URL key
+func (h *testHandlers) upload(t *testing.T, content string, req mediaservice.UploadRequest) (resourceKey, encKey string) {
+	t.Helper()
+	req.Data = bytes.NewReader([]byte(content))
+	req.Size = int64(len(content))
+	resp, err := h.media.UploadMedia(context.Background(), req)
+	if err != nil {
+		t.Fatalf("UploadMedia: %v", err)
+	}
+	resourceKey, encKey, _ = strings.Cut(resp.ResourceKey, "#")
+	h.access.resources[resourceKey] = accessrepo.ResourceAccess{ID: "id-" + resourceKey, ResourceKey: resourceKey}
+	return resourceKey, encKey
+}
+
+// uploadRequest builds a multipart upload of content as filename with the
+// given part content type (application/octet-stream when empty) and form fields
+func uploadRequest(t *testing.T, filename, contentType, content string, fields map[string]string) *http.Request {
+	t.Helper()
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	// The file is stored as it arrives, so the fields come first
+	for name, value := range fields {
+		if err := w.WriteField(name, value); err != nil {
+			t.Fatalf("WriteField: %v", err)
+		}
+	}
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename=%q`, filename))
+	header.Set("Content-Type", contentType)
+	part, err := w.CreatePart(header)
+	if err != nil {
+		t.Fatalf("CreatePart: %v", err)
+	}
+	_, _ = part.Write([]byte(content))
+	if err := w.Close(); err != nil {
+		t.Fatalf("close multipart writer: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/upload", &body)
+	req.Header.Set(fiber.HeaderContentType, w.FormDataContentType())
+	return req
+}
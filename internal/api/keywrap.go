@@ -0,0 +1,105 @@
+package api
+
+import (
+	"encoding/base64"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"lovebin/modules/encryption"
+)
+
+type WrapKeyRequest struct {
+	KeyBase64 string `json:"key"`
+}
+
+type WrapKeyResponse struct {
+	WrappedKeyBase64 string `json:"wrapped_key"`
+}
+
+type UnwrapKeyRequest struct {
+	WrappedKeyBase64 string `json:"wrapped_key"`
+}
+
+type UnwrapKeyResponse struct {
+	KeyBase64 string `json:"key"`
+}
+
+// WrapKey wraps a key with the server wrapping key for export
+// @Summary      Wrap key
+// @Description  Wrap a key with AES Key Wrap (RFC 3394) under the server wrapping key (KEY_WRAPPING_KEY) for transport in recovery scenarios. The key is standard base64, a multiple of 8 bytes and at least 16 bytes. Requires management token.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body      WrapKeyRequest  true  "Base64 key to wrap"
+// @Success      200      {object}  WrapKeyResponse
+// @Failure      400      {object}  map[string]string
+// @Failure      401      {object}  map[string]string
+// @Failure      503      {object}  map[string]string
+// @Router       /api/v1/admin/keys/wrap [post]
+func (h *Handlers) WrapKey(c *fiber.Ctx) error {
+	if h.cfg.KeyWrappingKey == nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "key wrapping is not configured"})
+	}
+
+	var req WrapKeyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request body"})
+	}
+	key, err := base64.StdEncoding.DecodeString(req.KeyBase64)
+	if err != nil || len(key) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "key must be base64"})
+	}
+
+	wrapped, err := encryption.WrapKey(key, h.cfg.KeyWrappingKey)
+	if errors.Is(err, encryption.ErrInvalidKeyToWrap) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		h.logger.ErrorCtx(c.Context(), "failed to wrap key", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to wrap key"})
+	}
+
+	return c.JSON(WrapKeyResponse{WrappedKeyBase64: base64.StdEncoding.EncodeToString(wrapped)})
+}
+
+// UnwrapKey unwraps a key wrapped by WrapKey for import
+// @Summary      Unwrap key
+// @Description  Unwrap a key wrapped with AES Key Wrap (RFC 3394) under the server wrapping key (KEY_WRAPPING_KEY). Tampered keys and keys wrapped under another wrapping key are rejected. Requires management token.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body      UnwrapKeyRequest  true  "Base64 wrapped key"
+// @Success      200      {object}  UnwrapKeyResponse
+// @Failure      400      {object}  map[string]string
+// @Failure      401      {object}  map[string]string
+// @Failure      503      {object}  map[string]string
+// @Router       /api/v1/admin/keys/unwrap [post]
+func (h *Handlers) UnwrapKey(c *fiber.Ctx) error {
+	if h.cfg.KeyWrappingKey == nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "key wrapping is not configured"})
+	}
+
+	var req UnwrapKeyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request body"})
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(req.WrappedKeyBase64)
+	if err != nil || len(wrapped) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "wrapped_key must be base64"})
+	}
+
+	key, err := encryption.UnwrapKey(wrapped, h.cfg.KeyWrappingKey)
+	if errors.Is(err, encryption.ErrKeyUnwrapFailed) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "wrapped key is invalid or was wrapped with another key"})
+	}
+	if err != nil {
+		h.logger.ErrorCtx(c.Context(), "failed to unwrap key", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to unwrap key"})
+	}
+
+	return c.JSON(UnwrapKeyResponse{KeyBase64: base64.StdEncoding.EncodeToString(key)})
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestWrapAndUnwrapKey(t *testing.T) {
+	h := newTestHandlers(t, Config{KeyWrappingKey: bytes.Repeat([]byte{1}, 32)})
+	app := fiber.New()
+	app.Post("/admin/keys/wrap", h.WrapKey)
+	app.Post("/admin/keys/unwrap", h.UnwrapKey)
+
+	key := bytes.Repeat([]byte{0xAB}, 32)
+	var wrapped WrapKeyResponse
+	body := fmt.Sprintf(`{"key":%q}`, base64.StdEncoding.EncodeToString(key))
+	if status := postJSON(t, app, "/admin/keys/wrap", body, &wrapped); status != fiber.StatusOK {
+		t.Fatalf("wrap status = %d, want 200", status)
+	}
+	if raw, err := base64.StdEncoding.DecodeString(wrapped.WrappedKeyBase64); err != nil || len(raw) != len(key)+8 {
+		t.Fatalf("wrapped key %q is not %d bytes of base64", wrapped.WrappedKeyBase64, len(key)+8)
+	}
+
+	var unwrapped UnwrapKeyResponse
+	body = fmt.Sprintf(`{"wrapped_key":%q}`, wrapped.WrappedKeyBase64)
+	if status := postJSON(t, app, "/admin/keys/unwrap", body, &unwrapped); status != fiber.StatusOK {
+		t.Fatalf("unwrap status = %d, want 200", status)
+	}
+	if got, _ := base64.StdEncoding.DecodeString(unwrapped.KeyBase64); !bytes.Equal(got, key) {
+		t.Errorf("unwrapped key = %x, want %x", got, key)
+	}
+
+	// A key wrapped elsewhere does not unwrap here
+	other := newTestHandlers(t, Config{KeyWrappingKey: bytes.Repeat([]byte{2}, 32)})
+	otherApp := fiber.New()
+	otherApp.Post("/admin/keys/unwrap", other.UnwrapKey)
+	if status := postJSON(t, otherApp, "/admin/keys/unwrap", body, nil); status != fiber.StatusBadRequest {
+		t.Errorf("unwrap under another wrapping key status = %d, want 400", status)
+	}
+}
+
+func TestWrapKeyErrors(t *testing.T) {
+	b64 := base64.StdEncoding.EncodeToString
+	tests := []struct {
+		name       string
+		wrapping   []byte
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{"wrap not configured", nil, "/admin/keys/wrap", fmt.Sprintf(`{"key":%q}`, b64(make([]byte, 16))), fiber.StatusServiceUnavailable},
+		{"unwrap not configured", nil, "/admin/keys/unwrap", fmt.Sprintf(`{"wrapped_key":%q}`, b64(make([]byte, 24))), fiber.StatusServiceUnavailable},
+		{"malformed body", make([]byte, 16), "/admin/keys/wrap", `{"key":`, fiber.StatusBadRequest},
+		{"key not base64", make([]byte, 16), "/admin/keys/wrap", `{"key":"not base64!"}`, fiber.StatusBadRequest},
+		{"empty key", make([]byte, 16), "/admin/keys/wrap", `{"key":""}`, fiber.StatusBadRequest},
+		{"short key", make([]byte, 16), "/admin/keys/wrap", fmt.Sprintf(`{"key":%q}`, b64(make([]byte, 8))), fiber.StatusBadRequest},
+		{"odd key length", make([]byte, 16), "/admin/keys/wrap", fmt.Sprintf(`{"key":%q}`, b64(make([]byte, 20))), fiber.StatusBadRequest},
+		{"wrapped key not base64", make([]byte, 16), "/admin/keys/unwrap", `{"wrapped_key":"%%%"}`, fiber.StatusBadRequest},
+		{"garbage wrapped key", make([]byte, 16), "/admin/keys/unwrap", fmt.Sprintf(`{"wrapped_key":%q}`, b64(make([]byte, 24))), fiber.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandlers(t, Config{KeyWrappingKey: tt.wrapping})
+			app := fiber.New()
+			app.Post("/admin/keys/wrap", h.WrapKey)
+			app.Post("/admin/keys/unwrap", h.UnwrapKey)
+			if status := postJSON(t, app, tt.path, tt.body, nil); status != tt.wantStatus {
+				t.Errorf("status = %d, want %d", status, tt.wantStatus)
+			}
+		})
+	}
+}
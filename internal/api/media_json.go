@@ -0,0 +1,129 @@
+package api
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	accessservice "lovebin/internal/services/access-service"
+	"lovebin/modules/timeparser"
+)
+
+type MediaMetadataResponse struct {
+	ResourceKey      string                    `json:"resource_key"`
+	Filename         string                    `json:"filename,omitempty"`
+	FileExtension    string                    `json:"file_extension,omitempty"`
+	IsImage          bool                      `json:"is_image"`
+	BlurIntensity    float64                   `json:"blur_intensity"`
+	DownloadOnly     bool                      `json:"download_only"`
+	ExpiresAt        timeparser.UniversalTime  `json:"expires_at"`
+	AvailableAt      *timeparser.UniversalTime `json:"available_at,omitempty"`
+	RequiresPassword bool                      `json:"requires_password"`
+	ViewsRemaining   int                       `json:"views_remaining"` // downloads left, each download uses one
+	// DownloadURL is set once access is granted. It is relative and carries no
+	// encryption key, append it as the URL fragment (#key) before downloading.
+	DownloadURL string `json:"download_url,omitempty"`
+}
+
+// ViewMediaJSON returns media metadata as JSON for client apps
+// @Summary      Media metadata
+// @Description  JSON counterpart of the /media/{key} view page. Checks access without using up the view. Password protected resources return their metadata without download_url until the correct password (or access code) is passed.
+// @Tags         media
+// @Produce      json
+// @Param        key       path      string  true   "Resource key"
+// @Param        password  query     string  false  "Password or access code of protected resources"
+// @Param        enc_key   query     string  false  "Encryption key, filename and file_extension of newer resources are only returned with it"
+// @Success      200       {object}  MediaMetadataResponse
+// @Failure      401       {object}  map[string]string
+// @Failure      403       {object}  map[string]string
+// @Failure      404       {object}  map[string]string
+// @Failure      410       {object}  map[string]string
+// @Failure      425       {object}  MediaMetadataResponse  "Scheduled resource, available_at tells when it opens"
+// @Failure      500       {object}  map[string]string
+// @Router       /api/v1/media/{key} [get]
+func (h *Handlers) ViewMediaJSON(c *fiber.Ctx) error {
+	// Fiber strings point into reused request buffers, the key outlives the request in the cache
+	resourceKey := strings.Clone(c.Params("key"))
+	password := c.Query("password", "")
+
+	accessInfo, accessErr := h.accessService.CheckResourceAccess(c.Context(), resourceKey)
+	switch accessErr {
+	case nil, accessservice.ErrNotYetAvailable:
+	case accessservice.ErrNotFound:
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "resource not found"})
+	case accessservice.ErrExpired, accessservice.ErrAlreadyViewed:
+		return c.Status(fiber.StatusGone).JSON(fiber.Map{"error": accessErr.Error()})
+	default:
+		h.logger.ErrorCtx(c.Context(), "failed to check resource access", zap.Error(accessErr))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to check access"})
+	}
+
+	if err := h.verifySignedURL(c, resourceKey, c.Query("enc_key"), accessInfo.SignedURLRequired); err != nil {
+		return err
+	}
+
+	mediaInfo, err := h.mediaService.GetMediaInfo(c.Context(), resourceKey, c.Query("enc_key"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "resource not found"})
+	}
+
+	resp := MediaMetadataResponse{
+		ResourceKey:      resourceKey,
+		IsImage:          mediaInfo.IsImage,
+		BlurIntensity:    mediaInfo.BlurIntensity,
+		DownloadOnly:     mediaInfo.DownloadOnly,
+		ExpiresAt:        accessInfo.ExpiresAt,
+		RequiresPassword: accessInfo.IsProtected(),
+		ViewsRemaining:   mediaInfo.ViewsRemaining,
+	}
+	if mediaInfo.Filename != nil {
+		resp.Filename = *mediaInfo.Filename
+	}
+	if mediaInfo.FileExtension != nil {
+		resp.FileExtension = *mediaInfo.FileExtension
+	}
+	if !accessInfo.AvailableAt.IsZero() {
+		resp.AvailableAt = &accessInfo.AvailableAt
+	}
+
+	// Scheduled resources only reveal their metadata and opening time
+	if accessErr == accessservice.ErrNotYetAvailable {
+		return c.Status(fiber.StatusTooEarly).JSON(resp)
+	}
+
+	if resp.RequiresPassword && password == "" {
+		return c.JSON(resp)
+	}
+
+	// Access codes are checked, not used up, like on the view page
+	switch err := h.accessService.CheckAccess(c.Context(), resourceKey, password); err {
+	case nil:
+	case accessservice.ErrPasswordRequired, accessservice.ErrInvalidPassword:
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid password"})
+	case accessservice.ErrCountryBlocked:
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	case accessservice.ErrNotFound:
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "resource not found"})
+	case accessservice.ErrExpired, accessservice.ErrAlreadyViewed:
+		return c.Status(fiber.StatusGone).JSON(fiber.Map{"error": err.Error()})
+	default:
+		h.logger.ErrorCtx(c.Context(), "failed to check access", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to check access"})
+	}
+
+	resp.DownloadURL = "/media/" + url.PathEscape(resourceKey) + "/download"
+	query := url.Values{}
+	if password != "" {
+		query.Set("password", password)
+	}
+	if sig := c.Query("sig", ""); sig != "" {
+		query.Set("expires", c.Query("expires", ""))
+		query.Set("sig", sig)
+	}
+	if len(query) > 0 {
+		resp.DownloadURL += "?" + query.Encode()
+	}
+	return c.JSON(resp)
+}
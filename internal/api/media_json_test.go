@@ -0,0 +1,111 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
+
+	accessrepo "lovebin/internal/services/access-service/repository"
+	mediaservice "lovebin/internal/services/media-service"
+	"lovebin/modules/timeparser"
+)
+
+func TestViewMediaJSON(t *testing.T) {
+	h := newTestHandlers(t, Config{})
+	app := fiber.New()
+	app.Get("/api/v1/media/:key", h.ViewMediaJSON)
+
+	resourceKey, encKey := h.upload(t, "json content", mediaservice.UploadRequest{Filename: "photo.png", MaxViews: 3})
+	protectedKey, _ := h.upload(t, "secret content", mediaservice.UploadRequest{})
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("bcrypt: %v", err)
+	}
+	passwordHash := string(hash)
+	protected := h.access.resources[protectedKey]
+	protected.PasswordHash = &passwordHash
+	h.access.resources[protectedKey] = protected
+
+	scheduledKey, _ := h.upload(t, "later", mediaservice.UploadRequest{})
+	availableAt := timeparser.UniversalTime{Time: time.Now().Add(time.Hour).UTC()}
+	scheduled := h.access.resources[scheduledKey]
+	scheduled.AvailableAt = availableAt
+	h.access.resources[scheduledKey] = scheduled
+
+	h.access.resources["viewed"] = accessrepo.ResourceAccess{ResourceKey: "viewed", Viewed: true}
+	h.access.resources["expired"] = accessrepo.ResourceAccess{
+		ResourceKey: "expired",
+		ExpiresAt:   timeparser.UniversalTime{Time: time.Now().Add(-time.Hour)},
+	}
+
+	tests := []struct {
+		name            string
+		path            string
+		wantStatus      int
+		wantFilename    string
+		wantDownloadURL string
+		wantPassword    bool
+		wantAvailableAt bool
+	}{
+		{"with key", "/api/v1/media/" + resourceKey + "?enc_key=" + encKey, fiber.StatusOK, "photo", "/media/" + resourceKey + "/download", false, false},
+		{"without key", "/api/v1/media/" + resourceKey, fiber.StatusOK, "", "/media/" + resourceKey + "/download", false, false},
+		{"password not given", "/api/v1/media/" + protectedKey, fiber.StatusOK, "", "", true, false},
+		{"wrong password", "/api/v1/media/" + protectedKey + "?password=guess", fiber.StatusUnauthorized, "", "", false, false},
+		{"password", "/api/v1/media/" + protectedKey + "?password=secret", fiber.StatusOK, "", "/media/" + protectedKey + "/download?password=secret", true, false},
+		{"scheduled", "/api/v1/media/" + scheduledKey, fiber.StatusTooEarly, "", "", false, true},
+		{"viewed", "/api/v1/media/viewed", fiber.StatusGone, "", "", false, false},
+		{"expired", "/api/v1/media/expired", fiber.StatusGone, "", "", false, false},
+		{"missing", "/api/v1/media/missing", fiber.StatusNotFound, "", "", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if err != nil {
+				t.Fatalf("request: %v", err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus != fiber.StatusOK && tt.wantStatus != fiber.StatusTooEarly {
+				return
+			}
+
+			var got MediaMetadataResponse
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if got.Filename != tt.wantFilename || got.DownloadURL != tt.wantDownloadURL || got.RequiresPassword != tt.wantPassword {
+				t.Errorf("response = %+v", got)
+			}
+			if (got.AvailableAt != nil) != tt.wantAvailableAt {
+				t.Errorf("available_at = %v, want set %v", got.AvailableAt, tt.wantAvailableAt)
+			}
+		})
+	}
+}
+
+func TestViewMediaJSONDoesNotUseTheView(t *testing.T) {
+	h := newTestHandlers(t, Config{})
+	app := fiber.New()
+	app.Get("/api/v1/media/:key", h.ViewMediaJSON)
+
+	resourceKey, encKey := h.upload(t, "content", mediaservice.UploadRequest{Filename: "a.txt", MaxViews: 2})
+	for range 3 {
+		var got MediaMetadataResponse
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/v1/media/"+resourceKey+"?enc_key="+encKey, nil))
+		if err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		if got.ViewsRemaining != 2 || got.FileExtension != "txt" {
+			t.Errorf("response = %+v, want 2 views remaining", got)
+		}
+	}
+}
@@ -0,0 +1,427 @@
+package api
+
+import (
+	"crypto/subtle"
+	"errors"
+	"io"
+	"math"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+
+	"lovebin/modules/audit"
+	"lovebin/modules/logger"
+	"lovebin/modules/ratelimit"
+)
+
+// RequireAdminToken protects management routes with a static bearer token.
+// An empty token disables the protected routes entirely.
+func RequireAdminToken(token string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if token == "" {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "management API is disabled",
+			})
+		}
+
+		provided, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "invalid or missing management token",
+			})
+		}
+
+		c.Locals(audit.ActorKey, audit.ActorAdmin)
+		return c.Next()
+	}
+}
+
+// AuditContext stores the client IP in the request context for audit entries
+func AuditContext() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Locals(audit.ClientIPKey, c.IP())
+		return c.Next()
+	}
+}
+
+// panicRecoveredKey marks a request whose handler panicked
+const panicRecoveredKey = "panic_recovered"
+
+// PanicRecoveryMiddleware recovers from panics in later handlers, logs the stack trace
+// and answers 500 with a JSON error instead of recover's plain text body
+func PanicRecoveryMiddleware(log logger.Logger) fiber.Handler {
+	recoverHandler := recover.New(recover.Config{
+		EnableStackTrace: true,
+		StackTraceHandler: func(c *fiber.Ctx, e interface{}) {
+			c.Locals(panicRecoveredKey, true)
+			log.ErrorCtx(c.Context(), "panic recovered",
+				zap.Any("panic", e),
+				zap.String("method", c.Method()),
+				zap.String("path", c.Path()),
+				zap.ByteString("stack", debug.Stack()),
+			)
+		},
+	})
+
+	return func(c *fiber.Ctx) error {
+		err := recoverHandler(c)
+		if panicked, _ := c.Locals(panicRecoveredKey).(bool); !panicked {
+			return err
+		}
+
+		// Drop whatever the handler wrote before panicking so the client always
+		// gets a complete JSON error. Headers like X-Request-ID are kept.
+		if c.Response().StatusCode() != 0 {
+			c.Response().ResetBody()
+		}
+		requestID, _ := c.Locals(logger.RequestIDKey).(string)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": fiber.Map{
+				"code":       "INTERNAL_ERROR",
+				"message":    "An unexpected error occurred",
+				"request_id": requestID,
+			},
+		})
+	}
+}
+
+// HSTS sets Strict-Transport-Security on HTTPS responses.
+// Plain HTTP responses are left untouched, as browsers ignore the header there.
+func HSTS(maxAge int, includeSubdomains, preload bool) fiber.Handler {
+	value := "max-age=" + strconv.Itoa(maxAge)
+	if includeSubdomains {
+		value += "; includeSubDomains"
+	}
+	if preload {
+		value += "; preload"
+	}
+
+	return func(c *fiber.Ctx) error {
+		if isHTTPS(c) {
+			c.Set(fiber.HeaderStrictTransportSecurity, value)
+		}
+		return c.Next()
+	}
+}
+
+// isHTTPS reports whether the client connection is HTTPS, directly or behind a proxy
+func isHTTPS(c *fiber.Ctx) bool {
+	if c.Protocol() == "https" {
+		return true
+	}
+	proto, _, _ := strings.Cut(c.Get(fiber.HeaderXForwardedProto), ",")
+	return strings.EqualFold(strings.TrimSpace(proto), "https")
+}
+
+// APIVersionHeader names the API version a response belongs to
+const APIVersionHeader = "X-API-Version"
+
+// APIVersion sets APIVersionHeader and defaults the response to application/json.
+// Handlers answering with another type, e.g. HTML for HTMX, set their own.
+func APIVersion(version string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Set(APIVersionHeader, version)
+		c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		return c.Next()
+	}
+}
+
+// RequireContentLength rejects POST/PUT requests without Content-Length (411)
+// or with a declared length above maxSize (413)
+func RequireContentLength(maxSize int64) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if c.Method() != fiber.MethodPost && c.Method() != fiber.MethodPut {
+			return c.Next()
+		}
+
+		// fasthttp reports chunked and unknown lengths as negative values
+		length := int64(c.Request().Header.ContentLength())
+		if length < 0 {
+			// The unread body would corrupt the next request on a kept-alive connection
+			c.Context().SetConnectionClose()
+			return c.Status(fiber.StatusLengthRequired).JSON(fiber.Map{
+				"error": "Content-Length header is required",
+			})
+		}
+		if length > maxSize {
+			c.Context().SetConnectionClose()
+			return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+				"error": "request body too large",
+			})
+		}
+
+		return c.Next()
+	}
+}
+
+// bodyStreamKey holds the request body stream as wrapped by the upload middleware,
+// e.g. with the bytes FileSizeGuard peeked at put back in front
+const bodyStreamKey = "body_stream"
+
+// requestBodyStream returns the unread body of a streamed request through the
+// wrappers of the upload middleware, or nil once the body is buffered
+func requestBodyStream(c *fiber.Ctx) io.Reader {
+	stream := c.Context().RequestBodyStream()
+	if stream == nil {
+		return nil
+	}
+	if wrapped, ok := c.Locals(bodyStreamKey).(io.Reader); ok {
+		return wrapped
+	}
+	return stream
+}
+
+// errRequestBodyTooLarge fails reads of a body past the LimitRequestBodyWithoutHeader limit
+var errRequestBodyTooLarge = errors.New("request body too large")
+
+// limitReader reads r, failing with err once more than remaining bytes arrive
+type limitReader struct {
+	r         io.Reader
+	remaining int64
+	err       error
+	exceeded  bool
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	if l.exceeded {
+		return 0, l.err
+	}
+	// Ask for one byte past the limit to tell "exactly at the limit" from "too large"
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	if int64(n) > l.remaining {
+		l.exceeded = true
+		return 0, l.err
+	}
+	l.remaining -= int64(n)
+	return n, err
+}
+
+// LimitRequestBodyWithoutHeader enforces maxSize on the bytes actually received,
+// which matters for streamed bodies where Fiber's BodyLimit is not applied.
+// The stream is not read here: reads past maxSize fail with errRequestBodyTooLarge,
+// and whatever the handler answered then is replaced by 413.
+func LimitRequestBodyWithoutHeader(maxSize int64) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		stream := requestBodyStream(c)
+		if stream == nil {
+			if int64(len(c.Body())) > maxSize {
+				return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+					"error": "request body too large",
+				})
+			}
+			return c.Next()
+		}
+
+		body := &limitReader{r: stream, remaining: maxSize, err: errRequestBodyTooLarge}
+		c.Locals(bodyStreamKey, body)
+		err := c.Next()
+		if body.exceeded {
+			// The unread body would corrupt the next request on a kept-alive connection
+			c.Context().SetConnectionClose()
+			c.Response().ResetBody()
+			return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+				"error": "request body too large",
+			})
+		}
+		return err
+	}
+}
+
+// Rate limit headers set by RateLimitPerIP
+const (
+	RateLimitLimitHeader     = "X-RateLimit-Limit"
+	RateLimitRemainingHeader = "X-RateLimit-Remaining"
+	RateLimitResetHeader     = "X-RateLimit-Reset" // seconds until RateLimitRemainingHeader grows
+)
+
+// RateLimitPerIP allows max requests per client IP and route within window, answering 429 above it.
+// Every response carries the rate limit headers, limiter errors let the request through.
+func RateLimitPerIP(limiter ratelimit.Limiter, max int, window time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Method() + " " + c.Route().Path + " " + c.IP()
+		decision, err := limiter.Allow(c.Context(), key, max, window)
+		if err != nil {
+			return c.Next()
+		}
+
+		reset := strconv.Itoa(int(math.Ceil(time.Until(decision.Reset).Seconds())))
+		c.Set(RateLimitLimitHeader, strconv.Itoa(max))
+		c.Set(RateLimitRemainingHeader, strconv.Itoa(decision.Remaining))
+		c.Set(RateLimitResetHeader, reset)
+		if !decision.Allowed {
+			c.Set(fiber.HeaderRetryAfter, reset)
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+				"error": "too many requests",
+			})
+		}
+		return c.Next()
+	}
+}
+
+// MetricsMiddleware records per-endpoint request duration and response size histograms
+// and the number of uploads in progress. Paths are labeled with the route pattern,
+// e.g. /media/{key}/download, so resource keys do not blow up cardinality.
+// The collectors are registered on registry, /metrics itself is not measured.
+func MetricsMiddleware(registry prometheus.Registerer) fiber.Handler {
+	duration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request duration by route.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "path", "status"})
+	size := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_response_size_bytes",
+		Help:    "HTTP response body size by route.",
+		Buckets: prometheus.ExponentialBuckets(256, 4, 10), // 256 B to 64 MiB
+	}, []string{"method", "path"})
+	uploads := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "lovebin_uploads_in_progress",
+		Help: "Number of POST /api/v1/upload requests being handled.",
+	})
+	registry.MustRegister(duration, size, uploads)
+
+	return func(c *fiber.Ctx) error {
+		if c.Path() == "/metrics" {
+			return c.Next()
+		}
+
+		if c.Method() == fiber.MethodPost && c.Path() == apiV1Prefix+"/upload" {
+			uploads.Inc()
+			defer uploads.Dec()
+		}
+
+		self := c.Route()
+		start := time.Now()
+		err := c.Next()
+
+		// Errors are turned into responses by the error handler after this middleware
+		status := c.Response().StatusCode()
+		if err != nil {
+			status = fiber.StatusInternalServerError
+			var fiberErr *fiber.Error
+			if errors.As(err, &fiberErr) {
+				status = fiberErr.Code
+			}
+		}
+
+		// Fiber strings point into reused request buffers, labels outlive the request
+		method := strings.Clone(c.Method())
+		path := "unmatched"
+		if route := c.Route(); route != self {
+			path = routePattern(route.Path)
+		}
+		duration.WithLabelValues(method, path, strconv.Itoa(status)).Observe(time.Since(start).Seconds())
+
+		// Reading a streamed body here would buffer it, its declared length is used instead
+		bodySize := 0
+		if c.Response().IsBodyStream() {
+			bodySize = max(c.Response().Header.ContentLength(), 0)
+		} else {
+			bodySize = len(c.Response().Body())
+		}
+		size.WithLabelValues(method, path).Observe(float64(bodySize))
+
+		return err
+	}
+}
+
+// routePattern writes the parameters of a route path as {name}
+func routePattern(routePath string) string {
+	segments := strings.Split(routePath, "/")
+	for i, segment := range segments {
+		if name, ok := strings.CutPrefix(segment, ":"); ok {
+			segments[i] = "{" + strings.TrimSuffix(name, "?") + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// sharedResponse is a snapshot of a response shared between deduplicated requests
+type sharedResponse struct {
+	status  int
+	body    []byte
+	headers [][2]string
+}
+
+// SingleFlight collapses concurrent identical GET/HEAD requests into one handler call.
+// Requests waiting on the same key receive a copy of the first request's response.
+// Must not be applied to one-time endpoints such as downloads.
+func SingleFlight(keyFunc func(*fiber.Ctx) string) fiber.Handler {
+	var group singleflight.Group
+
+	return func(c *fiber.Ctx) error {
+		// Only idempotent requests can be shared
+		if c.Method() != fiber.MethodGet && c.Method() != fiber.MethodHead {
+			return c.Next()
+		}
+
+		key := keyFunc(c)
+		if key == "" {
+			return c.Next()
+		}
+
+		leader := false
+		value, err, _ := group.Do(key, func() (interface{}, error) {
+			leader = true
+			if err := c.Next(); err != nil {
+				return nil, err
+			}
+
+			resp := c.Response()
+			shared := &sharedResponse{
+				status: resp.StatusCode(),
+				body:   append([]byte(nil), resp.Body()...),
+			}
+			resp.Header.VisitAll(func(k, v []byte) {
+				switch string(k) {
+				case fiber.HeaderContentLength, fiber.HeaderXRequestID:
+					return
+				}
+				shared.headers = append(shared.headers, [2]string{string(k), string(v)})
+			})
+			return shared, nil
+		})
+
+		// The leader already has its own response written
+		if leader || err != nil {
+			return err
+		}
+
+		shared := value.(*sharedResponse)
+		for _, header := range shared.headers {
+			c.Set(header[0], header[1])
+		}
+		return c.Status(shared.status).Send(shared.body)
+	}
+}
+
+// dedupVaryHeaders are the request headers the shared pages depend on: the
+// Referer may carry the encryption key, cookies the password form state
+var dedupVaryHeaders = []string{
+	fiber.HeaderReferer,
+	fiber.HeaderCookie,
+	fiber.HeaderAcceptLanguage,
+	TimezoneHeader,
+	"HX-Request",
+}
+
+// dedupKey builds a deduplication key from method, path, query params, client IP
+// and dedupVaryHeaders. The IP keeps per-country restrictions apart, a blocked
+// client must not receive the page rendered for an allowed one.
+func dedupKey(c *fiber.Ctx) string {
+	var key strings.Builder
+	key.WriteString(c.Method() + " " + c.OriginalURL() + " " + c.IP())
+	for _, header := range dedupVaryHeaders {
+		key.WriteString("\n" + header + ": " + c.Get(header))
+	}
+	return key.String()
+}
@@ -0,0 +1,635 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"lovebin/modules/logger"
+	"lovebin/modules/ratelimit"
+	"lovebin/modules/s3"
+)
+
+func TestSingleFlightSharesConcurrentRequests(t *testing.T) {
+	storage := s3.NewMockS3()
+	if _, err := storage.Upload(context.Background(), "", "preview", bytes.NewReader([]byte("preview bytes")), ""); err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var startOnce sync.Once
+
+	app := fiber.New()
+	app.Use(SingleFlight(dedupKey))
+	app.Get("/media/:key/preview", func(c *fiber.Ctx) error {
+		body, err := storage.Download(c.Context(), "", c.Params("key"))
+		if err != nil {
+			return err
+		}
+		defer body.Close()
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return err
+		}
+		// Hold the first download until every request is waiting on it
+		startOnce.Do(func() { close(started) })
+		<-release
+		c.Set(fiber.HeaderContentType, "image/png")
+		return c.Send(data)
+	})
+
+	const requests = 10
+	type result struct {
+		status      int
+		contentType string
+		body        string
+		err         error
+	}
+	results := make(chan result, requests)
+	for range requests {
+		go func() {
+			resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/media/preview/preview", nil), -1)
+			if err != nil {
+				results <- result{err: err}
+				return
+			}
+			body, err := io.ReadAll(resp.Body)
+			results <- result{resp.StatusCode, resp.Header.Get(fiber.HeaderContentType), string(body), err}
+		}()
+	}
+
+	<-started
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+
+	for range requests {
+		r := <-results
+		if r.err != nil {
+			t.Fatalf("request failed: %v", r.err)
+		}
+		if r.status != fiber.StatusOK || r.contentType != "image/png" || r.body != "preview bytes" {
+			t.Errorf("got %d %q %q, want 200 image/png \"preview bytes\"", r.status, r.contentType, r.body)
+		}
+	}
+	if calls := storage.Calls("Download"); calls != 1 {
+		t.Errorf("S3 downloads = %d, want 1", calls)
+	}
+}
+
+func TestSingleFlightSkipsWrites(t *testing.T) {
+	var mu sync.Mutex
+	calls := 0
+
+	app := fiber.New()
+	app.Use(SingleFlight(dedupKey))
+	app.Post("/upload", func(c *fiber.Ctx) error {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		return c.SendStatus(fiber.StatusCreated)
+	})
+
+	var wg sync.WaitGroup
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := app.Test(httptest.NewRequest(fiber.MethodPost, "/upload", nil), -1); err != nil {
+				t.Errorf("request failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if calls != 3 {
+		t.Errorf("handler calls = %d, want 3", calls)
+	}
+}
+
+func TestDedupKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		header map[string]string
+		same   bool
+	}{
+		{"identical", "/media/abc/preview?lang=en", nil, true},
+		{"other path", "/media/abd/preview?lang=en", nil, false},
+		{"other query", "/media/abc/preview?lang=ru", nil, false},
+		{"other client IP", "/media/abc/preview?lang=en", map[string]string{fiber.HeaderXForwardedFor: "203.0.113.9"}, false},
+		{"other referer", "/media/abc/preview?lang=en", map[string]string{fiber.HeaderReferer: "/media/abc#key"}, false},
+		{"other cookie", "/media/abc/preview?lang=en", map[string]string{fiber.HeaderCookie: "session=1"}, false},
+		{"other language", "/media/abc/preview?lang=en", map[string]string{fiber.HeaderAcceptLanguage: "ru"}, false},
+		{"other timezone", "/media/abc/preview?lang=en", map[string]string{TimezoneHeader: "Europe/Moscow"}, false},
+		{"htmx request", "/media/abc/preview?lang=en", map[string]string{"HX-Request": "true"}, false},
+		{"unrelated header", "/media/abc/preview?lang=en", map[string]string{fiber.HeaderUserAgent: "curl"}, true},
+	}
+
+	// The client IP is taken from X-Forwarded-For, as behind the production proxy
+	keyOf := func(target string, header map[string]string) string {
+		var key string
+		app := fiber.New(fiber.Config{ProxyHeader: fiber.HeaderXForwardedFor})
+		app.Get("/*", func(c *fiber.Ctx) error {
+			key = dedupKey(c)
+			return nil
+		})
+		req := httptest.NewRequest(fiber.MethodGet, target, nil)
+		req.Header.Set(fiber.HeaderXForwardedFor, "198.51.100.1")
+		for name, value := range header {
+			req.Header.Set(name, value)
+		}
+		if _, err := app.Test(req); err != nil {
+			t.Fatalf("app.Test: %v", err)
+		}
+		return key
+	}
+
+	baseKey := keyOf("/media/abc/preview?lang=en", nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := keyOf(tt.target, tt.header) == baseKey; got != tt.same {
+				t.Errorf("same key = %v, want %v", got, tt.same)
+			}
+		})
+	}
+}
+
+func TestHSTS(t *testing.T) {
+	tests := []struct {
+		name              string
+		maxAge            int
+		includeSubdomains bool
+		preload           bool
+		forwardedProto    string
+		want              string
+	}{
+		{"plain HTTP", 31536000, true, true, "", ""},
+		{"forwarded HTTP", 31536000, false, false, "http", ""},
+		{"max-age only", 31536000, false, false, "https", "max-age=31536000"},
+		{"subdomains", 600, true, false, "https", "max-age=600; includeSubDomains"},
+		{"preload", 63072000, true, true, "https", "max-age=63072000; includeSubDomains; preload"},
+		{"proxy chain", 600, false, false, "HTTPS, http", "max-age=600"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Use(HSTS(tt.maxAge, tt.includeSubdomains, tt.preload))
+			app.Get("/", func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusNoContent) })
+
+			req := httptest.NewRequest(fiber.MethodGet, "/", nil)
+			if tt.forwardedProto != "" {
+				req.Header.Set(fiber.HeaderXForwardedProto, tt.forwardedProto)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("app.Test: %v", err)
+			}
+			if got := resp.Header.Get(fiber.HeaderStrictTransportSecurity); got != tt.want {
+				t.Errorf("Strict-Transport-Security = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// serve runs app on a local listener and returns its base URL. app.Test always
+// sends a Content-Length, the size limits need chunked requests as well.
+func serve(t *testing.T, app *fiber.App) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go func() { _ = app.Listener(ln) }()
+	t.Cleanup(func() { _ = app.Shutdown() })
+	return "http://" + ln.Addr().String()
+}
+
+// sendBody sends body to url, chunked when chunked is set
+func sendBody(t *testing.T, method, url, body string, chunked bool) *http.Response {
+	t.Helper()
+	var reader io.Reader = strings.NewReader(body)
+	if chunked {
+		// An unknown length makes the client use chunked encoding
+		reader = io.MultiReader(reader)
+	}
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	return resp
+}
+
+func TestRequireContentLength(t *testing.T) {
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Use(RequireContentLength(100))
+	app.All("/upload", func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) })
+	url := serve(t, app)
+
+	tests := []struct {
+		name      string
+		method    string
+		body      string
+		chunked   bool
+		want      int
+		wantClose bool
+	}{
+		{"declared length", fiber.MethodPost, "data", false, fiber.StatusOK, false},
+		{"at the limit", fiber.MethodPost, strings.Repeat("a", 100), false, fiber.StatusOK, false},
+		{"above the limit", fiber.MethodPost, strings.Repeat("a", 101), false, fiber.StatusRequestEntityTooLarge, true},
+		{"chunked post", fiber.MethodPost, "data", true, fiber.StatusLengthRequired, true},
+		{"chunked put", fiber.MethodPut, "data", true, fiber.StatusLengthRequired, true},
+		{"get", fiber.MethodGet, "", false, fiber.StatusOK, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := sendBody(t, tt.method, url+"/upload", tt.body, tt.chunked)
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+			if resp.Close != tt.wantClose {
+				t.Errorf("connection close = %v, want %v", resp.Close, tt.wantClose)
+			}
+		})
+	}
+}
+
+func TestLimitRequestBodyWithoutHeader(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream bool
+		size   int
+		want   int
+	}{
+		{"streamed under the limit", true, 99, fiber.StatusOK},
+		{"streamed at the limit", true, 100, fiber.StatusOK},
+		{"streamed above the limit", true, 101, fiber.StatusRequestEntityTooLarge},
+		{"streamed far above the limit", true, 100000, fiber.StatusRequestEntityTooLarge},
+		{"buffered at the limit", false, 100, fiber.StatusOK},
+		{"buffered above the limit", false, 101, fiber.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New(fiber.Config{StreamRequestBody: tt.stream, DisableStartupMessage: true})
+			var received int
+			app.Post("/upload", LimitRequestBodyWithoutHeader(100), func(c *fiber.Ctx) error {
+				body := requestBodyStream(c)
+				if body == nil {
+					received = len(c.Body())
+					return c.SendStatus(fiber.StatusOK)
+				}
+				// The handler gets the stream and sees the limit as a read error
+				n, err := io.Copy(io.Discard, body)
+				received = int(n)
+				if err != nil {
+					return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+				}
+				return c.SendStatus(fiber.StatusOK)
+			})
+
+			resp := sendBody(t, fiber.MethodPost, serve(t, app)+"/upload", strings.Repeat("a", tt.size), true)
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+			if tt.want == fiber.StatusOK && received != tt.size {
+				t.Errorf("handler read %d bytes, want %d", received, tt.size)
+			}
+		})
+	}
+}
+
+func TestRateLimitPerIP(t *testing.T) {
+	app := fiber.New()
+	limiter := ratelimit.NewTokenBucketLimiter()
+	ok := func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) }
+	app.Post("/limited", RateLimitPerIP(limiter, 3, time.Minute), ok)
+	app.Post("/other", RateLimitPerIP(limiter, 3, time.Minute), ok)
+
+	for i, wantRemaining := range []string{"2", "1", "0"} {
+		resp, err := app.Test(httptest.NewRequest(fiber.MethodPost, "/limited", nil))
+		if err != nil {
+			t.Fatalf("request %d: %v", i+1, err)
+		}
+		if resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("request %d status = %d, want 200", i+1, resp.StatusCode)
+		}
+		if got := resp.Header.Get(RateLimitLimitHeader); got != "3" {
+			t.Errorf("%s = %q, want 3", RateLimitLimitHeader, got)
+		}
+		if got := resp.Header.Get(RateLimitRemainingHeader); got != wantRemaining {
+			t.Errorf("request %d %s = %q, want %s", i+1, RateLimitRemainingHeader, got, wantRemaining)
+		}
+	}
+
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodPost, "/limited", nil))
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusTooManyRequests {
+		t.Errorf("status over the limit = %d, want 429", resp.StatusCode)
+	}
+	if retryAfter, _ := strconv.Atoi(resp.Header.Get(fiber.HeaderRetryAfter)); retryAfter <= 0 || retryAfter > 60 {
+		t.Errorf("Retry-After = %q, want up to a minute", resp.Header.Get(fiber.HeaderRetryAfter))
+	}
+
+	// Routes are limited separately
+	resp, err = app.Test(httptest.NewRequest(fiber.MethodPost, "/other", nil))
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Errorf("other route status = %d, want 200", resp.StatusCode)
+	}
+}
+
+// failingLimiter fails every Allow call
+type failingLimiter struct{}
+
+func (failingLimiter) Allow(context.Context, string, int, time.Duration) (ratelimit.Decision, error) {
+	return ratelimit.Decision{}, errors.New("limiter unavailable")
+}
+
+func TestRateLimitPerIPFailsOpen(t *testing.T) {
+	app := fiber.New()
+	app.Post("/limited", RateLimitPerIP(failingLimiter{}, 1, time.Minute), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+	for range 3 {
+		resp, err := app.Test(httptest.NewRequest(fiber.MethodPost, "/limited", nil))
+		if err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		if resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("status with a failing limiter = %d, want 200", resp.StatusCode)
+		}
+	}
+}
+
+func TestPanicRecoveryMiddleware(t *testing.T) {
+	tests := []struct {
+		name       string
+		handler    fiber.Handler
+		wantStatus int
+		wantBody   string // the whole body, empty for the JSON error
+		wantLogged bool
+	}{
+		{
+			name:       "panic",
+			handler:    func(*fiber.Ctx) error { panic("boom") },
+			wantStatus: fiber.StatusInternalServerError,
+			wantLogged: true,
+		},
+		{
+			name: "panic after a partial response",
+			handler: func(c *fiber.Ctx) error {
+				c.Status(fiber.StatusOK).Set(fiber.HeaderContentType, fiber.MIMETextPlain)
+				_, _ = c.WriteString("partial")
+				panic(errors.New("boom"))
+			},
+			wantStatus: fiber.StatusInternalServerError,
+			wantLogged: true,
+		},
+		{
+			name:       "no panic",
+			handler:    func(c *fiber.Ctx) error { return c.SendString("ok") },
+			wantStatus: fiber.StatusOK,
+			wantBody:   "ok",
+		},
+		{
+			name:       "returned error",
+			handler:    func(*fiber.Ctx) error { return fiber.ErrTeapot },
+			wantStatus: fiber.StatusTeapot,
+			wantBody:   "I'm a teapot",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logPath := filepath.Join(t.TempDir(), "app.log")
+			log, err := logger.FileLogger(logPath, logger.Config{Level: "error"})
+			if err != nil {
+				t.Fatalf("FileLogger: %v", err)
+			}
+
+			app := fiber.New()
+			app.Use(func(c *fiber.Ctx) error {
+				c.Locals(logger.RequestIDKey, "req-1")
+				return c.Next()
+			})
+			app.Use(PanicRecoveryMiddleware(log))
+			app.Get("/", tt.handler)
+
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+			if err != nil {
+				t.Fatalf("request: %v", err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+
+			if tt.wantBody != "" {
+				if string(body) != tt.wantBody {
+					t.Errorf("body = %q, want %q", body, tt.wantBody)
+				}
+			} else {
+				var got struct {
+					Error struct {
+						Code      string `json:"code"`
+						Message   string `json:"message"`
+						RequestID string `json:"request_id"`
+					} `json:"error"`
+				}
+				if err := json.Unmarshal(body, &got); err != nil {
+					t.Fatalf("body %q is not JSON: %v", body, err)
+				}
+				if got.Error.Code != "INTERNAL_ERROR" || got.Error.Message != "An unexpected error occurred" || got.Error.RequestID != "req-1" {
+					t.Errorf("error = %+v", got.Error)
+				}
+				if ct := resp.Header.Get(fiber.HeaderContentType); !strings.HasPrefix(ct, fiber.MIMEApplicationJSON) {
+					t.Errorf("Content-Type = %q, want JSON", ct)
+				}
+			}
+
+			_ = log.Sync()
+			logged, err := os.ReadFile(logPath)
+			if err != nil {
+				t.Fatalf("read log: %v", err)
+			}
+			if !tt.wantLogged {
+				if len(logged) != 0 {
+					t.Errorf("unexpected log: %s", logged)
+				}
+				return
+			}
+			var entry struct {
+				Level   string `json:"level"`
+				Message string `json:"message"`
+				Panic   string `json:"panic"`
+				Path    string `json:"path"`
+				Stack   string `json:"stack"`
+			}
+			if err := json.Unmarshal(logged, &entry); err != nil {
+				t.Fatalf("log %q is not a JSON entry: %v", logged, err)
+			}
+			if entry.Level != "error" || entry.Message != "panic recovered" || entry.Panic != "boom" || entry.Path != "/" {
+				t.Errorf("log entry = %+v", entry)
+			}
+			if !strings.Contains(entry.Stack, "goroutine") || !strings.Contains(entry.Stack, "middleware_test.go") {
+				t.Errorf("stack trace does not reach the handler:\n%s", entry.Stack)
+			}
+		})
+	}
+}
+
+func TestRoutePattern(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/", "/"},
+		{"/upload", "/upload"},
+		{"/media/:key", "/media/{key}"},
+		{"/media/:key/download", "/media/{key}/download"},
+		{"/api/v1/media/:key/preview/:size?", "/api/v1/media/{key}/preview/{size}"},
+	}
+	for _, tt := range tests {
+		if got := routePattern(tt.path); got != tt.want {
+			t.Errorf("routePattern(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+// histograms returns the sample count and sum of every histogram series in
+// registry, keyed by metric name and label values
+func histograms(t *testing.T, registry *prometheus.Registry) map[string][2]float64 {
+	t.Helper()
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	series := make(map[string][2]float64)
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			histogram := metric.GetHistogram()
+			if histogram == nil {
+				continue
+			}
+			key := family.GetName()
+			for _, label := range metric.GetLabel() {
+				key += " " + label.GetName() + "=" + label.GetValue()
+			}
+			series[key] = [2]float64{float64(histogram.GetSampleCount()), histogram.GetSampleSum()}
+		}
+	}
+	return series
+}
+
+func TestMetricsMiddleware(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	app := fiber.New()
+	app.Use(MetricsMiddleware(registry))
+	app.Get("/media/:key", func(c *fiber.Ctx) error { return c.SendString("0123456789") })
+	app.Get("/fail", func(*fiber.Ctx) error { return fiber.ErrTeapot })
+	app.Get("/metrics", func(c *fiber.Ctx) error { return c.SendString("metrics") })
+
+	for _, path := range []string{"/media/first", "/media/second", "/fail", "/missing", "/metrics"} {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, path, nil))
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		_ = resp.Body.Close()
+	}
+
+	got := histograms(t, registry)
+	want := map[string]float64{
+		// Resource keys collapse into the route pattern
+		"http_request_duration_seconds method=GET path=/media/{key} status=200": 2,
+		"http_response_size_bytes method=GET path=/media/{key}":                 2,
+		"http_request_duration_seconds method=GET path=/fail status=418":        1,
+		"http_response_size_bytes method=GET path=/fail":                        1,
+		"http_request_duration_seconds method=GET path=unmatched status=404":    1,
+		"http_response_size_bytes method=GET path=unmatched":                    1,
+	}
+	if len(got) != len(want) {
+		t.Errorf("got series %v, want %d series", got, len(want))
+	}
+	for key, count := range want {
+		if got[key][0] != count {
+			t.Errorf("%s has %v samples, want %v", key, got[key][0], count)
+		}
+	}
+	if sum := got["http_response_size_bytes method=GET path=/media/{key}"][1]; sum != 20 {
+		t.Errorf("response sizes of /media/{key} sum to %v bytes, want 20", sum)
+	}
+}
+
+func TestMetricsMiddlewareCountsUploadsInProgress(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	inProgress := func() float64 {
+		families, err := registry.Gather()
+		if err != nil {
+			t.Fatalf("Gather: %v", err)
+		}
+		for _, family := range families {
+			if family.GetName() == "lovebin_uploads_in_progress" {
+				return family.GetMetric()[0].GetGauge().GetValue()
+			}
+		}
+		t.Fatal("lovebin_uploads_in_progress not registered")
+		return 0
+	}
+
+	app := fiber.New()
+	app.Use(MetricsMiddleware(registry))
+	var during float64
+	app.Post(apiV1Prefix+"/upload", func(c *fiber.Ctx) error {
+		during = inProgress()
+		return c.SendStatus(fiber.StatusCreated)
+	})
+	app.Post("/other", func(c *fiber.Ctx) error {
+		during = inProgress()
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	for _, tt := range []struct {
+		path string
+		want float64
+	}{
+		{apiV1Prefix + "/upload", 1},
+		{"/other", 0},
+	} {
+		resp, err := app.Test(httptest.NewRequest(http.MethodPost, tt.path, nil))
+		if err != nil {
+			t.Fatalf("POST %s: %v", tt.path, err)
+		}
+		_ = resp.Body.Close()
+		if during != tt.want {
+			t.Errorf("uploads in progress during POST %s = %v, want %v", tt.path, during, tt.want)
+		}
+		if after := inProgress(); after != 0 {
+			t.Errorf("uploads in progress after POST %s = %v, want 0", tt.path, after)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"lovebin/modules/postgres"
+	"lovebin/modules/timeparser"
+)
+
+type MigrationInfo struct {
+	Version     int64                     `json:"version"`
+	Description string                    `json:"description"`
+	AppliedAt   *timeparser.UniversalTime `json:"applied_at,omitempty"`
+}
+
+type MigrationStatusResponse struct {
+	Applied []MigrationInfo `json:"applied"`
+	Pending []MigrationInfo `json:"pending"`
+}
+
+type MigrationRunResponse struct {
+	Applied []MigrationInfo `json:"applied"` // migrations applied by this run
+}
+
+// MigrationStatus lists applied and pending database migrations
+// @Summary      Migration status
+// @Description  Applied and pending goose migrations of the database. Returns 202 while migrations are pending. Requires management token.
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  MigrationStatusResponse  "Database is up to date"
+// @Success      202  {object}  MigrationStatusResponse  "Migrations are pending, run them with POST /api/v1/admin/migrations/run"
+// @Failure      401  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /api/v1/admin/migrations/status [get]
+func (h *Handlers) MigrationStatus(c *fiber.Ctx) error {
+	status, err := h.migrator.Status(c.Context())
+	if err != nil {
+		h.logger.ErrorCtx(c.Context(), "failed to get migration status", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to get migration status"})
+	}
+
+	resp := MigrationStatusResponse{
+		Applied: toMigrationInfos(status.Applied),
+		Pending: toMigrationInfos(status.Pending),
+	}
+	if len(resp.Pending) > 0 {
+		return c.Status(fiber.StatusAccepted).JSON(resp)
+	}
+	return c.JSON(resp)
+}
+
+// RunMigrations applies pending database migrations
+// @Summary      Run migrations
+// @Description  Apply pending goose migrations in version order, each in its own transaction. Concurrent runs wait for each other. Requires management token.
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  MigrationRunResponse
+// @Failure      401  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /api/v1/admin/migrations/run [post]
+func (h *Handlers) RunMigrations(c *fiber.Ctx) error {
+	applied, err := h.migrator.Up(c.Context())
+	if err != nil {
+		h.logger.ErrorCtx(c.Context(), "failed to run migrations", zap.Error(err), zap.Int("applied", len(applied)))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "failed to run migrations",
+			"applied": toMigrationInfos(applied),
+		})
+	}
+
+	for _, migration := range applied {
+		h.logger.InfoCtx(c.Context(), "migration applied", zap.Int64("version", migration.Version), zap.String("description", migration.Description))
+	}
+	return c.JSON(MigrationRunResponse{Applied: toMigrationInfos(applied)})
+}
+
+func toMigrationInfos(migrations []postgres.Migration) []MigrationInfo {
+	infos := make([]MigrationInfo, 0, len(migrations))
+	for _, migration := range migrations {
+		info := MigrationInfo{
+			Version:     migration.Version,
+			Description: migration.Description,
+		}
+		if migration.AppliedAt != nil && !migration.AppliedAt.IsZero() {
+			appliedAt := timeparser.NewUniversalTime(*migration.AppliedAt)
+			info.AppliedAt = &appliedAt
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
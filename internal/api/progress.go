@@ -0,0 +1,224 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// uploadProgressTimeout bounds how long an unused or stalled progress session lives
+const uploadProgressTimeout = 5 * time.Minute
+
+// UploadSessionHeader carries the progress session ID on upload requests.
+// The multipart body is not parsed yet when tracking starts, so the form field alone is not enough.
+const UploadSessionHeader = "X-Upload-Session"
+
+// Phases of an upload reported in ProgressEvent
+const (
+	ProgressPhaseReceive = "receive" // the request body reaches the server
+	ProgressPhaseStore   = "store"   // the encrypted file is stored
+)
+
+// ProgressEvent is a single upload progress update sent over SSE
+type ProgressEvent struct {
+	Bytes int64  `json:"bytes"`
+	Total int64  `json:"total"`
+	Phase string `json:"phase"` // ProgressPhaseReceive or ProgressPhaseStore
+}
+
+type InitProgressResponse struct {
+	SessionID string `json:"session_id"`
+}
+
+// progressSession relays progress events of one upload to one SSE subscriber
+type progressSession struct {
+	events chan ProgressEvent
+	done   chan struct{}
+	once   sync.Once
+	timer  *time.Timer
+}
+
+func (s *progressSession) close() {
+	s.once.Do(func() {
+		s.timer.Stop()
+		close(s.done)
+	})
+}
+
+// publish sends an event without blocking, replacing a stale unread event
+func (s *progressSession) publish(event ProgressEvent) {
+	for {
+		select {
+		case <-s.done:
+			return
+		case s.events <- event:
+			return
+		default:
+		}
+		select {
+		case <-s.events:
+		default:
+		}
+	}
+}
+
+// progressTracker keeps active upload progress sessions keyed by session ID
+type progressTracker struct {
+	sessions sync.Map // session ID -> *progressSession
+}
+
+func newProgressTracker() *progressTracker {
+	return &progressTracker{}
+}
+
+// create registers a new session that expires after uploadProgressTimeout
+func (t *progressTracker) create() string {
+	id := uuid.NewString()
+	session := &progressSession{
+		events: make(chan ProgressEvent, 1),
+		done:   make(chan struct{}),
+	}
+	session.timer = time.AfterFunc(uploadProgressTimeout, func() {
+		t.finish(id)
+	})
+	t.sessions.Store(id, session)
+	return id
+}
+
+func (t *progressTracker) get(id string) (*progressSession, bool) {
+	value, ok := t.sessions.Load(id)
+	if !ok {
+		return nil, false
+	}
+	return value.(*progressSession), true
+}
+
+// finish removes the session and ends its SSE stream
+func (t *progressTracker) finish(id string) {
+	if value, ok := t.sessions.LoadAndDelete(id); ok {
+		value.(*progressSession).close()
+	}
+}
+
+// progressReader reports bytes read from the wrapped request body
+type progressReader struct {
+	r       io.Reader
+	read    atomic.Int64
+	total   int64
+	session *progressSession
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.session.publish(ProgressEvent{Bytes: p.read.Add(int64(n)), Total: p.total, Phase: ProgressPhaseReceive})
+	}
+	return n, err
+}
+
+// TrackUploadProgress reports progress as the handler reads the streamed request body
+// of uploads that carry a session ID. Requires fiber.Config.StreamRequestBody, otherwise
+// the body is already fully read before any handler runs.
+func (h *Handlers) TrackUploadProgress(c *fiber.Ctx) error {
+	sessionID := c.Get(UploadSessionHeader)
+	if sessionID == "" {
+		sessionID = c.Query("session_id")
+	}
+	if sessionID == "" {
+		return c.Next()
+	}
+
+	session, ok := h.progress.get(sessionID)
+	if !ok {
+		return c.Next()
+	}
+	defer h.progress.finish(sessionID)
+
+	if stream := requestBodyStream(c); stream != nil {
+		total := int64(c.Request().Header.ContentLength())
+		c.Locals(bodyStreamKey, &progressReader{r: stream, total: total, session: session})
+	}
+
+	return c.Next()
+}
+
+// InitUploadProgress creates an upload progress session
+// @Summary      Create upload progress session
+// @Description  Returns a session_id to send with POST /api/v1/upload (form field and X-Upload-Session header) and to subscribe to via GET /upload/progress/{session_id}. Sessions expire after 5 minutes.
+// @Tags         media
+// @Produce      json
+// @Success      200  {object}  InitProgressResponse
+// @Router       /upload/init-progress [post]
+func (h *Handlers) InitUploadProgress(c *fiber.Ctx) error {
+	return c.JSON(InitProgressResponse{SessionID: h.progress.create()})
+}
+
+// UploadProgress streams upload progress as Server-Sent Events
+// @Summary      Stream upload progress
+// @Description  Server-Sent Events stream of upload progress: data: {"bytes":N,"total":M,"phase":P}. Phase "receive" counts the request body reaching the server, "store" the encrypted file written to storage as it arrives (its size differs from the body, store events start once it is known). The stream ends when the upload completes or the session expires.
+// @Tags         media
+// @Produce      text/event-stream
+// @Param        session_id  path      string  true  "Progress session ID"
+// @Success      200  {object}  ProgressEvent
+// @Failure      404  {object}  map[string]string
+// @Router       /upload/progress/{session_id} [get]
+func (h *Handlers) UploadProgress(c *fiber.Ctx) error {
+	session, ok := h.progress.get(c.Params("session_id"))
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "progress session not found",
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	// Events must reach the client unbuffered and uncompressed
+	c.Set(fiber.HeaderContentEncoding, "identity")
+	c.Set("X-Accel-Buffering", "no")
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		// Flush headers right away so the client sees the stream open
+		if _, err := w.WriteString(": connected\n\n"); err != nil || w.Flush() != nil {
+			return
+		}
+		for {
+			select {
+			case <-session.done:
+				// Deliver the final update left by the upload
+				select {
+				case event := <-session.events:
+					_ = writeProgressEvent(w, event)
+				default:
+				}
+				return
+			case event := <-session.events:
+				if err := writeProgressEvent(w, event); err != nil {
+					// Client went away
+					return
+				}
+			}
+		}
+	})
+
+	return nil
+}
+
+// writeProgressEvent writes one SSE frame and flushes it to the client
+func writeProgressEvent(w *bufio.Writer, event ProgressEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "data: %s\n\n", payload); err != nil {
+		return err
+	}
+	return w.Flush()
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"bytes"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestProgressSessionPublishKeepsLatest(t *testing.T) {
+	tracker := newProgressTracker()
+	id := tracker.create()
+	defer tracker.finish(id)
+	session, ok := tracker.get(id)
+	if !ok {
+		t.Fatal("created session not found")
+	}
+
+	// Nobody reads yet, publishing must not block and only the newest event is kept
+	for i := int64(1); i <= 5; i++ {
+		session.publish(ProgressEvent{Bytes: i, Total: 5, Phase: ProgressPhaseReceive})
+	}
+	if event := <-session.events; event.Bytes != 5 {
+		t.Errorf("pending event = %+v, want bytes 5", event)
+	}
+}
+
+func TestProgressTrackerFinish(t *testing.T) {
+	tracker := newProgressTracker()
+	id := tracker.create()
+	session, _ := tracker.get(id)
+
+	tracker.finish(id)
+	if _, ok := tracker.get(id); ok {
+		t.Error("session still registered after finish")
+	}
+	select {
+	case <-session.done:
+	default:
+		t.Error("session not closed by finish")
+	}
+
+	// Publishing to and finishing a closed session are no-ops
+	session.publish(ProgressEvent{Bytes: 1})
+	tracker.finish(id)
+}
+
+func TestProgressReaderReportsCumulativeBytes(t *testing.T) {
+	tracker := newProgressTracker()
+	id := tracker.create()
+	defer tracker.finish(id)
+	session, _ := tracker.get(id)
+
+	data := bytes.Repeat([]byte("x"), 10000)
+	reader := &progressReader{r: bytes.NewReader(data), total: int64(len(data)), session: session}
+	if _, err := io.Copy(io.Discard, reader); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	event := <-session.events
+	if event.Bytes != int64(len(data)) || event.Total != int64(len(data)) || event.Phase != ProgressPhaseReceive {
+		t.Errorf("last event = %+v, want %d of %d received", event, len(data), len(data))
+	}
+}
+
+func TestUploadProgressStream(t *testing.T) {
+	h := &Handlers{progress: newProgressTracker()}
+	app := fiber.New()
+	app.Get("/upload/progress/:session_id", h.UploadProgress)
+
+	t.Run("unknown session", func(t *testing.T) {
+		resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/upload/progress/missing", nil))
+		if err != nil {
+			t.Fatalf("app.Test: %v", err)
+		}
+		if resp.StatusCode != fiber.StatusNotFound {
+			t.Errorf("status = %d, want 404", resp.StatusCode)
+		}
+	})
+
+	t.Run("final event of a finished upload", func(t *testing.T) {
+		id := h.progress.create()
+		session, _ := h.progress.get(id)
+		session.publish(ProgressEvent{Bytes: 42, Total: 42, Phase: ProgressPhaseStore})
+		h.progress.finish(id)
+
+		// The session is gone from the tracker, subscribe before finishing in real use
+		h.progress.sessions.Store(id, session)
+		defer h.progress.sessions.Delete(id)
+
+		resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/upload/progress/"+id, nil), -1)
+		if err != nil {
+			t.Fatalf("app.Test: %v", err)
+		}
+		if got := resp.Header.Get(fiber.HeaderContentType); got != "text/event-stream" {
+			t.Errorf("content type = %q, want text/event-stream", got)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		want := ": connected\n\ndata: {\"bytes\":42,\"total\":42,\"phase\":\"store\"}\n\n"
+		if string(body) != want {
+			t.Errorf("body = %q, want %q", body, want)
+		}
+	})
+}
+
+func TestTrackUploadProgress(t *testing.T) {
+	h := &Handlers{progress: newProgressTracker()}
+	app := fiber.New(fiber.Config{StreamRequestBody: true})
+	app.Post("/upload", h.TrackUploadProgress, func(c *fiber.Ctx) error {
+		body, err := io.ReadAll(requestBodyStream(c))
+		if err != nil {
+			return err
+		}
+		return c.Send(body)
+	})
+
+	id := h.progress.create()
+	session, _ := h.progress.get(id)
+
+	req := httptest.NewRequest(fiber.MethodPost, "/upload", strings.NewReader("file contents"))
+	req.Header.Set(UploadSessionHeader, id)
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("app.Test: %v", err)
+	}
+	if body, _ := io.ReadAll(resp.Body); string(body) != "file contents" {
+		t.Errorf("handler saw body %q", body)
+	}
+
+	// The session ends with the request and keeps its last event for the subscriber
+	if _, ok := h.progress.get(id); ok {
+		t.Error("session still registered after the upload")
+	}
+	if event := <-session.events; event.Bytes != int64(len("file contents")) {
+		t.Errorf("last event = %+v", event)
+	}
+}
@@ -5,19 +5,12 @@ import (
 	"path/filepath"
 	"time"
 
-	_ "lovebin/docs" // swagger docs
-
 	"lovebin/modules/logger"
 
 	"github.com/gofiber/fiber/v2"
-	fiberSwagger "github.com/swaggo/fiber-swagger"
 	"go.uber.org/zap"
 )
 
-var (
-	frontendDir string = findFrontendDir()
-)
-
 // findFrontendDir tries to find the frontend directory
 func findFrontendDir() string {
 	paths := []string{}
@@ -55,6 +48,8 @@ func findFrontendDir() string {
 }
 
 func SetupRoutes(app *fiber.App, handlers *Handlers, log logger.Logger) {
+	// Static files are served from the directory found at startup, also in dev mode
+	frontendDir := handlers.templates.Dir()
 	log.Info("Setting up static files", zap.String("frontend_dir", frontendDir))
 
 	// Verify the directory exists and contains logo.jpg
@@ -81,6 +76,7 @@ func SetupRoutes(app *fiber.App, handlers *Handlers, log logger.Logger) {
 
 	// Also add explicit route for logo as fallback
 	app.Get("/static/logo.jpg", func(c *fiber.Ctx) error {
+		frontendDir := handlers.templates.Dir()
 		logoFile := filepath.Join(frontendDir, "logo.jpg")
 		logoFile = filepath.Clean(logoFile)
 		// Try to get absolute path
@@ -112,19 +108,93 @@ func SetupRoutes(app *fiber.App, handlers *Handlers, log logger.Logger) {
 	// Main page
 	app.Get("/", handlers.IndexPage)
 
-	// Swagger routes
-	app.Get("/swagger/*", fiberSwagger.WrapHandler)
+	// API docs, served from the binary
+	if handlers.cfg.SwaggerEnabled {
+		app.Get("/swagger/*", handlers.ServeSwaggerUI)
+		app.Get("/swagger.json", handlers.ServeSwaggerSpec)
+	}
 
-	// Swagger JSON endpoint
-	app.Get("/swagger.json", func(c *fiber.Ctx) error {
-		c.Set("Content-Type", "application/json")
-		return c.SendFile("./docs/swagger.json")
-	})
+	// Pre-/api/v1 paths of the JSON API
+	app.Get("/health", redirectToAPIV1)
+	app.Post("/upload", redirectToAPIV1)
+	app.Post("/media/:key/signed-url", redirectToAPIV1)
+	app.All("/admin/*", redirectToAPIV1)
+
+	app.Post("/upload/init-progress", handlers.InitUploadProgress)
+	app.Get("/upload/progress/:session_id", handlers.UploadProgress) // SSE, must stay uncompressed
+	if handlers.cfg.RequestDedup {
+		// Share responses between concurrent identical requests (never for downloads)
+		dedup := SingleFlight(dedupKey)
+		app.Get("/media/:key", dedup, handlers.ViewMedia)            // View page with preview
+		app.Get("/media/:key/preview", dedup, handlers.PreviewMedia) // Image preview (doesn't delete)
+	} else {
+		app.Get("/media/:key", handlers.ViewMedia)            // View page with preview
+		app.Get("/media/:key/preview", handlers.PreviewMedia) // Image preview (doesn't delete)
+	}
+	app.Get("/media/:key/download", handlers.DownloadMediaFile)        // Direct download
+	app.Post("/media/:key/presign-download", handlers.PresignDownload) // Encrypted blob straight from S3
+	app.Post("/media/bulk-status", RateLimitPerIP(handlers.rateLimiter, 10, time.Minute), handlers.BulkStatus)
+	app.Get("/my/uploads", RateLimitPerIP(handlers.rateLimiter, 1, time.Minute), handlers.MyUploads)
+	app.Delete("/my/uploads", RateLimitPerIP(handlers.rateLimiter, 1, time.Minute), handlers.DeleteMyUploads)
+
+	SetupAPIV1Routes(app, handlers)
+}
+
+// apiV1Prefix is the path of the version 1 JSON API
+const apiV1Prefix = "/api/v1"
 
-	// API routes
-	app.Get("/health", handlers.HealthCheck)
-	app.Post("/upload", handlers.UploadMedia)
-	app.Get("/media/:key", handlers.ViewMedia)                  // View page with preview
-	app.Get("/media/:key/preview", handlers.PreviewMedia)       // Image preview (doesn't delete)
-	app.Get("/media/:key/download", handlers.DownloadMediaFile) // Direct download
+// SetupAPIV1Routes registers the JSON API under /api/v1. Its responses carry
+// X-API-Version and default to application/json.
+func SetupAPIV1Routes(app *fiber.App, handlers *Handlers) {
+	v1 := app.Group(apiV1Prefix, APIVersion("1"))
+
+	v1.Get("/health", handlers.HealthCheck)
+
+	// Rejected uploads are limited first, before their bodies are read
+	var upload []fiber.Handler
+	if limit := handlers.cfg.UploadRateLimit; limit > 0 {
+		upload = append(upload, RateLimitPerIP(handlers.rateLimiter, limit, handlers.cfg.UploadRateWindow))
+	}
+	if maxSize := handlers.cfg.MaxUploadSize; maxSize > 0 {
+		// Streamed bodies skip Fiber's BodyLimit, so the size is checked here.
+		// Content-Length bounds the progress reader, the limit reader covers the rest.
+		upload = append(upload, RequireContentLength(maxSize))
+		if len(handlers.cfg.MIMELimits) > 0 {
+			upload = append(upload, FileSizeGuard(handlers.cfg.MIMELimits))
+		}
+		upload = append(upload, handlers.TrackUploadProgress, LimitRequestBodyWithoutHeader(maxSize), handlers.UploadMedia)
+	} else {
+		upload = append(upload, handlers.TrackUploadProgress, handlers.UploadMedia)
+	}
+	v1.Post("/upload", upload...)
+	v1.Get("/upload/schema", handlers.GetUploadForm)
+	v1.Get("/media/:key", handlers.ViewMediaJSON)
+	v1.Post("/media/bulk-check", RateLimitPerIP(handlers.rateLimiter, 10, time.Minute), handlers.BulkStatus)
+
+	// Management routes (require management token)
+	requireAdmin := RequireAdminToken(handlers.cfg.AdminToken)
+	v1.Post("/media/:key/signed-url", requireAdmin, handlers.CreateSignedURL)
+	v1.Post("/admin/reencrypt/:key", requireAdmin, handlers.ReencryptResource)
+	v1.Get("/admin/audit", requireAdmin, handlers.ListAudit)
+	v1.Post("/admin/cleanup", requireAdmin, handlers.AdminForceCleanup)
+	v1.Get("/admin/cleanup/last-run", requireAdmin, handlers.AdminLastCleanup)
+	v1.Post("/admin/keys/wrap", requireAdmin, handlers.WrapKey)
+	v1.Post("/admin/keys/unwrap", requireAdmin, handlers.UnwrapKey)
+	v1.Get("/admin/resources/:key/export", requireAdmin, handlers.ExportResource)
+	v1.Get("/admin/resources/:key/timeline", requireAdmin, handlers.ResourceTimeline)
+	v1.Get("/admin/storage", requireAdmin, handlers.StorageUsage)
+	v1.Get("/admin/migrations/status", requireAdmin, handlers.MigrationStatus)
+	v1.Post("/admin/migrations/run", requireAdmin, handlers.RunMigrations)
+	v1.Get("/system/encryption-bench", requireAdmin, handlers.EncryptionBenchmark)
+}
+
+// redirectToAPIV1 redirects a path the JSON API had before /api/v1 to its new
+// place, keeping the query. GET and HEAD get 301; other methods get 308, since
+// clients turn a POST redirected with 301 into a GET without body.
+func redirectToAPIV1(c *fiber.Ctx) error {
+	status := fiber.StatusMovedPermanently
+	if c.Method() != fiber.MethodGet && c.Method() != fiber.MethodHead {
+		status = fiber.StatusPermanentRedirect
+	}
+	return c.Redirect(apiV1Prefix+c.OriginalURL(), status)
 }
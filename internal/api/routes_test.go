@@ -0,0 +1,164 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"lovebin/modules/ratelimit"
+)
+
+// newRoutesApp returns an app with every route SetupRoutes registers for cfg
+func newRoutesApp(t *testing.T, cfg Config) *fiber.App {
+	t.Helper()
+	h := newTestHandlers(t, cfg)
+	app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler, DisableStartupMessage: true})
+	SetupRoutes(app, h.Handlers, newTestLogger(t))
+	return app
+}
+
+func TestRedirectToAPIV1(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		wantStatus   int
+		wantLocation string
+	}{
+		{"health", http.MethodGet, "/health", fiber.StatusMovedPermanently, "/api/v1/health"},
+		{"health HEAD", http.MethodHead, "/health", fiber.StatusMovedPermanently, "/api/v1/health"},
+		{"upload keeps the method", http.MethodPost, "/upload", fiber.StatusPermanentRedirect, "/api/v1/upload"},
+		{"signed url", http.MethodPost, "/media/abc/signed-url", fiber.StatusPermanentRedirect, "/api/v1/media/abc/signed-url"},
+		{"admin GET", http.MethodGet, "/admin/audit?limit=5", fiber.StatusMovedPermanently, "/api/v1/admin/audit?limit=5"},
+		{"admin POST", http.MethodPost, "/admin/cleanup?dry_run=true", fiber.StatusPermanentRedirect, "/api/v1/admin/cleanup?dry_run=true"},
+	}
+	app := newRoutesApp(t, Config{})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := app.Test(httptest.NewRequest(tt.method, tt.path, nil))
+			if err != nil {
+				t.Fatalf("request: %v", err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := resp.Header.Get(fiber.HeaderLocation); got != tt.wantLocation {
+				t.Errorf("Location = %q, want %q", got, tt.wantLocation)
+			}
+		})
+	}
+}
+
+func TestAPIV1Routes(t *testing.T) {
+	app := newRoutesApp(t, Config{})
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+	}{
+		{"health", http.MethodGet, "/api/v1/health", fiber.StatusOK},
+		{"upload schema", http.MethodGet, "/api/v1/upload/schema", fiber.StatusOK},
+		{"admin disabled", http.MethodGet, "/api/v1/admin/audit", fiber.StatusForbidden},
+		{"unknown media", http.MethodGet, "/api/v1/media/missing", fiber.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := app.Test(httptest.NewRequest(tt.method, tt.path, nil))
+			if err != nil {
+				t.Fatalf("request: %v", err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := resp.Header.Get(APIVersionHeader); got != "1" {
+				t.Errorf("%s = %q, want 1", APIVersionHeader, got)
+			}
+			if got := resp.Header.Get(fiber.HeaderContentType); got != fiber.MIMEApplicationJSON && got != fiber.MIMEApplicationJSONCharsetUTF8 {
+				t.Errorf("Content-Type = %q, want JSON", got)
+			}
+		})
+	}
+
+	// Pages and media routes stay on the main app
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/media/missing", nil))
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if resp.Header.Get(APIVersionHeader) != "" {
+		t.Error("a media page carries the API version")
+	}
+}
+
+func TestUploadRateLimit(t *testing.T) {
+	for _, algorithm := range []string{ratelimit.AlgorithmTokenBucket, ratelimit.AlgorithmSlidingWindow} {
+		t.Run(algorithm, func(t *testing.T) {
+			limiter, err := ratelimit.New(algorithm)
+			if err != nil {
+				t.Fatalf("ratelimit.New: %v", err)
+			}
+			const limit = 3
+			h := newTestHandlers(t, Config{UploadRateLimit: limit, UploadRateWindow: time.Minute, RateLimiter: limiter})
+			// The client IP is taken from X-Forwarded-For, as behind the production proxy
+			app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler, ProxyHeader: fiber.HeaderXForwardedFor, DisableStartupMessage: true})
+			SetupRoutes(app, h.Handlers, newTestLogger(t))
+
+			upload := func(ip string) *http.Response {
+				t.Helper()
+				req := uploadRequest(t, "notes.txt", "text/plain", "rate limited", nil)
+				req.URL.Path, req.RequestURI = "/api/v1/upload", "/api/v1/upload"
+				req.Header.Set(fiber.HeaderXForwardedFor, ip)
+				resp, err := app.Test(req)
+				if err != nil {
+					t.Fatalf("upload request: %v", err)
+				}
+				return resp
+			}
+
+			for i := range limit {
+				resp := upload("203.0.113.1")
+				if resp.StatusCode != fiber.StatusOK {
+					t.Fatalf("upload %d of %d status = %d, want 200", i+1, limit, resp.StatusCode)
+				}
+				if got, want := resp.Header.Get(RateLimitRemainingHeader), strconv.Itoa(limit-i-1); got != want {
+					t.Errorf("upload %d %s = %q, want %q", i+1, RateLimitRemainingHeader, got, want)
+				}
+			}
+			resp := upload("203.0.113.1")
+			if resp.StatusCode != fiber.StatusTooManyRequests {
+				t.Fatalf("upload over the limit status = %d, want 429", resp.StatusCode)
+			}
+			if retryAfter, err := strconv.Atoi(resp.Header.Get(fiber.HeaderRetryAfter)); err != nil || retryAfter < 1 || retryAfter > 60 {
+				t.Errorf("Retry-After = %q, want seconds until the window frees up", resp.Header.Get(fiber.HeaderRetryAfter))
+			}
+
+			// Every client IP has its own limit
+			if resp := upload("203.0.113.2"); resp.StatusCode != fiber.StatusOK {
+				t.Errorf("upload from another IP status = %d, want 200", resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestUploadRateLimitDisabled(t *testing.T) {
+	app := newRoutesApp(t, Config{})
+	for i := range 10 {
+		req := uploadRequest(t, "notes.txt", "text/plain", "not limited", nil)
+		req.URL.Path, req.RequestURI = "/api/v1/upload", "/api/v1/upload"
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("upload request: %v", err)
+		}
+		if resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("upload %d status = %d, want 200", i+1, resp.StatusCode)
+		}
+		if resp.Header.Get(RateLimitLimitHeader) != "" {
+			t.Fatalf("upload %d carries %s without a limit", i+1, RateLimitLimitHeader)
+		}
+	}
+}
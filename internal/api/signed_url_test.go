@@ -0,0 +1,192 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	accessrepo "lovebin/internal/services/access-service/repository"
+	"lovebin/modules/encryption"
+)
+
+const (
+	testSigningSecret = "signing-secret"
+	testSignedEncKey  = "c2VjcmV0LWtleQ" // encryption key the test URLs are signed with
+)
+
+// signedURLApp serves 204 at /r/:key when check accepts the request with the
+// enc_key query param, else the check's error
+func signedURLApp(check func(c *fiber.Ctx, resourceKey, encKeyBase64 string) error) *fiber.App {
+	app := fiber.New()
+	app.Get("/r/:key", func(c *fiber.Ctx) error {
+		if err := check(c, c.Params("key"), c.Query("enc_key")); err != nil {
+			return err
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+	return app
+}
+
+// signedQuery returns the query of a URL signed for testSignedEncKey, which it passes as enc_key
+func signedQuery(secret, resourceKey string, expiresAt int64) string {
+	return "?expires=" + strconv.FormatInt(expiresAt, 10) + "&sig=" + encryption.SignURL(secret, resourceKey, expiresAt, testSignedEncKey) + "&enc_key=" + testSignedEncKey
+}
+
+func TestVerifySignedURL(t *testing.T) {
+	future := time.Now().Add(time.Hour).Unix()
+	past := time.Now().Add(-time.Hour).Unix()
+
+	tests := []struct {
+		name     string
+		secret   string
+		required bool
+		query    string
+		want     int
+	}{
+		{"valid", testSigningSecret, true, signedQuery(testSigningSecret, "abc", future), fiber.StatusNoContent},
+		{"valid when optional", testSigningSecret, false, signedQuery(testSigningSecret, "abc", future), fiber.StatusNoContent},
+		{"expired", testSigningSecret, true, signedQuery(testSigningSecret, "abc", past), fiber.StatusGone},
+		{"signed for another resource", testSigningSecret, true, signedQuery(testSigningSecret, "abd", future), fiber.StatusForbidden},
+		{"signed with another secret", testSigningSecret, true, signedQuery("other", "abc", future), fiber.StatusForbidden},
+		{"expiry extended", testSigningSecret, true, "?expires=" + strconv.FormatInt(future+60, 10) + "&sig=" + encryption.SignURL(testSigningSecret, "abc", future, testSignedEncKey) + "&enc_key=" + testSignedEncKey, fiber.StatusForbidden},
+		{"other encryption key", testSigningSecret, true, strings.Replace(signedQuery(testSigningSecret, "abc", future), "enc_key="+testSignedEncKey, "enc_key=b3RoZXIta2V5", 1), fiber.StatusForbidden},
+		{"without encryption key", testSigningSecret, true, strings.Replace(signedQuery(testSigningSecret, "abc", future), "&enc_key="+testSignedEncKey, "", 1), fiber.StatusForbidden},
+		// A forged signature must not reveal whether the link expired
+		{"forged and expired", testSigningSecret, true, "?expires=" + strconv.FormatInt(past, 10) + "&sig=00", fiber.StatusForbidden},
+		{"bad expiry", testSigningSecret, true, "?expires=soon&sig=00", fiber.StatusForbidden},
+		{"missing when required", testSigningSecret, true, "", fiber.StatusForbidden},
+		{"missing when optional", testSigningSecret, false, "", fiber.StatusNoContent},
+		{"partial when optional", testSigningSecret, false, "?expires=" + strconv.FormatInt(future, 10), fiber.StatusForbidden},
+		{"no signing secret", "", true, signedQuery("", "abc", future), fiber.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handlers{cfg: Config{SigningSecret: tt.secret}}
+			app := signedURLApp(func(c *fiber.Ctx, resourceKey, encKeyBase64 string) error {
+				return h.verifySignedURL(c, resourceKey, encKeyBase64, tt.required)
+			})
+
+			resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/r/abc"+tt.query, nil))
+			if err != nil {
+				t.Fatalf("app.Test: %v", err)
+			}
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckSignedURL(t *testing.T) {
+	future := time.Now().Add(time.Hour).Unix()
+	resources := map[string]accessrepo.ResourceAccess{
+		"open":   {ResourceKey: "open"},
+		"signed": {ResourceKey: "signed", SignedURLRequired: true},
+	}
+
+	tests := []struct {
+		name    string
+		repoErr error
+		key     string
+		query   string
+		want    int
+	}{
+		{"open without signature", nil, "open", "", fiber.StatusNoContent},
+		{"signed without signature", nil, "signed", "", fiber.StatusForbidden},
+		{"signed with signature", nil, "signed", signedQuery(testSigningSecret, "signed", future), fiber.StatusNoContent},
+		// Left to the access checks that follow, which answer 404
+		{"not found", nil, "missing", "", fiber.StatusNoContent},
+		// Without the access info the signature cannot be skipped
+		{"database error without signature", errors.New("connection refused"), "open", "", fiber.StatusForbidden},
+		{"database error with signature", errors.New("connection refused"), "open", signedQuery(testSigningSecret, "open", future), fiber.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeAccessRepo{resources: resources, err: tt.repoErr}
+			h := &Handlers{
+				logger:        newTestLogger(t),
+				accessService: newTestAccessService(t, repo),
+				cfg:           Config{SigningSecret: testSigningSecret},
+			}
+			app := signedURLApp(h.checkSignedURL)
+
+			resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/r/"+tt.key+tt.query, nil))
+			if err != nil {
+				t.Fatalf("app.Test: %v", err)
+			}
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateSignedURL(t *testing.T) {
+	tests := []struct {
+		name           string
+		path           string
+		body           string
+		want           int
+		wantSignedOnly bool
+	}{
+		{"key in the path", "/media/abc%23" + testSignedEncKey + "/signed-url", `{}`, fiber.StatusOK, false},
+		{"key as query param", "/media/abc/signed-url?enc_key=" + testSignedEncKey, `{"ttl_seconds": 60}`, fiber.StatusOK, false},
+		{"signed only", "/media/abc%23" + testSignedEncKey + "/signed-url", `{"signed_only": true}`, fiber.StatusOK, true},
+		{"without encryption key", "/media/abc/signed-url", `{"signed_only": true}`, fiber.StatusBadRequest, false},
+		{"unknown resource", "/media/abd%23" + testSignedEncKey + "/signed-url", `{}`, fiber.StatusNotFound, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandlers(t, Config{SigningSecret: testSigningSecret, SignedURLTTL: time.Hour})
+			h.access.resources["abc"] = accessrepo.ResourceAccess{ResourceKey: "abc"}
+			app := fiber.New()
+			app.Post("/media/:key/signed-url", h.CreateSignedURL)
+
+			req := httptest.NewRequest(fiber.MethodPost, tt.path, strings.NewReader(tt.body))
+			req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("app.Test: %v", err)
+			}
+			if resp.StatusCode != tt.want {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+			// Signing must not make the resource signed-only unless asked to
+			if got := h.access.resources["abc"].SignedURLRequired; got != tt.wantSignedOnly {
+				t.Errorf("SignedURLRequired = %v, want %v", got, tt.wantSignedOnly)
+			}
+			if resp.StatusCode != fiber.StatusOK {
+				return
+			}
+
+			var body SignedURLResponse
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			signedURL, err := url.Parse(body.URL)
+			if err != nil {
+				t.Fatalf("parse %q: %v", body.URL, err)
+			}
+			if signedURL.Query().Has("enc_key") {
+				t.Errorf("URL %q carries the encryption key", body.URL)
+			}
+			expiresAt, err := strconv.ParseInt(signedURL.Query().Get("expires"), 10, 64)
+			if err != nil {
+				t.Fatalf("expires of %q: %v", body.URL, err)
+			}
+			if !encryption.VerifyURLSignature(testSigningSecret, "abc", expiresAt, testSignedEncKey, signedURL.Query().Get("sig")) {
+				t.Errorf("signature of %q does not verify with the encryption key", body.URL)
+			}
+			if encryption.VerifyURLSignature(testSigningSecret, "abc", expiresAt, "", signedURL.Query().Get("sig")) {
+				t.Errorf("signature of %q verifies without the encryption key", body.URL)
+			}
+		})
+	}
+}
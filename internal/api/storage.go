@@ -0,0 +1,68 @@
+package api
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	mediaservice "lovebin/internal/services/media-service"
+	"lovebin/modules/timeparser"
+)
+
+type StorageUsageResponse struct {
+	TotalBytes   int64                    `json:"total_bytes"`
+	TotalObjects int64                    `json:"total_objects"`
+	Bucket       string                   `json:"bucket"`
+	Prefix       string                   `json:"prefix"`
+	ComputedAt   timeparser.UniversalTime `json:"computed_at"`
+	// ByDay maps UTC days (YYYY-MM-DD) to the bytes of objects last modified on them,
+	// only returned when from or to is given
+	ByDay map[string]int64 `json:"by_day,omitempty"`
+}
+
+// StorageUsage reports how much space media objects take in the bucket
+// @Summary      Storage usage
+// @Description  Total size and number of media objects, from a bucket listing reused for STORAGE_USAGE_CACHE_TTL (default 5 minutes). With from and/or to, by_day breaks the bytes down per day of last modification in [from, to); the breakdown lists the bucket on every request. Requires management token.
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param        from  query     string  false  "Start of the per-day breakdown"
+// @Param        to    query     string  false  "End of the per-day breakdown (exclusive)"
+// @Success      200   {object}  StorageUsageResponse
+// @Failure      400   {object}  map[string]string
+// @Failure      401   {object}  map[string]string
+// @Failure      500   {object}  map[string]string
+// @Router       /api/v1/admin/storage [get]
+func (h *Handlers) StorageUsage(c *fiber.Ctx) error {
+	from, err := timeparser.ParseUniversalTime(c.Query("from"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid from time"})
+	}
+	to, err := timeparser.ParseUniversalTime(c.Query("to"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid to time"})
+	}
+
+	usage, err := h.mediaService.StorageUsage(c.Context())
+	if err != nil {
+		h.logger.ErrorCtx(c.Context(), "failed to compute storage usage", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to compute storage usage"})
+	}
+
+	resp := StorageUsageResponse{
+		TotalBytes:   usage.TotalBytes,
+		TotalObjects: usage.TotalObjects,
+		Bucket:       h.cfg.StorageBucket,
+		Prefix:       mediaservice.MediaPrefix,
+		ComputedAt:   timeparser.NewUniversalTime(usage.ComputedAt),
+	}
+
+	if !from.IsZero() || !to.IsZero() {
+		resp.ByDay, err = h.mediaService.StorageByDay(c.Context(), from.Time, to.Time)
+		if err != nil {
+			h.logger.ErrorCtx(c.Context(), "failed to compute storage by day", zap.Error(err))
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to compute storage usage"})
+		}
+	}
+
+	return c.JSON(resp)
+}
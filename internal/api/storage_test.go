@@ -0,0 +1,97 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	mediaservice "lovebin/internal/services/media-service"
+)
+
+func TestStorageUsage(t *testing.T) {
+	h := newTestHandlers(t, Config{StorageBucket: "media"})
+	app := fiber.New()
+	app.Get("/admin/storage", h.StorageUsage)
+	h.upload(t, "stored content", mediaservice.UploadRequest{})
+	today := time.Now().UTC().Format("2006-01-02")
+
+	tests := []struct {
+		name      string
+		query     string
+		wantByDay map[string]bool // days expected in by_day, nil when it is omitted
+	}{
+		{"totals only", "", nil},
+		{"from", "?from=" + time.Now().Add(-time.Hour).Format(time.RFC3339), map[string]bool{today: true}},
+		// An empty breakdown is omitted like a missing one
+		{"to in the past", "?to=2000-01-01T00:00:00Z", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/admin/storage"+tt.query, nil))
+			if err != nil {
+				t.Fatalf("storage request: %v", err)
+			}
+			if resp.StatusCode != fiber.StatusOK {
+				t.Fatalf("status = %d, want 200", resp.StatusCode)
+			}
+			var body struct {
+				StorageUsageResponse
+				ByDay *map[string]int64 `json:"by_day"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if body.TotalObjects != 1 || body.TotalBytes == 0 || body.Bucket != "media" || body.Prefix != mediaservice.MediaPrefix {
+				t.Errorf("usage = %+v, want one media object", body.StorageUsageResponse)
+			}
+			if (body.ByDay == nil) != (tt.wantByDay == nil) {
+				t.Fatalf("by_day = %v, want present %v", body.ByDay, tt.wantByDay != nil)
+			}
+			if body.ByDay != nil {
+				for day := range *body.ByDay {
+					if !tt.wantByDay[day] {
+						t.Errorf("by_day has unexpected day %s", day)
+					}
+				}
+				if len(*body.ByDay) != len(tt.wantByDay) {
+					t.Errorf("by_day = %v, want days %v", *body.ByDay, tt.wantByDay)
+				}
+			}
+		})
+	}
+}
+
+func TestStorageUsageErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		query       string
+		failListing bool
+		want        int
+	}{
+		{"invalid from", "?from=yesterday-ish", false, fiber.StatusBadRequest},
+		{"invalid to", "?to=soon", false, fiber.StatusBadRequest},
+		{"listing fails", "", true, fiber.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandlers(t, Config{})
+			if tt.failListing {
+				h.storage.SetError("GetStorageUsage", errors.New("listing failed"))
+			}
+			app := fiber.New()
+			app.Get("/admin/storage", h.StorageUsage)
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/admin/storage"+tt.query, nil))
+			if err != nil {
+				t.Fatalf("storage request: %v", err)
+			}
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"embed"
+	"encoding/json"
+	"path"
+
+	"github.com/gofiber/fiber/v2"
+
+	"lovebin/docs"
+)
+
+// swaggerUIDist is the Swagger UI distribution, see swagger-ui-dist/README.md
+//
+//go:embed swagger-ui-dist/*
+var swaggerUIDist embed.FS
+
+// Swagger UI is served under swaggerUIPrefix; the spec it loads lives next to it
+const (
+	swaggerUIPrefix = "/swagger/"
+	swaggerSpecFile = "doc.json"
+	swaggerInitFile = "swagger-initializer.js"
+)
+
+// swaggerUICSP allows only the embedded assets. Swagger UI inlines styles and SVG
+// images; its scripts are all files, including the generated initializer.
+const swaggerUICSP = "default-src 'self'; script-src 'self'; style-src 'self' 'unsafe-inline'; img-src 'self' data:; " +
+	"object-src 'none'; base-uri 'self'; frame-ancestors 'none'"
+
+// swaggerAssetMaxAge is the Cache-Control max-age of the Swagger UI bundles, like /static
+const swaggerAssetMaxAge = "public, max-age=86400"
+
+// swaggerInitializer starts Swagger UI with the embedded spec. The validator badge
+// is turned off, it would send the spec to validator.swagger.io.
+var swaggerInitializer = func() []byte {
+	specURL, _ := json.Marshal("./" + swaggerSpecFile)
+	return []byte(`window.onload = function() {
+  window.ui = SwaggerUIBundle({
+    url: ` + string(specURL) + `,
+    dom_id: '#swagger-ui',
+    deepLinking: true,
+    validatorUrl: null,
+    presets: [
+      SwaggerUIBundle.presets.apis,
+      SwaggerUIStandalonePreset
+    ],
+    plugins: [
+      SwaggerUIBundle.plugins.DownloadUrl
+    ],
+    layout: "StandaloneLayout"
+  });
+};
+`)
+}()
+
+// ServeSwaggerUI serves the embedded Swagger UI on /swagger/*, its initializer and
+// the OpenAPI spec generated into the docs package, without any external assets
+func (h *Handlers) ServeSwaggerUI(c *fiber.Ctx) error {
+	name := c.Params("*")
+	switch name {
+	case "":
+		// Relative asset URLs need the trailing path element
+		return c.Redirect(swaggerUIPrefix+"index.html", fiber.StatusMovedPermanently)
+	case swaggerSpecFile:
+		return h.ServeSwaggerSpec(c)
+	case swaggerInitFile:
+		c.Set(fiber.HeaderContentSecurityPolicy, swaggerUICSP)
+		c.Type("js")
+		return c.Send(swaggerInitializer)
+	}
+
+	data, err := swaggerUIDist.ReadFile(path.Join("swagger-ui-dist", path.Clean("/"+name)))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).SendString("Not found")
+	}
+	c.Set(fiber.HeaderContentSecurityPolicy, swaggerUICSP)
+	if name != "index.html" {
+		c.Set(fiber.HeaderCacheControl, swaggerAssetMaxAge)
+	}
+	c.Type(path.Ext(name))
+	return c.Send(data)
+}
+
+// ServeSwaggerSpec serves the OpenAPI spec embedded in the docs package
+func (h *Handlers) ServeSwaggerSpec(c *fiber.Ctx) error {
+	c.Type("json")
+	return c.SendString(docs.SwaggerInfo.ReadDoc())
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestServeSwaggerUI(t *testing.T) {
+	app := newRoutesApp(t, Config{SwaggerEnabled: true})
+
+	tests := []struct {
+		name            string
+		path            string
+		wantStatus      int
+		wantType        string // prefix of the Content-Type
+		wantBody        string // part of the body
+		wantCached      bool
+		wantPolicy      bool
+		wantRedirection string
+	}{
+		{"index", "/swagger/index.html", fiber.StatusOK, "text/html", "swagger-initializer.js", false, true, ""},
+		{"no trailing element", "/swagger/", fiber.StatusMovedPermanently, "", "", false, false, "/swagger/index.html"},
+		{"css", "/swagger/swagger-ui.css", fiber.StatusOK, "text/css", "", true, true, ""},
+		{"bundle", "/swagger/swagger-ui-bundle.js", fiber.StatusOK, "text/javascript", "", true, true, ""},
+		{"preset", "/swagger/swagger-ui-standalone-preset.js", fiber.StatusOK, "text/javascript", "", true, true, ""},
+		{"favicon", "/swagger/favicon-32x32.png", fiber.StatusOK, "image/png", "", true, true, ""},
+		{"initializer", "/swagger/swagger-initializer.js", fiber.StatusOK, "text/javascript", `url: "./doc.json"`, false, true, ""},
+		{"spec", "/swagger/doc.json", fiber.StatusOK, "application/json", `"swagger"`, false, false, ""},
+		{"root spec", "/swagger.json", fiber.StatusOK, "application/json", `"swagger"`, false, false, ""},
+		{"unknown file", "/swagger/missing.js", fiber.StatusNotFound, "", "", false, false, ""},
+		{"parent directory", "/swagger/..%2fswagger.go", fiber.StatusNotFound, "", "", false, false, ""},
+		{"outside the dist", "/swagger/../api/swagger.go", fiber.StatusNotFound, "", "", false, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if err != nil {
+				t.Fatalf("request: %v", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := resp.Header.Get(fiber.HeaderLocation); got != tt.wantRedirection {
+				t.Errorf("Location = %q, want %q", got, tt.wantRedirection)
+			}
+			if got := resp.Header.Get(fiber.HeaderContentType); !strings.HasPrefix(got, tt.wantType) {
+				t.Errorf("Content-Type = %q, want %s", got, tt.wantType)
+			}
+			if cached := resp.Header.Get(fiber.HeaderCacheControl) == swaggerAssetMaxAge; cached != tt.wantCached {
+				t.Errorf("Cache-Control = %q, want cached %v", resp.Header.Get(fiber.HeaderCacheControl), tt.wantCached)
+			}
+			if policy := resp.Header.Get(fiber.HeaderContentSecurityPolicy) == swaggerUICSP; policy != tt.wantPolicy {
+				t.Errorf("Content-Security-Policy = %q, want the Swagger UI policy %v", resp.Header.Get(fiber.HeaderContentSecurityPolicy), tt.wantPolicy)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			if !strings.Contains(string(body), tt.wantBody) {
+				t.Errorf("body does not contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestServeSwaggerUILoadsNothingExternal(t *testing.T) {
+	app := newRoutesApp(t, Config{SwaggerEnabled: true})
+	for _, path := range []string{"/swagger/index.html", "/swagger/swagger-initializer.js"} {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, path, nil))
+		if err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if strings.Contains(string(body), "http://") || strings.Contains(string(body), "https://") {
+			t.Errorf("%s refers to another host:\n%s", path, body)
+		}
+	}
+	if !strings.Contains(string(swaggerInitializer), "validatorUrl: null") {
+		t.Error("the initializer does not turn off the validator badge")
+	}
+}
+
+func TestServeSwaggerSpec(t *testing.T) {
+	app := newRoutesApp(t, Config{SwaggerEnabled: true})
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/swagger.json", nil))
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var spec struct {
+		Paths map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&spec); err != nil {
+		t.Fatalf("spec is not JSON: %v", err)
+	}
+	if len(spec.Paths) == 0 {
+		t.Error("spec has no paths")
+	}
+}
+
+func TestSwaggerDisabled(t *testing.T) {
+	app := newRoutesApp(t, Config{})
+	for _, path := range []string{"/swagger/index.html", "/swagger/doc.json", "/swagger.json"} {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, path, nil))
+		if err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != fiber.StatusNotFound {
+			t.Errorf("GET %s = %d with Swagger disabled, want 404", path, resp.StatusCode)
+		}
+	}
+}
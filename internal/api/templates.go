@@ -0,0 +1,75 @@
+package api
+
+import (
+	"fmt"
+	"html/template"
+	"path/filepath"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"lovebin/modules/logger"
+)
+
+// TemplateResolver locates the frontend directory and its HTML templates. In
+// production the directory is resolved once and the templates are parsed up
+// front. In dev mode both happen again on every render, so edited or remounted
+// templates show up without a restart.
+type TemplateResolver struct {
+	log     logger.Logger
+	devMode bool
+
+	mu  sync.Mutex
+	dir string // last resolved frontend directory
+
+	templates *template.Template // preloaded templates, nil in dev mode or when preloading failed
+}
+
+// NewTemplateResolver resolves the frontend directory and, outside of dev mode,
+// preloads its templates. When preloading fails the templates are parsed on each render.
+func NewTemplateResolver(log logger.Logger, devMode bool) *TemplateResolver {
+	r := &TemplateResolver{
+		log:     log,
+		devMode: devMode,
+		dir:     findFrontendDir(),
+	}
+	if devMode {
+		return r
+	}
+
+	templates, err := template.New("").Funcs(templateFuncs).ParseGlob(filepath.Join(r.dir, "*.html"))
+	if err != nil {
+		log.Error("failed to preload templates", zap.Error(err), zap.String("frontend_dir", r.dir))
+		return r
+	}
+	r.templates = templates
+	return r
+}
+
+// Dir returns the frontend directory. In dev mode it is looked up again and a
+// warning is logged when it no longer is the one found before.
+func (r *TemplateResolver) Dir() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.devMode {
+		return r.dir
+	}
+
+	dir := findFrontendDir()
+	if dir != r.dir {
+		r.log.Warn("frontend directory changed", zap.String("previous", r.dir), zap.String("frontend_dir", dir))
+		r.dir = dir
+	}
+	return dir
+}
+
+// Lookup returns the template of the file name in the frontend directory
+func (r *TemplateResolver) Lookup(name string) (*template.Template, error) {
+	if r.templates != nil {
+		if tmpl := r.templates.Lookup(name); tmpl != nil {
+			return tmpl, nil
+		}
+		return nil, fmt.Errorf("template %s not found in %s", name, r.Dir())
+	}
+	return parseTemplate(filepath.Join(r.Dir(), name))
+}
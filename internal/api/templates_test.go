@@ -0,0 +1,132 @@
+package api
+
+import (
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"lovebin/modules/timeparser"
+)
+
+// frontendDir is the frontend directory seen from this package
+const frontendDir = "../../frontend"
+
+func TestTemplatesParse(t *testing.T) {
+	if _, err := template.New("").Funcs(templateFuncs).ParseGlob(filepath.Join(frontendDir, "*.html")); err != nil {
+		t.Fatalf("parse frontend templates: %v", err)
+	}
+}
+
+func TestResultTemplateRelativeExpiry(t *testing.T) {
+	tmpl, err := parseTemplate(filepath.Join(frontendDir, "result.html"))
+	if err != nil {
+		t.Fatalf("parseTemplate: %v", err)
+	}
+
+	var buf strings.Builder
+	err = tmpl.Execute(&buf, resultData{
+		Success:   true,
+		URL:       "https://lovebin.example/media/key#enc",
+		ExpiresIn: timeparser.NewUniversalTime(time.Now().Add(3*time.Hour + 30*time.Second)),
+	})
+	if err != nil {
+		t.Fatalf("execute result.html: %v", err)
+	}
+	if !strings.Contains(buf.String(), ">3 hours from now</span>") {
+		t.Errorf("result.html does not show the relative expiry:\n%s", buf.String())
+	}
+}
+
+// writeTemplate writes a template file into dir
+func writeTemplate(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// renderTemplate looks up name and executes it without data
+func renderTemplate(t *testing.T, r *TemplateResolver, name string) string {
+	t.Helper()
+	tmpl, err := r.Lookup(name)
+	if err != nil {
+		t.Fatalf("Lookup(%s): %v", name, err)
+	}
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, nil); err != nil {
+		t.Fatalf("execute %s: %v", name, err)
+	}
+	return buf.String()
+}
+
+func TestTemplateResolver(t *testing.T) {
+	tests := []struct {
+		name    string
+		devMode bool
+		want    string // page after the template was edited
+	}{
+		{"production keeps the preloaded template", false, "first"},
+		{"dev mode reloads the template", true, "second"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			dir := filepath.Join(root, "frontend")
+			if err := os.Mkdir(dir, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			writeTemplate(t, dir, "page.html", "first")
+			t.Chdir(root)
+
+			r := NewTemplateResolver(newTestLogger(t), tt.devMode)
+			if got, _ := filepath.EvalSymlinks(r.Dir()); got != mustEvalSymlinks(t, dir) {
+				t.Errorf("Dir() = %s, want %s", got, dir)
+			}
+			if got := renderTemplate(t, r, "page.html"); got != "first" {
+				t.Errorf("page = %q, want first", got)
+			}
+
+			writeTemplate(t, dir, "page.html", "second")
+			if got := renderTemplate(t, r, "page.html"); got != tt.want {
+				t.Errorf("edited page = %q, want %q", got, tt.want)
+			}
+
+			if _, err := r.Lookup("missing.html"); err == nil {
+				t.Error("Lookup of a missing template succeeded")
+			}
+		})
+	}
+}
+
+func TestTemplateResolverPreloadFailure(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "frontend")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeTemplate(t, dir, "broken.html", "{{ .Unclosed")
+	writeTemplate(t, dir, "page.html", "first")
+	t.Chdir(root)
+
+	// A broken template does not stop the others from being parsed on each render
+	r := NewTemplateResolver(newTestLogger(t), false)
+	writeTemplate(t, dir, "page.html", "second")
+	if got := renderTemplate(t, r, "page.html"); got != "second" {
+		t.Errorf("page = %q, want it parsed on render", got)
+	}
+	if _, err := r.Lookup("broken.html"); err == nil {
+		t.Error("Lookup of a broken template succeeded")
+	}
+}
+
+func mustEvalSymlinks(t *testing.T, path string) string {
+	t.Helper()
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resolved
+}
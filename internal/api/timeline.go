@@ -0,0 +1,71 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	mediaservice "lovebin/internal/services/media-service"
+	"lovebin/modules/timeparser"
+)
+
+type TimelineEventJSON struct {
+	EventType string                   `json:"event_type"`
+	At        timeparser.UniversalTime `json:"at"`
+	Details   map[string]string        `json:"details"`
+}
+
+type ResourceTimelineResponse struct {
+	ResourceKey string              `json:"resource_key"`
+	Events      []TimelineEventJSON `json:"events"`
+}
+
+// ResourceTimeline returns the history of a resource
+// @Summary      Resource timeline
+// @Description  Events of a resource, oldest first: audit trail entries (upload, download, reencrypt, cleanup, ...), used access codes (access_code_used), expiry notifications (expiry_notified) and, while the record exists, its creation, availability and expiry (created, available, expired). Deleted resources keep their recorded events. Requires management token.
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param        key   path      string  true   "Resource key"
+// @Param        from  query     string  false  "Only events at or after this time"
+// @Param        to    query     string  false  "Only events before this time"
+// @Success      200   {object}  ResourceTimelineResponse
+// @Failure      400   {object}  map[string]string
+// @Failure      401   {object}  map[string]string
+// @Failure      404   {object}  map[string]string
+// @Failure      500   {object}  map[string]string
+// @Router       /api/v1/admin/resources/{key}/timeline [get]
+func (h *Handlers) ResourceTimeline(c *fiber.Ctx) error {
+	from, err := timeparser.ParseUniversalTime(c.Query("from"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid from time"})
+	}
+	to, err := timeparser.ParseUniversalTime(c.Query("to"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid to time"})
+	}
+
+	resourceKey := c.Params("key")
+	events, err := h.mediaService.GetResourceTimeline(c.Context(), resourceKey, from.Time, to.Time)
+	if errors.Is(err, mediaservice.ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "resource not found"})
+	}
+	if err != nil {
+		h.logger.ErrorCtx(c.Context(), "failed to load resource timeline", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to load resource timeline"})
+	}
+
+	resp := ResourceTimelineResponse{
+		ResourceKey: resourceKey,
+		Events:      make([]TimelineEventJSON, 0, len(events)),
+	}
+	for _, event := range events {
+		resp.Events = append(resp.Events, TimelineEventJSON{
+			EventType: event.EventType,
+			At:        event.At,
+			Details:   event.Details,
+		})
+	}
+	return c.JSON(resp)
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	mediaservice "lovebin/internal/services/media-service"
+)
+
+func TestResourceTimeline(t *testing.T) {
+	h := newTestHandlers(t, Config{})
+	app := fiber.New()
+	app.Get("/resources/:key/timeline", h.ResourceTimeline)
+	resourceKey, _ := h.upload(t, "timeline", mediaservice.UploadRequest{})
+
+	tests := []struct {
+		name       string
+		key        string
+		query      url.Values
+		wantStatus int
+		wantEvents []string
+	}{
+		{"resource", resourceKey, nil, fiber.StatusOK, []string{mediaservice.TimelineCreated}},
+		{"range before the upload", resourceKey, url.Values{"to": {"2020-01-01T00:00:00Z"}}, fiber.StatusOK, []string{}},
+		{"duration from now", resourceKey, url.Values{"from": {"PT1H"}}, fiber.StatusOK, []string{}},
+		{"unknown resource", "unknown", nil, fiber.StatusNotFound, nil},
+		{"invalid from", resourceKey, url.Values{"from": {"yesterday"}}, fiber.StatusBadRequest, nil},
+		{"invalid to", resourceKey, url.Values{"to": {"P"}}, fiber.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := "/resources/" + tt.key + "/timeline"
+			if tt.query != nil {
+				target += "?" + tt.query.Encode()
+			}
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, target, nil))
+			if err != nil {
+				t.Fatalf("request: %v", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantEvents == nil {
+				return
+			}
+
+			var timeline ResourceTimelineResponse
+			if err := json.NewDecoder(resp.Body).Decode(&timeline); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if timeline.ResourceKey != tt.key || timeline.Events == nil {
+				t.Errorf("timeline = %+v, want the events of %s as a list", timeline, tt.key)
+			}
+			if len(timeline.Events) != len(tt.wantEvents) {
+				t.Fatalf("events = %+v, want %v", timeline.Events, tt.wantEvents)
+			}
+			for i, event := range timeline.Events {
+				if event.EventType != tt.wantEvents[i] || event.At.IsZero() {
+					t.Errorf("event %d = %+v, want %s", i, event, tt.wantEvents[i])
+				}
+			}
+		})
+	}
+}
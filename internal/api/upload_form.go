@@ -0,0 +1,124 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// errFieldsAfterFile fails uploads with form fields after the file: the file is
+// stored as it is read, so they would come too late to apply to it
+var errFieldsAfterFile = errors.New("form fields must come before the file")
+
+// errUploadFormTooLarge fails uploads whose fields take more than uploadFormAllowance
+var errUploadFormTooLarge = errors.New("upload form fields too large")
+
+// fileTooLargeError fails reads of a file past the SERVER_MIME_LIMITS entry of its type
+type fileTooLargeError struct {
+	pattern string
+	limit   int64
+}
+
+func (e *fileTooLargeError) Error() string {
+	return fileTooLargeMessage(e.pattern, e.limit) + ", the file is larger"
+}
+
+// multipartUpload is an upload form read up to its file, which is left in the body
+// to be stored as it arrives
+type multipartUpload struct {
+	values map[string]string     // first value of every field
+	file   *multipart.FileHeader // the "file" part, nil when there is none
+	data   io.Reader             // contents of file, read from the request body
+	done   bool                  // the body was read to its end
+}
+
+// value returns the first value of the field name, empty when the form has none
+func (f *multipartUpload) value(name string) string {
+	return f.values[name]
+}
+
+// readUploadForm reads the multipart form of an upload up to the start of its
+// "file" part. The fields must come first and take at most uploadFormAllowance;
+// reading the file fails with errFieldsAfterFile when any part follows it.
+// The Size of the file is 0 when it is empty and -1 otherwise, as the rest of
+// it has not arrived yet. Requests that are not multipart have no fields and no file.
+func readUploadForm(c *fiber.Ctx) (*multipartUpload, error) {
+	form := &multipartUpload{values: make(map[string]string)}
+	mediaType, params, err := mime.ParseMediaType(c.Get(fiber.HeaderContentType))
+	if err != nil || mediaType != fiber.MIMEMultipartForm || params["boundary"] == "" {
+		return form, nil
+	}
+
+	body := requestBodyStream(c)
+	if body == nil {
+		body = bytes.NewReader(c.Body())
+	}
+	reader := multipart.NewReader(body, params["boundary"])
+	allowance := int64(uploadFormAllowance)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			form.done = true
+			return form, nil
+		}
+		if err != nil {
+			return form, err
+		}
+
+		if part.FormName() == "file" && part.FileName() != "" {
+			data := bufio.NewReader(&uploadFileReader{part: part, reader: reader, form: form})
+			size := int64(-1)
+			if _, err := data.Peek(1); err == io.EOF {
+				size = 0
+			} else if err != nil {
+				return form, err
+			}
+			form.file = &multipart.FileHeader{Filename: part.FileName(), Header: part.Header, Size: size}
+			form.data = data
+			return form, nil
+		}
+
+		// Other parts, files included, count towards the allowance of the fields
+		value, err := io.ReadAll(io.LimitReader(part, allowance+1))
+		if err != nil {
+			return form, err
+		}
+		if allowance -= int64(len(value)); allowance < 0 {
+			return form, errUploadFormTooLarge
+		}
+		if _, ok := form.values[part.FormName()]; !ok && part.FileName() == "" {
+			form.values[part.FormName()] = string(value)
+		}
+	}
+}
+
+// uploadFileReader reads the file part of an upload form, which must be its last part
+type uploadFileReader struct {
+	part   *multipart.Part
+	reader *multipart.Reader
+	form   *multipartUpload
+}
+
+func (r *uploadFileReader) Read(p []byte) (int, error) {
+	if r.form.done {
+		return 0, io.EOF
+	}
+	n, err := r.part.Read(p)
+	if err != io.EOF {
+		return n, err
+	}
+	switch _, err := r.reader.NextPart(); err {
+	case io.EOF:
+		r.form.done = true
+		return n, io.EOF
+	case nil:
+		return n, errFieldsAfterFile
+	default:
+		return n, err
+	}
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestReadUploadForm(t *testing.T) {
+	type field struct{ name, value string }
+	tests := []struct {
+		name       string
+		fields     []field
+		noFile     bool
+		file       string
+		wantErr    error
+		wantFields map[string]string
+		wantSize   int64
+	}{
+		{"fields then file", []field{{"password", "one"}, {"password", "two"}, {"max_views", "3"}}, false, "content", nil,
+			map[string]string{"password": "one", "max_views": "3"}, -1},
+		{"empty file", nil, false, "", nil, map[string]string{}, 0},
+		{"no file", []field{{"password", "one"}}, true, "", nil, map[string]string{"password": "one"}, 0},
+		{"fields too large", []field{{"access_codes", strings.Repeat("a", uploadFormAllowance+1)}}, false, "content", errUploadFormTooLarge, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body bytes.Buffer
+			w := multipart.NewWriter(&body)
+			for _, f := range tt.fields {
+				_ = w.WriteField(f.name, f.value)
+			}
+			if !tt.noFile {
+				part, _ := w.CreateFormFile("file", "notes.txt")
+				_, _ = part.Write([]byte(tt.file))
+			}
+			_ = w.Close()
+
+			app := fiber.New(fiber.Config{StreamRequestBody: true, DisablePreParseMultipartForm: true})
+			app.Post("/upload", func(c *fiber.Ctx) error {
+				form, err := readUploadForm(c)
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("readUploadForm error = %v, want %v", err, tt.wantErr)
+				}
+				if err != nil {
+					return nil
+				}
+				for name, want := range tt.wantFields {
+					if got := form.value(name); got != want {
+						t.Errorf("%s = %q, want %q", name, got, want)
+					}
+				}
+				if tt.noFile {
+					if form.file != nil || !form.done {
+						t.Errorf("form without a file: file %v, read to the end %v", form.file, form.done)
+					}
+					return nil
+				}
+				if form.file == nil || form.file.Filename != "notes.txt" || form.file.Size != tt.wantSize {
+					t.Fatalf("file = %+v, want notes.txt of size %d", form.file, tt.wantSize)
+				}
+				content, err := io.ReadAll(form.data)
+				if err != nil || string(content) != tt.file || !form.done {
+					t.Errorf("file content = %q, %v, read to the end %v", content, err, form.done)
+				}
+				return nil
+			})
+			req := httptest.NewRequest(http.MethodPost, "/upload", &body)
+			req.Header.Set(fiber.HeaderContentType, w.FormDataContentType())
+			if _, err := app.Test(req); err != nil {
+				t.Fatalf("request: %v", err)
+			}
+		})
+	}
+}
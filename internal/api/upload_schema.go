@@ -0,0 +1,43 @@
+package api
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"lovebin/modules/schema"
+)
+
+// uploadForm describes the fields of POST /api/v1/upload for GetUploadForm.
+// The rules mirror validateUploadForm and mediaservice limits, keep them in sync.
+type uploadForm struct {
+	File             string  `json:"file" validate:"required,format=binary" desc:"Media file to upload, must not be empty. Sent after the other fields, as it is stored while it arrives"`
+	Password         string  `json:"password" validate:"min=8,max=72" desc:"Optional password for access protection, 8 to 72 bytes without null bytes"`
+	ExpiresIn        string  `json:"expires_in" desc:"Expiration time: duration (1h, 24h, 7d, 2w) or absolute (RFC3339, ISO8601, Unix timestamp), must be in the future. Defaults to 24h"`
+	AvailableAt      string  `json:"available_at" desc:"Scheduled reveal: the file cannot be opened before this time, same formats as expires_in and before it"`
+	BlurIntensity    float64 `json:"blur_intensity" validate:"min=0,max=1" desc:"Blur strength of the image preview, from 0 (no blur, default) to 1 (maximum blur)"`
+	DownloadOnly     string  `json:"download_only" validate:"oneof=true false" desc:"Skip the view page and redirect straight to download"`
+	AllowedCountries string  `json:"allowed_countries" desc:"Comma separated ISO 3166-1 alpha-2 country codes allowed to open the file, only when the server has GeoIP"`
+	AccessCodes      string  `json:"access_codes" desc:"Up to 100 single-use access codes of at most 72 bytes, one per line or comma separated; cannot be combined with password"`
+	MaxViews         int     `json:"max_views" validate:"min=1,max=100" desc:"Number of downloads allowed, defaults to 1; with access_codes every code is one download and max_views must be 1 or empty"`
+	SessionID        string  `json:"session_id" desc:"Optional progress session ID from /upload/init-progress"`
+}
+
+// schemaGenerator caches generated schemas, the upload form is only built once
+var schemaGenerator = schema.NewGenerator()
+
+// GetUploadForm describes the upload form fields as JSON Schema
+// @Summary      Upload form schema
+// @Description  JSON Schema (draft 2020-12) of the multipart fields accepted by POST /api/v1/upload, with their validation rules. The file must be the last part. Form values are sent as strings, numbers and booleans in their text form.
+// @Tags         media
+// @Produce      json
+// @Success      200  {object}  schema.Schema
+// @Failure      500  {object}  map[string]string
+// @Router       /api/v1/upload/schema [get]
+func (h *Handlers) GetUploadForm(c *fiber.Ctx) error {
+	s, err := schemaGenerator.Generate(uploadForm{})
+	if err != nil {
+		h.logger.ErrorCtx(c.Context(), "failed to generate upload form schema", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to generate schema"})
+	}
+	return c.JSON(s)
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"lovebin/modules/schema"
+)
+
+func TestGetUploadForm(t *testing.T) {
+	h := newTestHandlers(t, Config{})
+	app := fiber.New()
+	app.Get("/api/v1/upload/schema", h.GetUploadForm)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/api/v1/upload/schema", nil))
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	var got schema.Schema
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	if got.SchemaURI != schema.Draft || !reflect.DeepEqual(got.Required, []string{"file"}) {
+		t.Errorf("schema %s requires %v, want only file", got.SchemaURI, got.Required)
+	}
+	if file := got.Properties["file"]; file == nil || file.Format != "binary" {
+		t.Errorf("file = %+v, want a binary string", file)
+	}
+	if password := got.Properties["password"]; password == nil || *password.MinLength != 8 || *password.MaxLength != 72 {
+		t.Errorf("password = %+v, want 8 to 72 bytes", password)
+	}
+	if blur := got.Properties["blur_intensity"]; blur == nil || *blur.Minimum != 0 || *blur.Maximum != 1 {
+		t.Errorf("blur_intensity = %+v, want 0 to 1", blur)
+	}
+	// Every field of the form is described, and only those
+	for _, field := range []string{"file", "password", "expires_in", "available_at", "blur_intensity",
+		"download_only", "allowed_countries", "access_codes", "max_views", "session_id"} {
+		if _, ok := got.Properties[field]; !ok {
+			t.Errorf("field %s is not described", field)
+		}
+	}
+	if len(got.Properties) != 10 {
+		t.Errorf("%d fields described, want 10", len(got.Properties))
+	}
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"lovebin/modules/timeparser"
+)
+
+type RecentUploadResponse struct {
+	ResourceKey string                   `json:"resource_key"`
+	Filename    string                   `json:"filename"`
+	CreatedAt   timeparser.UniversalTime `json:"created_at"`
+	ExpiresAt   timeparser.UniversalTime `json:"expires_at"`
+	Viewed      bool                     `json:"viewed"`
+}
+
+type RecentUploadsResponse struct {
+	Uploads []RecentUploadResponse `json:"uploads"`
+}
+
+type DeleteUploadsResponse struct {
+	Deleted int `json:"deleted"`
+}
+
+// MyUploads lists the active uploads made from the caller's IP
+// @Summary      List my uploads
+// @Description  List active uploads made from the caller's IP address, newest first. Only the resource keys are returned, links still need the encryption key.
+// @Tags         media
+// @Produce      json
+// @Param        limit  query     int  false  "Maximum number of uploads (default and max 50)"
+// @Success      200    {object}  RecentUploadsResponse
+// @Failure      429    {object}  map[string]string
+// @Failure      500    {object}  map[string]string
+// @Router       /my/uploads [get]
+func (h *Handlers) MyUploads(c *fiber.Ctx) error {
+	uploads, err := h.mediaService.GetRecentUploads(c.Context(), c.IP(), c.QueryInt("limit", 0))
+	if err != nil {
+		h.logger.ErrorCtx(c.Context(), "failed to list recent uploads", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to list uploads"})
+	}
+
+	resp := RecentUploadsResponse{Uploads: make([]RecentUploadResponse, 0, len(uploads))}
+	for _, upload := range uploads {
+		resp.Uploads = append(resp.Uploads, RecentUploadResponse{
+			ResourceKey: upload.ResourceKey,
+			Filename:    joinFilename(upload.Filename, upload.FileExtension),
+			CreatedAt:   upload.CreatedAt,
+			ExpiresAt:   upload.ExpiresAt,
+			Viewed:      upload.Viewed,
+		})
+	}
+	return c.JSON(resp)
+}
+
+// joinFilename builds the display filename from the stored name and extension
+func joinFilename(name, ext *string) string {
+	filename := "file"
+	if name != nil {
+		filename = *name
+		if ext != nil {
+			filename += "." + *ext
+		}
+	} else if ext != nil {
+		filename = "file." + *ext
+	}
+	return filename
+}
+
+// DeleteMyUploads deletes every upload made from the caller's IP
+// @Summary      Delete my uploads
+// @Description  Permanently delete all uploads made from the caller's IP address, including already viewed ones.
+// @Tags         media
+// @Produce      json
+// @Success      200  {object}  DeleteUploadsResponse
+// @Failure      429  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /my/uploads [delete]
+func (h *Handlers) DeleteMyUploads(c *fiber.Ctx) error {
+	deleted, err := h.mediaService.DeleteUploadsByIP(c.Context(), c.IP())
+	if err != nil {
+		h.logger.ErrorCtx(c.Context(), "failed to delete uploads", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to delete uploads"})
+	}
+	return c.JSON(DeleteUploadsResponse{Deleted: deleted})
+}
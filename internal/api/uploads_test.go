@@ -0,0 +1,355 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	mediaservice "lovebin/internal/services/media-service"
+	"lovebin/modules/cache"
+	"lovebin/modules/encryption"
+	"lovebin/modules/s3"
+)
+
+func TestJoinFilename(t *testing.T) {
+	name, ext := "photo", "png"
+	tests := []struct {
+		name      string
+		filename  *string
+		extension *string
+		want      string
+	}{
+		{"name and extension", &name, &ext, "photo.png"},
+		{"name only", &name, nil, "photo"},
+		{"extension only", nil, &ext, "file.png"},
+		{"neither", nil, nil, "file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := joinFilename(tt.filename, tt.extension); got != tt.want {
+				t.Errorf("joinFilename = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMyUploads(t *testing.T) {
+	h := newTestHandlers(t, Config{})
+	app := fiber.New()
+	app.Get("/my/uploads", h.MyUploads)
+	app.Delete("/my/uploads", h.DeleteMyUploads)
+
+	// app.Test requests come from 0.0.0.0
+	mine, _ := h.upload(t, "content", mediaservice.UploadRequest{Filename: "notes.txt", UploadIP: "0.0.0.0"})
+	h.upload(t, "content", mediaservice.UploadRequest{UploadIP: "203.0.113.7"})
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/my/uploads", nil))
+	if err != nil {
+		t.Fatalf("GET /my/uploads: %v", err)
+	}
+	var list RecentUploadsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusOK || len(list.Uploads) != 1 || list.Uploads[0].ResourceKey != mine {
+		t.Fatalf("GET /my/uploads = %d %+v, want only %s", resp.StatusCode, list, mine)
+	}
+	if upload := list.Uploads[0]; upload.Viewed || upload.CreatedAt.IsZero() {
+		t.Errorf("upload = %+v", upload)
+	}
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodDelete, "/my/uploads", nil))
+	if err != nil {
+		t.Fatalf("DELETE /my/uploads: %v", err)
+	}
+	var deleted DeleteUploadsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&deleted); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusOK || deleted.Deleted != 1 {
+		t.Errorf("DELETE /my/uploads = %d %+v, want 1 deleted", resp.StatusCode, deleted)
+	}
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/my/uploads", nil))
+	if err != nil {
+		t.Fatalf("GET /my/uploads: %v", err)
+	}
+	list = RecentUploadsResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	// An empty history is a list, not null
+	if list.Uploads == nil || len(list.Uploads) != 0 {
+		t.Errorf("uploads after delete = %#v, want an empty list", list.Uploads)
+	}
+}
+
+// blockingS3 holds stream uploads until release is closed, reporting each on started
+type blockingS3 struct {
+	*s3.MockS3
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingS3) UploadStream(ctx context.Context, bucket, key string, body io.Reader, size int64, contentType string, progress s3.ProgressFunc) (string, error) {
+	b.started <- struct{}{}
+	<-b.release
+	return b.MockS3.UploadStream(ctx, bucket, key, body, size, contentType, progress)
+}
+
+func TestUploadMediaConcurrencyLimit(t *testing.T) {
+	const limit = 2
+	h := newTestHandlers(t, Config{MaxConcurrentUploads: limit})
+	storage := &blockingS3{MockS3: h.storage, started: make(chan struct{}, limit), release: make(chan struct{})}
+	h.mediaService = mediaservice.NewService(newTestLogger(t), inlinePostgres{}, storage, encryption.Init(fastKDF, nil), nil,
+		mediaservice.NewMockRepository(), cache.NewLRU[string, mediaservice.MediaInfo](0), nil, mediaservice.Config{})
+	app := fiber.New()
+	app.Post("/upload", h.UploadMedia)
+
+	// limit uploads take every slot and wait in storage
+	statuses := make(chan int, limit)
+	for i := range limit {
+		go func() {
+			resp, err := app.Test(uploadRequest(t, fmt.Sprintf("file%d.bin", i), "", "content", nil), -1)
+			if err != nil {
+				t.Errorf("upload %d: %v", i, err)
+				statuses <- 0
+				return
+			}
+			statuses <- resp.StatusCode
+		}()
+	}
+	for range limit {
+		<-storage.started
+	}
+	if n := len(h.uploadSlots); n != limit {
+		t.Errorf("upload slots taken = %d, want %d", n, limit)
+	}
+
+	// One more is turned away without waiting
+	for _, htmx := range []bool{false, true} {
+		req := uploadRequest(t, "extra.bin", "", "content", nil)
+		if htmx {
+			req.Header.Set("HX-Request", "true")
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("extra upload: %v", err)
+		}
+		if !htmx && resp.StatusCode != fiber.StatusServiceUnavailable {
+			t.Errorf("upload over the limit status = %d, want 503", resp.StatusCode)
+		}
+		if got := resp.Header.Get(fiber.HeaderRetryAfter); got != uploadRetryAfter {
+			t.Errorf("Retry-After = %q, want %q (HTMX %v)", got, uploadRetryAfter, htmx)
+		}
+	}
+	if n := storage.MockS3.Calls("UploadStream"); n != 0 {
+		t.Errorf("UploadStream finished %d times while uploads were held", n)
+	}
+
+	close(storage.release)
+	for range limit {
+		if status := <-statuses; status != fiber.StatusOK {
+			t.Errorf("held upload status = %d, want 200", status)
+		}
+	}
+	if n := len(h.uploadSlots); n != 0 {
+		t.Errorf("upload slots taken after the uploads = %d, want 0", n)
+	}
+
+	// Released slots are taken again
+	storage.started = make(chan struct{}, 1)
+	resp, err := app.Test(uploadRequest(t, "later.bin", "", "content", nil))
+	if err != nil {
+		t.Fatalf("upload after the slots were released: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Errorf("upload after the slots were released status = %d, want 200", resp.StatusCode)
+	}
+}
+
+// streamingS3 closes received once a stream upload has read threshold bytes of its body
+type streamingS3 struct {
+	*s3.MockS3
+	threshold int
+	received  chan struct{}
+}
+
+func (s *streamingS3) UploadStream(ctx context.Context, bucket, key string, body io.Reader, size int64, contentType string, progress s3.ProgressFunc) (string, error) {
+	return s.MockS3.UploadStream(ctx, bucket, key, &thresholdReader{r: body, threshold: s.threshold, reached: s.received}, size, contentType, progress)
+}
+
+// thresholdReader closes reached once threshold bytes are read from r
+type thresholdReader struct {
+	r         io.Reader
+	read      int
+	threshold int
+	reached   chan struct{}
+}
+
+func (t *thresholdReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	if t.read < t.threshold && t.read+n >= t.threshold {
+		close(t.reached)
+	}
+	t.read += n
+	return n, err
+}
+
+func TestUploadMediaStreamsFile(t *testing.T) {
+	const (
+		bufferCap = 64 << 10 // body bytes fiber may buffer
+		fileSize  = 4 << 20
+	)
+	h := newTestHandlers(t, Config{MaxUploadSize: 2 * fileSize})
+	storage := &streamingS3{MockS3: h.storage, threshold: 4 * bufferCap, received: make(chan struct{})}
+	h.mediaService = mediaservice.NewService(newTestLogger(t), inlinePostgres{}, storage, encryption.Init(fastKDF, nil), nil,
+		mediaservice.NewMockRepository(), cache.NewLRU[string, mediaservice.MediaInfo](0), nil, mediaservice.Config{})
+	app := fiber.New(fiber.Config{
+		BodyLimit:                    bufferCap,
+		StreamRequestBody:            true,
+		DisablePreParseMultipartForm: true,
+		DisableStartupMessage:        true,
+		ErrorHandler:                 h.ErrorHandler,
+	})
+	SetupRoutes(app, h.Handlers, newTestLogger(t))
+	url := serve(t, app)
+
+	// The fields, then the file, of which the first half is sent right away
+	content := bytes.Repeat([]byte("0123456789abcdef"), fileSize/16)
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	_ = w.WriteField("download_only", "true")
+	part, err := w.CreateFormFile("file", "large.bin")
+	if err != nil {
+		t.Fatalf("CreateFormFile: %v", err)
+	}
+	_, _ = part.Write(content[:fileSize/2])
+	split := body.Len()
+	_, _ = part.Write(content[fileSize/2:])
+	_ = w.Close()
+
+	// The rest only follows once storage got part of the file, which it cannot
+	// while the server waits for the whole body
+	pr, pw := io.Pipe()
+	go func() {
+		if _, err := pw.Write(body.Bytes()[:split]); err != nil {
+			return
+		}
+		select {
+		case <-storage.received:
+		case <-time.After(5 * time.Second):
+			pw.CloseWithError(errors.New("storage received nothing before the whole body was sent"))
+			return
+		}
+		_, _ = pw.Write(body.Bytes()[split:])
+		_ = pw.Close()
+	}()
+	req, err := http.NewRequest(http.MethodPost, url+"/api/v1/upload", pr)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	req.ContentLength = int64(body.Len())
+	req.Header.Set(fiber.HeaderContentType, w.FormDataContentType())
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != fiber.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		t.Fatalf("upload status = %d (%s), want 200", resp.StatusCode, msg)
+	}
+
+	var upload UploadResponse
+	if err := json.NewDecoder(resp.Body).Decode(&upload); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !upload.DownloadOnly {
+		t.Error("download_only sent before the file was not applied")
+	}
+	resourceKey, encKey, _ := strings.Cut(upload.ResourceKey, "#")
+	download, err := h.mediaService.DownloadMedia(context.Background(), &mediaservice.DownloadRequest{ResourceKey: resourceKey, EncKeyBase64: encKey})
+	if err != nil {
+		t.Fatalf("DownloadMedia: %v", err)
+	}
+	defer download.Data.Close()
+	if got, _ := io.ReadAll(download.Data); !bytes.Equal(got, content) {
+		t.Errorf("downloaded %d bytes, not the %d uploaded", len(got), len(content))
+	}
+}
+
+func TestUploadMediaFieldsAfterFile(t *testing.T) {
+	h := newTestHandlers(t, Config{})
+	app := fiber.New(fiber.Config{StreamRequestBody: true, DisablePreParseMultipartForm: true})
+	app.Post("/upload", h.UploadMedia)
+
+	// A password after the file would come too late to protect it
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, _ := w.CreateFormFile("file", "late.txt")
+	_, _ = part.Write([]byte("content"))
+	_ = w.WriteField("password", "correct horse battery staple")
+	_ = w.Close()
+	req := httptest.NewRequest(http.MethodPost, "/upload", &body)
+	req.Header.Set(fiber.HeaderContentType, w.FormDataContentType())
+
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusBadRequest {
+		t.Errorf("status = %d, want 400", resp.StatusCode)
+	}
+	if keys, _ := h.storage.ListObjects(context.Background(), "", ""); len(keys) != 0 {
+		t.Errorf("objects left in storage: %v", keys)
+	}
+}
+
+func TestUploadMediaUnlimited(t *testing.T) {
+	h := newTestHandlers(t, Config{})
+	app := fiber.New()
+	app.Post("/upload", h.UploadMedia)
+
+	for i := range 3 {
+		resp, err := app.Test(uploadRequest(t, "file.bin", "", "content", nil))
+		if err != nil {
+			t.Fatalf("upload %d: %v", i, err)
+		}
+		if resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("upload %d status = %d, want 200", i, resp.StatusCode)
+		}
+	}
+	if n := len(h.uploadSlots); n != 0 {
+		t.Errorf("upload slots taken without a limit = %d, want 0", n)
+	}
+}
+
+func TestUploadMediaStorageQuota(t *testing.T) {
+	h := newTestHandlers(t, Config{})
+	// A quota below any encrypted upload rejects the first one
+	h.mediaService = mediaservice.NewService(newTestLogger(t), inlinePostgres{}, h.storage, encryption.Init(fastKDF, nil), nil,
+		mediaservice.NewMockRepository(), cache.NewLRU[string, mediaservice.MediaInfo](0), nil, mediaservice.Config{MaxStoragePerIPBytes: 1})
+	app := fiber.New()
+	app.Post("/upload", h.UploadMedia)
+
+	resp, err := app.Test(uploadRequest(t, "file.bin", "", "content", nil))
+	if err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != fiber.StatusInsufficientStorage || !strings.Contains(string(body), mediaservice.ErrStorageQuotaExceeded.Error()) {
+		t.Errorf("upload over the quota = %d %s, want 507", resp.StatusCode, body)
+	}
+}
@@ -0,0 +1,340 @@
+package api
+
+import (
+	"errors"
+	"mime/multipart"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	mediaservice "lovebin/internal/services/media-service"
+	"lovebin/modules/password"
+	"lovebin/modules/timeparser"
+)
+
+// Validation messages returned to API clients
+const (
+	msgRequired        = "is required"
+	msgEmptyFile       = "must not be empty"
+	msgInvalidFormat   = "invalid format"
+	msgMustBeFuture    = "must be in the future"
+	msgTooShort        = "too short"
+	msgTooLong         = "too long"
+	msgNullBytes       = "must not contain null bytes"
+	msgMustBeTrueFalse = "must be \"true\" or \"false\""
+	msgWeakPassword    = "too weak"
+	msgTooManyCodes    = "too many codes"
+	msgDuplicateCode   = "must not contain duplicates"
+	msgCodeTooLong     = "code too long"
+	msgCodesOrPassword = "cannot be combined with a password"
+	msgInvalidCountry  = "must be ISO 3166-1 alpha-2 country codes"
+	msgGeoIPDisabled   = "not supported by this server"
+	msgMustBeBefore    = "must be before expires_in"
+	msgOutOfRange      = "must be a number between 0 and 1"
+	msgViewsOutOfRange = "must be a whole number between 1 and 100"
+	msgViewsOrCodes    = "cannot be combined with access_codes"
+)
+
+// validationMessagesRU translates validation messages for the HTML form
+var validationMessagesRU = map[string]string{
+	msgRequired:        "Обязательное поле",
+	msgEmptyFile:       "Файл не должен быть пустым",
+	msgInvalidFormat:   "Неверный формат",
+	msgMustBeFuture:    "Время должно быть в будущем",
+	msgTooShort:        "Слишком короткий пароль (минимум 8 байт)",
+	msgTooLong:         "Слишком длинный пароль (максимум 72 байта)",
+	msgNullBytes:       "Пароль не должен содержать нулевые байты",
+	msgMustBeTrueFalse: "Допустимые значения: true или false",
+	msgWeakPassword:    "Слишком простой пароль",
+	msgTooManyCodes:    "Слишком много кодов (максимум 100)",
+	msgDuplicateCode:   "Коды не должны повторяться",
+	msgCodeTooLong:     "Слишком длинный код (максимум 72 байта)",
+	msgCodesOrPassword: "Используйте либо пароль, либо коды доступа",
+	msgInvalidCountry:  "Укажите двухбуквенные коды стран через запятую (RU, DE)",
+	msgGeoIPDisabled:   "Ограничение по странам не настроено на сервере",
+	msgMustBeBefore:    "Файл должен открыться раньше, чем истечет срок его жизни",
+	msgOutOfRange:      "Укажите число от 0 до 1",
+	msgViewsOutOfRange: "Укажите целое число от 1 до 100",
+	msgViewsOrCodes:    "С кодами доступа каждый код открывает файл один раз",
+
+	password.SuggestLonger:        "Используйте не менее 8 символов",
+	password.SuggestEvenLonger:    "Используйте 12 или больше символов",
+	password.SuggestLowercase:     "Добавьте строчные буквы",
+	password.SuggestUppercase:     "Добавьте заглавные буквы",
+	password.SuggestDigits:        "Добавьте цифры",
+	password.SuggestSymbols:       "Добавьте спецсимволы",
+	password.SuggestAvoidCommon:   "Не используйте распространенные пароли",
+	password.SuggestAvoidPatterns: "Избегайте повторов и последовательностей (1234, qwerty)",
+}
+
+// uploadFormFields lists the upload form fields that can carry errors
+var uploadFormFields = []string{"file", "password", "expires_in", "blur_intensity", "download_only", "access_codes", "max_views", "allowed_countries", "available_at"}
+
+const (
+	minPasswordBytes   = 8
+	maxPasswordBytes   = 72 // bcrypt limit
+	maxAccessCodeBytes = 72
+)
+
+// ValidationError collects field-level validation errors
+type ValidationError struct {
+	Fields map[string][]string `json:"errors"`
+}
+
+func NewValidationError() *ValidationError {
+	return &ValidationError{Fields: make(map[string][]string)}
+}
+
+// Add records a validation message for a field
+func (e *ValidationError) Add(field, message string) {
+	e.Fields[field] = append(e.Fields[field], message)
+}
+
+// HasErrors reports whether any field failed validation
+func (e *ValidationError) HasErrors() bool {
+	return len(e.Fields) > 0
+}
+
+func (e *ValidationError) Error() string {
+	fields := make([]string, 0, len(e.Fields))
+	for field := range e.Fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	parts := make([]string, 0, len(fields))
+	for _, field := range fields {
+		parts = append(parts, field+": "+strings.Join(e.Fields[field], ", "))
+	}
+	return "validation failed: " + strings.Join(parts, "; ")
+}
+
+// Localized returns the field errors translated for the HTML form
+func (e *ValidationError) Localized() map[string][]string {
+	result := make(map[string][]string, len(e.Fields))
+	for field, messages := range e.Fields {
+		for _, message := range messages {
+			if translated, ok := validationMessagesRU[message]; ok {
+				message = translated
+			}
+			result[field] = append(result[field], message)
+		}
+	}
+	return result
+}
+
+// uploadFormValues holds raw upload form values
+type uploadFormValues struct {
+	File             *multipart.FileHeader
+	Password         string
+	ExpiresIn        string
+	AvailableAt      string // scheduled reveal time, same formats as expires_in
+	BlurIntensity    string // 0 to 1, empty means no blur
+	DownloadOnly     string
+	AccessCodes      string         // one code per line or comma separated
+	MaxViews         string         // downloads allowed, empty means one
+	AllowedCountries string         // comma separated country codes
+	GeoIPEnabled     bool           // allowed_countries can be enforced
+	Location         *time.Location // timezone for expires_in values without an offset
+}
+
+// validateUploadForm validates all upload fields and collects every error
+func validateUploadForm(form uploadFormValues) (UploadRequest, *ValidationError) {
+	var req UploadRequest
+	verr := NewValidationError()
+
+	// file
+	if form.File == nil {
+		verr.Add("file", msgRequired)
+	} else if form.File.Size == 0 {
+		verr.Add("file", msgEmptyFile)
+	}
+
+	// expires_in
+	if form.ExpiresIn != "" {
+		expiresIn, err := timeparser.ParserWithLocation(form.Location).Parse(form.ExpiresIn)
+		req.ExpiresIn = expiresIn
+		if err != nil {
+			verr.Add("expires_in", msgInvalidFormat)
+		} else if !req.ExpiresIn.IsZero() && req.ExpiresIn.Time.Before(time.Now().UTC()) {
+			verr.Add("expires_in", msgMustBeFuture)
+		}
+	} else {
+		req.ExpiresIn = timeparser.NewUniversalTime(time.Now().Add(24 * time.Hour))
+	}
+
+	// available_at
+	if form.AvailableAt != "" {
+		availableAt, err := timeparser.ParserWithLocation(form.Location).Parse(form.AvailableAt)
+		if err != nil {
+			verr.Add("available_at", msgInvalidFormat)
+		} else if !availableAt.IsZero() {
+			if !req.ExpiresIn.IsZero() && !availableAt.Time.Before(req.ExpiresIn.Time) {
+				verr.Add("available_at", msgMustBeBefore)
+			}
+			req.AvailableAt = &availableAt
+		}
+	}
+
+	// password
+	if form.Password != "" {
+		if len(form.Password) < minPasswordBytes {
+			verr.Add("password", msgTooShort)
+		}
+		if len(form.Password) > maxPasswordBytes {
+			verr.Add("password", msgTooLong)
+		}
+		if strings.ContainsRune(form.Password, 0) {
+			verr.Add("password", msgNullBytes)
+		}
+		req.Password = form.Password
+	}
+
+	// access_codes
+	if form.AccessCodes != "" {
+		req.AccessCodes = parseAccessCodes(form.AccessCodes)
+		if len(req.AccessCodes) > mediaservice.MaxAccessCodes {
+			verr.Add("access_codes", msgTooManyCodes)
+		}
+		seen := make(map[string]struct{}, len(req.AccessCodes))
+		for _, code := range req.AccessCodes {
+			if len(code) > maxAccessCodeBytes {
+				verr.Add("access_codes", msgCodeTooLong)
+				break
+			}
+			if _, ok := seen[code]; ok {
+				verr.Add("access_codes", msgDuplicateCode)
+				break
+			}
+			seen[code] = struct{}{}
+		}
+		if len(req.AccessCodes) > 0 && form.Password != "" {
+			verr.Add("access_codes", msgCodesOrPassword)
+		}
+	}
+
+	// max_views
+	if form.MaxViews != "" {
+		maxViews, err := strconv.Atoi(strings.TrimSpace(form.MaxViews))
+		if err != nil || maxViews < 1 || maxViews > mediaservice.MaxViews {
+			verr.Add("max_views", msgViewsOutOfRange)
+		} else if maxViews > 1 && len(req.AccessCodes) > 0 {
+			verr.Add("max_views", msgViewsOrCodes)
+		} else {
+			req.MaxViews = maxViews
+		}
+	}
+
+	// allowed_countries
+	if form.AllowedCountries != "" {
+		for _, country := range strings.Split(form.AllowedCountries, ",") {
+			if country = strings.ToUpper(strings.TrimSpace(country)); country == "" {
+				continue
+			}
+			if !isCountryCode(country) {
+				verr.Add("allowed_countries", msgInvalidCountry)
+				break
+			}
+			req.AllowedCountries = append(req.AllowedCountries, country)
+		}
+		if len(req.AllowedCountries) > 0 && !form.GeoIPEnabled {
+			verr.Add("allowed_countries", msgGeoIPDisabled)
+		}
+	}
+
+	// blur_intensity
+	if form.BlurIntensity != "" {
+		intensity, err := strconv.ParseFloat(strings.TrimSpace(form.BlurIntensity), 64)
+		// Written so that NaN fails too
+		if err != nil || !(intensity >= 0 && intensity <= 1) {
+			verr.Add("blur_intensity", msgOutOfRange)
+		} else {
+			req.BlurIntensity = intensity
+		}
+	}
+
+	// download_only (unchecked checkbox sends nothing)
+	req.DownloadOnly = parseFormBool(verr, "download_only", form.DownloadOnly)
+
+	return req, verr
+}
+
+// parseAccessCodes splits a list of codes separated by newlines or commas, dropping blanks
+func parseAccessCodes(value string) []string {
+	fields := strings.FieldsFunc(value, func(r rune) bool {
+		return r == '\n' || r == '\r' || r == ','
+	})
+	codes := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if code := strings.TrimSpace(field); code != "" {
+			codes = append(codes, code)
+		}
+	}
+	return codes
+}
+
+// weakPasswordError reports a rejected password with its suggestions as field errors
+func weakPasswordError(weak *mediaservice.WeakPasswordError) *ValidationError {
+	verr := NewValidationError()
+	verr.Add("password", msgWeakPassword)
+	for _, suggestion := range weak.Suggestions {
+		verr.Add("password", suggestion)
+	}
+	return verr
+}
+
+func isCountryCode(s string) bool {
+	return len(s) == 2 && s[0] >= 'A' && s[0] <= 'Z' && s[1] >= 'A' && s[1] <= 'Z'
+}
+
+// uploadOptionsError maps access code, view count, country, availability and blur errors of the media service to field errors, nil for other errors
+func uploadOptionsError(err error) *ValidationError {
+	var message string
+	switch {
+	case errors.Is(err, mediaservice.ErrTooManyAccessCodes):
+		message = msgTooManyCodes
+	case errors.Is(err, mediaservice.ErrDuplicateAccessCode):
+		message = msgDuplicateCode
+	case errors.Is(err, mediaservice.ErrAccessCodesWithPassword):
+		message = msgCodesOrPassword
+	case errors.Is(err, mediaservice.ErrAvailableAfterExpiry):
+		verr := NewValidationError()
+		verr.Add("available_at", msgMustBeBefore)
+		return verr
+	case errors.Is(err, mediaservice.ErrInvalidBlurIntensity):
+		verr := NewValidationError()
+		verr.Add("blur_intensity", msgOutOfRange)
+		return verr
+	case errors.Is(err, mediaservice.ErrInvalidMaxViews):
+		verr := NewValidationError()
+		verr.Add("max_views", msgViewsOutOfRange)
+		return verr
+	case errors.Is(err, mediaservice.ErrMaxViewsWithAccessCodes):
+		verr := NewValidationError()
+		verr.Add("max_views", msgViewsOrCodes)
+		return verr
+	case errors.Is(err, mediaservice.ErrInvalidCountryCode):
+		verr := NewValidationError()
+		verr.Add("allowed_countries", msgInvalidCountry)
+		return verr
+	default:
+		return nil
+	}
+	verr := NewValidationError()
+	verr.Add("access_codes", message)
+	return verr
+}
+
+// parseFormBool parses a checkbox value, recording an error for anything but "", "true" or "false"
+func parseFormBool(verr *ValidationError, field, value string) bool {
+	switch value {
+	case "", "false":
+		return false
+	case "true":
+		return true
+	default:
+		verr.Add(field, msgMustBeTrueFalse)
+		return false
+	}
+}
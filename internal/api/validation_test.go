@@ -0,0 +1,175 @@
+package api
+
+import (
+	"mime/multipart"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateUploadForm(t *testing.T) {
+	file := &multipart.FileHeader{Filename: "photo.png", Size: 10}
+	future := time.Now().Add(48 * time.Hour).UTC().Format(time.RFC3339)
+	later := time.Now().Add(72 * time.Hour).UTC().Format(time.RFC3339)
+
+	tests := []struct {
+		name string
+		form uploadFormValues
+		want map[string][]string // nil when the form is valid
+	}{
+		{"minimal", uploadFormValues{File: file}, nil},
+		{"all options", uploadFormValues{
+			File: file, Password: "long enough", ExpiresIn: later, AvailableAt: future,
+			BlurIntensity: "0.5", DownloadOnly: "true", MaxViews: "3", AllowedCountries: "ru, de", GeoIPEnabled: true,
+		}, nil},
+		{"access codes", uploadFormValues{File: file, AccessCodes: "one\ntwo,three"}, nil},
+		{"missing file", uploadFormValues{}, map[string][]string{"file": {msgRequired}}},
+		{"empty file", uploadFormValues{File: &multipart.FileHeader{Filename: "a"}}, map[string][]string{"file": {msgEmptyFile}}},
+		{"bad expiry", uploadFormValues{File: file, ExpiresIn: "whenever"}, map[string][]string{"expires_in": {msgInvalidFormat}}},
+		{"past expiry", uploadFormValues{File: file, ExpiresIn: "2000-01-01T00:00:00Z"}, map[string][]string{"expires_in": {msgMustBeFuture}}},
+		{"bad availability", uploadFormValues{File: file, AvailableAt: "whenever"}, map[string][]string{"available_at": {msgInvalidFormat}}},
+		{"available after expiry", uploadFormValues{File: file, ExpiresIn: future, AvailableAt: later}, map[string][]string{"available_at": {msgMustBeBefore}}},
+		{"short password", uploadFormValues{File: file, Password: "short"}, map[string][]string{"password": {msgTooShort}}},
+		{"long password", uploadFormValues{File: file, Password: strings.Repeat("a", 73)}, map[string][]string{"password": {msgTooLong}}},
+		{"null byte password", uploadFormValues{File: file, Password: "password\x00"}, map[string][]string{"password": {msgNullBytes}}},
+		{"too many codes", uploadFormValues{File: file, AccessCodes: manyCodes(101)}, map[string][]string{"access_codes": {msgTooManyCodes}}},
+		{"duplicate code", uploadFormValues{File: file, AccessCodes: "a,b,a"}, map[string][]string{"access_codes": {msgDuplicateCode}}},
+		{"long code", uploadFormValues{File: file, AccessCodes: strings.Repeat("c", 73)}, map[string][]string{"access_codes": {msgCodeTooLong}}},
+		{"codes and password", uploadFormValues{File: file, AccessCodes: "a", Password: "long enough"}, map[string][]string{"access_codes": {msgCodesOrPassword}}},
+		{"zero views", uploadFormValues{File: file, MaxViews: "0"}, map[string][]string{"max_views": {msgViewsOutOfRange}}},
+		{"too many views", uploadFormValues{File: file, MaxViews: "101"}, map[string][]string{"max_views": {msgViewsOutOfRange}}},
+		{"views not a number", uploadFormValues{File: file, MaxViews: "many"}, map[string][]string{"max_views": {msgViewsOutOfRange}}},
+		{"views and codes", uploadFormValues{File: file, MaxViews: "2", AccessCodes: "a"}, map[string][]string{"max_views": {msgViewsOrCodes}}},
+		{"bad country", uploadFormValues{File: file, AllowedCountries: "RUS", GeoIPEnabled: true}, map[string][]string{"allowed_countries": {msgInvalidCountry}}},
+		{"countries without geoip", uploadFormValues{File: file, AllowedCountries: "RU"}, map[string][]string{"allowed_countries": {msgGeoIPDisabled}}},
+		{"blur out of range", uploadFormValues{File: file, BlurIntensity: "1.5"}, map[string][]string{"blur_intensity": {msgOutOfRange}}},
+		{"blur NaN", uploadFormValues{File: file, BlurIntensity: "NaN"}, map[string][]string{"blur_intensity": {msgOutOfRange}}},
+		{"bad checkbox", uploadFormValues{File: file, DownloadOnly: "on"}, map[string][]string{"download_only": {msgMustBeTrueFalse}}},
+		// Every field is reported in one response
+		{"several fields", uploadFormValues{Password: "x\x00", ExpiresIn: "whenever", BlurIntensity: "-1"}, map[string][]string{
+			"file":           {msgRequired},
+			"password":       {msgTooShort, msgNullBytes},
+			"expires_in":     {msgInvalidFormat},
+			"blur_intensity": {msgOutOfRange},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, verr := validateUploadForm(tt.form)
+			if tt.want == nil {
+				if verr.HasErrors() {
+					t.Fatalf("unexpected errors: %v", verr)
+				}
+				return
+			}
+			if !reflect.DeepEqual(verr.Fields, tt.want) {
+				t.Errorf("errors = %v, want %v", verr.Fields, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateUploadFormValues(t *testing.T) {
+	req, verr := validateUploadForm(uploadFormValues{
+		File:             &multipart.FileHeader{Size: 1},
+		BlurIntensity:    " 0.25 ",
+		DownloadOnly:     "true",
+		MaxViews:         " 5 ",
+		AllowedCountries: "ru,,de ",
+		GeoIPEnabled:     true,
+	})
+	if verr.HasErrors() {
+		t.Fatalf("unexpected errors: %v", verr)
+	}
+	if req.BlurIntensity != 0.25 || !req.DownloadOnly || req.MaxViews != 5 {
+		t.Errorf("got blur %v, download only %v, max views %d", req.BlurIntensity, req.DownloadOnly, req.MaxViews)
+	}
+	if !reflect.DeepEqual(req.AllowedCountries, []string{"RU", "DE"}) {
+		t.Errorf("countries = %v, want [RU DE]", req.AllowedCountries)
+	}
+	// Without expires_in resources live a day
+	if until := time.Until(req.ExpiresIn.Time); until < 23*time.Hour || until > 25*time.Hour {
+		t.Errorf("default expiry in %v, want 24h", until)
+	}
+}
+
+func TestValidateUploadFormLocation(t *testing.T) {
+	// A date without an offset is midnight in the client's timezone
+	date := time.Now().AddDate(0, 0, 3).Format("2006-01-02")
+	moscow := time.FixedZone("MSK", 3*60*60)
+
+	tests := []struct {
+		name string
+		loc  *time.Location
+		want time.Time
+	}{
+		{"utc by default", nil, mustParseDate(t, date, time.UTC)},
+		{"client timezone", moscow, mustParseDate(t, date, moscow)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, verr := validateUploadForm(uploadFormValues{File: &multipart.FileHeader{Size: 1}, ExpiresIn: date, Location: tt.loc})
+			if verr.HasErrors() {
+				t.Fatalf("unexpected errors: %v", verr)
+			}
+			if !req.ExpiresIn.Time.Equal(tt.want) || req.ExpiresIn.Time.Location() != time.UTC {
+				t.Errorf("expires_in = %v, want %v in UTC", req.ExpiresIn.Time, tt.want.UTC())
+			}
+		})
+	}
+}
+
+func mustParseDate(t *testing.T, date string, loc *time.Location) time.Time {
+	t.Helper()
+	parsed, err := time.ParseInLocation("2006-01-02", date, loc)
+	if err != nil {
+		t.Fatalf("parse %q: %v", date, err)
+	}
+	return parsed
+}
+
+func TestValidationErrorMessages(t *testing.T) {
+	verr := NewValidationError()
+	verr.Add("password", msgTooShort)
+	verr.Add("file", msgRequired)
+	verr.Add("password", "custom message")
+
+	if want := "validation failed: file: is required; password: too short, custom message"; verr.Error() != want {
+		t.Errorf("Error() = %q, want %q", verr.Error(), want)
+	}
+	want := map[string][]string{
+		"file":     {validationMessagesRU[msgRequired]},
+		"password": {validationMessagesRU[msgTooShort], "custom message"},
+	}
+	if got := verr.Localized(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Localized() = %v, want %v", got, want)
+	}
+}
+
+func TestParseAccessCodes(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{"a", []string{"a"}},
+		{"a,b", []string{"a", "b"}},
+		{"a\nb\r\nc", []string{"a", "b", "c"}},
+		{" a , ,b\n\n", []string{"a", "b"}},
+		{",\n", []string{}},
+	}
+	for _, tt := range tests {
+		if got := parseAccessCodes(tt.value); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseAccessCodes(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func manyCodes(n int) string {
+	codes := make([]string, n)
+	for i := range codes {
+		codes[i] = "code" + strconv.Itoa(i)
+	}
+	return strings.Join(codes, "\n")
+}
@@ -2,12 +2,22 @@ package app
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
 	"github.com/gofiber/fiber/v2/middleware/cors"
-	"github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/robfig/cron/v3"
 	"go.uber.org/zap"
 
@@ -16,95 +26,360 @@ import (
 	accessrepo "lovebin/internal/services/access-service/repository"
 	mediaservice "lovebin/internal/services/media-service"
 	mediarepo "lovebin/internal/services/media-service/repository"
+	"lovebin/migrations"
+	"lovebin/modules/audit"
+	"lovebin/modules/cache"
 	"lovebin/modules/encryption"
+	"lovebin/modules/geoip"
 	"lovebin/modules/logger"
 	"lovebin/modules/postgres"
+	"lovebin/modules/ratelimit"
 	"lovebin/modules/s3"
 )
 
 type Config struct {
-	Logger     logger.Config
-	Postgres   postgres.Config
-	S3         s3.Config
-	Encryption encryption.Config
-	Server     ServerConfig
+	Logger        logger.Config
+	Postgres      postgres.Config
+	S3            s3.Config
+	Media         mediaservice.Config
+	Encryption    encryption.Config
+	GeoIP         geoip.Config // empty DBPath disables country restrictions
+	Server        ServerConfig
+	Admin         AdminConfig
+	Metrics       MetricsConfig
+	CacheBackend  string        // "memory" (default) or "memcached"
+	MemcachedAddr string        // required when CacheBackend is "memcached"
+	SigningSecret string        // HMAC secret for signed download URLs
+	SignedURLTTL  time.Duration // default lifetime of signed download URLs
+
+	KeyWrappingKey  string // base64 AES key (16, 24 or 32 bytes) for key export and import, empty disables it
+	AdminSigningKey string // HMAC key of signed resource exports, empty disables them
+
+	AuditBufferSize int // audit entries queued before new ones are dropped
+
+	MaxConcurrentUploads int // uploads handled at once, further ones get 503 (0 is unlimited)
+
+	DevMode bool // re-read frontend templates on every render instead of preloading them
+
+	SwaggerEnabled bool // serve Swagger UI and the OpenAPI spec, e.g. off in production
+
+	RateLimitAlgorithm string // ratelimit.AlgorithmTokenBucket (default) or ratelimit.AlgorithmSlidingWindow
 }
 
 type ServerConfig struct {
-	Port string
-	Host string
+	Port         string
+	Host         string
+	RequestDedup bool // deduplicate concurrent identical view/preview requests
+
+	HSTSMaxAge            int  // Strict-Transport-Security max-age in seconds (0 disables HSTS)
+	HSTSIncludeSubdomains bool // add includeSubDomains directive
+	HSTSPreload           bool // add preload directive
+
+	ProxyHeader    string   // header holding the client IP, e.g. X-Forwarded-For (empty uses the remote address)
+	TrustedProxies []string // proxies allowed to set ProxyHeader (empty trusts any)
+
+	RateLimit RateLimitConfig
+
+	MaxBodyBytes int64            // request body limit, also applied to streamed uploads (default 100 MiB)
+	MIMELimits   map[string]int64 // upload limits by file type, e.g. "video/*" or "image/png", in bytes
+}
+
+// RateLimitConfig holds the per-IP limits of routes with configurable limits,
+// counted by the limiter of Config.RateLimitAlgorithm
+type RateLimitConfig struct {
+	UploadLimit  int           // uploads allowed per client IP within UploadWindow (0 disables the limit)
+	UploadWindow time.Duration // window of UploadLimit
 }
 
+type AdminConfig struct {
+	Token        string // bearer token for management routes (empty disables them)
+	MaxBenchSize int64  // largest payload of /api/v1/system/encryption-bench (default 10 MiB)
+}
+
+type MetricsConfig struct {
+	Enabled      bool          // expose Prometheus metrics on /metrics
+	PoolInterval time.Duration // how often postgres pool stats are collected
+}
+
+// defaultExpiryNotifyWindow is used when mediaservice.Config.ExpiryNotifyWindow is not set
+const defaultExpiryNotifyWindow = 24 * time.Hour
+
+// defaultMaxBodyBytes is the request body limit used when ServerConfig.MaxBodyBytes is not set
+const defaultMaxBodyBytes = 100 * 1024 * 1024
+
 type App struct {
 	logger        logger.Logger
 	postgres      postgres.Postgres
 	s3            s3.S3
 	encryption    encryption.Encryption
+	audit         *audit.PostgresAuditWriter
 	mediaService  *mediaservice.Service
 	accessService *accessservice.Service
 	handlers      *api.Handlers
 	server        *fiber.App
 	cron          *cron.Cron
+
+	stopPoolMetrics func() // nil when metrics are disabled
+	stopPoolWatcher func()
+	geoip           geoip.GeoIP
+	stopGeoIPReload func() // nil when geoip is disabled
+	stopS3Health    func()
+	drainTimeout    time.Duration
 }
 
 func New(ctx context.Context, cfg Config) (*App, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
 	// Initialize logger
-	log, err := logger.Init(logger.Config{Level: cfg.Logger.Level})
+	log, err := logger.Init(cfg.Logger)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize logger: %w", err)
 	}
+	if cfg.Logger.FilePath != "" {
+		fileLog, err := logger.FileLogger(cfg.Logger.FilePath, cfg.Logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize log file: %w", err)
+		}
+		log = logger.NewTeeLogger(log, fileLog)
+	}
 
 	// Initialize PostgreSQL
-	pg, err := postgres.Init(ctx, cfg.Postgres)
+	pg, err := postgres.Init(ctx, cfg.Postgres, log)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize postgres: %w", err)
 	}
 
+	drainTimeout := cfg.Postgres.DrainTimeout
+	if drainTimeout <= 0 {
+		drainTimeout = postgres.DefaultDrainTimeout
+	}
+
+	// Initialize metrics
+	var (
+		metricsRegistry *prometheus.Registry
+		stopPoolMetrics func()
+	)
+	if cfg.Metrics.Enabled {
+		metricsRegistry = prometheus.NewRegistry()
+		metricsRegistry.MustRegister(
+			collectors.NewGoCollector(),
+			collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		)
+		stopPoolMetrics, err = postgres.StartPoolMetricsCollector(pg.GetPool(), metricsRegistry, cfg.Metrics.PoolInterval)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start postgres metrics: %w", err)
+		}
+	}
+	poolWatcherCfg := cfg.Postgres.PoolWatcher
+	if metricsRegistry != nil {
+		poolWatcherCfg.Registry = metricsRegistry
+	}
+	stopPoolWatcher := postgres.StartPoolWatcher(pg.GetPool(), log, poolWatcherCfg)
+
 	// Initialize S3
 	s3Client, err := s3.Init(ctx, cfg.S3)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize s3: %w", err)
 	}
+	s3Health, stopS3Health := s3.StartHealthMonitor(log, s3Client, cfg.S3.HealthCheckInterval)
+
+	// Abort multipart uploads left behind by interrupted uploads, without holding up startup
+	go func() {
+		abortCtx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		defer cancel()
+
+		aborted, err := s3.NewMultipartUploadManager(log, s3Client, "", cfg.S3.MultipartUploadTTL).AbortStale(abortCtx)
+		if err != nil {
+			log.Error("Failed to abort incomplete multipart uploads", zap.Error(err))
+			return
+		}
+		if aborted > 0 {
+			log.Info("Aborted incomplete multipart uploads", zap.Int("aborted", aborted))
+		}
+	}()
 
 	// Initialize encryption
-	enc := encryption.Init(cfg.Encryption)
+	enc := encryption.Init(cfg.Encryption, log)
+	if err := enc.SelfTest(); err != nil {
+		return nil, fmt.Errorf("failed to initialize encryption: %w", err)
+	}
+	keys, err := encryption.KeyManagerFromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load encryption keys: %w", err)
+	}
+
+	// Initialize GeoIP
+	var (
+		geo             geoip.GeoIP
+		stopGeoIPReload func()
+	)
+	if cfg.GeoIP.DBPath != "" {
+		geo, err = geoip.Init(cfg.GeoIP)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize geoip: %w", err)
+		}
+		stopGeoIPReload = reloadGeoIPOnSIGHUP(log, geo)
+	}
+
+	// Check the queries against the schema before anything uses them
+	if cfg.Postgres.ValidateQueriesOnStartup {
+		queries := append(mediarepo.AllQueries(), accessrepo.AllQueries()...)
+		if err := postgres.ValidateQueries(ctx, pg.GetPool(), queries); err != nil {
+			return nil, fmt.Errorf("failed to validate queries: %w", err)
+		}
+		log.Info("validated queries against the database schema", zap.Int("queries", len(queries)))
+	}
 
 	// Initialize repositories
 	mediaRepo := mediarepo.NewMediaRepository(pg.GetPool())
 	accessRepo := accessrepo.NewAccessRepository(pg.GetPool())
 
+	// Initialize caches
+	mediaInfoCache, err := cache.New[string, mediaservice.MediaInfo](cache.Config{
+		Backend:       cfg.CacheBackend,
+		MemcachedAddr: cfg.MemcachedAddr,
+		Prefix:        "lovebin:",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize cache: %w", err)
+	}
+	accessStatusCache, err := cache.New[string, accessservice.ResourceStatus](cache.Config{
+		Backend:       cfg.CacheBackend,
+		MemcachedAddr: cfg.MemcachedAddr,
+		Prefix:        "lovebin:",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize cache: %w", err)
+	}
+
+	// Initialize audit trail
+	auditWriter := audit.NewPostgresAuditWriter(pg.GetPool(), log, cfg.AuditBufferSize)
+
 	// Initialize services
-	mediaSvc := mediaservice.NewService(log, pg, s3Client, enc, mediaRepo)
-	accessSvc := accessservice.NewService(log, pg, accessRepo)
+	mediaSvc := mediaservice.NewService(log, pg, s3Client, enc, keys, mediaRepo, mediaInfoCache, auditWriter, cfg.Media)
+	accessSvc := accessservice.NewService(log, pg, accessRepo, geo, accessStatusCache)
+
+	// Decode the key export wrapping key
+	var keyWrappingKey []byte
+	if cfg.KeyWrappingKey != "" {
+		keyWrappingKey, err = base64.StdEncoding.DecodeString(cfg.KeyWrappingKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key wrapping key: %w", err)
+		}
+		if n := len(keyWrappingKey); n != 16 && n != 24 && n != 32 {
+			return nil, fmt.Errorf("invalid key wrapping key: must be 16, 24 or 32 bytes, got %d", n)
+		}
+	}
+
+	// Validate checked the algorithm
+	rateLimiter, err := ratelimit.New(cfg.RateLimitAlgorithm)
+	if err != nil {
+		return nil, err
+	}
 
 	// Initialize handlers
-	handlers := api.NewHandlers(log, mediaSvc, accessSvc)
+	// The azure backend keeps media in a container where the S3 one uses a bucket
+	storageBucket := cfg.S3.Bucket
+	if cfg.S3.Backend == s3.BackendAzure {
+		storageBucket = cfg.S3.AzureContainerName
+	}
+
+	maxBodyBytes := cfg.Server.MaxBodyBytes
+	if maxBodyBytes == 0 {
+		maxBodyBytes = defaultMaxBodyBytes
+	}
+
+	handlers := api.NewHandlers(log, mediaSvc, accessSvc, auditWriter, postgres.NewMigrator(pg.GetPool(), migrations.FS), api.Config{
+		AdminToken:    cfg.Admin.Token,
+		SigningSecret: cfg.SigningSecret,
+		SignedURLTTL:  cfg.SignedURLTTL,
+		RequestDedup:  cfg.Server.RequestDedup,
+		MaxUploadSize: maxBodyBytes,
+		MIMELimits:    cfg.Server.MIMELimits,
+		GeoIPEnabled:  geo != nil,
+
+		MaxConcurrentUploads: cfg.MaxConcurrentUploads,
+		KeyWrappingKey:       keyWrappingKey,
+		AdminSigningKey:      cfg.AdminSigningKey,
+		StorageHealth:        s3Health,
+		StorageBucket:        storageBucket,
+		DevMode:              cfg.DevMode,
+		SwaggerEnabled:       cfg.SwaggerEnabled,
+		RateLimiter:          rateLimiter,
+		UploadRateLimit:      cfg.Server.RateLimit.UploadLimit,
+		UploadRateWindow:     cfg.Server.RateLimit.UploadWindow,
+		Encryption:           enc,
+		KDFIterations:        cfg.Encryption.Iterations,
+		MaxBenchSize:         cfg.Admin.MaxBenchSize,
+	})
 
 	// Initialize Fiber
 	server := fiber.New(fiber.Config{
-		AppName:      "LoveBin",
-		BodyLimit:    100 * 1024 * 1024, // 100MB limit
-		ReadTimeout:  time.Second * 30,
-		WriteTimeout: time.Second * 30,
+		AppName:   "LoveBin",
+		BodyLimit: int(maxBodyBytes),
+		// Stream request bodies so uploads reach storage as they arrive, with progress reported while reading
+		StreamRequestBody:            true,
+		DisablePreParseMultipartForm: true,
+		ReadTimeout:                  time.Second * 30,
+		WriteTimeout:                 time.Second * 30,
+		// Client IP is recorded with uploads and identifies the owner on /my/uploads
+		ProxyHeader:             cfg.Server.ProxyHeader,
+		EnableTrustedProxyCheck: len(cfg.Server.TrustedProxies) > 0,
+		TrustedProxies:          cfg.Server.TrustedProxies,
+		// JSON (or the error page for browsers) instead of fiber's plain text errors
+		ErrorHandler: handlers.ErrorHandler,
 	})
 
 	// Middleware
-	server.Use(recover.New())
+	server.Use(api.PanicRecoveryMiddleware(log))
 	server.Use(cors.New(cors.Config{
 		AllowOrigins:     "*",
 		AllowMethods:     "GET,POST,PUT,DELETE,OPTIONS",
-		AllowHeaders:     "Origin,Content-Type,Accept,Authorization",
+		AllowHeaders:     "Origin,Content-Type,Accept,Authorization," + api.UploadSessionHeader + "," + api.TimezoneHeader,
 		AllowCredentials: false,
-		ExposeHeaders:    "Content-Length",
+		ExposeHeaders:    "Content-Length," + api.APIVersionHeader + "," + api.RateLimitLimitHeader + "," + api.RateLimitRemainingHeader + "," + api.RateLimitResetHeader,
 		MaxAge:           3600,
 	}))
+	if cfg.Server.HSTSMaxAge > 0 {
+		server.Use(api.HSTS(cfg.Server.HSTSMaxAge, cfg.Server.HSTSIncludeSubdomains, cfg.Server.HSTSPreload))
+	}
+	// Store request and trace IDs in the request context for log correlation
+	server.Use(requestid.New(requestid.Config{ContextKey: logger.RequestIDKey}))
+	server.Use(api.AuditContext())
+	server.Use(func(c *fiber.Ctx) error {
+		if traceID := c.Get("X-Trace-Id"); traceID != "" {
+			c.Locals(logger.TraceIDKey, traceID)
+		}
+		return c.Next()
+	})
 	server.Use(func(c *fiber.Ctx) error {
 		err := c.Next()
 		statusCode := c.Response().StatusCode()
-		log.Info("Request", zap.String("method", c.Method()), zap.String("path", c.Path()), zap.Int("status", statusCode))
+		log.InfoCtx(c.Context(), "Request", zap.String("method", c.Method()), zap.String("path", c.Path()), zap.Int("status", statusCode))
 		return err
 	})
 
+	// Metrics endpoint and per-route request metrics
+	if metricsRegistry != nil {
+		server.Use(api.MetricsMiddleware(metricsRegistry))
+		for _, protected := range []bool{true, false} {
+			metricsRegistry.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+				Name:        "lovebin_downloads_rate_limited_total",
+				Help:        "Downloads rejected by the per-resource rate limit.",
+				ConstLabels: prometheus.Labels{"protected": strconv.FormatBool(protected)},
+			}, func() float64 {
+				protectedLimited, openLimited := handlers.DownloadsRateLimited()
+				if protected {
+					return float64(protectedLimited)
+				}
+				return float64(openLimited)
+			}))
+		}
+		server.Get("/metrics", adaptor.HTTPHandler(promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{})))
+	}
+
 	// Setup routes
 	api.SetupRoutes(server, handlers, log)
 
@@ -115,16 +390,54 @@ func New(ctx context.Context, cfg Config) (*App, error) {
 		defer cancel()
 
 		log.Info("Starting cleanup of expired resources")
-		if err := mediaSvc.CleanupExpiredResources(cleanupCtx); err != nil {
+		if result, err := mediaSvc.CleanupExpiredResources(cleanupCtx); errors.Is(err, mediaservice.ErrOutsideCleanupWindow) {
+			log.Info("Skipped cleanup of expired resources outside of the cleanup window")
+		} else if err != nil {
 			log.Error("Failed to cleanup expired resources", zap.Error(err))
 		} else {
-			log.Info("Successfully completed cleanup of expired resources")
+			log.Info("Successfully completed cleanup of expired resources",
+				zap.Int("deleted_expired", result.DeletedExpired), zap.Int("deleted_viewed", result.DeletedViewed))
+		}
+
+		log.Info("Starting cleanup of orphaned S3 objects")
+		if err := mediaSvc.OrphanCleanup(cleanupCtx); err != nil {
+			log.Error("Failed to cleanup orphaned S3 objects", zap.Error(err))
+		}
+
+		log.Info("Starting backfill of encrypted sizes")
+		if err := mediaSvc.BackfillEncryptedSizes(cleanupCtx); err != nil {
+			log.Error("Failed to backfill encrypted sizes", zap.Error(err))
 		}
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to setup cron job: %w", err)
 	}
 
+	// Notify of resources about to expire (hourly)
+	if cfg.Media.ExpiryWebhookURL != "" || cfg.Media.ExpiryNotifyDryRun {
+		window := cfg.Media.ExpiryNotifyWindow
+		if window <= 0 {
+			window = defaultExpiryNotifyWindow
+		}
+		_, err = c.AddFunc("0 * * * *", func() {
+			notifyCtx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+			defer cancel()
+
+			sent, err := mediaSvc.NotifyExpiringResources(notifyCtx, window, cfg.Media.ExpiryNotifyDryRun)
+			if err != nil {
+				log.Error("Failed to notify of expiring resources", zap.Error(err), zap.Int("sent", sent))
+				return
+			}
+			if sent > 0 {
+				log.Info("Notified of expiring resources", zap.Int("sent", sent), zap.Bool("dry_run", cfg.Media.ExpiryNotifyDryRun))
+			}
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to setup expiry notification cron job: %w", err)
+		}
+		log.Info("Cron job scheduled for expiry notifications", zap.String("schedule", "0 * * * *"), zap.Duration("window", window))
+	}
+
 	// Start cron scheduler
 	c.Start()
 	log.Info("Cron job scheduled for cleanup expired resources", zap.String("schedule", "15 0 * * *"))
@@ -134,14 +447,50 @@ func New(ctx context.Context, cfg Config) (*App, error) {
 		postgres:      pg,
 		s3:            s3Client,
 		encryption:    enc,
+		audit:         auditWriter,
 		mediaService:  mediaSvc,
 		accessService: accessSvc,
 		handlers:      handlers,
 		server:        server,
 		cron:          c,
+
+		stopPoolMetrics: stopPoolMetrics,
+		stopPoolWatcher: stopPoolWatcher,
+		geoip:           geo,
+		stopGeoIPReload: stopGeoIPReload,
+		stopS3Health:    stopS3Health,
+		drainTimeout:    drainTimeout,
 	}, nil
 }
 
+// reloadGeoIPOnSIGHUP reopens the GeoIP database on SIGHUP, so updated
+// MaxMind files are picked up without a restart
+func reloadGeoIPOnSIGHUP(log logger.Logger, geo geoip.GeoIP) (stop func()) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-hup:
+				if err := geo.Reload(); err != nil {
+					log.Error("Failed to reload geoip database", zap.Error(err))
+					continue
+				}
+				log.Info("GeoIP database reloaded")
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(hup)
+		close(done)
+	}
+}
+
 func (a *App) Start(addr string) error {
 	return a.server.Listen(addr)
 }
@@ -156,7 +505,30 @@ func (a *App) Shutdown(ctx context.Context) error {
 	if err := a.server.Shutdown(); err != nil {
 		return err
 	}
-	a.postgres.Close()
+
+	// Finish pending background deletions after the last request is served
+	if err := a.mediaService.Shutdown(ctx); err != nil {
+		a.logger.Warn("Media service shutdown interrupted", zap.Error(err))
+	}
+	// Flush queued audit entries while the database is still open
+	if err := a.audit.Close(ctx); err != nil {
+		a.logger.Warn("Audit writer shutdown interrupted", zap.Error(err))
+	}
+	if a.stopPoolMetrics != nil {
+		a.stopPoolMetrics()
+	}
+	a.stopPoolWatcher()
+	a.stopS3Health()
+	if a.geoip != nil {
+		a.stopGeoIPReload()
+		_ = a.geoip.Close()
+	}
+	// Let in-flight queries finish, bounded by the drain timeout
+	drainCtx, cancel := context.WithTimeout(ctx, a.drainTimeout)
+	defer cancel()
+	if err := a.postgres.GracefulClose(drainCtx); err != nil {
+		a.logger.Warn("Closed postgres pool with queries still in flight", zap.Error(err))
+	}
 	a.logger.Sync()
 	return nil
 }
@@ -0,0 +1,385 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+
+	mediaservice "lovebin/internal/services/media-service"
+	"lovebin/modules/encryption"
+	"lovebin/modules/geoip"
+	"lovebin/modules/logger"
+	"lovebin/modules/postgres"
+	"lovebin/modules/ratelimit"
+	"lovebin/modules/s3"
+	"lovebin/modules/timeparser"
+)
+
+//go:generate go run ../../cmd/configexample -o ../../config/config.example.yaml
+
+// LoadConfig loads the configuration from the YAML or TOML file at path (chosen by
+// its extension) and the environment. Each file key is the environment variable in
+// lower case, with its section as a parent, e.g. postgres.host for POSTGRES_HOST,
+// and set variables override the file. An empty path or a missing file loads the
+// environment alone, see config/config.example.yaml for all keys and defaults.
+func LoadConfig(path string) (Config, error) {
+	v := viper.New()
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+
+	if path != "" {
+		v.SetConfigFile(path)
+		if err := v.ReadInConfig(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return Config{}, fmt.Errorf("failed to read config file %s: %w", path, err)
+		}
+	}
+
+	return loadConfig(&configSource{v: v}), nil
+}
+
+// configSource reads configuration values, every value is read with its default.
+// Like the environment before config files, values that do not parse use the default.
+type configSource struct {
+	v *viper.Viper
+
+	// keys read so far with their defaults, in order, for ExampleConfig
+	keys []configKey
+}
+
+type configKey struct {
+	name  string
+	value any
+}
+
+func (s *configSource) raw(key string, defaultValue any) string {
+	s.keys = append(s.keys, configKey{name: key, value: defaultValue})
+	return s.v.GetString(key)
+}
+
+func (s *configSource) str(key, defaultValue string) string {
+	if value := s.raw(key, defaultValue); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+func (s *configSource) boolean(key string, defaultValue bool) bool {
+	if value := s.raw(key, defaultValue); value != "" {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return defaultValue
+}
+
+func (s *configSource) integer(key string, defaultValue int) int {
+	if value := s.raw(key, defaultValue); value != "" {
+		if i, err := strconv.Atoi(value); err == nil {
+			return i
+		}
+	}
+	return defaultValue
+}
+
+func (s *configSource) float(key string, defaultValue float64) float64 {
+	if value := s.raw(key, defaultValue); value != "" {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	}
+	return defaultValue
+}
+
+func (s *configSource) duration(key string, defaultValue time.Duration) time.Duration {
+	if value := s.raw(key, defaultValue); value != "" {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+	}
+	return defaultValue
+}
+
+// list reads a comma-separated variable or a list in the file
+func (s *configSource) list(key string) []string {
+	s.keys = append(s.keys, configKey{name: key, value: []string{}})
+	var items []string
+	switch value := s.v.Get(key).(type) {
+	case []any:
+		for _, item := range value {
+			items = append(items, fmt.Sprint(item))
+		}
+	case string:
+		items = strings.Split(value, ",")
+	}
+
+	var list []string
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// sizes reads byte sizes by name, comma-separated name=bytes pairs in the variable
+// or a map in the file. Sizes that do not parse are kept as -1 for Validate to report.
+func (s *configSource) sizes(key string) map[string]int64 {
+	s.keys = append(s.keys, configKey{name: key, value: map[string]int64{}})
+	raw := map[string]string{}
+	switch value := s.v.Get(key).(type) {
+	case map[string]any:
+		for name, size := range value {
+			raw[name] = fmt.Sprint(size)
+		}
+	case string:
+		for _, pair := range strings.Split(value, ",") {
+			if name, size, ok := strings.Cut(pair, "="); ok {
+				raw[name] = size
+			} else if pair = strings.TrimSpace(pair); pair != "" {
+				raw[pair] = ""
+			}
+		}
+	}
+
+	sizes := map[string]int64{}
+	for name, size := range raw {
+		n, err := strconv.ParseInt(strings.TrimSpace(size), 10, 64)
+		if err != nil {
+			n = -1
+		}
+		sizes[strings.ToLower(strings.TrimSpace(name))] = n
+	}
+	return sizes
+}
+
+// interval parses an ISO 8601 interval, unset or invalid values give no interval
+func (s *configSource) interval(key string) timeparser.Interval {
+	if value := s.raw(key, ""); value != "" {
+		if interval, err := timeparser.ParseInterval(value); err == nil {
+			return interval
+		}
+	}
+	return timeparser.Interval{}
+}
+
+// loadConfig builds the configuration from src; Logger.Version is left to the caller
+func loadConfig(src *configSource) Config {
+	devMode := src.boolean("dev_mode", false)
+	return Config{
+		Logger: logger.Config{
+			Level:           src.str("log.level", "info"),
+			Format:          src.str("log.format", logger.FormatJSON),
+			NoColor:         src.boolean("log.no_color", false),
+			SamplingEnabled: src.boolean("log.sampling_enabled", false),
+			SamplingEvery:   uint64(src.integer("log.sampling_every", 100)),
+
+			FilePath:       src.str("log.file_path", ""),
+			FileMaxSizeMB:  src.integer("log.file_max_size_mb", logger.DefaultFileMaxSizeMB),
+			FileMaxBackups: src.integer("log.file_max_backups", 0),
+			FileMaxAgeDays: src.integer("log.file_max_age_days", 0),
+		},
+		Postgres: postgres.Config{
+			Host:          src.str("postgres.host", "localhost"),
+			Port:          src.str("postgres.port", "5432"),
+			User:          src.str("postgres.user", "postgres"),
+			Password:      src.str("postgres.password", "postgres"),
+			DBName:        src.str("postgres.db", "lovebin"),
+			SSLMode:       src.str("postgres.sslmode", "disable"),
+			QueryExecMode: src.str("postgres.query_exec_mode", postgres.QueryExecModeCacheStatement),
+			DrainTimeout:  src.duration("postgres.drain_timeout", postgres.DefaultDrainTimeout),
+
+			ValidateQueriesOnStartup: src.boolean("postgres.validate_queries", devMode),
+			LogPGNotices:             src.boolean("postgres.log_notices", true),
+			PoolWatcher: postgres.PoolWatcherConfig{
+				CheckInterval: src.duration("postgres.pool_check_interval", postgres.DefaultPoolWatcherInterval),
+				WarnThreshold: src.float("postgres.pool_warn_threshold", postgres.DefaultPoolWarnThreshold),
+				CritThreshold: src.float("postgres.pool_crit_threshold", postgres.DefaultPoolCritThreshold),
+			},
+		},
+		S3: s3.Config{
+			Backend:          src.str("s3.backend", s3.BackendS3),
+			Provider:         src.str("s3.provider", s3.ProviderAWS),
+			Region:           src.str("s3.region", ""), // defaults to the provider's region in s3.Init
+			Bucket:           src.str("s3.bucket", "lovebin-media"),
+			Endpoint:         src.str("s3.endpoint", ""),
+			R2AccountID:      src.str("s3.r2_account_id", ""),
+			AccessKeyID:      src.str("s3.access_key_id", ""),
+			SecretAccessKey:  src.str("s3.secret_access_key", ""),
+			AutoCreateBucket: src.boolean("s3.auto_create_bucket", false),
+			VerifyMD5:        src.boolean("s3.verify_md5", true),
+			MaxRetries:       src.integer("s3.max_retries", 3),
+			RetryBaseDelay:   src.duration("s3.retry_base_delay", 100*time.Millisecond),
+			Timeouts: s3.OperationTimeouts{
+				Upload:   src.duration("s3.upload_timeout", s3.DefaultUploadTimeout),
+				Download: src.duration("s3.download_timeout", s3.DefaultDownloadTimeout),
+				Delete:   src.duration("s3.delete_timeout", s3.DefaultDeleteTimeout),
+			},
+
+			MultipartUploadTTL:  src.duration("s3.multipart_upload_ttl", 24*time.Hour),
+			HealthCheckInterval: src.duration("s3.health_check_interval", s3.DefaultHealthCheckInterval),
+
+			Transfer: s3.TransferManagerConfig{
+				MultipartThreshold: int64(src.integer("s3.multipart_threshold", s3.DefaultMultipartThreshold)),
+				PartSize:           int64(src.integer("s3.part_size", s3.DefaultPartSize)),
+				ConcurrentParts:    src.integer("s3.concurrent_parts", s3.DefaultConcurrentParts),
+				AbortOnError:       src.boolean("s3.abort_on_part_error", false),
+				PartRetries:        src.integer("s3.part_retries", s3.DefaultPartRetries),
+			},
+
+			EnableObjectLock:        src.boolean("s3.object_lock", false),
+			ObjectLockRetentionDays: src.integer("s3.object_lock_retention_days", 0),
+
+			CloudFront: s3.CloudFrontConfig{
+				KeyPairID:          src.str("cloudfront.key_pair_id", ""),
+				PrivateKeyPEM:      src.str("cloudfront.private_key", ""),
+				DistributionDomain: src.str("cloudfront.domain", ""),
+			},
+
+			AzureAccountName:   src.str("azure.storage_account", ""),
+			AzureAccountKey:    src.str("azure.storage_key", ""),
+			AzureContainerName: src.str("azure.container", ""),
+		},
+		Media: mediaservice.Config{
+			DeleteWorkerEnabled:    src.boolean("delete_worker_enabled", false),
+			DeleteWorkerBufferSize: src.integer("delete_worker_buffer_size", 100),
+			MinPasswordScore:       src.integer("min_password_score", 2),
+			MediaInfoCacheTTL:      src.duration("media_info_cache_ttl", 5*time.Minute),
+			PresignTTL:             src.duration("presign_ttl", 60*time.Second),
+			StorageUsageCacheTTL:   src.duration("storage_usage_cache_ttl", 5*time.Minute),
+
+			CleanupWindow: src.interval("cleanup_window"),
+
+			ExpiryWebhookURL:   src.str("expiry.webhook_url", ""),
+			ExpiryNotifyWindow: src.duration("expiry.notify_window", 24*time.Hour),
+			ExpiryNotifyDryRun: src.boolean("expiry.notify_dry_run", false),
+
+			MaxStoragePerIPBytes: int64(src.integer("max_storage_per_ip_bytes", 0)),
+			QuotaExemptIPs:       src.list("quota_exempt_ips"),
+		},
+		Encryption: encryption.Config{
+			Iterations: 100000,
+			Cipher:     encryption.Cipher(src.str("encryption.cipher", string(encryption.CipherAESGCM))),
+			Algorithm:  encryption.Algorithm(src.str("kdf.algorithm", string(encryption.AlgorithmArgon2id))),
+			Scrypt: encryption.ScryptParams{
+				N: src.integer("scrypt.n", encryption.DefaultScryptParams.N),
+				R: src.integer("scrypt.r", encryption.DefaultScryptParams.R),
+				P: src.integer("scrypt.p", encryption.DefaultScryptParams.P),
+			},
+			Argon2id: encryption.Argon2idParams{
+				Time:    src.integer("argon2id.time", encryption.DefaultArgon2idParams.Time),
+				Memory:  src.integer("argon2id.memory", encryption.DefaultArgon2idParams.Memory),
+				Threads: src.integer("argon2id.threads", encryption.DefaultArgon2idParams.Threads),
+			},
+
+			MaxKDFDuration: src.duration("kdf.max_duration", encryption.DefaultMaxKDFDuration),
+		},
+		GeoIP: geoip.Config{
+			DBPath: src.str("geoip.db_path", ""),
+		},
+		Server: ServerConfig{
+			Port:         src.str("server.port", "8080"),
+			Host:         src.str("server.host", "0.0.0.0"),
+			RequestDedup: src.boolean("request_dedup_enabled", false),
+
+			HSTSMaxAge:            src.integer("hsts.max_age", 0),
+			HSTSIncludeSubdomains: src.boolean("hsts.include_subdomains", false),
+			HSTSPreload:           src.boolean("hsts.preload", false),
+
+			ProxyHeader:    src.str("proxy_header", ""),
+			TrustedProxies: src.list("trusted_proxies"),
+
+			RateLimit: RateLimitConfig{
+				UploadLimit:  src.integer("rate_limit.upload_limit", 10),
+				UploadWindow: src.duration("rate_limit.upload_window", time.Minute),
+			},
+
+			MaxBodyBytes: int64(src.integer("server.max_body_bytes", defaultMaxBodyBytes)),
+			MIMELimits:   src.sizes("server.mime_limits"),
+		},
+		Admin: AdminConfig{
+			Token:        src.str("admin.token", ""),
+			MaxBenchSize: int64(src.integer("encryption.bench_max_size", int(encryption.DefaultMaxBenchSize))),
+		},
+		Metrics: MetricsConfig{
+			Enabled:      src.boolean("metrics.enabled", false),
+			PoolInterval: src.duration("metrics.pool_interval", 15*time.Second),
+		},
+		CacheBackend:  src.str("cache_backend", "memory"),
+		MemcachedAddr: src.str("memcached_addr", ""),
+		SigningSecret: src.str("signing_secret", ""),
+		SignedURLTTL:  src.duration("signed_url_ttl", time.Hour),
+
+		KeyWrappingKey:  src.str("key_wrapping_key", ""),
+		AdminSigningKey: src.str("admin_signing_key", ""),
+
+		AuditBufferSize: src.integer("audit_buffer_size", 1024),
+
+		MaxConcurrentUploads: src.integer("max_concurrent_uploads", 10),
+
+		DevMode:            devMode,
+		SwaggerEnabled:     src.boolean("swagger_enabled", true),
+		RateLimitAlgorithm: src.str("rate_limit_algorithm", ratelimit.AlgorithmTokenBucket),
+	}
+}
+
+// ExampleConfig returns a YAML config file listing every key with its default,
+// ignoring the environment. go generate writes it to config/config.example.yaml.
+func ExampleConfig() []byte {
+	src := &configSource{v: viper.New()}
+	loadConfig(src)
+
+	// Top-level keys first, then the sections in the order they are first read
+	var (
+		top      []string
+		sections []string
+		nested   = map[string][]string{}
+	)
+	for _, key := range src.keys {
+		line := key.name + ": " + yamlValue(key.value)
+		parent, name, ok := strings.Cut(key.name, ".")
+		if !ok {
+			top = append(top, line)
+			continue
+		}
+		if _, seen := nested[parent]; !seen {
+			sections = append(sections, parent)
+		}
+		nested[parent] = append(nested[parent], "  "+name+": "+yamlValue(key.value))
+	}
+
+	var b strings.Builder
+	b.WriteString("# Generated by go generate ./internal/app, DO NOT EDIT.\n")
+	b.WriteString("# Every key can also be set with its environment variable, the key with its\n")
+	b.WriteString("# section in upper case (postgres.host is POSTGRES_HOST), which overrides the file.\n\n")
+	for _, line := range top {
+		b.WriteString(line + "\n")
+	}
+	for _, section := range sections {
+		b.WriteString("\n" + section + ":\n")
+		for _, line := range nested[section] {
+			b.WriteString(line + "\n")
+		}
+	}
+	return []byte(b.String())
+}
+
+// yamlValue formats a default for ExampleConfig
+func yamlValue(value any) string {
+	switch value := value.(type) {
+	case string:
+		return strconv.Quote(value)
+	case time.Duration:
+		return strconv.Quote(value.String())
+	case []string:
+		return "[]"
+	case map[string]int64:
+		return "{}"
+	default:
+		return fmt.Sprint(value)
+	}
+}
@@ -0,0 +1,174 @@
+package app
+
+import (
+	"bytes"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+// writeConfigFile writes content to a file named name in a temporary directory
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return path
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{"yaml", "config.yaml", `
+dev_mode: true
+trusted_proxies: ["10.0.0.1", "10.0.0.2"]
+postgres:
+  host: "db.internal"
+s3:
+  max_retries: 5
+rate_limit:
+  upload_window: "30s"
+server:
+  mime_limits:
+    video/*: 1048576
+    IMAGE/PNG: 1024
+`},
+		{"toml", "config.toml", `
+dev_mode = true
+trusted_proxies = ["10.0.0.1", "10.0.0.2"]
+
+[postgres]
+host = "db.internal"
+
+[s3]
+max_retries = 5
+
+[rate_limit]
+upload_window = "30s"
+
+[server.mime_limits]
+"video/*" = 1048576
+"IMAGE/PNG" = 1024
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := LoadConfig(writeConfigFile(t, tt.file, tt.content))
+			if err != nil {
+				t.Fatalf("LoadConfig: %v", err)
+			}
+			if cfg.Postgres.Host != "db.internal" || cfg.S3.MaxRetries != 5 || cfg.Server.RateLimit.UploadWindow != 30*time.Second {
+				t.Errorf("postgres host %q, s3 retries %d, upload window %v", cfg.Postgres.Host, cfg.S3.MaxRetries, cfg.Server.RateLimit.UploadWindow)
+			}
+			if !cfg.DevMode || !cfg.Postgres.ValidateQueriesOnStartup {
+				t.Error("dev_mode is not set or does not turn on query validation")
+			}
+			if want := []string{"10.0.0.1", "10.0.0.2"}; !slices.Equal(cfg.Server.TrustedProxies, want) {
+				t.Errorf("trusted proxies = %v, want %v", cfg.Server.TrustedProxies, want)
+			}
+			if want := map[string]int64{"video/*": 1 << 20, "image/png": 1024}; !maps.Equal(cfg.Server.MIMELimits, want) {
+				t.Errorf("mime limits = %v, want %v", cfg.Server.MIMELimits, want)
+			}
+			// Keys missing from the file keep their defaults
+			if cfg.Postgres.Port != "5432" || cfg.Server.Port != "8080" {
+				t.Errorf("postgres port %q, server port %q, want the defaults", cfg.Postgres.Port, cfg.Server.Port)
+			}
+		})
+	}
+}
+
+func TestLoadConfigEnvironment(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+postgres:
+  host: "file-host"
+  port: "6432"
+`)
+	t.Setenv("POSTGRES_HOST", "env-host")
+	t.Setenv("TRUSTED_PROXIES", " 10.0.0.1, ,10.0.0.2 ")
+	t.Setenv("SERVER_MIME_LIMITS", "video/*=100, Image/PNG = 5,broken,bad=size")
+	t.Setenv("S3_MAX_RETRIES", "many")
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.Postgres.Host != "env-host" {
+		t.Errorf("postgres host = %q, want the environment to override the file", cfg.Postgres.Host)
+	}
+	if cfg.Postgres.Port != "6432" {
+		t.Errorf("postgres port = %q, want the file value", cfg.Postgres.Port)
+	}
+	if want := []string{"10.0.0.1", "10.0.0.2"}; !slices.Equal(cfg.Server.TrustedProxies, want) {
+		t.Errorf("trusted proxies = %v, want %v", cfg.Server.TrustedProxies, want)
+	}
+	// Sizes that do not parse are kept for Validate to report
+	if want := map[string]int64{"video/*": 100, "image/png": 5, "broken": -1, "bad": -1}; !maps.Equal(cfg.Server.MIMELimits, want) {
+		t.Errorf("mime limits = %v, want %v", cfg.Server.MIMELimits, want)
+	}
+	if cfg.S3.MaxRetries != 3 {
+		t.Errorf("s3 retries = %d, want the default for a value that does not parse", cfg.S3.MaxRetries)
+	}
+
+	// Without a file the environment is read alone
+	cfg, err = LoadConfig("")
+	if err != nil {
+		t.Fatalf("LoadConfig without a file: %v", err)
+	}
+	if cfg.Postgres.Host != "env-host" || cfg.Postgres.Port != "5432" {
+		t.Errorf("postgres %s:%s, want env-host and the default port", cfg.Postgres.Host, cfg.Postgres.Port)
+	}
+}
+
+func TestLoadConfigFileErrors(t *testing.T) {
+	cfg, err := LoadConfig(filepath.Join(t.TempDir(), "missing.yaml"))
+	if err != nil {
+		t.Fatalf("LoadConfig of a missing file: %v", err)
+	}
+	if !reflect.DeepEqual(cfg, defaultConfig()) {
+		t.Error("a missing file does not load the defaults")
+	}
+
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{"malformed yaml", "config.yaml", "postgres:\n  host: [unterminated\n"},
+		{"malformed toml", "config.toml", "[postgres\nhost = 1\n"},
+		{"unknown format", "config.ini", "host = db\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := LoadConfig(writeConfigFile(t, tt.file, tt.content)); err == nil {
+				t.Error("LoadConfig succeeded")
+			}
+		})
+	}
+}
+
+func TestExampleConfig(t *testing.T) {
+	const path = "../../config/config.example.yaml"
+	committed, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if !bytes.Equal(committed, ExampleConfig()) {
+		t.Errorf("%s is out of date, run go generate ./internal/app", path)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if !reflect.DeepEqual(cfg, defaultConfig()) {
+		t.Errorf("the example config differs from the defaults:\n%+v\n%+v", cfg, defaultConfig())
+	}
+}
@@ -0,0 +1,140 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"lovebin/modules/encryption"
+	"lovebin/modules/ratelimit"
+	"lovebin/modules/s3"
+)
+
+// minAdminTokenLength is the shortest accepted management token
+const minAdminTokenLength = 32
+
+// logLevels are the accepted logger levels, empty picks the default
+var logLevels = map[string]bool{"": true, "debug": true, "info": true, "warn": true, "error": true, "fatal": true}
+
+// Validate checks the configuration before any module is initialized and reports
+// every problem at once, joined with errors.Join
+func (cfg Config) Validate() error {
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	for _, field := range []struct{ name, env, value string }{
+		{"host", "POSTGRES_HOST", cfg.Postgres.Host},
+		{"port", "POSTGRES_PORT", cfg.Postgres.Port},
+		{"user", "POSTGRES_USER", cfg.Postgres.User},
+		{"database name", "POSTGRES_DB", cfg.Postgres.DBName},
+	} {
+		if field.value == "" {
+			fail("postgres %s is required (%s)", field.name, field.env)
+		}
+	}
+
+	if watcher := cfg.Postgres.PoolWatcher; watcher.WarnThreshold > 1 || watcher.CritThreshold > 1 ||
+		(watcher.WarnThreshold > 0 && watcher.CritThreshold > 0 && watcher.WarnThreshold > watcher.CritThreshold) {
+		fail("postgres pool thresholds must be at most 1 with warn below crit (POSTGRES_POOL_WARN_THRESHOLD, POSTGRES_POOL_CRIT_THRESHOLD), got %g and %g",
+			watcher.WarnThreshold, watcher.CritThreshold)
+	}
+
+	if cfg.S3.Backend == s3.BackendAzure {
+		if cfg.S3.AzureContainerName == "" {
+			fail("azure container is required (AZURE_CONTAINER)")
+		}
+	} else if cfg.S3.Bucket == "" {
+		fail("s3 bucket is required (S3_BUCKET)")
+	}
+	switch cfg.S3.Provider {
+	case "", s3.ProviderAWS:
+	case s3.ProviderR2, s3.ProviderB2, s3.ProviderWasabi:
+		if cfg.S3.Backend == s3.BackendAzure {
+			fail("s3 provider %s (S3_PROVIDER) needs the s3 backend", cfg.S3.Provider)
+		}
+		if cfg.S3.Provider == s3.ProviderR2 && cfg.S3.Endpoint == "" && cfg.S3.R2AccountID == "" {
+			fail("r2 account id is required with S3_PROVIDER=r2 (S3_R2_ACCOUNT_ID), or set S3_ENDPOINT")
+		}
+		if cfg.S3.Provider == s3.ProviderB2 && cfg.S3.Endpoint == "" && cfg.S3.Region == "" {
+			fail("region of the bucket is required with S3_PROVIDER=b2 (S3_REGION), or set S3_ENDPOINT")
+		}
+	default:
+		fail("unknown s3 provider %q (S3_PROVIDER), use aws, r2, b2 or wasabi", cfg.S3.Provider)
+	}
+	if cfg.S3.EnableObjectLock {
+		if cfg.S3.Backend == s3.BackendAzure {
+			fail("object lock (S3_OBJECT_LOCK) needs the s3 backend")
+		}
+		if cfg.S3.ObjectLockRetentionDays <= 0 {
+			fail("object lock retention must be at least one day (S3_OBJECT_LOCK_RETENTION_DAYS), got %d", cfg.S3.ObjectLockRetentionDays)
+		}
+	}
+	if cfg.S3.CloudFront.DistributionDomain != "" {
+		if cfg.S3.Backend == s3.BackendAzure {
+			fail("cloudfront (CLOUDFRONT_DOMAIN) needs the s3 backend")
+		}
+		if cfg.S3.CloudFront.KeyPairID == "" || cfg.S3.CloudFront.PrivateKeyPEM == "" {
+			fail("cloudfront key pair id and private key are required with CLOUDFRONT_DOMAIN (CLOUDFRONT_KEY_PAIR_ID, CLOUDFRONT_PRIVATE_KEY)")
+		}
+	}
+
+	if cfg.Encryption.Iterations <= 0 {
+		fail("encryption iterations must be positive, got %d", cfg.Encryption.Iterations)
+	}
+	switch cfg.Encryption.Algorithm {
+	case encryption.AlgorithmPBKDF2:
+	case encryption.AlgorithmScrypt:
+		if err := cfg.Encryption.Scrypt.Validate(); err != nil {
+			fail("%v (SCRYPT_N, SCRYPT_R, SCRYPT_P)", err)
+		}
+	case "", encryption.AlgorithmArgon2id:
+		if err := cfg.Encryption.Argon2id.Validate(); err != nil {
+			fail("%v (ARGON2ID_TIME, ARGON2ID_MEMORY, ARGON2ID_THREADS)", err)
+		}
+	default:
+		fail("kdf algorithm must be %s, %s or %s (KDF_ALGORITHM), got %q",
+			encryption.AlgorithmArgon2id, encryption.AlgorithmPBKDF2, encryption.AlgorithmScrypt, cfg.Encryption.Algorithm)
+	}
+
+	if port, err := strconv.Atoi(cfg.Server.Port); err != nil || port < 1 || port > 65535 {
+		fail("server port must be a number from 1 to 65535 (SERVER_PORT), got %q", cfg.Server.Port)
+	}
+
+	if cfg.Server.MaxBodyBytes <= 0 {
+		fail("server max body size must be positive (SERVER_MAX_BODY_BYTES), got %d", cfg.Server.MaxBodyBytes)
+	}
+	for pattern, limit := range cfg.Server.MIMELimits {
+		if major, minor, ok := strings.Cut(pattern, "/"); !ok || major == "" || major == "*" || minor == "" {
+			fail("mime limit %q must be for a type like video/* or image/png (SERVER_MIME_LIMITS)", pattern)
+		} else if limit <= 0 || limit > cfg.Server.MaxBodyBytes {
+			fail("mime limit of %s must be a byte count from 1 to the max body size of %d (SERVER_MIME_LIMITS), got %d",
+				pattern, cfg.Server.MaxBodyBytes, limit)
+		}
+	}
+
+	if !logLevels[cfg.Logger.Level] {
+		fail("log level must be one of debug, info, warn, error, fatal (LOG_LEVEL), got %q", cfg.Logger.Level)
+	}
+
+	switch cfg.RateLimitAlgorithm {
+	case "", ratelimit.AlgorithmTokenBucket, ratelimit.AlgorithmSlidingWindow:
+	default:
+		fail("rate limit algorithm must be %s or %s (RATE_LIMIT_ALGORITHM), got %q",
+			ratelimit.AlgorithmTokenBucket, ratelimit.AlgorithmSlidingWindow, cfg.RateLimitAlgorithm)
+	}
+
+	if limit := cfg.Server.RateLimit.UploadLimit; limit < 0 {
+		fail("upload rate limit must not be negative (RATE_LIMIT_UPLOAD_LIMIT), got %d", limit)
+	} else if window := cfg.Server.RateLimit.UploadWindow; limit > 0 && window <= 0 {
+		fail("upload rate limit window must be positive (RATE_LIMIT_UPLOAD_WINDOW), got %s", window)
+	}
+
+	if token := cfg.Admin.Token; token != "" && len(token) < minAdminTokenLength {
+		fail("admin token must be at least %d characters (ADMIN_TOKEN), got %d", minAdminTokenLength, len(token))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,164 @@
+package app
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"lovebin/modules/encryption"
+	"lovebin/modules/s3"
+)
+
+// defaultConfig returns the configuration loaded from an empty source
+func defaultConfig() Config {
+	return loadConfig(&configSource{v: viper.New()})
+}
+
+// validationErrors splits the joined error of Validate
+func validationErrors(err error) []error {
+	if err == nil {
+		return nil
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return joined.Unwrap()
+	}
+	return []error{err}
+}
+
+func TestValidateDefaults(t *testing.T) {
+	if err := defaultConfig().Validate(); err != nil {
+		t.Errorf("default config is invalid: %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(cfg *Config)
+		want   string // part of the single error, empty when the config is valid
+	}{
+		{"postgres host", func(cfg *Config) { cfg.Postgres.Host = "" }, "POSTGRES_HOST"},
+		{"postgres port", func(cfg *Config) { cfg.Postgres.Port = "" }, "POSTGRES_PORT"},
+		{"postgres user", func(cfg *Config) { cfg.Postgres.User = "" }, "POSTGRES_USER"},
+		{"postgres database", func(cfg *Config) { cfg.Postgres.DBName = "" }, "POSTGRES_DB"},
+		{"pool warn above 1", func(cfg *Config) { cfg.Postgres.PoolWatcher.WarnThreshold = 1.5 }, "POSTGRES_POOL_WARN_THRESHOLD"},
+		{"pool crit above 1", func(cfg *Config) { cfg.Postgres.PoolWatcher.CritThreshold = 2 }, "POSTGRES_POOL_CRIT_THRESHOLD"},
+		{"pool warn above crit", func(cfg *Config) {
+			cfg.Postgres.PoolWatcher.WarnThreshold, cfg.Postgres.PoolWatcher.CritThreshold = 0.9, 0.8
+		}, "warn below crit"},
+		{"pool thresholds unset", func(cfg *Config) {
+			cfg.Postgres.PoolWatcher.WarnThreshold, cfg.Postgres.PoolWatcher.CritThreshold = 0, 0
+		}, ""},
+
+		{"s3 bucket", func(cfg *Config) { cfg.S3.Bucket = "" }, "S3_BUCKET"},
+		{"azure container", func(cfg *Config) { cfg.S3.Backend = s3.BackendAzure; cfg.S3.AzureContainerName = "" }, "AZURE_CONTAINER"},
+		{"azure without bucket", func(cfg *Config) {
+			cfg.S3.Backend, cfg.S3.AzureContainerName, cfg.S3.Bucket = s3.BackendAzure, "media", ""
+		}, ""},
+		{"unknown provider", func(cfg *Config) { cfg.S3.Provider = "minio" }, "unknown s3 provider"},
+		{"provider on azure", func(cfg *Config) {
+			cfg.S3.Backend, cfg.S3.AzureContainerName, cfg.S3.Provider = s3.BackendAzure, "media", s3.ProviderWasabi
+		}, "needs the s3 backend"},
+		{"r2 without account", func(cfg *Config) { cfg.S3.Provider, cfg.S3.Endpoint, cfg.S3.R2AccountID = s3.ProviderR2, "", "" }, "S3_R2_ACCOUNT_ID"},
+		{"r2 with account", func(cfg *Config) { cfg.S3.Provider, cfg.S3.Endpoint, cfg.S3.R2AccountID = s3.ProviderR2, "", "account" }, ""},
+		{"r2 with endpoint", func(cfg *Config) { cfg.S3.Provider, cfg.S3.Endpoint = s3.ProviderR2, "https://r2.example" }, ""},
+		{"b2 without region", func(cfg *Config) { cfg.S3.Provider, cfg.S3.Endpoint, cfg.S3.Region = s3.ProviderB2, "", "" }, "S3_REGION"},
+		{"object lock on azure", func(cfg *Config) {
+			cfg.S3.Backend, cfg.S3.AzureContainerName = s3.BackendAzure, "media"
+			cfg.S3.EnableObjectLock, cfg.S3.ObjectLockRetentionDays = true, 30
+		}, "S3_OBJECT_LOCK"},
+		{"object lock without retention", func(cfg *Config) {
+			cfg.S3.EnableObjectLock, cfg.S3.ObjectLockRetentionDays = true, 0
+		}, "S3_OBJECT_LOCK_RETENTION_DAYS"},
+		{"cloudfront without keys", func(cfg *Config) { cfg.S3.CloudFront.DistributionDomain = "cdn.example" }, "CLOUDFRONT_KEY_PAIR_ID"},
+		{"cloudfront on azure", func(cfg *Config) {
+			cfg.S3.Backend, cfg.S3.AzureContainerName = s3.BackendAzure, "media"
+			cfg.S3.CloudFront = s3.CloudFrontConfig{DistributionDomain: "cdn.example", KeyPairID: "K", PrivateKeyPEM: "pem"}
+		}, "CLOUDFRONT_DOMAIN"},
+
+		{"iterations", func(cfg *Config) { cfg.Encryption.Iterations = 0 }, "iterations must be positive"},
+		{"unknown kdf", func(cfg *Config) { cfg.Encryption.Algorithm = "bcrypt" }, "KDF_ALGORITHM"},
+		{"scrypt N", func(cfg *Config) {
+			cfg.Encryption.Algorithm, cfg.Encryption.Scrypt = encryption.AlgorithmScrypt, encryption.ScryptParams{N: 1000}
+		}, "SCRYPT_N"},
+		{"argon2id memory", func(cfg *Config) {
+			cfg.Encryption.Algorithm, cfg.Encryption.Argon2id = encryption.AlgorithmArgon2id, encryption.Argon2idParams{Time: 1, Memory: 4, Threads: 1}
+		}, "ARGON2ID_MEMORY"},
+		{"argon2id threads", func(cfg *Config) { cfg.Encryption.Argon2id.Threads = 300 }, "ARGON2ID_THREADS"},
+		{"pbkdf2 ignores argon2id", func(cfg *Config) {
+			cfg.Encryption.Algorithm, cfg.Encryption.Argon2id.Threads = encryption.AlgorithmPBKDF2, 300
+		}, ""},
+
+		{"port not a number", func(cfg *Config) { cfg.Server.Port = "http" }, "SERVER_PORT"},
+		{"port zero", func(cfg *Config) { cfg.Server.Port = "0" }, "SERVER_PORT"},
+		{"port too high", func(cfg *Config) { cfg.Server.Port = "65536" }, "SERVER_PORT"},
+		{"max body", func(cfg *Config) { cfg.Server.MaxBodyBytes = 0 }, "SERVER_MAX_BODY_BYTES"},
+		{"mime limit pattern", func(cfg *Config) { cfg.Server.MIMELimits = map[string]int64{"video": 1} }, "SERVER_MIME_LIMITS"},
+		{"mime limit wildcard major", func(cfg *Config) { cfg.Server.MIMELimits = map[string]int64{"*/*": 1} }, "SERVER_MIME_LIMITS"},
+		{"mime limit above max body", func(cfg *Config) {
+			cfg.Server.MIMELimits = map[string]int64{"video/*": cfg.Server.MaxBodyBytes + 1}
+		}, "SERVER_MIME_LIMITS"},
+		{"mime limit zero", func(cfg *Config) { cfg.Server.MIMELimits = map[string]int64{"image/png": 0} }, "SERVER_MIME_LIMITS"},
+		{"mime limits", func(cfg *Config) { cfg.Server.MIMELimits = map[string]int64{"video/*": 1 << 20, "image/png": 1} }, ""},
+
+		{"log level", func(cfg *Config) { cfg.Logger.Level = "verbose" }, "LOG_LEVEL"},
+		{"rate limit algorithm", func(cfg *Config) { cfg.RateLimitAlgorithm = "leaky_bucket" }, "RATE_LIMIT_ALGORITHM"},
+		{"negative upload limit", func(cfg *Config) { cfg.Server.RateLimit.UploadLimit = -1 }, "RATE_LIMIT_UPLOAD_LIMIT"},
+		{"upload window", func(cfg *Config) {
+			cfg.Server.RateLimit.UploadLimit, cfg.Server.RateLimit.UploadWindow = 10, 0
+		}, "RATE_LIMIT_UPLOAD_WINDOW"},
+		{"upload limit off", func(cfg *Config) {
+			cfg.Server.RateLimit.UploadLimit, cfg.Server.RateLimit.UploadWindow = 0, -time.Second
+		}, ""},
+		{"short admin token", func(cfg *Config) { cfg.Admin.Token = "short" }, "ADMIN_TOKEN"},
+		{"admin token", func(cfg *Config) { cfg.Admin.Token = strings.Repeat("t", minAdminTokenLength) }, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := defaultConfig()
+			tt.modify(&cfg)
+			errs := validationErrors(cfg.Validate())
+
+			if tt.want == "" {
+				if len(errs) != 0 {
+					t.Errorf("Validate = %v, want a valid config", errs)
+				}
+				return
+			}
+			if len(errs) != 1 {
+				t.Fatalf("Validate = %v, want one error", errs)
+			}
+			if !strings.Contains(errs[0].Error(), tt.want) {
+				t.Errorf("Validate = %v, want it to mention %s", errs[0], tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateReportsEveryError(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.Postgres.Host = ""
+	cfg.S3.Bucket = ""
+	cfg.Server.Port = "not a port"
+	cfg.Logger.Level = "loud"
+	cfg.Admin.Token = "short"
+
+	err := cfg.Validate()
+	errs := validationErrors(err)
+	if len(errs) != 5 {
+		t.Fatalf("Validate reported %d errors, want 5: %v", len(errs), err)
+	}
+	for _, want := range []string{"POSTGRES_HOST", "S3_BUCKET", "SERVER_PORT", "LOG_LEVEL", "ADMIN_TOKEN"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate error does not mention %s:\n%v", want, err)
+		}
+	}
+	for _, e := range errs {
+		if !errors.Is(err, e) {
+			t.Errorf("joined error does not wrap %v", e)
+		}
+	}
+}
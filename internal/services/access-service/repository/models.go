@@ -8,15 +8,49 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+type AccessCode struct {
+	ID          pgtype.UUID        `json:"id"`
+	ResourceKey string             `json:"resource_key"`
+	CodeHash    string             `json:"code_hash"`
+	UsedAt      pgtype.Timestamptz `json:"used_at"`
+}
+
+type AuditTrail struct {
+	ID          pgtype.UUID        `json:"id"`
+	Operation   string             `json:"operation"`
+	ResourceKey pgtype.Text        `json:"resource_key"`
+	PerformedBy string             `json:"performed_by"`
+	Ip          pgtype.Text        `json:"ip"`
+	Details     []byte             `json:"details"`
+	At          pgtype.Timestamptz `json:"at"`
+}
+
+type ExpiryNotification struct {
+	ResourceKey string             `json:"resource_key"`
+	NotifiedAt  pgtype.Timestamptz `json:"notified_at"`
+}
+
 type MediaResource struct {
-	ID            pgtype.UUID      `json:"id"`
-	ResourceKey   string           `json:"resource_key"`
-	PasswordHash  pgtype.Text      `json:"password_hash"`
-	ExpiresAt     pgtype.Timestamp `json:"expires_at"`
-	Viewed        pgtype.Bool      `json:"viewed"`
-	CreatedAt     pgtype.Timestamp `json:"created_at"`
-	Salt          []byte           `json:"salt"`
-	Filename      pgtype.Text      `json:"filename"`
-	FileExtension pgtype.Text      `json:"file_extension"`
-	BlurEnabled   pgtype.Bool      `json:"blur_enabled"`
+	ID                 pgtype.UUID        `json:"id"`
+	ResourceKey        string             `json:"resource_key"`
+	PasswordHash       pgtype.Text        `json:"password_hash"`
+	ExpiresAt          pgtype.Timestamp   `json:"expires_at"`
+	Viewed             pgtype.Bool        `json:"viewed"`
+	CreatedAt          pgtype.Timestamp   `json:"created_at"`
+	Salt               []byte             `json:"salt"`
+	Filename           pgtype.Text        `json:"filename"`
+	FileExtension      pgtype.Text        `json:"file_extension"`
+	BlurIntensity      pgtype.Float8      `json:"blur_intensity"`
+	DownloadOnly       pgtype.Bool        `json:"download_only"`
+	UploadIp           pgtype.Text        `json:"upload_ip"`
+	AllowedCountries   []string           `json:"allowed_countries"`
+	KeyCheck           []byte             `json:"key_check"`
+	AvailableAt        pgtype.Timestamptz `json:"available_at"`
+	KeyVersion         pgtype.Text        `json:"key_version"`
+	EncryptedSize      pgtype.Int8        `json:"encrypted_size"`
+	VerificationPubkey []byte             `json:"verification_pubkey"`
+	MetadataEncrypted  []byte             `json:"metadata_encrypted"`
+	MaxViews           int32              `json:"max_views"`
+	ViewCount          int32              `json:"view_count"`
+	SignedUrlRequired  bool               `json:"signed_url_required"`
 }
@@ -12,6 +12,10 @@ import (
 
 type Querier interface {
 	CheckResourceAccess(ctx context.Context, resourceKey string) (CheckResourceAccessRow, error)
+	CountUnusedAccessCodes(ctx context.Context, resourceKey string) (int64, error)
+	GetResourceStatuses(ctx context.Context, resourceKeys []string) ([]GetResourceStatusesRow, error)
+	IsAccessCodeUnused(ctx context.Context, arg IsAccessCodeUnusedParams) (bool, error)
+	RequireSignedURL(ctx context.Context, resourceKey string) (int64, error)
 	VerifyPassword(ctx context.Context, resourceKey string) (pgtype.Text, error)
 }
 
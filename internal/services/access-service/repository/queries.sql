@@ -9,8 +9,38 @@ SELECT
     password_hash,
     expires_at,
     viewed,
-    salt
+    salt,
+    allowed_countries,
+    available_at,
+    signed_url_required,
+    EXISTS (
+        SELECT 1 FROM access_codes
+        WHERE access_codes.resource_key = media_resources.resource_key
+    )::boolean AS has_access_codes
 FROM media_resources
 WHERE resource_key = $1
 AND (expires_at IS NULL OR expires_at > NOW());
 
+-- name: RequireSignedURL :execrows
+UPDATE media_resources
+SET signed_url_required = TRUE
+WHERE resource_key = $1;
+
+-- name: IsAccessCodeUnused :one
+SELECT EXISTS (
+    SELECT 1 FROM access_codes
+    WHERE resource_key = $1
+    AND code_hash = $2
+    AND used_at IS NULL
+)::boolean AS unused;
+
+-- name: CountUnusedAccessCodes :one
+SELECT COUNT(*) FROM access_codes
+WHERE resource_key = $1
+AND used_at IS NULL;
+
+-- name: GetResourceStatuses :many
+SELECT resource_key, expires_at, viewed, available_at
+FROM media_resources
+WHERE resource_key = ANY(@resource_keys::text[]);
+
@@ -18,19 +18,30 @@ SELECT
     password_hash,
     expires_at,
     viewed,
-    salt
+    salt,
+    allowed_countries,
+    available_at,
+    signed_url_required,
+    EXISTS (
+        SELECT 1 FROM access_codes
+        WHERE access_codes.resource_key = media_resources.resource_key
+    )::boolean AS has_access_codes
 FROM media_resources
 WHERE resource_key = $1
 AND (expires_at IS NULL OR expires_at > NOW())
 `
 
 type CheckResourceAccessRow struct {
-	ID           pgtype.UUID      `json:"id"`
-	ResourceKey  string           `json:"resource_key"`
-	PasswordHash pgtype.Text      `json:"password_hash"`
-	ExpiresAt    pgtype.Timestamp `json:"expires_at"`
-	Viewed       pgtype.Bool      `json:"viewed"`
-	Salt         []byte           `json:"salt"`
+	ID                pgtype.UUID        `json:"id"`
+	ResourceKey       string             `json:"resource_key"`
+	PasswordHash      pgtype.Text        `json:"password_hash"`
+	ExpiresAt         pgtype.Timestamp   `json:"expires_at"`
+	Viewed            pgtype.Bool        `json:"viewed"`
+	Salt              []byte             `json:"salt"`
+	AllowedCountries  []string           `json:"allowed_countries"`
+	AvailableAt       pgtype.Timestamptz `json:"available_at"`
+	SignedUrlRequired bool               `json:"signed_url_required"`
+	HasAccessCodes    bool               `json:"has_access_codes"`
 }
 
 func (q *Queries) CheckResourceAccess(ctx context.Context, resourceKey string) (CheckResourceAccessRow, error) {
@@ -43,10 +54,100 @@ func (q *Queries) CheckResourceAccess(ctx context.Context, resourceKey string) (
 		&i.ExpiresAt,
 		&i.Viewed,
 		&i.Salt,
+		&i.AllowedCountries,
+		&i.AvailableAt,
+		&i.SignedUrlRequired,
+		&i.HasAccessCodes,
 	)
 	return i, err
 }
 
+const countUnusedAccessCodes = `-- name: CountUnusedAccessCodes :one
+SELECT COUNT(*) FROM access_codes
+WHERE resource_key = $1
+AND used_at IS NULL
+`
+
+func (q *Queries) CountUnusedAccessCodes(ctx context.Context, resourceKey string) (int64, error) {
+	row := q.db.QueryRow(ctx, countUnusedAccessCodes, resourceKey)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const getResourceStatuses = `-- name: GetResourceStatuses :many
+SELECT resource_key, expires_at, viewed, available_at
+FROM media_resources
+WHERE resource_key = ANY($1::text[])
+`
+
+type GetResourceStatusesRow struct {
+	ResourceKey string             `json:"resource_key"`
+	ExpiresAt   pgtype.Timestamp   `json:"expires_at"`
+	Viewed      pgtype.Bool        `json:"viewed"`
+	AvailableAt pgtype.Timestamptz `json:"available_at"`
+}
+
+func (q *Queries) GetResourceStatuses(ctx context.Context, resourceKeys []string) ([]GetResourceStatusesRow, error) {
+	rows, err := q.db.Query(ctx, getResourceStatuses, resourceKeys)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetResourceStatusesRow
+	for rows.Next() {
+		var i GetResourceStatusesRow
+		if err := rows.Scan(
+			&i.ResourceKey,
+			&i.ExpiresAt,
+			&i.Viewed,
+			&i.AvailableAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const isAccessCodeUnused = `-- name: IsAccessCodeUnused :one
+SELECT EXISTS (
+    SELECT 1 FROM access_codes
+    WHERE resource_key = $1
+    AND code_hash = $2
+    AND used_at IS NULL
+)::boolean AS unused
+`
+
+type IsAccessCodeUnusedParams struct {
+	ResourceKey string `json:"resource_key"`
+	CodeHash    string `json:"code_hash"`
+}
+
+func (q *Queries) IsAccessCodeUnused(ctx context.Context, arg IsAccessCodeUnusedParams) (bool, error) {
+	row := q.db.QueryRow(ctx, isAccessCodeUnused, arg.ResourceKey, arg.CodeHash)
+	var unused bool
+	err := row.Scan(&unused)
+	return unused, err
+}
+
+const requireSignedURL = `-- name: RequireSignedURL :execrows
+UPDATE media_resources
+SET signed_url_required = TRUE
+WHERE resource_key = $1
+`
+
+func (q *Queries) RequireSignedURL(ctx context.Context, resourceKey string) (int64, error) {
+	result, err := q.db.Exec(ctx, requireSignedURL, resourceKey)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const verifyPassword = `-- name: VerifyPassword :one
 SELECT password_hash FROM media_resources
 WHERE resource_key = $1
@@ -10,12 +10,24 @@ import (
 
 // ResourceAccess represents resource access information
 type ResourceAccess struct {
-	ID           string
-	ResourceKey  string
-	PasswordHash *string
-	ExpiresAt    timeparser.UniversalTime
-	Viewed       bool
-	Salt         []byte
+	ID                string
+	ResourceKey       string
+	PasswordHash      *string
+	ExpiresAt         timeparser.UniversalTime
+	Viewed            bool
+	Salt              []byte
+	AllowedCountries  []string
+	AvailableAt       timeparser.UniversalTime // zero when available immediately
+	HasAccessCodes    bool
+	SignedURLRequired bool
+}
+
+// ResourceStatus is the state of a resource as far as access is concerned
+type ResourceStatus struct {
+	ResourceKey string
+	ExpiresAt   timeparser.UniversalTime // zero when the resource never expires
+	Viewed      bool
+	AvailableAt timeparser.UniversalTime // zero when available immediately
 }
 
 // AccessRepository wraps sqlc Queries and converts types
@@ -29,6 +41,18 @@ func NewAccessRepository(db *pgxpool.Pool) *AccessRepository {
 	}
 }
 
+// AllQueries returns the SQL of every generated query, for postgres.ValidateQueries
+func AllQueries() []string {
+	return []string{
+		checkResourceAccess,
+		countUnusedAccessCodes,
+		getResourceStatuses,
+		isAccessCodeUnused,
+		requireSignedURL,
+		verifyPassword,
+	}
+}
+
 func (r *AccessRepository) VerifyPassword(ctx context.Context, resourceKey string) (string, error) {
 	result, err := r.queries.VerifyPassword(ctx, resourceKey)
 	if err != nil {
@@ -49,10 +73,64 @@ func (r *AccessRepository) CheckResourceAccess(ctx context.Context, resourceKey
 	return toResourceAccess(dbAccess), nil
 }
 
+// RequireSignedURL makes a resource open only with a signed URL, reporting whether it exists
+func (r *AccessRepository) RequireSignedURL(ctx context.Context, resourceKey string) (bool, error) {
+	rows, err := r.queries.RequireSignedURL(ctx, resourceKey)
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// IsAccessCodeUnused reports whether an access code matches and is still valid, without using it
+func (r *AccessRepository) IsAccessCodeUnused(ctx context.Context, resourceKey, codeHash string) (bool, error) {
+	return r.queries.IsAccessCodeUnused(ctx, IsAccessCodeUnusedParams{
+		ResourceKey: resourceKey,
+		CodeHash:    codeHash,
+	})
+}
+
+// CountUnusedAccessCodes returns how many access codes of a resource are still valid
+func (r *AccessRepository) CountUnusedAccessCodes(ctx context.Context, resourceKey string) (int, error) {
+	count, err := r.queries.CountUnusedAccessCodes(ctx, resourceKey)
+	if err != nil {
+		return 0, err
+	}
+	return int(count), nil
+}
+
+// GetResourceStatuses returns the states of the given resources in one query,
+// including expired and viewed ones. Keys without a record are omitted.
+func (r *AccessRepository) GetResourceStatuses(ctx context.Context, resourceKeys []string) ([]ResourceStatus, error) {
+	rows, err := r.queries.GetResourceStatuses(ctx, resourceKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]ResourceStatus, 0, len(rows))
+	for _, row := range rows {
+		status := ResourceStatus{
+			ResourceKey: row.ResourceKey,
+			Viewed:      row.Viewed.Valid && row.Viewed.Bool,
+		}
+		if row.ExpiresAt.Valid {
+			status.ExpiresAt = timeparser.NewUniversalTime(row.ExpiresAt.Time)
+		}
+		if row.AvailableAt.Valid {
+			status.AvailableAt = timeparser.NewUniversalTime(row.AvailableAt.Time)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
 func toResourceAccess(db CheckResourceAccessRow) ResourceAccess {
 	result := ResourceAccess{
-		ResourceKey: db.ResourceKey,
-		Salt:        db.Salt,
+		ResourceKey:       db.ResourceKey,
+		Salt:              db.Salt,
+		AllowedCountries:  db.AllowedCountries,
+		HasAccessCodes:    db.HasAccessCodes,
+		SignedURLRequired: db.SignedUrlRequired,
 	}
 
 	// Convert ID
@@ -70,6 +148,11 @@ func toResourceAccess(db CheckResourceAccessRow) ResourceAccess {
 		result.ExpiresAt = timeparser.NewUniversalTime(db.ExpiresAt.Time)
 	}
 
+	// Convert available at to UniversalTime (UTC)
+	if db.AvailableAt.Valid {
+		result.AvailableAt = timeparser.NewUniversalTime(db.AvailableAt.Time)
+	}
+
 	// Convert viewed
 	if db.Viewed.Valid {
 		result.Viewed = db.Viewed.Bool
@@ -0,0 +1,12 @@
+package repository
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAllQueries(t *testing.T) {
+	if got, want := len(AllQueries()), reflect.TypeFor[Querier]().NumMethod(); got != want {
+		t.Errorf("AllQueries has %d queries, Querier has %d", got, want)
+	}
+}
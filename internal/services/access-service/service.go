@@ -3,11 +3,18 @@ package accessservice
 import (
 	"context"
 	"errors"
+	"slices"
 	"time"
 
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
 	"golang.org/x/crypto/bcrypt"
 
 	accessrepo "lovebin/internal/services/access-service/repository"
+	"lovebin/modules/audit"
+	"lovebin/modules/cache"
+	"lovebin/modules/encryption"
+	"lovebin/modules/geoip"
 	"lovebin/modules/logger"
 	"lovebin/modules/postgres"
 	"lovebin/modules/timeparser"
@@ -16,53 +23,75 @@ import (
 // Convert repository types to service types
 func repoToServiceResourceAccess(repo accessrepo.ResourceAccess) ResourceAccess {
 	return ResourceAccess{
-		ID:           repo.ID,
-		ResourceKey:  repo.ResourceKey,
-		PasswordHash: repo.PasswordHash,
-		ExpiresAt:    repo.ExpiresAt,
-		Viewed:       repo.Viewed,
-		Salt:         repo.Salt,
+		ID:                repo.ID,
+		ResourceKey:       repo.ResourceKey,
+		PasswordHash:      repo.PasswordHash,
+		ExpiresAt:         repo.ExpiresAt,
+		Viewed:            repo.Viewed,
+		Salt:              repo.Salt,
+		AllowedCountries:  repo.AllowedCountries,
+		AvailableAt:       repo.AvailableAt,
+		HasAccessCodes:    repo.HasAccessCodes,
+		SignedURLRequired: repo.SignedURLRequired,
 	}
 }
 
 type Service struct {
-	logger   logger.Logger
-	postgres postgres.Postgres
-	repo     Repository
+	logger      logger.Logger
+	postgres    postgres.Postgres
+	repo        Repository
+	geoip       geoip.GeoIP
+	statusCache cache.Cache[string, ResourceStatus] // nil disables caching of BulkCheckResourceAccess
 }
 
 type Repository interface {
 	VerifyPassword(ctx context.Context, resourceKey string) (string, error)
 	CheckResourceAccess(ctx context.Context, resourceKey string) (accessrepo.ResourceAccess, error)
+	IsAccessCodeUnused(ctx context.Context, resourceKey, codeHash string) (bool, error)
+	CountUnusedAccessCodes(ctx context.Context, resourceKey string) (int, error)
+	RequireSignedURL(ctx context.Context, resourceKey string) (bool, error)
+	GetResourceStatuses(ctx context.Context, resourceKeys []string) ([]accessrepo.ResourceStatus, error)
 }
 
 type ResourceAccess struct {
-	ID           string
-	ResourceKey  string
-	PasswordHash *string
-	ExpiresAt    timeparser.UniversalTime
-	Viewed       bool
-	Salt         []byte
+	ID                string
+	ResourceKey       string
+	PasswordHash      *string
+	ExpiresAt         timeparser.UniversalTime
+	Viewed            bool
+	Salt              []byte
+	AllowedCountries  []string                 // ISO country codes allowed to access, empty allows all
+	AvailableAt       timeparser.UniversalTime // resource opens at this time, zero when available immediately
+	HasAccessCodes    bool                     // access is granted by single-use codes instead of a password
+	SignedURLRequired bool                     // the resource only opens with a signed URL
 }
 
 func NewService(
 	logger logger.Logger,
 	postgres postgres.Postgres,
 	repo Repository,
+	geo geoip.GeoIP, // nil disables country lookups
+	statusCache cache.Cache[string, ResourceStatus], // nil disables status caching
 ) *Service {
 	return &Service{
-		logger:   logger,
-		postgres: postgres,
-		repo:     repo,
+		logger:      logger.Child("access-service"),
+		postgres:    postgres,
+		repo:        repo,
+		geoip:       geo,
+		statusCache: statusCache,
 	}
 }
 
-// CheckResourceAccess checks resource access without verifying password
+// CheckResourceAccess checks resource access without verifying password.
+// Database errors are returned as they are, not as ErrNotFound.
 func (s *Service) CheckResourceAccess(ctx context.Context, resourceKey string) (ResourceAccess, error) {
 	repoAccess, err := s.repo.CheckResourceAccess(ctx, resourceKey)
-	if err != nil {
+	if errors.Is(err, pgx.ErrNoRows) {
 		return ResourceAccess{}, ErrNotFound
 	}
+	if err != nil {
+		return ResourceAccess{}, err
+	}
 	access := repoToServiceResourceAccess(repoAccess)
 
 	// Check expiration
@@ -75,10 +104,40 @@ func (s *Service) CheckResourceAccess(ctx context.Context, resourceKey string) (
 		return ResourceAccess{}, ErrAlreadyViewed
 	}
 
+	// Check scheduled availability, access is returned so callers can show when it opens
+	if access.notYetAvailable() {
+		return access, ErrNotYetAvailable
+	}
+
 	return access, nil
 }
 
-func (s *Service) VerifyAccess(ctx context.Context, resourceKey, password string) error {
+// RequireSignedURL makes the resource open only with a valid signed URL from now on,
+// so a signed link stripped of its signature does not keep working
+func (s *Service) RequireSignedURL(ctx context.Context, resourceKey string) error {
+	ok, err := s.repo.RequireSignedURL(ctx, resourceKey)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// IsProtected reports whether downloading needs a password or access code
+func (a ResourceAccess) IsProtected() bool {
+	return (a.PasswordHash != nil && *a.PasswordHash != "") || a.HasAccessCodes
+}
+
+func (a ResourceAccess) notYetAvailable() bool {
+	return !a.AvailableAt.IsZero() && time.Now().UTC().Before(a.AvailableAt.Time)
+}
+
+// CheckAccess checks that the resource can be opened with password. For resources
+// protected by access codes password is a code, which must be unused; downloads use it
+// up together with the view, see mediaservice.Service.DownloadMedia.
+func (s *Service) CheckAccess(ctx context.Context, resourceKey, password string) error {
 	repoAccess, err := s.repo.CheckResourceAccess(ctx, resourceKey)
 	if err != nil {
 		return ErrNotFound
@@ -95,6 +154,11 @@ func (s *Service) VerifyAccess(ctx context.Context, resourceKey, password string
 		return ErrAlreadyViewed
 	}
 
+	// Check scheduled availability
+	if access.notYetAvailable() {
+		return ErrNotYetAvailable
+	}
+
 	// Verify password if required
 	if access.PasswordHash != nil {
 		if password == "" {
@@ -105,13 +169,77 @@ func (s *Service) VerifyAccess(ctx context.Context, resourceKey, password string
 		}
 	}
 
+	if err := s.checkCountry(ctx, access.AllowedCountries); err != nil {
+		return err
+	}
+
+	if access.HasAccessCodes {
+		return s.checkAccessCode(ctx, resourceKey, password)
+	}
+
 	return nil
 }
 
+// checkCountry allows the client IP stored by api.AuditContext only from allowed countries.
+// Clients without a public IP (self-hosted, local network) are let through.
+func (s *Service) checkCountry(ctx context.Context, allowed []string) error {
+	if len(allowed) == 0 {
+		return nil
+	}
+	if s.geoip == nil {
+		// Fail closed: the restriction cannot be checked
+		s.logger.WarnCtx(ctx, "resource is restricted by country but geoip is not configured")
+		return ErrCountryBlocked
+	}
+
+	ip, _ := ctx.Value(audit.ClientIPKey).(string)
+	country, err := s.geoip.LookupCountry(ip)
+	if err != nil {
+		if !errors.Is(err, geoip.ErrUnknownCountry) {
+			s.logger.WarnCtx(ctx, "failed to look up client country", zap.Error(err), zap.String("ip", ip))
+		}
+		return ErrCountryBlocked
+	}
+	if country == "" {
+		// Empty, private or loopback address
+		return nil
+	}
+	if !slices.Contains(allowed, country) {
+		return ErrCountryBlocked
+	}
+	return nil
+}
+
+func (s *Service) checkAccessCode(ctx context.Context, resourceKey, code string) error {
+	if code == "" {
+		return ErrPasswordRequired
+	}
+
+	ok, err := s.repo.IsAccessCodeUnused(ctx, resourceKey, encryption.HashAccessCode(resourceKey, code))
+	if err != nil {
+		return err
+	}
+	if ok {
+		return nil
+	}
+
+	unused, err := s.repo.CountUnusedAccessCodes(ctx, resourceKey)
+	if err != nil {
+		return err
+	}
+	if unused == 0 {
+		return ErrAlreadyViewed
+	}
+	return ErrInvalidPassword
+}
+
 var (
 	ErrNotFound         = errors.New("resource not found")
 	ErrExpired          = errors.New("resource expired")
 	ErrAlreadyViewed    = errors.New("resource already viewed")
 	ErrPasswordRequired = errors.New("password required")
 	ErrInvalidPassword  = errors.New("invalid password")
+	ErrCountryBlocked   = errors.New("access from this country is not allowed")
+	ErrNotYetAvailable  = errors.New("resource is not available yet")
+	ErrTooManyKeys      = errors.New("too many resource keys")
 )
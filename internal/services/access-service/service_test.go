@@ -0,0 +1,256 @@
+package accessservice
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	accessrepo "lovebin/internal/services/access-service/repository"
+	"lovebin/modules/audit"
+	"lovebin/modules/encryption"
+	"lovebin/modules/geoip"
+	"lovebin/modules/logger"
+	"lovebin/modules/timeparser"
+)
+
+// fakeRepo is an in-memory Repository. codes maps code hashes of each resource to
+// whether they were used
+type fakeRepo struct {
+	mu        sync.Mutex
+	resources map[string]accessrepo.ResourceAccess
+	codes     map[string]map[string]bool
+}
+
+func newFakeRepo() *fakeRepo {
+	return &fakeRepo{
+		resources: make(map[string]accessrepo.ResourceAccess),
+		codes:     make(map[string]map[string]bool),
+	}
+}
+
+// addCodes stores a resource protected by the given access codes
+func (r *fakeRepo) addCodes(resourceKey string, codes ...string) {
+	r.resources[resourceKey] = accessrepo.ResourceAccess{ResourceKey: resourceKey, HasAccessCodes: true}
+	r.codes[resourceKey] = make(map[string]bool)
+	for _, code := range codes {
+		r.codes[resourceKey][encryption.HashAccessCode(resourceKey, code)] = false
+	}
+}
+
+func (r *fakeRepo) VerifyPassword(ctx context.Context, resourceKey string) (string, error) {
+	access, err := r.CheckResourceAccess(ctx, resourceKey)
+	if err != nil || access.PasswordHash == nil {
+		return "", err
+	}
+	return *access.PasswordHash, nil
+}
+
+func (r *fakeRepo) CheckResourceAccess(_ context.Context, resourceKey string) (accessrepo.ResourceAccess, error) {
+	access, ok := r.resources[resourceKey]
+	if !ok {
+		return accessrepo.ResourceAccess{}, pgx.ErrNoRows
+	}
+	return access, nil
+}
+
+func (r *fakeRepo) IsAccessCodeUnused(_ context.Context, resourceKey, codeHash string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	used, ok := r.codes[resourceKey][codeHash]
+	return ok && !used, nil
+}
+
+func (r *fakeRepo) CountUnusedAccessCodes(_ context.Context, resourceKey string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	unused := 0
+	for _, used := range r.codes[resourceKey] {
+		if !used {
+			unused++
+		}
+	}
+	return unused, nil
+}
+
+func (r *fakeRepo) RequireSignedURL(context.Context, string) (bool, error) {
+	return false, nil
+}
+
+func (r *fakeRepo) GetResourceStatuses(context.Context, []string) ([]accessrepo.ResourceStatus, error) {
+	return nil, nil
+}
+
+func newTestService(t *testing.T, repo Repository) *Service {
+	t.Helper()
+	log, err := logger.Init(logger.Config{Level: "fatal"})
+	if err != nil {
+		t.Fatalf("logger.Init: %v", err)
+	}
+	return NewService(log, nil, repo, nil, nil)
+}
+
+// redeem marks access codes of a resource as used
+func (r *fakeRepo) redeem(resourceKey string, codes ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, code := range codes {
+		r.codes[resourceKey][encryption.HashAccessCode(resourceKey, code)] = true
+	}
+}
+
+func TestCheckAccessCode(t *testing.T) {
+	tests := []struct {
+		name    string
+		used    []string // codes redeemed before the checked one
+		code    string
+		wantErr error
+	}{
+		{"unused code", nil, "alpha", nil},
+		{"other code used", []string{"beta"}, "alpha", nil},
+		{"used code", []string{"alpha"}, "alpha", ErrInvalidPassword},
+		{"unknown code", nil, "gamma", ErrInvalidPassword},
+		{"no code", nil, "", ErrPasswordRequired},
+		{"all codes used", []string{"alpha", "beta"}, "alpha", ErrAlreadyViewed},
+		{"unknown code after all used", []string{"alpha", "beta"}, "gamma", ErrAlreadyViewed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeRepo()
+			repo.addCodes("key", "alpha", "beta")
+			repo.redeem("key", tt.used...)
+			svc := newTestService(t, repo)
+
+			if err := svc.CheckAccess(context.Background(), "key", tt.code); !errors.Is(err, tt.wantErr) {
+				t.Errorf("CheckAccess(%q) error = %v, want %v", tt.code, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckAccessDoesNotRedeemCodes(t *testing.T) {
+	repo := newFakeRepo()
+	repo.addCodes("key", "alpha")
+	svc := newTestService(t, repo)
+	ctx := context.Background()
+
+	for range 2 {
+		if err := svc.CheckAccess(ctx, "key", "alpha"); err != nil {
+			t.Fatalf("CheckAccess: %v", err)
+		}
+	}
+	if unused, _ := repo.CountUnusedAccessCodes(ctx, "key"); unused != 1 {
+		t.Errorf("unused codes after CheckAccess = %d, want 1", unused)
+	}
+}
+
+func TestAccessCodesAreBoundToTheResource(t *testing.T) {
+	repo := newFakeRepo()
+	repo.addCodes("first", "alpha")
+	repo.addCodes("second", "beta")
+	svc := newTestService(t, repo)
+
+	if err := svc.CheckAccess(context.Background(), "second", "alpha"); !errors.Is(err, ErrInvalidPassword) {
+		t.Errorf("CheckAccess with a code of another resource error = %v, want ErrInvalidPassword", err)
+	}
+}
+
+func TestCheckAccessCountry(t *testing.T) {
+	geo := geoip.NewMockGeoIP(map[string]string{"8.8.8.8": "US", "81.2.69.142": "GB"})
+
+	tests := []struct {
+		name    string
+		allowed []string
+		geo     geoip.GeoIP
+		ip      string
+		wantErr error
+	}{
+		{"unrestricted", nil, nil, "8.8.8.8", nil},
+		{"allowed country", []string{"GB", "US"}, geo, "8.8.8.8", nil},
+		{"blocked country", []string{"GB"}, geo, "8.8.8.8", ErrCountryBlocked},
+		{"unknown country", []string{"GB"}, geo, "1.1.1.1", ErrCountryBlocked},
+		{"invalid IP", []string{"GB"}, geo, "not an ip", ErrCountryBlocked},
+		{"private IP", []string{"GB"}, geo, "192.168.1.10", nil},
+		{"no IP", []string{"GB"}, geo, "", nil},
+		{"geoip not configured", []string{"GB"}, nil, "81.2.69.142", ErrCountryBlocked},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeRepo()
+			repo.resources["key"] = accessrepo.ResourceAccess{ResourceKey: "key", AllowedCountries: tt.allowed}
+			svc := newTestService(t, repo)
+			svc.geoip = tt.geo
+
+			ctx := context.WithValue(context.Background(), audit.ClientIPKey, tt.ip)
+			if err := svc.CheckAccess(ctx, "key", ""); !errors.Is(err, tt.wantErr) {
+				t.Errorf("CheckAccess from %q error = %v, want %v", tt.ip, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestScheduledAvailability(t *testing.T) {
+	tests := []struct {
+		name        string
+		availableAt time.Time
+		wantErr     error
+	}{
+		{"immediately", time.Time{}, nil},
+		{"already open", time.Now().Add(-time.Minute), nil},
+		{"scheduled", time.Now().Add(time.Hour), ErrNotYetAvailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeRepo()
+			repo.resources["key"] = accessrepo.ResourceAccess{ResourceKey: "key", AvailableAt: timeparser.UniversalTime{Time: tt.availableAt}}
+			svc := newTestService(t, repo)
+			ctx := context.Background()
+
+			access, err := svc.CheckResourceAccess(ctx, "key")
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("CheckResourceAccess error = %v, want %v", err, tt.wantErr)
+			}
+			// The opening time is returned for the countdown
+			if !access.AvailableAt.Time.Equal(tt.availableAt) {
+				t.Errorf("AvailableAt = %v, want %v", access.AvailableAt, tt.availableAt)
+			}
+			if err := svc.CheckAccess(ctx, "key", ""); !errors.Is(err, tt.wantErr) {
+				t.Errorf("CheckAccess error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestScheduledAvailabilityOpens(t *testing.T) {
+	repo := newFakeRepo()
+	repo.resources["key"] = accessrepo.ResourceAccess{
+		ResourceKey: "key",
+		AvailableAt: timeparser.UniversalTime{Time: time.Now().Add(100 * time.Millisecond)},
+	}
+	svc := newTestService(t, repo)
+	ctx := context.Background()
+
+	if err := svc.CheckAccess(ctx, "key", ""); !errors.Is(err, ErrNotYetAvailable) {
+		t.Fatalf("CheckAccess before the reveal error = %v, want ErrNotYetAvailable", err)
+	}
+	time.Sleep(150 * time.Millisecond)
+	if err := svc.CheckAccess(ctx, "key", ""); err != nil {
+		t.Errorf("CheckAccess after the reveal: %v", err)
+	}
+}
+
+func TestScheduledAvailabilityChecksExpiryFirst(t *testing.T) {
+	repo := newFakeRepo()
+	repo.resources["key"] = accessrepo.ResourceAccess{
+		ResourceKey: "key",
+		ExpiresAt:   timeparser.UniversalTime{Time: time.Now().Add(-time.Minute)},
+		AvailableAt: timeparser.UniversalTime{Time: time.Now().Add(time.Hour)},
+	}
+	svc := newTestService(t, repo)
+	if err := svc.CheckAccess(context.Background(), "key", ""); !errors.Is(err, ErrExpired) {
+		t.Errorf("CheckAccess error = %v, want ErrExpired", err)
+	}
+}
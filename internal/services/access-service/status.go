@@ -0,0 +1,128 @@
+package accessservice
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	accessrepo "lovebin/internal/services/access-service/repository"
+	"lovebin/modules/timeparser"
+)
+
+// MaxBulkCheckKeys is the maximum number of keys accepted by BulkCheckResourceAccess
+const MaxBulkCheckKeys = 50
+
+// statusCacheTTL is how long the status of a key stays cached
+const statusCacheTTL = 5 * time.Second
+
+// ResourceStatus is the public access state of a resource. Unknown and deleted
+// resources are both inactive with no other details.
+type ResourceStatus struct {
+	Active          bool // can be opened now
+	Viewed          bool
+	Expired         bool
+	NotYetAvailable bool
+	ExpiresAt       *timeparser.UniversalTime
+	AvailableAt     *timeparser.UniversalTime // set while not yet available
+}
+
+// BulkCheckResourceAccess returns the access state of each key. Keys may be full
+// resource keys with the "#enc_key" fragment. States are cached per resource for a
+// few seconds; the others are loaded with a single query.
+func (s *Service) BulkCheckResourceAccess(ctx context.Context, keys []string) (map[string]ResourceStatus, error) {
+	if len(keys) > MaxBulkCheckKeys {
+		return nil, ErrTooManyKeys
+	}
+
+	statuses := make(map[string]ResourceStatus, len(keys))
+	found := make(map[string]ResourceStatus, len(keys))
+	var missing []string
+	for _, key := range keys {
+		resourceKey, _, _ := strings.Cut(key, "#")
+		if _, ok := found[resourceKey]; ok || slices.Contains(missing, resourceKey) {
+			continue
+		}
+		if status, ok := s.cachedStatus(ctx, resourceKey); ok {
+			found[resourceKey] = status
+			continue
+		}
+		missing = append(missing, resourceKey)
+	}
+
+	if len(missing) > 0 {
+		rows, err := s.repo.GetResourceStatuses(ctx, missing)
+		if err != nil {
+			return nil, err
+		}
+		now := time.Now().UTC()
+		for _, row := range rows {
+			found[row.ResourceKey] = toResourceStatus(row, now)
+		}
+		for _, resourceKey := range missing {
+			// Keys without a record are cached too, as the zero value
+			s.cacheStatus(ctx, resourceKey, found[resourceKey], now)
+		}
+	}
+
+	for _, key := range keys {
+		resourceKey, _, _ := strings.Cut(key, "#")
+		statuses[key] = found[resourceKey]
+	}
+	return statuses, nil
+}
+
+func toResourceStatus(row accessrepo.ResourceStatus, now time.Time) ResourceStatus {
+	status := ResourceStatus{Viewed: row.Viewed}
+	if !row.ExpiresAt.IsZero() {
+		expiresAt := row.ExpiresAt
+		status.ExpiresAt = &expiresAt
+		status.Expired = !row.ExpiresAt.After(now)
+	}
+	if !row.AvailableAt.IsZero() && now.Before(row.AvailableAt.Time) {
+		availableAt := row.AvailableAt
+		status.AvailableAt = &availableAt
+		status.NotYetAvailable = true
+	}
+	status.Active = !status.Viewed && !status.Expired && !status.NotYetAvailable
+	return status
+}
+
+// cachedStatus returns the cached state of a resource, cache failures count as misses
+func (s *Service) cachedStatus(ctx context.Context, resourceKey string) (ResourceStatus, bool) {
+	if s.statusCache == nil {
+		return ResourceStatus{}, false
+	}
+	status, ok, err := s.statusCache.Get(ctx, statusCacheKey(resourceKey))
+	if err != nil {
+		s.logger.WarnCtx(ctx, "failed to read resource status from cache", zap.Error(err), zap.String("resource_key", resourceKey))
+		return ResourceStatus{}, false
+	}
+	return status, ok
+}
+
+// cacheStatus caches the state of a resource, never past the moment it expires or opens
+func (s *Service) cacheStatus(ctx context.Context, resourceKey string, status ResourceStatus, now time.Time) {
+	if s.statusCache == nil {
+		return
+	}
+	ttl := statusCacheTTL
+	if status.ExpiresAt != nil && !status.Expired {
+		ttl = min(ttl, status.ExpiresAt.Sub(now))
+	}
+	if status.AvailableAt != nil {
+		ttl = min(ttl, status.AvailableAt.Sub(now))
+	}
+	if ttl <= 0 {
+		return
+	}
+	if err := s.statusCache.Set(ctx, statusCacheKey(resourceKey), status, ttl); err != nil {
+		s.logger.WarnCtx(ctx, "failed to write resource status to cache", zap.Error(err), zap.String("resource_key", resourceKey))
+	}
+}
+
+func statusCacheKey(resourceKey string) string {
+	return "access:status:" + resourceKey
+}
@@ -0,0 +1,249 @@
+package accessservice
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	accessrepo "lovebin/internal/services/access-service/repository"
+	"lovebin/modules/cache"
+	"lovebin/modules/logger"
+	"lovebin/modules/timeparser"
+)
+
+// statusRepo serves resource statuses and records the keys of each load
+type statusRepo struct {
+	*fakeRepo
+	statuses map[string]accessrepo.ResourceStatus
+	loads    [][]string
+	err      error
+}
+
+func (r *statusRepo) GetResourceStatuses(_ context.Context, keys []string) ([]accessrepo.ResourceStatus, error) {
+	r.loads = append(r.loads, slices.Clone(keys))
+	if r.err != nil {
+		return nil, r.err
+	}
+	var rows []accessrepo.ResourceStatus
+	for _, key := range keys {
+		if row, ok := r.statuses[key]; ok {
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+// ttlCache is an in-memory status cache that records the TTL of each entry
+type ttlCache struct {
+	entries map[string]ResourceStatus
+	ttls    map[string]time.Duration
+	err     error
+}
+
+func newTTLCache() *ttlCache {
+	return &ttlCache{entries: make(map[string]ResourceStatus), ttls: make(map[string]time.Duration)}
+}
+
+func (c *ttlCache) Get(_ context.Context, key string) (ResourceStatus, bool, error) {
+	if c.err != nil {
+		return ResourceStatus{}, false, c.err
+	}
+	status, ok := c.entries[key]
+	return status, ok, nil
+}
+
+func (c *ttlCache) Set(_ context.Context, key string, status ResourceStatus, ttl time.Duration) error {
+	if c.err != nil {
+		return c.err
+	}
+	c.entries[key], c.ttls[key] = status, ttl
+	return nil
+}
+
+func (c *ttlCache) Delete(_ context.Context, key string) error {
+	delete(c.entries, key)
+	delete(c.ttls, key)
+	return nil
+}
+
+func newStatusService(t *testing.T, repo Repository, statusCache cache.Cache[string, ResourceStatus]) *Service {
+	t.Helper()
+	log, err := logger.Init(logger.Config{Level: "fatal"})
+	if err != nil {
+		t.Fatalf("logger.Init: %v", err)
+	}
+	return NewService(log, nil, repo, nil, statusCache)
+}
+
+func TestToResourceStatus(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) timeparser.UniversalTime { return timeparser.NewUniversalTime(now.Add(d)) }
+
+	tests := []struct {
+		name            string
+		row             accessrepo.ResourceStatus
+		active          bool
+		viewed          bool
+		expired         bool
+		notYetAvailable bool
+		hasExpiry       bool
+		hasAvailability bool
+	}{
+		{"open", accessrepo.ResourceStatus{}, true, false, false, false, false, false},
+		{"expires later", accessrepo.ResourceStatus{ExpiresAt: at(time.Hour)}, true, false, false, false, true, false},
+		{"viewed", accessrepo.ResourceStatus{Viewed: true}, false, true, false, false, false, false},
+		{"expired", accessrepo.ResourceStatus{ExpiresAt: at(-time.Hour)}, false, false, true, false, true, false},
+		{"expires now", accessrepo.ResourceStatus{ExpiresAt: at(0)}, false, false, true, false, true, false},
+		{"opens later", accessrepo.ResourceStatus{AvailableAt: at(time.Hour)}, false, false, false, true, false, true},
+		{"opened", accessrepo.ResourceStatus{AvailableAt: at(-time.Hour)}, true, false, false, false, false, false},
+		{"opens now", accessrepo.ResourceStatus{AvailableAt: at(0)}, true, false, false, false, false, false},
+		{"viewed and expired", accessrepo.ResourceStatus{Viewed: true, ExpiresAt: at(-time.Hour)}, false, true, true, false, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := toResourceStatus(tt.row, now)
+			if got.Active != tt.active || got.Viewed != tt.viewed || got.Expired != tt.expired || got.NotYetAvailable != tt.notYetAvailable {
+				t.Errorf("toResourceStatus = %+v", got)
+			}
+			if (got.ExpiresAt != nil) != tt.hasExpiry || (got.AvailableAt != nil) != tt.hasAvailability {
+				t.Errorf("ExpiresAt = %v, AvailableAt = %v, want set %v and %v", got.ExpiresAt, got.AvailableAt, tt.hasExpiry, tt.hasAvailability)
+			}
+		})
+	}
+}
+
+func TestBulkCheckResourceAccess(t *testing.T) {
+	repo := &statusRepo{fakeRepo: newFakeRepo(), statuses: map[string]accessrepo.ResourceStatus{
+		"active": {ResourceKey: "active"},
+		"viewed": {ResourceKey: "viewed", Viewed: true},
+	}}
+	svc := newStatusService(t, repo, nil)
+
+	statuses, err := svc.BulkCheckResourceAccess(context.Background(), []string{"active#key", "active", "viewed", "unknown", "viewed#other"})
+	if err != nil {
+		t.Fatalf("BulkCheckResourceAccess: %v", err)
+	}
+	if len(repo.loads) != 1 || !slices.Equal(repo.loads[0], []string{"active", "viewed", "unknown"}) {
+		t.Errorf("statuses loaded for %q, want once for each resource", repo.loads)
+	}
+
+	want := map[string]ResourceStatus{
+		"active#key":   {Active: true},
+		"active":       {Active: true},
+		"viewed":       {Viewed: true},
+		"viewed#other": {Viewed: true},
+		"unknown":      {},
+	}
+	if len(statuses) != len(want) {
+		t.Errorf("BulkCheckResourceAccess returned %d statuses, want %d", len(statuses), len(want))
+	}
+	for key, status := range want {
+		if got, ok := statuses[key]; !ok || got != status {
+			t.Errorf("status of %q = %+v, want %+v", key, got, status)
+		}
+	}
+
+	// Without a cache every call loads the statuses again
+	if _, err := svc.BulkCheckResourceAccess(context.Background(), []string{"active"}); err != nil {
+		t.Fatalf("BulkCheckResourceAccess: %v", err)
+	}
+	if len(repo.loads) != 2 {
+		t.Errorf("statuses loaded %d times, want 2", len(repo.loads))
+	}
+}
+
+func TestBulkCheckResourceAccessErrors(t *testing.T) {
+	repo := &statusRepo{fakeRepo: newFakeRepo()}
+	svc := newStatusService(t, repo, nil)
+
+	tooMany := make([]string, MaxBulkCheckKeys+1)
+	if _, err := svc.BulkCheckResourceAccess(context.Background(), tooMany); !errors.Is(err, ErrTooManyKeys) {
+		t.Errorf("BulkCheckResourceAccess of %d keys = %v, want %v", len(tooMany), err, ErrTooManyKeys)
+	}
+	if len(repo.loads) != 0 {
+		t.Error("too many keys loaded statuses")
+	}
+
+	repo.err = errors.New("connection reset")
+	if _, err := svc.BulkCheckResourceAccess(context.Background(), []string{"key"}); !errors.Is(err, repo.err) {
+		t.Errorf("BulkCheckResourceAccess on repository failure = %v, want %v", err, repo.err)
+	}
+}
+
+func TestBulkCheckResourceAccessCache(t *testing.T) {
+	now := time.Now().UTC()
+	repo := &statusRepo{fakeRepo: newFakeRepo(), statuses: map[string]accessrepo.ResourceStatus{
+		"active":      {ResourceKey: "active"},
+		"expiring":    {ResourceKey: "expiring", ExpiresAt: timeparser.NewUniversalTime(now.Add(2 * time.Second))},
+		"opening":     {ResourceKey: "opening", AvailableAt: timeparser.NewUniversalTime(now.Add(time.Second))},
+		"expired":     {ResourceKey: "expired", ExpiresAt: timeparser.NewUniversalTime(now.Add(-time.Hour))},
+		"long expiry": {ResourceKey: "long expiry", ExpiresAt: timeparser.NewUniversalTime(now.Add(time.Hour))},
+	}}
+	statusCache := newTTLCache()
+	svc := newStatusService(t, repo, statusCache)
+
+	keys := []string{"active", "expiring", "opening", "expired", "long expiry", "unknown"}
+	if _, err := svc.BulkCheckResourceAccess(context.Background(), keys); err != nil {
+		t.Fatalf("BulkCheckResourceAccess: %v", err)
+	}
+
+	tests := []struct {
+		key    string
+		maxTTL time.Duration
+	}{
+		{"active", statusCacheTTL},
+		{"expiring", 2 * time.Second},
+		{"opening", time.Second},
+		{"expired", statusCacheTTL},
+		{"long expiry", statusCacheTTL},
+		{"unknown", statusCacheTTL},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			ttl, ok := statusCache.ttls[statusCacheKey(tt.key)]
+			if !ok {
+				t.Fatal("status was not cached")
+			}
+			if ttl <= 0 || ttl > tt.maxTTL || (tt.maxTTL == statusCacheTTL && ttl != statusCacheTTL) {
+				t.Errorf("cached for %v, want at most %v", ttl, tt.maxTTL)
+			}
+		})
+	}
+
+	// Cached statuses, unknown keys included, are not loaded again
+	statuses, err := svc.BulkCheckResourceAccess(context.Background(), []string{"active", "unknown", "new"})
+	if err != nil {
+		t.Fatalf("BulkCheckResourceAccess: %v", err)
+	}
+	if len(repo.loads) != 2 || !slices.Equal(repo.loads[1], []string{"new"}) {
+		t.Errorf("statuses loaded for %q, want only the uncached key", repo.loads)
+	}
+	if !statuses["active"].Active || statuses["unknown"].Active {
+		t.Errorf("cached statuses = %+v", statuses)
+	}
+}
+
+func TestBulkCheckResourceAccessCacheFailure(t *testing.T) {
+	repo := &statusRepo{fakeRepo: newFakeRepo(), statuses: map[string]accessrepo.ResourceStatus{
+		"active": {ResourceKey: "active"},
+	}}
+	statusCache := newTTLCache()
+	statusCache.err = errors.New("memcached unreachable")
+	svc := newStatusService(t, repo, statusCache)
+
+	// A failing cache counts as a miss, the statuses still come from the database
+	for range 2 {
+		statuses, err := svc.BulkCheckResourceAccess(context.Background(), []string{"active"})
+		if err != nil {
+			t.Fatalf("BulkCheckResourceAccess: %v", err)
+		}
+		if !statuses["active"].Active {
+			t.Errorf("status = %+v, want active", statuses["active"])
+		}
+	}
+	if len(repo.loads) != 2 {
+		t.Errorf("statuses loaded %d times, want on every call", len(repo.loads))
+	}
+}
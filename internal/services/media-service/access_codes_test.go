@@ -0,0 +1,205 @@
+package mediaservice
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"lovebin/modules/encryption"
+)
+
+func TestNormalizeAccessCodes(t *testing.T) {
+	manyCodes := func(n int) []string {
+		codes := make([]string, n)
+		for i := range codes {
+			codes[i] = fmt.Sprintf("code-%d", i)
+		}
+		return codes
+	}
+
+	tests := []struct {
+		name     string
+		codes    []string
+		password string
+		want     []string
+		wantErr  error
+	}{
+		{"none", nil, "", []string{}, nil},
+		{"trimmed", []string{" alpha ", "beta"}, "", []string{"alpha", "beta"}, nil},
+		{"blank dropped", []string{"alpha", "  ", ""}, "", []string{"alpha"}, nil},
+		{"duplicate", []string{"alpha", "beta", "alpha"}, "", nil, ErrDuplicateAccessCode},
+		{"duplicate after trimming", []string{"alpha", "alpha "}, "", nil, ErrDuplicateAccessCode},
+		{"maximum", manyCodes(MaxAccessCodes), "", manyCodes(MaxAccessCodes), nil},
+		{"too many", manyCodes(MaxAccessCodes + 1), "", nil, ErrTooManyAccessCodes},
+		{"with password", []string{"alpha"}, "secret", nil, ErrAccessCodesWithPassword},
+		{"password without codes", nil, "secret", []string{}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeAccessCodes(tt.codes, tt.password)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("normalizeAccessCodes error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("normalizeAccessCodes = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUploadMediaAccessCodes(t *testing.T) {
+	svc := newTestService(t, Config{})
+	resp, err := svc.UploadMedia(context.Background(), UploadRequest{
+		Data:        bytes.NewReader([]byte("ticket")),
+		Size:        6,
+		AccessCodes: []string{" alpha", "beta "},
+	})
+	if err != nil {
+		t.Fatalf("UploadMedia: %v", err)
+	}
+	if !reflect.DeepEqual(resp.AccessCodes, []string{"alpha", "beta"}) {
+		t.Errorf("AccessCodes = %q, want the trimmed codes", resp.AccessCodes)
+	}
+
+	// Only the hashes are stored
+	resourceKey, _, _ := strings.Cut(resp.ResourceKey, "#")
+	want := []string{encryption.HashAccessCode(resourceKey, "alpha"), encryption.HashAccessCode(resourceKey, "beta")}
+	if got := svc.repo.accessCodes[resourceKey]; !reflect.DeepEqual(got, want) {
+		t.Errorf("stored codes = %q, want their hashes", got)
+	}
+}
+
+func TestUploadMediaWithoutAccessCodes(t *testing.T) {
+	svc := newTestService(t, Config{})
+	resp, err := svc.UploadMedia(context.Background(), UploadRequest{Data: bytes.NewReader([]byte("data")), Size: 4})
+	if err != nil {
+		t.Fatalf("UploadMedia: %v", err)
+	}
+	if resp.AccessCodes != nil {
+		t.Errorf("AccessCodes = %q, want none", resp.AccessCodes)
+	}
+	if n := len(svc.repo.accessCodes); n != 0 {
+		t.Errorf("%d resources have access codes, want 0", n)
+	}
+}
+
+func TestUploadMediaRejectsAccessCodes(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     UploadRequest
+		wantErr error
+	}{
+		{"duplicate", UploadRequest{AccessCodes: []string{"alpha", "alpha"}}, ErrDuplicateAccessCode},
+		{"with password", UploadRequest{AccessCodes: []string{"alpha"}, Password: "correct horse battery staple"}, ErrAccessCodesWithPassword},
+		{"with max views", UploadRequest{AccessCodes: []string{"alpha"}, MaxViews: 3}, ErrMaxViewsWithAccessCodes},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestService(t, Config{})
+			tt.req.Data = bytes.NewReader([]byte("data"))
+			tt.req.Size = 4
+			if _, err := svc.UploadMedia(context.Background(), tt.req); !errors.Is(err, tt.wantErr) {
+				t.Errorf("UploadMedia error = %v, want %v", err, tt.wantErr)
+			}
+			if n := len(svc.repo.accessCodes); n != 0 {
+				t.Errorf("%d resources have access codes after a rejected upload", n)
+			}
+		})
+	}
+}
+
+func TestDownloadMediaRedeemsAccessCodes(t *testing.T) {
+	svc := newTestService(t, Config{})
+	ctx := context.Background()
+	resp, err := svc.UploadMedia(ctx, UploadRequest{
+		Data:        bytes.NewReader([]byte("ticket")),
+		Size:        6,
+		AccessCodes: []string{"alpha", "beta"},
+	})
+	if err != nil {
+		t.Fatalf("UploadMedia: %v", err)
+	}
+	resourceKey, encKey, _ := strings.Cut(resp.ResourceKey, "#")
+	download := func(code string) (*DownloadResponse, error) {
+		return svc.DownloadMedia(ctx, &DownloadRequest{ResourceKey: resourceKey, Password: code, EncKeyBase64: encKey})
+	}
+	unused := func() int {
+		t.Helper()
+		count, err := svc.repo.CountUnusedAccessCodes(ctx, resourceKey)
+		if err != nil {
+			t.Fatalf("CountUnusedAccessCodes: %v", err)
+		}
+		return count
+	}
+
+	// The code is only used up with the view, a failed download leaves it
+	svc.storage.SetError("Download", errors.New("storage unavailable"))
+	if _, err := download("alpha"); err == nil {
+		t.Fatal("download succeeded without the object")
+	}
+	svc.storage.SetError("Download", nil)
+	if got := unused(); got != 2 {
+		t.Errorf("unused codes after a failed download = %d, want 2", got)
+	}
+
+	if _, err := download("gamma"); !errors.Is(err, ErrInvalidPassword) {
+		t.Errorf("download with an unknown code error = %v, want ErrInvalidPassword", err)
+	}
+	if got, err := download("alpha"); err != nil || got.ViewsRemaining != 1 {
+		t.Fatalf("download with alpha = %+v, %v, want one view left", got, err)
+	}
+	if _, err := download("alpha"); !errors.Is(err, ErrInvalidPassword) {
+		t.Errorf("second download with alpha error = %v, want ErrInvalidPassword", err)
+	}
+	if got, err := download("beta"); err != nil || got.ViewsRemaining != 0 {
+		t.Fatalf("download with beta = %+v, %v, want no views left", got, err)
+	}
+	if _, err := download("beta"); !errors.Is(err, ErrAlreadyViewed) {
+		t.Errorf("download after every code was used error = %v, want ErrAlreadyViewed", err)
+	}
+}
+
+func TestDownloadMediaConcurrentCodeRedemption(t *testing.T) {
+	svc := newTestService(t, Config{})
+	ctx := context.Background()
+	resp, err := svc.UploadMedia(ctx, UploadRequest{
+		Data:        bytes.NewReader([]byte("ticket")),
+		Size:        6,
+		AccessCodes: []string{"alpha", "beta"},
+	})
+	if err != nil {
+		t.Fatalf("UploadMedia: %v", err)
+	}
+	resourceKey, encKey, _ := strings.Cut(resp.ResourceKey, "#")
+
+	const callers = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := svc.DownloadMedia(ctx, &DownloadRequest{ResourceKey: resourceKey, Password: "alpha", EncKeyBase64: encKey})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	redeemed := 0
+	for err := range errs {
+		if err == nil {
+			redeemed++
+		} else if !errors.Is(err, ErrInvalidPassword) {
+			t.Errorf("DownloadMedia error = %v, want ErrInvalidPassword", err)
+		}
+	}
+	if redeemed != 1 {
+		t.Errorf("code redeemed %d times, want 1", redeemed)
+	}
+}
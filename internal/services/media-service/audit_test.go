@@ -0,0 +1,83 @@
+package mediaservice
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+
+	"lovebin/modules/audit"
+)
+
+// recordingAudit keeps the entries written to it
+type recordingAudit struct {
+	mu      sync.Mutex
+	entries []audit.AuditEntry
+}
+
+func (r *recordingAudit) Write(_ context.Context, entry audit.AuditEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, entry)
+	return nil
+}
+
+func (r *recordingAudit) operations(resourceKey string) []audit.Operation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var ops []audit.Operation
+	for _, entry := range r.entries {
+		if entry.ResourceKey == resourceKey {
+			ops = append(ops, entry.Operation)
+		}
+	}
+	return ops
+}
+
+func TestServiceRecordsAudit(t *testing.T) {
+	svc := newTestService(t, Config{})
+	trail := &recordingAudit{}
+	svc.Service.audit = trail
+
+	resourceKey, encKey := svc.upload(t, UploadRequest{Data: bytes.NewReader([]byte("content")), Password: "mzkqTW7!pLx9", MaxViews: 2})
+	if _, err := download(t, svc, resourceKey, encKey, "mzkqTW7!pLx9"); err != nil {
+		t.Fatalf("download: %v", err)
+	}
+	putOrphan(t, svc, "orphan")
+	if err := svc.OrphanCleanup(context.Background()); err != nil {
+		t.Fatalf("OrphanCleanup: %v", err)
+	}
+
+	tests := []struct {
+		resourceKey string
+		want        []audit.Operation
+	}{
+		{resourceKey, []audit.Operation{audit.OpUpload, audit.OpDownload}},
+		{"orphan", []audit.Operation{audit.OpOrphanCleanup}},
+	}
+	for _, tt := range tests {
+		got := trail.operations(tt.resourceKey)
+		if len(got) != len(tt.want) {
+			t.Errorf("operations on %s = %v, want %v", tt.resourceKey, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("operations on %s = %v, want %v", tt.resourceKey, got, tt.want)
+				break
+			}
+		}
+	}
+
+	// Details never carry the password or the URL key
+	for _, entry := range trail.entries {
+		for key, value := range entry.Details {
+			if s, ok := value.(string); ok && (s == "mzkqTW7!pLx9" || s == encKey) {
+				t.Errorf("%s entry detail %s holds a secret", entry.Operation, key)
+			}
+		}
+	}
+	if upload := trail.entries[0]; upload.Details["password_protected"] != true || upload.Details["max_views"] != 2 {
+		t.Errorf("upload details = %v", upload.Details)
+	}
+}
@@ -0,0 +1,51 @@
+package mediaservice
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestUploadMediaBlurIntensity(t *testing.T) {
+	tests := []struct {
+		name      string
+		intensity float64
+		wantErr   error
+	}{
+		{"no blur", 0, nil},
+		{"half", 0.5, nil},
+		{"maximum", 1, nil},
+		{"negative", -0.1, ErrInvalidBlurIntensity},
+		{"above maximum", 1.01, ErrInvalidBlurIntensity},
+		{"NaN", math.NaN(), ErrInvalidBlurIntensity},
+		{"infinity", math.Inf(1), ErrInvalidBlurIntensity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestService(t, Config{})
+			ctx := context.Background()
+			resp, err := svc.UploadMedia(ctx, UploadRequest{Data: bytes.NewReader([]byte("data")), Size: 4, BlurIntensity: tt.intensity})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UploadMedia error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if n := len(svc.repo.resources); n != 0 {
+					t.Errorf("%d resources stored after a rejected upload", n)
+				}
+				return
+			}
+
+			resourceKey, _, _ := strings.Cut(resp.ResourceKey, "#")
+			info, err := svc.GetMediaInfo(ctx, resourceKey, "")
+			if err != nil {
+				t.Fatalf("GetMediaInfo: %v", err)
+			}
+			if info.BlurIntensity != tt.intensity {
+				t.Errorf("BlurIntensity = %v, want %v", info.BlurIntensity, tt.intensity)
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package mediaservice
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	mediarepo "lovebin/internal/services/media-service/repository"
+	"lovebin/modules/s3"
+	"lovebin/modules/timeparser"
+)
+
+// failingLookupRepo fails the lookups of one resource key
+type failingLookupRepo struct {
+	*MockRepository
+	key string
+}
+
+func (r *failingLookupRepo) GetMediaResourceByKeyAny(ctx context.Context, resourceKey string) (mediarepo.MediaResourceResult, error) {
+	if resourceKey == r.key {
+		return mediarepo.MediaResourceResult{}, errors.New("connection reset")
+	}
+	return r.MockRepository.GetMediaResourceByKeyAny(ctx, resourceKey)
+}
+
+// putOrphan stores an object and a manifest for a resource without a record
+func putOrphan(t *testing.T, svc *testService, resourceKey string) {
+	t.Helper()
+	ctx := context.Background()
+	if _, err := svc.storage.Upload(ctx, "", "media/"+resourceKey, bytes.NewReader([]byte("orphan")), ""); err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	if err := s3.UploadManifest(ctx, svc.storage, resourceKey, []byte("manifest")); err != nil {
+		t.Fatalf("UploadManifest: %v", err)
+	}
+}
+
+func TestOrphanCleanup(t *testing.T) {
+	svc := newTestService(t, Config{})
+	kept, _ := svc.upload(t, UploadRequest{Data: bytes.NewReader([]byte("data"))})
+	putOrphan(t, svc, "orphan1")
+	putOrphan(t, svc, "orphan2")
+	putOrphan(t, svc, "unknown")
+	svc.Service.repo = &failingLookupRepo{MockRepository: svc.repo, key: "unknown"}
+
+	if err := svc.OrphanCleanup(context.Background()); err != nil {
+		t.Fatalf("OrphanCleanup: %v", err)
+	}
+
+	tests := []struct {
+		resourceKey string
+		wantKept    bool
+	}{
+		{kept, true},
+		{"orphan1", false},
+		{"orphan2", false},
+		// A failed lookup leaves the object alone
+		{"unknown", true},
+	}
+	for _, tt := range tests {
+		if _, ok := svc.storage.Object("", "media/"+tt.resourceKey); ok != tt.wantKept {
+			t.Errorf("object %s kept = %v, want %v", tt.resourceKey, ok, tt.wantKept)
+		}
+		if _, ok := svc.storage.Object("", s3.ManifestKey(tt.resourceKey)); ok != tt.wantKept {
+			t.Errorf("manifest of %s kept = %v, want %v", tt.resourceKey, ok, tt.wantKept)
+		}
+	}
+}
+
+func TestOrphanCleanupErrors(t *testing.T) {
+	t.Run("list fails", func(t *testing.T) {
+		svc := newTestService(t, Config{})
+		listErr := errors.New("list failed")
+		svc.storage.SetError("ListObjects", listErr)
+		if err := svc.OrphanCleanup(context.Background()); !errors.Is(err, listErr) {
+			t.Errorf("OrphanCleanup error = %v, want %v", err, listErr)
+		}
+	})
+
+	t.Run("delete fails", func(t *testing.T) {
+		svc := newTestService(t, Config{})
+		putOrphan(t, svc, "orphan")
+		svc.storage.SetError("Delete", errors.New("delete failed"))
+		// A failed delete is retried by the next run, the cleanup itself succeeds
+		if err := svc.OrphanCleanup(context.Background()); err != nil {
+			t.Errorf("OrphanCleanup: %v", err)
+		}
+		if _, ok := svc.storage.Object("", "media/orphan"); !ok {
+			t.Error("object gone although the delete failed")
+		}
+	})
+}
+
+func TestCleanupExpiredResources(t *testing.T) {
+	svc := newTestService(t, Config{PresignTTL: time.Minute})
+	ctx := context.Background()
+
+	active, _ := svc.upload(t, UploadRequest{Data: bytes.NewReader([]byte("active"))})
+	expired, _ := svc.upload(t, UploadRequest{Data: bytes.NewReader([]byte("expired"))})
+	past := time.Now().Add(-time.Minute)
+	svc.repo.update(expired, func(resource *mediarepo.MediaResourceResult) { resource.ExpiresAt = &past })
+	viewed, viewedKey := svc.upload(t, UploadRequest{Data: bytes.NewReader([]byte("viewed"))})
+	if _, err := download(t, svc, viewed, viewedKey, ""); err != nil {
+		t.Fatalf("download: %v", err)
+	}
+	// A browser may still be fetching the object of a presigned download
+	presigned, presignedKey := svc.upload(t, UploadRequest{Data: io.MultiReader(strings.NewReader("presigned"))})
+	if _, err := svc.PresignDownload(ctx, &DownloadRequest{ResourceKey: presigned, EncKeyBase64: presignedKey}); err != nil {
+		t.Fatalf("PresignDownload: %v", err)
+	}
+
+	if _, ok := svc.LastCleanup(); ok {
+		t.Error("LastCleanup reports a run before any cleanup")
+	}
+	result, err := svc.CleanupExpiredResources(ctx)
+	if err != nil {
+		t.Fatalf("CleanupExpiredResources: %v", err)
+	}
+	if result != (CleanupResult{DeletedExpired: 1, DeletedViewed: 1}) {
+		t.Errorf("CleanupExpiredResources = %+v, want one expired and one viewed", result)
+	}
+
+	tests := []struct {
+		name        string
+		resourceKey string
+		wantKept    bool
+	}{
+		{"active", active, true},
+		{"expired", expired, false},
+		{"viewed", viewed, false},
+		{"presigned", presigned, true},
+	}
+	for _, tt := range tests {
+		if _, ok := svc.repo.resource(tt.resourceKey); ok != tt.wantKept {
+			t.Errorf("%s record kept = %v, want %v", tt.name, ok, tt.wantKept)
+		}
+		if _, ok := svc.storage.Object("", "media/"+tt.resourceKey); ok != tt.wantKept {
+			t.Errorf("%s object kept = %v, want %v", tt.name, ok, tt.wantKept)
+		}
+	}
+
+	run, ok := svc.LastCleanup()
+	if !ok {
+		t.Fatal("LastCleanup reports no run")
+	}
+	if run.Result != result || run.Err != nil || run.FinishedAt.Before(run.StartedAt) {
+		t.Errorf("LastCleanup = %+v, want the finished run", run)
+	}
+}
+
+func TestCleanupExpiredResourcesWindow(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name    string
+		window  timeparser.Interval
+		wantErr error
+	}{
+		{"no window", timeparser.Interval{}, nil},
+		{"inside", timeparser.Interval{Start: timeparser.NewUniversalTime(now.Add(-time.Hour)), End: timeparser.NewUniversalTime(now.Add(time.Hour))}, nil},
+		{"before", timeparser.Interval{Start: timeparser.NewUniversalTime(now.Add(time.Hour)), End: timeparser.NewUniversalTime(now.Add(2 * time.Hour))}, ErrOutsideCleanupWindow},
+		{"after", timeparser.Interval{Start: timeparser.NewUniversalTime(now.Add(-2 * time.Hour)), End: timeparser.NewUniversalTime(now.Add(-time.Hour))}, ErrOutsideCleanupWindow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestService(t, Config{CleanupWindow: tt.window})
+			resourceKey, _ := svc.upload(t, UploadRequest{Data: bytes.NewReader([]byte("expired"))})
+			past := time.Now().Add(-time.Minute)
+			svc.repo.update(resourceKey, func(resource *mediarepo.MediaResourceResult) { resource.ExpiresAt = &past })
+
+			_, err := svc.CleanupExpiredResources(context.Background())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CleanupExpiredResources error = %v, want %v", err, tt.wantErr)
+			}
+			_, kept := svc.repo.resource(resourceKey)
+			_, ran := svc.LastCleanup()
+			// Outside the window nothing is removed and no run is recorded
+			if wantRun := tt.wantErr == nil; kept == wantRun || ran != wantRun {
+				t.Errorf("resource kept = %v, run recorded = %v", kept, ran)
+			}
+		})
+	}
+}
+
+func TestCleanupExpiredResourcesLockedObject(t *testing.T) {
+	svc := newTestService(t, Config{})
+	svc.storage.ObjectLockRetention = time.Hour
+	expired, _ := svc.upload(t, UploadRequest{Data: bytes.NewReader([]byte("expired"))})
+	past := time.Now().Add(-time.Minute)
+	svc.repo.update(expired, func(resource *mediarepo.MediaResourceResult) { resource.ExpiresAt = &past })
+
+	if _, err := svc.CleanupExpiredResources(context.Background()); err != nil {
+		t.Fatalf("CleanupExpiredResources: %v", err)
+	}
+	// The record is gone so the resource is no longer served, the object stays until unlocked
+	if _, ok := svc.repo.resource(expired); ok {
+		t.Error("record of a locked object kept")
+	}
+	if _, ok := svc.storage.Object("", "media/"+expired); !ok {
+		t.Error("locked object deleted")
+	}
+
+	// The orphan cleanup leaves it alone while the retention is in force
+	if err := svc.OrphanCleanup(context.Background()); err != nil {
+		t.Fatalf("OrphanCleanup: %v", err)
+	}
+	if _, ok := svc.storage.Object("", "media/"+expired); !ok {
+		t.Error("locked orphan deleted")
+	}
+}
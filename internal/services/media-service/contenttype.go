@@ -0,0 +1,66 @@
+package mediaservice
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"lovebin/modules/s3"
+)
+
+// contentTypeCacheSize bounds how many content types are kept in memory
+const contentTypeCacheSize = 1024
+
+// detectContentType sniffs the content type of a plaintext. Types the content
+// alone does not tell apart, e.g. CSV from plain text or SVG from XML, are taken
+// from the file extension (without dot) when it names a known type.
+func detectContentType(data []byte, extension string) string {
+	sniffed := http.DetectContentType(data)
+	if extension == "" || !isGenericContentType(sniffed) {
+		return sniffed
+	}
+	if byExtension := mime.TypeByExtension("." + strings.ToLower(extension)); byExtension != "" {
+		return byExtension
+	}
+	return sniffed
+}
+
+// isGenericContentType reports whether a content type says no more than "some bytes" or "some text"
+func isGenericContentType(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "", "application/octet-stream", "binary/octet-stream", "text/plain", "text/xml", "application/xml", "application/zip":
+		return true
+	}
+	return false
+}
+
+// objectContentType returns the content type stored with the object of a resource,
+// or "" when none was stored, e.g. for objects uploaded before content types were
+// recorded. Lookups are cached, a failed lookup is logged and returns "".
+func (s *Service) objectContentType(ctx context.Context, resourceKey string) string {
+	cacheKey := "media:type:" + resourceKey
+	if contentType, ok, err := s.typeCache.Get(ctx, cacheKey); err == nil && ok {
+		return contentType
+	}
+
+	metadata, err := s.s3.GetObjectMetadata(ctx, "", "media/"+resourceKey)
+	if err != nil {
+		s.logger.WarnCtx(ctx, "failed to read object metadata", zap.Error(err), zap.String("resource_key", resourceKey))
+		return ""
+	}
+
+	// S3 answers binary/octet-stream for objects stored without a type
+	contentType := metadata[s3.MetadataContentType]
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType == "binary/octet-stream" || mediaType == "application/octet-stream" {
+		contentType = ""
+	}
+
+	if err := s.typeCache.Set(ctx, cacheKey, contentType, s.infoTTL); err != nil {
+		s.logger.WarnCtx(ctx, "failed to cache content type", zap.Error(err), zap.String("resource_key", resourceKey))
+	}
+	return contentType
+}
@@ -0,0 +1,110 @@
+package mediaservice
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestDetectContentType(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      []byte
+		extension string
+		want      string
+	}{
+		{"png", pngHeader, "", "image/png"},
+		{"png named txt", pngHeader, "txt", "image/png"},
+		// Only extensions built into the mime package, so the system tables do not matter
+		{"json", []byte(`{"a": 1}`), "json", "application/json"},
+		{"uppercase extension", []byte(`{"a": 1}`), "JSON", "application/json"},
+		{"plain text", []byte("hello"), "", "text/plain; charset=utf-8"},
+		{"unknown extension", []byte("hello"), "lovebin", "text/plain; charset=utf-8"},
+		{"svg", []byte(`<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg"/>`), "svg", "image/svg+xml"},
+		{"binary", []byte{0x00, 0x01, 0x02, 0xff}, "", "application/octet-stream"},
+		{"pdf", []byte("%PDF-1.7\n"), "bin", "application/pdf"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectContentType(tt.data, tt.extension); got != tt.want {
+				t.Errorf("detectContentType(%q) = %q, want %q", tt.extension, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDownloadMediaContentType(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     io.Reader
+		filename string
+		want     string
+	}{
+		{"stream", bytes.NewReader(pngHeader), "photo.png", "image/png"},
+		{"buffered", io.MultiReader(bytes.NewReader(pngHeader)), "photo.png", "image/png"},
+		{"by extension", bytes.NewReader([]byte(`{"a": 1}`)), "data.json", "application/json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestService(t, Config{})
+			resourceKey, encKey := svc.upload(t, UploadRequest{Data: tt.data, Filename: tt.filename})
+
+			resp, err := svc.DownloadMedia(context.Background(), &DownloadRequest{ResourceKey: resourceKey, EncKeyBase64: encKey})
+			if err != nil {
+				t.Fatalf("DownloadMedia: %v", err)
+			}
+			_ = resp.Data.Close()
+			if resp.ContentType != tt.want {
+				t.Errorf("ContentType = %q, want %q", resp.ContentType, tt.want)
+			}
+		})
+	}
+}
+
+func TestObjectContentType(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("cached", func(t *testing.T) {
+		svc := newTestService(t, Config{})
+		resourceKey, _ := svc.upload(t, UploadRequest{Data: bytes.NewReader(pngHeader)})
+		for range 3 {
+			if got := svc.objectContentType(ctx, resourceKey); got != "image/png" {
+				t.Errorf("objectContentType = %q, want image/png", got)
+			}
+		}
+		if n := svc.storage.Calls("GetObjectMetadata"); n != 1 {
+			t.Errorf("GetObjectMetadata called %d times, want 1", n)
+		}
+	})
+
+	t.Run("stored without a type", func(t *testing.T) {
+		svc := newTestService(t, Config{})
+		for _, contentType := range []string{"", "binary/octet-stream", "application/octet-stream"} {
+			if _, err := svc.storage.Upload(ctx, "", "media/untyped"+contentType, bytes.NewReader([]byte("data")), contentType); err != nil {
+				t.Fatalf("Upload: %v", err)
+			}
+			if got := svc.objectContentType(ctx, "untyped"+contentType); got != "" {
+				t.Errorf("objectContentType of an object stored as %q = %q, want empty", contentType, got)
+			}
+		}
+	})
+
+	t.Run("failed lookup", func(t *testing.T) {
+		svc := newTestService(t, Config{})
+		resourceKey, _ := svc.upload(t, UploadRequest{Data: bytes.NewReader(pngHeader)})
+		svc.storage.SetError("GetObjectMetadata", errors.New("head failed"))
+		if got := svc.objectContentType(ctx, resourceKey); got != "" {
+			t.Errorf("objectContentType after a failed lookup = %q, want empty", got)
+		}
+
+		// Failures are not cached
+		svc.storage.SetError("GetObjectMetadata", nil)
+		if got := svc.objectContentType(ctx, resourceKey); got != "image/png" {
+			t.Errorf("objectContentType after recovery = %q, want image/png", got)
+		}
+	})
+}
@@ -0,0 +1,50 @@
+package mediaservice
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeCountries(t *testing.T) {
+	tests := []struct {
+		name      string
+		countries []string
+		want      []string
+		wantErr   error
+	}{
+		{"none", nil, nil, nil},
+		{"upper case", []string{"US", "GB"}, []string{"US", "GB"}, nil},
+		{"lower case and spaces", []string{" us", "gb "}, []string{"US", "GB"}, nil},
+		{"duplicates", []string{"US", "us", "GB"}, []string{"US", "GB"}, nil},
+		{"blank dropped", []string{"", "  ", "DE"}, []string{"DE"}, nil},
+		{"three letters", []string{"USA"}, nil, ErrInvalidCountryCode},
+		{"one letter", []string{"U"}, nil, ErrInvalidCountryCode},
+		{"digits", []string{"U1"}, nil, ErrInvalidCountryCode},
+		{"non-ASCII", []string{"ÜS"}, nil, ErrInvalidCountryCode},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeCountries(tt.countries)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("normalizeCountries(%q) error = %v, want %v", tt.countries, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("normalizeCountries(%q) = %q, want %q", tt.countries, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUploadMediaRejectsInvalidCountry(t *testing.T) {
+	svc := newTestService(t, Config{})
+	_, err := svc.UploadMedia(context.Background(), UploadRequest{Data: bytes.NewReader([]byte("data")), Size: 4, AllowedCountries: []string{"us", "USA"}})
+	if !errors.Is(err, ErrInvalidCountryCode) {
+		t.Errorf("UploadMedia with an invalid country error = %v, want ErrInvalidCountryCode", err)
+	}
+	if n := len(svc.repo.resources); n != 0 {
+		t.Errorf("%d resources stored after a rejected upload", n)
+	}
+}
@@ -0,0 +1,127 @@
+package mediaservice
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"lovebin/modules/logger"
+	"lovebin/modules/s3"
+)
+
+const (
+	defaultDeleteWorkerBufferSize = 100
+	deleteWorkerTimeout           = 30 * time.Second // per S3 deletion
+)
+
+// deleteWorker removes S3 objects of viewed resources in the background.
+// Objects it misses are still removed by the nightly cleanup.
+type deleteWorker struct {
+	logger logger.Logger
+	s3     s3.S3
+	queue  chan string
+
+	mu     sync.RWMutex
+	closed bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func newDeleteWorker(log logger.Logger, s3Client s3.S3, bufferSize int) *deleteWorker {
+	if bufferSize <= 0 {
+		bufferSize = defaultDeleteWorkerBufferSize
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &deleteWorker{
+		logger: log,
+		s3:     s3Client,
+		queue:  make(chan string, bufferSize),
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// enqueue schedules deletion without blocking the caller
+func (w *deleteWorker) enqueue(ctx context.Context, resourceKey string) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return
+	}
+
+	select {
+	case w.queue <- resourceKey:
+	default:
+		w.logger.WarnCtx(ctx, "delete queue is full, leaving object for nightly cleanup", zap.String("resource_key", resourceKey))
+	}
+}
+
+// enqueueAfter schedules deletion once delay has passed. Keys still pending
+// at shutdown are left for the nightly cleanup.
+func (w *deleteWorker) enqueueAfter(resourceKey string, delay time.Duration) {
+	time.AfterFunc(delay, func() {
+		w.enqueue(w.ctx, resourceKey)
+	})
+}
+
+func (w *deleteWorker) run() {
+	defer close(w.done)
+	for resourceKey := range w.queue {
+		if w.ctx.Err() != nil {
+			// Shutting down, drop the rest of the queue
+			continue
+		}
+		w.delete(resourceKey)
+	}
+}
+
+func (w *deleteWorker) delete(resourceKey string) {
+	ctx, cancel := context.WithTimeout(w.ctx, deleteWorkerTimeout)
+	defer cancel()
+
+	err := w.s3.Delete(ctx, "", "media/"+resourceKey)
+	switch {
+	case errors.Is(err, s3.ErrObjectLocked):
+		// The nightly cleanup removes the record, the orphan cleanup the object once unlocked
+		w.logger.Debug("viewed resource is locked, kept until its retention passes", zap.String("resource_key", resourceKey), zap.Error(err))
+		return
+	case err != nil:
+		w.logger.Warn("failed to delete viewed resource from S3", zap.String("resource_key", resourceKey), zap.Error(err))
+		return
+	}
+	w.logger.Debug("deleted viewed resource from S3", zap.String("resource_key", resourceKey))
+
+	// The manifest is of no use without the object, the nightly cleanup retries failures
+	if err := s3.DeleteManifest(ctx, w.s3, resourceKey); err != nil {
+		w.logger.Warn("failed to delete resource manifest from S3", zap.String("resource_key", resourceKey), zap.Error(err))
+	}
+}
+
+// shutdown stops accepting keys and drains the queue until ctx is done
+func (w *deleteWorker) shutdown(ctx context.Context) error {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+	}
+	w.mu.Unlock()
+
+	select {
+	case <-w.done:
+		w.cancel()
+		return nil
+	case <-ctx.Done():
+		// Abort in-flight deletions, remaining keys are left for the nightly cleanup
+		w.cancel()
+		<-w.done
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,174 @@
+package mediaservice
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"lovebin/modules/s3"
+)
+
+func TestDeleteWorkerRemovesViewedObjects(t *testing.T) {
+	svc := newTestService(t, Config{DeleteWorkerEnabled: true})
+	resourceKey, encKey := svc.upload(t, UploadRequest{Data: bytes.NewReader([]byte("two views")), MaxViews: 2})
+
+	if _, err := download(t, svc, resourceKey, encKey, ""); err != nil {
+		t.Fatalf("first download: %v", err)
+	}
+	if _, err := download(t, svc, resourceKey, encKey, ""); err != nil {
+		t.Fatalf("last download: %v", err)
+	}
+	if err := svc.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	if _, ok := svc.storage.Object("", "media/"+resourceKey); ok {
+		t.Error("object kept after the last view")
+	}
+	if _, ok := svc.storage.Object("", s3.ManifestKey(resourceKey)); ok {
+		t.Error("manifest kept after the last view")
+	}
+	if deletes := svc.storage.Calls("Delete"); deletes != 2 {
+		t.Errorf("S3 deletes = %d, want 2 (object and manifest)", deletes)
+	}
+}
+
+func TestDeleteWorkerWaitsForDownloadToClose(t *testing.T) {
+	svc := newTestService(t, Config{DeleteWorkerEnabled: true})
+	resourceKey, encKey := svc.upload(t, UploadRequest{Data: bytes.NewReader([]byte("one view"))})
+
+	// The object is read while the download is sent, it is deleted once closed
+	resp, err := svc.DownloadMedia(context.Background(), &DownloadRequest{ResourceKey: resourceKey, EncKeyBase64: encKey})
+	if err != nil {
+		t.Fatalf("DownloadMedia: %v", err)
+	}
+	if err := svc.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if _, ok := svc.storage.Object("", "media/"+resourceKey); !ok {
+		t.Error("object deleted before the download was closed")
+	}
+	resp.Data.Close()
+}
+
+func TestDeleteWorkerKeepsObjectWithViewsLeft(t *testing.T) {
+	svc := newTestService(t, Config{DeleteWorkerEnabled: true})
+	resourceKey, encKey := svc.upload(t, UploadRequest{Data: bytes.NewReader([]byte("two views")), MaxViews: 2})
+
+	if _, err := download(t, svc, resourceKey, encKey, ""); err != nil {
+		t.Fatalf("download: %v", err)
+	}
+	if err := svc.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if _, ok := svc.storage.Object("", "media/"+resourceKey); !ok {
+		t.Error("object deleted while a view is left")
+	}
+}
+
+func TestDeleteWorkerDisabled(t *testing.T) {
+	svc := newTestService(t, Config{})
+	resourceKey, encKey := svc.upload(t, UploadRequest{Data: bytes.NewReader([]byte("once"))})
+
+	if _, err := download(t, svc, resourceKey, encKey, ""); err != nil {
+		t.Fatalf("download: %v", err)
+	}
+	if err := svc.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	// Left for the nightly cleanup
+	if _, ok := svc.storage.Object("", "media/"+resourceKey); !ok {
+		t.Error("object deleted without the delete worker")
+	}
+}
+
+// blockingS3 holds every Delete until release is closed
+type blockingS3 struct {
+	*s3.MockS3
+	deleting chan string
+	release  chan struct{}
+}
+
+func (b *blockingS3) Delete(ctx context.Context, bucket, key string) error {
+	b.deleting <- key
+	<-b.release
+	return b.MockS3.Delete(ctx, bucket, key)
+}
+
+func TestDeleteWorkerFullQueue(t *testing.T) {
+	storage := &blockingS3{MockS3: s3.NewMockS3(), deleting: make(chan string, 10), release: make(chan struct{})}
+	ctx := context.Background()
+	for i := range 3 {
+		if _, err := storage.Upload(ctx, "", fmt.Sprintf("media/key%d", i), bytes.NewReader([]byte("x")), ""); err != nil {
+			t.Fatalf("Upload: %v", err)
+		}
+	}
+	w := newDeleteWorker(newTestLogger(t), storage, 1)
+
+	// key0 is being deleted, key1 fills the queue
+	w.enqueue(ctx, "key0")
+	<-storage.deleting
+	w.enqueue(ctx, "key1")
+
+	// A full queue drops keys instead of blocking the download
+	done := make(chan struct{})
+	go func() {
+		w.enqueue(ctx, "key2")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("enqueue blocked on a full queue")
+	}
+
+	close(storage.release)
+	if err := w.shutdown(ctx); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	for key, wantKept := range map[string]bool{"key0": false, "key1": false, "key2": true} {
+		if _, ok := storage.Object("", "media/"+key); ok != wantKept {
+			t.Errorf("%s kept = %v, want %v", key, ok, wantKept)
+		}
+	}
+
+	// Keys arriving after shutdown are ignored
+	w.enqueue(ctx, "key2")
+}
+
+func TestDeleteWorkerShutdownTimeout(t *testing.T) {
+	storage := s3.NewMockS3()
+	w := newDeleteWorker(newTestLogger(t), storage, 10)
+	for i := range 10 {
+		w.enqueue(context.Background(), fmt.Sprintf("key%d", i))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// An expired context aborts the drain, remaining keys are left for the nightly cleanup
+	if err := w.shutdown(ctx); err != nil && err != context.Canceled {
+		t.Fatalf("shutdown: %v", err)
+	}
+	select {
+	case <-w.done:
+	default:
+		t.Error("worker still running after shutdown")
+	}
+}
+
+func TestDeleteWorkerObjectLocked(t *testing.T) {
+	storage := s3.NewMockS3()
+	storage.SetError("Delete", s3.ErrObjectLocked)
+	w := newDeleteWorker(newTestLogger(t), storage, 1)
+
+	w.enqueue(context.Background(), "locked")
+	if err := w.shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	// The manifest is kept while the object is under retention
+	if deletes := storage.Calls("Delete"); deletes != 1 {
+		t.Errorf("S3 deletes = %d, want 1", deletes)
+	}
+}
@@ -0,0 +1,204 @@
+package mediaservice
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDownloadMediaConcurrentSingleView(t *testing.T) {
+	svc := newTestService(t, Config{})
+	resourceKey, encKey := svc.upload(t, UploadRequest{Data: bytes.NewReader([]byte("once"))})
+
+	const downloads = 10
+	errs := make(chan error, downloads)
+	var wg sync.WaitGroup
+	for range downloads {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := download(t, svc, resourceKey, encKey, "")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, ErrAlreadyViewed):
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("%d downloads succeeded, want 1", succeeded)
+	}
+}
+
+func TestDownloadMediaWaitsForLockedResource(t *testing.T) {
+	svc := newTestService(t, Config{})
+	resourceKey, encKey := svc.upload(t, UploadRequest{Data: bytes.NewReader([]byte("twice")), MaxViews: 2})
+
+	// Another download holds the lock
+	lock := svc.repo.lock(resourceKey)
+	lock.Lock()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := download(t, svc, resourceKey, encKey, "")
+		done <- err
+	}()
+
+	// The download falls back to waiting for the lock instead of failing
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		svc.repo.mu.Lock()
+		waits := svc.repo.lockWaits
+		svc.repo.mu.Unlock()
+		if waits > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("download did not wait for the lock")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case err := <-done:
+		t.Fatalf("download finished while the resource was locked: %v", err)
+	default:
+	}
+
+	lock.Unlock()
+	if err := <-done; err != nil {
+		t.Fatalf("download after the lock was released: %v", err)
+	}
+	if resource, _ := svc.repo.resource(resourceKey); resource.ViewCount != 1 {
+		t.Errorf("view count = %d, want 1", resource.ViewCount)
+	}
+}
+
+func TestDownloadMediaFailedViewIsNotCounted(t *testing.T) {
+	svc := newTestService(t, Config{})
+	resourceKey, _ := svc.upload(t, UploadRequest{Data: bytes.NewReader([]byte("data"))})
+
+	if _, err := download(t, svc, resourceKey, newURLKey(t), ""); !errors.Is(err, ErrDecryptionFailed) {
+		t.Fatalf("download with a wrong key: err = %v, want ErrDecryptionFailed", err)
+	}
+	if resource, _ := svc.repo.resource(resourceKey); resource.ViewCount != 0 || resource.Viewed {
+		t.Errorf("failed download counted: view count %d, viewed %v", resource.ViewCount, resource.Viewed)
+	}
+}
+
+func TestNormalizeMaxViews(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxViews    int
+		accessCodes []string
+		want        int
+		wantErr     error
+	}{
+		{"unset is one", 0, nil, 1, nil},
+		{"one", 1, nil, 1, nil},
+		{"several", 5, nil, 5, nil},
+		{"at most", MaxViews, nil, MaxViews, nil},
+		{"above the maximum", MaxViews + 1, nil, 0, ErrInvalidMaxViews},
+		{"negative", -1, nil, 0, ErrInvalidMaxViews},
+		{"one per access code", 0, []string{"alpha", "beta", "gamma"}, 3, nil},
+		{"one with access codes", 1, []string{"alpha", "beta"}, 2, nil},
+		{"several with access codes", 2, []string{"alpha", "beta"}, 0, ErrMaxViewsWithAccessCodes},
+		{"invalid with access codes", -1, []string{"alpha"}, 0, ErrInvalidMaxViews},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeMaxViews(tt.maxViews, tt.accessCodes)
+			if got != tt.want || !errors.Is(err, tt.wantErr) {
+				t.Errorf("normalizeMaxViews(%d, %q) = %d, %v, want %d, %v", tt.maxViews, tt.accessCodes, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestViewsRemaining(t *testing.T) {
+	tests := []struct {
+		name     string
+		resource MediaResource
+		want     int
+	}{
+		{"unviewed", MediaResource{MaxViews: 3}, 3},
+		{"partly viewed", MediaResource{MaxViews: 3, ViewCount: 2}, 1},
+		{"used up", MediaResource{MaxViews: 3, ViewCount: 3}, 0},
+		{"over counted", MediaResource{MaxViews: 3, ViewCount: 4}, 0},
+		{"marked viewed", MediaResource{MaxViews: 3, ViewCount: 1, Viewed: true}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.resource.viewsRemaining(); got != tt.want {
+				t.Errorf("viewsRemaining = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDownloadMediaCountsViews(t *testing.T) {
+	tests := []struct {
+		name      string
+		maxViews  int
+		wantViews int
+	}{
+		{"default", 0, 1},
+		{"one", 1, 1},
+		{"three", 3, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestService(t, Config{})
+			resourceKey, encKey := svc.upload(t, UploadRequest{Data: bytes.NewReader([]byte("counted")), MaxViews: tt.maxViews})
+			if resource, _ := svc.repo.resource(resourceKey); resource.MaxViews != tt.wantViews {
+				t.Fatalf("MaxViews = %d, want %d", resource.MaxViews, tt.wantViews)
+			}
+
+			for i := range tt.wantViews {
+				resp, err := svc.DownloadMedia(context.Background(), &DownloadRequest{ResourceKey: resourceKey, EncKeyBase64: encKey})
+				if err != nil {
+					t.Fatalf("download %d of %d: %v", i+1, tt.wantViews, err)
+				}
+				resp.Data.Close()
+				if want := tt.wantViews - i - 1; resp.ViewsRemaining != want {
+					t.Errorf("download %d ViewsRemaining = %d, want %d", i+1, resp.ViewsRemaining, want)
+				}
+
+				resource, _ := svc.repo.resource(resourceKey)
+				if resource.ViewCount != i+1 || resource.Viewed != (i+1 == tt.wantViews) {
+					t.Errorf("after download %d ViewCount = %d, Viewed = %v", i+1, resource.ViewCount, resource.Viewed)
+				}
+			}
+
+			if _, err := download(t, svc, resourceKey, encKey, ""); !errors.Is(err, ErrAlreadyViewed) {
+				t.Errorf("download after the last view = %v, want %v", err, ErrAlreadyViewed)
+			}
+			if resource, _ := svc.repo.resource(resourceKey); resource.ViewCount != tt.wantViews {
+				t.Errorf("ViewCount = %d after a rejected download, want %d", resource.ViewCount, tt.wantViews)
+			}
+		})
+	}
+}
+
+func TestUploadMediaRejectsMaxViews(t *testing.T) {
+	for _, maxViews := range []int{-1, MaxViews + 1} {
+		svc := newTestService(t, Config{})
+		_, err := svc.UploadMedia(context.Background(), UploadRequest{Data: bytes.NewReader([]byte("data")), Size: 4, MaxViews: maxViews})
+		if !errors.Is(err, ErrInvalidMaxViews) {
+			t.Errorf("UploadMedia with %d max views = %v, want %v", maxViews, err, ErrInvalidMaxViews)
+		}
+		if n := svc.storage.Calls("Upload") + svc.storage.Calls("UploadWithProgress") + svc.storage.Calls("UploadStream"); n != 0 {
+			t.Errorf("%d objects stored for %d max views", n, maxViews)
+		}
+	}
+}
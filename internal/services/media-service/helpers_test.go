@@ -0,0 +1,74 @@
+package mediaservice
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"lovebin/modules/cache"
+	"lovebin/modules/encryption"
+	"lovebin/modules/logger"
+	"lovebin/modules/s3"
+)
+
+// inlinePostgres runs WithTx functions directly with a nil transaction, for
+// services whose repository is a fake that ignores it
+type inlinePostgres struct{}
+
+func (inlinePostgres) GetPool() *pgxpool.Pool { return nil }
+
+func (inlinePostgres) WithTx(_ context.Context, fn func(tx pgx.Tx) error) error {
+	return fn(nil)
+}
+
+func (inlinePostgres) Close() {}
+
+func (inlinePostgres) GracefulClose(context.Context) error { return nil }
+
+// testService is a Service on a fake repository and MockS3
+type testService struct {
+	*Service
+	repo    *MockRepository
+	storage *s3.MockS3
+}
+
+// fastKDF keeps password tests quick, the parameters are not meant to be secure
+var fastKDF = encryption.Config{Iterations: 1000, Argon2id: encryption.Argon2idParams{Time: 1, Memory: 64, Threads: 1}}
+
+func newTestLogger(t *testing.T) logger.Logger {
+	t.Helper()
+	log, err := logger.Init(logger.Config{Level: "fatal"})
+	if err != nil {
+		t.Fatalf("logger.Init: %v", err)
+	}
+	return log
+}
+
+func newTestService(t *testing.T, cfg Config) *testService {
+	t.Helper()
+	log := newTestLogger(t)
+	repo := NewMockRepository()
+	storage := s3.NewMockS3()
+	svc := NewService(log, inlinePostgres{}, storage, encryption.Init(fastKDF, nil), nil, repo,
+		cache.NewLRU[string, MediaInfo](0), nil, cfg)
+	return &testService{Service: svc, repo: repo, storage: storage}
+}
+
+// upload stores data and returns its resource key and URL key
+func (s *testService) upload(t *testing.T, req UploadRequest) (resourceKey, encKey string) {
+	t.Helper()
+	data, _ := req.Data.(*bytes.Reader)
+	if data != nil && req.Size == 0 {
+		req.Size = data.Size()
+	}
+	resp, err := s.UploadMedia(context.Background(), req)
+	if err != nil {
+		t.Fatalf("UploadMedia: %v", err)
+	}
+	resourceKey, encKey, _ = strings.Cut(resp.ResourceKey, "#")
+	return resourceKey, encKey
+}
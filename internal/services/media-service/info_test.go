@@ -0,0 +1,153 @@
+package mediaservice
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	mediarepo "lovebin/internal/services/media-service/repository"
+	"lovebin/modules/timeparser"
+)
+
+// countingRepo counts the database loads of media info and can hold them until release is closed
+type countingRepo struct {
+	*MockRepository
+	loads   atomic.Int32
+	started chan struct{} // receives a value when a load starts, if set
+	release chan struct{} // loads wait for it to close, if set
+}
+
+func (r *countingRepo) GetMediaResourceByKeyAny(ctx context.Context, resourceKey string) (mediarepo.MediaResourceResult, error) {
+	r.loads.Add(1)
+	if r.started != nil {
+		r.started <- struct{}{}
+	}
+	if r.release != nil {
+		<-r.release
+	}
+	return r.MockRepository.GetMediaResourceByKeyAny(ctx, resourceKey)
+}
+
+// withCountingRepo makes svc load media info through a countingRepo
+func withCountingRepo(svc *testService) *countingRepo {
+	repo := &countingRepo{MockRepository: svc.repo}
+	svc.Service.repo = repo
+	return repo
+}
+
+func TestGetMediaInfoIsCached(t *testing.T) {
+	svc := newTestService(t, Config{})
+	resourceKey, encKey := svc.upload(t, UploadRequest{Data: bytes.NewReader([]byte("data")), Filename: "photo.png"})
+	repo := withCountingRepo(svc)
+	ctx := context.Background()
+
+	for range 3 {
+		info, err := svc.GetMediaInfo(ctx, resourceKey, encKey)
+		if err != nil {
+			t.Fatalf("GetMediaInfo: %v", err)
+		}
+		if info.Filename == nil || info.FileExtension == nil || *info.Filename+"."+*info.FileExtension != "photo.png" || !info.IsImage {
+			t.Fatalf("GetMediaInfo = %+v, want photo.png as an image", info)
+		}
+		// Callers get their own copy, changing it does not change the cache
+		info.DownloadOnly = true
+	}
+	if n := repo.loads.Load(); n != 1 {
+		t.Errorf("media info loaded %d times, want 1", n)
+	}
+	if info, _ := svc.GetMediaInfo(ctx, resourceKey, encKey); info.DownloadOnly {
+		t.Error("a caller's change leaked into the cache")
+	}
+
+	// The cache holds the sealed metadata only, without the URL key the name stays hidden
+	info, err := svc.GetMediaInfo(ctx, resourceKey, "")
+	if err != nil || info.Filename != nil || info.FileExtension != nil || info.IsImage {
+		t.Errorf("GetMediaInfo without key = %+v, %v, want no filename", info, err)
+	}
+	if n := repo.loads.Load(); n != 1 {
+		t.Errorf("media info loaded %d times, want 1", n)
+	}
+}
+
+func TestGetMediaInfoInvalidatedByDownload(t *testing.T) {
+	svc := newTestService(t, Config{})
+	resourceKey, encKey := svc.upload(t, UploadRequest{Data: bytes.NewReader([]byte("data")), MaxViews: 2})
+	ctx := context.Background()
+
+	for _, wantRemaining := range []int{2, 1, 0} {
+		info, err := svc.GetMediaInfo(ctx, resourceKey, encKey)
+		if err != nil {
+			t.Fatalf("GetMediaInfo: %v", err)
+		}
+		if info.ViewsRemaining != wantRemaining {
+			t.Errorf("ViewsRemaining = %d, want %d", info.ViewsRemaining, wantRemaining)
+		}
+		if wantRemaining > 0 {
+			if _, err := download(t, svc, resourceKey, encKey, ""); err != nil {
+				t.Fatalf("download: %v", err)
+			}
+		}
+	}
+}
+
+func TestGetMediaInfoSharesConcurrentLoads(t *testing.T) {
+	svc := newTestService(t, Config{})
+	resourceKey, encKey := svc.upload(t, UploadRequest{Data: bytes.NewReader([]byte("data"))})
+	repo := withCountingRepo(svc)
+	repo.started = make(chan struct{}, 10)
+	repo.release = make(chan struct{})
+
+	const callers = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := svc.GetMediaInfo(context.Background(), resourceKey, encKey)
+			errs <- err
+		}()
+	}
+
+	// Let the other callers join the load in flight before it finishes
+	<-repo.started
+	time.Sleep(50 * time.Millisecond)
+	close(repo.release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("GetMediaInfo: %v", err)
+		}
+	}
+	if n := repo.loads.Load(); n != 1 {
+		t.Errorf("media info loaded %d times, want 1", n)
+	}
+}
+
+func TestGetMediaInfoNotCachedPastExpiry(t *testing.T) {
+	svc := newTestService(t, Config{MediaInfoCacheTTL: time.Hour})
+	expiresAt := timeparser.UniversalTime{Time: time.Now().Add(100 * time.Millisecond)}
+	resourceKey, encKey := svc.upload(t, UploadRequest{Data: bytes.NewReader([]byte("data")), ExpiresAt: expiresAt})
+	ctx := context.Background()
+
+	if _, err := svc.GetMediaInfo(ctx, resourceKey, encKey); err != nil {
+		t.Fatalf("GetMediaInfo before expiry: %v", err)
+	}
+	time.Sleep(150 * time.Millisecond)
+	if _, err := svc.GetMediaInfo(ctx, resourceKey, encKey); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetMediaInfo after expiry error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestGetMediaInfoNotFound(t *testing.T) {
+	svc := newTestService(t, Config{})
+	if _, err := svc.GetMediaInfo(context.Background(), "missing", ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetMediaInfo(missing) error = %v, want ErrNotFound", err)
+	}
+}
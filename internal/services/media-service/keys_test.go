@@ -0,0 +1,66 @@
+package mediaservice
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"lovebin/modules/encryption"
+)
+
+func TestServerKeyRotation(t *testing.T) {
+	v1 := bytes.Repeat([]byte{1}, 32)
+	v2 := bytes.Repeat([]byte{2}, 32)
+
+	tests := []struct {
+		name     string
+		password string
+		data     func() io.Reader
+	}{
+		{"fast", "", func() io.Reader { return io.MultiReader(strings.NewReader("rotated content")) }},
+		{"fast stream", "", func() io.Reader { return bytes.NewReader([]byte("rotated content")) }},
+		{"password", "mzkqTW7!pLx9", func() io.Reader { return bytes.NewReader([]byte("rotated content")) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestService(t, Config{})
+			svc.Service.keys = encryption.NewStaticKeyManager("v1", v1)
+			oldKey, oldEncKey := svc.upload(t, UploadRequest{Data: tt.data(), Password: tt.password})
+			if resource, _ := svc.repo.resource(oldKey); resource.KeyVersion != "v1" {
+				t.Fatalf("KeyVersion = %q, want v1", resource.KeyVersion)
+			}
+
+			rotated, err := encryption.NewRotatingKeyManager(map[string][]byte{"v1": v1, "v2": v2}, "v2")
+			if err != nil {
+				t.Fatalf("NewRotatingKeyManager: %v", err)
+			}
+			svc.Service.keys = rotated
+			newKey, newEncKey := svc.upload(t, UploadRequest{Data: tt.data(), Password: tt.password})
+			if resource, _ := svc.repo.resource(newKey); resource.KeyVersion != "v2" {
+				t.Errorf("KeyVersion after rotation = %q, want v2", resource.KeyVersion)
+			}
+
+			// Both versions open while the old key is kept
+			for _, r := range []struct{ resourceKey, encKey string }{{oldKey, oldEncKey}, {newKey, newEncKey}} {
+				got, err := download(t, svc, r.resourceKey, r.encKey, tt.password)
+				if err != nil || string(got) != "rotated content" {
+					t.Errorf("download = %q, %v, want the content", got, err)
+				}
+			}
+		})
+	}
+}
+
+func TestServerKeyRequired(t *testing.T) {
+	svc := newTestService(t, Config{})
+	svc.Service.keys = encryption.NewStaticKeyManager("v1", bytes.Repeat([]byte{1}, 32))
+	resourceKey, encKey := svc.upload(t, UploadRequest{Data: io.MultiReader(strings.NewReader("content"))})
+
+	// Without the v1 key the URL key alone does not open the resource
+	svc.Service.keys = encryption.NewStaticKeyManager("v2", bytes.Repeat([]byte{2}, 32))
+	if _, err := download(t, svc, resourceKey, encKey, ""); !errors.Is(err, ErrDecryptionFailed) {
+		t.Errorf("download error = %v, want ErrDecryptionFailed", err)
+	}
+}
@@ -0,0 +1,151 @@
+package mediaservice
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"hash"
+	"io"
+	"time"
+
+	"lovebin/modules/encryption"
+	"lovebin/modules/s3"
+	"lovebin/modules/timeparser"
+
+	"go.uber.org/zap"
+)
+
+// ErrManifestMismatch is returned when the manifest stored with a resource does not
+// match it: the content hash differs, it belongs to another resource or cannot be opened
+var ErrManifestMismatch = errors.New("resource manifest does not match its content")
+
+// resourceManifest is stored sealed with the URL key at s3.ManifestKey(resourceKey).
+// It lets the content be checked without trusting the database record.
+type resourceManifest struct {
+	ResourceKey string `json:"resource_key"`
+	ContentHash string `json:"content_hash"` // hex SHA-256 of the plaintext
+	Filename    string `json:"filename"`
+	CreatedAt   string `json:"created_at"` // RFC 3339 in UTC
+	ExpiresAt   string `json:"expires_at"` // see ManifestExpiresAt, empty for resources that never expire
+}
+
+// newResourceManifest describes a resource uploaded now
+func newResourceManifest(resourceKey, contentHash, filename string, expiresAt timeparser.UniversalTime) resourceManifest {
+	return resourceManifest{
+		ResourceKey: resourceKey,
+		ContentHash: contentHash,
+		Filename:    filename,
+		CreatedAt:   time.Now().UTC().Format(time.RFC3339),
+		ExpiresAt:   ManifestExpiresAt(expiresAt),
+	}
+}
+
+// sealManifest encodes and seals a manifest with the URL key
+func sealManifest(manifest resourceManifest, key []byte) ([]byte, error) {
+	plaintext, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+	return encryption.EncryptManifest(plaintext, key)
+}
+
+// openManifest opens a manifest sealed by sealManifest, a wrong key gives ErrManifestMismatch
+func openManifest(sealed, key []byte) (resourceManifest, error) {
+	var manifest resourceManifest
+	plaintext, err := encryption.DecryptManifest(sealed, key)
+	if err != nil {
+		return manifest, ErrManifestMismatch
+	}
+	if err := json.Unmarshal(plaintext, &manifest); err != nil {
+		return manifest, ErrManifestMismatch
+	}
+	return manifest, nil
+}
+
+// loadManifest downloads and opens the manifest of resourceKey. Resources uploaded
+// before manifests were stored have none, ok is false for them.
+func (s *Service) loadManifest(ctx context.Context, resourceKey string, key []byte) (manifest resourceManifest, sealed []byte, ok bool, err error) {
+	sealed, err = s3.DownloadManifest(ctx, s.s3, resourceKey)
+	if errors.Is(err, s3.ErrManifestNotFound) {
+		return manifest, nil, false, nil
+	}
+	if err != nil {
+		return manifest, nil, false, err
+	}
+	manifest, err = openManifest(sealed, key)
+	if err != nil {
+		return manifest, nil, false, err
+	}
+	if manifest.ResourceKey != resourceKey {
+		return manifest, nil, false, ErrManifestMismatch
+	}
+	return manifest, sealed, true, nil
+}
+
+// verifyManifest wraps the decrypted content of a resource so it is checked against
+// its manifest while read. The manifest itself is checked here, the content once read
+// to its end: the last read returns ErrManifestMismatch instead of io.EOF when they differ.
+func (s *Service) verifyManifest(ctx context.Context, resourceKey string, key []byte, plaintext io.ReadCloser) (io.ReadCloser, error) {
+	manifest, _, ok, err := s.loadManifest(ctx, resourceKey, key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return plaintext, nil
+	}
+	return &manifestReader{
+		ReadCloser: plaintext,
+		manifest:   manifest,
+		hash:       sha256.New(),
+		mismatch: func() {
+			s.logger.WarnCtx(ctx, "resource content does not match its manifest", zap.String("resource_key", resourceKey))
+		},
+	}, nil
+}
+
+// manifestReader hashes a plaintext as it is read, see verifyManifest
+type manifestReader struct {
+	io.ReadCloser
+	manifest resourceManifest
+	hash     hash.Hash
+	mismatch func()
+	err      error // result of the check, kept for reads past the end
+}
+
+func (r *manifestReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	n, err := r.ReadCloser.Read(p)
+	r.hash.Write(p[:n])
+	if err == io.EOF {
+		r.err = io.EOF
+		if !manifestDigestMatches(r.manifest, r.hash.Sum(nil)) {
+			r.mismatch()
+			r.err = ErrManifestMismatch
+		}
+		return n, r.err
+	}
+	return n, err
+}
+
+// manifestHashMatches reports whether plaintext hashes to the content hash of manifest
+func manifestHashMatches(manifest resourceManifest, plaintext []byte) bool {
+	contentHash := sha256.Sum256(plaintext)
+	return manifestDigestMatches(manifest, contentHash[:])
+}
+
+// manifestDigestMatches is manifestHashMatches for the SHA-256 digest of a plaintext hashed while streaming
+func manifestDigestMatches(manifest resourceManifest, digest []byte) bool {
+	return subtle.ConstantTimeCompare([]byte(hex.EncodeToString(digest)), []byte(manifest.ContentHash)) == 1
+}
+
+// deleteManifest removes the manifest of a deleted resource, failures are logged like object deletes
+func (s *Service) deleteManifest(ctx context.Context, resourceKey string) {
+	if err := s3.DeleteManifest(ctx, s.s3, resourceKey); err != nil {
+		s.warnDeleteFailed(ctx, "failed to delete resource manifest from S3", resourceKey, err)
+	}
+}
@@ -0,0 +1,215 @@
+package mediaservice
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"lovebin/modules/encryption"
+	"lovebin/modules/s3"
+	"lovebin/modules/timeparser"
+)
+
+// storedManifest opens the manifest stored for resourceKey with the URL key of its link
+func storedManifest(t *testing.T, svc *testService, resourceKey, encKey string) resourceManifest {
+	t.Helper()
+	sealed, ok := svc.storage.Object("", s3.ManifestKey(resourceKey))
+	if !ok {
+		t.Fatalf("no manifest stored for %s", resourceKey)
+	}
+	manifest, err := openManifest(sealed, decodeURLKey(encKey))
+	if err != nil {
+		t.Fatalf("openManifest: %v", err)
+	}
+	return manifest
+}
+
+// replaceManifest stores manifest sealed with key in place of the one of resourceKey
+func replaceManifest(t *testing.T, svc *testService, resourceKey string, manifest resourceManifest, key []byte) {
+	t.Helper()
+	sealed, err := sealManifest(manifest, key)
+	if err != nil {
+		t.Fatalf("sealManifest: %v", err)
+	}
+	if err := s3.UploadManifest(context.Background(), svc.storage, resourceKey, sealed); err != nil {
+		t.Fatalf("UploadManifest: %v", err)
+	}
+}
+
+func TestSealManifest(t *testing.T) {
+	key := decodeURLKey(newURLKey(t))
+	manifest := newResourceManifest("resource", strings.Repeat("a", 64), "notes.txt", timeparser.NewUniversalTime(time.Date(2026, 10, 16, 12, 0, 0, 0, time.FixedZone("MSK", 3*3600))))
+	sealed, err := sealManifest(manifest, key)
+	if err != nil {
+		t.Fatalf("sealManifest: %v", err)
+	}
+
+	got, err := openManifest(sealed, key)
+	if err != nil {
+		t.Fatalf("openManifest: %v", err)
+	}
+	if got != manifest {
+		t.Errorf("openManifest = %+v, want %+v", got, manifest)
+	}
+	if got.ExpiresAt != "2026-10-16T09:00:00Z" {
+		t.Errorf("ExpiresAt = %q, want it in UTC", got.ExpiresAt)
+	}
+
+	flipped := bytes.Clone(sealed)
+	flipped[len(flipped)-1] ^= 1
+	notJSON, err := encryption.EncryptManifest([]byte("not a manifest"), key)
+	if err != nil {
+		t.Fatalf("EncryptManifest: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		sealed []byte
+		key    []byte
+	}{
+		{"other key", sealed, decodeURLKey(newURLKey(t))},
+		{"changed ciphertext", flipped, key},
+		{"truncated", sealed[:len(sealed)/2], key},
+		{"empty", nil, key},
+		{"not a manifest", notJSON, key},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := openManifest(tt.sealed, tt.key); !errors.Is(err, ErrManifestMismatch) {
+				t.Errorf("openManifest error = %v, want %v", err, ErrManifestMismatch)
+			}
+		})
+	}
+}
+
+func TestManifestHashMatches(t *testing.T) {
+	plaintext := []byte("manifest content")
+	sum := sha256.Sum256(plaintext)
+	manifest := resourceManifest{ContentHash: hex.EncodeToString(sum[:])}
+
+	tests := []struct {
+		name      string
+		plaintext []byte
+		want      bool
+	}{
+		{"same content", plaintext, true},
+		{"changed content", []byte("manifest Content"), false},
+		{"truncated content", plaintext[:len(plaintext)-1], false},
+		{"empty content", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := manifestHashMatches(manifest, tt.plaintext); got != tt.want {
+				t.Errorf("manifestHashMatches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	upper := resourceManifest{ContentHash: strings.ToUpper(manifest.ContentHash)}
+	if manifestDigestMatches(upper, sum[:]) {
+		t.Error("an upper case content hash matches, the manifest stores it in lower case")
+	}
+}
+
+func TestUploadMediaStoresManifest(t *testing.T) {
+	const content = "manifest content"
+	sum := sha256.Sum256([]byte(content))
+
+	tests := []struct {
+		name string
+		data io.Reader
+	}{
+		{"stream", bytes.NewReader([]byte(content))},
+		{"buffered", io.MultiReader(strings.NewReader(content))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestService(t, Config{})
+			expiresAt := timeparser.NewUniversalTime(time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC))
+			resourceKey, encKey := svc.upload(t, UploadRequest{Data: tt.data, Filename: "notes.txt", ExpiresAt: expiresAt})
+
+			manifest := storedManifest(t, svc, resourceKey, encKey)
+			if manifest.ResourceKey != resourceKey {
+				t.Errorf("ResourceKey = %q, want %q", manifest.ResourceKey, resourceKey)
+			}
+			if manifest.ContentHash != hex.EncodeToString(sum[:]) {
+				t.Errorf("ContentHash = %q, want the SHA-256 of the plaintext", manifest.ContentHash)
+			}
+			if manifest.Filename != "notes.txt" || manifest.ExpiresAt != "2026-10-16T12:00:00Z" || manifest.CreatedAt == "" {
+				t.Errorf("manifest = %+v", manifest)
+			}
+
+			sealed, _ := svc.storage.Object("", s3.ManifestKey(resourceKey))
+			if bytes.Contains(sealed, []byte(resourceKey)) || bytes.Contains(sealed, []byte("notes.txt")) {
+				t.Error("the stored manifest is not sealed")
+			}
+		})
+	}
+}
+
+func TestDownloadMediaVerifiesManifest(t *testing.T) {
+	tests := []struct {
+		name    string
+		tamper  func(t *testing.T, svc *testService, resourceKey string, manifest resourceManifest, key []byte)
+		wantErr error
+		counted bool // content is checked while it is read, after the view is counted
+	}{
+		{"untouched", func(*testing.T, *testService, string, resourceManifest, []byte) {}, nil, true},
+		{"no manifest", func(t *testing.T, svc *testService, resourceKey string, _ resourceManifest, _ []byte) {
+			if err := s3.DeleteManifest(context.Background(), svc.storage, resourceKey); err != nil {
+				t.Fatalf("DeleteManifest: %v", err)
+			}
+		}, nil, true},
+		{"other content", func(t *testing.T, svc *testService, resourceKey string, manifest resourceManifest, key []byte) {
+			manifest.ContentHash = strings.Repeat("0", 64)
+			replaceManifest(t, svc, resourceKey, manifest, key)
+		}, ErrManifestMismatch, true},
+		{"other resource", func(t *testing.T, svc *testService, resourceKey string, manifest resourceManifest, key []byte) {
+			manifest.ResourceKey = "other"
+			replaceManifest(t, svc, resourceKey, manifest, key)
+		}, ErrManifestMismatch, false},
+		{"other key", func(t *testing.T, svc *testService, resourceKey string, manifest resourceManifest, _ []byte) {
+			replaceManifest(t, svc, resourceKey, manifest, decodeURLKey(newURLKey(t)))
+		}, ErrManifestMismatch, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestService(t, Config{})
+			resourceKey, encKey := svc.upload(t, UploadRequest{Data: bytes.NewReader([]byte("verified content")), MaxViews: 2})
+			tt.tamper(t, svc, resourceKey, storedManifest(t, svc, resourceKey, encKey), decodeURLKey(encKey))
+
+			preview, err := svc.GetMediaPreview(context.Background(), &DownloadRequest{ResourceKey: resourceKey, EncKeyBase64: encKey})
+			if err == nil {
+				_, err = io.ReadAll(preview.Data)
+				preview.Data.Close()
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetMediaPreview error = %v, want %v", err, tt.wantErr)
+			}
+
+			data, err := download(t, svc, resourceKey, encKey, "")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("DownloadMedia error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && string(data) != "verified content" {
+				t.Errorf("DownloadMedia = %q", data)
+			}
+
+			// A manifest that cannot be used is found before the view is counted
+			resource, _ := svc.repo.resource(resourceKey)
+			wantViews := 0
+			if tt.counted {
+				wantViews = 1
+			}
+			if resource.ViewCount != wantViews {
+				t.Errorf("ViewCount = %d, want %d", resource.ViewCount, wantViews)
+			}
+		})
+	}
+}
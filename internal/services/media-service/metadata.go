@@ -0,0 +1,85 @@
+package mediaservice
+
+import (
+	"slices"
+	"strings"
+
+	"lovebin/modules/encryption"
+)
+
+// fileMetadataSeparator joins filename and extension in the sealed metadata.
+// The extension follows the last separator, so extensions never contain it.
+const fileMetadataSeparator = "|"
+
+// imageExtensions are the extensions shown with a preview on the view page
+var imageExtensions = []string{"jpg", "jpeg", "png", "gif", "webp", "bmp", "svg", "ico"}
+
+// isImageExtension reports whether extension (without dot) names an image format
+func isImageExtension(extension *string) bool {
+	return extension != nil && slices.Contains(imageExtensions, strings.ToLower(*extension))
+}
+
+// sealFileMetadata seals filename and extension with the URL key. Without
+// either there is nothing to hide and nil is returned.
+func sealFileMetadata(filename, extension *string, key []byte) ([]byte, error) {
+	if filename == nil && extension == nil {
+		return nil, nil
+	}
+	var plaintext string
+	if filename != nil {
+		plaintext = *filename
+	}
+	plaintext += fileMetadataSeparator
+	if extension != nil {
+		plaintext += *extension
+	}
+	return encryption.EncryptMetadata([]byte(plaintext), key)
+}
+
+// openFileMetadata returns the filename and extension of a resource. Resources
+// uploaded before metadata was encrypted keep them in plaintext; sealed ones need
+// the URL key and give nil for both without it or with a wrong one.
+func openFileMetadata(filename, extension *string, sealed, key []byte) (*string, *string) {
+	if sealed == nil {
+		return filename, extension
+	}
+	if len(key) == 0 {
+		return nil, nil
+	}
+
+	plaintext, err := encryption.DecryptMetadata(sealed, key)
+	if err != nil {
+		return nil, nil
+	}
+	name, ext, _ := cutLast(string(plaintext), fileMetadataSeparator)
+	var openedName, openedExt *string
+	if name != "" {
+		openedName = &name
+	}
+	if ext != "" {
+		openedExt = &ext
+	}
+	return openedName, openedExt
+}
+
+// openResourceMetadata is openFileMetadata for a loaded resource
+func openResourceMetadata(resource MediaResource, key []byte) (filename, extension *string) {
+	return openFileMetadata(resource.Filename, resource.FileExtension, resource.MetadataEncrypted, key)
+}
+
+// cutLast slices s around the last instance of sep
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// decodeURLKey decodes the base64 URL key of a request, nil when missing or malformed
+func decodeURLKey(encKeyBase64 string) []byte {
+	key, err := encryption.ConstantTimeDecode(encKeyBase64, encryption.URLKeySize)
+	if err != nil {
+		return nil
+	}
+	return key
+}
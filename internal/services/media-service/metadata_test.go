@@ -0,0 +1,121 @@
+package mediaservice
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"testing"
+
+	"lovebin/modules/encryption"
+)
+
+// ptr returns a pointer to s, nil for the empty string
+func ptr(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func TestFileMetadataRoundTrip(t *testing.T) {
+	key := make([]byte, encryption.URLKeySize)
+	rand.Read(key)
+
+	tests := []struct {
+		name, filename, extension string
+	}{
+		{"filename and extension", "holiday.jpg", "jpg"},
+		{"filename only", "README", ""},
+		{"extension only", "", "png"},
+		{"separator in filename", "a|b|c.txt", "txt"},
+		{"unicode", "отпуск.jpg", "jpg"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sealed, err := sealFileMetadata(ptr(tt.filename), ptr(tt.extension), key)
+			if err != nil {
+				t.Fatalf("sealFileMetadata: %v", err)
+			}
+			if tt.filename != "" && bytes.Contains(sealed, []byte(tt.filename)) {
+				t.Error("sealed metadata contains the filename")
+			}
+
+			name, ext := openFileMetadata(nil, nil, sealed, key)
+			if name != nil && *name != tt.filename || name == nil && tt.filename != "" {
+				t.Errorf("filename = %v, want %q", name, tt.filename)
+			}
+			if ext != nil && *ext != tt.extension || ext == nil && tt.extension != "" {
+				t.Errorf("extension = %v, want %q", ext, tt.extension)
+			}
+		})
+	}
+
+	if sealed, err := sealFileMetadata(nil, nil, key); sealed != nil || err != nil {
+		t.Errorf("sealFileMetadata without metadata = %v, %v, want nil", sealed, err)
+	}
+}
+
+func TestOpenFileMetadata(t *testing.T) {
+	key := make([]byte, encryption.URLKeySize)
+	rand.Read(key)
+	otherKey := make([]byte, encryption.URLKeySize)
+	rand.Read(otherKey)
+	sealed, err := sealFileMetadata(ptr("secret.pdf"), ptr("pdf"), key)
+	if err != nil {
+		t.Fatalf("sealFileMetadata: %v", err)
+	}
+
+	tests := []struct {
+		name                string
+		filename, extension *string
+		sealed, key         []byte
+		wantName, wantExt   string
+	}{
+		{"sealed", nil, nil, sealed, key, "secret.pdf", "pdf"},
+		{"missing key", nil, nil, sealed, nil, "", ""},
+		{"wrong key", nil, nil, sealed, otherKey, "", ""},
+		{"corrupted", nil, nil, sealed[:len(sealed)-1], key, "", ""},
+		{"legacy plaintext", ptr("old.png"), ptr("png"), nil, nil, "old.png", "png"},
+		{"legacy with key", ptr("old.png"), ptr("png"), nil, key, "old.png", "png"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, ext := openFileMetadata(tt.filename, tt.extension, tt.sealed, tt.key)
+			if got := deref(name); got != tt.wantName {
+				t.Errorf("filename = %q, want %q", got, tt.wantName)
+			}
+			if got := deref(ext); got != tt.wantExt {
+				t.Errorf("extension = %q, want %q", got, tt.wantExt)
+			}
+		})
+	}
+}
+
+// deref returns *s, the empty string for nil
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func TestUploadMediaSealsMetadata(t *testing.T) {
+	svc := newTestService(t, Config{})
+	resourceKey, encKey := svc.upload(t, UploadRequest{Data: bytes.NewReader([]byte("data")), Filename: "holiday.png"})
+
+	stored, ok := svc.repo.resource(resourceKey)
+	if !ok {
+		t.Fatal("resource not stored")
+	}
+	if stored.Filename != nil || stored.FileExtension != nil || stored.MetadataEncrypted == nil {
+		t.Errorf("stored filename %v, extension %v, want them sealed", stored.Filename, stored.FileExtension)
+	}
+
+	info, err := svc.GetMediaInfo(context.Background(), resourceKey, encKey)
+	if err != nil {
+		t.Fatalf("GetMediaInfo: %v", err)
+	}
+	if deref(info.Filename) != "holiday" || deref(info.FileExtension) != "png" || !info.IsImage {
+		t.Errorf("GetMediaInfo = %q, %q, image %v, want holiday and png", deref(info.Filename), deref(info.FileExtension), info.IsImage)
+	}
+}
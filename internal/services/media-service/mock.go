@@ -0,0 +1,400 @@
+package mediaservice
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	mediarepo "lovebin/internal/services/media-service/repository"
+)
+
+// MockRepository is an in-memory Repository for tests that construct a Service without a database.
+// Per-resource mutexes stand in for the advisory locks of GetMediaResourceByKeyWithLock and ReplaceSalt.
+type MockRepository struct {
+	mu          sync.Mutex
+	resources   map[string]mediarepo.MediaResourceResult
+	accessCodes map[string][]string // unused code hashes
+	usedCodes   map[string][]string // redeemed code hashes
+	locks       map[string]*sync.Mutex
+	lockWaits   int                                        // blocking GetMediaResourceByKeyWithLock calls
+	notified    map[string]bool                            // resources recorded by MarkExpiryNotified
+	events      map[string][]mediarepo.ResourceEventResult // returned by GetResourceEvents, see addEvent
+}
+
+var _ Repository = (*MockRepository)(nil)
+
+// NewMockRepository creates an empty MockRepository
+func NewMockRepository() *MockRepository {
+	return &MockRepository{
+		resources:   make(map[string]mediarepo.MediaResourceResult),
+		accessCodes: make(map[string][]string),
+		usedCodes:   make(map[string][]string),
+		locks:       make(map[string]*sync.Mutex),
+		notified:    make(map[string]bool),
+		events:      make(map[string][]mediarepo.ResourceEventResult),
+	}
+}
+
+// resource returns the stored state of a resource, whatever its expiry
+func (r *MockRepository) resource(resourceKey string) (mediarepo.MediaResourceResult, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	resource, ok := r.resources[resourceKey]
+	return resource, ok
+}
+
+func (r *MockRepository) update(resourceKey string, fn func(*mediarepo.MediaResourceResult)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	resource := r.resources[resourceKey]
+	fn(&resource)
+	r.resources[resourceKey] = resource
+}
+
+func (r *MockRepository) lock(resourceKey string) *sync.Mutex {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	l, ok := r.locks[resourceKey]
+	if !ok {
+		l = &sync.Mutex{}
+		r.locks[resourceKey] = l
+	}
+	return l
+}
+
+// active returns a resource unless it is expired, as the queries filter them
+func (r *MockRepository) active(resourceKey string) (mediarepo.MediaResourceResult, error) {
+	resource, ok := r.resource(resourceKey)
+	if !ok || (resource.ExpiresAt != nil && !resource.ExpiresAt.After(time.Now())) {
+		return mediarepo.MediaResourceResult{}, pgx.ErrNoRows
+	}
+	return resource, nil
+}
+
+func (r *MockRepository) CreateMediaResource(_ context.Context, arg mediarepo.CreateMediaResourceInput) (mediarepo.MediaResourceResult, error) {
+	resource := mediarepo.MediaResourceResult{
+		ID:                 "id-" + arg.ResourceKey,
+		ResourceKey:        arg.ResourceKey,
+		PasswordHash:       arg.PasswordHash,
+		ExpiresAt:          arg.ExpiresAt,
+		CreatedAt:          time.Now(),
+		Salt:               arg.Salt,
+		Filename:           arg.Filename,
+		FileExtension:      arg.FileExtension,
+		BlurIntensity:      arg.BlurIntensity,
+		DownloadOnly:       arg.DownloadOnly,
+		UploadIP:           arg.UploadIP,
+		KeyCheck:           arg.KeyCheck,
+		AvailableAt:        arg.AvailableAt,
+		KeyVersion:         arg.KeyVersion,
+		EncryptedSize:      arg.EncryptedSize,
+		VerificationPubkey: arg.VerificationPubkey,
+		MetadataEncrypted:  arg.MetadataEncrypted,
+		MaxViews:           max(arg.MaxViews, 1),
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.resources[arg.ResourceKey] = resource
+	return resource, nil
+}
+
+func (r *MockRepository) GetMediaResourceByKey(_ context.Context, resourceKey string) (mediarepo.MediaResourceResult, error) {
+	resource, err := r.active(resourceKey)
+	if err == nil && resource.Viewed {
+		return mediarepo.MediaResourceResult{}, pgx.ErrNoRows
+	}
+	return resource, err
+}
+
+func (r *MockRepository) GetMediaResourceByKeyAny(_ context.Context, resourceKey string) (mediarepo.MediaResourceResult, error) {
+	return r.active(resourceKey)
+}
+
+func (r *MockRepository) IncrementViewCount(_ context.Context, resourceKey string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	resource, ok := r.resources[resourceKey]
+	if !ok {
+		return 0, pgx.ErrNoRows
+	}
+	resource.ViewCount++
+	resource.Viewed = resource.ViewCount >= resource.MaxViews
+	r.resources[resourceKey] = resource
+	return resource.ViewCount, nil
+}
+
+func (r *MockRepository) DeleteMediaResource(_ context.Context, resourceKey string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.resources, resourceKey)
+	return nil
+}
+
+func (r *MockRepository) GetRecentUploadsByIP(_ context.Context, ip string, limit int) ([]mediarepo.MediaResourceResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	var uploads []mediarepo.MediaResourceResult
+	for _, resource := range r.resources {
+		if resource.UploadIP == nil || *resource.UploadIP != ip || resource.Viewed ||
+			(resource.ExpiresAt != nil && !resource.ExpiresAt.After(now)) {
+			continue
+		}
+		uploads = append(uploads, resource)
+	}
+	sort.Slice(uploads, func(i, j int) bool { return uploads[i].CreatedAt.After(uploads[j].CreatedAt) })
+	if len(uploads) > limit {
+		uploads = uploads[:limit]
+	}
+	return uploads, nil
+}
+
+func (r *MockRepository) GetEncryptedSizeByIP(_ context.Context, ip string) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var total int64
+	for _, resource := range r.resources {
+		if resource.UploadIP != nil && *resource.UploadIP == ip && resource.EncryptedSize != nil && !resource.Viewed {
+			total += *resource.EncryptedSize
+		}
+	}
+	return total, nil
+}
+
+func (r *MockRepository) DeleteUploadsByIP(_ context.Context, ip string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var resourceKeys []string
+	for resourceKey, resource := range r.resources {
+		if resource.UploadIP != nil && *resource.UploadIP == ip {
+			resourceKeys = append(resourceKeys, resourceKey)
+			delete(r.resources, resourceKey)
+		}
+	}
+	return resourceKeys, nil
+}
+
+func (r *MockRepository) GetMediaResourceForView(_ context.Context, resourceKey string) (mediarepo.MediaResourceResult, error) {
+	return r.active(resourceKey)
+}
+
+func (r *MockRepository) GetMediaResourceForExport(_ context.Context, resourceKey string) (mediarepo.MediaResourceResult, error) {
+	resource, ok := r.resource(resourceKey)
+	if !ok {
+		return mediarepo.MediaResourceResult{}, pgx.ErrNoRows
+	}
+	return resource, nil
+}
+
+func (r *MockRepository) GetExpiredResources(context.Context) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	var resourceKeys []string
+	for resourceKey, resource := range r.resources {
+		if resource.ExpiresAt != nil && !resource.ExpiresAt.After(now) {
+			resourceKeys = append(resourceKeys, resourceKey)
+		}
+	}
+	return resourceKeys, nil
+}
+
+func (r *MockRepository) DeleteExpiredResources(context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for resourceKey, resource := range r.resources {
+		if resource.ExpiresAt != nil && !resource.ExpiresAt.After(now) {
+			delete(r.resources, resourceKey)
+		}
+	}
+	return nil
+}
+
+func (r *MockRepository) DeleteViewedResources(_ context.Context, keepKeys []string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var resourceKeys []string
+	for resourceKey, resource := range r.resources {
+		if resource.Viewed && !slices.Contains(keepKeys, resourceKey) {
+			resourceKeys = append(resourceKeys, resourceKey)
+			delete(r.resources, resourceKey)
+		}
+	}
+	return resourceKeys, nil
+}
+
+func (r *MockRepository) GetMediaResourceByKeyWithLock(ctx context.Context, resourceKey string, blocking bool, accessCodeHash string, view func(mediarepo.MediaResourceResult) error) (int, error) {
+	l := r.lock(resourceKey)
+	if blocking {
+		r.mu.Lock()
+		r.lockWaits++
+		r.mu.Unlock()
+		l.Lock()
+	} else if !l.TryLock() {
+		return 0, mediarepo.ErrResourceLocked
+	}
+	defer l.Unlock()
+
+	resource, err := r.active(resourceKey)
+	if err != nil {
+		return 0, err
+	}
+	if err := view(resource); err != nil {
+		return 0, err
+	}
+	if err := r.redeemAccessCode(resourceKey, accessCodeHash); err != nil {
+		return 0, err
+	}
+	return r.IncrementViewCount(ctx, resourceKey)
+}
+
+// redeemAccessCode uses up the code of codeHash if the resource has access codes
+func (r *MockRepository) redeemAccessCode(resourceKey, codeHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	codes := r.accessCodes[resourceKey]
+	if i := slices.Index(codes, codeHash); i >= 0 {
+		r.accessCodes[resourceKey] = slices.Delete(codes, i, i+1)
+		r.usedCodes[resourceKey] = append(r.usedCodes[resourceKey], codeHash)
+		return nil
+	}
+	if len(codes) > 0 || len(r.usedCodes[resourceKey]) > 0 {
+		return mediarepo.ErrAccessCodeNotRedeemed
+	}
+	return nil
+}
+
+func (r *MockRepository) ReplaceSalt(_ context.Context, resourceKey string, update func(mediarepo.MediaResourceResult) (mediarepo.SaltUpdate, error)) error {
+	l := r.lock(resourceKey)
+	l.Lock()
+	defer l.Unlock()
+
+	resource, err := r.active(resourceKey)
+	if err != nil {
+		return err
+	}
+	newSalt, err := update(resource)
+	if err != nil {
+		return err
+	}
+	r.update(resourceKey, func(resource *mediarepo.MediaResourceResult) {
+		resource.Salt = newSalt.Salt
+		resource.KeyVersion = newSalt.KeyVersion
+		resource.MetadataEncrypted = newSalt.MetadataEncrypted
+		resource.KeyCheck = newSalt.KeyCheck
+		resource.EncryptedSize = &newSalt.EncryptedSize
+	})
+	return nil
+}
+
+func (r *MockRepository) CreateAccessCodes(_ context.Context, resourceKey string, codeHashes []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.accessCodes[resourceKey] = append(r.accessCodes[resourceKey], codeHashes...)
+	return nil
+}
+
+func (r *MockRepository) CountUnusedAccessCodes(_ context.Context, resourceKey string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.accessCodes[resourceKey]), nil
+}
+
+func (r *MockRepository) UpdateEncryptedSize(_ context.Context, resourceKey string, size int64) error {
+	r.update(resourceKey, func(resource *mediarepo.MediaResourceResult) {
+		if resource.EncryptedSize == nil {
+			resource.EncryptedSize = &size
+		}
+	})
+	return nil
+}
+
+func (r *MockRepository) GetResourcesWithoutEncryptedSize(_ context.Context, limit int) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	var resources []mediarepo.MediaResourceResult
+	for _, resource := range r.resources {
+		if resource.EncryptedSize != nil || resource.Viewed ||
+			(resource.ExpiresAt != nil && !resource.ExpiresAt.After(now)) {
+			continue
+		}
+		resources = append(resources, resource)
+	}
+	sort.Slice(resources, func(i, j int) bool { return resources[i].CreatedAt.Before(resources[j].CreatedAt) })
+	if len(resources) > limit {
+		resources = resources[:limit]
+	}
+	keys := make([]string, 0, len(resources))
+	for _, resource := range resources {
+		keys = append(keys, resource.ResourceKey)
+	}
+	return keys, nil
+}
+
+func (r *MockRepository) GetResourcesExpiringBetween(_ context.Context, from, to time.Time, after mediarepo.ExpiryCursor, limit int) ([]mediarepo.MediaResourceResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var page []mediarepo.MediaResourceResult
+	for _, resource := range r.resources {
+		if resource.ExpiresAt == nil || resource.Viewed || r.notified[resource.ResourceKey] {
+			continue
+		}
+		expiresAt := *resource.ExpiresAt
+		if expiresAt.Before(from) || expiresAt.After(to) {
+			continue
+		}
+		if expiresAt.Before(after.ExpiresAt) || (expiresAt.Equal(after.ExpiresAt) && resource.ResourceKey <= after.ResourceKey) {
+			continue
+		}
+		page = append(page, resource)
+	}
+	sort.Slice(page, func(i, j int) bool {
+		if !page[i].ExpiresAt.Equal(*page[j].ExpiresAt) {
+			return page[i].ExpiresAt.Before(*page[j].ExpiresAt)
+		}
+		return page[i].ResourceKey < page[j].ResourceKey
+	})
+	if len(page) > limit {
+		page = page[:limit]
+	}
+	return page, nil
+}
+
+func (r *MockRepository) MarkExpiryNotified(_ context.Context, resourceKey string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.notified[resourceKey] = true
+	return nil
+}
+
+// addEvent records an event of a resource, like an audit entry or a used access code
+func (r *MockRepository) addEvent(resourceKey string, event mediarepo.ResourceEventResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events[resourceKey] = append(r.events[resourceKey], event)
+}
+
+func (r *MockRepository) GetResourceEvents(_ context.Context, resourceKey string, from, to time.Time, limit int) ([]mediarepo.ResourceEventResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var events []mediarepo.ResourceEventResult
+	for _, event := range r.events[resourceKey] {
+		if (from.IsZero() || !event.At.Before(from)) && (to.IsZero() || event.At.Before(to)) {
+			events = append(events, event)
+		}
+	}
+	slices.SortStableFunc(events, func(a, b mediarepo.ResourceEventResult) int {
+		return a.At.Compare(b.At)
+	})
+	return events[:min(len(events), limit)], nil
+}
+
+func (r *MockRepository) WithTx(pgx.Tx) Repository {
+	return r
+}
@@ -0,0 +1,124 @@
+package mediaservice
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	mediarepo "lovebin/internal/services/media-service/repository"
+	"lovebin/modules/timeparser"
+)
+
+const (
+	// expiryNotifyPageSize bounds how many resources one query of NotifyExpiringResources loads
+	expiryNotifyPageSize = 100
+
+	// expiryWebhookTimeout bounds a single webhook delivery
+	expiryWebhookTimeout = 10 * time.Second
+)
+
+// ErrNoExpiryNotifier is returned by NotifyExpiringResources when no notifier is configured
+var ErrNoExpiryNotifier = errors.New("no expiry notifier configured")
+
+// ExpiryNotifier delivers the notice that a resource is about to expire
+type ExpiryNotifier interface {
+	NotifyExpiry(ctx context.Context, resource MediaResource) error
+}
+
+// ExpiryWebhookPayload is the JSON body WebhookNotifier posts for each resource
+type ExpiryWebhookPayload struct {
+	ResourceKey string                   `json:"resource_key"`
+	ExpiresAt   timeparser.UniversalTime `json:"expires_at"`
+}
+
+// WebhookNotifier posts an ExpiryWebhookPayload per expiring resource to a fixed URL
+type WebhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookNotifier returns a notifier posting to url
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{url: url, client: &http.Client{Timeout: expiryWebhookTimeout}}
+}
+
+func (n *WebhookNotifier) NotifyExpiry(ctx context.Context, resource MediaResource) error {
+	body, err := json.Marshal(ExpiryWebhookPayload{
+		ResourceKey: resource.ResourceKey,
+		ExpiresAt:   resource.ExpiresAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("expiry webhook answered %s", resp.Status)
+	}
+	return nil
+}
+
+// NotifyExpiringResources notifies about unviewed resources expiring within window
+// from now and returns how many notifications were sent. Every resource is notified
+// once; failed deliveries are logged and retried on the next run. With dryRun the
+// notifications are only logged and nothing is recorded, so a later run sends them.
+func (s *Service) NotifyExpiringResources(ctx context.Context, window time.Duration, dryRun bool) (int, error) {
+	if s.notifier == nil && !dryRun {
+		return 0, ErrNoExpiryNotifier
+	}
+
+	now := time.Now().UTC()
+	from, to := now, now.Add(window)
+
+	sent := 0
+	var cursor mediarepo.ExpiryCursor
+	for {
+		page, err := s.repo.GetResourcesExpiringBetween(ctx, from, to, cursor, expiryNotifyPageSize)
+		if err != nil {
+			return sent, err
+		}
+
+		for _, repoResource := range page {
+			resource := repoToServiceMediaResource(repoResource)
+			fields := []zap.Field{zap.String("resource_key", resource.ResourceKey), zap.Time("expires_at", resource.ExpiresAt.Time)}
+
+			if dryRun {
+				s.logger.InfoCtx(ctx, "dry run: would notify of upcoming expiry", fields...)
+				sent++
+				continue
+			}
+
+			if err := s.notifier.NotifyExpiry(ctx, resource); err != nil {
+				s.logger.WarnCtx(ctx, "failed to notify of upcoming expiry", append(fields, zap.Error(err))...)
+				continue
+			}
+			if err := s.repo.MarkExpiryNotified(ctx, resource.ResourceKey); err != nil {
+				// Sent but not recorded, the next run notifies again
+				s.logger.ErrorCtx(ctx, "failed to record expiry notification", append(fields, zap.Error(err))...)
+			}
+			sent++
+		}
+
+		if len(page) < expiryNotifyPageSize {
+			return sent, nil
+		}
+		last := page[len(page)-1]
+		cursor = mediarepo.ExpiryCursor{ExpiresAt: *last.ExpiresAt, ResourceKey: last.ResourceKey}
+	}
+}
@@ -0,0 +1,149 @@
+package mediaservice
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	mediarepo "lovebin/internal/services/media-service/repository"
+)
+
+// recordingNotifier records notified resource keys and fails for those in fail
+type recordingNotifier struct {
+	mu       sync.Mutex
+	notified []string
+	fail     map[string]bool
+}
+
+func (n *recordingNotifier) NotifyExpiry(_ context.Context, resource MediaResource) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.fail[resource.ResourceKey] {
+		return errors.New("delivery failed")
+	}
+	n.notified = append(n.notified, resource.ResourceKey)
+	return nil
+}
+
+// addExpiring stores a resource expiring in expiresIn, a zero expiresIn never expires
+func (s *testService) addExpiring(resourceKey string, expiresIn time.Duration, viewed bool) {
+	s.repo.update(resourceKey, func(r *mediarepo.MediaResourceResult) {
+		r.ResourceKey = resourceKey
+		r.Viewed = viewed
+		if expiresIn != 0 {
+			expiresAt := time.Now().Add(expiresIn)
+			r.ExpiresAt = &expiresAt
+		}
+	})
+}
+
+func TestNotifyExpiringResources(t *testing.T) {
+	svc := newTestService(t, Config{})
+	notifier := &recordingNotifier{fail: map[string]bool{"failing": true}}
+	svc.notifier = notifier
+
+	svc.addExpiring("soon", time.Hour, false)
+	svc.addExpiring("sooner", time.Minute, false)
+	svc.addExpiring("failing", 2*time.Hour, false)
+	svc.addExpiring("later", 48*time.Hour, false)
+	svc.addExpiring("viewed", time.Hour, true)
+	svc.addExpiring("expired", -time.Hour, false)
+	svc.addExpiring("forever", 0, false)
+
+	sent, err := svc.NotifyExpiringResources(context.Background(), 24*time.Hour, false)
+	if err != nil {
+		t.Fatalf("NotifyExpiringResources: %v", err)
+	}
+	if want := []string{"sooner", "soon"}; sent != 2 || !slices.Equal(notifier.notified, want) {
+		t.Errorf("sent %d: %v, want %v in expiry order", sent, notifier.notified, want)
+	}
+
+	// Sent notifications are not repeated, failed ones are retried
+	delete(notifier.fail, "failing")
+	sent, err = svc.NotifyExpiringResources(context.Background(), 24*time.Hour, false)
+	if err != nil || sent != 1 || notifier.notified[len(notifier.notified)-1] != "failing" {
+		t.Errorf("second run sent %d, %v: %v, want only the failed one", sent, err, notifier.notified)
+	}
+	if sent, _ := svc.NotifyExpiringResources(context.Background(), 24*time.Hour, false); sent != 0 {
+		t.Errorf("third run sent %d, want 0", sent)
+	}
+}
+
+func TestNotifyExpiringResourcesDryRun(t *testing.T) {
+	svc := newTestService(t, Config{})
+	svc.addExpiring("soon", time.Hour, false)
+
+	// A dry run needs no notifier and records nothing
+	for range 2 {
+		if sent, err := svc.NotifyExpiringResources(context.Background(), 24*time.Hour, true); err != nil || sent != 1 {
+			t.Errorf("dry run = %d, %v, want 1", sent, err)
+		}
+	}
+
+	if _, err := svc.NotifyExpiringResources(context.Background(), 24*time.Hour, false); !errors.Is(err, ErrNoExpiryNotifier) {
+		t.Errorf("NotifyExpiringResources without a notifier error = %v, want ErrNoExpiryNotifier", err)
+	}
+}
+
+func TestNotifyExpiringResourcesPages(t *testing.T) {
+	svc := newTestService(t, Config{})
+	notifier := &recordingNotifier{}
+	svc.notifier = notifier
+
+	// More than a page, with resources sharing an expiry across the page boundary
+	count := 2*expiryNotifyPageSize + 10
+	for i := range count {
+		svc.addExpiring(fmt.Sprintf("resource-%03d", i), time.Hour+time.Duration(i/3)*time.Second, false)
+	}
+
+	sent, err := svc.NotifyExpiringResources(context.Background(), 24*time.Hour, false)
+	if err != nil || sent != count {
+		t.Fatalf("NotifyExpiringResources = %d, %v, want %d", sent, err, count)
+	}
+	seen := make(map[string]bool)
+	for _, key := range notifier.notified {
+		if seen[key] {
+			t.Errorf("%s notified twice", key)
+		}
+		seen[key] = true
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var got ExpiryWebhookPayload
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("webhook request %s with Content-Type %q", r.Method, r.Header.Get("Content-Type"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode webhook payload: %v", err)
+		}
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	svc := newTestService(t, Config{ExpiryWebhookURL: server.URL})
+	svc.addExpiring("soon", time.Hour, false)
+	resource, _ := svc.repo.resource("soon")
+
+	if sent, err := svc.NotifyExpiringResources(context.Background(), 24*time.Hour, false); err != nil || sent != 1 {
+		t.Fatalf("NotifyExpiringResources = %d, %v, want 1", sent, err)
+	}
+	if got.ResourceKey != "soon" || !got.ExpiresAt.Equal(resource.ExpiresAt.Truncate(time.Second)) {
+		t.Errorf("payload = %+v, want soon expiring at %s", got, resource.ExpiresAt)
+	}
+
+	status = http.StatusInternalServerError
+	notifier := NewWebhookNotifier(server.URL)
+	if err := notifier.NotifyExpiry(context.Background(), MediaResource{ResourceKey: "soon"}); err == nil {
+		t.Error("NotifyExpiry succeeded with a 500 answer")
+	}
+}
@@ -0,0 +1,150 @@
+package mediaservice
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"lovebin/modules/encryption"
+)
+
+// openPresigned decrypts a PresignScheme blob the way a browser does
+func openPresigned(t *testing.T, blob []byte, encKey string) []byte {
+	t.Helper()
+	rawKey, err := base64.RawURLEncoding.DecodeString(encKey)
+	if err != nil {
+		t.Fatalf("decode URL key: %v", err)
+	}
+	key := sha256.Sum256(rawKey)
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		t.Fatalf("aes.NewCipher: %v", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatalf("cipher.NewGCM: %v", err)
+	}
+	if len(blob) < gcm.NonceSize() {
+		t.Fatalf("blob of %d bytes has no nonce", len(blob))
+	}
+	plaintext, err := gcm.Open(nil, blob[:gcm.NonceSize()], blob[gcm.NonceSize():], nil)
+	if err != nil {
+		t.Fatalf("open blob: %v", err)
+	}
+	return plaintext
+}
+
+func TestPresignDownload(t *testing.T) {
+	svc := newTestService(t, Config{PresignTTL: 2 * time.Minute})
+	// An unknown size keeps the upload in one sealed blob
+	resourceKey, encKey := svc.upload(t, UploadRequest{Data: io.MultiReader(strings.NewReader("browser content")), Filename: "notes.txt"})
+	ctx := context.Background()
+
+	before := time.Now()
+	resp, err := svc.PresignDownload(ctx, &DownloadRequest{ResourceKey: resourceKey, EncKeyBase64: encKey})
+	if err != nil {
+		t.Fatalf("PresignDownload: %v", err)
+	}
+	if !strings.Contains(resp.URL, "/media/"+resourceKey+"?expires=") {
+		t.Errorf("URL = %q, want the encrypted object", resp.URL)
+	}
+	if expiresAt := resp.ExpiresAt.Time; expiresAt.Before(before.Add(2*time.Minute)) || expiresAt.After(time.Now().Add(2*time.Minute)) {
+		t.Errorf("ExpiresAt = %v, want the configured TTL from now", expiresAt)
+	}
+	if resp.Scheme != PresignScheme {
+		t.Errorf("Scheme = %q, want %q", resp.Scheme, PresignScheme)
+	}
+	if resp.Filename == nil || *resp.Filename != "notes" || resp.FileExtension == nil || *resp.FileExtension != "txt" {
+		t.Errorf("filename = %v.%v, want notes.txt", resp.Filename, resp.FileExtension)
+	}
+
+	// The object behind the URL opens with the URL key alone
+	blob, ok := svc.storage.Object("", "media/"+resourceKey)
+	if !ok {
+		t.Fatal("encrypted object was deleted")
+	}
+	if got := openPresigned(t, blob, encKey); string(got) != "browser content" {
+		t.Errorf("decrypted blob = %q, want the uploaded content", got)
+	}
+
+	// The view is consumed
+	if _, err := svc.PresignDownload(ctx, &DownloadRequest{ResourceKey: resourceKey, EncKeyBase64: encKey}); !errors.Is(err, ErrAlreadyViewed) {
+		t.Errorf("second PresignDownload error = %v, want ErrAlreadyViewed", err)
+	}
+}
+
+func TestPresignDownloadStream(t *testing.T) {
+	svc := newTestService(t, Config{})
+	content := bytes.Repeat([]byte("x"), encryption.StreamChunkSize+1)
+	// A known size makes the upload stream
+	resourceKey, encKey := svc.upload(t, UploadRequest{Data: bytes.NewReader(content)})
+
+	resp, err := svc.PresignDownload(context.Background(), &DownloadRequest{ResourceKey: resourceKey, EncKeyBase64: encKey})
+	if err != nil {
+		t.Fatalf("PresignDownload: %v", err)
+	}
+	if resp.Scheme != PresignSchemeChunked {
+		t.Errorf("Scheme = %q, want %q", resp.Scheme, PresignSchemeChunked)
+	}
+}
+
+func TestPresignDownloadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		upload  UploadRequest
+		encKey  func(encKey string) string
+		siv     bool
+		wantErr error
+	}{
+		{"missing key", UploadRequest{}, func(string) string { return "" }, false, ErrMissingEncryptionKey},
+		{"malformed key", UploadRequest{}, func(string) string { return "not a key" }, false, ErrInvalidEncryptionKey},
+		{"wrong key", UploadRequest{}, func(string) string { return newURLKey(t) }, false, ErrInvalidEncryptionKey},
+		{"password", UploadRequest{Password: "correct horse battery staple"}, nil, false, ErrPresignUnsupported},
+		{"AES-SIV", UploadRequest{}, nil, true, ErrPresignUnsupported},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestService(t, Config{})
+			tt.upload.Data = bytes.NewReader([]byte("data"))
+			resourceKey, encKey := svc.upload(t, tt.upload)
+			if tt.encKey != nil {
+				encKey = tt.encKey(encKey)
+			}
+			if tt.siv {
+				cfg := fastKDF
+				cfg.Cipher = encryption.CipherAESSIV
+				svc.Service.encryption = encryption.Init(cfg, nil)
+			}
+
+			_, err := svc.PresignDownload(context.Background(), &DownloadRequest{ResourceKey: resourceKey, EncKeyBase64: encKey})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("PresignDownload error = %v, want %v", err, tt.wantErr)
+			}
+			if n := svc.storage.Calls("PresignGetURL"); n != 0 {
+				t.Errorf("PresignGetURL called %d times", n)
+			}
+			// A rejected request does not burn the view
+			if tt.encKey == nil && !tt.siv {
+				return
+			}
+			if resource, _ := svc.repo.resource(resourceKey); resource.Viewed {
+				t.Error("resource viewed after a rejected presign")
+			}
+		})
+	}
+}
+
+func TestPresignDownloadNotFound(t *testing.T) {
+	svc := newTestService(t, Config{})
+	if _, err := svc.PresignDownload(context.Background(), &DownloadRequest{ResourceKey: "missing", EncKeyBase64: newURLKey(t)}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("PresignDownload(missing) error = %v, want ErrNotFound", err)
+	}
+}
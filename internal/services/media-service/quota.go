@@ -0,0 +1,37 @@
+package mediaservice
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrStorageQuotaExceeded is returned by UploadMedia when the upload would take
+// the uploader's IP over Config.MaxStoragePerIPBytes
+var ErrStorageQuotaExceeded = errors.New("storage quota exceeded")
+
+// GetDiskUsageByIP returns how many bytes the active uploads made from ip take
+// in storage. Only resources whose encrypted size is recorded are counted.
+func (s *Service) GetDiskUsageByIP(ctx context.Context, ip string) (int64, error) {
+	return s.repo.GetEncryptedSizeByIP(ctx, ip)
+}
+
+// checkStorageQuota fails with ErrStorageQuotaExceeded when storing size more
+// bytes would take ip over the quota. Uploads without a known IP, exempt IPs and
+// a zero quota are not limited.
+func (s *Service) checkStorageQuota(ctx context.Context, ip string, size int64) error {
+	if s.maxStoragePerIP <= 0 || ip == "" {
+		return nil
+	}
+	if _, exempt := s.quotaExempt[ip]; exempt {
+		return nil
+	}
+
+	usage, err := s.GetDiskUsageByIP(ctx, ip)
+	if err != nil {
+		return err
+	}
+	if usage+size > s.maxStoragePerIP {
+		return ErrStorageQuotaExceeded
+	}
+	return nil
+}
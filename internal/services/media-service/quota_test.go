@@ -0,0 +1,89 @@
+package mediaservice
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	mediarepo "lovebin/internal/services/media-service/repository"
+)
+
+// addStored stores a resource uploaded from ip taking size bytes in storage
+func (s *testService) addStored(resourceKey, ip string, size int64, viewed bool) {
+	s.repo.update(resourceKey, func(r *mediarepo.MediaResourceResult) {
+		r.ResourceKey = resourceKey
+		r.UploadIP = &ip
+		r.EncryptedSize = &size
+		r.Viewed = viewed
+	})
+}
+
+func TestCheckStorageQuota(t *testing.T) {
+	tests := []struct {
+		name    string
+		quota   int64
+		ip      string
+		size    int64
+		wantErr bool
+	}{
+		{"below the quota", 1000, "10.0.0.1", 100, false},
+		{"up to the quota", 1000, "10.0.0.1", 400, false},
+		{"over the quota", 1000, "10.0.0.1", 401, true},
+		{"other IP", 1000, "10.0.0.2", 1000, false},
+		{"exempt IP", 1000, "10.0.0.9", 1 << 40, false},
+		{"unknown IP", 1000, "", 1 << 40, false},
+		{"quota disabled", 0, "10.0.0.1", 1 << 40, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestService(t, Config{MaxStoragePerIPBytes: tt.quota, QuotaExemptIPs: []string{"10.0.0.9"}})
+			svc.addStored("a", "10.0.0.1", 500, false)
+			svc.addStored("b", "10.0.0.1", 100, false)
+			svc.addStored("viewed", "10.0.0.1", 10000, true) // deleted soon, not counted
+			svc.addStored("exempt", "10.0.0.9", 10000, false)
+
+			err := svc.checkStorageQuota(context.Background(), tt.ip, tt.size)
+			if tt.wantErr != errors.Is(err, ErrStorageQuotaExceeded) || (!tt.wantErr && err != nil) {
+				t.Errorf("checkStorageQuota = %v, want quota exceeded %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUploadMediaStorageQuota(t *testing.T) {
+	content := bytes.Repeat([]byte("x"), 1000)
+
+	// Measure what one upload takes in storage
+	probe := newTestService(t, Config{})
+	resourceKey, _ := probe.upload(t, UploadRequest{Data: bytes.NewReader(content), UploadIP: "10.0.0.1"})
+	usage, err := probe.GetDiskUsageByIP(context.Background(), "10.0.0.1")
+	if err != nil || usage <= int64(len(content)) {
+		t.Fatalf("GetDiskUsageByIP = %d, %v, want the encrypted size of %s", usage, err, resourceKey)
+	}
+
+	svc := newTestService(t, Config{MaxStoragePerIPBytes: 2 * usage})
+	for range 2 {
+		svc.upload(t, UploadRequest{Data: bytes.NewReader(content), UploadIP: "10.0.0.1"})
+	}
+	uploads := svc.storage.Calls("UploadStream") + svc.storage.Calls("Upload")
+	_, err = svc.UploadMedia(context.Background(), UploadRequest{Data: bytes.NewReader(content), Size: int64(len(content)), UploadIP: "10.0.0.1"})
+	if !errors.Is(err, ErrStorageQuotaExceeded) {
+		t.Fatalf("third upload error = %v, want ErrStorageQuotaExceeded", err)
+	}
+	if n := svc.storage.Calls("UploadStream") + svc.storage.Calls("Upload"); n != uploads {
+		t.Errorf("the rejected upload reached storage")
+	}
+
+	// Without a size the upload is only found over the quota once stored, and removed again
+	_, err = svc.UploadMedia(context.Background(), UploadRequest{Data: bytes.NewReader(content), Size: -1, UploadIP: "10.0.0.1"})
+	if !errors.Is(err, ErrStorageQuotaExceeded) {
+		t.Fatalf("upload of unknown size error = %v, want ErrStorageQuotaExceeded", err)
+	}
+	if keys, _ := svc.storage.ListObjects(context.Background(), "", "media/"); len(keys) != 2 {
+		t.Errorf("objects stored = %v, want the 2 accepted uploads", keys)
+	}
+
+	// The quota is per IP
+	svc.upload(t, UploadRequest{Data: bytes.NewReader(content), UploadIP: "10.0.0.2"})
+}
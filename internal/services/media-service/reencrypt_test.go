@@ -0,0 +1,198 @@
+package mediaservice
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io"
+	"testing"
+
+	"lovebin/modules/encryption"
+	"lovebin/modules/s3"
+)
+
+func download(t *testing.T, svc *testService, resourceKey, encKey, password string) ([]byte, error) {
+	t.Helper()
+	resp, err := svc.DownloadMedia(context.Background(), &DownloadRequest{ResourceKey: resourceKey, EncKeyBase64: encKey, Password: password})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Data.Close()
+	return io.ReadAll(resp.Data)
+}
+
+// newURLKey returns a random URL key as it appears in resource links
+func newURLKey(t *testing.T) string {
+	t.Helper()
+	key := make([]byte, encryption.URLKeySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("rand.Read: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(key)
+}
+
+func TestReencryptResource(t *testing.T) {
+	content := bytes.Repeat([]byte("lovebin "), 3*encryption.StreamChunkSize/8+11)
+
+	tests := []struct {
+		name     string
+		password string
+	}{
+		{"url key only", ""},
+		{"password protected", "correct horse battery staple"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestService(t, Config{})
+			ctx := context.Background()
+			resourceKey, oldKey := svc.upload(t, UploadRequest{Data: bytes.NewReader(content), Filename: "notes.txt", Password: tt.password, MaxViews: 2})
+			before, _ := svc.repo.resource(resourceKey)
+
+			resp, err := svc.ReencryptResource(ctx, &ReencryptRequest{ResourceKey: resourceKey, EncKeyBase64: oldKey, Password: tt.password})
+			if err != nil {
+				t.Fatalf("ReencryptResource: %v", err)
+			}
+			newKey := resp.URL[len("/media/"+resourceKey+"#"):]
+			if newKey == oldKey {
+				t.Fatal("new key equals the old one")
+			}
+
+			after, _ := svc.repo.resource(resourceKey)
+			// URL key resources have a constant salt mode marker, password ones a fresh salt
+			if tt.password != "" && bytes.Equal(after.Salt, before.Salt) {
+				t.Error("salt was not replaced")
+			}
+			newKeyBytes, _ := encryption.ConstantTimeDecode(newKey, encryption.URLKeySize)
+			if !bytes.Equal(after.KeyCheck, encryption.KeyCheck(newKeyBytes)) {
+				t.Error("key check does not match the new key")
+			}
+			object, _ := svc.storage.Object("", "media/"+resourceKey)
+			if after.EncryptedSize == nil || *after.EncryptedSize != int64(len(object)) {
+				t.Errorf("encrypted size = %v, want %d", after.EncryptedSize, len(object))
+			}
+			if _, ok := svc.storage.Object("", reencryptBackupPrefix+resourceKey); ok {
+				t.Error("backup object left behind")
+			}
+
+			if _, err := download(t, svc, resourceKey, oldKey, tt.password); !errors.Is(err, ErrDecryptionFailed) {
+				t.Errorf("download with the old key: err = %v, want ErrDecryptionFailed", err)
+			}
+			got, err := download(t, svc, resourceKey, newKey, tt.password)
+			if err != nil {
+				t.Fatalf("download with the new key: %v", err)
+			}
+			if !bytes.Equal(got, content) {
+				t.Error("content changed by re-encryption")
+			}
+			info, err := svc.GetMediaInfo(ctx, resourceKey, newKey)
+			if err != nil || info.Filename == nil || *info.Filename != "notes" {
+				t.Errorf("filename after re-encryption = %v, %v, want notes", info, err)
+			}
+		})
+	}
+}
+
+func TestReencryptResourceWithExplicitKey(t *testing.T) {
+	svc := newTestService(t, Config{})
+	resourceKey, oldKey := svc.upload(t, UploadRequest{Data: bytes.NewReader([]byte("data")), Filename: "a.bin"})
+
+	newKey := newURLKey(t)
+	resp, err := svc.ReencryptResource(context.Background(), &ReencryptRequest{ResourceKey: resourceKey, EncKeyBase64: oldKey, NewEncKeyBase64: newKey})
+	if err != nil {
+		t.Fatalf("ReencryptResource: %v", err)
+	}
+	if want := "/media/" + resourceKey + "#" + newKey; resp.URL != want {
+		t.Errorf("URL = %s, want %s", resp.URL, want)
+	}
+	if got, err := download(t, svc, resourceKey, newKey, ""); err != nil || string(got) != "data" {
+		t.Errorf("download = %q, %v, want data", got, err)
+	}
+}
+
+func TestReencryptResourceRejected(t *testing.T) {
+	const password = "correct horse battery staple"
+	otherKey := newURLKey(t)
+
+	tests := []struct {
+		name         string
+		withManifest bool
+		req          func(resourceKey, encKey string) *ReencryptRequest
+		wantErr      error // nil accepts any error
+	}{
+		{"missing key", true, func(resourceKey, _ string) *ReencryptRequest {
+			return &ReencryptRequest{ResourceKey: resourceKey, Password: password}
+		}, ErrMissingEncryptionKey},
+		{"malformed key", true, func(resourceKey, _ string) *ReencryptRequest {
+			return &ReencryptRequest{ResourceKey: resourceKey, EncKeyBase64: "not-a-key", Password: password}
+		}, ErrInvalidEncryptionKey},
+		{"malformed new key", true, func(resourceKey, encKey string) *ReencryptRequest {
+			return &ReencryptRequest{ResourceKey: resourceKey, EncKeyBase64: encKey, NewEncKeyBase64: "short", Password: password}
+		}, ErrInvalidEncryptionKey},
+		{"unknown resource", true, func(_, encKey string) *ReencryptRequest {
+			return &ReencryptRequest{ResourceKey: "missing", EncKeyBase64: encKey, Password: password}
+		}, ErrNotFound},
+		{"wrong password", true, func(resourceKey, encKey string) *ReencryptRequest {
+			return &ReencryptRequest{ResourceKey: resourceKey, EncKeyBase64: encKey, Password: "wrong"}
+		}, ErrInvalidPassword},
+		{"wrong key", true, func(resourceKey, _ string) *ReencryptRequest {
+			return &ReencryptRequest{ResourceKey: resourceKey, EncKeyBase64: otherKey, Password: password}
+		}, nil},
+		{"wrong key without manifest", false, func(resourceKey, _ string) *ReencryptRequest {
+			return &ReencryptRequest{ResourceKey: resourceKey, EncKeyBase64: otherKey, Password: password}
+		}, ErrDecryptionFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestService(t, Config{})
+			ctx := context.Background()
+			resourceKey, encKey := svc.upload(t, UploadRequest{Data: bytes.NewReader([]byte("secret")), Password: password})
+			if !tt.withManifest {
+				if err := s3.DeleteManifest(ctx, svc.storage, resourceKey); err != nil {
+					t.Fatalf("DeleteManifest: %v", err)
+				}
+			}
+			before, _ := svc.storage.Object("", "media/"+resourceKey)
+
+			_, err := svc.ReencryptResource(ctx, tt.req(resourceKey, encKey))
+			if err == nil || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+
+			// A rejected rotation leaves the resource readable with the old key
+			after, _ := svc.storage.Object("", "media/"+resourceKey)
+			if !bytes.Equal(before, after) {
+				t.Error("object changed by a rejected re-encryption")
+			}
+			if got, err := download(t, svc, resourceKey, encKey, password); err != nil || string(got) != "secret" {
+				t.Errorf("download = %q, %v, want secret", got, err)
+			}
+		})
+	}
+}
+
+func TestReencryptResourceRestoresObjectOnFailure(t *testing.T) {
+	svc := newTestService(t, Config{})
+	ctx := context.Background()
+	resourceKey, encKey := svc.upload(t, UploadRequest{Data: bytes.NewReader([]byte("keep me"))})
+	before, _ := svc.storage.Object("", "media/"+resourceKey)
+
+	// The new object is stored, then the manifest upload fails
+	svc.storage.SetError("Upload", errors.New("s3 unavailable"))
+	if _, err := svc.ReencryptResource(ctx, &ReencryptRequest{ResourceKey: resourceKey, EncKeyBase64: encKey}); err == nil {
+		t.Fatal("ReencryptResource succeeded without a manifest upload")
+	}
+	svc.storage.SetError("Upload", nil)
+
+	after, _ := svc.storage.Object("", "media/"+resourceKey)
+	if !bytes.Equal(before, after) {
+		t.Error("original ciphertext was not restored")
+	}
+	if _, ok := svc.storage.Object("", reencryptBackupPrefix+resourceKey); ok {
+		t.Error("backup object left behind")
+	}
+	if got, err := download(t, svc, resourceKey, encKey, ""); err != nil || string(got) != "keep me" {
+		t.Errorf("download = %q, %v, want keep me", got, err)
+	}
+}
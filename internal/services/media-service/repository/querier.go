@@ -6,17 +6,36 @@ package repository
 
 import (
 	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type Querier interface {
+	CountUnusedAccessCodes(ctx context.Context, resourceKey string) (int64, error)
+	CreateAccessCodes(ctx context.Context, arg CreateAccessCodesParams) error
 	CreateMediaResource(ctx context.Context, arg CreateMediaResourceParams) (MediaResource, error)
 	DeleteExpiredResources(ctx context.Context) error
 	DeleteMediaResource(ctx context.Context, resourceKey string) error
+	DeleteUploadsByIP(ctx context.Context, uploadIp pgtype.Text) ([]string, error)
+	DeleteViewedResources(ctx context.Context, keepKeys []string) ([]string, error)
+	GetEncryptedSizeByIP(ctx context.Context, uploadIp pgtype.Text) (int64, error)
 	GetExpiredResources(ctx context.Context) ([]string, error)
 	GetMediaResourceByKey(ctx context.Context, resourceKey string) (MediaResource, error)
 	GetMediaResourceByKeyAny(ctx context.Context, resourceKey string) (MediaResource, error)
+	GetMediaResourceForExport(ctx context.Context, resourceKey string) (MediaResource, error)
 	GetMediaResourceForView(ctx context.Context, resourceKey string) (MediaResource, error)
-	MarkAsViewed(ctx context.Context, resourceKey string) error
+	GetRecentUploadsByIP(ctx context.Context, arg GetRecentUploadsByIPParams) ([]MediaResource, error)
+	GetResourceEvents(ctx context.Context, arg GetResourceEventsParams) ([]GetResourceEventsRow, error)
+	GetResourcesExpiringBetween(ctx context.Context, arg GetResourcesExpiringBetweenParams) ([]MediaResource, error)
+	GetResourcesWithoutEncryptedSize(ctx context.Context, limit int32) ([]string, error)
+	HasAccessCodes(ctx context.Context, resourceKey string) (bool, error)
+	IncrementViewCount(ctx context.Context, resourceKey string) (int32, error)
+	LockResource(ctx context.Context, resourceKey string) error
+	MarkExpiryNotified(ctx context.Context, resourceKey string) error
+	RedeemAccessCode(ctx context.Context, arg RedeemAccessCodeParams) (int64, error)
+	TryLockResource(ctx context.Context, resourceKey string) (bool, error)
+	UpdateEncryptedSize(ctx context.Context, arg UpdateEncryptedSizeParams) error
+	UpdateSalt(ctx context.Context, arg UpdateSaltParams) error
 }
 
 var _ Querier = (*Queries)(nil)
@@ -6,28 +6,41 @@ INSERT INTO media_resources (
     salt,
     filename,
     file_extension,
-    blur_enabled
+    blur_intensity,
+    download_only,
+    upload_ip,
+    allowed_countries,
+    key_check,
+    available_at,
+    key_version,
+    encrypted_size,
+    verification_pubkey,
+    metadata_encrypted,
+    max_views
 ) VALUES (
-    $1, $2, $3, $4, $5, $6, $7
-) RETURNING id, resource_key, password_hash, expires_at, viewed, created_at, salt, filename, file_extension, blur_enabled;
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17
+) RETURNING id, resource_key, password_hash, expires_at, viewed, created_at, salt, filename, file_extension, blur_intensity, download_only, upload_ip, allowed_countries, key_check, available_at, key_version, encrypted_size, verification_pubkey, metadata_encrypted, max_views, view_count;
 
 -- name: GetMediaResourceByKey :one
-SELECT id, resource_key, password_hash, expires_at, viewed, created_at, salt, filename, file_extension, blur_enabled
+SELECT id, resource_key, password_hash, expires_at, viewed, created_at, salt, filename, file_extension, blur_intensity, download_only, upload_ip, allowed_countries, key_check, available_at, key_version, encrypted_size, verification_pubkey, metadata_encrypted, max_views, view_count
 FROM media_resources
 WHERE resource_key = $1
 AND (expires_at IS NULL OR expires_at > NOW())
 AND viewed = FALSE;
 
 -- name: GetMediaResourceByKeyAny :one
-SELECT id, resource_key, password_hash, expires_at, viewed, created_at, salt, filename, file_extension, blur_enabled
+SELECT id, resource_key, password_hash, expires_at, viewed, created_at, salt, filename, file_extension, blur_intensity, download_only, upload_ip, allowed_countries, key_check, available_at, key_version, encrypted_size, verification_pubkey, metadata_encrypted, max_views, view_count
 FROM media_resources
 WHERE resource_key = $1
 AND (expires_at IS NULL OR expires_at > NOW());
 
--- name: MarkAsViewed :exec
+-- name: IncrementViewCount :one
+-- The resource is viewed, i.e. closed to downloads, once view_count reaches max_views
 UPDATE media_resources
-SET viewed = TRUE
-WHERE resource_key = $1;
+SET view_count = view_count + 1,
+    viewed = view_count + 1 >= max_views
+WHERE resource_key = $1
+RETURNING view_count;
 
 -- name: DeleteMediaResource :exec
 DELETE FROM media_resources
@@ -44,10 +57,133 @@ DELETE FROM media_resources
 WHERE expires_at IS NOT NULL
 AND expires_at <= NOW();
 
+-- name: DeleteViewedResources :many
+-- keep_keys are left alone, e.g. while a presigned download still needs the object
+DELETE FROM media_resources
+WHERE viewed = TRUE
+AND resource_key <> ALL(@keep_keys::text[])
+RETURNING resource_key;
+
 -- name: GetMediaResourceForView :one
-SELECT id, resource_key, password_hash, expires_at, viewed, created_at, salt, filename, file_extension, blur_enabled
+SELECT id, resource_key, password_hash, expires_at, viewed, created_at, salt, filename, file_extension, blur_intensity, download_only, upload_ip, allowed_countries, key_check, available_at, key_version, encrypted_size, verification_pubkey, metadata_encrypted, max_views, view_count
 FROM media_resources
 WHERE resource_key = $1
 AND (expires_at IS NULL OR expires_at > NOW())
 FOR UPDATE;
 
+-- name: UpdateSalt :exec
+UPDATE media_resources
+SET salt = $2, key_version = $3, metadata_encrypted = $4, key_check = $5, encrypted_size = $6
+WHERE resource_key = $1;
+
+-- name: TryLockResource :one
+SELECT pg_try_advisory_xact_lock(hashtext(@resource_key::text))::boolean AS locked;
+
+-- name: LockResource :exec
+SELECT pg_advisory_xact_lock(hashtext(@resource_key::text));
+
+-- name: GetRecentUploadsByIP :many
+SELECT id, resource_key, password_hash, expires_at, viewed, created_at, salt, filename, file_extension, blur_intensity, download_only, upload_ip, allowed_countries, key_check, available_at, key_version, encrypted_size, verification_pubkey, metadata_encrypted, max_views, view_count
+FROM media_resources
+WHERE upload_ip = $1
+AND viewed = FALSE
+AND (expires_at IS NULL OR expires_at > NOW())
+ORDER BY created_at DESC
+LIMIT $2;
+
+-- name: DeleteUploadsByIP :many
+DELETE FROM media_resources
+WHERE upload_ip = $1
+RETURNING resource_key;
+
+-- name: CreateAccessCodes :exec
+INSERT INTO access_codes (resource_key, code_hash)
+SELECT @resource_key::text, unnest(@code_hashes::text[]);
+
+-- name: CountUnusedAccessCodes :one
+SELECT COUNT(*) FROM access_codes
+WHERE resource_key = $1
+AND used_at IS NULL;
+
+-- name: RedeemAccessCode :execrows
+UPDATE access_codes
+SET used_at = NOW()
+WHERE resource_key = $1
+AND code_hash = $2
+AND used_at IS NULL;
+
+-- name: HasAccessCodes :one
+SELECT EXISTS (
+    SELECT 1 FROM access_codes
+    WHERE resource_key = $1
+)::boolean AS has_access_codes;
+
+-- name: UpdateEncryptedSize :exec
+UPDATE media_resources
+SET encrypted_size = $2
+WHERE resource_key = $1
+AND encrypted_size IS NULL;
+
+-- name: GetResourcesWithoutEncryptedSize :many
+SELECT resource_key
+FROM media_resources
+WHERE encrypted_size IS NULL
+AND viewed = FALSE
+AND (expires_at IS NULL OR expires_at > NOW())
+ORDER BY created_at
+LIMIT $1;
+
+-- name: GetMediaResourceForExport :one
+-- Unlike the other lookups, expired and viewed resources are returned too
+SELECT id, resource_key, password_hash, expires_at, viewed, created_at, salt, filename, file_extension, blur_intensity, download_only, upload_ip, allowed_countries, key_check, available_at, key_version, encrypted_size, verification_pubkey, metadata_encrypted, max_views, view_count
+FROM media_resources
+WHERE resource_key = $1;
+
+-- name: GetResourcesExpiringBetween :many
+-- Pages by the (expires_at, resource_key) of the previous page's last row, resources already notified are skipped
+SELECT id, resource_key, password_hash, expires_at, viewed, created_at, salt, filename, file_extension, blur_intensity, download_only, upload_ip, allowed_countries, key_check, available_at, key_version, encrypted_size, verification_pubkey, metadata_encrypted, max_views, view_count
+FROM media_resources
+WHERE expires_at BETWEEN @expires_from AND @expires_to
+AND viewed = FALSE
+AND (expires_at, resource_key) > (@after_expires_at::timestamp, @after_key::text)
+AND NOT EXISTS (
+    SELECT 1 FROM expiry_notifications
+    WHERE expiry_notifications.resource_key = media_resources.resource_key
+)
+ORDER BY expires_at, resource_key
+LIMIT @page_limit;
+
+-- name: MarkExpiryNotified :exec
+INSERT INTO expiry_notifications (resource_key)
+VALUES ($1)
+ON CONFLICT (resource_key) DO NOTHING;
+
+-- name: GetEncryptedSizeByIP :one
+-- Resources whose object size is not recorded yet count as empty
+SELECT COALESCE(SUM(encrypted_size), 0)::bigint
+FROM media_resources
+WHERE upload_ip = $1
+AND viewed = FALSE
+AND (expires_at IS NULL OR expires_at > NOW());
+
+-- name: GetResourceEvents :many
+-- Recorded events of a resource in [at_from, at_to), oldest first; NULL bounds are open
+SELECT event_type::text, at::timestamptz, performed_by::text, ip::text, details::jsonb
+FROM (
+    SELECT operation AS event_type, at, performed_by, COALESCE(ip, '') AS ip, details
+    FROM audit_trail
+    WHERE audit_trail.resource_key = @resource_key::text
+    UNION ALL
+    SELECT 'access_code_used', used_at, 'anonymous', '', NULL
+    FROM access_codes
+    WHERE access_codes.resource_key = @resource_key::text
+    AND used_at IS NOT NULL
+    UNION ALL
+    SELECT 'expiry_notified', notified_at, 'system', '', NULL
+    FROM expiry_notifications
+    WHERE expiry_notifications.resource_key = @resource_key::text
+) AS events
+WHERE (sqlc.narg(at_from)::timestamptz IS NULL OR at >= sqlc.narg(at_from))
+AND (sqlc.narg(at_to)::timestamptz IS NULL OR at < sqlc.narg(at_to))
+ORDER BY at
+LIMIT @event_limit;
@@ -11,6 +11,34 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const countUnusedAccessCodes = `-- name: CountUnusedAccessCodes :one
+SELECT COUNT(*) FROM access_codes
+WHERE resource_key = $1
+AND used_at IS NULL
+`
+
+func (q *Queries) CountUnusedAccessCodes(ctx context.Context, resourceKey string) (int64, error) {
+	row := q.db.QueryRow(ctx, countUnusedAccessCodes, resourceKey)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createAccessCodes = `-- name: CreateAccessCodes :exec
+INSERT INTO access_codes (resource_key, code_hash)
+SELECT $1::text, unnest($2::text[])
+`
+
+type CreateAccessCodesParams struct {
+	ResourceKey string   `json:"resource_key"`
+	CodeHashes  []string `json:"code_hashes"`
+}
+
+func (q *Queries) CreateAccessCodes(ctx context.Context, arg CreateAccessCodesParams) error {
+	_, err := q.db.Exec(ctx, createAccessCodes, arg.ResourceKey, arg.CodeHashes)
+	return err
+}
+
 const createMediaResource = `-- name: CreateMediaResource :one
 INSERT INTO media_resources (
     resource_key,
@@ -19,20 +47,40 @@ INSERT INTO media_resources (
     salt,
     filename,
     file_extension,
-    blur_enabled
+    blur_intensity,
+    download_only,
+    upload_ip,
+    allowed_countries,
+    key_check,
+    available_at,
+    key_version,
+    encrypted_size,
+    verification_pubkey,
+    metadata_encrypted,
+    max_views
 ) VALUES (
-    $1, $2, $3, $4, $5, $6, $7
-) RETURNING id, resource_key, password_hash, expires_at, viewed, created_at, salt, filename, file_extension, blur_enabled
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17
+) RETURNING id, resource_key, password_hash, expires_at, viewed, created_at, salt, filename, file_extension, blur_intensity, download_only, upload_ip, allowed_countries, key_check, available_at, key_version, encrypted_size, verification_pubkey, metadata_encrypted, max_views, view_count
 `
 
 type CreateMediaResourceParams struct {
-	ResourceKey   string           `json:"resource_key"`
-	PasswordHash  pgtype.Text      `json:"password_hash"`
-	ExpiresAt     pgtype.Timestamp `json:"expires_at"`
-	Salt          []byte           `json:"salt"`
-	Filename      pgtype.Text      `json:"filename"`
-	FileExtension pgtype.Text      `json:"file_extension"`
-	BlurEnabled   pgtype.Bool      `json:"blur_enabled"`
+	ResourceKey        string             `json:"resource_key"`
+	PasswordHash       pgtype.Text        `json:"password_hash"`
+	ExpiresAt          pgtype.Timestamp   `json:"expires_at"`
+	Salt               []byte             `json:"salt"`
+	Filename           pgtype.Text        `json:"filename"`
+	FileExtension      pgtype.Text        `json:"file_extension"`
+	BlurIntensity      pgtype.Float8      `json:"blur_intensity"`
+	DownloadOnly       pgtype.Bool        `json:"download_only"`
+	UploadIp           pgtype.Text        `json:"upload_ip"`
+	AllowedCountries   []string           `json:"allowed_countries"`
+	KeyCheck           []byte             `json:"key_check"`
+	AvailableAt        pgtype.Timestamptz `json:"available_at"`
+	KeyVersion         pgtype.Text        `json:"key_version"`
+	EncryptedSize      pgtype.Int8        `json:"encrypted_size"`
+	VerificationPubkey []byte             `json:"verification_pubkey"`
+	MetadataEncrypted  []byte             `json:"metadata_encrypted"`
+	MaxViews           int32              `json:"max_views"`
 }
 
 func (q *Queries) CreateMediaResource(ctx context.Context, arg CreateMediaResourceParams) (MediaResource, error) {
@@ -43,7 +91,17 @@ func (q *Queries) CreateMediaResource(ctx context.Context, arg CreateMediaResour
 		arg.Salt,
 		arg.Filename,
 		arg.FileExtension,
-		arg.BlurEnabled,
+		arg.BlurIntensity,
+		arg.DownloadOnly,
+		arg.UploadIp,
+		arg.AllowedCountries,
+		arg.KeyCheck,
+		arg.AvailableAt,
+		arg.KeyVersion,
+		arg.EncryptedSize,
+		arg.VerificationPubkey,
+		arg.MetadataEncrypted,
+		arg.MaxViews,
 	)
 	var i MediaResource
 	err := row.Scan(
@@ -56,7 +114,18 @@ func (q *Queries) CreateMediaResource(ctx context.Context, arg CreateMediaResour
 		&i.Salt,
 		&i.Filename,
 		&i.FileExtension,
-		&i.BlurEnabled,
+		&i.BlurIntensity,
+		&i.DownloadOnly,
+		&i.UploadIp,
+		&i.AllowedCountries,
+		&i.KeyCheck,
+		&i.AvailableAt,
+		&i.KeyVersion,
+		&i.EncryptedSize,
+		&i.VerificationPubkey,
+		&i.MetadataEncrypted,
+		&i.MaxViews,
+		&i.ViewCount,
 	)
 	return i, err
 }
@@ -82,6 +151,76 @@ func (q *Queries) DeleteMediaResource(ctx context.Context, resourceKey string) e
 	return err
 }
 
+const deleteUploadsByIP = `-- name: DeleteUploadsByIP :many
+DELETE FROM media_resources
+WHERE upload_ip = $1
+RETURNING resource_key
+`
+
+func (q *Queries) DeleteUploadsByIP(ctx context.Context, uploadIp pgtype.Text) ([]string, error) {
+	rows, err := q.db.Query(ctx, deleteUploadsByIP, uploadIp)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var resource_key string
+		if err := rows.Scan(&resource_key); err != nil {
+			return nil, err
+		}
+		items = append(items, resource_key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const deleteViewedResources = `-- name: DeleteViewedResources :many
+DELETE FROM media_resources
+WHERE viewed = TRUE
+AND resource_key <> ALL($1::text[])
+RETURNING resource_key
+`
+
+// keep_keys are left alone, e.g. while a presigned download still needs the object
+func (q *Queries) DeleteViewedResources(ctx context.Context, keepKeys []string) ([]string, error) {
+	rows, err := q.db.Query(ctx, deleteViewedResources, keepKeys)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var resource_key string
+		if err := rows.Scan(&resource_key); err != nil {
+			return nil, err
+		}
+		items = append(items, resource_key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getEncryptedSizeByIP = `-- name: GetEncryptedSizeByIP :one
+SELECT COALESCE(SUM(encrypted_size), 0)::bigint
+FROM media_resources
+WHERE upload_ip = $1
+AND viewed = FALSE
+AND (expires_at IS NULL OR expires_at > NOW())
+`
+
+// Resources whose object size is not recorded yet count as empty
+func (q *Queries) GetEncryptedSizeByIP(ctx context.Context, uploadIp pgtype.Text) (int64, error) {
+	row := q.db.QueryRow(ctx, getEncryptedSizeByIP, uploadIp)
+	var column_1 int64
+	err := row.Scan(&column_1)
+	return column_1, err
+}
+
 const getExpiredResources = `-- name: GetExpiredResources :many
 SELECT resource_key
 FROM media_resources
@@ -110,7 +249,7 @@ func (q *Queries) GetExpiredResources(ctx context.Context) ([]string, error) {
 }
 
 const getMediaResourceByKey = `-- name: GetMediaResourceByKey :one
-SELECT id, resource_key, password_hash, expires_at, viewed, created_at, salt, filename, file_extension, blur_enabled
+SELECT id, resource_key, password_hash, expires_at, viewed, created_at, salt, filename, file_extension, blur_intensity, download_only, upload_ip, allowed_countries, key_check, available_at, key_version, encrypted_size, verification_pubkey, metadata_encrypted, max_views, view_count
 FROM media_resources
 WHERE resource_key = $1
 AND (expires_at IS NULL OR expires_at > NOW())
@@ -130,13 +269,24 @@ func (q *Queries) GetMediaResourceByKey(ctx context.Context, resourceKey string)
 		&i.Salt,
 		&i.Filename,
 		&i.FileExtension,
-		&i.BlurEnabled,
+		&i.BlurIntensity,
+		&i.DownloadOnly,
+		&i.UploadIp,
+		&i.AllowedCountries,
+		&i.KeyCheck,
+		&i.AvailableAt,
+		&i.KeyVersion,
+		&i.EncryptedSize,
+		&i.VerificationPubkey,
+		&i.MetadataEncrypted,
+		&i.MaxViews,
+		&i.ViewCount,
 	)
 	return i, err
 }
 
 const getMediaResourceByKeyAny = `-- name: GetMediaResourceByKeyAny :one
-SELECT id, resource_key, password_hash, expires_at, viewed, created_at, salt, filename, file_extension, blur_enabled
+SELECT id, resource_key, password_hash, expires_at, viewed, created_at, salt, filename, file_extension, blur_intensity, download_only, upload_ip, allowed_countries, key_check, available_at, key_version, encrypted_size, verification_pubkey, metadata_encrypted, max_views, view_count
 FROM media_resources
 WHERE resource_key = $1
 AND (expires_at IS NULL OR expires_at > NOW())
@@ -155,13 +305,60 @@ func (q *Queries) GetMediaResourceByKeyAny(ctx context.Context, resourceKey stri
 		&i.Salt,
 		&i.Filename,
 		&i.FileExtension,
-		&i.BlurEnabled,
+		&i.BlurIntensity,
+		&i.DownloadOnly,
+		&i.UploadIp,
+		&i.AllowedCountries,
+		&i.KeyCheck,
+		&i.AvailableAt,
+		&i.KeyVersion,
+		&i.EncryptedSize,
+		&i.VerificationPubkey,
+		&i.MetadataEncrypted,
+		&i.MaxViews,
+		&i.ViewCount,
+	)
+	return i, err
+}
+
+const getMediaResourceForExport = `-- name: GetMediaResourceForExport :one
+SELECT id, resource_key, password_hash, expires_at, viewed, created_at, salt, filename, file_extension, blur_intensity, download_only, upload_ip, allowed_countries, key_check, available_at, key_version, encrypted_size, verification_pubkey, metadata_encrypted, max_views, view_count
+FROM media_resources
+WHERE resource_key = $1
+`
+
+// Unlike the other lookups, expired and viewed resources are returned too
+func (q *Queries) GetMediaResourceForExport(ctx context.Context, resourceKey string) (MediaResource, error) {
+	row := q.db.QueryRow(ctx, getMediaResourceForExport, resourceKey)
+	var i MediaResource
+	err := row.Scan(
+		&i.ID,
+		&i.ResourceKey,
+		&i.PasswordHash,
+		&i.ExpiresAt,
+		&i.Viewed,
+		&i.CreatedAt,
+		&i.Salt,
+		&i.Filename,
+		&i.FileExtension,
+		&i.BlurIntensity,
+		&i.DownloadOnly,
+		&i.UploadIp,
+		&i.AllowedCountries,
+		&i.KeyCheck,
+		&i.AvailableAt,
+		&i.KeyVersion,
+		&i.EncryptedSize,
+		&i.VerificationPubkey,
+		&i.MetadataEncrypted,
+		&i.MaxViews,
+		&i.ViewCount,
 	)
 	return i, err
 }
 
 const getMediaResourceForView = `-- name: GetMediaResourceForView :one
-SELECT id, resource_key, password_hash, expires_at, viewed, created_at, salt, filename, file_extension, blur_enabled
+SELECT id, resource_key, password_hash, expires_at, viewed, created_at, salt, filename, file_extension, blur_intensity, download_only, upload_ip, allowed_countries, key_check, available_at, key_version, encrypted_size, verification_pubkey, metadata_encrypted, max_views, view_count
 FROM media_resources
 WHERE resource_key = $1
 AND (expires_at IS NULL OR expires_at > NOW())
@@ -181,18 +378,371 @@ func (q *Queries) GetMediaResourceForView(ctx context.Context, resourceKey strin
 		&i.Salt,
 		&i.Filename,
 		&i.FileExtension,
-		&i.BlurEnabled,
+		&i.BlurIntensity,
+		&i.DownloadOnly,
+		&i.UploadIp,
+		&i.AllowedCountries,
+		&i.KeyCheck,
+		&i.AvailableAt,
+		&i.KeyVersion,
+		&i.EncryptedSize,
+		&i.VerificationPubkey,
+		&i.MetadataEncrypted,
+		&i.MaxViews,
+		&i.ViewCount,
 	)
 	return i, err
 }
 
-const markAsViewed = `-- name: MarkAsViewed :exec
+const getRecentUploadsByIP = `-- name: GetRecentUploadsByIP :many
+SELECT id, resource_key, password_hash, expires_at, viewed, created_at, salt, filename, file_extension, blur_intensity, download_only, upload_ip, allowed_countries, key_check, available_at, key_version, encrypted_size, verification_pubkey, metadata_encrypted, max_views, view_count
+FROM media_resources
+WHERE upload_ip = $1
+AND viewed = FALSE
+AND (expires_at IS NULL OR expires_at > NOW())
+ORDER BY created_at DESC
+LIMIT $2
+`
+
+type GetRecentUploadsByIPParams struct {
+	UploadIp pgtype.Text `json:"upload_ip"`
+	Limit    int32       `json:"limit"`
+}
+
+func (q *Queries) GetRecentUploadsByIP(ctx context.Context, arg GetRecentUploadsByIPParams) ([]MediaResource, error) {
+	rows, err := q.db.Query(ctx, getRecentUploadsByIP, arg.UploadIp, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []MediaResource
+	for rows.Next() {
+		var i MediaResource
+		if err := rows.Scan(
+			&i.ID,
+			&i.ResourceKey,
+			&i.PasswordHash,
+			&i.ExpiresAt,
+			&i.Viewed,
+			&i.CreatedAt,
+			&i.Salt,
+			&i.Filename,
+			&i.FileExtension,
+			&i.BlurIntensity,
+			&i.DownloadOnly,
+			&i.UploadIp,
+			&i.AllowedCountries,
+			&i.KeyCheck,
+			&i.AvailableAt,
+			&i.KeyVersion,
+			&i.EncryptedSize,
+			&i.VerificationPubkey,
+			&i.MetadataEncrypted,
+			&i.MaxViews,
+			&i.ViewCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getResourceEvents = `-- name: GetResourceEvents :many
+SELECT event_type::text, at::timestamptz, performed_by::text, ip::text, details::jsonb
+FROM (
+    SELECT operation AS event_type, at, performed_by, COALESCE(ip, '') AS ip, details
+    FROM audit_trail
+    WHERE audit_trail.resource_key = $1::text
+    UNION ALL
+    SELECT 'access_code_used', used_at, 'anonymous', '', NULL
+    FROM access_codes
+    WHERE access_codes.resource_key = $1::text
+    AND used_at IS NOT NULL
+    UNION ALL
+    SELECT 'expiry_notified', notified_at, 'system', '', NULL
+    FROM expiry_notifications
+    WHERE expiry_notifications.resource_key = $1::text
+) AS events
+WHERE ($2::timestamptz IS NULL OR at >= $2)
+AND ($3::timestamptz IS NULL OR at < $3)
+ORDER BY at
+LIMIT $4
+`
+
+type GetResourceEventsParams struct {
+	ResourceKey string             `json:"resource_key"`
+	AtFrom      pgtype.Timestamptz `json:"at_from"`
+	AtTo        pgtype.Timestamptz `json:"at_to"`
+	EventLimit  int32              `json:"event_limit"`
+}
+
+type GetResourceEventsRow struct {
+	EventType   string             `json:"event_type"`
+	At          pgtype.Timestamptz `json:"at"`
+	PerformedBy string             `json:"performed_by"`
+	Ip          string             `json:"ip"`
+	Details     []byte             `json:"details"`
+}
+
+// Recorded events of a resource in [at_from, at_to), oldest first; NULL bounds are open
+func (q *Queries) GetResourceEvents(ctx context.Context, arg GetResourceEventsParams) ([]GetResourceEventsRow, error) {
+	rows, err := q.db.Query(ctx, getResourceEvents,
+		arg.ResourceKey,
+		arg.AtFrom,
+		arg.AtTo,
+		arg.EventLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetResourceEventsRow
+	for rows.Next() {
+		var i GetResourceEventsRow
+		if err := rows.Scan(
+			&i.EventType,
+			&i.At,
+			&i.PerformedBy,
+			&i.Ip,
+			&i.Details,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getResourcesExpiringBetween = `-- name: GetResourcesExpiringBetween :many
+SELECT id, resource_key, password_hash, expires_at, viewed, created_at, salt, filename, file_extension, blur_intensity, download_only, upload_ip, allowed_countries, key_check, available_at, key_version, encrypted_size, verification_pubkey, metadata_encrypted, max_views, view_count
+FROM media_resources
+WHERE expires_at BETWEEN $1 AND $2
+AND viewed = FALSE
+AND (expires_at, resource_key) > ($3::timestamp, $4::text)
+AND NOT EXISTS (
+    SELECT 1 FROM expiry_notifications
+    WHERE expiry_notifications.resource_key = media_resources.resource_key
+)
+ORDER BY expires_at, resource_key
+LIMIT $5
+`
+
+type GetResourcesExpiringBetweenParams struct {
+	ExpiresFrom    pgtype.Timestamp `json:"expires_from"`
+	ExpiresTo      pgtype.Timestamp `json:"expires_to"`
+	AfterExpiresAt pgtype.Timestamp `json:"after_expires_at"`
+	AfterKey       string           `json:"after_key"`
+	PageLimit      int32            `json:"page_limit"`
+}
+
+// Pages by the (expires_at, resource_key) of the previous page's last row, resources already notified are skipped
+func (q *Queries) GetResourcesExpiringBetween(ctx context.Context, arg GetResourcesExpiringBetweenParams) ([]MediaResource, error) {
+	rows, err := q.db.Query(ctx, getResourcesExpiringBetween,
+		arg.ExpiresFrom,
+		arg.ExpiresTo,
+		arg.AfterExpiresAt,
+		arg.AfterKey,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []MediaResource
+	for rows.Next() {
+		var i MediaResource
+		if err := rows.Scan(
+			&i.ID,
+			&i.ResourceKey,
+			&i.PasswordHash,
+			&i.ExpiresAt,
+			&i.Viewed,
+			&i.CreatedAt,
+			&i.Salt,
+			&i.Filename,
+			&i.FileExtension,
+			&i.BlurIntensity,
+			&i.DownloadOnly,
+			&i.UploadIp,
+			&i.AllowedCountries,
+			&i.KeyCheck,
+			&i.AvailableAt,
+			&i.KeyVersion,
+			&i.EncryptedSize,
+			&i.VerificationPubkey,
+			&i.MetadataEncrypted,
+			&i.MaxViews,
+			&i.ViewCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getResourcesWithoutEncryptedSize = `-- name: GetResourcesWithoutEncryptedSize :many
+SELECT resource_key
+FROM media_resources
+WHERE encrypted_size IS NULL
+AND viewed = FALSE
+AND (expires_at IS NULL OR expires_at > NOW())
+ORDER BY created_at
+LIMIT $1
+`
+
+func (q *Queries) GetResourcesWithoutEncryptedSize(ctx context.Context, limit int32) ([]string, error) {
+	rows, err := q.db.Query(ctx, getResourcesWithoutEncryptedSize, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var resource_key string
+		if err := rows.Scan(&resource_key); err != nil {
+			return nil, err
+		}
+		items = append(items, resource_key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const hasAccessCodes = `-- name: HasAccessCodes :one
+SELECT EXISTS (
+    SELECT 1 FROM access_codes
+    WHERE resource_key = $1
+)::boolean AS has_access_codes
+`
+
+func (q *Queries) HasAccessCodes(ctx context.Context, resourceKey string) (bool, error) {
+	row := q.db.QueryRow(ctx, hasAccessCodes, resourceKey)
+	var has_access_codes bool
+	err := row.Scan(&has_access_codes)
+	return has_access_codes, err
+}
+
+const incrementViewCount = `-- name: IncrementViewCount :one
+UPDATE media_resources
+SET view_count = view_count + 1,
+    viewed = view_count + 1 >= max_views
+WHERE resource_key = $1
+RETURNING view_count
+`
+
+// The resource is viewed, i.e. closed to downloads, once view_count reaches max_views
+func (q *Queries) IncrementViewCount(ctx context.Context, resourceKey string) (int32, error) {
+	row := q.db.QueryRow(ctx, incrementViewCount, resourceKey)
+	var view_count int32
+	err := row.Scan(&view_count)
+	return view_count, err
+}
+
+const lockResource = `-- name: LockResource :exec
+SELECT pg_advisory_xact_lock(hashtext($1::text))
+`
+
+func (q *Queries) LockResource(ctx context.Context, resourceKey string) error {
+	_, err := q.db.Exec(ctx, lockResource, resourceKey)
+	return err
+}
+
+const markExpiryNotified = `-- name: MarkExpiryNotified :exec
+INSERT INTO expiry_notifications (resource_key)
+VALUES ($1)
+ON CONFLICT (resource_key) DO NOTHING
+`
+
+func (q *Queries) MarkExpiryNotified(ctx context.Context, resourceKey string) error {
+	_, err := q.db.Exec(ctx, markExpiryNotified, resourceKey)
+	return err
+}
+
+const redeemAccessCode = `-- name: RedeemAccessCode :execrows
+UPDATE access_codes
+SET used_at = NOW()
+WHERE resource_key = $1
+AND code_hash = $2
+AND used_at IS NULL
+`
+
+type RedeemAccessCodeParams struct {
+	ResourceKey string `json:"resource_key"`
+	CodeHash    string `json:"code_hash"`
+}
+
+func (q *Queries) RedeemAccessCode(ctx context.Context, arg RedeemAccessCodeParams) (int64, error) {
+	result, err := q.db.Exec(ctx, redeemAccessCode, arg.ResourceKey, arg.CodeHash)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const tryLockResource = `-- name: TryLockResource :one
+SELECT pg_try_advisory_xact_lock(hashtext($1::text))::boolean AS locked
+`
+
+func (q *Queries) TryLockResource(ctx context.Context, resourceKey string) (bool, error) {
+	row := q.db.QueryRow(ctx, tryLockResource, resourceKey)
+	var locked bool
+	err := row.Scan(&locked)
+	return locked, err
+}
+
+const updateEncryptedSize = `-- name: UpdateEncryptedSize :exec
 UPDATE media_resources
-SET viewed = TRUE
+SET encrypted_size = $2
 WHERE resource_key = $1
+AND encrypted_size IS NULL
 `
 
-func (q *Queries) MarkAsViewed(ctx context.Context, resourceKey string) error {
-	_, err := q.db.Exec(ctx, markAsViewed, resourceKey)
+type UpdateEncryptedSizeParams struct {
+	ResourceKey   string      `json:"resource_key"`
+	EncryptedSize pgtype.Int8 `json:"encrypted_size"`
+}
+
+func (q *Queries) UpdateEncryptedSize(ctx context.Context, arg UpdateEncryptedSizeParams) error {
+	_, err := q.db.Exec(ctx, updateEncryptedSize, arg.ResourceKey, arg.EncryptedSize)
+	return err
+}
+
+const updateSalt = `-- name: UpdateSalt :exec
+UPDATE media_resources
+SET salt = $2, key_version = $3, metadata_encrypted = $4, key_check = $5, encrypted_size = $6
+WHERE resource_key = $1
+`
+
+type UpdateSaltParams struct {
+	ResourceKey       string      `json:"resource_key"`
+	Salt              []byte      `json:"salt"`
+	KeyVersion        pgtype.Text `json:"key_version"`
+	MetadataEncrypted []byte      `json:"metadata_encrypted"`
+	KeyCheck          []byte      `json:"key_check"`
+	EncryptedSize     pgtype.Int8 `json:"encrypted_size"`
+}
+
+func (q *Queries) UpdateSalt(ctx context.Context, arg UpdateSaltParams) error {
+	_, err := q.db.Exec(ctx, updateSalt,
+		arg.ResourceKey,
+		arg.Salt,
+		arg.KeyVersion,
+		arg.MetadataEncrypted,
+		arg.KeyCheck,
+		arg.EncryptedSize,
+	)
 	return err
 }
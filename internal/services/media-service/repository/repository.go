@@ -2,27 +2,92 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// Repository is the media resource storage used by the media service
+type Repository interface {
+	CreateMediaResource(ctx context.Context, arg CreateMediaResourceInput) (MediaResourceResult, error)
+	GetMediaResourceByKey(ctx context.Context, resourceKey string) (MediaResourceResult, error)
+	GetMediaResourceByKeyAny(ctx context.Context, resourceKey string) (MediaResourceResult, error)
+	// IncrementViewCount counts a download of a resource and returns its view count,
+	// the resource is viewed once the count reaches its max views
+	IncrementViewCount(ctx context.Context, resourceKey string) (int, error)
+	DeleteMediaResource(ctx context.Context, resourceKey string) error
+	GetRecentUploadsByIP(ctx context.Context, ip string, limit int) ([]MediaResourceResult, error)
+	// GetEncryptedSizeByIP sums the stored size of the active uploads made from ip
+	GetEncryptedSizeByIP(ctx context.Context, ip string) (int64, error)
+	DeleteUploadsByIP(ctx context.Context, ip string) ([]string, error)
+	GetMediaResourceForView(ctx context.Context, resourceKey string) (MediaResourceResult, error)
+	// GetMediaResourceForExport returns a resource whatever its state, including expired and viewed ones
+	GetMediaResourceForExport(ctx context.Context, resourceKey string) (MediaResourceResult, error)
+	GetExpiredResources(ctx context.Context) ([]string, error)
+	DeleteExpiredResources(ctx context.Context) error
+	// DeleteViewedResources deletes viewed resources except keepKeys and returns their keys
+	DeleteViewedResources(ctx context.Context, keepKeys []string) ([]string, error)
+	// GetMediaResourceByKeyWithLock counts a view once view accepts the resource,
+	// redeeming the access code of accessCodeHash if the resource has codes
+	GetMediaResourceByKeyWithLock(ctx context.Context, resourceKey string, blocking bool, accessCodeHash string, view func(MediaResourceResult) error) (int, error)
+	ReplaceSalt(ctx context.Context, resourceKey string, update func(MediaResourceResult) (SaltUpdate, error)) error
+	CreateAccessCodes(ctx context.Context, resourceKey string, codeHashes []string) error
+	CountUnusedAccessCodes(ctx context.Context, resourceKey string) (int, error)
+	// UpdateEncryptedSize records the S3 object size unless it is already known
+	UpdateEncryptedSize(ctx context.Context, resourceKey string, size int64) error
+	GetResourcesWithoutEncryptedSize(ctx context.Context, limit int) ([]string, error)
+	// GetResourcesExpiringBetween returns up to limit unviewed, not yet notified resources
+	// expiring in [from, to], ordered by expiry, starting after the cursor
+	GetResourcesExpiringBetween(ctx context.Context, from, to time.Time, after ExpiryCursor, limit int) ([]MediaResourceResult, error)
+	// MarkExpiryNotified records that the upcoming expiry of a resource was announced
+	MarkExpiryNotified(ctx context.Context, resourceKey string) error
+	// GetResourceEvents returns up to limit recorded events of a resource in [from, to),
+	// oldest first; zero bounds are open
+	GetResourceEvents(ctx context.Context, resourceKey string, from, to time.Time, limit int) ([]ResourceEventResult, error)
+	// WithTx returns a repository running its queries on tx
+	WithTx(tx pgx.Tx) Repository
+}
+
+// txStarter begins transactions: a pool starts real ones, a pgx.Tx starts savepoints
+type txStarter interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
 // MediaRepository wraps sqlc Queries and converts types
 type MediaRepository struct {
+	db      txStarter
 	queries *Queries
 }
 
+var _ Repository = (*MediaRepository)(nil)
+
 // CreateMediaResourceInput represents input parameters for creating a media resource
 type CreateMediaResourceInput struct {
-	ResourceKey   string
-	PasswordHash  *string
-	ExpiresAt     *time.Time
-	Salt          []byte
-	Filename      *string
-	FileExtension *string
-	BlurEnabled   bool
+	ResourceKey      string
+	PasswordHash     *string
+	ExpiresAt        *time.Time
+	Salt             []byte
+	Filename         *string
+	FileExtension    *string
+	BlurIntensity    float64 // 0.0 no blur to 1.0 maximum blur
+	DownloadOnly     bool
+	UploadIP         *string
+	AllowedCountries []string
+	KeyCheck         []byte
+	AvailableAt      *time.Time
+	KeyVersion       string // empty when no server key is mixed in
+	EncryptedSize    *int64
+	// VerificationPubkey is the PKIX public key of the upload manifest signature
+	VerificationPubkey []byte
+	// MetadataEncrypted is the filename and extension sealed with the URL key,
+	// Filename and FileExtension stay nil when it is set
+	MetadataEncrypted []byte
+	MaxViews          int // downloads allowed before the resource is viewed, 0 is one
 }
 
 // MediaResourceResult represents a media resource result
@@ -36,15 +101,94 @@ type MediaResourceResult struct {
 	Salt          []byte
 	Filename      *string
 	FileExtension *string
-	BlurEnabled   bool
+	BlurIntensity float64
+	DownloadOnly  bool
+	UploadIP      *string
+	KeyCheck      []byte
+	AvailableAt   *time.Time
+	KeyVersion    string
+	EncryptedSize *int64
+
+	VerificationPubkey []byte
+	MetadataEncrypted  []byte // nil for resources with plaintext Filename and FileExtension
+
+	MaxViews  int // downloads allowed, Viewed is set once ViewCount reaches it
+	ViewCount int // downloads so far
+}
+
+// ExpiryCursor is the position after the last resource of a GetResourcesExpiringBetween
+// page, the zero value starts at the first page
+type ExpiryCursor struct {
+	ExpiresAt   time.Time
+	ResourceKey string
+}
+
+// SaltUpdate is the new key material of a re-encrypted resource
+type SaltUpdate struct {
+	Salt              []byte
+	KeyVersion        string
+	MetadataEncrypted []byte // resealed with the new key, nil when the resource has none
+	KeyCheck          []byte // encryption.KeyCheck of the new URL key
+	EncryptedSize     int64  // size of the new S3 object
+}
+
+// ResourceEventResult is an event of a resource recorded in the audit trail,
+// its access codes or its expiry notifications
+type ResourceEventResult struct {
+	EventType   string // audit operation, "access_code_used" or "expiry_notified"
+	At          time.Time
+	PerformedBy string
+	IP          string // empty when not recorded
+	Details     []byte // JSON object of audit details, nil for other events
 }
 
 func NewMediaRepository(db *pgxpool.Pool) *MediaRepository {
 	return &MediaRepository{
+		db:      db,
 		queries: New(db),
 	}
 }
 
+// AllQueries returns the SQL of every generated query, for postgres.ValidateQueries
+func AllQueries() []string {
+	return []string{
+		countUnusedAccessCodes,
+		createAccessCodes,
+		createMediaResource,
+		deleteExpiredResources,
+		deleteMediaResource,
+		deleteUploadsByIP,
+		deleteViewedResources,
+		getEncryptedSizeByIP,
+		getExpiredResources,
+		getMediaResourceByKey,
+		getMediaResourceByKeyAny,
+		getMediaResourceForExport,
+		getMediaResourceForView,
+		getRecentUploadsByIP,
+		getResourceEvents,
+		getResourcesExpiringBetween,
+		getResourcesWithoutEncryptedSize,
+		hasAccessCodes,
+		incrementViewCount,
+		lockResource,
+		markExpiryNotified,
+		redeemAccessCode,
+		tryLockResource,
+		updateEncryptedSize,
+		updateSalt,
+	}
+}
+
+// WithTx returns a repository bound to tx. Methods that open their own
+// transaction (locking, salt replacement) use savepoints inside tx.
+func (r *MediaRepository) WithTx(tx pgx.Tx) Repository {
+	return &MediaRepository{
+		db:      tx,
+		queries: r.queries.WithTx(tx),
+	}
+}
+
 func (r *MediaRepository) CreateMediaResource(ctx context.Context, arg CreateMediaResourceInput) (MediaResourceResult, error) {
 	// Convert input types to sqlc types
 	sqlcParams := CreateMediaResourceParams{
@@ -84,12 +228,59 @@ func (r *MediaRepository) CreateMediaResource(ctx context.Context, arg CreateMed
 		}
 	}
 
-	// Convert blur enabled
-	sqlcParams.BlurEnabled = pgtype.Bool{
-		Bool:  arg.BlurEnabled,
+	// Convert blur intensity
+	sqlcParams.BlurIntensity = pgtype.Float8{
+		Float64: arg.BlurIntensity,
+		Valid:   true,
+	}
+
+	// Convert download only
+	sqlcParams.DownloadOnly = pgtype.Bool{
+		Bool:  arg.DownloadOnly,
 		Valid: true,
 	}
 
+	// Convert upload IP
+	if arg.UploadIP != nil {
+		sqlcParams.UploadIp = pgtype.Text{
+			String: *arg.UploadIP,
+			Valid:  true,
+		}
+	}
+
+	sqlcParams.AllowedCountries = arg.AllowedCountries
+	sqlcParams.KeyCheck = arg.KeyCheck
+
+	// Convert available at
+	if arg.AvailableAt != nil {
+		sqlcParams.AvailableAt = pgtype.Timestamptz{
+			Time:  *arg.AvailableAt,
+			Valid: true,
+		}
+	}
+
+	// Convert key version
+	if arg.KeyVersion != "" {
+		sqlcParams.KeyVersion = pgtype.Text{
+			String: arg.KeyVersion,
+			Valid:  true,
+		}
+	}
+
+	// Convert encrypted size
+	if arg.EncryptedSize != nil {
+		sqlcParams.EncryptedSize = pgtype.Int8{
+			Int64: *arg.EncryptedSize,
+			Valid: true,
+		}
+	}
+
+	sqlcParams.VerificationPubkey = arg.VerificationPubkey
+	sqlcParams.MetadataEncrypted = arg.MetadataEncrypted
+
+	// Convert max views
+	sqlcParams.MaxViews = int32(max(arg.MaxViews, 1))
+
 	dbResource, err := r.queries.CreateMediaResource(ctx, sqlcParams)
 	if err != nil {
 		return MediaResourceResult{}, err
@@ -116,14 +307,103 @@ func (r *MediaRepository) GetMediaResourceByKeyAny(ctx context.Context, resource
 	return toMediaResourceResult(dbResource), nil
 }
 
-func (r *MediaRepository) MarkAsViewed(ctx context.Context, resourceKey string) error {
-	return r.queries.MarkAsViewed(ctx, resourceKey)
+func (r *MediaRepository) IncrementViewCount(ctx context.Context, resourceKey string) (int, error) {
+	count, err := r.queries.IncrementViewCount(ctx, resourceKey)
+	return int(count), err
 }
 
 func (r *MediaRepository) DeleteMediaResource(ctx context.Context, resourceKey string) error {
 	return r.queries.DeleteMediaResource(ctx, resourceKey)
 }
 
+// CreateAccessCodes stores the hashed single-use access codes of a resource
+func (r *MediaRepository) CreateAccessCodes(ctx context.Context, resourceKey string, codeHashes []string) error {
+	return r.queries.CreateAccessCodes(ctx, CreateAccessCodesParams{
+		ResourceKey: resourceKey,
+		CodeHashes:  codeHashes,
+	})
+}
+
+// CountUnusedAccessCodes returns how many access codes of a resource are still valid
+func (r *MediaRepository) CountUnusedAccessCodes(ctx context.Context, resourceKey string) (int, error) {
+	count, err := r.queries.CountUnusedAccessCodes(ctx, resourceKey)
+	if err != nil {
+		return 0, err
+	}
+	return int(count), nil
+}
+
+func (r *MediaRepository) UpdateEncryptedSize(ctx context.Context, resourceKey string, size int64) error {
+	return r.queries.UpdateEncryptedSize(ctx, UpdateEncryptedSizeParams{
+		ResourceKey:   resourceKey,
+		EncryptedSize: pgtype.Int8{Int64: size, Valid: true},
+	})
+}
+
+// GetResourcesWithoutEncryptedSize returns up to limit active resources whose object size is unknown, oldest first
+func (r *MediaRepository) GetResourcesWithoutEncryptedSize(ctx context.Context, limit int) ([]string, error) {
+	return r.queries.GetResourcesWithoutEncryptedSize(ctx, int32(limit))
+}
+
+// GetEncryptedSizeByIP sums the stored size of the active uploads made from ip
+func (r *MediaRepository) GetEncryptedSizeByIP(ctx context.Context, ip string) (int64, error) {
+	return r.queries.GetEncryptedSizeByIP(ctx, pgtype.Text{String: ip, Valid: true})
+}
+
+// GetRecentUploadsByIP returns the newest active uploads made from ip
+func (r *MediaRepository) GetRecentUploadsByIP(ctx context.Context, ip string, limit int) ([]MediaResourceResult, error) {
+	dbResources, err := r.queries.GetRecentUploadsByIP(ctx, GetRecentUploadsByIPParams{
+		UploadIp: pgtype.Text{String: ip, Valid: true},
+		Limit:    int32(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]MediaResourceResult, 0, len(dbResources))
+	for _, dbResource := range dbResources {
+		results = append(results, toMediaResourceResult(dbResource))
+	}
+	return results, nil
+}
+
+func (r *MediaRepository) GetResourcesExpiringBetween(ctx context.Context, from, to time.Time, after ExpiryCursor, limit int) ([]MediaResourceResult, error) {
+	dbResources, err := r.queries.GetResourcesExpiringBetween(ctx, GetResourcesExpiringBetweenParams{
+		ExpiresFrom:    pgtype.Timestamp{Time: from, Valid: true},
+		ExpiresTo:      pgtype.Timestamp{Time: to, Valid: true},
+		AfterExpiresAt: pgtype.Timestamp{Time: after.ExpiresAt, Valid: true},
+		AfterKey:       after.ResourceKey,
+		PageLimit:      int32(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]MediaResourceResult, 0, len(dbResources))
+	for _, dbResource := range dbResources {
+		results = append(results, toMediaResourceResult(dbResource))
+	}
+	return results, nil
+}
+
+func (r *MediaRepository) MarkExpiryNotified(ctx context.Context, resourceKey string) error {
+	return r.queries.MarkExpiryNotified(ctx, resourceKey)
+}
+
+// DeleteUploadsByIP deletes every resource uploaded from ip and returns their keys
+func (r *MediaRepository) DeleteUploadsByIP(ctx context.Context, ip string) ([]string, error) {
+	return r.queries.DeleteUploadsByIP(ctx, pgtype.Text{String: ip, Valid: true})
+}
+
+func (r *MediaRepository) GetMediaResourceForExport(ctx context.Context, resourceKey string) (MediaResourceResult, error) {
+	dbResource, err := r.queries.GetMediaResourceForExport(ctx, resourceKey)
+	if err != nil {
+		return MediaResourceResult{}, err
+	}
+
+	return toMediaResourceResult(dbResource), nil
+}
+
 func (r *MediaRepository) GetMediaResourceForView(ctx context.Context, resourceKey string) (MediaResourceResult, error) {
 	dbResource, err := r.queries.GetMediaResourceForView(ctx, resourceKey)
 	if err != nil {
@@ -133,6 +413,30 @@ func (r *MediaRepository) GetMediaResourceForView(ctx context.Context, resourceK
 	return toMediaResourceResult(dbResource), nil
 }
 
+func (r *MediaRepository) GetResourceEvents(ctx context.Context, resourceKey string, from, to time.Time, limit int) ([]ResourceEventResult, error) {
+	rows, err := r.queries.GetResourceEvents(ctx, GetResourceEventsParams{
+		ResourceKey: resourceKey,
+		AtFrom:      pgtype.Timestamptz{Time: from, Valid: !from.IsZero()},
+		AtTo:        pgtype.Timestamptz{Time: to, Valid: !to.IsZero()},
+		EventLimit:  int32(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]ResourceEventResult, 0, len(rows))
+	for _, row := range rows {
+		events = append(events, ResourceEventResult{
+			EventType:   row.EventType,
+			At:          row.At.Time,
+			PerformedBy: row.PerformedBy,
+			IP:          row.Ip,
+			Details:     row.Details,
+		})
+	}
+	return events, nil
+}
+
 func (r *MediaRepository) GetExpiredResources(ctx context.Context) ([]string, error) {
 	return r.queries.GetExpiredResources(ctx)
 }
@@ -141,6 +445,125 @@ func (r *MediaRepository) DeleteExpiredResources(ctx context.Context) error {
 	return r.queries.DeleteExpiredResources(ctx)
 }
 
+func (r *MediaRepository) DeleteViewedResources(ctx context.Context, keepKeys []string) ([]string, error) {
+	// A nil slice is sent as NULL, and <> ALL(NULL) matches no rows
+	if keepKeys == nil {
+		keepKeys = []string{}
+	}
+	return r.queries.DeleteViewedResources(ctx, keepKeys)
+}
+
+// ErrResourceLocked is returned when another transaction holds the resource advisory lock
+var ErrResourceLocked = errors.New("resource is locked by another transaction")
+
+// ErrAccessCodeNotRedeemed is returned for a resource with access codes when accessCodeHash
+// matches none of its unused codes
+var ErrAccessCodeNotRedeemed = errors.New("access code not redeemed")
+
+// GetMediaResourceByKeyWithLock takes a transaction-scoped advisory lock on the resource,
+// reads it with SELECT ... FOR UPDATE and passes it to view. If view succeeds the access code
+// of accessCodeHash is redeemed, for resources that have codes, and the view is counted in the
+// same transaction and the new view count returned; the lock is released on commit or rollback.
+// With blocking=false it returns ErrResourceLocked instead of waiting for the lock.
+func (r *MediaRepository) GetMediaResourceByKeyWithLock(ctx context.Context, resourceKey string, blocking bool, accessCodeHash string, view func(MediaResourceResult) error) (int, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }() // no-op after commit
+
+	queries := r.queries.WithTx(tx)
+
+	if blocking {
+		if err := queries.LockResource(ctx, resourceKey); err != nil {
+			return 0, err
+		}
+	} else {
+		locked, err := queries.TryLockResource(ctx, resourceKey)
+		if err != nil {
+			return 0, err
+		}
+		if !locked {
+			return 0, ErrResourceLocked
+		}
+	}
+
+	dbResource, err := queries.GetMediaResourceForView(ctx, resourceKey)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := view(toMediaResourceResult(dbResource)); err != nil {
+		return 0, err
+	}
+
+	// A code is used up together with the view, so a failed download leaves it unused
+	redeemed, err := queries.RedeemAccessCode(ctx, RedeemAccessCodeParams{
+		ResourceKey: resourceKey,
+		CodeHash:    accessCodeHash,
+	})
+	if err != nil {
+		return 0, err
+	}
+	if redeemed == 0 {
+		hasCodes, err := queries.HasAccessCodes(ctx, resourceKey)
+		if err != nil {
+			return 0, err
+		}
+		if hasCodes {
+			return 0, ErrAccessCodeNotRedeemed
+		}
+	}
+
+	count, err := queries.IncrementViewCount(ctx, resourceKey)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return int(count), nil
+}
+
+// ReplaceSalt locks the resource row, lets update produce a new salt and key version and stores them atomically.
+// If update returns an error the transaction is rolled back and the salt stays unchanged.
+func (r *MediaRepository) ReplaceSalt(ctx context.Context, resourceKey string, update func(MediaResourceResult) (SaltUpdate, error)) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }() // no-op after commit
+
+	queries := r.queries.WithTx(tx)
+
+	dbResource, err := queries.GetMediaResourceForView(ctx, resourceKey)
+	if err != nil {
+		return err
+	}
+
+	newSalt, err := update(toMediaResourceResult(dbResource))
+	if err != nil {
+		return err
+	}
+
+	params := UpdateSaltParams{
+		ResourceKey:       resourceKey,
+		Salt:              newSalt.Salt,
+		MetadataEncrypted: newSalt.MetadataEncrypted,
+		KeyCheck:          newSalt.KeyCheck,
+		EncryptedSize:     pgtype.Int8{Int64: newSalt.EncryptedSize, Valid: true},
+	}
+	if newSalt.KeyVersion != "" {
+		params.KeyVersion = pgtype.Text{String: newSalt.KeyVersion, Valid: true}
+	}
+	if err := queries.UpdateSalt(ctx, params); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
 func toMediaResourceResult(db MediaResource) MediaResourceResult {
 	result := MediaResourceResult{
 		ResourceKey: db.ResourceKey,
@@ -182,8 +605,38 @@ func toMediaResourceResult(db MediaResource) MediaResourceResult {
 		result.FileExtension = &db.FileExtension.String
 	}
 
-	// Convert blur enabled (default to false if not valid, but should always be valid since column has DEFAULT FALSE)
-	result.BlurEnabled = db.BlurEnabled.Valid && db.BlurEnabled.Bool
+	// Convert blur intensity (NULL means no blur, the column defaults to 0.0)
+	if db.BlurIntensity.Valid {
+		result.BlurIntensity = db.BlurIntensity.Float64
+	}
+
+	// Convert download only
+	result.DownloadOnly = db.DownloadOnly.Valid && db.DownloadOnly.Bool
+
+	// Convert upload IP
+	if db.UploadIp.Valid {
+		result.UploadIP = &db.UploadIp.String
+	}
+
+	result.KeyCheck = db.KeyCheck
+
+	// Convert available at
+	if db.AvailableAt.Valid {
+		result.AvailableAt = &db.AvailableAt.Time
+	}
+
+	result.KeyVersion = db.KeyVersion.String
+
+	// Convert encrypted size
+	if db.EncryptedSize.Valid {
+		result.EncryptedSize = &db.EncryptedSize.Int64
+	}
+
+	result.VerificationPubkey = db.VerificationPubkey
+	result.MetadataEncrypted = db.MetadataEncrypted
+
+	result.MaxViews = int(db.MaxViews)
+	result.ViewCount = int(db.ViewCount)
 
 	return result
 }
@@ -0,0 +1,312 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"lovebin/migrations"
+	"lovebin/modules/postgres"
+)
+
+// newTestRepository returns a repository on the database at TEST_DATABASE_URL with
+// all migrations applied, skipping the test without one. Its queries are recorded.
+func newTestRepository(t *testing.T) (*MediaRepository, *postgres.RecordingPostgres) {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		t.Fatalf("pgxpool.New: %v", err)
+	}
+	if _, err := postgres.NewMigrator(pool, migrations.FS).Up(ctx); err != nil {
+		pool.Close()
+		t.Fatalf("migrations: %v", err)
+	}
+
+	inner, err := postgres.NewMockPostgres(pool)
+	if err != nil {
+		pool.Close()
+		t.Fatalf("NewMockPostgres: %v", err)
+	}
+	recorder, err := postgres.NewRecordingPostgres(ctx, inner)
+	if err != nil {
+		inner.Close()
+		t.Fatalf("NewRecordingPostgres: %v", err)
+	}
+	t.Cleanup(recorder.Close)
+	return NewMediaRepository(recorder.GetPool()), recorder
+}
+
+// createTestResource stores a resource allowing maxViews downloads and removes it after the test
+func createTestResource(t *testing.T, repo *MediaRepository, maxViews int) string {
+	t.Helper()
+	key := make([]byte, 8)
+	_, _ = rand.Read(key)
+	resourceKey := "test-" + hex.EncodeToString(key)
+	expiresAt := time.Now().Add(time.Hour)
+
+	ctx := context.Background()
+	if _, err := repo.CreateMediaResource(ctx, CreateMediaResourceInput{
+		ResourceKey: resourceKey,
+		ExpiresAt:   &expiresAt,
+		Salt:        []byte{1},
+		MaxViews:    maxViews,
+	}); err != nil {
+		t.Fatalf("CreateMediaResource: %v", err)
+	}
+	t.Cleanup(func() { _ = repo.DeleteMediaResource(ctx, resourceKey) })
+	return resourceKey
+}
+
+var errTestViewed = errors.New("viewed")
+
+// viewUnlessViewed is a view callback that refuses resources without views left
+func viewUnlessViewed(resource MediaResourceResult) error {
+	if resource.Viewed {
+		return errTestViewed
+	}
+	return nil
+}
+
+func TestGetMediaResourceByKeyWithLockNonBlocking(t *testing.T) {
+	repo, _ := newTestRepository(t)
+	resourceKey := createTestResource(t, repo, 2)
+	ctx := context.Background()
+
+	holding := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		_, err := repo.GetMediaResourceByKeyWithLock(ctx, resourceKey, true, "", func(MediaResourceResult) error {
+			close(holding)
+			<-release
+			return nil
+		})
+		done <- err
+	}()
+	<-holding
+
+	if _, err := repo.GetMediaResourceByKeyWithLock(ctx, resourceKey, false, "", viewUnlessViewed); !errors.Is(err, ErrResourceLocked) {
+		t.Errorf("non-blocking call while locked: err = %v, want ErrResourceLocked", err)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("lock holder: %v", err)
+	}
+	count, err := repo.GetMediaResourceByKeyWithLock(ctx, resourceKey, false, "", viewUnlessViewed)
+	if err != nil || count != 2 {
+		t.Errorf("after release = %d, %v, want 2, nil", count, err)
+	}
+}
+
+func TestGetMediaResourceByKeyWithLockConcurrentViews(t *testing.T) {
+	repo, _ := newTestRepository(t)
+	ctx := context.Background()
+
+	for _, maxViews := range []int{1, 3} {
+		resourceKey := createTestResource(t, repo, maxViews)
+
+		const downloads = 10
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		succeeded, refused := 0, 0
+		for range downloads {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := repo.GetMediaResourceByKeyWithLock(ctx, resourceKey, true, "", viewUnlessViewed)
+				mu.Lock()
+				defer mu.Unlock()
+				switch {
+				case err == nil:
+					succeeded++
+				case errors.Is(err, errTestViewed):
+					refused++
+				default:
+					t.Errorf("unexpected error: %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if succeeded != maxViews || refused != downloads-maxViews {
+			t.Errorf("max views %d: %d succeeded, %d refused", maxViews, succeeded, refused)
+		}
+		resource, err := repo.GetMediaResourceByKeyAny(ctx, resourceKey)
+		if err != nil {
+			t.Fatalf("GetMediaResourceByKeyAny: %v", err)
+		}
+		if resource.ViewCount != maxViews || !resource.Viewed {
+			t.Errorf("max views %d: view count %d, viewed %v", maxViews, resource.ViewCount, resource.Viewed)
+		}
+	}
+}
+
+func TestGetMediaResourceByKeyWithLockViewErrorKeepsView(t *testing.T) {
+	repo, _ := newTestRepository(t)
+	resourceKey := createTestResource(t, repo, 1)
+	ctx := context.Background()
+
+	failed := errors.New("decryption failed")
+	if _, err := repo.GetMediaResourceByKeyWithLock(ctx, resourceKey, true, "", func(MediaResourceResult) error { return failed }); !errors.Is(err, failed) {
+		t.Fatalf("err = %v, want the view error", err)
+	}
+	resource, err := repo.GetMediaResourceByKey(ctx, resourceKey)
+	if err != nil || resource.ViewCount != 0 {
+		t.Errorf("after a failed view: %+v, %v, want an unviewed resource", resource, err)
+	}
+}
+
+func TestGetMediaResourceByKeyWithLockAccessCodes(t *testing.T) {
+	repo, _ := newTestRepository(t)
+	resourceKey := createTestResource(t, repo, 2)
+	ctx := context.Background()
+	if err := repo.CreateAccessCodes(ctx, resourceKey, []string{"hash-a", "hash-b"}); err != nil {
+		t.Fatalf("CreateAccessCodes: %v", err)
+	}
+	unused := func() int {
+		t.Helper()
+		count, err := repo.CountUnusedAccessCodes(ctx, resourceKey)
+		if err != nil {
+			t.Fatalf("CountUnusedAccessCodes: %v", err)
+		}
+		return count
+	}
+
+	// A failed view leaves the code unused
+	failed := errors.New("decryption failed")
+	if _, err := repo.GetMediaResourceByKeyWithLock(ctx, resourceKey, true, "hash-a", func(MediaResourceResult) error { return failed }); !errors.Is(err, failed) {
+		t.Fatalf("err = %v, want the view error", err)
+	}
+	if got := unused(); got != 2 {
+		t.Errorf("unused codes after a failed view = %d, want 2", got)
+	}
+
+	if _, err := repo.GetMediaResourceByKeyWithLock(ctx, resourceKey, true, "hash-c", viewUnlessViewed); !errors.Is(err, ErrAccessCodeNotRedeemed) {
+		t.Errorf("view with an unknown code err = %v, want ErrAccessCodeNotRedeemed", err)
+	}
+	if count, err := repo.GetMediaResourceByKeyWithLock(ctx, resourceKey, true, "hash-a", viewUnlessViewed); err != nil || count != 1 {
+		t.Fatalf("view with an unused code = %d, %v, want view count 1", count, err)
+	}
+	if _, err := repo.GetMediaResourceByKeyWithLock(ctx, resourceKey, true, "hash-a", viewUnlessViewed); !errors.Is(err, ErrAccessCodeNotRedeemed) {
+		t.Errorf("view with a used code err = %v, want ErrAccessCodeNotRedeemed", err)
+	}
+	if got := unused(); got != 1 {
+		t.Errorf("unused codes = %d, want 1", got)
+	}
+	// Rejected redemptions do not count a view
+	resource, err := repo.GetMediaResourceByKey(ctx, resourceKey)
+	if err != nil || resource.ViewCount != 1 {
+		t.Errorf("resource = %+v, %v, want view count 1", resource, err)
+	}
+}
+
+func TestGetMediaResourceByKeyWithLockQueries(t *testing.T) {
+	repo, recorder := newTestRepository(t)
+	resourceKey := createTestResource(t, repo, 2)
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		blocking bool
+		lock     string
+	}{
+		{"blocking", true, "SELECT pg_advisory_xact_lock(hashtext($1::text))"},
+		{"non-blocking", false, "pg_try_advisory_xact_lock(hashtext($1::text))"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder.Reset()
+			if _, err := repo.GetMediaResourceByKeyWithLock(ctx, resourceKey, tt.blocking, "", viewUnlessViewed); err != nil {
+				t.Fatalf("GetMediaResourceByKeyWithLock: %v", err)
+			}
+			recorder.AssertQuery(t, tt.lock)
+			recorder.AssertQuery(t, "FOR UPDATE")
+			recorder.AssertQuery(t, "view_count = view_count + 1")
+		})
+	}
+}
+
+func TestUploadsByIP(t *testing.T) {
+	repo, _ := newTestRepository(t)
+	ctx := context.Background()
+
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	ip := "test-ip-" + hex.EncodeToString(suffix)
+	create := func(resourceKey string, expiresAt time.Time) {
+		t.Helper()
+		if _, err := repo.CreateMediaResource(ctx, CreateMediaResourceInput{
+			ResourceKey: resourceKey,
+			ExpiresAt:   &expiresAt,
+			Salt:        []byte{1},
+			UploadIP:    &ip,
+		}); err != nil {
+			t.Fatalf("CreateMediaResource: %v", err)
+		}
+		t.Cleanup(func() { _ = repo.DeleteMediaResource(ctx, resourceKey) })
+	}
+	prefix := ip + "-"
+	create(prefix+"old", time.Now().Add(time.Hour))
+	create(prefix+"new", time.Now().Add(time.Hour))
+	create(prefix+"expired", time.Now().Add(-time.Hour))
+	other := createTestResource(t, repo, 1)
+
+	uploads, err := repo.GetRecentUploadsByIP(ctx, ip, 10)
+	if err != nil {
+		t.Fatalf("GetRecentUploadsByIP: %v", err)
+	}
+	if len(uploads) != 2 || uploads[0].ResourceKey != prefix+"new" || uploads[1].ResourceKey != prefix+"old" {
+		t.Errorf("GetRecentUploadsByIP returned %d uploads, want new then old", len(uploads))
+	}
+	if uploads, _ := repo.GetRecentUploadsByIP(ctx, ip, 1); len(uploads) != 1 {
+		t.Errorf("GetRecentUploadsByIP with limit 1 returned %d uploads", len(uploads))
+	}
+
+	deleted, err := repo.DeleteUploadsByIP(ctx, ip)
+	if err != nil {
+		t.Fatalf("DeleteUploadsByIP: %v", err)
+	}
+	// Expired uploads are erased too
+	if len(deleted) != 3 {
+		t.Errorf("DeleteUploadsByIP deleted %q, want 3 resources", deleted)
+	}
+	if _, err := repo.GetMediaResourceByKeyAny(ctx, other); err != nil {
+		t.Errorf("resource of another IP: %v", err)
+	}
+}
+
+func TestAllQueries(t *testing.T) {
+	queries := AllQueries()
+	if want := reflect.TypeFor[Querier]().NumMethod(); len(queries) != want {
+		t.Errorf("AllQueries has %d queries, Querier has %d", len(queries), want)
+	}
+	names := make(map[string]bool)
+	for _, sql := range queries {
+		name, _, _ := strings.Cut(strings.TrimPrefix(sql, "-- name: "), " ")
+		if names[name] {
+			t.Errorf("%s listed twice", name)
+		}
+		names[name] = true
+	}
+
+	// The queries match the migrated schema
+	_, db := newTestRepository(t)
+	if err := postgres.ValidateQueries(context.Background(), db.GetPool(), queries); err != nil {
+		t.Errorf("ValidateQueries: %v", err)
+	}
+}
@@ -0,0 +1,63 @@
+package mediaservice
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"lovebin/modules/timeparser"
+)
+
+func TestUploadMediaAvailableAt(t *testing.T) {
+	now := time.Now().UTC()
+	at := func(d time.Duration) *timeparser.UniversalTime {
+		return &timeparser.UniversalTime{Time: now.Add(d)}
+	}
+
+	tests := []struct {
+		name        string
+		availableAt *timeparser.UniversalTime
+		expiresAt   timeparser.UniversalTime
+		wantStored  bool
+		wantErr     error
+	}{
+		{"not scheduled", nil, timeparser.UniversalTime{}, false, nil},
+		{"zero time", &timeparser.UniversalTime{}, timeparser.UniversalTime{}, false, nil},
+		{"without expiry", at(time.Hour), timeparser.UniversalTime{}, true, nil},
+		{"before expiry", at(time.Hour), *at(2 * time.Hour), true, nil},
+		{"at expiry", at(time.Hour), *at(time.Hour), false, ErrAvailableAfterExpiry},
+		{"after expiry", at(2 * time.Hour), *at(time.Hour), false, ErrAvailableAfterExpiry},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestService(t, Config{})
+			resp, err := svc.UploadMedia(context.Background(), UploadRequest{
+				Data:        bytes.NewReader([]byte("data")),
+				Size:        4,
+				AvailableAt: tt.availableAt,
+				ExpiresAt:   tt.expiresAt,
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UploadMedia error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if n := len(svc.repo.resources); n != 0 {
+					t.Errorf("%d resources stored after a rejected upload", n)
+				}
+				return
+			}
+
+			resourceKey, _, _ := strings.Cut(resp.ResourceKey, "#")
+			resource, _ := svc.repo.resource(resourceKey)
+			if stored := resource.AvailableAt != nil; stored != tt.wantStored {
+				t.Fatalf("AvailableAt stored = %v, want %v", stored, tt.wantStored)
+			}
+			if tt.wantStored && !resource.AvailableAt.Equal(tt.availableAt.Time) {
+				t.Errorf("AvailableAt = %v, want %v", resource.AvailableAt, tt.availableAt.Time)
+			}
+		})
+	}
+}
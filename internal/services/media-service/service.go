@@ -1,22 +1,33 @@
 package mediaservice
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"hash"
 	"io"
 	"path/filepath"
-	"slices"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/sync/singleflight"
 
 	"fmt"
 	mediarepo "lovebin/internal/services/media-service/repository"
+	"lovebin/modules/audit"
+	"lovebin/modules/cache"
+	"lovebin/modules/crypto"
 	"lovebin/modules/encryption"
 	"lovebin/modules/logger"
+	"lovebin/modules/password"
 	"lovebin/modules/postgres"
 	"lovebin/modules/s3"
 	"lovebin/modules/timeparser"
@@ -34,7 +45,17 @@ func repoToServiceMediaResource(repo mediarepo.MediaResourceResult) MediaResourc
 		Salt:          repo.Salt,
 		Filename:      repo.Filename,
 		FileExtension: repo.FileExtension,
-		BlurEnabled:   repo.BlurEnabled,
+		BlurIntensity: repo.BlurIntensity,
+		DownloadOnly:  repo.DownloadOnly,
+		KeyCheck:      repo.KeyCheck,
+		KeyVersion:    repo.KeyVersion,
+		EncryptedSize: repo.EncryptedSize,
+
+		VerificationPubkey: repo.VerificationPubkey,
+		MetadataEncrypted:  repo.MetadataEncrypted,
+
+		MaxViews:  repo.MaxViews,
+		ViewCount: repo.ViewCount,
 	}
 
 	// Convert ExpiresAt
@@ -52,13 +73,24 @@ func repoToServiceMediaResource(repo mediarepo.MediaResourceResult) MediaResourc
 
 func serviceToRepoCreateParams(arg CreateMediaResourceParams) mediarepo.CreateMediaResourceInput {
 	return mediarepo.CreateMediaResourceInput{
-		ResourceKey:   arg.ResourceKey,
-		PasswordHash:  arg.PasswordHash,
-		ExpiresAt:     arg.ExpiresAt,
-		Salt:          arg.Salt,
-		Filename:      arg.Filename,
-		FileExtension: arg.FileExtension,
-		BlurEnabled:   arg.BlurEnabled,
+		ResourceKey:      arg.ResourceKey,
+		PasswordHash:     arg.PasswordHash,
+		ExpiresAt:        arg.ExpiresAt,
+		Salt:             arg.Salt,
+		Filename:         arg.Filename,
+		FileExtension:    arg.FileExtension,
+		BlurIntensity:    arg.BlurIntensity,
+		DownloadOnly:     arg.DownloadOnly,
+		UploadIP:         arg.UploadIP,
+		AllowedCountries: arg.AllowedCountries,
+		KeyCheck:         arg.KeyCheck,
+		AvailableAt:      arg.AvailableAt,
+		KeyVersion:       arg.KeyVersion,
+		EncryptedSize:    arg.EncryptedSize,
+
+		VerificationPubkey: arg.VerificationPubkey,
+		MetadataEncrypted:  arg.MetadataEncrypted,
+		MaxViews:           arg.MaxViews,
 	}
 }
 
@@ -67,28 +99,87 @@ type Service struct {
 	postgres   postgres.Postgres
 	s3         s3.S3
 	encryption encryption.Encryption
+	keys       encryption.KeyManager
 	repo       Repository
+	infoCache  cache.Cache[string, MediaInfo]
+	infoTTL    time.Duration
+	typeCache  cache.Cache[string, string] // stored content type per resource, see objectContentType
+	presignTTL time.Duration
+	infoGroup  singleflight.Group // collapses concurrent cache misses per resource
+	deleter    *deleteWorker      // nil when background deletion is disabled
+	audit      audit.AuditWriter
+
+	presignMu sync.Mutex
+	presigned map[string]time.Time // presigned URL expiry per resource, the object is kept until then
+
+	cleanupMu   sync.Mutex
+	lastCleanup *CleanupRun
+
+	usageMu  sync.Mutex
+	usage    *StorageUsage // nil until first computed
+	usageTTL time.Duration
+
+	minPasswordScore int
+	cleanupWindow    timeparser.Interval
+	maxStoragePerIP  int64               // 0 disables the quota
+	quotaExempt      map[string]struct{} // IPs the quota does not apply to
+	notifier         ExpiryNotifier      // nil when expiry notifications are disabled
+}
+
+// Config holds media service configuration
+type Config struct {
+	DeleteWorkerEnabled    bool // delete S3 objects right after a resource is viewed
+	DeleteWorkerBufferSize int  // pending deletions before new ones are left for the nightly cleanup
+	MinPasswordScore       int  // minimum password.Score for upload passwords (0 accepts any)
+
+	MediaInfoCacheTTL time.Duration // upper bound for how long media info stays cached
+	PresignTTL        time.Duration // lifetime of presigned S3 download URLs
+
+	StorageUsageCacheTTL time.Duration // how long the storage usage of the admin API is reused
+
+	CleanupWindow timeparser.Interval // maintenance window cleanup is restricted to (zero runs it any time)
+
+	ExpiryWebhookURL   string        // posted to for every resource about to expire (empty disables the notifications)
+	ExpiryNotifyWindow time.Duration // how long before expiry resources are notified
+	ExpiryNotifyDryRun bool          // log expiry notifications instead of sending them
+
+	MaxStoragePerIPBytes int64    // stored bytes of active uploads allowed per uploader IP (0 disables the quota)
+	QuotaExemptIPs       []string // uploader IPs without a storage quota
 }
 
-type Repository interface {
-	CreateMediaResource(ctx context.Context, arg mediarepo.CreateMediaResourceInput) (mediarepo.MediaResourceResult, error)
-	GetMediaResourceByKey(ctx context.Context, resourceKey string) (mediarepo.MediaResourceResult, error)
-	GetMediaResourceByKeyAny(ctx context.Context, resourceKey string) (mediarepo.MediaResourceResult, error)
-	MarkAsViewed(ctx context.Context, resourceKey string) error
-	DeleteMediaResource(ctx context.Context, resourceKey string) error
-	GetMediaResourceForView(ctx context.Context, resourceKey string) (mediarepo.MediaResourceResult, error)
-	GetExpiredResources(ctx context.Context) ([]string, error)
-	DeleteExpiredResources(ctx context.Context) error
+// defaultMediaInfoCacheTTL is used when Config.MediaInfoCacheTTL is not set
+const defaultMediaInfoCacheTTL = 5 * time.Minute
+
+// defaultPresignTTL is used when Config.PresignTTL is not set
+const defaultPresignTTL = 60 * time.Second
+
+// noServerKey is the key manager used when NewService gets none: URL keys are used as is
+func noServerKey() encryption.KeyManager {
+	return encryption.NewStaticKeyManager("", nil)
 }
 
+// Repository is the storage the service works with, see mediarepo.Repository
+type Repository = mediarepo.Repository
+
 type CreateMediaResourceParams struct {
-	ResourceKey   string
-	PasswordHash  *string
-	ExpiresAt     *time.Time
-	Salt          []byte
-	Filename      *string
-	FileExtension *string
-	BlurEnabled   bool
+	ResourceKey      string
+	PasswordHash     *string
+	ExpiresAt        *time.Time
+	Salt             []byte
+	Filename         *string
+	FileExtension    *string
+	BlurIntensity    float64
+	DownloadOnly     bool
+	UploadIP         *string
+	AllowedCountries []string
+	KeyCheck         []byte
+	AvailableAt      *time.Time
+	KeyVersion       string
+	EncryptedSize    *int64
+
+	VerificationPubkey []byte
+	MetadataEncrypted  []byte
+	MaxViews           int
 }
 
 type MediaResource struct {
@@ -101,7 +192,25 @@ type MediaResource struct {
 	Salt          []byte
 	Filename      *string
 	FileExtension *string
-	BlurEnabled   bool
+	BlurIntensity float64
+	DownloadOnly  bool
+	KeyCheck      []byte // encryption.KeyCheck of the URL key, nil for older resources
+	KeyVersion    string // server key mixed into the URL key, empty when none
+	EncryptedSize *int64 // size of the S3 object, nil until known
+
+	VerificationPubkey []byte // public key of the upload manifest signature, nil for older resources
+	MetadataEncrypted  []byte // filename and extension sealed with the URL key, nil for older resources
+
+	MaxViews  int // downloads allowed, one per access code for resources with codes
+	ViewCount int // downloads so far
+}
+
+// viewsRemaining returns how many more downloads the resource allows
+func (r MediaResource) viewsRemaining() int {
+	if r.Viewed {
+		return 0
+	}
+	return max(r.MaxViews-r.ViewCount, 0)
 }
 
 func NewService(
@@ -109,31 +218,206 @@ func NewService(
 	postgres postgres.Postgres,
 	s3 s3.S3,
 	encryption encryption.Encryption,
+	keys encryption.KeyManager,
 	repo Repository,
+	infoCache cache.Cache[string, MediaInfo],
+	auditWriter audit.AuditWriter,
+	cfg Config,
 ) *Service {
-	return &Service{
+	logger = logger.Child("media-service")
+	svc := &Service{
 		logger:     logger,
 		postgres:   postgres,
 		s3:         s3,
 		encryption: encryption,
+		keys:       keys,
 		repo:       repo,
+		infoCache:  infoCache,
+		infoTTL:    cfg.MediaInfoCacheTTL,
+		typeCache:  cache.NewLRU[string, string](contentTypeCacheSize),
+		presignTTL: cfg.PresignTTL,
+		usageTTL:   cfg.StorageUsageCacheTTL,
+		audit:      auditWriter,
+		presigned:  make(map[string]time.Time),
+
+		minPasswordScore: cfg.MinPasswordScore,
+		cleanupWindow:    cfg.CleanupWindow,
+		maxStoragePerIP:  cfg.MaxStoragePerIPBytes,
+		quotaExempt:      make(map[string]struct{}, len(cfg.QuotaExemptIPs)),
+	}
+	for _, ip := range cfg.QuotaExemptIPs {
+		svc.quotaExempt[ip] = struct{}{}
+	}
+	if svc.audit == nil {
+		svc.audit = audit.NopWriter{}
+	}
+	if svc.keys == nil {
+		svc.keys = noServerKey()
 	}
+	if svc.infoTTL <= 0 {
+		svc.infoTTL = defaultMediaInfoCacheTTL
+	}
+	if svc.presignTTL <= 0 {
+		svc.presignTTL = defaultPresignTTL
+	}
+	if svc.usageTTL <= 0 {
+		svc.usageTTL = defaultStorageUsageCacheTTL
+	}
+	if cfg.ExpiryWebhookURL != "" {
+		svc.notifier = NewWebhookNotifier(cfg.ExpiryWebhookURL)
+	}
+	if cfg.DeleteWorkerEnabled {
+		svc.deleter = newDeleteWorker(logger, s3, cfg.DeleteWorkerBufferSize)
+	}
+	return svc
+}
+
+// Shutdown stops background workers, waiting for queued work until ctx is done
+func (s *Service) Shutdown(ctx context.Context) error {
+	if s.deleter == nil {
+		return nil
+	}
+	return s.deleter.shutdown(ctx)
 }
 
 type UploadRequest struct {
-	Data        io.Reader
-	Password    string
-	ExpiresAt   timeparser.UniversalTime // zero time means never expires
-	Filename    string                   // original filename
-	BlurEnabled bool                     // enable blur effect on preview
+	Data          io.Reader
+	Size          int64 // length of Data, which is then encrypted as it is read; negative when unknown, 0 reads it whole first
+	Password      string
+	ExpiresAt     timeparser.UniversalTime // zero time means never expires
+	Filename      string                   // original filename
+	BlurIntensity float64                  // preview blur strength, 0 (none) to 1 (maximum)
+	DownloadOnly  bool                     // skip the view page and go straight to download
+	UploadIP      string                   // client IP, empty when unknown
+	AccessCodes   []string                 // single-use codes replacing the password, at most MaxAccessCodes
+	MaxViews      int                      // downloads allowed, 0 is one, at most MaxViews; with access codes one per code
+	// AllowedCountries restricts access to ISO 3166-1 alpha-2 country codes, empty allows all
+	AllowedCountries []string
+	// AvailableAt keeps the resource closed until then (scheduled reveal), nil opens it immediately
+	AvailableAt *timeparser.UniversalTime
+	// Progress is called as the encrypted data is stored, nil when nobody listens
+	Progress s3.ProgressFunc
 }
 
 type UploadResponse struct {
 	ResourceKey string
 	URL         string
+	AccessCodes []string // codes that open the resource, one download each
+	MaxViews    int      // downloads the resource allows
+
+	// Manifest signature, see crypto.SignResourceManifest and ManifestExpiresAt
+	ManifestSignature  []byte
+	VerificationPubkey []byte
+}
+
+// ManifestExpiresAt formats an expiry the way it is signed in upload manifests:
+// RFC 3339 in UTC, as in JSON responses, and empty for resources that never expire
+func ManifestExpiresAt(expiresAt timeparser.UniversalTime) string {
+	if expiresAt.IsZero() {
+		return ""
+	}
+	return expiresAt.Time.UTC().Format(time.RFC3339)
+}
+
+// normalizeCountries upper-cases and deduplicates country codes, rejecting anything but two letters
+func normalizeCountries(countries []string) ([]string, error) {
+	var normalized []string
+	seen := make(map[string]struct{}, len(countries))
+	for _, country := range countries {
+		country = strings.ToUpper(strings.TrimSpace(country))
+		if country == "" {
+			continue
+		}
+		if len(country) != 2 || country[0] < 'A' || country[0] > 'Z' || country[1] < 'A' || country[1] > 'Z' {
+			return nil, ErrInvalidCountryCode
+		}
+		if _, ok := seen[country]; ok {
+			continue
+		}
+		seen[country] = struct{}{}
+		normalized = append(normalized, country)
+	}
+	return normalized, nil
+}
+
+// MaxAccessCodes is the maximum number of access codes per resource
+const MaxAccessCodes = 100
+
+// normalizeAccessCodes trims the codes, drops blank ones and rejects invalid lists
+func normalizeAccessCodes(codes []string, password string) ([]string, error) {
+	normalized := make([]string, 0, len(codes))
+	seen := make(map[string]struct{}, len(codes))
+	for _, code := range codes {
+		code = strings.TrimSpace(code)
+		if code == "" {
+			continue
+		}
+		if _, ok := seen[code]; ok {
+			return nil, ErrDuplicateAccessCode
+		}
+		seen[code] = struct{}{}
+		normalized = append(normalized, code)
+	}
+	if len(normalized) > MaxAccessCodes {
+		return nil, ErrTooManyAccessCodes
+	}
+	// Every code holder must be able to decrypt, so the data cannot depend on a password
+	if len(normalized) > 0 && password != "" {
+		return nil, ErrAccessCodesWithPassword
+	}
+	return normalized, nil
+}
+
+// MaxViews is the maximum number of downloads a resource can allow
+const MaxViews = 100
+
+// normalizeMaxViews returns the downloads a resource allows, one per access code
+// when it has codes
+func normalizeMaxViews(maxViews int, accessCodes []string) (int, error) {
+	if maxViews < 0 || maxViews > MaxViews {
+		return 0, ErrInvalidMaxViews
+	}
+	if len(accessCodes) > 0 {
+		if maxViews > 1 {
+			return 0, ErrMaxViewsWithAccessCodes
+		}
+		return len(accessCodes), nil
+	}
+	return max(maxViews, 1), nil
 }
 
 func (s *Service) UploadMedia(ctx context.Context, req UploadRequest) (*UploadResponse, error) {
+	accessCodes, err := normalizeAccessCodes(req.AccessCodes, req.Password)
+	if err != nil {
+		return nil, err
+	}
+	maxViews, err := normalizeMaxViews(req.MaxViews, accessCodes)
+	if err != nil {
+		return nil, err
+	}
+	allowedCountries, err := normalizeCountries(req.AllowedCountries)
+	if err != nil {
+		return nil, err
+	}
+	var availableAt *time.Time
+	if req.AvailableAt != nil && !req.AvailableAt.IsZero() {
+		if !req.ExpiresAt.IsZero() && !req.AvailableAt.Time.Before(req.ExpiresAt.Time) {
+			return nil, ErrAvailableAfterExpiry
+		}
+		availableAt = &req.AvailableAt.Time
+	}
+	// Written so that NaN fails too
+	if !(req.BlurIntensity >= 0 && req.BlurIntensity <= 1) {
+		return nil, ErrInvalidBlurIntensity
+	}
+
+	// Reject weak passwords before doing any work
+	if req.Password != "" {
+		if score, suggestions := password.Score(req.Password); score < s.minPasswordScore {
+			return nil, &WeakPasswordError{Score: score, Suggestions: suggestions}
+		}
+	}
+
 	// Generate resource key (this will be part of URL)
 	resourceKey, err := encryption.GenerateURLKey()
 	if err != nil {
@@ -147,29 +431,27 @@ func (s *Service) UploadMedia(ctx context.Context, req UploadRequest) (*UploadRe
 	}
 	encKeyBase64 := base64.RawURLEncoding.EncodeToString(encKey)
 
-	// Read all data
-	data, err := io.ReadAll(req.Data)
-	if err != nil {
+	// Stored with the object, downloads are served with it. Sniffing looks at 512 bytes at most.
+	data := bufio.NewReaderSize(req.Data, 512)
+	head, err := data.Peek(512)
+	if err != nil && err != io.EOF {
 		return nil, err
 	}
+	contentType := detectContentType(head, strings.TrimPrefix(filepath.Ext(req.Filename), "."))
+
+	// The plaintext is hashed as it is encrypted, the manifest is signed once it is stored
+	plaintext := &plaintextDigest{Hash: sha256.New()}
 
 	// Encrypt data using encryption key
 	// If password is provided, we use it as additional layer, otherwise use encKey
-	encryptionPassword := string(encKey)
-	if req.Password != "" {
-		// Combine encryption key with password for stronger security
-		encryptionPassword = req.Password + string(encKey)
-	}
-
-	encryptedData, salt, err := s.encryption.Encrypt(data, encryptionPassword)
+	encryptedData, encryptedSize, salt, keyVersion, err := s.sealStream(io.TeeReader(data, plaintext), req.Size, encKey, req.Password)
 	if err != nil {
 		return nil, err
 	}
+	defer encryptedData.Close()
 
-	// Upload to S3
-	s3Key := "media/" + resourceKey
-	_, err = s.s3.Upload(ctx, "", s3Key, bytes.NewReader(encryptedData))
-	if err != nil {
+	// The quota counts stored, i.e. encrypted, bytes; uploads of unknown size are checked again once stored
+	if err := s.checkStorageQuota(ctx, req.UploadIP, max(encryptedSize, 0)); err != nil {
 		return nil, err
 	}
 
@@ -195,7 +477,7 @@ func (s *Service) UploadMedia(ctx context.Context, req UploadRequest) (*UploadRe
 	if req.Filename != "" {
 		// Extract extension from filename
 		extWithDot := filepath.Ext(req.Filename)
-		if extWithDot != "" {
+		if extWithDot != "" && !strings.Contains(extWithDot, fileMetadataSeparator) {
 			// Remove leading dot from extension for storage
 			ext := strings.TrimPrefix(extWithDot, ".")
 			fileExtension = &ext
@@ -211,33 +493,130 @@ func (s *Service) UploadMedia(ctx context.Context, req UploadRequest) (*UploadRe
 		}
 	}
 
+	// Filename and extension are only kept sealed, the URL key is needed to read them
+	metadataEncrypted, err := sealFileMetadata(filename, fileExtension, encKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var uploadIP *string
+	if req.UploadIP != "" {
+		uploadIP = &req.UploadIP
+	}
+
+	// The object and its manifest are stored before the record, so the transaction only
+	// covers the database writes instead of holding a connection for the whole transfer.
+	// A partial upload, or an object whose record failed to commit, is removed.
+	s3Key := "media/" + resourceKey
+	committed := false
+	defer func() {
+		if !committed {
+			_ = s.s3.Delete(ctx, "", s3Key)
+			_ = s3.DeleteManifest(ctx, s.s3, resourceKey)
+		}
+	}()
+
+	// Large objects are uploaded in parts, see s3.TransferManagerConfig
+	if _, err := s.s3.UploadStream(ctx, "", s3Key, encryptedData, encryptedSize, contentType, req.Progress); err != nil {
+		return nil, err
+	}
+	if req.Size > 0 && plaintext.size != req.Size {
+		return nil, errUploadSize
+	}
+	if encryptedSize < 0 {
+		encryptedSize = encryption.StreamEncryptedSize(plaintext.size)
+		if err := s.checkStorageQuota(ctx, req.UploadIP, encryptedSize); err != nil {
+			return nil, err
+		}
+	}
+
+	// Sign the manifest of the plaintext, which is what downloaders can hash
+	contentHashHex := hex.EncodeToString(plaintext.Sum(nil))
+	verificationPubkey, manifestSignature, err := crypto.SignResourceManifest(
+		resourceKey, contentHashHex, ManifestExpiresAt(req.ExpiresAt))
+	if err != nil {
+		return nil, err
+	}
+
+	// The same manifest is stored sealed next to the object, downloads check the content against it
+	sealedManifest, err := sealManifest(newResourceManifest(resourceKey, contentHashHex, req.Filename, req.ExpiresAt), encKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := s3.UploadManifest(ctx, s.s3, resourceKey, sealedManifest); err != nil {
+		return nil, err
+	}
+
 	// Store in database (salt is needed for decryption)
-	_, err = s.repo.CreateMediaResource(ctx, serviceToRepoCreateParams(CreateMediaResourceParams{
-		ResourceKey:   resourceKey,
-		PasswordHash:  passwordHash,
-		ExpiresAt:     expiresAt,
-		Salt:          salt,
-		Filename:      filename,
-		FileExtension: fileExtension,
-		BlurEnabled:   req.BlurEnabled,
-	}))
+	err = s.postgres.WithTx(ctx, func(tx pgx.Tx) error {
+		_, err := s.repo.WithTx(tx).CreateMediaResource(ctx, serviceToRepoCreateParams(CreateMediaResourceParams{
+			ResourceKey:      resourceKey,
+			PasswordHash:     passwordHash,
+			ExpiresAt:        expiresAt,
+			Salt:             salt,
+			BlurIntensity:    req.BlurIntensity,
+			DownloadOnly:     req.DownloadOnly,
+			UploadIP:         uploadIP,
+			AllowedCountries: allowedCountries,
+			KeyCheck:         encryption.KeyCheck(encKey),
+			AvailableAt:      availableAt,
+			KeyVersion:       keyVersion,
+			EncryptedSize:    &encryptedSize,
+
+			VerificationPubkey: verificationPubkey,
+			MetadataEncrypted:  metadataEncrypted,
+			MaxViews:           maxViews,
+		}))
+		if err != nil {
+			return err
+		}
+
+		if len(accessCodes) > 0 {
+			codeHashes := make([]string, 0, len(accessCodes))
+			for _, code := range accessCodes {
+				codeHashes = append(codeHashes, encryption.HashAccessCode(resourceKey, code))
+			}
+			if err := s.repo.WithTx(tx).CreateAccessCodes(ctx, resourceKey, codeHashes); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 	if err != nil {
-		// Cleanup S3 on error
-		_ = s.s3.Delete(ctx, "", s3Key)
 		return nil, err
 	}
+	committed = true
+
+	s.recordAudit(ctx, audit.OpUpload, resourceKey, map[string]any{
+		"size":               plaintext.size,
+		"password_protected": passwordHash != nil,
+		"expires_at":         expiresAt,
+		"download_only":      req.DownloadOnly,
+		"access_codes":       len(accessCodes),
+		"max_views":          maxViews,
+		"allowed_countries":  allowedCountries,
+		"available_at":       availableAt,
+	})
 
 	// Return URL with encryption key as fragment (not sent to server)
 	// Format: /media/{resourceKey}#{encKey}
-	return &UploadResponse{
+	resp := &UploadResponse{
 		ResourceKey: resourceKey + "#" + encKeyBase64,
 		URL:         "/media/" + resourceKey + "#" + encKeyBase64,
-	}, nil
+		MaxViews:    maxViews,
+
+		ManifestSignature:  manifestSignature,
+		VerificationPubkey: verificationPubkey,
+	}
+	if len(accessCodes) > 0 {
+		resp.AccessCodes = accessCodes
+	}
+	return resp, nil
 }
 
 type DownloadRequest struct {
 	ResourceKey  string
-	Password     string
+	Password     string // password, or the access code redeemed with the view
 	EncKeyBase64 string
 }
 
@@ -245,124 +624,244 @@ type MediaInfo struct {
 	Filename      *string
 	FileExtension *string
 	IsImage       bool
-	BlurEnabled   bool
+	BlurIntensity float64
+	DownloadOnly  bool
+
+	ViewsRemaining int // downloads left before the resource is viewed
+
+	// MetadataEncrypted is set when the filename and extension are sealed;
+	// they are then nil unless the URL key was given
+	MetadataEncrypted []byte
 }
 
-// GetMediaInfo gets media file information without downloading
-func (s *Service) GetMediaInfo(ctx context.Context, resourceKey string) (*MediaInfo, error) {
-	// Get resource from database (any, including viewed)
-	repoResource, err := s.repo.GetMediaResourceByKeyAny(ctx, resourceKey)
+// GetMediaInfo gets media file information without downloading. encKeyBase64 is
+// the URL key, needed for the filename and extension of newer resources; it may be empty.
+func (s *Service) GetMediaInfo(ctx context.Context, resourceKey, encKeyBase64 string) (*MediaInfo, error) {
+	info, err := s.cachedMediaInfo(ctx, resourceKey)
 	if err != nil {
-		return nil, ErrNotFound
+		return nil, err
 	}
-	resource := repoToServiceMediaResource(repoResource)
 
-	// Check if it's an image
-	isImage := false
-	if resource.FileExtension != nil {
-		ext := strings.ToLower(*resource.FileExtension)
-		isImage = slices.Contains([]string{"jpg", "jpeg", "png", "gif", "webp", "bmp", "svg", "ico"}, ext)
+	// Only the sealed metadata is cached, it is opened per request
+	if info.MetadataEncrypted != nil {
+		info.Filename, info.FileExtension = openFileMetadata(nil, nil, info.MetadataEncrypted, decodeURLKey(encKeyBase64))
+		info.IsImage = isImageExtension(info.FileExtension)
 	}
-
-	return &MediaInfo{
-		Filename:      resource.Filename,
-		FileExtension: resource.FileExtension,
-		IsImage:       isImage,
-		BlurEnabled:   resource.BlurEnabled,
-	}, nil
+	return info, nil
 }
 
-// GetMediaPreview gets media file for preview (doesn't mark as viewed or delete)
-func (s *Service) GetMediaPreview(ctx context.Context, req *DownloadRequest) (*DownloadResponse, error) {
-	if req.EncKeyBase64 == "" {
-		return nil, ErrMissingEncryptionKey
+// cachedMediaInfo returns media info from the cache, loading it on a miss
+func (s *Service) cachedMediaInfo(ctx context.Context, resourceKey string) (*MediaInfo, error) {
+	cacheKey := mediaInfoCacheKey(resourceKey)
+
+	// Try cache first, cache failures fall through to the database
+	if cached, ok, err := s.infoCache.Get(ctx, cacheKey); err != nil {
+		s.logger.WarnCtx(ctx, "failed to read media info from cache", zap.Error(err), zap.String("resource_key", resourceKey))
+	} else if ok {
+		return &cached, nil
 	}
 
-	// Decode encryption key
-	encKey, err := base64.RawURLEncoding.DecodeString(req.EncKeyBase64)
+	// Concurrent misses share one database load; it must not fail because the first caller went away
+	loadCtx := context.WithoutCancel(ctx)
+	value, err, _ := s.infoGroup.Do(cacheKey, func() (interface{}, error) {
+		return s.loadMediaInfo(loadCtx, resourceKey)
+	})
 	if err != nil {
-		return nil, ErrInvalidEncryptionKey
+		return nil, err
 	}
 
-	// Get resource from database (without lock, don't mark as viewed)
-	repoResource, err := s.repo.GetMediaResourceByKey(ctx, req.ResourceKey)
+	info := *value.(*MediaInfo)
+	return &info, nil
+}
+
+// loadMediaInfo reads media info from the database and caches it
+func (s *Service) loadMediaInfo(ctx context.Context, resourceKey string) (*MediaInfo, error) {
+	// Get resource from database (any, including viewed)
+	repoResource, err := s.repo.GetMediaResourceByKeyAny(ctx, resourceKey)
 	if err != nil {
 		return nil, ErrNotFound
 	}
 	resource := repoToServiceMediaResource(repoResource)
 
-	// Check expiration
-	if !resource.ExpiresAt.IsZero() && resource.ExpiresAt.Time.Before(time.Now().UTC()) {
-		return nil, ErrExpired
-	}
+	info := MediaInfo{
+		Filename:      resource.Filename,
+		FileExtension: resource.FileExtension,
+		IsImage:       isImageExtension(resource.FileExtension),
+		BlurIntensity: resource.BlurIntensity,
+		DownloadOnly:  resource.DownloadOnly,
 
-	// Check if already viewed
-	if resource.Viewed {
-		return nil, ErrAlreadyViewed
+		ViewsRemaining: resource.viewsRemaining(),
+
+		MetadataEncrypted: resource.MetadataEncrypted,
 	}
 
-	// Verify password if required
-	if resource.PasswordHash != nil {
-		if !verifyPassword(req.Password, *resource.PasswordHash) {
-			return nil, ErrInvalidPassword
+	// Never keep info cached past the resource expiration
+	ttl := s.infoTTL
+	if !resource.ExpiresAt.IsZero() {
+		ttl = min(ttl, time.Until(resource.ExpiresAt.Time))
+	}
+	if ttl > 0 {
+		if err := s.infoCache.Set(ctx, mediaInfoCacheKey(resourceKey), info, ttl); err != nil {
+			s.logger.WarnCtx(ctx, "failed to write media info to cache", zap.Error(err), zap.String("resource_key", resourceKey))
 		}
 	}
 
-	// Download from S3
-	s3Key := "media/" + req.ResourceKey
-	data, err := s.s3.Download(ctx, "", s3Key)
+	return &info, nil
+}
+
+// invalidateMediaInfo drops cached media info after the resource state changes
+func (s *Service) invalidateMediaInfo(ctx context.Context, resourceKey string) {
+	if err := s.infoCache.Delete(ctx, mediaInfoCacheKey(resourceKey)); err != nil {
+		s.logger.WarnCtx(ctx, "failed to invalidate media info cache", zap.Error(err), zap.String("resource_key", resourceKey))
+	}
+}
+
+func mediaInfoCacheKey(resourceKey string) string {
+	return "media:info:" + resourceKey
+}
+
+// MaxRecentUploads is the maximum number of entries returned by GetRecentUploads
+const MaxRecentUploads = 50
+
+// RecentUpload is an entry of the personal upload history
+type RecentUpload struct {
+	ResourceKey   string
+	Filename      *string
+	FileExtension *string
+	CreatedAt     timeparser.UniversalTime
+	ExpiresAt     timeparser.UniversalTime
+	Viewed        bool
+}
+
+// ResourceExport is the archival metadata of a resource. It carries neither
+// the password hash nor any key material.
+type ResourceExport struct {
+	ResourceKey   string
+	Filename      *string
+	FileExtension *string
+	CreatedAt     timeparser.UniversalTime
+	ExpiresAt     timeparser.UniversalTime
+	Viewed        bool
+	HasPassword   bool
+	BlurIntensity float64
+	ContentHash   string // hex SHA-256 of the stored encrypted object, empty once the object is deleted
+}
+
+// ExportResource returns the metadata of a resource in any state, expired and viewed included
+func (s *Service) ExportResource(ctx context.Context, resourceKey string) (*ResourceExport, error) {
+	repoResource, err := s.repo.GetMediaResourceForExport(ctx, resourceKey)
 	if err != nil {
-		return nil, ErrNotFound
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
 	}
+	resource := repoToServiceMediaResource(repoResource)
 
-	// Decrypt data
-	encryptedData, err := io.ReadAll(data)
+	contentHash, err := s.objectHash(ctx, resourceKey)
 	if err != nil {
 		return nil, err
 	}
-	err = data.Close()
+
+	return &ResourceExport{
+		ResourceKey:   resource.ResourceKey,
+		Filename:      resource.Filename,
+		FileExtension: resource.FileExtension,
+		CreatedAt:     resource.CreatedAt,
+		ExpiresAt:     resource.ExpiresAt,
+		Viewed:        resource.Viewed,
+		HasPassword:   resource.PasswordHash != nil && *resource.PasswordHash != "",
+		BlurIntensity: resource.BlurIntensity,
+		ContentHash:   contentHash,
+	}, nil
+}
+
+// objectHash streams the encrypted object of a resource through SHA-256.
+// A missing object, e.g. deleted after viewing, gives an empty hash.
+func (s *Service) objectHash(ctx context.Context, resourceKey string) (string, error) {
+	// Like openObject, a failed download means the object is gone
+	data, err := s.s3.Download(ctx, "", "media/"+resourceKey)
 	if err != nil {
-		return nil, fmt.Errorf("failed to close data: %w", err)
+		return "", nil
 	}
+	defer data.Close()
 
-	// Reconstruct encryption password
-	encryptionPassword := string(encKey)
-	if req.Password != "" {
-		encryptionPassword = req.Password + string(encKey)
+	hash := sha256.New()
+	if _, err := io.Copy(hash, data); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// GetRecentUploads returns the newest active uploads made from ip
+func (s *Service) GetRecentUploads(ctx context.Context, ip string, limit int) ([]RecentUpload, error) {
+	if ip == "" {
+		return nil, nil
+	}
+	if limit <= 0 || limit > MaxRecentUploads {
+		limit = MaxRecentUploads
 	}
 
-	decryptedData, err := s.encryption.Decrypt(encryptedData, resource.Salt, encryptionPassword)
+	resources, err := s.repo.GetRecentUploadsByIP(ctx, ip, limit)
 	if err != nil {
-		return nil, ErrDecryptionFailed
+		return nil, err
 	}
 
-	// Return preview (don't delete or mark as viewed)
-	return &DownloadResponse{
-		Data:          io.NopCloser(bytes.NewReader(decryptedData)),
-		Filename:      resource.Filename,
-		FileExtension: resource.FileExtension,
-	}, nil
+	uploads := make([]RecentUpload, 0, len(resources))
+	for _, repoResource := range resources {
+		resource := repoToServiceMediaResource(repoResource)
+		uploads = append(uploads, RecentUpload{
+			ResourceKey:   resource.ResourceKey,
+			Filename:      resource.Filename,
+			FileExtension: resource.FileExtension,
+			CreatedAt:     resource.CreatedAt,
+			ExpiresAt:     resource.ExpiresAt,
+			Viewed:        resource.Viewed,
+		})
+	}
+	return uploads, nil
 }
 
-type DownloadResponse struct {
-	Data          io.ReadCloser
-	Filename      *string
-	FileExtension *string
+// DeleteUploadsByIP removes every resource uploaded from ip (right to erasure)
+// and returns how many were deleted
+func (s *Service) DeleteUploadsByIP(ctx context.Context, ip string) (int, error) {
+	if ip == "" {
+		return 0, nil
+	}
+
+	resourceKeys, err := s.repo.DeleteUploadsByIP(ctx, ip)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, resourceKey := range resourceKeys {
+		// The records are gone, an object left behind is picked up by the orphan cleanup
+		if err := s.s3.Delete(ctx, "", "media/"+resourceKey); err != nil {
+			s.warnDeleteFailed(ctx, "failed to delete resource from S3", resourceKey, err)
+		}
+		s.deleteManifest(ctx, resourceKey)
+		s.invalidateMediaInfo(ctx, resourceKey)
+		s.recordAudit(ctx, audit.OpDeleteByOwner, resourceKey, nil)
+	}
+
+	s.logger.InfoCtx(ctx, "deleted uploads by owner", zap.Int("deleted_count", len(resourceKeys)))
+	return len(resourceKeys), nil
 }
 
-func (s *Service) DownloadMedia(ctx context.Context, req *DownloadRequest) (*DownloadResponse, error) {
+// GetMediaPreview gets media file for preview (doesn't mark as viewed or delete)
+func (s *Service) GetMediaPreview(ctx context.Context, req *DownloadRequest) (*DownloadResponse, error) {
 	if req.EncKeyBase64 == "" {
 		return nil, ErrMissingEncryptionKey
 	}
 
 	// Decode encryption key
-	encKey, err := base64.RawURLEncoding.DecodeString(req.EncKeyBase64)
+	encKey, err := encryption.ConstantTimeDecode(req.EncKeyBase64, encryption.URLKeySize)
 	if err != nil {
 		return nil, ErrInvalidEncryptionKey
 	}
 
-	// Get resource from database with lock
-	repoResource, err := s.repo.GetMediaResourceForView(ctx, req.ResourceKey)
+	// Get resource from database (without lock, don't mark as viewed)
+	repoResource, err := s.repo.GetMediaResourceByKey(ctx, req.ResourceKey)
 	if err != nil {
 		return nil, ErrNotFound
 	}
@@ -385,52 +884,510 @@ func (s *Service) DownloadMedia(ctx context.Context, req *DownloadRequest) (*Dow
 		}
 	}
 
-	// Download from S3
-	s3Key := "media/" + req.ResourceKey
-	data, err := s.s3.Download(ctx, "", s3Key)
-	if err != nil {
-		return nil, ErrNotFound
-	}
-
-	// Decrypt data first
-	encryptedData, err := io.ReadAll(data)
-	data.Close()
+	// Download from S3, decrypting as the preview is sent
+	decryptedData, size, encryptedSize, err := s.openObject(ctx, resource, encKey, req.Password)
 	if err != nil {
 		return nil, err
 	}
 
-	// Reconstruct encryption password
-	encryptionPassword := string(encKey)
-	if req.Password != "" {
-		encryptionPassword = req.Password + string(encKey)
+	// Resources uploaded before sizes were recorded learn theirs on first preview
+	if resource.EncryptedSize == nil {
+		if err := s.repo.UpdateEncryptedSize(ctx, req.ResourceKey, encryptedSize); err != nil {
+			s.logger.WarnCtx(ctx, "failed to record encrypted size", zap.Error(err), zap.String("resource_key", req.ResourceKey))
+		}
 	}
 
-	decryptedData, err := s.encryption.Decrypt(encryptedData, resource.Salt, encryptionPassword)
-	if err != nil {
-		return nil, ErrDecryptionFailed
+	// Return preview (don't delete or mark as viewed)
+	filename, fileExtension := openResourceMetadata(resource, encKey)
+	return &DownloadResponse{
+		Data:          decryptedData,
+		Size:          size,
+		ContentType:   s.objectContentType(ctx, req.ResourceKey),
+		Filename:      filename,
+		FileExtension: fileExtension,
+	}, nil
+}
+
+type DownloadResponse struct {
+	Data              io.ReadCloser
+	Size              int64  // length of Data in bytes
+	ContentType       string // content type detected on upload, empty when unknown
+	Filename          *string
+	FileExtension     *string
+	ExpiresAt         timeparser.UniversalTime // zero time means never expires
+	PasswordProtected bool
+	ViewsRemaining    int // downloads left after this one
+}
+
+func (s *Service) DownloadMedia(ctx context.Context, req *DownloadRequest) (*DownloadResponse, error) {
+	if req.EncKeyBase64 == "" {
+		return nil, ErrMissingEncryptionKey
 	}
 
-	// Mark as viewed (don't delete, just mark as viewed)
-	err = s.repo.MarkAsViewed(ctx, req.ResourceKey)
+	// Decode encryption key
+	encKey, err := encryption.ConstantTimeDecode(req.EncKeyBase64, encryption.URLKeySize)
 	if err != nil {
-		// Log error but don't fail the download
-		s.logger.Warn("failed to mark resource as viewed", zap.Error(err), zap.String("resource_key", req.ResourceKey))
+		return nil, ErrInvalidEncryptionKey
 	}
 
-	return &DownloadResponse{
-		Data:          io.NopCloser(bytes.NewReader(decryptedData)),
-		Filename:      resource.Filename,
-		FileExtension: resource.FileExtension,
+	var resp *DownloadResponse
+	maxViews := 0
+	view := func(repoResource mediarepo.MediaResourceResult) error {
+		resource := repoToServiceMediaResource(repoResource)
+
+		// Check expiration
+		if !resource.ExpiresAt.IsZero() && resource.ExpiresAt.Time.Before(time.Now().UTC()) {
+			return ErrExpired
+		}
+
+		// Check if every view is used up
+		if resource.viewsRemaining() == 0 {
+			return ErrAlreadyViewed
+		}
+		maxViews = resource.MaxViews
+
+		// Verify password if required
+		if resource.PasswordHash != nil {
+			if !verifyPassword(req.Password, *resource.PasswordHash) {
+				return ErrInvalidPassword
+			}
+		}
+
+		// Open the object before the view is counted, the data is decrypted while it is
+		// sent and checked against the manifest at its end
+		decryptedData, size, _, err := s.openObject(ctx, resource, encKey, req.Password)
+		if err != nil {
+			return err
+		}
+
+		filename, fileExtension := openResourceMetadata(resource, encKey)
+		resp = &DownloadResponse{
+			Data:              decryptedData,
+			Size:              size,
+			ContentType:       s.objectContentType(ctx, req.ResourceKey),
+			Filename:          filename,
+			FileExtension:     fileExtension,
+			ExpiresAt:         resource.ExpiresAt,
+			PasswordProtected: resource.PasswordHash != nil,
+		}
+		return nil
+	}
+
+	// Get resource under advisory lock, the access code is redeemed and the view counted
+	// in the same transaction
+	codeHash := encryption.HashAccessCode(req.ResourceKey, req.Password)
+	viewCount, err := s.repo.GetMediaResourceByKeyWithLock(ctx, req.ResourceKey, false, codeHash, view)
+	if errors.Is(err, mediarepo.ErrResourceLocked) {
+		// Another download is in progress, wait for it and re-check the resource state
+		viewCount, err = s.repo.GetMediaResourceByKeyWithLock(ctx, req.ResourceKey, true, codeHash, view)
+	}
+	if err != nil {
+		if resp != nil {
+			// Opened, but the view was not counted
+			resp.Data.Close()
+		}
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		if errors.Is(err, mediarepo.ErrAccessCodeNotRedeemed) {
+			return nil, s.accessCodeError(ctx, req.ResourceKey)
+		}
+		return nil, err
+	}
+	resp.ViewsRemaining = max(maxViews-viewCount, 0)
+
+	// The resource is viewed once its last view is used
+	s.invalidateMediaInfo(ctx, req.ResourceKey)
+	s.recordAudit(ctx, audit.OpDownload, req.ResourceKey, map[string]any{"view_count": viewCount})
+
+	// The object is still being read, it is deleted after the last view is sent
+	if s.deleter != nil && resp.ViewsRemaining == 0 {
+		resp.Data = &closeHook{ReadCloser: resp.Data, fn: func() {
+			s.deleter.enqueue(ctx, req.ResourceKey)
+		}}
+	}
+
+	return resp, nil
+}
+
+// accessCodeError reports why an access code was not redeemed: the resource is
+// viewed once every code is used, otherwise the code is wrong
+func (s *Service) accessCodeError(ctx context.Context, resourceKey string) error {
+	unused, err := s.repo.CountUnusedAccessCodes(ctx, resourceKey)
+	if err != nil {
+		return err
+	}
+	if unused == 0 {
+		return ErrAlreadyViewed
+	}
+	return ErrInvalidPassword
+}
+
+// PresignScheme describes how presigned downloads are decrypted by the client:
+// AES-256-GCM keyed with SHA-256 of the raw URL key, the blob is nonce (12 bytes) || ciphertext
+const PresignScheme = "aes-256-gcm-sha256"
+
+// PresignSchemeChunked is PresignScheme for objects uploaded as a stream, the blob is
+// AES-256-GCM in chunks as described in modules/encryption/stream.go
+const PresignSchemeChunked = "aes-256-gcm-sha256-chunked"
+
+type PresignedDownload struct {
+	URL           string
+	ExpiresAt     timeparser.UniversalTime // when URL stops working
+	Scheme        string                   // PresignScheme or PresignSchemeChunked
+	Filename      *string
+	FileExtension *string
+
+	ViewsRemaining int // downloads left after this one
+}
+
+// PresignDownload consumes a view like DownloadMedia, but instead of decrypting
+// it returns a short-lived S3 URL of the encrypted object for in-browser decryption.
+// Only resources sealed with the URL key alone under AES-GCM qualify, the key is
+// checked against the stored key check so the view cannot be burned without it.
+func (s *Service) PresignDownload(ctx context.Context, req *DownloadRequest) (*PresignedDownload, error) {
+	if req.EncKeyBase64 == "" {
+		return nil, ErrMissingEncryptionKey
+	}
+
+	encKey, err := encryption.ConstantTimeDecode(req.EncKeyBase64, encryption.URLKeySize)
+	if err != nil {
+		return nil, ErrInvalidEncryptionKey
+	}
+
+	if s.encryption.Cipher() != encryption.CipherAESGCM {
+		// Browsers have no AES-SIV
+		return nil, ErrPresignUnsupported
+	}
+
+	var resp *PresignedDownload
+	maxViews := 0
+	view := func(repoResource mediarepo.MediaResourceResult) error {
+		resource := repoToServiceMediaResource(repoResource)
+
+		// Check expiration
+		if !resource.ExpiresAt.IsZero() && resource.ExpiresAt.Time.Before(time.Now().UTC()) {
+			return ErrExpired
+		}
+
+		// Check if every view is used up
+		if resource.viewsRemaining() == 0 {
+			return ErrAlreadyViewed
+		}
+		maxViews = resource.MaxViews
+
+		// Password resources derive their key from the password, older ones cannot verify the key,
+		// and the browser has no server key
+		fastSalt := encryption.IsFastSalt(resource.Salt) || encryption.IsFastStreamSalt(resource.Salt)
+		if resource.PasswordHash != nil || !fastSalt || resource.KeyCheck == nil || resource.KeyVersion != "" {
+			return ErrPresignUnsupported
+		}
+		if !hmac.Equal(resource.KeyCheck, encryption.KeyCheck(encKey)) {
+			return ErrInvalidEncryptionKey
+		}
+
+		url, err := s.s3.PresignGetURL(ctx, "", "media/"+req.ResourceKey, s.presignTTL)
+		if err != nil {
+			return err
+		}
+
+		scheme := PresignScheme
+		if encryption.IsFastStreamSalt(resource.Salt) {
+			scheme = PresignSchemeChunked
+		}
+		filename, fileExtension := openResourceMetadata(resource, encKey)
+		resp = &PresignedDownload{
+			URL:           url,
+			ExpiresAt:     timeparser.NewUniversalTime(time.Now().Add(s.presignTTL)),
+			Scheme:        scheme,
+			Filename:      filename,
+			FileExtension: fileExtension,
+		}
+		return nil
+	}
+
+	codeHash := encryption.HashAccessCode(req.ResourceKey, req.Password)
+	viewCount, err := s.repo.GetMediaResourceByKeyWithLock(ctx, req.ResourceKey, false, codeHash, view)
+	if errors.Is(err, mediarepo.ErrResourceLocked) {
+		viewCount, err = s.repo.GetMediaResourceByKeyWithLock(ctx, req.ResourceKey, true, codeHash, view)
+	}
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		if errors.Is(err, mediarepo.ErrAccessCodeNotRedeemed) {
+			return nil, s.accessCodeError(ctx, req.ResourceKey)
+		}
+		return nil, err
+	}
+	resp.ViewsRemaining = max(maxViews-viewCount, 0)
+
+	s.invalidateMediaInfo(ctx, req.ResourceKey)
+	s.recordAudit(ctx, audit.OpDownload, req.ResourceKey, map[string]any{"presigned": true, "view_count": viewCount})
+
+	// The client still needs the object until the URL expires
+	s.trackPresigned(req.ResourceKey)
+	if s.deleter != nil && resp.ViewsRemaining == 0 {
+		s.deleter.enqueueAfter(req.ResourceKey, s.presignTTL)
+	}
+
+	return resp, nil
+}
+
+type ReencryptRequest struct {
+	ResourceKey     string
+	Password        string // required when the resource is password protected
+	EncKeyBase64    string // current encryption key
+	NewEncKeyBase64 string // optional, generated when empty
+}
+
+type ReencryptResponse struct {
+	ResourceKey string
+	URL         string
+}
+
+// ReencryptResource rotates the encryption key of a stored resource without changing its URL key
+func (s *Service) ReencryptResource(ctx context.Context, req *ReencryptRequest) (*ReencryptResponse, error) {
+	if req.EncKeyBase64 == "" {
+		return nil, ErrMissingEncryptionKey
+	}
+
+	oldKey, err := encryption.ConstantTimeDecode(req.EncKeyBase64, encryption.URLKeySize)
+	if err != nil {
+		return nil, ErrInvalidEncryptionKey
+	}
+
+	var newKey []byte
+	if req.NewEncKeyBase64 != "" {
+		newKey, err = encryption.ConstantTimeDecode(req.NewEncKeyBase64, encryption.URLKeySize)
+		if err != nil {
+			return nil, ErrInvalidEncryptionKey
+		}
+	} else {
+		newKey, err = s.encryption.GenerateKey()
+		if err != nil {
+			return nil, err
+		}
+	}
+	newKeyBase64 := base64.RawURLEncoding.EncodeToString(newKey)
+
+	s3Key := "media/" + req.ResourceKey
+
+	// The original ciphertext is kept as a server-side copy to restore it if the
+	// DB update fails after upload, so large objects are never held in memory
+	backupKey := reencryptBackupPrefix + req.ResourceKey
+	var contentType string      // detected again from the plaintext, as on upload
+	var originalManifest []byte // nil for resources without a manifest
+	backedUp, uploaded := false, false
+
+	err = s.repo.ReplaceSalt(ctx, req.ResourceKey, func(repoResource mediarepo.MediaResourceResult) (mediarepo.SaltUpdate, error) {
+		resource := repoToServiceMediaResource(repoResource)
+
+		// Check expiration
+		if !resource.ExpiresAt.IsZero() && resource.ExpiresAt.Time.Before(time.Now().UTC()) {
+			return mediarepo.SaltUpdate{}, ErrExpired
+		}
+
+		// Check if already viewed
+		if resource.Viewed {
+			return mediarepo.SaltUpdate{}, ErrAlreadyViewed
+		}
+
+		// Verify password if required
+		if resource.PasswordHash != nil {
+			if !verifyPassword(req.Password, *resource.PasswordHash) {
+				return mediarepo.SaltUpdate{}, ErrInvalidPassword
+			}
+		}
+
+		// The manifest moves to the new key as well, the content is checked against it while streaming
+		manifest, sealedManifest, hasManifest, err := s.loadManifest(ctx, req.ResourceKey, oldKey)
+		if err != nil {
+			return mediarepo.SaltUpdate{}, err
+		}
+		var resealedManifest []byte
+		if hasManifest {
+			resealedManifest, err = sealManifest(manifest, newKey)
+			if err != nil {
+				return mediarepo.SaltUpdate{}, err
+			}
+		}
+
+		// Sealed metadata moves to the new key, older resources keep it in plaintext
+		filename, fileExtension := openResourceMetadata(resource, oldKey)
+		var metadataEncrypted []byte
+		if resource.MetadataEncrypted != nil {
+			metadataEncrypted, err = sealFileMetadata(filename, fileExtension, newKey)
+			if err != nil {
+				return mediarepo.SaltUpdate{}, err
+			}
+		}
+
+		// Decrypt the current ciphertext with the old key as it is downloaded
+		var encryptedSize int64
+		if resource.EncryptedSize != nil {
+			encryptedSize = *resource.EncryptedSize
+		} else if encryptedSize, err = s.s3.ObjectSize(ctx, "", s3Key); err != nil {
+			return mediarepo.SaltUpdate{}, ErrNotFound
+		}
+		encryptedData, err := s.s3.Download(ctx, "", s3Key)
+		if err != nil {
+			return mediarepo.SaltUpdate{}, ErrNotFound
+		}
+		decrypted, size, err := s.openStream(encryptedData, encryptedSize, resource.Salt, resource.KeyVersion, oldKey, req.Password)
+		if err != nil {
+			return mediarepo.SaltUpdate{}, ErrDecryptionFailed
+		}
+		defer decrypted.Close()
+
+		var extension string
+		if fileExtension != nil {
+			extension = *fileExtension
+		}
+		data := bufio.NewReaderSize(decrypted, 512)
+		head, err := data.Peek(512)
+		if err != nil && err != io.EOF {
+			return mediarepo.SaltUpdate{}, reencryptReadError(err)
+		}
+		contentType = detectContentType(head, extension)
+
+		// Encrypt with the new key, a fresh salt and the current server key
+		plaintext := &plaintextDigest{Hash: sha256.New()}
+		reencryptedData, reencryptedSize, newSalt, keyVersion, err := s.sealStream(io.TeeReader(data, plaintext), size, newKey, req.Password)
+		if err != nil {
+			return mediarepo.SaltUpdate{}, reencryptReadError(err)
+		}
+		defer reencryptedData.Close()
+
+		// Overwrite the object in S3
+		if err := s.s3.CopyObject(ctx, "", s3Key, "", backupKey); err != nil {
+			return mediarepo.SaltUpdate{}, err
+		}
+		backedUp = true
+		uploaded = true
+		if _, err := s.s3.UploadStream(ctx, "", s3Key, reencryptedData, reencryptedSize, contentType, nil); err != nil {
+			return mediarepo.SaltUpdate{}, reencryptReadError(err)
+		}
+		if hasManifest && !manifestDigestMatches(manifest, plaintext.Sum(nil)) {
+			return mediarepo.SaltUpdate{}, ErrManifestMismatch
+		}
+		if hasManifest {
+			originalManifest = sealedManifest
+			if err := s3.UploadManifest(ctx, s.s3, req.ResourceKey, resealedManifest); err != nil {
+				return mediarepo.SaltUpdate{}, err
+			}
+		}
+
+		return mediarepo.SaltUpdate{
+			Salt:              newSalt,
+			KeyVersion:        keyVersion,
+			MetadataEncrypted: metadataEncrypted,
+			KeyCheck:          encryption.KeyCheck(newKey),
+			EncryptedSize:     reencryptedSize,
+		}, nil
+	})
+	if err != nil && uploaded {
+		// Salt was not updated, put the old ciphertext back
+		if restoreErr := s.s3.CopyObject(ctx, "", backupKey, "", s3Key); restoreErr != nil {
+			s.logger.ErrorCtx(ctx, "failed to restore original ciphertext", zap.Error(restoreErr), zap.String("resource_key", req.ResourceKey))
+			backedUp = false // the backup is the only copy left
+		}
+		if originalManifest != nil {
+			if restoreErr := s3.UploadManifest(ctx, s.s3, req.ResourceKey, originalManifest); restoreErr != nil {
+				s.logger.ErrorCtx(ctx, "failed to restore original manifest", zap.Error(restoreErr), zap.String("resource_key", req.ResourceKey))
+			}
+		}
+	}
+	if backedUp {
+		if deleteErr := s.s3.Delete(ctx, "", backupKey); deleteErr != nil {
+			s.warnDeleteFailed(ctx, "failed to delete re-encryption backup from S3", req.ResourceKey, deleteErr)
+		}
+	}
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	// Cached info holds the metadata sealed with the old key
+	s.invalidateMediaInfo(ctx, req.ResourceKey)
+	s.logger.InfoCtx(ctx, "resource re-encrypted", zap.String("resource_key", req.ResourceKey))
+	s.recordAudit(ctx, audit.OpReencrypt, req.ResourceKey, nil)
+
+	return &ReencryptResponse{
+		ResourceKey: req.ResourceKey + "#" + newKeyBase64,
+		URL:         "/media/" + req.ResourceKey + "#" + newKeyBase64,
 	}, nil
 }
 
-// CleanupExpiredResources removes expired resources from database and S3
-func (s *Service) CleanupExpiredResources(ctx context.Context) error {
+// reencryptBackupPrefix is the S3 key prefix of the original objects kept while
+// ReencryptResource replaces them, outside MediaPrefix so cleanup does not see them
+const reencryptBackupPrefix = "reencrypt/"
+
+// reencryptReadError maps a failure to read the old ciphertext, seen only once
+// it is streamed, to ErrDecryptionFailed
+func reencryptReadError(err error) error {
+	if errors.Is(err, encryption.ErrStreamCorrupted) {
+		return ErrDecryptionFailed
+	}
+	return err
+}
+
+// CleanupResult counts the resources removed by a cleanup run
+type CleanupResult struct {
+	DeletedExpired int
+	DeletedViewed  int
+}
+
+// CleanupRun describes a finished cleanup run
+type CleanupRun struct {
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Result     CleanupResult
+	Err        error // nil when the run succeeded
+}
+
+// CleanupExpiredResources removes expired and viewed resources from database and S3.
+// On error the result still counts what was removed before it. Outside the
+// configured cleanup window nothing is removed and ErrOutsideCleanupWindow is returned.
+func (s *Service) CleanupExpiredResources(ctx context.Context) (CleanupResult, error) {
+	if !s.cleanupWindow.IsZero() && !s.cleanupWindow.Contains(timeparser.NewUniversalTimeNow()) {
+		return CleanupResult{}, ErrOutsideCleanupWindow
+	}
+
+	run := CleanupRun{StartedAt: time.Now().UTC()}
+	defer func() {
+		run.FinishedAt = time.Now().UTC()
+		s.cleanupMu.Lock()
+		s.lastCleanup = &run
+		s.cleanupMu.Unlock()
+	}()
+
+	run.Result.DeletedExpired, run.Err = s.deleteExpiredResources(ctx)
+	if run.Err != nil {
+		return run.Result, run.Err
+	}
+	run.Result.DeletedViewed, run.Err = s.DeleteViewedResources(ctx)
+	return run.Result, run.Err
+}
+
+// LastCleanup returns the most recent cleanup run, false if none ran since startup
+func (s *Service) LastCleanup() (CleanupRun, bool) {
+	s.cleanupMu.Lock()
+	defer s.cleanupMu.Unlock()
+	if s.lastCleanup == nil {
+		return CleanupRun{}, false
+	}
+	return *s.lastCleanup, true
+}
+
+func (s *Service) deleteExpiredResources(ctx context.Context) (int, error) {
 	// Get list of expired resource keys before deletion
 	expiredKeys, err := s.repo.GetExpiredResources(ctx)
 	if err != nil {
-		s.logger.Error("failed to get expired resources", zap.Error(err))
-		return err
+		s.logger.ErrorCtx(ctx, "failed to get expired resources", zap.Error(err))
+		return 0, err
 	}
 
 	// Delete from S3
@@ -438,23 +1395,414 @@ func (s *Service) CleanupExpiredResources(ctx context.Context) error {
 		s3Key := "media/" + resourceKey
 		if err := s.s3.Delete(ctx, "", s3Key); err != nil {
 			// Log error but continue with other deletions
-			s.logger.Warn("failed to delete expired resource from S3", zap.String("resource_key", resourceKey), zap.Error(err))
+			s.warnDeleteFailed(ctx, "failed to delete expired resource from S3", resourceKey, err)
 		} else {
-			s.logger.Info("deleted expired resource from S3", zap.String("resource_key", resourceKey))
+			s.logger.InfoCtx(ctx, "deleted expired resource from S3", zap.String("resource_key", resourceKey))
 		}
+		s.deleteManifest(ctx, resourceKey)
 	}
 
 	// Delete from database
 	if err := s.repo.DeleteExpiredResources(ctx); err != nil {
-		s.logger.Error("failed to delete expired resources from database", zap.Error(err))
+		s.logger.ErrorCtx(ctx, "failed to delete expired resources from database", zap.Error(err))
+		return 0, err
+	}
+
+	for _, resourceKey := range expiredKeys {
+		s.invalidateMediaInfo(ctx, resourceKey)
+		s.recordAudit(ctx, audit.OpCleanup, resourceKey, nil)
+	}
+
+	s.logger.InfoCtx(ctx, "cleanup completed", zap.Int("deleted_count", len(expiredKeys)))
+	return len(expiredKeys), nil
+}
+
+// DeleteViewedResources removes viewed resources from database and S3 and returns
+// how many were removed. Resources with a presigned URL that has not expired yet
+// are kept, the client may still be downloading the object.
+func (s *Service) DeleteViewedResources(ctx context.Context) (int, error) {
+	viewedKeys, err := s.repo.DeleteViewedResources(ctx, s.activePresigned())
+	if err != nil {
+		s.logger.ErrorCtx(ctx, "failed to delete viewed resources from database", zap.Error(err))
+		return 0, err
+	}
+
+	for _, resourceKey := range viewedKeys {
+		// The delete worker usually removed the object already
+		if err := s.s3.Delete(ctx, "", "media/"+resourceKey); err != nil {
+			s.warnDeleteFailed(ctx, "failed to delete viewed resource from S3", resourceKey, err)
+		}
+		s.deleteManifest(ctx, resourceKey)
+		s.invalidateMediaInfo(ctx, resourceKey)
+		s.recordAudit(ctx, audit.OpCleanup, resourceKey, map[string]any{"viewed": true})
+	}
+
+	s.logger.InfoCtx(ctx, "viewed resources cleanup completed", zap.Int("deleted_count", len(viewedKeys)))
+	return len(viewedKeys), nil
+}
+
+// trackPresigned records that a presigned URL for resourceKey is valid for presignTTL
+func (s *Service) trackPresigned(resourceKey string) {
+	s.presignMu.Lock()
+	s.presigned[resourceKey] = time.Now().Add(s.presignTTL)
+	s.presignMu.Unlock()
+}
+
+// activePresigned returns the resources whose presigned URLs have not expired, forgetting the rest
+func (s *Service) activePresigned() []string {
+	s.presignMu.Lock()
+	defer s.presignMu.Unlock()
+
+	now := time.Now()
+	keys := make([]string, 0, len(s.presigned))
+	for resourceKey, expiresAt := range s.presigned {
+		if now.After(expiresAt) {
+			delete(s.presigned, resourceKey)
+			continue
+		}
+		keys = append(keys, resourceKey)
+	}
+	return keys
+}
+
+// Helper functions
+// recordAudit writes an audit entry; failures are logged and never fail the operation
+func (s *Service) recordAudit(ctx context.Context, op audit.Operation, resourceKey string, details map[string]any) {
+	err := s.audit.Write(ctx, audit.AuditEntry{
+		Operation:   op,
+		ResourceKey: resourceKey,
+		Details:     details,
+	})
+	if err != nil {
+		s.logger.WarnCtx(ctx, "failed to record audit entry", zap.Error(err), zap.String("operation", string(op)), zap.String("resource_key", resourceKey))
+	}
+}
+
+// seal encrypts data with the URL key bound to the current server key, returning
+// the ciphertext, salt and server key version. Without a password the key is random
+// enough to be used directly, so key derivation is skipped and the salt only records the mode.
+func (s *Service) seal(data, encKey []byte, password string) ([]byte, []byte, string, error) {
+	keyVersion, serverKey, err := s.keys.CurrentKey()
+	if err != nil {
+		return nil, nil, "", err
+	}
+	encKey = encryption.BindServerKey(encKey, serverKey)
+
+	if password == "" {
+		encryptedData, err := s.encryption.FastEncrypt(data, encKey)
+		if err != nil {
+			return nil, nil, "", err
+		}
+		return encryptedData, encryption.FastSalt(), keyVersion, nil
+	}
+	// Combine encryption key with password for stronger security
+	encryptedData, salt, err := s.encryption.Encrypt(data, password+string(encKey))
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return encryptedData, salt, keyVersion, nil
+}
+
+// errUploadSize fails uploads whose data is not of the size they declared
+var errUploadSize = errors.New("upload data does not match its size")
+
+// sealStream is seal for uploads. Data is encrypted as it is read and never held
+// whole in memory; the stream ciphers are AES-GCM, so data under AES-SIV, or of
+// size 0, is read whole and sealed by seal. A negative size streams data of unknown
+// length, whose encrypted size is then returned as -1. It returns the encrypted
+// data with its size, the salt and the server key version.
+func (s *Service) sealStream(data io.Reader, size int64, encKey []byte, password string) (io.ReadCloser, int64, []byte, string, error) {
+	if size == 0 || s.encryption.Cipher() != encryption.CipherAESGCM {
+		plaintext, err := io.ReadAll(data)
+		if err != nil {
+			return nil, 0, nil, "", err
+		}
+		encryptedData, salt, keyVersion, err := s.seal(plaintext, encKey, password)
+		if err != nil {
+			return nil, 0, nil, "", err
+		}
+		return io.NopCloser(bytes.NewReader(encryptedData)), int64(len(encryptedData)), salt, keyVersion, nil
+	}
+
+	keyVersion, serverKey, err := s.keys.CurrentKey()
+	if err != nil {
+		return nil, 0, nil, "", err
+	}
+	encKey = encryption.BindServerKey(encKey, serverKey)
+
+	// The object size is sent before the data, so data off by a byte fails the upload
+	encryptedSize := int64(-1)
+	if size > 0 {
+		data = &exactReader{r: data, remaining: size}
+		encryptedSize = encryption.StreamEncryptedSize(size)
+	}
+	if password == "" {
+		encryptedData, err := s.encryption.FastEncryptStream(data, encKey)
+		if err != nil {
+			return nil, 0, nil, "", err
+		}
+		return encryptedData, encryptedSize, encryption.FastStreamSalt(), keyVersion, nil
+	}
+	encryptedData, salt, err := s.encryption.EncryptStream(data, password+string(encKey))
+	if err != nil {
+		return nil, 0, nil, "", err
+	}
+	return encryptedData, encryptedSize, salt, keyVersion, nil
+}
+
+// exactReader reads r, failing with errUploadSize when it ends before or goes past remaining bytes
+type exactReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (e *exactReader) Read(p []byte) (int, error) {
+	if e.remaining <= 0 {
+		var extra [1]byte
+		n, err := e.r.Read(extra[:])
+		if n > 0 {
+			return 0, errUploadSize
+		}
+		return 0, err
+	}
+
+	if int64(len(p)) > e.remaining {
+		p = p[:e.remaining]
+	}
+	n, err := e.r.Read(p)
+	e.remaining -= int64(n)
+	if err == io.EOF && e.remaining > 0 {
+		return n, errUploadSize
+	}
+	return n, err
+}
+
+// plaintextDigest hashes and counts the plaintext of an upload as it is encrypted
+type plaintextDigest struct {
+	hash.Hash
+	size int64
+}
+
+func (d *plaintextDigest) Write(p []byte) (int, error) {
+	d.size += int64(len(p))
+	return d.Hash.Write(p)
+}
+
+// open reverses seal and sealStream, picking the mode from the stored salt so resources
+// created before the fast path existed still decrypt through PBKDF2
+func (s *Service) open(encryptedData, salt []byte, keyVersion string, encKey []byte, password string) ([]byte, error) {
+	if keyVersion != "" {
+		serverKey, err := s.keys.GetKey(keyVersion)
+		if err != nil {
+			return nil, err
+		}
+		encKey = encryption.BindServerKey(encKey, serverKey)
+	}
+
+	switch {
+	case encryption.IsFastSalt(salt):
+		return s.encryption.FastDecrypt(encryptedData, encKey)
+	case encryption.IsFastStreamSalt(salt):
+		return readDecrypted(s.encryption.FastDecryptStream(bytes.NewReader(encryptedData), encKey))
+	case encryption.IsStreamSalt(salt):
+		return readDecrypted(s.encryption.DecryptStream(bytes.NewReader(encryptedData), salt, password+string(encKey)))
+	}
+	return s.encryption.Decrypt(encryptedData, salt, password+string(encKey))
+}
+
+// openStream is open for an object read from S3, returning the plaintext with its size.
+// Streamed data is decrypted as it is read, data sealed whole is read and opened whole.
+// encryptedSize is the length of encryptedData, the plaintext is closed by the caller.
+func (s *Service) openStream(encryptedData io.ReadCloser, encryptedSize int64, salt []byte, keyVersion string, encKey []byte, password string) (io.ReadCloser, int64, error) {
+	if !encryption.IsFastStreamSalt(salt) && !encryption.IsStreamSalt(salt) {
+		data, err := io.ReadAll(encryptedData)
+		encryptedData.Close()
+		if err != nil {
+			return nil, 0, err
+		}
+		plaintext, err := s.open(data, salt, keyVersion, encKey, password)
+		if err != nil {
+			return nil, 0, err
+		}
+		return io.NopCloser(bytes.NewReader(plaintext)), int64(len(plaintext)), nil
+	}
+
+	size, err := encryption.StreamPlaintextSize(encryptedSize)
+	if err != nil {
+		encryptedData.Close()
+		return nil, 0, err
+	}
+	if keyVersion != "" {
+		serverKey, err := s.keys.GetKey(keyVersion)
+		if err != nil {
+			encryptedData.Close()
+			return nil, 0, err
+		}
+		encKey = encryption.BindServerKey(encKey, serverKey)
+	}
+
+	var plaintext io.ReadCloser
+	if encryption.IsFastStreamSalt(salt) {
+		plaintext, err = s.encryption.FastDecryptStream(encryptedData, encKey)
+	} else {
+		plaintext, err = s.encryption.DecryptStream(encryptedData, salt, password+string(encKey))
+	}
+	if err != nil {
+		encryptedData.Close()
+		return nil, 0, err
+	}
+	return plaintext, size, nil
+}
+
+// readDecrypted reads a decrypting stream whole
+func readDecrypted(plaintext io.ReadCloser, err error) ([]byte, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer plaintext.Close()
+	return io.ReadAll(plaintext)
+}
+
+// openObject downloads the encrypted object of a resource and decrypts it as it is
+// read, checked against its manifest. Objects without a recorded size are looked up
+// with a HEAD request, encryptedSize returns the size used.
+func (s *Service) openObject(ctx context.Context, resource MediaResource, encKey []byte, password string) (plaintext io.ReadCloser, size, encryptedSize int64, err error) {
+	s3Key := "media/" + resource.ResourceKey
+	if resource.EncryptedSize != nil {
+		encryptedSize = *resource.EncryptedSize
+	} else if encryptedSize, err = s.s3.ObjectSize(ctx, "", s3Key); err != nil {
+		return nil, 0, 0, ErrNotFound
+	}
+	encryptedData, err := s.s3.Download(ctx, "", s3Key)
+	if err != nil {
+		return nil, 0, 0, ErrNotFound
+	}
+	decrypted, size, err := s.openStream(encryptedData, encryptedSize, resource.Salt, resource.KeyVersion, encKey, password)
+	if err != nil {
+		return nil, 0, 0, ErrDecryptionFailed
+	}
+
+	// The first chunk is opened here, so a wrong key or password fails before the view is counted
+	head := bufio.NewReader(decrypted)
+	if _, err := head.Peek(1); err != nil && err != io.EOF {
+		decrypted.Close()
+		return nil, 0, 0, ErrDecryptionFailed
+	}
+	plaintext, err = s.verifyManifest(ctx, resource.ResourceKey, encKey, readCloser{Reader: head, Closer: decrypted})
+	if err != nil {
+		decrypted.Close()
+		return nil, 0, 0, err
+	}
+	return plaintext, size, encryptedSize, nil
+}
+
+// readCloser reads from Reader and closes Closer, e.g. a buffered stream
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// closeHook runs fn once the wrapped stream is closed
+type closeHook struct {
+	io.ReadCloser
+	fn func()
+}
+
+func (c *closeHook) Close() error {
+	err := c.ReadCloser.Close()
+	if c.fn != nil {
+		c.fn()
+		c.fn = nil
+	}
+	return err
+}
+
+// encryptedSizeBackfillBatch bounds how many object sizes one BackfillEncryptedSizes run looks up
+const encryptedSizeBackfillBatch = 1000
+
+// BackfillEncryptedSizes records the S3 object size of active resources created
+// before sizes were stored, using HEAD requests instead of downloads
+func (s *Service) BackfillEncryptedSizes(ctx context.Context) error {
+	keys, err := s.repo.GetResourcesWithoutEncryptedSize(ctx, encryptedSizeBackfillBatch)
+	if err != nil {
+		return fmt.Errorf("failed to list resources without encrypted size: %w", err)
+	}
+
+	updated := 0
+	for _, key := range keys {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		size, err := s.s3.ObjectSize(ctx, "", "media/"+key)
+		if err != nil {
+			// Missing objects are left to the expiry and orphan cleanups
+			s.logger.WarnCtx(ctx, "failed to get object size", zap.Error(err), zap.String("resource_key", key))
+			continue
+		}
+		if err := s.repo.UpdateEncryptedSize(ctx, key, size); err != nil {
+			return fmt.Errorf("failed to record encrypted size: %w", err)
+		}
+		updated++
+	}
+
+	s.logger.InfoCtx(ctx, "encrypted size backfill completed", zap.Int("checked", len(keys)), zap.Int("updated", updated))
+	return nil
+}
+
+// warnDeleteFailed logs a failed object delete. Objects kept by their object lock
+// retention are expected and logged as info; their records are gone, so the orphan
+// cleanup deletes them once the retention has passed.
+func (s *Service) warnDeleteFailed(ctx context.Context, msg, resourceKey string, err error) {
+	if errors.Is(err, s3.ErrObjectLocked) {
+		s.logger.InfoCtx(ctx, "object is locked, kept until its retention passes", zap.String("resource_key", resourceKey), zap.Error(err))
+		return
+	}
+	s.logger.WarnCtx(ctx, msg, zap.String("resource_key", resourceKey), zap.Error(err))
+}
+
+// OrphanCleanup deletes S3 objects under media/ that have no database record, and
+// their manifests, e.g. when the record was lost or removed while the S3 delete failed
+func (s *Service) OrphanCleanup(ctx context.Context) error {
+	s3Keys, listErrs := s.s3.ListObjectsChan(ctx, "", "media/")
+
+	scanned, deleted := 0, 0
+	for s3Key := range s3Keys {
+		scanned++
+		resourceKey := strings.TrimPrefix(s3Key, "media/")
+		if resourceKey == "" {
+			continue
+		}
+
+		_, err := s.repo.GetMediaResourceByKeyAny(ctx, resourceKey)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			// Unknown state, keep the object
+			s.logger.WarnCtx(ctx, "failed to check resource for orphan cleanup", zap.String("resource_key", resourceKey), zap.Error(err))
+			continue
+		}
+
+		if err := s.s3.Delete(ctx, "", s3Key); err != nil {
+			s.warnDeleteFailed(ctx, "failed to delete orphaned object from S3", resourceKey, err)
+			continue
+		}
+		s.deleteManifest(ctx, resourceKey)
+		deleted++
+		s.logger.InfoCtx(ctx, "deleted orphaned object from S3", zap.String("resource_key", resourceKey))
+		s.recordAudit(ctx, audit.OpOrphanCleanup, resourceKey, nil)
+	}
+
+	if err := <-listErrs; err != nil {
+		s.logger.ErrorCtx(ctx, "failed to list S3 objects", zap.Error(err), zap.Int("scanned_count", scanned), zap.Int("deleted_count", deleted))
 		return err
 	}
 
-	s.logger.Info("cleanup completed", zap.Int("deleted_count", len(expiredKeys)))
+	s.logger.InfoCtx(ctx, "orphan cleanup completed", zap.Int("scanned_count", scanned), zap.Int("deleted_count", deleted))
 	return nil
 }
 
-// Helper functions
 func hashPassword(password string) (string, error) {
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
@@ -476,4 +1824,30 @@ var (
 	ErrMissingEncryptionKey = errors.New("encryption key missing from URL")
 	ErrInvalidEncryptionKey = errors.New("invalid encryption key")
 	ErrDecryptionFailed     = errors.New("decryption failed")
+	ErrWeakPassword         = errors.New("password is too weak")
+
+	ErrTooManyAccessCodes      = errors.New("too many access codes")
+	ErrDuplicateAccessCode     = errors.New("duplicate access code")
+	ErrAccessCodesWithPassword = errors.New("access codes cannot be combined with a password")
+	ErrMaxViewsWithAccessCodes = errors.New("max views cannot be combined with access codes")
+	ErrInvalidMaxViews         = errors.New("max views must be between 1 and 100")
+	ErrInvalidCountryCode      = errors.New("invalid country code")
+	ErrPresignUnsupported      = errors.New("resource cannot be downloaded through a presigned URL")
+	ErrAvailableAfterExpiry    = errors.New("resource must become available before it expires")
+	ErrInvalidBlurIntensity    = errors.New("blur intensity must be between 0 and 1")
+	ErrOutsideCleanupWindow    = errors.New("cleanup is outside of the maintenance window")
 )
+
+// WeakPasswordError carries suggestions for a rejected password, it matches ErrWeakPassword
+type WeakPasswordError struct {
+	Score       int
+	Suggestions []string
+}
+
+func (e *WeakPasswordError) Error() string {
+	return ErrWeakPassword.Error()
+}
+
+func (e *WeakPasswordError) Unwrap() error {
+	return ErrWeakPassword
+}
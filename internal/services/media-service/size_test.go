@@ -0,0 +1,92 @@
+package mediaservice
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	mediarepo "lovebin/internal/services/media-service/repository"
+)
+
+func TestUploadMediaRecordsEncryptedSize(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     io.Reader
+		password string
+	}{
+		{"fast", io.MultiReader(strings.NewReader("sized content")), ""},
+		{"fast stream", bytes.NewReader([]byte("sized content")), ""},
+		{"password stream", bytes.NewReader([]byte("sized content")), "mzkqTW7!pLx9"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestService(t, Config{})
+			resourceKey, _ := svc.upload(t, UploadRequest{Data: tt.data, Password: tt.password})
+
+			object, ok := svc.storage.Object("", "media/"+resourceKey)
+			if !ok {
+				t.Fatal("object not stored")
+			}
+			resource, _ := svc.repo.resource(resourceKey)
+			if resource.EncryptedSize == nil || *resource.EncryptedSize != int64(len(object)) {
+				t.Errorf("EncryptedSize = %v, want %d", resource.EncryptedSize, len(object))
+			}
+		})
+	}
+}
+
+func TestGetMediaPreviewRecordsMissingEncryptedSize(t *testing.T) {
+	svc := newTestService(t, Config{})
+	resourceKey, encKey := svc.upload(t, UploadRequest{Data: io.MultiReader(strings.NewReader("content"))})
+	svc.repo.update(resourceKey, func(resource *mediarepo.MediaResourceResult) { resource.EncryptedSize = nil })
+
+	resp, err := svc.GetMediaPreview(context.Background(), &DownloadRequest{ResourceKey: resourceKey, EncKeyBase64: encKey})
+	if err != nil {
+		t.Fatalf("GetMediaPreview: %v", err)
+	}
+	resp.Data.Close()
+	object, _ := svc.storage.Object("", "media/"+resourceKey)
+	if resource, _ := svc.repo.resource(resourceKey); resource.EncryptedSize == nil || *resource.EncryptedSize != int64(len(object)) {
+		t.Errorf("EncryptedSize after preview = %v, want %d", resource.EncryptedSize, len(object))
+	}
+}
+
+func TestBackfillEncryptedSizes(t *testing.T) {
+	svc := newTestService(t, Config{})
+	ctx := context.Background()
+	sized, _ := svc.upload(t, UploadRequest{Data: bytes.NewReader([]byte("sized"))})
+	legacy, _ := svc.upload(t, UploadRequest{Data: bytes.NewReader([]byte("legacy"))})
+	missing, _ := svc.upload(t, UploadRequest{Data: bytes.NewReader([]byte("missing"))})
+	for _, resourceKey := range []string{legacy, missing} {
+		svc.repo.update(resourceKey, func(resource *mediarepo.MediaResourceResult) { resource.EncryptedSize = nil })
+	}
+	if err := svc.storage.Delete(ctx, "", "media/"+missing); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	sizedBefore, _ := svc.repo.resource(sized)
+	downloads := svc.storage.Calls("Download")
+
+	if err := svc.BackfillEncryptedSizes(ctx); err != nil {
+		t.Fatalf("BackfillEncryptedSizes: %v", err)
+	}
+
+	object, _ := svc.storage.Object("", "media/"+legacy)
+	if resource, _ := svc.repo.resource(legacy); resource.EncryptedSize == nil || *resource.EncryptedSize != int64(len(object)) {
+		t.Errorf("legacy EncryptedSize = %v, want %d", resource.EncryptedSize, len(object))
+	}
+	// A missing object is skipped, not fatal
+	if resource, _ := svc.repo.resource(missing); resource.EncryptedSize != nil {
+		t.Errorf("EncryptedSize of a missing object = %d, want none", *resource.EncryptedSize)
+	}
+	if resource, _ := svc.repo.resource(sized); *resource.EncryptedSize != *sizedBefore.EncryptedSize {
+		t.Error("a known size was changed")
+	}
+	if n := svc.storage.Calls("ObjectSize"); n != 2 {
+		t.Errorf("ObjectSize called %d times, want 2", n)
+	}
+	if n := svc.storage.Calls("Download") - downloads; n != 0 {
+		t.Errorf("backfill downloaded %d objects", n)
+	}
+}
@@ -0,0 +1,44 @@
+package mediaservice
+
+import (
+	"context"
+	"time"
+
+	"lovebin/modules/s3"
+)
+
+// MediaPrefix is the S3 key prefix of all media objects
+const MediaPrefix = "media/"
+
+// defaultStorageUsageCacheTTL is used when Config.StorageUsageCacheTTL is not set
+const defaultStorageUsageCacheTTL = 5 * time.Minute
+
+// StorageUsage is the space taken by media objects as of ComputedAt
+type StorageUsage struct {
+	s3.StorageUsage
+	ComputedAt time.Time
+}
+
+// StorageUsage returns the size and number of media objects. Listing the bucket
+// takes a request per 1000 objects, so the result is reused for the cache TTL.
+func (s *Service) StorageUsage(ctx context.Context) (StorageUsage, error) {
+	s.usageMu.Lock()
+	defer s.usageMu.Unlock()
+
+	if s.usage != nil && time.Since(s.usage.ComputedAt) < s.usageTTL {
+		return *s.usage, nil
+	}
+
+	usage, err := s.s3.GetStorageUsage(ctx, "", MediaPrefix)
+	if err != nil {
+		return StorageUsage{}, err
+	}
+	s.usage = &StorageUsage{StorageUsage: usage, ComputedAt: time.Now()}
+	return *s.usage, nil
+}
+
+// StorageByDay returns the bytes of media objects per UTC day (s3.StorageDayLayout)
+// they were last modified in [from, to). It lists the bucket on every call.
+func (s *Service) StorageByDay(ctx context.Context, from, to time.Time) (map[string]int64, error) {
+	return s.s3.GetStorageByDay(ctx, "", MediaPrefix, from, to)
+}
@@ -0,0 +1,61 @@
+package mediaservice
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStorageUsageIsCached(t *testing.T) {
+	svc := newTestService(t, Config{StorageUsageCacheTTL: time.Hour})
+	ctx := context.Background()
+	svc.upload(t, UploadRequest{Data: bytes.NewReader([]byte("first"))})
+
+	first, err := svc.StorageUsage(ctx)
+	if err != nil {
+		t.Fatalf("StorageUsage: %v", err)
+	}
+	if first.TotalObjects != 1 || first.TotalBytes == 0 || first.ComputedAt.IsZero() {
+		t.Errorf("StorageUsage = %+v, want one object", first)
+	}
+
+	// Within the TTL the listing is reused, even after another upload
+	svc.upload(t, UploadRequest{Data: bytes.NewReader([]byte("second"))})
+	second, err := svc.StorageUsage(ctx)
+	if err != nil || second != first {
+		t.Errorf("cached StorageUsage = %+v, %v, want %+v", second, err, first)
+	}
+	if n := svc.storage.Calls("GetStorageUsage"); n != 1 {
+		t.Errorf("GetStorageUsage called %d times, want 1", n)
+	}
+
+	// Once it expires the bucket is listed again
+	svc.usageMu.Lock()
+	svc.usage.ComputedAt = time.Now().Add(-2 * time.Hour)
+	svc.usageMu.Unlock()
+	third, err := svc.StorageUsage(ctx)
+	if err != nil || third.TotalObjects != 2 {
+		t.Errorf("StorageUsage after the TTL = %+v, %v, want two objects", third, err)
+	}
+	if n := svc.storage.Calls("GetStorageUsage"); n != 2 {
+		t.Errorf("GetStorageUsage called %d times, want 2", n)
+	}
+}
+
+func TestStorageUsageErrorIsNotCached(t *testing.T) {
+	svc := newTestService(t, Config{})
+	svc.storage.SetError("GetStorageUsage", errors.New("listing failed"))
+	if _, err := svc.StorageUsage(context.Background()); err == nil {
+		t.Fatal("StorageUsage succeeded with a failed listing")
+	}
+
+	svc.storage.SetError("GetStorageUsage", nil)
+	if _, err := svc.StorageUsage(context.Background()); err != nil {
+		t.Errorf("StorageUsage after the listing recovered: %v", err)
+	}
+	if n := svc.storage.Calls("GetStorageUsage"); n != 2 {
+		t.Errorf("GetStorageUsage called %d times, want 2", n)
+	}
+}
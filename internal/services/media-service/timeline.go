@@ -0,0 +1,118 @@
+package mediaservice
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"lovebin/modules/timeparser"
+)
+
+// maxTimelineEvents bounds the recorded events returned by GetResourceTimeline
+const maxTimelineEvents = 1000
+
+// Event types of GetResourceTimeline taken from the resource record. Recorded events
+// use the audit operation, "access_code_used" or "expiry_notified".
+const (
+	TimelineCreated   = "created"
+	TimelineAvailable = "available"
+	TimelineExpired   = "expired"
+)
+
+// TimelineEvent is one event in the history of a resource
+type TimelineEvent struct {
+	EventType string
+	At        timeparser.UniversalTime
+	Details   map[string]string
+}
+
+// GetResourceTimeline returns the events of a resource in [from, to), oldest first;
+// zero bounds are open. It merges the audit trail, used access codes and expiry
+// notifications with the creation, availability and expiry of the resource record.
+// The audit trail outlives deleted resources, ErrNotFound means no event at all.
+func (s *Service) GetResourceTimeline(ctx context.Context, resourceKey string, from, to time.Time) ([]TimelineEvent, error) {
+	recorded, err := s.repo.GetResourceEvents(ctx, resourceKey, from, to, maxTimelineEvents)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]TimelineEvent, 0, len(recorded)+3)
+	for _, event := range recorded {
+		details := auditDetailsToStrings(event.Details)
+		details["performed_by"] = event.PerformedBy
+		if event.IP != "" {
+			details["ip"] = event.IP
+		}
+		events = append(events, TimelineEvent{
+			EventType: event.EventType,
+			At:        timeparser.NewUniversalTime(event.At),
+			Details:   details,
+		})
+	}
+
+	resource, err := s.repo.GetMediaResourceForExport(ctx, resourceKey)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		if len(events) == 0 {
+			return nil, ErrNotFound
+		}
+	case err != nil:
+		return nil, err
+	default:
+		inRange := func(at time.Time) bool {
+			return (from.IsZero() || !at.Before(from)) && (to.IsZero() || at.Before(to))
+		}
+		now := time.Now()
+		if inRange(resource.CreatedAt) {
+			events = append(events, TimelineEvent{
+				EventType: TimelineCreated,
+				At:        timeparser.NewUniversalTime(resource.CreatedAt),
+				Details:   map[string]string{},
+			})
+		}
+		if at := resource.AvailableAt; at != nil && at.After(resource.CreatedAt) && !at.After(now) && inRange(*at) {
+			events = append(events, TimelineEvent{
+				EventType: TimelineAvailable,
+				At:        timeparser.NewUniversalTime(*at),
+				Details:   map[string]string{},
+			})
+		}
+		if at := resource.ExpiresAt; at != nil && !at.After(now) && inRange(*at) {
+			events = append(events, TimelineEvent{
+				EventType: TimelineExpired,
+				At:        timeparser.NewUniversalTime(*at),
+				Details:   map[string]string{"viewed": strconv.FormatBool(resource.Viewed)},
+			})
+		}
+	}
+
+	// Recorded events are already in order, stable sorting keeps ties as recorded
+	slices.SortStableFunc(events, func(a, b TimelineEvent) int {
+		return a.At.Compare(b.At.Time)
+	})
+	return events, nil
+}
+
+// auditDetailsToStrings flattens the JSON details of an audit entry, values that
+// are not strings keep their JSON encoding
+func auditDetailsToStrings(raw []byte) map[string]string {
+	details := map[string]string{}
+	var values map[string]json.RawMessage
+	if len(raw) == 0 || json.Unmarshal(raw, &values) != nil {
+		return details
+	}
+	for key, value := range values {
+		var str string
+		if json.Unmarshal(value, &str) == nil {
+			details[key] = str
+			continue
+		}
+		details[key] = string(value)
+	}
+	return details
+}
@@ -0,0 +1,155 @@
+package mediaservice
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	mediarepo "lovebin/internal/services/media-service/repository"
+)
+
+func TestAuditDetailsToStrings(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want map[string]string
+	}{
+		{"empty", "", map[string]string{}},
+		{"strings", `{"filename": "a.png", "reason": "expired"}`, map[string]string{"filename": "a.png", "reason": "expired"}},
+		{"other values keep their JSON", `{"size": 12, "dry_run": true, "keys": ["a"]}`,
+			map[string]string{"size": "12", "dry_run": "true", "keys": `["a"]`}},
+		{"null is an empty string", `{"none": null}`, map[string]string{"none": ""}},
+		{"not an object", `["a"]`, map[string]string{}},
+		{"malformed", `{"size":`, map[string]string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := auditDetailsToStrings([]byte(tt.raw)); !maps.Equal(got, tt.want) {
+				t.Errorf("auditDetailsToStrings(%s) = %v, want %v", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetResourceTimeline(t *testing.T) {
+	created := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) time.Time { return created.Add(d) }
+	availableAt, expiresAt := at(time.Hour), at(48*time.Hour)
+
+	svc := newTestService(t, Config{})
+	svc.repo.update("resource", func(r *mediarepo.MediaResourceResult) {
+		r.ResourceKey, r.CreatedAt, r.AvailableAt, r.ExpiresAt, r.Viewed = "resource", created, &availableAt, &expiresAt, true
+	})
+	svc.repo.addEvent("resource", mediarepo.ResourceEventResult{EventType: "download", At: at(2 * time.Hour), PerformedBy: "anonymous", IP: "203.0.113.7",
+		Details: []byte(`{"size": 12}`)})
+	svc.repo.addEvent("resource", mediarepo.ResourceEventResult{EventType: "upload", At: created, PerformedBy: "anonymous"})
+	svc.repo.addEvent("resource", mediarepo.ResourceEventResult{EventType: "access_code_used", At: at(3 * time.Hour), PerformedBy: "anonymous"})
+	svc.repo.addEvent("resource", mediarepo.ResourceEventResult{EventType: "expiry_notified", At: at(47 * time.Hour), PerformedBy: "system"})
+
+	tests := []struct {
+		name     string
+		from, to time.Time
+		want     []string
+	}{
+		{"everything", time.Time{}, time.Time{}, []string{"upload", TimelineCreated, TimelineAvailable, "download", "access_code_used", "expiry_notified", TimelineExpired}},
+		{"from is inclusive", availableAt, time.Time{}, []string{TimelineAvailable, "download", "access_code_used", "expiry_notified", TimelineExpired}},
+		{"to is exclusive", time.Time{}, availableAt, []string{"upload", TimelineCreated}},
+		{"window", at(90 * time.Minute), at(4 * time.Hour), []string{"download", "access_code_used"}},
+		{"after every event", at(72 * time.Hour), time.Time{}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := svc.GetResourceTimeline(context.Background(), "resource", tt.from, tt.to)
+			if err != nil {
+				t.Fatalf("GetResourceTimeline: %v", err)
+			}
+			got := make([]string, 0, len(events))
+			for _, event := range events {
+				got = append(got, event.EventType)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("events = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	events, err := svc.GetResourceTimeline(context.Background(), "resource", time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("GetResourceTimeline: %v", err)
+	}
+	details := map[string]map[string]string{}
+	for _, event := range events {
+		details[event.EventType] = event.Details
+	}
+	if want := map[string]string{"size": "12", "performed_by": "anonymous", "ip": "203.0.113.7"}; !maps.Equal(details["download"], want) {
+		t.Errorf("download details = %v, want %v", details["download"], want)
+	}
+	if want := map[string]string{"performed_by": "system"}; !maps.Equal(details["expiry_notified"], want) {
+		t.Errorf("expiry_notified details = %v, want %v", details["expiry_notified"], want)
+	}
+	if want := map[string]string{"viewed": "true"}; !maps.Equal(details[TimelineExpired], want) {
+		t.Errorf("expired details = %v, want %v", details[TimelineExpired], want)
+	}
+}
+
+func TestGetResourceTimelineFutureEvents(t *testing.T) {
+	now := time.Now().UTC()
+	later := now.Add(time.Hour)
+	created := now.Add(-time.Hour)
+
+	tests := []struct {
+		name   string
+		modify func(r *mediarepo.MediaResourceResult)
+		want   []string
+	}{
+		{"opens and expires later", func(r *mediarepo.MediaResourceResult) { r.AvailableAt, r.ExpiresAt = &later, &later }, []string{TimelineCreated}},
+		{"available on creation", func(r *mediarepo.MediaResourceResult) { r.AvailableAt = &created }, []string{TimelineCreated}},
+		{"never expires", func(r *mediarepo.MediaResourceResult) { r.ExpiresAt = nil }, []string{TimelineCreated}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestService(t, Config{})
+			svc.repo.update("resource", func(r *mediarepo.MediaResourceResult) {
+				r.ResourceKey, r.CreatedAt = "resource", created
+				tt.modify(r)
+			})
+			events, err := svc.GetResourceTimeline(context.Background(), "resource", time.Time{}, time.Time{})
+			if err != nil {
+				t.Fatalf("GetResourceTimeline: %v", err)
+			}
+			got := make([]string, 0, len(events))
+			for _, event := range events {
+				got = append(got, event.EventType)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("events = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetResourceTimelineDeletedResource(t *testing.T) {
+	svc := newTestService(t, Config{})
+	deletedAt := time.Date(2026, 10, 2, 0, 0, 0, 0, time.UTC)
+	svc.repo.addEvent("deleted", mediarepo.ResourceEventResult{EventType: "delete", At: deletedAt, PerformedBy: "admin"})
+
+	// The audit trail outlives the record
+	events, err := svc.GetResourceTimeline(context.Background(), "deleted", time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("GetResourceTimeline: %v", err)
+	}
+	if len(events) != 1 || events[0].EventType != "delete" || !events[0].At.Equal(deletedAt) {
+		t.Errorf("events = %+v, want the recorded delete", events)
+	}
+
+	if _, err := svc.GetResourceTimeline(context.Background(), "unknown", time.Time{}, time.Time{}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetResourceTimeline of an unknown resource = %v, want %v", err, ErrNotFound)
+	}
+	// Outside the range of its events a deleted resource has nothing to show either
+	if _, err := svc.GetResourceTimeline(context.Background(), "deleted", deletedAt.Add(time.Hour), time.Time{}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetResourceTimeline after the last event = %v, want %v", err, ErrNotFound)
+	}
+}
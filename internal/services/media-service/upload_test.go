@@ -0,0 +1,349 @@
+package mediaservice
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	mediarepo "lovebin/internal/services/media-service/repository"
+	"lovebin/modules/crypto"
+	"lovebin/modules/encryption"
+	"lovebin/modules/password"
+	"lovebin/modules/s3"
+	"lovebin/modules/timeparser"
+)
+
+func TestUploadMediaPasswordStrength(t *testing.T) {
+	tests := []struct {
+		name     string
+		minScore int
+		password string
+		wantErr  bool
+	}{
+		{"no password", 2, "", false},
+		{"weak rejected", 2, "1234", true},
+		{"below minimum", 2, "mzkqTWhv", true},
+		{"at minimum", 2, "mzkqTW7v", false},
+		{"strong", 2, "mzkqTW7!pLx9", false},
+		{"minimum 0 accepts any", 0, "1234", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestService(t, Config{MinPasswordScore: tt.minScore})
+			_, err := svc.UploadMedia(context.Background(), UploadRequest{
+				Data:     bytes.NewReader([]byte("content")),
+				Size:     7,
+				Password: tt.password,
+				Filename: "a.txt",
+			})
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("UploadMedia: %v", err)
+				}
+				return
+			}
+
+			if !errors.Is(err, ErrWeakPassword) {
+				t.Fatalf("UploadMedia error = %v, want ErrWeakPassword", err)
+			}
+			var weak *WeakPasswordError
+			if !errors.As(err, &weak) {
+				t.Fatalf("UploadMedia error %T is not a *WeakPasswordError", err)
+			}
+			score, suggestions := password.Score(tt.password)
+			if weak.Score != score || !reflect.DeepEqual(weak.Suggestions, suggestions) {
+				t.Errorf("WeakPasswordError = %d %q, want %d %q", weak.Score, weak.Suggestions, score, suggestions)
+			}
+			if n := svc.storage.Calls("Upload"); n != 0 {
+				t.Errorf("weak password stored %d objects", n)
+			}
+		})
+	}
+}
+
+func TestGetMediaInfoDownloadOnly(t *testing.T) {
+	svc := newTestService(t, Config{})
+
+	for _, downloadOnly := range []bool{false, true} {
+		resourceKey, encKey := svc.upload(t, UploadRequest{
+			Data:         bytes.NewReader([]byte("content")),
+			Filename:     "a.txt",
+			DownloadOnly: downloadOnly,
+		})
+		info, err := svc.GetMediaInfo(context.Background(), resourceKey, encKey)
+		if err != nil {
+			t.Fatalf("GetMediaInfo: %v", err)
+		}
+		if info.DownloadOnly != downloadOnly {
+			t.Errorf("DownloadOnly = %v, want %v", info.DownloadOnly, downloadOnly)
+		}
+	}
+}
+
+func TestUploadMediaSaltMode(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      io.Reader
+		size      int64 // filled in for a bytes.Reader when 0
+		password  string
+		wantMode  func([]byte) bool
+		wantNoKDF bool
+	}{
+		{"no password", bytes.NewReader([]byte("content")), 0, "", encryption.IsFastStreamSalt, true},
+		{"no password read whole", io.MultiReader(strings.NewReader("content")), 0, "", encryption.IsFastSalt, true},
+		{"no password unknown size", io.MultiReader(strings.NewReader("content")), -1, "", encryption.IsFastStreamSalt, true},
+		{"password", bytes.NewReader([]byte("content")), 0, "mzkqTW7!pLx9", encryption.IsStreamSalt, false},
+		{"password read whole", io.MultiReader(strings.NewReader("content")), 0, "mzkqTW7!pLx9", func(salt []byte) bool {
+			return len(salt) > 1 && salt[0] == encryption.SaltModeKDF
+		}, false},
+		{"password unknown size", io.MultiReader(strings.NewReader("content")), -1, "mzkqTW7!pLx9", encryption.IsStreamSalt, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestService(t, Config{})
+			resourceKey, encKey := svc.upload(t, UploadRequest{Data: tt.data, Size: tt.size, Password: tt.password})
+
+			resource, _ := svc.repo.resource(resourceKey)
+			if !tt.wantMode(resource.Salt) {
+				t.Errorf("salt = %x, not of the expected mode", resource.Salt)
+			}
+			// Also known once data of unknown size is stored
+			if object, _ := svc.storage.Object("", "media/"+resourceKey); resource.EncryptedSize == nil || *resource.EncryptedSize != int64(len(object)) {
+				t.Errorf("encrypted size = %v, want the %d bytes stored", resource.EncryptedSize, len(object))
+			}
+			if tt.wantNoKDF && resource.PasswordHash != nil {
+				t.Error("password hash stored without a password")
+			}
+
+			got, err := download(t, svc, resourceKey, encKey, tt.password)
+			if err != nil || string(got) != "content" {
+				t.Fatalf("download = %q, %v, want the content", got, err)
+			}
+		})
+	}
+}
+
+func TestUploadMediaManifestSignature(t *testing.T) {
+	const content = "signed content"
+	sum := sha256.Sum256([]byte(content))
+	contentHash := hex.EncodeToString(sum[:])
+	expiresAt := timeparser.NewUniversalTime(time.Date(2026, 10, 16, 12, 0, 0, 0, time.FixedZone("MSK", 3*3600)))
+
+	tests := []struct {
+		name        string
+		data        io.Reader
+		expiresAt   timeparser.UniversalTime
+		wantExpires string
+	}{
+		{"stream", bytes.NewReader([]byte(content)), expiresAt, "2026-10-16T09:00:00Z"},
+		{"buffered", io.MultiReader(strings.NewReader(content)), expiresAt, "2026-10-16T09:00:00Z"},
+		{"never expires", bytes.NewReader([]byte(content)), timeparser.UniversalTime{}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestService(t, Config{})
+			resp, err := svc.UploadMedia(context.Background(), UploadRequest{Data: tt.data, ExpiresAt: tt.expiresAt})
+			if err != nil {
+				t.Fatalf("UploadMedia: %v", err)
+			}
+			resourceKey, _, _ := strings.Cut(resp.ResourceKey, "#")
+
+			if got := ManifestExpiresAt(tt.expiresAt); got != tt.wantExpires {
+				t.Errorf("ManifestExpiresAt = %q, want %q", got, tt.wantExpires)
+			}
+			if !crypto.VerifyResourceManifest(resp.VerificationPubkey, resp.ManifestSignature, resourceKey, contentHash, tt.wantExpires) {
+				t.Error("manifest signature does not verify against the plaintext hash")
+			}
+			if crypto.VerifyResourceManifest(resp.VerificationPubkey, resp.ManifestSignature, resourceKey, contentHash, "2030-01-01T00:00:00Z") {
+				t.Error("manifest signature verifies with another expiry")
+			}
+
+			resource, _ := svc.repo.resource(resourceKey)
+			if !bytes.Equal(resource.VerificationPubkey, resp.VerificationPubkey) {
+				t.Error("stored verification key differs from the returned one")
+			}
+		})
+	}
+}
+
+func TestDownloadMediaWithoutPasswordKeyErrors(t *testing.T) {
+	svc := newTestService(t, Config{})
+	resourceKey, _ := svc.upload(t, UploadRequest{Data: bytes.NewReader([]byte("content"))})
+
+	tests := []struct {
+		name    string
+		encKey  string
+		wantErr error
+	}{
+		{"missing key", "", ErrMissingEncryptionKey},
+		{"malformed key", "not base64!", ErrInvalidEncryptionKey},
+		{"other key", newURLKey(t), ErrDecryptionFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := download(t, svc, resourceKey, tt.encKey, ""); !errors.Is(err, tt.wantErr) {
+				t.Errorf("download error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDownloadMediaLegacyPBKDF2(t *testing.T) {
+	svc := newTestService(t, Config{})
+	resourceKey, encKey := svc.upload(t, UploadRequest{Data: bytes.NewReader([]byte("placeholder"))})
+
+	// Resources stored before the fast path derived the key from the URL key with PBKDF2
+	// and have no manifest
+	key, err := base64.RawURLEncoding.DecodeString(encKey)
+	if err != nil {
+		t.Fatalf("decode URL key: %v", err)
+	}
+	legacy := encryption.Init(encryption.Config{Algorithm: encryption.AlgorithmPBKDF2, Iterations: 1000}, nil)
+	ciphertext, salt, err := legacy.Encrypt([]byte("legacy content"), string(key))
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if len(salt) != 16 {
+		t.Fatalf("PBKDF2 salt is %d bytes, want 16", len(salt))
+	}
+	if _, err := svc.storage.Upload(context.Background(), "", "media/"+resourceKey, bytes.NewReader(ciphertext), ""); err != nil {
+		t.Fatalf("replace object: %v", err)
+	}
+	if err := s3.DeleteManifest(context.Background(), svc.storage, resourceKey); err != nil {
+		t.Fatalf("delete manifest: %v", err)
+	}
+	svc.repo.update(resourceKey, func(r *mediarepo.MediaResourceResult) {
+		r.Salt = salt
+		r.KeyVersion = ""
+	})
+
+	got, err := download(t, svc, resourceKey, encKey, "")
+	if err != nil || string(got) != "legacy content" {
+		t.Errorf("download = %q, %v, want the legacy content", got, err)
+	}
+}
+
+// failingCreateRepo fails every record insert
+type failingCreateRepo struct {
+	*MockRepository
+}
+
+func (r failingCreateRepo) CreateMediaResource(context.Context, mediarepo.CreateMediaResourceInput) (mediarepo.MediaResourceResult, error) {
+	return mediarepo.MediaResourceResult{}, errors.New("insert failed")
+}
+
+func (r failingCreateRepo) WithTx(pgx.Tx) mediarepo.Repository {
+	return r
+}
+
+// failingCommitPostgres runs WithTx functions and then fails the commit
+type failingCommitPostgres struct {
+	inlinePostgres
+}
+
+func (failingCommitPostgres) WithTx(_ context.Context, fn func(tx pgx.Tx) error) error {
+	if err := fn(nil); err != nil {
+		return err
+	}
+	return errors.New("commit failed")
+}
+
+func TestUploadMediaCleansUpFailedTransaction(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(svc *testService)
+	}{
+		{"upload fails", func(svc *testService) {
+			svc.storage.SetError("UploadStream", errors.New("upload failed"))
+		}},
+		{"manifest upload fails", func(svc *testService) {
+			svc.storage.SetError("Upload", errors.New("upload failed"))
+		}},
+		{"insert fails", func(svc *testService) {
+			svc.Service.repo = failingCreateRepo{svc.repo}
+		}},
+		{"commit fails", func(svc *testService) {
+			svc.Service.postgres = failingCommitPostgres{}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestService(t, Config{})
+			tt.setup(svc)
+
+			_, err := svc.UploadMedia(context.Background(), UploadRequest{Data: bytes.NewReader([]byte("content")), Size: 7})
+			if err == nil {
+				t.Fatal("UploadMedia succeeded")
+			}
+			keys, err := svc.storage.ListObjects(context.Background(), "", "")
+			if err != nil {
+				t.Fatalf("ListObjects: %v", err)
+			}
+			if len(keys) != 0 {
+				t.Errorf("objects left after the failed upload: %q", keys)
+			}
+		})
+	}
+}
+
+// txTrackingPostgres runs WithTx functions inline, reporting whether one is running
+type txTrackingPostgres struct {
+	inlinePostgres
+	inTx *bool
+}
+
+func (p txTrackingPostgres) WithTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	*p.inTx = true
+	defer func() { *p.inTx = false }()
+	return fn(nil)
+}
+
+// txCheckingS3 records the uploads made while a transaction is open
+type txCheckingS3 struct {
+	*s3.MockS3
+	inTx      *bool
+	uploadsTx []string
+}
+
+func (m *txCheckingS3) UploadStream(ctx context.Context, bucket, key string, body io.Reader, size int64, contentType string, progress s3.ProgressFunc) (string, error) {
+	if *m.inTx {
+		m.uploadsTx = append(m.uploadsTx, key)
+	}
+	return m.MockS3.UploadStream(ctx, bucket, key, body, size, contentType, progress)
+}
+
+func (m *txCheckingS3) Upload(ctx context.Context, bucket, key string, body io.Reader, contentType string) (string, error) {
+	if *m.inTx {
+		m.uploadsTx = append(m.uploadsTx, key)
+	}
+	return m.MockS3.Upload(ctx, bucket, key, body, contentType)
+}
+
+func TestUploadMediaStoresOutsideTransaction(t *testing.T) {
+	svc := newTestService(t, Config{})
+	inTx := false
+	storage := &txCheckingS3{MockS3: svc.storage, inTx: &inTx}
+	svc.Service.s3 = storage
+	svc.Service.postgres = txTrackingPostgres{inTx: &inTx}
+
+	resourceKey, encKey := svc.upload(t, UploadRequest{Data: bytes.NewReader([]byte("content"))})
+	if len(storage.uploadsTx) != 0 {
+		t.Errorf("uploaded %q while the transaction was open", storage.uploadsTx)
+	}
+	if _, ok := svc.repo.resource(resourceKey); !ok {
+		t.Fatal("no record of the upload")
+	}
+	if got, err := download(t, svc, resourceKey, encKey, ""); err != nil || string(got) != "content" {
+		t.Errorf("download = %q, %v, want content", got, err)
+	}
+}
@@ -0,0 +1,111 @@
+package mediaservice
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	mediarepo "lovebin/internal/services/media-service/repository"
+	"lovebin/modules/s3"
+)
+
+func TestGetRecentUploads(t *testing.T) {
+	svc := newTestService(t, Config{})
+	ctx := context.Background()
+
+	var mine []string
+	for i := range 3 {
+		resourceKey, _ := svc.upload(t, UploadRequest{Data: bytes.NewReader([]byte("data")), UploadIP: "203.0.113.7"})
+		// Creation times a second apart keep the order stable
+		svc.repo.update(resourceKey, func(r *mediarepo.MediaResourceResult) {
+			r.CreatedAt = time.Now().Add(time.Duration(i) * time.Second)
+		})
+		mine = append(mine, resourceKey)
+	}
+	svc.upload(t, UploadRequest{Data: bytes.NewReader([]byte("data")), UploadIP: "198.51.100.1"})
+	svc.upload(t, UploadRequest{Data: bytes.NewReader([]byte("data"))})
+	viewed, viewedKey := svc.upload(t, UploadRequest{Data: bytes.NewReader([]byte("data")), UploadIP: "203.0.113.7"})
+	if _, err := download(t, svc, viewed, viewedKey, ""); err != nil {
+		t.Fatalf("download: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		ip    string
+		limit int
+		want  []string
+	}{
+		{"newest first", "203.0.113.7", 0, []string{mine[2], mine[1], mine[0]}},
+		{"limit", "203.0.113.7", 2, []string{mine[2], mine[1]}},
+		{"limit above the maximum", "203.0.113.7", MaxRecentUploads + 1, []string{mine[2], mine[1], mine[0]}},
+		{"other ip", "192.0.2.1", 0, nil},
+		{"unknown ip", "", 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uploads, err := svc.GetRecentUploads(ctx, tt.ip, tt.limit)
+			if err != nil {
+				t.Fatalf("GetRecentUploads: %v", err)
+			}
+			var got []string
+			for _, upload := range uploads {
+				got = append(got, upload.ResourceKey)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("GetRecentUploads = %q, want %q", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("GetRecentUploads = %q, want %q", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestDeleteUploadsByIP(t *testing.T) {
+	svc := newTestService(t, Config{})
+	ctx := context.Background()
+
+	mine, _ := svc.upload(t, UploadRequest{Data: bytes.NewReader([]byte("data")), UploadIP: "203.0.113.7"})
+	viewed, viewedKey := svc.upload(t, UploadRequest{Data: bytes.NewReader([]byte("data")), UploadIP: "203.0.113.7"})
+	if _, err := download(t, svc, viewed, viewedKey, ""); err != nil {
+		t.Fatalf("download: %v", err)
+	}
+	other, _ := svc.upload(t, UploadRequest{Data: bytes.NewReader([]byte("data")), UploadIP: "198.51.100.1"})
+
+	if n, err := svc.DeleteUploadsByIP(ctx, ""); err != nil || n != 0 {
+		t.Errorf("DeleteUploadsByIP without an IP = %d, %v, want nothing deleted", n, err)
+	}
+
+	deleted, err := svc.DeleteUploadsByIP(ctx, "203.0.113.7")
+	if err != nil {
+		t.Fatalf("DeleteUploadsByIP: %v", err)
+	}
+	// Viewed uploads are erased as well
+	if deleted != 2 {
+		t.Errorf("deleted %d uploads, want 2", deleted)
+	}
+
+	tests := []struct {
+		resourceKey string
+		wantKept    bool
+	}{
+		{mine, false},
+		{viewed, false},
+		{other, true},
+	}
+	for _, tt := range tests {
+		if _, ok := svc.repo.resource(tt.resourceKey); ok != tt.wantKept {
+			t.Errorf("record %s kept = %v, want %v", tt.resourceKey, ok, tt.wantKept)
+		}
+		if _, ok := svc.storage.Object("", "media/"+tt.resourceKey); ok != tt.wantKept {
+			t.Errorf("object %s kept = %v, want %v", tt.resourceKey, ok, tt.wantKept)
+		}
+		if _, ok := svc.storage.Object("", s3.ManifestKey(tt.resourceKey)); ok != tt.wantKept {
+			t.Errorf("manifest %s kept = %v, want %v", tt.resourceKey, ok, tt.wantKept)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE media_resources
+ADD COLUMN IF NOT EXISTS download_only BOOLEAN DEFAULT FALSE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE media_resources
+DROP COLUMN IF EXISTS download_only;
+-- +goose StatementEnd
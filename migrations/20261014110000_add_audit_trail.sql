@@ -0,0 +1,31 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS audit_trail (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    operation TEXT NOT NULL, -- upload, download, cleanup, ...
+    resource_key TEXT, -- NULL for operations not tied to one resource
+    performed_by TEXT NOT NULL, -- anonymous, admin or system
+    ip TEXT, -- client IP, NULL for background jobs
+    details JSONB,
+    at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_audit_trail_resource_key ON audit_trail(resource_key, at);
+CREATE INDEX IF NOT EXISTS idx_audit_trail_at ON audit_trail(at);
+
+-- Audit rows are append-only
+CREATE OR REPLACE FUNCTION audit_trail_immutable() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_trail is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_trail_no_update_delete
+BEFORE UPDATE OR DELETE ON audit_trail
+FOR EACH ROW EXECUTE FUNCTION audit_trail_immutable();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS audit_trail;
+DROP FUNCTION IF EXISTS audit_trail_immutable();
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE media_resources
+ADD COLUMN IF NOT EXISTS upload_ip TEXT; -- client IP of the uploader, for the personal upload history
+CREATE INDEX IF NOT EXISTS idx_media_resources_upload_ip ON media_resources(upload_ip, created_at DESC) WHERE upload_ip IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_media_resources_upload_ip;
+ALTER TABLE media_resources
+DROP COLUMN IF EXISTS upload_ip;
+-- +goose StatementEnd
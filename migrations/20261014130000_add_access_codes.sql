@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS access_codes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    resource_key VARCHAR(255) NOT NULL REFERENCES media_resources(resource_key) ON DELETE CASCADE,
+    code_hash TEXT NOT NULL, -- see encryption.HashAccessCode
+    used_at TIMESTAMPTZ, -- NULL while the code is still valid
+    UNIQUE (resource_key, code_hash)
+);
+CREATE INDEX IF NOT EXISTS idx_access_codes_unused ON access_codes(resource_key) WHERE used_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS access_codes;
+-- +goose StatementEnd
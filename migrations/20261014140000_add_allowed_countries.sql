@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE media_resources
+ADD COLUMN IF NOT EXISTS allowed_countries TEXT[]; -- ISO country codes allowed to access, NULL or empty allows all
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE media_resources
+DROP COLUMN IF EXISTS allowed_countries;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE media_resources
+ADD COLUMN IF NOT EXISTS key_check BYTEA; -- encryption.KeyCheck of the URL key, NULL for older resources
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE media_resources
+DROP COLUMN IF EXISTS key_check;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE media_resources
+ADD COLUMN IF NOT EXISTS available_at TIMESTAMPTZ; -- resource cannot be opened before this time, NULL means immediately
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE media_resources
+DROP COLUMN IF EXISTS available_at;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE media_resources
+ADD COLUMN IF NOT EXISTS key_version TEXT; -- server key version mixed into the content key, NULL means none
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE media_resources
+DROP COLUMN IF EXISTS key_version;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE media_resources
+ADD COLUMN IF NOT EXISTS blur_intensity DOUBLE PRECISION DEFAULT 0.0; -- 0.0 no blur, 1.0 maximum blur
+
+UPDATE media_resources
+SET blur_intensity = CASE WHEN blur_enabled THEN 1.0 ELSE 0.0 END;
+
+ALTER TABLE media_resources
+DROP COLUMN IF EXISTS blur_enabled;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE media_resources
+ADD COLUMN IF NOT EXISTS blur_enabled BOOLEAN DEFAULT FALSE;
+
+UPDATE media_resources
+SET blur_enabled = COALESCE(blur_intensity, 0.0) > 0.0;
+
+ALTER TABLE media_resources
+DROP COLUMN IF EXISTS blur_intensity;
+-- +goose StatementEnd
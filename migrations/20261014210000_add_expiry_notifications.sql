@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Resources whose upcoming expiry was already announced, so each gets one notification
+CREATE TABLE IF NOT EXISTS expiry_notifications (
+    resource_key VARCHAR(255) PRIMARY KEY REFERENCES media_resources(resource_key) ON DELETE CASCADE,
+    notified_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS expiry_notifications;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE media_resources
+ADD COLUMN IF NOT EXISTS metadata_encrypted BYTEA; -- filename and extension sealed with the URL key, NULL for older resources
+COMMENT ON COLUMN media_resources.filename IS 'Deprecated: NULL when metadata_encrypted is set, kept for resources uploaded before it';
+COMMENT ON COLUMN media_resources.file_extension IS 'Deprecated: NULL when metadata_encrypted is set, kept for resources uploaded before it';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+COMMENT ON COLUMN media_resources.filename IS NULL;
+COMMENT ON COLUMN media_resources.file_extension IS NULL;
+ALTER TABLE media_resources
+DROP COLUMN IF EXISTS metadata_encrypted;
+-- +goose StatementEnd
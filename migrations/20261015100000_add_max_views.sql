@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE media_resources
+ADD COLUMN IF NOT EXISTS max_views INTEGER NOT NULL DEFAULT 1, -- downloads allowed, viewed is set once view_count reaches it
+ADD COLUMN IF NOT EXISTS view_count INTEGER NOT NULL DEFAULT 0;
+-- Every access code opens the resource once
+UPDATE media_resources
+SET max_views = codes.total, view_count = codes.used
+FROM (
+    SELECT resource_key, COUNT(*) AS total, COUNT(used_at) AS used
+    FROM access_codes
+    GROUP BY resource_key
+) AS codes
+WHERE codes.resource_key = media_resources.resource_key;
+UPDATE media_resources
+SET view_count = max_views
+WHERE viewed = TRUE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE media_resources
+DROP COLUMN IF EXISTS view_count,
+DROP COLUMN IF EXISTS max_views;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE media_resources
+ADD COLUMN IF NOT EXISTS signed_url_required BOOLEAN NOT NULL DEFAULT FALSE; -- set by a signed URL issued with signed_only, the resource then only opens with a valid signature
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE media_resources
+DROP COLUMN IF EXISTS signed_url_required;
+-- +goose StatementEnd
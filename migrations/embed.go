@@ -0,0 +1,10 @@
+// Package migrations embeds the goose SQL migrations, so the server can report
+// and apply them without the migrations directory being deployed next to it.
+package migrations
+
+import "embed"
+
+// FS holds the *.sql migration files
+//
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,95 @@
+package audit
+
+import (
+	"context"
+	"time"
+)
+
+// Operation names a write operation recorded in the audit trail
+type Operation string
+
+const (
+	OpUpload        Operation = "upload"
+	OpDownload      Operation = "download"
+	OpReencrypt     Operation = "reencrypt"
+	OpCleanup       Operation = "cleanup"
+	OpOrphanCleanup Operation = "orphan_cleanup"
+	OpDeleteByOwner Operation = "delete_by_owner"
+)
+
+// Actors stored in AuditEntry.PerformedBy
+const (
+	ActorAnonymous = "anonymous"
+	ActorAdmin     = "admin"
+	ActorSystem    = "system"
+)
+
+// contextKey is the type of the context keys read by EntryFromContext
+type contextKey string
+
+const (
+	// ClientIPKey is the context key holding the client IP of the current request
+	ClientIPKey contextKey = "client_ip"
+	// ActorKey is the context key holding the actor of the current request
+	ActorKey contextKey = "audit_actor"
+)
+
+// AuditEntry is one row of the audit trail
+type AuditEntry struct {
+	ID          string         `json:"id"`
+	Operation   Operation      `json:"operation"`
+	ResourceKey string         `json:"resource_key,omitempty"`
+	PerformedBy string         `json:"performed_by"`
+	IP          string         `json:"ip,omitempty"`
+	Details     map[string]any `json:"details,omitempty"`
+	At          time.Time      `json:"at"`
+}
+
+// AuditWriter records audit entries
+type AuditWriter interface {
+	Write(ctx context.Context, entry AuditEntry) error
+}
+
+// AuditReader lists recorded audit entries
+type AuditReader interface {
+	List(ctx context.Context, filter Filter) ([]AuditEntry, error)
+}
+
+// Filter selects audit entries; zero fields are not applied
+type Filter struct {
+	ResourceKey string
+	From        time.Time
+	To          time.Time
+	Limit       int
+	Offset      int
+}
+
+// fillFromContext sets At, IP and PerformedBy from ctx where they are empty.
+// Requests without a client IP come from background jobs and are attributed to the system.
+func fillFromContext(ctx context.Context, entry AuditEntry) AuditEntry {
+	if entry.At.IsZero() {
+		entry.At = time.Now().UTC()
+	}
+	if entry.IP == "" {
+		if ip, ok := ctx.Value(ClientIPKey).(string); ok {
+			entry.IP = ip
+		}
+	}
+	if entry.PerformedBy == "" {
+		if actor, ok := ctx.Value(ActorKey).(string); ok && actor != "" {
+			entry.PerformedBy = actor
+		} else if entry.IP != "" {
+			entry.PerformedBy = ActorAnonymous
+		} else {
+			entry.PerformedBy = ActorSystem
+		}
+	}
+	return entry
+}
+
+// NopWriter discards all entries
+type NopWriter struct{}
+
+func (NopWriter) Write(context.Context, AuditEntry) error {
+	return nil
+}
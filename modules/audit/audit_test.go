@@ -0,0 +1,46 @@
+package audit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFillFromContext(t *testing.T) {
+	at := time.Date(2026, 5, 1, 10, 0, 0, 0, time.UTC)
+	request := context.WithValue(context.Background(), ClientIPKey, "203.0.113.7")
+	admin := context.WithValue(request, ActorKey, ActorAdmin)
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		entry   AuditEntry
+		wantIP  string
+		wantBy  string
+		keepsAt bool
+	}{
+		{"background job", context.Background(), AuditEntry{}, "", ActorSystem, false},
+		{"anonymous request", request, AuditEntry{}, "203.0.113.7", ActorAnonymous, false},
+		{"admin request", admin, AuditEntry{}, "203.0.113.7", ActorAdmin, false},
+		{"empty actor", context.WithValue(request, ActorKey, ""), AuditEntry{}, "203.0.113.7", ActorAnonymous, false},
+		{"explicit fields win", admin, AuditEntry{IP: "198.51.100.1", PerformedBy: "owner", At: at}, "198.51.100.1", "owner", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := time.Now().UTC()
+			got := fillFromContext(tt.ctx, tt.entry)
+			if got.IP != tt.wantIP || got.PerformedBy != tt.wantBy {
+				t.Errorf("IP, PerformedBy = %q, %q, want %q, %q", got.IP, got.PerformedBy, tt.wantIP, tt.wantBy)
+			}
+			if tt.keepsAt {
+				if !got.At.Equal(at) {
+					t.Errorf("At = %v, want %v", got.At, at)
+				}
+				return
+			}
+			if got.At.Before(before) || got.At.Location() != time.UTC {
+				t.Errorf("At = %v, want the current UTC time", got.At)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"lovebin/modules/logger"
+)
+
+const (
+	defaultBufferSize = 1024
+	defaultListLimit  = 50
+	maxListLimit      = 500
+	insertTimeout     = 5 * time.Second
+)
+
+// PostgresAuditWriter inserts audit entries into the audit_trail table from a background
+// goroutine, so request handling never waits on the audit insert
+type PostgresAuditWriter struct {
+	pool   *pgxpool.Pool
+	logger logger.Logger
+	queue  chan AuditEntry
+	done   chan struct{}
+
+	mu     sync.RWMutex // guards closed against concurrent Write and Close
+	closed bool
+}
+
+var (
+	_ AuditWriter = (*PostgresAuditWriter)(nil)
+	_ AuditReader = (*PostgresAuditWriter)(nil)
+)
+
+// NewPostgresAuditWriter starts a writer with a queue of bufferSize entries
+func NewPostgresAuditWriter(pool *pgxpool.Pool, log logger.Logger, bufferSize int) *PostgresAuditWriter {
+	if bufferSize <= 0 {
+		bufferSize = defaultBufferSize
+	}
+	w := &PostgresAuditWriter{
+		pool:   pool,
+		logger: log.Child("audit"),
+		queue:  make(chan AuditEntry, bufferSize),
+		done:   make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// Write enqueues entry without blocking. Request data is read from ctx here,
+// the request context itself is not kept.
+func (w *PostgresAuditWriter) Write(ctx context.Context, entry AuditEntry) error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return ErrClosed
+	}
+
+	select {
+	case w.queue <- fillFromContext(ctx, entry):
+		return nil
+	default:
+		return ErrBufferFull
+	}
+}
+
+// Close stops accepting entries and waits until the queue is written or ctx is done
+func (w *PostgresAuditWriter) Close(ctx context.Context) error {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+	}
+	w.mu.Unlock()
+
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *PostgresAuditWriter) run() {
+	defer close(w.done)
+	for entry := range w.queue {
+		if err := w.insert(entry); err != nil {
+			w.logger.Error("failed to write audit entry", zap.Error(err),
+				zap.String("operation", string(entry.Operation)), zap.String("resource_key", entry.ResourceKey))
+		}
+	}
+}
+
+func (w *PostgresAuditWriter) insert(entry AuditEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), insertTimeout)
+	defer cancel()
+
+	var details []byte
+	if len(entry.Details) > 0 {
+		var err error
+		if details, err = json.Marshal(entry.Details); err != nil {
+			return fmt.Errorf("failed to encode details: %w", err)
+		}
+	}
+
+	_, err := w.pool.Exec(ctx,
+		`INSERT INTO audit_trail (operation, resource_key, performed_by, ip, details, at)
+		VALUES ($1, NULLIF($2, ''), $3, NULLIF($4, ''), $5, $6)`,
+		string(entry.Operation), entry.ResourceKey, entry.PerformedBy, entry.IP, details, entry.At,
+	)
+	return err
+}
+
+// List returns entries matching filter, newest first
+func (w *PostgresAuditWriter) List(ctx context.Context, filter Filter) ([]AuditEntry, error) {
+	var (
+		conditions []string
+		args       []any
+	)
+	if filter.ResourceKey != "" {
+		args = append(args, filter.ResourceKey)
+		conditions = append(conditions, fmt.Sprintf("resource_key = $%d", len(args)))
+	}
+	if !filter.From.IsZero() {
+		args = append(args, filter.From)
+		conditions = append(conditions, fmt.Sprintf("at >= $%d", len(args)))
+	}
+	if !filter.To.IsZero() {
+		args = append(args, filter.To)
+		conditions = append(conditions, fmt.Sprintf("at < $%d", len(args)))
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	limit = min(limit, maxListLimit)
+	args = append(args, limit, max(filter.Offset, 0))
+
+	query := `SELECT id::text, operation, COALESCE(resource_key, ''), performed_by, COALESCE(ip, ''), details, at FROM audit_trail`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += fmt.Sprintf(" ORDER BY at DESC, id LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := w.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []AuditEntry
+	for rows.Next() {
+		var (
+			entry     AuditEntry
+			operation string
+			details   []byte
+		)
+		if err := rows.Scan(&entry.ID, &operation, &entry.ResourceKey, &entry.PerformedBy, &entry.IP, &details, &entry.At); err != nil {
+			return nil, err
+		}
+		entry.Operation = Operation(operation)
+		if len(details) > 0 {
+			if err := json.Unmarshal(details, &entry.Details); err != nil {
+				return nil, fmt.Errorf("failed to decode details: %w", err)
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// Errors returned by Write; the entry is dropped in both cases
+var (
+	ErrBufferFull = errors.New("audit buffer is full")
+	ErrClosed     = errors.New("audit writer is closed")
+)
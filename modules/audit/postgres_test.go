@@ -0,0 +1,156 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"lovebin/migrations"
+	"lovebin/modules/logger"
+	"lovebin/modules/postgres"
+)
+
+func newTestLogger(t *testing.T) logger.Logger {
+	t.Helper()
+	log, err := logger.Init(logger.Config{Level: "fatal"})
+	if err != nil {
+		t.Fatalf("logger.Init: %v", err)
+	}
+	return log
+}
+
+func TestPostgresAuditWriterQueue(t *testing.T) {
+	// Without the background goroutine the queue only fills up
+	w := &PostgresAuditWriter{queue: make(chan AuditEntry, 2), done: make(chan struct{})}
+	ctx := context.WithValue(context.Background(), ClientIPKey, "203.0.113.7")
+
+	for range 2 {
+		if err := w.Write(ctx, AuditEntry{Operation: OpUpload}); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := w.Write(ctx, AuditEntry{Operation: OpUpload}); !errors.Is(err, ErrBufferFull) {
+		t.Errorf("Write to a full queue = %v, want ErrBufferFull", err)
+	}
+
+	// Request data is read when the entry is queued
+	if entry := <-w.queue; entry.IP != "203.0.113.7" || entry.PerformedBy != ActorAnonymous {
+		t.Errorf("queued entry = %+v, want the client IP filled in", entry)
+	}
+}
+
+func TestPostgresAuditWriterClose(t *testing.T) {
+	w := &PostgresAuditWriter{queue: make(chan AuditEntry, 1), done: make(chan struct{})}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	// Nothing drains the queue, Close gives up at the deadline
+	if err := w.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Close = %v, want the context deadline", err)
+	}
+	if err := w.Write(context.Background(), AuditEntry{Operation: OpUpload}); !errors.Is(err, ErrClosed) {
+		t.Errorf("Write after Close = %v, want ErrClosed", err)
+	}
+	// A second Close does not close the queue twice
+	close(w.done)
+	if err := w.Close(context.Background()); err != nil {
+		t.Errorf("second Close = %v", err)
+	}
+}
+
+// newTestPool returns a pool on the database at TEST_DATABASE_URL with all migrations
+// applied and an empty audit trail, skipping the test without one
+func newTestPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		t.Fatalf("pgxpool.New: %v", err)
+	}
+	t.Cleanup(pool.Close)
+	if _, err := postgres.NewMigrator(pool, migrations.FS).Up(ctx); err != nil {
+		t.Fatalf("migrations: %v", err)
+	}
+	// The trail is append-only, only TRUNCATE clears it
+	if _, err := pool.Exec(ctx, `TRUNCATE audit_trail`); err != nil {
+		t.Fatalf("truncate audit_trail: %v", err)
+	}
+	return pool
+}
+
+func TestPostgresAuditWriter(t *testing.T) {
+	pool := newTestPool(t)
+	w := NewPostgresAuditWriter(pool, newTestLogger(t), 0)
+	ctx := context.Background()
+
+	base := time.Now().UTC().Truncate(time.Second).Add(-time.Hour)
+	entries := []AuditEntry{
+		{Operation: OpUpload, ResourceKey: "a", At: base, Details: map[string]any{"size": float64(7)}},
+		{Operation: OpDownload, ResourceKey: "a", At: base.Add(time.Minute), IP: "203.0.113.7"},
+		{Operation: OpUpload, ResourceKey: "b", At: base.Add(2 * time.Minute)},
+		{Operation: OpCleanup, At: base.Add(3 * time.Minute)},
+	}
+	for _, entry := range entries {
+		if err := w.Write(ctx, entry); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := w.Close(ctx); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []Operation
+	}{
+		{"all, newest first", Filter{}, []Operation{OpCleanup, OpUpload, OpDownload, OpUpload}},
+		{"resource", Filter{ResourceKey: "a"}, []Operation{OpDownload, OpUpload}},
+		{"from", Filter{From: base.Add(2 * time.Minute)}, []Operation{OpCleanup, OpUpload}},
+		{"to is exclusive", Filter{To: base.Add(time.Minute)}, []Operation{OpUpload}},
+		{"limit and offset", Filter{Limit: 2, Offset: 1}, []Operation{OpUpload, OpDownload}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := w.List(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("List: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("List returned %d entries, want %d", len(got), len(tt.want))
+			}
+			for i, entry := range got {
+				if entry.Operation != tt.want[i] {
+					t.Errorf("entry %d operation = %s, want %s", i, entry.Operation, tt.want[i])
+				}
+			}
+		})
+	}
+
+	got, err := w.List(ctx, Filter{ResourceKey: "a"})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	download, upload := got[0], got[1]
+	if download.IP != "203.0.113.7" || download.PerformedBy != ActorAnonymous || download.ID == "" {
+		t.Errorf("download entry = %+v", download)
+	}
+	if upload.PerformedBy != ActorSystem || upload.Details["size"] != float64(7) || !upload.At.Equal(base) {
+		t.Errorf("upload entry = %+v", upload)
+	}
+
+	if _, err := pool.Exec(ctx, `UPDATE audit_trail SET performed_by = 'someone'`); err == nil {
+		t.Error("UPDATE of the audit trail succeeded")
+	}
+	if _, err := pool.Exec(ctx, `DELETE FROM audit_trail`); err == nil {
+		t.Error("DELETE from the audit trail succeeded")
+	}
+}
@@ -0,0 +1,42 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	BackendMemory    = "memory"
+	BackendMemcached = "memcached"
+)
+
+// Cache interface for dependency injection
+type Cache[K comparable, V any] interface {
+	Get(ctx context.Context, key K) (V, bool, error) // returns value and whether it was found
+	Set(ctx context.Context, key K, value V, ttl time.Duration) error
+	Delete(ctx context.Context, key K) error
+}
+
+// Config holds cache configuration
+type Config struct {
+	Backend       string // "memory" (default) or "memcached"
+	MemcachedAddr string // memcached server address, e.g. "localhost:11211"
+	Size          int    // max entries for the in-process LRU
+	Prefix        string // key prefix, keeps different caches apart in a shared memcached
+}
+
+// New creates a cache for the configured backend
+func New[K comparable, V any](cfg Config) (Cache[K, V], error) {
+	switch cfg.Backend {
+	case "", BackendMemory:
+		return NewLRU[K, V](cfg.Size), nil
+	case BackendMemcached:
+		if cfg.MemcachedAddr == "" {
+			return nil, fmt.Errorf("memcached address is required for %q cache backend", BackendMemcached)
+		}
+		return NewMemcache[K, V](cfg.MemcachedAddr, cfg.Prefix), nil
+	default:
+		return nil, fmt.Errorf("unknown cache backend: %s", cfg.Backend)
+	}
+}
@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLRUSetGetDelete(t *testing.T) {
+	c := NewLRU[string, int](0)
+	ctx := context.Background()
+
+	if _, ok, _ := c.Get(ctx, "a"); ok {
+		t.Fatal("empty cache returned a value")
+	}
+	_ = c.Set(ctx, "a", 1, 0)
+	if got, ok, _ := c.Get(ctx, "a"); !ok || got != 1 {
+		t.Fatalf("Get(a) = %d, %v, want 1, true", got, ok)
+	}
+	_ = c.Set(ctx, "a", 2, 0)
+	if got, _, _ := c.Get(ctx, "a"); got != 2 {
+		t.Fatalf("Get(a) after overwrite = %d, want 2", got)
+	}
+	_ = c.Delete(ctx, "a")
+	if _, ok, _ := c.Get(ctx, "a"); ok {
+		t.Fatal("value still present after Delete")
+	}
+}
+
+func TestLRUExpiry(t *testing.T) {
+	c := NewLRU[string, int](10)
+	ctx := context.Background()
+
+	_ = c.Set(ctx, "short", 1, 10*time.Millisecond)
+	_ = c.Set(ctx, "forever", 2, 0)
+	time.Sleep(20 * time.Millisecond)
+
+	if _, ok, _ := c.Get(ctx, "short"); ok {
+		t.Error("expired value returned")
+	}
+	if _, ok, _ := c.Get(ctx, "forever"); !ok {
+		t.Error("value without TTL expired")
+	}
+}
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewLRU[string, int](2)
+	ctx := context.Background()
+
+	_ = c.Set(ctx, "a", 1, 0)
+	_ = c.Set(ctx, "b", 2, 0)
+	_, _, _ = c.Get(ctx, "a") // b is now the least recently used
+	_ = c.Set(ctx, "c", 3, 0)
+
+	if _, ok, _ := c.Get(ctx, "b"); ok {
+		t.Error("b should have been evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok, _ := c.Get(ctx, key); !ok {
+			t.Errorf("%s should still be cached", key)
+		}
+	}
+}
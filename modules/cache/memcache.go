@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+type memcacheImpl[K comparable, V any] struct {
+	client *memcache.Client
+	prefix string
+}
+
+// NewMemcache creates a memcached-backed cache, values are stored as JSON
+func NewMemcache[K comparable, V any](addr, prefix string) Cache[K, V] {
+	return &memcacheImpl[K, V]{
+		client: memcache.New(addr),
+		prefix: prefix,
+	}
+}
+
+func (c *memcacheImpl[K, V]) Get(_ context.Context, key K) (V, bool, error) {
+	var value V
+
+	item, err := c.client.Get(c.key(key))
+	if errors.Is(err, memcache.ErrCacheMiss) {
+		return value, false, nil
+	}
+	if err != nil {
+		return value, false, err
+	}
+
+	if err := json.Unmarshal(item.Value, &value); err != nil {
+		return value, false, fmt.Errorf("failed to decode cached value: %w", err)
+	}
+
+	return value, true, nil
+}
+
+func (c *memcacheImpl[K, V]) Set(_ context.Context, key K, value V, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode cache value: %w", err)
+	}
+
+	return c.client.Set(&memcache.Item{
+		Key:        c.key(key),
+		Value:      data,
+		Expiration: expirationSeconds(ttl),
+	})
+}
+
+func (c *memcacheImpl[K, V]) Delete(_ context.Context, key K) error {
+	err := c.client.Delete(c.key(key))
+	if errors.Is(err, memcache.ErrCacheMiss) {
+		return nil
+	}
+	return err
+}
+
+func (c *memcacheImpl[K, V]) key(key K) string {
+	return c.prefix + fmt.Sprint(key)
+}
+
+// expirationSeconds converts ttl to memcached expiration (0 means never expires)
+func expirationSeconds(ttl time.Duration) int32 {
+	if ttl <= 0 {
+		return 0
+	}
+	seconds := math.Ceil(ttl.Seconds())
+	// memcached treats values above 30 days as absolute unix timestamps
+	if seconds > 30*24*60*60 {
+		return int32(time.Now().Add(ttl).Unix())
+	}
+	return int32(seconds)
+}
@@ -0,0 +1,140 @@
+package cache
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type cachedValue struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// newTestMemcache returns a cache on the memcached at MEMCACHED_ADDR, skipping the test without one
+func newTestMemcache(t *testing.T) Cache[string, cachedValue] {
+	t.Helper()
+	addr := os.Getenv("MEMCACHED_ADDR")
+	if addr == "" {
+		t.Skip("MEMCACHED_ADDR is not set")
+	}
+	// A prefix per test run keeps runs against a shared server apart
+	return NewMemcache[string, cachedValue](addr, "test:"+strconv.FormatInt(time.Now().UnixNano(), 36)+":")
+}
+
+func TestMemcacheSetGetDelete(t *testing.T) {
+	c := newTestMemcache(t)
+	ctx := context.Background()
+
+	if _, ok, err := c.Get(ctx, "missing"); err != nil || ok {
+		t.Fatalf("Get(missing) = ok %v, err %v, want a miss", ok, err)
+	}
+
+	want := cachedValue{Name: "a", Count: 3}
+	if err := c.Set(ctx, "key", want, time.Minute); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	got, ok, err := c.Get(ctx, "key")
+	if err != nil || !ok || got != want {
+		t.Fatalf("Get = %+v, %v, %v, want %+v", got, ok, err, want)
+	}
+
+	if err := c.Delete(ctx, "key"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, ok, err := c.Get(ctx, "key"); err != nil || ok {
+		t.Fatalf("Get after Delete = ok %v, err %v, want a miss", ok, err)
+	}
+	// Deleting a missing key is not an error
+	if err := c.Delete(ctx, "key"); err != nil {
+		t.Fatalf("Delete of a missing key: %v", err)
+	}
+}
+
+func TestMemcacheTTLExpiry(t *testing.T) {
+	c := newTestMemcache(t)
+	ctx := context.Background()
+
+	if err := c.Set(ctx, "short", cachedValue{Name: "b"}, time.Second); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if _, ok, _ := c.Get(ctx, "short"); !ok {
+		t.Fatal("value missing right after Set")
+	}
+
+	// memcached expires with a one second resolution
+	time.Sleep(2100 * time.Millisecond)
+	if _, ok, err := c.Get(ctx, "short"); err != nil || ok {
+		t.Fatalf("Get after TTL = ok %v, err %v, want a miss", ok, err)
+	}
+}
+
+func TestMemcacheUnavailable(t *testing.T) {
+	// Nothing listens on port 1, callers see errors and fall through to the database
+	c := NewMemcache[string, cachedValue]("127.0.0.1:1", "")
+	ctx := context.Background()
+
+	if _, ok, err := c.Get(ctx, "key"); err == nil || ok {
+		t.Errorf("Get = ok %v, err %v, want an error", ok, err)
+	}
+	if err := c.Set(ctx, "key", cachedValue{}, time.Minute); err == nil {
+		t.Error("Set succeeded without a server")
+	}
+	if err := c.Delete(ctx, "key"); err == nil {
+		t.Error("Delete succeeded without a server")
+	}
+}
+
+func TestExpirationSeconds(t *testing.T) {
+	tests := []struct {
+		name string
+		ttl  time.Duration
+		want int32
+	}{
+		{"zero never expires", 0, 0},
+		{"negative never expires", -time.Second, 0},
+		{"rounds up", 1500 * time.Millisecond, 2},
+		{"below a second", time.Millisecond, 1},
+		{"one hour", time.Hour, 3600},
+		{"exactly 30 days", 30 * 24 * time.Hour, 30 * 24 * 60 * 60},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := expirationSeconds(tt.ttl); got != tt.want {
+				t.Errorf("expirationSeconds(%v) = %d, want %d", tt.ttl, got, tt.want)
+			}
+		})
+	}
+
+	// Beyond 30 days memcached expects an absolute unix timestamp
+	ttl := 31 * 24 * time.Hour
+	got := int64(expirationSeconds(ttl))
+	want := time.Now().Add(ttl).Unix()
+	if got < want-5 || got > want+5 {
+		t.Errorf("expirationSeconds(%v) = %d, want a timestamp near %d", ttl, got, want)
+	}
+}
+
+func TestNewBackends(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{"default is memory", Config{}, false},
+		{"memory", Config{Backend: BackendMemory}, false},
+		{"memcached", Config{Backend: BackendMemcached, MemcachedAddr: "localhost:11211"}, false},
+		{"memcached without address", Config{Backend: BackendMemcached}, true},
+		{"unknown", Config{Backend: "redis"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New[string, int](tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("New(%+v) error = %v, wantErr %v", tt.cfg, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+)
+
+// manifestDigest computes SHA-256(resourceKey + contentHash + expiresAt)
+func manifestDigest(resourceKey, contentHash, expiresAt string) []byte {
+	sum := sha256.Sum256([]byte(resourceKey + contentHash + expiresAt))
+	return sum[:]
+}
+
+// SignResourceManifest signs a resource manifest with a new ECDSA P-256 key that
+// is thrown away afterwards, so nothing signed later can reuse it. It returns the
+// PKIX (DER) public key and the ASN.1 (DER) signature.
+func SignResourceManifest(resourceKey, contentHash, expiresAt string) (pubkey, signature []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	signature, err = ecdsa.SignASN1(rand.Reader, key, manifestDigest(resourceKey, contentHash, expiresAt))
+	if err != nil {
+		return nil, nil, err
+	}
+	pubkey, err = x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pubkey, signature, nil
+}
+
+// VerifyResourceManifest checks a signature made by SignResourceManifest. pubkeyBytes
+// is the PKIX (DER) P-256 public key, signature the ASN.1 (DER) ECDSA signature.
+// Malformed keys and signatures fail verification.
+func VerifyResourceManifest(pubkeyBytes, signature []byte, resourceKey, contentHash, expiresAt string) bool {
+	parsed, err := x509.ParsePKIXPublicKey(pubkeyBytes)
+	if err != nil {
+		return false
+	}
+	pub, ok := parsed.(*ecdsa.PublicKey)
+	if !ok || pub.Curve != elliptic.P256() {
+		return false
+	}
+	return ecdsa.VerifyASN1(pub, manifestDigest(resourceKey, contentHash, expiresAt), signature)
+}
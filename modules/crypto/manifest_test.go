@@ -0,0 +1,93 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"testing"
+)
+
+const (
+	testResourceKey = "HevCXxMTpVQ6gHeSSy0wxg=="
+	testContentHash = "5ff4b39d8283734fb63a2e90a9390bed6811c3e0a1e7e83b115589cd5ea99a6b"
+	testExpiresAt   = "2026-10-16T12:00:00Z"
+)
+
+func TestVerifyResourceManifest(t *testing.T) {
+	pubkey, signature, err := SignResourceManifest(testResourceKey, testContentHash, testExpiresAt)
+	if err != nil {
+		t.Fatalf("SignResourceManifest: %v", err)
+	}
+
+	flipped := bytes.Clone(signature)
+	flipped[len(flipped)-1] ^= 1
+	otherPubkey, _, err := SignResourceManifest(testResourceKey, testContentHash, testExpiresAt)
+	if err != nil {
+		t.Fatalf("SignResourceManifest: %v", err)
+	}
+	p384, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	p384Pubkey, _ := x509.MarshalPKIXPublicKey(&p384.PublicKey)
+	edPublic, _, _ := ed25519.GenerateKey(rand.Reader)
+	edPubkey, _ := x509.MarshalPKIXPublicKey(edPublic)
+
+	tests := []struct {
+		name                              string
+		pubkey, signature                 []byte
+		resourceKey, contentHash, expires string
+		want                              bool
+	}{
+		{"valid", pubkey, signature, testResourceKey, testContentHash, testExpiresAt, true},
+		{"other resource", pubkey, signature, "other", testContentHash, testExpiresAt, false},
+		{"changed hash", pubkey, signature, testResourceKey, "0" + testContentHash[1:], testExpiresAt, false},
+		{"changed expiry", pubkey, signature, testResourceKey, testContentHash, "2026-10-17T12:00:00Z", false},
+		{"no expiry", pubkey, signature, testResourceKey, testContentHash, "", false},
+		{"changed signature", pubkey, flipped, testResourceKey, testContentHash, testExpiresAt, false},
+		{"truncated signature", pubkey, signature[:len(signature)-2], testResourceKey, testContentHash, testExpiresAt, false},
+		{"empty signature", pubkey, nil, testResourceKey, testContentHash, testExpiresAt, false},
+		{"other key", otherPubkey, signature, testResourceKey, testContentHash, testExpiresAt, false},
+		{"truncated key", pubkey[:len(pubkey)-2], signature, testResourceKey, testContentHash, testExpiresAt, false},
+		{"empty key", nil, signature, testResourceKey, testContentHash, testExpiresAt, false},
+		{"P-384 key", p384Pubkey, signature, testResourceKey, testContentHash, testExpiresAt, false},
+		{"Ed25519 key", edPubkey, signature, testResourceKey, testContentHash, testExpiresAt, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifyResourceManifest(tt.pubkey, tt.signature, tt.resourceKey, tt.contentHash, tt.expires); got != tt.want {
+				t.Errorf("VerifyResourceManifest = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignResourceManifestUsesOneTimeKeys(t *testing.T) {
+	first, _, err := SignResourceManifest(testResourceKey, testContentHash, "")
+	if err != nil {
+		t.Fatalf("SignResourceManifest: %v", err)
+	}
+	second, signature, err := SignResourceManifest(testResourceKey, testContentHash, "")
+	if err != nil {
+		t.Fatalf("SignResourceManifest: %v", err)
+	}
+	if bytes.Equal(first, second) {
+		t.Error("two manifests were signed with the same key")
+	}
+	if !VerifyResourceManifest(second, signature, testResourceKey, testContentHash, "") {
+		t.Error("manifest without expiry does not verify")
+	}
+}
+
+func BenchmarkVerifyResourceManifest(b *testing.B) {
+	pubkey, signature, err := SignResourceManifest(testResourceKey, testContentHash, testExpiresAt)
+	if err != nil {
+		b.Fatalf("SignResourceManifest: %v", err)
+	}
+	b.ResetTimer()
+	for range b.N {
+		if !VerifyResourceManifest(pubkey, signature, testResourceKey, testContentHash, testExpiresAt) {
+			b.Fatal("signature does not verify")
+		}
+	}
+}
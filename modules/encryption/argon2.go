@@ -0,0 +1,45 @@
+package encryption
+
+import (
+	"fmt"
+	"math"
+)
+
+// Argon2idParams are the cost parameters of Argon2id. The defaults are the OWASP
+// recommendation of 19 MiB, two passes and one thread; more memory is preferred
+// over more passes where the machine has it.
+type Argon2idParams struct {
+	Time    int // passes over the memory (default 2)
+	Memory  int // memory in KiB (default 19456)
+	Threads int // parallelism (default 1)
+}
+
+// DefaultArgon2idParams are the parameters used where Config.Argon2id leaves fields unset
+var DefaultArgon2idParams = Argon2idParams{Time: 2, Memory: 19 << 10, Threads: 1}
+
+// withDefaults fills unset fields with DefaultArgon2idParams
+func (p Argon2idParams) withDefaults() Argon2idParams {
+	if p.Time == 0 {
+		p.Time = DefaultArgon2idParams.Time
+	}
+	if p.Memory == 0 {
+		p.Memory = DefaultArgon2idParams.Memory
+	}
+	if p.Threads == 0 {
+		p.Threads = DefaultArgon2idParams.Threads
+	}
+	return p
+}
+
+// Validate reports parameters Argon2id rejects or the salt prefix cannot record.
+// Unset fields are taken as their defaults.
+func (p Argon2idParams) Validate() error {
+	p = p.withDefaults()
+	if p.Time < 1 || p.Time > 255 || p.Threads < 1 || p.Threads > 255 {
+		return fmt.Errorf("argon2id time and threads must be from 1 to 255, got %d and %d", p.Time, p.Threads)
+	}
+	if p.Memory < 8*p.Threads || p.Memory > math.MaxUint32 {
+		return fmt.Errorf("argon2id memory must be at least 8 KiB per thread, got %d KiB", p.Memory)
+	}
+	return nil
+}
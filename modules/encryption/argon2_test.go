@@ -0,0 +1,122 @@
+package encryption
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestArgon2idParamsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  Argon2idParams
+		wantErr bool
+	}{
+		{"defaults", Argon2idParams{}, false},
+		{"owasp", DefaultArgon2idParams, false},
+		{"test", testArgon2id, false},
+		{"more memory", Argon2idParams{Time: 1, Memory: 64 << 10, Threads: 4}, false},
+		{"8 KiB per thread", Argon2idParams{Memory: 32, Threads: 4}, false},
+		{"negative time", Argon2idParams{Time: -1}, true},
+		{"time above a byte", Argon2idParams{Time: 256}, true},
+		{"negative threads", Argon2idParams{Threads: -1}, true},
+		{"threads above a byte", Argon2idParams{Threads: 256}, true},
+		{"below 8 KiB per thread", Argon2idParams{Memory: 31, Threads: 4}, true},
+		{"negative memory", Argon2idParams{Memory: -1}, true},
+		{"memory above 4 bytes", Argon2idParams{Memory: 1 << 32}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.params.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestArgon2idIsTheDefault(t *testing.T) {
+	for _, algorithm := range []Algorithm{"", "bcrypt", AlgorithmArgon2id} {
+		e := Init(Config{Algorithm: algorithm}, nil).(*encryptionImpl)
+		if e.algorithm != AlgorithmArgon2id {
+			t.Errorf("Init with algorithm %q uses %q, want %q", algorithm, e.algorithm, AlgorithmArgon2id)
+		}
+		if e.argon2id != DefaultArgon2idParams {
+			t.Errorf("Init with unset parameters uses %+v, want %+v", e.argon2id, DefaultArgon2idParams)
+		}
+	}
+
+	e := Init(Config{Argon2id: Argon2idParams{Memory: 64}}, nil).(*encryptionImpl)
+	if want := (Argon2idParams{Time: DefaultArgon2idParams.Time, Memory: 64, Threads: DefaultArgon2idParams.Threads}); e.argon2id != want {
+		t.Errorf("Init with only the memory set uses %+v, want %+v", e.argon2id, want)
+	}
+}
+
+func TestArgon2idSalt(t *testing.T) {
+	e := Init(Config{Argon2id: Argon2idParams{Time: 1, Memory: 300, Threads: 2}}, nil)
+	ciphertext, salt, err := e.Encrypt([]byte("data"), "password")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	// Mode, tag, time, memory (big endian) and threads precede the random salt
+	if want := []byte{SaltModeKDF, kdfTagArgon2id, 1, 0, 0, 1, 44, 2}; len(salt) != argon2idSaltSize || !bytes.Equal(salt[:8], want) {
+		t.Errorf("salt = %x, want prefix %x", salt, want)
+	}
+	if got, err := e.Decrypt(ciphertext, salt, "password"); err != nil || string(got) != "data" {
+		t.Errorf("Decrypt = %q, %v", got, err)
+	}
+	if _, err := e.Decrypt(ciphertext, salt, "wrong"); err == nil {
+		t.Error("Decrypt with a wrong password succeeded")
+	}
+
+	// Parameters come from the salt, not from the configuration
+	other := Init(Config{Argon2id: testArgon2id}, nil)
+	if got, err := other.Decrypt(ciphertext, salt, "password"); err != nil || string(got) != "data" {
+		t.Errorf("Decrypt with other parameters configured = %q, %v", got, err)
+	}
+	changed := bytes.Clone(salt)
+	changed[2] = 2
+	if _, err := e.Decrypt(ciphertext, changed, "password"); err == nil {
+		t.Error("Decrypt with the time changed in the salt succeeded")
+	}
+}
+
+func TestArgon2idInvalidSalt(t *testing.T) {
+	e := Init(Config{Argon2id: testArgon2id}, nil).(*encryptionImpl)
+	random := bytes.Repeat([]byte{0xab}, pbkdf2SaltSize)
+
+	tests := []struct {
+		name string
+		salt []byte
+	}{
+		{"too short", append([]byte{SaltModeKDF, kdfTagArgon2id, 1, 0, 0, 0, 64}, random...)},
+		{"too long", append([]byte{SaltModeKDF, kdfTagArgon2id, 1, 0, 0, 0, 64, 1, 0}, random...)},
+		{"time of zero", append([]byte{SaltModeKDF, kdfTagArgon2id, 0, 0, 0, 0, 64, 1}, random...)},
+		{"threads of zero", append([]byte{SaltModeKDF, kdfTagArgon2id, 1, 0, 0, 0, 64, 0}, random...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := e.deriveKey("password", tt.salt, 32); err == nil {
+				t.Error("deriveKey succeeded")
+			}
+		})
+	}
+}
+
+func TestArgon2idKeyDependsOnSize(t *testing.T) {
+	e := Init(Config{Argon2id: testArgon2id}, nil).(*encryptionImpl)
+	salt, err := e.newSalt()
+	if err != nil {
+		t.Fatalf("newSalt: %v", err)
+	}
+	short, err := e.deriveKey("password", salt, 32)
+	if err != nil {
+		t.Fatalf("deriveKey: %v", err)
+	}
+	long, err := e.deriveKey("password", salt, 64)
+	if err != nil {
+		t.Fatalf("deriveKey: %v", err)
+	}
+	// Unlike PBKDF2, a shorter key is not a prefix of a longer one
+	if bytes.Equal(short, long[:32]) {
+		t.Error("the 32-byte key is the start of the 64-byte key")
+	}
+}
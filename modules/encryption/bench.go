@@ -0,0 +1,68 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// DefaultMaxBenchSize is the largest payload of BenchmarkEncryption when no cap is given
+const DefaultMaxBenchSize int64 = 10 << 20
+
+// benchSizes are the payload sizes measured by BenchmarkEncryption
+var benchSizes = []int64{1 << 10, 100 << 10, 1 << 20, 10 << 20}
+
+// BenchResult is the time of one Encrypt and one Decrypt of SizeBytes random bytes
+type BenchResult struct {
+	SizeBytes int64
+	Encrypt   time.Duration
+	Decrypt   time.Duration
+}
+
+// BenchmarkEncryption encrypts and decrypts random payloads of 1 KB, 100 KB, 1 MB and
+// 10 MB with a random password, skipping sizes above maxSize (DefaultMaxBenchSize when
+// not positive). Both calls derive the key, so each time includes one key derivation.
+func BenchmarkEncryption(enc Encryption, maxSize int64) ([]BenchResult, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxBenchSize
+	}
+
+	key, err := enc.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	password := hex.EncodeToString(key)
+
+	var results []BenchResult
+	for _, size := range benchSizes {
+		if size > maxSize {
+			break
+		}
+		payload := make([]byte, size)
+		if _, err := rand.Read(payload); err != nil {
+			return nil, err
+		}
+
+		start := time.Now()
+		ciphertext, salt, err := enc.Encrypt(payload, password)
+		if err != nil {
+			return nil, err
+		}
+		result := BenchResult{SizeBytes: size, Encrypt: time.Since(start)}
+
+		start = time.Now()
+		plaintext, err := enc.Decrypt(ciphertext, salt, password)
+		if err != nil {
+			return nil, err
+		}
+		result.Decrypt = time.Since(start)
+
+		if !bytes.Equal(plaintext, payload) {
+			return nil, errors.New("encryption benchmark: decrypted payload differs")
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
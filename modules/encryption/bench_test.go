@@ -0,0 +1,77 @@
+package encryption
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// corruptingEncryption flips a byte of every decrypted payload
+type corruptingEncryption struct {
+	Encryption
+}
+
+func (e corruptingEncryption) Decrypt(encryptedData, salt []byte, password string) ([]byte, error) {
+	plaintext, err := e.Encryption.Decrypt(encryptedData, salt, password)
+	if err == nil && len(plaintext) > 0 {
+		plaintext[0] ^= 1
+	}
+	return plaintext, err
+}
+
+// failingEncryption fails every Encrypt
+type failingEncryption struct {
+	Encryption
+	err error
+}
+
+func (e failingEncryption) Encrypt([]byte, string) ([]byte, []byte, error) {
+	return nil, nil, e.err
+}
+
+func TestBenchmarkEncryption(t *testing.T) {
+	tests := []struct {
+		name    string
+		maxSize int64
+		want    []int64
+	}{
+		{"default cap", 0, []int64{1 << 10, 100 << 10, 1 << 20, 10 << 20}},
+		{"negative cap", -1, []int64{1 << 10, 100 << 10, 1 << 20, 10 << 20}},
+		{"1 MB", 1 << 20, []int64{1 << 10, 100 << 10, 1 << 20}},
+		{"between sizes", 500 << 10, []int64{1 << 10, 100 << 10}},
+		{"below every size", 512, nil},
+	}
+	enc := newTestEncryption(CipherAESGCM)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := BenchmarkEncryption(enc, tt.maxSize)
+			if err != nil {
+				t.Fatalf("BenchmarkEncryption: %v", err)
+			}
+			var sizes []int64
+			for _, result := range results {
+				sizes = append(sizes, result.SizeBytes)
+				if result.Encrypt <= 0 || result.Decrypt <= 0 {
+					t.Errorf("%d bytes took %v to encrypt and %v to decrypt", result.SizeBytes, result.Encrypt, result.Decrypt)
+				}
+			}
+			if !slices.Equal(sizes, tt.want) {
+				t.Errorf("sizes = %v, want %v", sizes, tt.want)
+			}
+		})
+	}
+}
+
+func TestBenchmarkEncryptionErrors(t *testing.T) {
+	enc := newTestEncryption(CipherAESGCM)
+
+	if _, err := BenchmarkEncryption(corruptingEncryption{enc}, 1<<10); err == nil || !strings.Contains(err.Error(), "differs") {
+		t.Errorf("BenchmarkEncryption with a corrupted round trip = %v, want an error", err)
+	}
+
+	encryptErr := errors.New("cipher unavailable")
+	if _, err := BenchmarkEncryption(failingEncryption{enc, encryptErr}, 1<<10); !errors.Is(err, encryptErr) {
+		t.Errorf("BenchmarkEncryption with a failing Encrypt = %v, want %v", err, encryptErr)
+	}
+}
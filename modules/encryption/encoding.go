@@ -0,0 +1,75 @@
+package encryption
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+)
+
+// URLKeySize is the length of the keys made by GenerateKey and carried in download URLs
+const URLKeySize = 32
+
+// ErrInvalidEncodedKey is returned by ConstantTimeDecode for input that is not the
+// unpadded base64url encoding of a key of the expected length
+var ErrInvalidEncodedKey = errors.New("invalid encoded key")
+
+// ConstantTimeDecode decodes an unpadded base64url key of expectedLen bytes.
+// Unlike base64.RawURLEncoding, whose table lookups and early returns depend on
+// the input, the input is padded or truncated to the encoded length of
+// expectedLen and every character is decoded branch-free, so the time taken
+// does not tell where the input is malformed or how long it is.
+// Trailing bits of the last character are ignored, as by RawURLEncoding.
+func ConstantTimeDecode(encoded string, expectedLen int) ([]byte, error) {
+	encodedLen := base64.RawURLEncoding.EncodedLen(expectedLen)
+	lengthOK := subtle.ConstantTimeEq(int32(len(encoded)), int32(encodedLen))
+
+	buf := make([]byte, encodedLen)
+	copy(buf, encoded)
+
+	out := make([]byte, expectedLen)
+	invalid := 0
+	var acc, bits, n int
+	for _, c := range buf {
+		v := decodeBase64URLChar(int(c))
+		invalid |= v >> 6 // 0xFF for invalid characters, 0..63 otherwise
+		acc = (acc<<6 | v&0x3F) & 0xFFFF
+		bits += 6
+		if bits >= 8 && n < expectedLen {
+			bits -= 8
+			out[n] = byte(acc >> bits)
+			n++
+		}
+	}
+
+	if lengthOK&subtle.ConstantTimeByteEq(byte(invalid), 0) != 1 {
+		return nil, ErrInvalidEncodedKey
+	}
+	return out, nil
+}
+
+// decodeBase64URLChar maps a base64url character to its 6-bit value and any other
+// byte to 0xFF, without branches or table lookups on c
+func decodeBase64URLChar(c int) int {
+	x := (ctGreater(c, 'A'-1) & ctLess(c, 'Z'+1) & (c - 'A')) |
+		(ctGreater(c, 'a'-1) & ctLess(c, 'z'+1) & (c - ('a' - 26))) |
+		(ctGreater(c, '0'-1) & ctLess(c, '9'+1) & (c - ('0' - 52))) |
+		(ctEqual(c, '-') & 62) |
+		(ctEqual(c, '_') & 63)
+	// 0 is both 'A' and the value of no match
+	return x | (ctEqual(x, 0) & (ctEqual(c, 'A') ^ 0xFF))
+}
+
+// ctLess returns 0xFF when x < y and 0 otherwise, for x and y in [0, 255]
+func ctLess(x, y int) int {
+	return ((x - y) >> 8) & 0xFF
+}
+
+// ctGreater returns 0xFF when x > y and 0 otherwise, for x and y in [0, 255]
+func ctGreater(x, y int) int {
+	return ctLess(y, x)
+}
+
+// ctEqual returns 0xFF when x == y and 0 otherwise, for x and y in [0, 255]
+func ctEqual(x, y int) int {
+	return (((x ^ y) - 1) >> 8) & 0xFF
+}
@@ -0,0 +1,120 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const base64URLAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
+
+func TestDecodeBase64URLChar(t *testing.T) {
+	for c := range 256 {
+		want := strings.IndexByte(base64URLAlphabet, byte(c))
+		if want < 0 {
+			want = 0xFF
+		}
+		if got := decodeBase64URLChar(c); got != want {
+			t.Errorf("decodeBase64URLChar(%q) = %#x, want %#x", rune(c), got, want)
+		}
+	}
+}
+
+func TestConstantTimeDecode(t *testing.T) {
+	key := make([]byte, URLKeySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("rand.Read: %v", err)
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(key)
+
+	got, err := ConstantTimeDecode(encoded, URLKeySize)
+	if err != nil {
+		t.Fatalf("ConstantTimeDecode: %v", err)
+	}
+	if !bytes.Equal(got, key) {
+		t.Errorf("ConstantTimeDecode = %x, want %x", got, key)
+	}
+
+	// Every length, so that each number of trailing bits is covered
+	for n := 1; n <= 48; n++ {
+		data := bytes.Repeat([]byte{0xA5, 0x5A, 0xFF}, n)[:n]
+		got, err := ConstantTimeDecode(base64.RawURLEncoding.EncodeToString(data), n)
+		if err != nil || !bytes.Equal(got, data) {
+			t.Errorf("ConstantTimeDecode of %d bytes = %x, %v, want %x", n, got, err, data)
+		}
+	}
+}
+
+func TestConstantTimeDecodeRejects(t *testing.T) {
+	valid := base64.RawURLEncoding.EncodeToString(make([]byte, URLKeySize))
+
+	tests := []struct {
+		name    string
+		encoded string
+	}{
+		{"empty", ""},
+		{"too short", valid[:len(valid)-1]},
+		{"too long", valid + "A"},
+		{"padded", base64.URLEncoding.EncodeToString(make([]byte, URLKeySize))},
+		{"standard alphabet plus", "+" + valid[1:]},
+		{"standard alphabet slash", valid[:10] + "/" + valid[11:]},
+		{"space", valid[:len(valid)-1] + " "},
+		{"newline", valid[:20] + "\n" + valid[21:]},
+		{"NUL", valid[:5] + "\x00" + valid[6:]},
+		{"non-ASCII", "é" + valid[2:]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ConstantTimeDecode(tt.encoded, URLKeySize)
+			if !errors.Is(err, ErrInvalidEncodedKey) {
+				t.Errorf("ConstantTimeDecode(%q) = %x, %v, want %v", tt.encoded, got, err, ErrInvalidEncodedKey)
+			}
+		})
+	}
+}
+
+func TestConstantTimeDecodeIgnoresTrailingBits(t *testing.T) {
+	// "AB" holds 12 bits for one byte, like RawURLEncoding the last four are ignored
+	got, err := ConstantTimeDecode("AB", 1)
+	if err != nil || !bytes.Equal(got, []byte{0}) {
+		t.Errorf("ConstantTimeDecode(AB) = %x, %v, want 00", got, err)
+	}
+	want, _ := base64.RawURLEncoding.DecodeString("AB")
+	if !bytes.Equal(got, want) {
+		t.Errorf("ConstantTimeDecode(AB) = %x, RawURLEncoding gives %x", got, want)
+	}
+}
+
+func FuzzConstantTimeDecode(f *testing.F) {
+	f.Add(base64.RawURLEncoding.EncodeToString(make([]byte, URLKeySize)), URLKeySize)
+	f.Add("AB", 1)
+	f.Add("_-_-", 3)
+	f.Add("a+b/", 3)
+	f.Add("", 0)
+
+	f.Fuzz(func(t *testing.T, encoded string, n int) {
+		if n < 0 || n > 64 {
+			return
+		}
+		got, err := ConstantTimeDecode(encoded, n)
+
+		// RawURLEncoding skips line breaks, ConstantTimeDecode rejects them
+		want, wantErr := base64.RawURLEncoding.DecodeString(encoded)
+		if strings.ContainsAny(encoded, "\r\n") {
+			wantErr = errors.New("line break")
+		}
+		if wantErr == nil && len(want) != n {
+			wantErr = errors.New("other length")
+		}
+
+		if (err == nil) != (wantErr == nil) {
+			t.Fatalf("ConstantTimeDecode(%q, %d) error = %v, RawURLEncoding: %v", encoded, n, err, wantErr)
+		}
+		if err == nil && !bytes.Equal(got, want) {
+			t.Errorf("ConstantTimeDecode(%q, %d) = %x, want %x", encoded, n, got, want)
+		}
+	})
+}
@@ -3,13 +3,18 @@ package encryption
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"time"
 
-	"golang.org/x/crypto/pbkdf2"
+	"lovebin/modules/logger"
 )
 
 // Encryption interface for dependency injection
@@ -17,35 +22,135 @@ type Encryption interface {
 	Encrypt(data []byte, password string) ([]byte, []byte, error) // returns encrypted data and salt
 	Decrypt(encryptedData []byte, salt []byte, password string) ([]byte, error)
 	GenerateKey() ([]byte, error)
+
+	// FastEncrypt and FastDecrypt use a high-entropy key directly, without PBKDF2.
+	// Only for random keys such as the URL enc_key, never for user passwords.
+	FastEncrypt(data []byte, key []byte) ([]byte, error)
+	FastDecrypt(data []byte, key []byte) ([]byte, error)
+
+	// EncryptStream and DecryptStream are Encrypt and Decrypt for data too large to
+	// hold in memory, sealed chunk by chunk with AES-GCM (see StreamChunkSize).
+	// Their salts are marked, see IsStreamSalt.
+	EncryptStream(r io.Reader, password string) (io.ReadCloser, []byte, error)
+	DecryptStream(r io.Reader, salt []byte, password string) (io.ReadCloser, error)
+	// FastEncryptStream and FastDecryptStream stream like FastEncrypt and FastDecrypt,
+	// the salt recorded for their data is FastStreamSalt
+	FastEncryptStream(r io.Reader, key []byte) (io.ReadCloser, error)
+	FastDecryptStream(r io.Reader, key []byte) (io.ReadCloser, error)
+
+	// Cipher returns the content cipher used by FastEncrypt
+	Cipher() Cipher
+
+	// SelfTest encrypts and decrypts a fixed test vector through the configured
+	// key derivation and cipher, to catch a broken setup on startup
+	SelfTest() error
+}
+
+// SaltModeFast is stored as the whole salt of data sealed with FastEncrypt.
+// PBKDF2 salts are 16 random bytes, so the length tells the modes apart (see SaltModeKDF
+// and SaltModeStream).
+const SaltModeFast byte = 0x01
+
+// FastSalt returns the salt value recorded for FastEncrypt data
+func FastSalt() []byte {
+	return []byte{SaltModeFast}
 }
 
+// IsFastSalt reports whether salt marks data sealed with FastEncrypt
+func IsFastSalt(salt []byte) bool {
+	return len(salt) == 1 && salt[0] == SaltModeFast
+}
+
+// Cipher selects the content encryption algorithm
+type Cipher string
+
+const (
+	// CipherAESGCM is AES-256-GCM with a random nonce (default)
+	CipherAESGCM Cipher = "aes-gcm"
+	// CipherAESSIV is deterministic AES-256-SIV: the same plaintext and key always give the
+	// same ciphertext, so ciphertext hashes can serve as deduplication keys. It leaks equality
+	// of plaintexts encrypted under one key and should only be used when deduplication is the
+	// explicit goal. Unlike GCM, a repeated nonce does not break confidentiality, it is the
+	// determinism itself that reveals repeats.
+	CipherAESSIV Cipher = "aes-siv"
+)
+
 type encryptionImpl struct {
 	iterations int
+	cipher     Cipher
+	algorithm  Algorithm
+	scrypt     ScryptParams
+	argon2id   Argon2idParams
 }
 
 // Config holds encryption configuration
 type Config struct {
-	Iterations int // PBKDF2 iterations
+	Iterations int            // PBKDF2 iterations
+	Cipher     Cipher         // content cipher, CipherAESGCM when empty
+	Algorithm  Algorithm      // key derivation of new password resources, AlgorithmArgon2id when empty
+	Scrypt     ScryptParams   // parameters of AlgorithmScrypt, DefaultScryptParams for unset fields
+	Argon2id   Argon2idParams // parameters of AlgorithmArgon2id, DefaultArgon2idParams for unset fields
+
+	MaxKDFDuration time.Duration // startup KDF benchmark warns above this, DefaultMaxKDFDuration when zero
 }
 
-// Init initializes the encryption module
-func Init(cfg Config) Encryption {
+// Init initializes the encryption module and logs a KDF benchmark (skipped when log is nil)
+func Init(cfg Config, log logger.Logger) Encryption {
 	iterations := cfg.Iterations
 	if iterations == 0 {
 		iterations = 100000 // default
 	}
-	return &encryptionImpl{iterations: iterations}
+	c := cfg.Cipher
+	if c != CipherAESSIV {
+		c = CipherAESGCM // default
+	}
+
+	algorithm := cfg.Algorithm
+	if algorithm != AlgorithmPBKDF2 && algorithm != AlgorithmScrypt {
+		algorithm = AlgorithmArgon2id // default
+	}
+	e := &encryptionImpl{
+		iterations: iterations,
+		cipher:     c,
+		algorithm:  algorithm,
+		scrypt:     cfg.Scrypt.withDefaults(),
+		argon2id:   cfg.Argon2id.withDefaults(),
+	}
+
+	if log != nil {
+		maxDuration := cfg.MaxKDFDuration
+		if maxDuration <= 0 {
+			maxDuration = DefaultMaxKDFDuration
+		}
+		e.logKDFBenchmark(log.Child("encryption"), maxDuration)
+	}
+
+	return e
 }
 
 func (e *encryptionImpl) Encrypt(data []byte, password string) ([]byte, []byte, error) {
-	// Generate salt
-	salt := make([]byte, 16)
-	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+	salt, err := e.newSalt()
+	if err != nil {
 		return nil, nil, err
 	}
 
+	if e.cipher == CipherAESSIV {
+		key, err := e.deriveKey(password, salt, sivKeySize)
+		if err != nil {
+			return nil, nil, err
+		}
+		ciphertext, err := sealSIV(key, data)
+		if err != nil {
+			return nil, nil, err
+		}
+		return ciphertext, salt, nil
+	}
+
 	// Derive key from password
-	key := pbkdf2.Key([]byte(password), salt, e.iterations, 32, sha256.New)
+	key, err := e.deriveKey(password, salt, 32)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	// Create AES cipher
 	block, err := aes.NewCipher(key)
@@ -72,9 +177,80 @@ func (e *encryptionImpl) Encrypt(data []byte, password string) ([]byte, []byte,
 }
 
 func (e *encryptionImpl) Decrypt(encryptedData []byte, salt []byte, password string) ([]byte, error) {
+	if e.cipher == CipherAESSIV {
+		// The first 32 bytes of the 64-byte PBKDF2 (or scrypt) output equal the GCM key, so
+		// data stored before switching to SIV still decrypts without a second derivation.
+		// Argon2id output depends on its length, its GCM key is derived again.
+		key, err := e.deriveKey(password, salt, sivKeySize)
+		if err != nil {
+			return nil, err
+		}
+		if plaintext, err := openSIV(key, encryptedData); err == nil {
+			return plaintext, nil
+		}
+		gcmKey := key[:32]
+		if isArgon2idSalt(salt) {
+			if gcmKey, err = e.deriveKey(password, salt, 32); err != nil {
+				return nil, err
+			}
+		}
+		return openGCM(gcmKey, encryptedData)
+	}
+
 	// Derive key from password
-	key := pbkdf2.Key([]byte(password), salt, e.iterations, 32, sha256.New)
+	key, err := e.deriveKey(password, salt, 32)
+	if err != nil {
+		return nil, err
+	}
+	return openGCM(key, encryptedData)
+}
+
+func (e *encryptionImpl) Cipher() Cipher {
+	return e.cipher
+}
+
+func (e *encryptionImpl) FastEncrypt(data []byte, key []byte) ([]byte, error) {
+	if e.cipher == CipherAESSIV {
+		sum := sha512.Sum512(key)
+		return sealSIV(sum[:], data)
+	}
+	sum := sha256.Sum256(key)
+	return sealGCM(sum[:], data)
+}
+
+func (e *encryptionImpl) FastDecrypt(data []byte, key []byte) ([]byte, error) {
+	if e.cipher == CipherAESSIV {
+		sum := sha512.Sum512(key)
+		if plaintext, err := openSIV(sum[:], data); err == nil {
+			return plaintext, nil
+		}
+	}
+	sum := sha256.Sum256(key)
+	return openGCM(sum[:], data)
+}
+
+// sealGCM encrypts data with AES-GCM and returns nonce || ciphertext
+func sealGCM(key []byte, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, data, nil), nil
+}
 
+// openGCM decrypts nonce || ciphertext produced by AES-GCM
+func openGCM(key []byte, encryptedData []byte) ([]byte, error) {
 	// Create AES cipher
 	block, err := aes.NewCipher(key)
 	if err != nil {
@@ -104,8 +280,47 @@ func (e *encryptionImpl) Decrypt(encryptedData []byte, salt []byte, password str
 	return plaintext, nil
 }
 
+// selfTestPlaintext and selfTestPassword are the fixed test vector of SelfTest
+const (
+	selfTestPlaintext = "lovebin encryption self test"
+	selfTestPassword  = "lovebin self test password"
+)
+
+// SelfTest round trips the test vector through Encrypt and Decrypt, checks that
+// a wrong password is rejected, and round trips it through FastEncrypt and FastDecrypt
+func (e *encryptionImpl) SelfTest() error {
+	ciphertext, salt, err := e.Encrypt([]byte(selfTestPlaintext), selfTestPassword)
+	if err != nil {
+		return fmt.Errorf("encryption self test: encrypt: %w", err)
+	}
+	plaintext, err := e.Decrypt(ciphertext, salt, selfTestPassword)
+	if err != nil {
+		return fmt.Errorf("encryption self test: decrypt: %w", err)
+	}
+	if string(plaintext) != selfTestPlaintext {
+		return errors.New("encryption self test: decrypted data differs")
+	}
+	if _, err := e.Decrypt(ciphertext, salt, selfTestPassword+"!"); err == nil {
+		return errors.New("encryption self test: wrong password was accepted")
+	}
+
+	key := sha256.Sum256([]byte(selfTestPassword))
+	sealed, err := e.FastEncrypt([]byte(selfTestPlaintext), key[:])
+	if err != nil {
+		return fmt.Errorf("encryption self test: fast encrypt: %w", err)
+	}
+	plaintext, err = e.FastDecrypt(sealed, key[:])
+	if err != nil {
+		return fmt.Errorf("encryption self test: fast decrypt: %w", err)
+	}
+	if string(plaintext) != selfTestPlaintext {
+		return errors.New("encryption self test: fast decrypted data differs")
+	}
+	return nil
+}
+
 func (e *encryptionImpl) GenerateKey() ([]byte, error) {
-	key := make([]byte, 32)
+	key := make([]byte, URLKeySize)
 	if _, err := io.ReadFull(rand.Reader, key); err != nil {
 		return nil, err
 	}
@@ -120,3 +335,21 @@ func GenerateURLKey() (string, error) {
 	}
 	return base64.URLEncoding.EncodeToString(key), nil
 }
+
+// HashAccessCode hashes a single-use access code of a resource.
+// The resource key acts as salt, so equal codes of different resources differ.
+func HashAccessCode(resourceKey, code string) string {
+	sum := sha256.Sum256([]byte(resourceKey + ":" + code))
+	return hex.EncodeToString(sum[:])
+}
+
+// keyCheckLabel domain-separates KeyCheck from the other uses of the URL key
+const keyCheckLabel = "lovebin key check"
+
+// KeyCheck derives a value that proves knowledge of the URL key without revealing it.
+// It can be stored server side to verify a key without decrypting the content.
+func KeyCheck(key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(keyCheckLabel))
+	return mac.Sum(nil)
+}
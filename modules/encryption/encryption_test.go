@@ -0,0 +1,186 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+)
+
+// testArgon2id keeps tests quick, the parameters are not meant to be secure
+var testArgon2id = Argon2idParams{Time: 1, Memory: 64, Threads: 1}
+
+func newTestEncryption(c Cipher) Encryption {
+	return Init(Config{Cipher: c, Iterations: 1000, Argon2id: testArgon2id}, nil)
+}
+
+func TestCipherRoundTrip(t *testing.T) {
+	plaintext := []byte("attack at dawn")
+	key := sha256.Sum256([]byte("url key"))
+
+	for _, c := range []Cipher{CipherAESGCM, CipherAESSIV} {
+		t.Run(string(c), func(t *testing.T) {
+			e := newTestEncryption(c)
+			if e.Cipher() != c {
+				t.Fatalf("Cipher() = %q, want %q", e.Cipher(), c)
+			}
+
+			ciphertext, salt, err := e.Encrypt(plaintext, "password")
+			if err != nil {
+				t.Fatalf("Encrypt: %v", err)
+			}
+			got, err := e.Decrypt(ciphertext, salt, "password")
+			if err != nil || !bytes.Equal(got, plaintext) {
+				t.Fatalf("Decrypt = %q, %v, want %q", got, err, plaintext)
+			}
+			if _, err := e.Decrypt(ciphertext, salt, "wrong"); err == nil {
+				t.Error("Decrypt with a wrong password succeeded")
+			}
+
+			sealed, err := e.FastEncrypt(plaintext, key[:])
+			if err != nil {
+				t.Fatalf("FastEncrypt: %v", err)
+			}
+			got, err = e.FastDecrypt(sealed, key[:])
+			if err != nil || !bytes.Equal(got, plaintext) {
+				t.Fatalf("FastDecrypt = %q, %v, want %q", got, err, plaintext)
+			}
+			otherKey := sha256.Sum256([]byte("other key"))
+			if _, err := e.FastDecrypt(sealed, otherKey[:]); err == nil {
+				t.Error("FastDecrypt with a wrong key succeeded")
+			}
+
+			tampered := bytes.Clone(sealed)
+			tampered[len(tampered)-1] ^= 1
+			if _, err := e.FastDecrypt(tampered, key[:]); err == nil {
+				t.Error("FastDecrypt of tampered data succeeded")
+			}
+
+			if err := e.SelfTest(); err != nil {
+				t.Errorf("SelfTest: %v", err)
+			}
+		})
+	}
+}
+
+func TestCipherDeterminism(t *testing.T) {
+	plaintext := []byte("same content")
+	key := sha256.Sum256([]byte("url key"))
+
+	tests := []struct {
+		cipher        Cipher
+		deterministic bool
+	}{
+		{CipherAESGCM, false},
+		{CipherAESSIV, true},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.cipher), func(t *testing.T) {
+			e := newTestEncryption(tt.cipher)
+			a, _ := e.FastEncrypt(plaintext, key[:])
+			b, _ := e.FastEncrypt(plaintext, key[:])
+			if bytes.Equal(a, b) != tt.deterministic {
+				t.Errorf("equal ciphertexts = %v, want %v", bytes.Equal(a, b), tt.deterministic)
+			}
+
+			// Different keys never give the same ciphertext
+			otherKey := sha256.Sum256([]byte("other key"))
+			c, _ := e.FastEncrypt(plaintext, otherKey[:])
+			if bytes.Equal(a, c) {
+				t.Error("different keys gave the same ciphertext")
+			}
+		})
+	}
+}
+
+func TestSIVDecryptsGCMData(t *testing.T) {
+	plaintext := []byte("stored before the switch")
+	key := sha256.Sum256([]byte("url key"))
+
+	// Data written with GCM must stay readable after switching the cipher to SIV,
+	// for every key derivation
+	for _, algorithm := range []Algorithm{AlgorithmPBKDF2, AlgorithmScrypt, AlgorithmArgon2id} {
+		t.Run(string(algorithm), func(t *testing.T) {
+			cfg := Config{Iterations: 1000, Algorithm: algorithm, Scrypt: ScryptParams{N: 16}, Argon2id: testArgon2id}
+			gcm := Init(cfg, nil)
+			cfg.Cipher = CipherAESSIV
+			siv := Init(cfg, nil)
+
+			ciphertext, salt, err := gcm.Encrypt(plaintext, "password")
+			if err != nil {
+				t.Fatalf("Encrypt: %v", err)
+			}
+			got, err := siv.Decrypt(ciphertext, salt, "password")
+			if err != nil || !bytes.Equal(got, plaintext) {
+				t.Errorf("SIV Decrypt of GCM data = %q, %v", got, err)
+			}
+			if _, err := siv.Decrypt(ciphertext, salt, "wrong"); err == nil {
+				t.Error("SIV Decrypt of GCM data with a wrong password succeeded")
+			}
+		})
+	}
+
+	gcm := newTestEncryption(CipherAESGCM)
+	siv := newTestEncryption(CipherAESSIV)
+	sealed, _ := gcm.FastEncrypt(plaintext, key[:])
+	if got, err := siv.FastDecrypt(sealed, key[:]); err != nil || !bytes.Equal(got, plaintext) {
+		t.Errorf("SIV FastDecrypt of GCM data = %q, %v", got, err)
+	}
+
+	// GCM does not read SIV data, switching back is not supported
+	sealed, _ = siv.FastEncrypt(plaintext, key[:])
+	if _, err := gcm.FastDecrypt(sealed, key[:]); err == nil {
+		t.Error("GCM FastDecrypt of SIV data succeeded")
+	}
+}
+
+func TestInitUnknownCipherDefaultsToGCM(t *testing.T) {
+	for _, c := range []Cipher{"", "chacha20"} {
+		if got := newTestEncryption(c).Cipher(); got != CipherAESGCM {
+			t.Errorf("Init with cipher %q uses %q, want %q", c, got, CipherAESGCM)
+		}
+	}
+}
+
+func TestSaltModes(t *testing.T) {
+	tests := []struct {
+		name                     string
+		salt                     []byte
+		fast, fastStream, stream bool
+	}{
+		{"fast", FastSalt(), true, false, false},
+		{"fast stream", FastStreamSalt(), false, true, false},
+		{"stream", []byte{SaltModeStream, 0x02, 0x01}, false, false, true},
+		{"stream without parameters", []byte{SaltModeStream}, false, false, false},
+		{"pbkdf2", bytes.Repeat([]byte{SaltModeFast}, 16), false, false, false},
+		{"kdf", []byte{SaltModeKDF, 0x02, 0x01}, false, false, false},
+		{"empty", nil, false, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsFastSalt(tt.salt); got != tt.fast {
+				t.Errorf("IsFastSalt = %v, want %v", got, tt.fast)
+			}
+			if got := IsFastStreamSalt(tt.salt); got != tt.fastStream {
+				t.Errorf("IsFastStreamSalt = %v, want %v", got, tt.fastStream)
+			}
+			if got := IsStreamSalt(tt.salt); got != tt.stream {
+				t.Errorf("IsStreamSalt = %v, want %v", got, tt.stream)
+			}
+		})
+	}
+}
+
+func TestFastEncryptIsBoundToTheKey(t *testing.T) {
+	e := newTestEncryption(CipherAESGCM)
+	key := sha256.Sum256([]byte("url key"))
+	sealed, err := e.FastEncrypt([]byte("content"), key[:])
+	if err != nil {
+		t.Fatalf("FastEncrypt: %v", err)
+	}
+
+	// The fast path hashes the URL key instead of stretching it, its data does not open
+	// through the password key derivation
+	if _, err := e.Decrypt(sealed, FastSalt(), string(key[:])); err == nil {
+		t.Error("fast data decrypted through the password key derivation")
+	}
+}
@@ -0,0 +1,113 @@
+package encryption
+
+import (
+	"crypto/sha256"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+
+	"lovebin/modules/logger"
+)
+
+const (
+	// DefaultMaxKDFDuration is used when Config.MaxKDFDuration is not set
+	DefaultMaxKDFDuration = 500 * time.Millisecond
+	// minKDFDuration is the duration below which more iterations are recommended
+	minKDFDuration = 50 * time.Millisecond
+)
+
+// BenchmarkKDF measures a single PBKDF2 derivation with the given iteration count
+func BenchmarkKDF(iterations int) time.Duration {
+	salt := make([]byte, 16)
+	start := time.Now()
+	pbkdf2.Key([]byte("lovebin-kdf-benchmark"), salt, iterations, 32, sha256.New)
+	return time.Since(start)
+}
+
+// BenchmarkScrypt measures a single scrypt derivation with the given parameters,
+// DefaultScryptParams for unset fields
+func BenchmarkScrypt(params ScryptParams) (time.Duration, error) {
+	params = params.withDefaults()
+	salt := make([]byte, 16)
+	start := time.Now()
+	if _, err := scrypt.Key([]byte("lovebin-kdf-benchmark"), salt, params.N, params.R, params.P, 32); err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
+
+// BenchmarkArgon2id measures a single Argon2id derivation with the given parameters,
+// DefaultArgon2idParams for unset fields
+func BenchmarkArgon2id(params Argon2idParams) time.Duration {
+	params = params.withDefaults()
+	salt := make([]byte, 16)
+	start := time.Now()
+	argon2.IDKey([]byte("lovebin-kdf-benchmark"), salt, uint32(params.Time), uint32(params.Memory), uint8(params.Threads), 32)
+	return time.Since(start)
+}
+
+// logKDFBenchmark benchmarks the configured algorithm and logs whether its cost fits this machine
+func (e *encryptionImpl) logKDFBenchmark(log logger.Logger, maxDuration time.Duration) {
+	if e.algorithm == AlgorithmArgon2id {
+		d := BenchmarkArgon2id(e.argon2id)
+		fields := []zap.Field{
+			zap.Duration("kdf_bench", d),
+			zap.Int("argon2id_time", e.argon2id.Time),
+			zap.Int("argon2id_memory_kib", e.argon2id.Memory),
+			zap.Int("argon2id_threads", e.argon2id.Threads),
+		}
+		switch {
+		case d > maxDuration:
+			log.Warn("argon2id is slow on this machine, reduce its time or memory",
+				append(fields, zap.Duration("max_kdf_duration", maxDuration))...)
+		case d < minKDFDuration:
+			log.Info("argon2id is fast on this machine, consider increasing its memory", fields...)
+		default:
+			log.Info("argon2id benchmark", fields...)
+		}
+		return
+	}
+
+	if e.algorithm == AlgorithmScrypt {
+		d, err := BenchmarkScrypt(e.scrypt)
+		if err != nil {
+			log.Error("scrypt benchmark failed", zap.Error(err))
+			return
+		}
+		fields := []zap.Field{
+			zap.Duration("kdf_bench", d),
+			zap.Int("scrypt_n", e.scrypt.N),
+			zap.Int("scrypt_r", e.scrypt.R),
+			zap.Int("scrypt_p", e.scrypt.P),
+		}
+		switch {
+		case d > maxDuration:
+			log.Warn("scrypt is slow on this machine, reduce N",
+				append(fields, zap.Duration("max_kdf_duration", maxDuration))...)
+		case d < minKDFDuration:
+			log.Info("scrypt is fast on this machine, consider increasing N", fields...)
+		default:
+			log.Info("scrypt benchmark", fields...)
+		}
+		return
+	}
+
+	d := BenchmarkKDF(e.iterations)
+	fields := []zap.Field{
+		zap.Duration("kdf_bench", d),
+		zap.Int("iterations", e.iterations),
+	}
+
+	switch {
+	case d > maxDuration:
+		log.Warn("PBKDF2 is slow on this machine, reduce the iteration count or switch to argon2id",
+			append(fields, zap.Duration("max_kdf_duration", maxDuration))...)
+	case d < minKDFDuration:
+		log.Info("PBKDF2 is fast on this machine, consider increasing the iteration count", fields...)
+	default:
+		log.Info("PBKDF2 benchmark", fields...)
+	}
+}
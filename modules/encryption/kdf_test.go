@@ -0,0 +1,157 @@
+package encryption
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"lovebin/modules/logger"
+)
+
+// logEntry is one message written to a recordingLogger
+type logEntry struct {
+	level  string
+	msg    string
+	fields map[string]any
+}
+
+// recordingLogger keeps the entries written to it and its children
+type recordingLogger struct {
+	entries *[]logEntry
+}
+
+func newRecordingLogger() recordingLogger {
+	return recordingLogger{entries: new([]logEntry)}
+}
+
+func (l recordingLogger) record(level, msg string, fields []zap.Field) {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, field := range fields {
+		field.AddTo(enc)
+	}
+	*l.entries = append(*l.entries, logEntry{level: level, msg: msg, fields: enc.Fields})
+}
+
+func (l recordingLogger) Info(msg string, fields ...zap.Field)  { l.record("info", msg, fields) }
+func (l recordingLogger) Error(msg string, fields ...zap.Field) { l.record("error", msg, fields) }
+func (l recordingLogger) Warn(msg string, fields ...zap.Field)  { l.record("warn", msg, fields) }
+func (l recordingLogger) Debug(msg string, fields ...zap.Field) { l.record("debug", msg, fields) }
+func (l recordingLogger) Fatal(msg string, fields ...zap.Field) { l.record("fatal", msg, fields) }
+func (l recordingLogger) InfoCtx(_ context.Context, msg string, fields ...zap.Field) {
+	l.Info(msg, fields...)
+}
+func (l recordingLogger) ErrorCtx(_ context.Context, msg string, fields ...zap.Field) {
+	l.Error(msg, fields...)
+}
+func (l recordingLogger) WarnCtx(_ context.Context, msg string, fields ...zap.Field) {
+	l.Warn(msg, fields...)
+}
+func (l recordingLogger) DebugCtx(_ context.Context, msg string, fields ...zap.Field) {
+	l.Debug(msg, fields...)
+}
+func (l recordingLogger) FatalCtx(_ context.Context, msg string, fields ...zap.Field) {
+	l.Fatal(msg, fields...)
+}
+func (l recordingLogger) Sync() error                   { return nil }
+func (l recordingLogger) With(...zap.Field) *zap.Logger { return zap.NewNop() }
+func (l recordingLogger) Child(string) logger.Logger    { return l }
+
+func TestBenchmarkKDF(t *testing.T) {
+	cheap := BenchmarkKDF(1)
+	costly := BenchmarkKDF(200000)
+	if cheap <= 0 || costly <= cheap {
+		t.Errorf("BenchmarkKDF(1) = %v, BenchmarkKDF(200000) = %v, want more iterations to take longer", cheap, costly)
+	}
+
+	if d, err := BenchmarkScrypt(ScryptParams{N: 16}); err != nil || d <= 0 {
+		t.Errorf("BenchmarkScrypt = %v, %v", d, err)
+	}
+	if _, err := BenchmarkScrypt(ScryptParams{N: 15}); err == nil {
+		t.Error("BenchmarkScrypt accepted N that is not a power of two")
+	}
+	if d := BenchmarkArgon2id(testArgon2id); d <= 0 {
+		t.Errorf("BenchmarkArgon2id = %v", d)
+	}
+}
+
+func TestInitLogsKDFBenchmark(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         Config
+		wantLevel   string
+		wantMessage string
+		wantField   string
+	}{
+		{
+			name:        "pbkdf2 slow",
+			cfg:         Config{Algorithm: AlgorithmPBKDF2, Iterations: 200000, MaxKDFDuration: time.Nanosecond},
+			wantLevel:   "warn",
+			wantMessage: "PBKDF2 is slow",
+			wantField:   "iterations",
+		},
+		{
+			name:        "pbkdf2 fast",
+			cfg:         Config{Algorithm: AlgorithmPBKDF2, Iterations: 1},
+			wantLevel:   "info",
+			wantMessage: "PBKDF2 is fast",
+			wantField:   "iterations",
+		},
+		{
+			name:        "scrypt slow",
+			cfg:         Config{Algorithm: AlgorithmScrypt, Scrypt: ScryptParams{N: 16}, MaxKDFDuration: time.Nanosecond},
+			wantLevel:   "warn",
+			wantMessage: "scrypt is slow",
+			wantField:   "scrypt_n",
+		},
+		{
+			name:        "scrypt fast",
+			cfg:         Config{Algorithm: AlgorithmScrypt, Scrypt: ScryptParams{N: 16}},
+			wantLevel:   "info",
+			wantMessage: "scrypt is fast",
+			wantField:   "scrypt_n",
+		},
+		{
+			name:        "argon2id slow",
+			cfg:         Config{Argon2id: testArgon2id, MaxKDFDuration: time.Nanosecond},
+			wantLevel:   "warn",
+			wantMessage: "argon2id is slow",
+			wantField:   "argon2id_memory_kib",
+		},
+		{
+			name:        "argon2id fast",
+			cfg:         Config{Argon2id: testArgon2id},
+			wantLevel:   "info",
+			wantMessage: "argon2id is fast",
+			wantField:   "argon2id_memory_kib",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := newRecordingLogger()
+			Init(tt.cfg, log)
+
+			entries := *log.entries
+			if len(entries) != 1 {
+				t.Fatalf("logged %d entries, want 1", len(entries))
+			}
+			entry := entries[0]
+			if entry.level != tt.wantLevel || !strings.HasPrefix(entry.msg, tt.wantMessage) {
+				t.Errorf("logged %s %q, want %s %q", entry.level, entry.msg, tt.wantLevel, tt.wantMessage)
+			}
+			if _, ok := entry.fields["kdf_bench"]; !ok {
+				t.Error("entry has no kdf_bench field")
+			}
+			if _, ok := entry.fields[tt.wantField]; !ok {
+				t.Errorf("entry has no %s field", tt.wantField)
+			}
+			_, hasMax := entry.fields["max_kdf_duration"]
+			if hasMax != (tt.wantLevel == "warn") {
+				t.Errorf("max_kdf_duration logged = %v on %s", hasMax, tt.wantLevel)
+			}
+		})
+	}
+}
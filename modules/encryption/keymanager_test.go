@@ -0,0 +1,106 @@
+package encryption
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func TestStaticKeyManager(t *testing.T) {
+	m := NewStaticKeyManager("v1", []byte("key"))
+	if id, key, err := m.CurrentKey(); err != nil || id != "v1" || string(key) != "key" {
+		t.Errorf("CurrentKey = %q, %q, %v", id, key, err)
+	}
+	if key, err := m.GetKey("v1"); err != nil || string(key) != "key" {
+		t.Errorf("GetKey(v1) = %q, %v", key, err)
+	}
+	if _, err := m.GetKey("v2"); !errors.Is(err, ErrUnknownKeyVersion) {
+		t.Errorf("GetKey(v2) error = %v, want ErrUnknownKeyVersion", err)
+	}
+
+	// The default manager mixes in no key
+	none := NewStaticKeyManager("", nil)
+	if key, err := none.GetKey(""); err != nil || key != nil {
+		t.Errorf("GetKey(\"\") = %q, %v, want no key", key, err)
+	}
+}
+
+func TestRotatingKeyManager(t *testing.T) {
+	keys := map[string][]byte{"v1": []byte("old"), "v2": []byte("new")}
+	m, err := NewRotatingKeyManager(keys, "v2")
+	if err != nil {
+		t.Fatalf("NewRotatingKeyManager: %v", err)
+	}
+	if id, key, _ := m.CurrentKey(); id != "v2" || string(key) != "new" {
+		t.Errorf("CurrentKey = %q, %q, want v2", id, key)
+	}
+	// Old versions stay readable after the rotation
+	if key, err := m.GetKey("v1"); err != nil || string(key) != "old" {
+		t.Errorf("GetKey(v1) = %q, %v", key, err)
+	}
+	if _, err := m.GetKey("v3"); !errors.Is(err, ErrUnknownKeyVersion) {
+		t.Errorf("GetKey(v3) error = %v, want ErrUnknownKeyVersion", err)
+	}
+
+	if _, err := NewRotatingKeyManager(keys, "v3"); !errors.Is(err, ErrUnknownKeyVersion) {
+		t.Errorf("NewRotatingKeyManager with a missing current key error = %v, want ErrUnknownKeyVersion", err)
+	}
+}
+
+func TestKeyManagerFromEnv(t *testing.T) {
+	key1 := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+	key2 := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{2}, 48))
+	short := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{3}, 31))
+
+	tests := []struct {
+		name        string
+		env         map[string]string
+		wantCurrent string
+		wantErr     bool
+	}{
+		{"no keys", nil, "", false},
+		{"one key", map[string]string{"ENCRYPTION_KEY_V1": key1, "CURRENT_KEY_VERSION": "v1"}, "v1", false},
+		{"rotated", map[string]string{"ENCRYPTION_KEY_V1": key1, "ENCRYPTION_KEY_V2": key2, "CURRENT_KEY_VERSION": "V2"}, "v2", false},
+		{"other variables ignored", map[string]string{"ENCRYPTION_KEY_": key1, "ENCRYPTION_KEY_X1": "junk"}, "", false},
+		{"missing current version", map[string]string{"ENCRYPTION_KEY_V1": key1}, "", true},
+		{"unknown current version", map[string]string{"ENCRYPTION_KEY_V1": key1, "CURRENT_KEY_VERSION": "v2"}, "", true},
+		{"invalid base64", map[string]string{"ENCRYPTION_KEY_V1": "not base64!", "CURRENT_KEY_VERSION": "v1"}, "", true},
+		{"short key", map[string]string{"ENCRYPTION_KEY_V1": short, "CURRENT_KEY_VERSION": "v1"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CURRENT_KEY_VERSION", "")
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			m, err := KeyManagerFromEnv()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("KeyManagerFromEnv error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if id, _, _ := m.CurrentKey(); id != tt.wantCurrent {
+				t.Errorf("current version = %q, want %q", id, tt.wantCurrent)
+			}
+		})
+	}
+}
+
+func TestBindServerKey(t *testing.T) {
+	resourceKey := []byte("resource key")
+	if got := BindServerKey(resourceKey, nil); !bytes.Equal(got, resourceKey) {
+		t.Errorf("BindServerKey without a server key = %q, want the resource key", got)
+	}
+
+	a := BindServerKey(resourceKey, []byte("server key a"))
+	b := BindServerKey(resourceKey, []byte("server key b"))
+	if len(a) != 32 || bytes.Equal(a, resourceKey) || bytes.Equal(a, b) {
+		t.Errorf("bound keys %x and %x do not depend on the server key", a, b)
+	}
+	if again := BindServerKey(resourceKey, []byte("server key a")); !bytes.Equal(a, again) {
+		t.Error("BindServerKey is not deterministic")
+	}
+}
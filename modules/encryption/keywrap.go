@@ -0,0 +1,85 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+)
+
+// keyWrapIV is the default initial value of RFC 3394, checked on unwrap
+var keyWrapIV = [8]byte{0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6}
+
+var (
+	// ErrInvalidKeyToWrap is returned for keys that are not a multiple of 8 bytes or shorter than 16
+	ErrInvalidKeyToWrap = errors.New("key to wrap must be a multiple of 8 bytes and at least 16 bytes")
+	// ErrKeyUnwrapFailed is returned when a wrapped key fails the integrity check:
+	// it was tampered with or wrapped under another wrapping key
+	ErrKeyUnwrapFailed = errors.New("key unwrap failed")
+)
+
+// WrapKey wraps keyToWrap with AES Key Wrap (RFC 3394). The wrapping key must be
+// 16, 24 or 32 bytes, the result is 8 bytes longer than keyToWrap.
+func WrapKey(keyToWrap []byte, wrappingKey []byte) ([]byte, error) {
+	if len(keyToWrap) < 16 || len(keyToWrap)%8 != 0 {
+		return nil, ErrInvalidKeyToWrap
+	}
+	block, err := aes.NewCipher(wrappingKey)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(keyToWrap) / 8
+	out := make([]byte, 8+len(keyToWrap))
+	copy(out[:8], keyWrapIV[:])
+	copy(out[8:], keyToWrap)
+
+	var buf [16]byte
+	for j := 0; j < 6; j++ {
+		for i := 1; i <= n; i++ {
+			copy(buf[:8], out[:8])
+			copy(buf[8:], out[i*8:i*8+8])
+			block.Encrypt(buf[:], buf[:])
+
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(out[:8], binary.BigEndian.Uint64(buf[:8])^t)
+			copy(out[i*8:i*8+8], buf[8:])
+		}
+	}
+	return out, nil
+}
+
+// UnwrapKey reverses WrapKey, returning ErrKeyUnwrapFailed when the integrity check fails
+func UnwrapKey(wrappedKey []byte, wrappingKey []byte) ([]byte, error) {
+	if len(wrappedKey) < 24 || len(wrappedKey)%8 != 0 {
+		return nil, ErrKeyUnwrapFailed
+	}
+	block, err := aes.NewCipher(wrappingKey)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(wrappedKey)/8 - 1
+	var a [8]byte
+	copy(a[:], wrappedKey[:8])
+	key := make([]byte, len(wrappedKey)-8)
+	copy(key, wrappedKey[8:])
+
+	var buf [16]byte
+	for j := 5; j >= 0; j-- {
+		for i := n; i >= 1; i-- {
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(buf[:8], binary.BigEndian.Uint64(a[:])^t)
+			copy(buf[8:], key[(i-1)*8:i*8])
+			block.Decrypt(buf[:], buf[:])
+
+			copy(a[:], buf[:8])
+			copy(key[(i-1)*8:i*8], buf[8:])
+		}
+	}
+
+	if subtle.ConstantTimeCompare(a[:], keyWrapIV[:]) != 1 {
+		return nil, ErrKeyUnwrapFailed
+	}
+	return key, nil
+}
@@ -0,0 +1,111 @@
+package encryption
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("hex %q: %v", s, err)
+	}
+	return b
+}
+
+func TestWrapKeyRFC3394Vectors(t *testing.T) {
+	const (
+		kek128 = "000102030405060708090A0B0C0D0E0F"
+		kek192 = "000102030405060708090A0B0C0D0E0F1011121314151617"
+		kek256 = "000102030405060708090A0B0C0D0E0F101112131415161718191A1B1C1D1E1F"
+		key128 = "00112233445566778899AABBCCDDEEFF"
+		key192 = "00112233445566778899AABBCCDDEEFF0001020304050607"
+		key256 = "00112233445566778899AABBCCDDEEFF000102030405060708090A0B0C0D0E0F"
+	)
+	tests := []struct {
+		name    string
+		kek     string
+		key     string
+		wrapped string
+	}{
+		{"4.1 128-bit key with 128-bit KEK", kek128, key128, "1FA68B0A8112B447AEF34BD8FB5A7B829D3E862371D2CFE5"},
+		{"4.2 128-bit key with 192-bit KEK", kek192, key128, "96778B25AE6CA435F92B5B97C050AED2468AB8A17AD84E5D"},
+		{"4.3 128-bit key with 256-bit KEK", kek256, key128, "64E8C3F9CE0F5BA263E9777905818A2A93C8191E7D6E8AE7"},
+		{"4.4 192-bit key with 192-bit KEK", kek192, key192, "031D33264E15D33268F24EC260743EDCE1C6C7DDEE725A936BA814915C6762D2"},
+		{"4.5 192-bit key with 256-bit KEK", kek256, key192, "A8F9BC1612C68B3FF6E6F4FBE30E71E4769C8B80A32CB8958CD5D17D6B254DA1"},
+		{"4.6 256-bit key with 256-bit KEK", kek256, key256, "28C9F404C4B810F4CBCCB35CFB87F8263F5786E2D80ED326CBC7F0E71A99F43BFB988B9B7A02DD21"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kek, key, want := mustHex(t, tt.kek), mustHex(t, tt.key), mustHex(t, tt.wrapped)
+
+			wrapped, err := WrapKey(key, kek)
+			if err != nil || !bytes.Equal(wrapped, want) {
+				t.Fatalf("WrapKey = %X, %v, want %X", wrapped, err, want)
+			}
+			unwrapped, err := UnwrapKey(wrapped, kek)
+			if err != nil || !bytes.Equal(unwrapped, key) {
+				t.Errorf("UnwrapKey = %X, %v, want %X", unwrapped, err, key)
+			}
+		})
+	}
+}
+
+func TestWrapKeyErrors(t *testing.T) {
+	kek := bytes.Repeat([]byte{1}, 32)
+	tests := []struct {
+		name    string
+		key     []byte
+		kek     []byte
+		wantErr error
+	}{
+		{"empty key", nil, kek, ErrInvalidKeyToWrap},
+		{"8-byte key", make([]byte, 8), kek, ErrInvalidKeyToWrap},
+		{"not a multiple of 8", make([]byte, 20), kek, ErrInvalidKeyToWrap},
+		{"bad wrapping key", make([]byte, 16), make([]byte, 10), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := WrapKey(tt.key, tt.kek)
+			if err == nil {
+				t.Fatal("WrapKey succeeded")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("WrapKey error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUnwrapKeyRejects(t *testing.T) {
+	kek := mustHex(t, "000102030405060708090A0B0C0D0E0F")
+	wrapped := mustHex(t, "1FA68B0A8112B447AEF34BD8FB5A7B829D3E862371D2CFE5")
+
+	tampered := bytes.Clone(wrapped)
+	tampered[len(tampered)-1] ^= 1
+	tamperedIV := bytes.Clone(wrapped)
+	tamperedIV[0] ^= 1
+
+	tests := []struct {
+		name    string
+		wrapped []byte
+		kek     []byte
+	}{
+		{"tampered key", tampered, kek},
+		{"tampered integrity value", tamperedIV, kek},
+		{"other wrapping key", wrapped, bytes.Repeat([]byte{7}, 16)},
+		{"truncated", wrapped[:16], kek},
+		{"not a multiple of 8", wrapped[:23], kek},
+		{"empty", nil, kek},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if key, err := UnwrapKey(tt.wrapped, tt.kek); !errors.Is(err, ErrKeyUnwrapFailed) {
+				t.Errorf("UnwrapKey = %X, %v, want ErrKeyUnwrapFailed", key, err)
+			}
+		})
+	}
+}
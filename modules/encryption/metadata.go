@@ -0,0 +1,48 @@
+package encryption
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+)
+
+// metadataKeyLabel domain-separates the metadata key from the other uses of the URL key
+const metadataKeyLabel = "lovebin metadata"
+
+// metadataKey derives the AES-256 key of EncryptMetadata from the URL key
+func metadataKey(key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(metadataKeyLabel))
+	return mac.Sum(nil)
+}
+
+// EncryptMetadata seals resource metadata, e.g. the filename, with AES-GCM under a
+// key derived from the URL key, whatever cipher the content uses. It returns nonce || ciphertext.
+func EncryptMetadata(data []byte, key []byte) ([]byte, error) {
+	return sealGCM(metadataKey(key), data)
+}
+
+// DecryptMetadata opens data sealed by EncryptMetadata, a wrong key fails authentication
+func DecryptMetadata(data []byte, key []byte) ([]byte, error) {
+	return openGCM(metadataKey(key), data)
+}
+
+// manifestKeyLabel domain-separates the manifest key from the metadata key
+const manifestKeyLabel = "lovebin manifest"
+
+// manifestKey derives the AES-256 key of EncryptManifest from the URL key
+func manifestKey(key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(manifestKeyLabel))
+	return mac.Sum(nil)
+}
+
+// EncryptManifest seals a resource manifest with AES-GCM under a key derived from
+// the URL key, like EncryptMetadata. It returns nonce || ciphertext.
+func EncryptManifest(data []byte, key []byte) ([]byte, error) {
+	return sealGCM(manifestKey(key), data)
+}
+
+// DecryptManifest opens data sealed by EncryptManifest, a wrong key fails authentication
+func DecryptManifest(data []byte, key []byte) ([]byte, error) {
+	return openGCM(manifestKey(key), data)
+}
@@ -0,0 +1,39 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+)
+
+func TestEncryptMetadata(t *testing.T) {
+	key := sha256.Sum256([]byte("url key"))
+	other := sha256.Sum256([]byte("other key"))
+	plaintext := []byte("holiday.jpg|jpg")
+
+	sealed, err := EncryptMetadata(plaintext, key[:])
+	if err != nil {
+		t.Fatalf("EncryptMetadata: %v", err)
+	}
+	if bytes.Contains(sealed, []byte("holiday")) {
+		t.Error("sealed metadata contains the plaintext")
+	}
+	again, _ := EncryptMetadata(plaintext, key[:])
+	if bytes.Equal(sealed, again) {
+		t.Error("sealing twice gave the same ciphertext")
+	}
+
+	if got, err := DecryptMetadata(sealed, key[:]); err != nil || !bytes.Equal(got, plaintext) {
+		t.Errorf("DecryptMetadata = %q, %v, want %q", got, err, plaintext)
+	}
+	if _, err := DecryptMetadata(sealed, other[:]); err == nil {
+		t.Error("DecryptMetadata with a wrong key succeeded")
+	}
+	if _, err := DecryptMetadata(sealed[:len(sealed)-1], key[:]); err == nil {
+		t.Error("DecryptMetadata of truncated data succeeded")
+	}
+	// The manifest key is derived from the same URL key but differs
+	if _, err := DecryptManifest(sealed, key[:]); err == nil {
+		t.Error("DecryptManifest opened sealed metadata")
+	}
+}
@@ -0,0 +1,140 @@
+package encryption
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Algorithm selects the key derivation function of password resources
+type Algorithm string
+
+const (
+	// AlgorithmPBKDF2 is PBKDF2-HMAC-SHA256 with Config.Iterations, the derivation
+	// of every resource created before salts recorded their algorithm
+	AlgorithmPBKDF2 Algorithm = "pbkdf2"
+	// AlgorithmScrypt is memory-hard scrypt with Config.Scrypt
+	AlgorithmScrypt Algorithm = "scrypt"
+	// AlgorithmArgon2id is memory-hard Argon2id with Config.Argon2id (default)
+	AlgorithmArgon2id Algorithm = "argon2id"
+)
+
+// ScryptParams are the cost parameters of scrypt. Memory use is 128 * N * R bytes.
+// N=32768 (32 MiB with R=8) suits interactive use such as opening a resource in the
+// browser; N=1048576 (1 GiB) suits bulk or offline use where a derivation may take seconds.
+type ScryptParams struct {
+	N int // CPU and memory cost, a power of two (default 32768)
+	R int // block size (default 8)
+	P int // parallelization (default 1)
+}
+
+// DefaultScryptParams are the interactive parameters used where Config.Scrypt leaves fields unset
+var DefaultScryptParams = ScryptParams{N: 1 << 15, R: 8, P: 1}
+
+// withDefaults fills unset fields with DefaultScryptParams
+func (p ScryptParams) withDefaults() ScryptParams {
+	if p.N == 0 {
+		p.N = DefaultScryptParams.N
+	}
+	if p.R == 0 {
+		p.R = DefaultScryptParams.R
+	}
+	if p.P == 0 {
+		p.P = DefaultScryptParams.P
+	}
+	return p
+}
+
+// Validate reports parameters scrypt rejects or the salt prefix cannot record.
+// Unset fields are taken as their defaults.
+func (p ScryptParams) Validate() error {
+	p = p.withDefaults()
+	if p.N < 2 || p.N&(p.N-1) != 0 {
+		return fmt.Errorf("scrypt N must be a power of two greater than 1, got %d", p.N)
+	}
+	if p.R < 1 || p.R > 255 || p.P < 1 || p.P > 255 {
+		return fmt.Errorf("scrypt R and P must be from 1 to 255, got %d and %d", p.R, p.P)
+	}
+	if uint64(p.R)*uint64(p.P) >= 1<<30 {
+		return fmt.Errorf("scrypt R*P must be below 2^30, got %d", p.R*p.P)
+	}
+	return nil
+}
+
+const (
+	// pbkdf2SaltSize is the length of the random part of every password salt. Salts
+	// of exactly this length predate the salt prefix and are derived with PBKDF2.
+	pbkdf2SaltSize = 16
+
+	// SaltModeKDF starts salts recording their key derivation function: the mode,
+	// an algorithm tag, the parameters of the algorithm and the random salt. The
+	// lengths differ from SaltModeFast and from plain PBKDF2 salts.
+	SaltModeKDF byte = 0x02
+
+	// kdfTagScrypt is followed by log2(N), R and P, one byte each
+	kdfTagScrypt byte = 0x01
+	// kdfTagArgon2id is followed by the time (1 byte), the memory in KiB (4 bytes,
+	// big endian) and the threads (1 byte)
+	kdfTagArgon2id byte = 0x02
+
+	// argon2idSaltSize is the length of Argon2id salts with their prefix
+	argon2idSaltSize = 8 + pbkdf2SaltSize
+)
+
+// newSalt returns a random salt, prefixed with the algorithm and its parameters
+// unless the algorithm is PBKDF2
+func (e *encryptionImpl) newSalt() ([]byte, error) {
+	random := make([]byte, pbkdf2SaltSize)
+	if _, err := io.ReadFull(rand.Reader, random); err != nil {
+		return nil, err
+	}
+
+	switch e.algorithm {
+	case AlgorithmScrypt:
+		prefix := []byte{SaltModeKDF, kdfTagScrypt, byte(bits.TrailingZeros(uint(e.scrypt.N))), byte(e.scrypt.R), byte(e.scrypt.P)}
+		return append(prefix, random...), nil
+	case AlgorithmArgon2id:
+		prefix := []byte{SaltModeKDF, kdfTagArgon2id, byte(e.argon2id.Time)}
+		prefix = binary.BigEndian.AppendUint32(prefix, uint32(e.argon2id.Memory))
+		prefix = append(prefix, byte(e.argon2id.Threads))
+		return append(prefix, random...), nil
+	default:
+		return random, nil
+	}
+}
+
+// isArgon2idSalt reports whether salt is derived with Argon2id
+func isArgon2idSalt(salt []byte) bool {
+	return len(salt) == argon2idSaltSize && salt[0] == SaltModeKDF && salt[1] == kdfTagArgon2id
+}
+
+// deriveKey derives a size-byte key with the algorithm and parameters recorded in salt,
+// so resources keep decrypting after the configured algorithm changes
+func (e *encryptionImpl) deriveKey(password string, salt []byte, size int) ([]byte, error) {
+	if len(salt) == pbkdf2SaltSize || len(salt) < 2 || salt[0] != SaltModeKDF {
+		return pbkdf2.Key([]byte(password), salt, e.iterations, size, sha256.New), nil
+	}
+
+	switch salt[1] {
+	case kdfTagScrypt:
+		if len(salt) != 5+pbkdf2SaltSize || salt[2] < 1 || salt[2] > 62 {
+			return nil, errors.New("invalid scrypt salt")
+		}
+		return scrypt.Key([]byte(password), salt, 1<<salt[2], int(salt[3]), int(salt[4]), size)
+	case kdfTagArgon2id:
+		if !isArgon2idSalt(salt) || salt[2] == 0 || salt[7] == 0 {
+			return nil, errors.New("invalid argon2id salt")
+		}
+		return argon2.IDKey([]byte(password), salt, uint32(salt[2]), binary.BigEndian.Uint32(salt[3:7]), salt[7], uint32(size)), nil
+	default:
+		return nil, fmt.Errorf("unknown key derivation tag 0x%02x", salt[1])
+	}
+}
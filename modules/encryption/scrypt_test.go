@@ -0,0 +1,111 @@
+package encryption
+
+import (
+	"bytes"
+	"testing"
+)
+
+// testScrypt keeps tests quick, the parameters are not meant to be secure
+var testScrypt = ScryptParams{N: 16, R: 8, P: 1}
+
+func TestScryptParamsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  ScryptParams
+		wantErr bool
+	}{
+		{"defaults", ScryptParams{}, false},
+		{"interactive", DefaultScryptParams, false},
+		{"bulk", ScryptParams{N: 1 << 20, R: 8, P: 1}, false},
+		{"N not a power of two", ScryptParams{N: 1000}, true},
+		{"N of one", ScryptParams{N: 1}, true},
+		{"negative N", ScryptParams{N: -16}, true},
+		{"R above a byte", ScryptParams{R: 256}, true},
+		{"negative P", ScryptParams{P: -1}, true},
+		{"P above a byte", ScryptParams{P: 256}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.params.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestScryptSalt(t *testing.T) {
+	e := Init(Config{Algorithm: AlgorithmScrypt, Scrypt: testScrypt}, nil)
+	ciphertext, salt, err := e.Encrypt([]byte("data"), "password")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	// Mode, tag, log2(N), R and P precede the random salt
+	if want := []byte{SaltModeKDF, kdfTagScrypt, 4, 8, 1}; len(salt) != 5+pbkdf2SaltSize || !bytes.Equal(salt[:5], want) {
+		t.Errorf("salt = %x, want prefix %x", salt, want)
+	}
+	if got, err := e.Decrypt(ciphertext, salt, "password"); err != nil || string(got) != "data" {
+		t.Errorf("Decrypt = %q, %v", got, err)
+	}
+}
+
+func TestDecryptFollowsTheSaltAlgorithm(t *testing.T) {
+	configs := map[Algorithm]Config{
+		AlgorithmPBKDF2:   {Algorithm: AlgorithmPBKDF2, Iterations: 1000},
+		AlgorithmScrypt:   {Algorithm: AlgorithmScrypt, Scrypt: testScrypt},
+		AlgorithmArgon2id: {Algorithm: AlgorithmArgon2id, Argon2id: testArgon2id},
+	}
+	for encryptedWith, cfg := range configs {
+		ciphertext, salt, err := Init(cfg, nil).Encrypt([]byte("data"), "password")
+		if err != nil {
+			t.Fatalf("Encrypt with %s: %v", encryptedWith, err)
+		}
+		// Resources keep decrypting after the configured algorithm changes
+		for decryptedWith, other := range configs {
+			other.Iterations = 1000
+			got, err := Init(other, nil).Decrypt(ciphertext, salt, "password")
+			if err != nil || string(got) != "data" {
+				t.Errorf("%s resource decrypted with %s configured = %q, %v", encryptedWith, decryptedWith, got, err)
+			}
+		}
+	}
+}
+
+func TestDeriveKeyInvalidSalt(t *testing.T) {
+	e := Init(Config{Iterations: 1000}, nil).(*encryptionImpl)
+	random := bytes.Repeat([]byte{0xab}, pbkdf2SaltSize)
+
+	tests := []struct {
+		name string
+		salt []byte
+	}{
+		{"scrypt too short", append([]byte{SaltModeKDF, kdfTagScrypt, 4, 8}, random...)},
+		{"scrypt N of one", append([]byte{SaltModeKDF, kdfTagScrypt, 0, 8, 1}, random...)},
+		{"scrypt N too large", append([]byte{SaltModeKDF, kdfTagScrypt, 63, 8, 1}, random...)},
+		{"scrypt R of zero", append([]byte{SaltModeKDF, kdfTagScrypt, 4, 0, 1}, random...)},
+		{"unknown tag", append([]byte{SaltModeKDF, 0x7f, 4, 8, 1}, random...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := e.deriveKey("password", tt.salt, 32); err == nil {
+				t.Error("deriveKey succeeded")
+			}
+		})
+	}
+}
+
+func TestSelfTest(t *testing.T) {
+	for _, c := range []Cipher{CipherAESGCM, CipherAESSIV} {
+		for _, cfg := range []Config{
+			{Algorithm: AlgorithmPBKDF2, Iterations: 1000},
+			{Algorithm: AlgorithmScrypt, Scrypt: testScrypt},
+			{Algorithm: AlgorithmArgon2id, Argon2id: testArgon2id},
+		} {
+			cfg.Cipher = c
+			t.Run(string(c)+"/"+string(cfg.Algorithm), func(t *testing.T) {
+				if err := Init(cfg, nil).SelfTest(); err != nil {
+					t.Errorf("SelfTest: %v", err)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package encryption
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+)
+
+// SignURL computes HMAC-SHA256(resourceKey + expiresAt + encKeyBase64, secret) as hex.
+// Signed URLs never carry the encryption key, but the signature only holds
+// together with the key it was issued for.
+func SignURL(secret, resourceKey string, expiresAt int64, encKeyBase64 string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(resourceKey + strconv.FormatInt(expiresAt, 10) + encKeyBase64))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyURLSignature checks a signature produced by SignURL in constant time
+func VerifyURLSignature(secret, resourceKey string, expiresAt int64, encKeyBase64, signature string) bool {
+	expected, err := hex.DecodeString(SignURL(secret, resourceKey, expiresAt, encKeyBase64))
+	if err != nil {
+		return false
+	}
+	actual, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(expected, actual)
+}
@@ -0,0 +1,76 @@
+package encryption
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestVerifyURLSignature(t *testing.T) {
+	const secret = "signing-secret"
+	const resourceKey = "abc123"
+	const expiresAt = int64(1767225600)
+	const encKey = "c2VjcmV0LWtleQ"
+	signature := SignURL(secret, resourceKey, expiresAt, encKey)
+
+	tests := []struct {
+		name        string
+		secret      string
+		resourceKey string
+		expiresAt   int64
+		encKey      string
+		signature   string
+		want        bool
+	}{
+		{"valid", secret, resourceKey, expiresAt, encKey, signature, true},
+		{"valid uppercase hex", secret, resourceKey, expiresAt, encKey, strings.ToUpper(signature), true},
+		{"other resource", secret, "abc124", expiresAt, encKey, signature, false},
+		{"extended expiry", secret, resourceKey, expiresAt + 3600, encKey, signature, false},
+		{"other encryption key", secret, resourceKey, expiresAt, "b3RoZXIta2V5", signature, false},
+		{"no encryption key", secret, resourceKey, expiresAt, "", signature, false},
+		{"wrong secret", "other-secret", resourceKey, expiresAt, encKey, signature, false},
+		{"tampered signature", secret, resourceKey, expiresAt, encKey, flipLastHexDigit(signature), false},
+		{"truncated signature", secret, resourceKey, expiresAt, encKey, signature[:len(signature)-2], false},
+		{"not hex", secret, resourceKey, expiresAt, encKey, strings.Repeat("zz", 32), false},
+		{"empty", secret, resourceKey, expiresAt, encKey, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifyURLSignature(tt.secret, tt.resourceKey, tt.expiresAt, tt.encKey, tt.signature); got != tt.want {
+				t.Errorf("VerifyURLSignature() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignURLIsDeterministic(t *testing.T) {
+	a := SignURL("secret", "key", 100, "enc")
+	b := SignURL("secret", "key", 100, "enc")
+	if a != b {
+		t.Errorf("signatures differ: %s, %s", a, b)
+	}
+	if len(a) != 64 {
+		t.Errorf("signature length = %d, want 64 hex digits", len(a))
+	}
+}
+
+func TestSignURLInput(t *testing.T) {
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte("abc123" + "1767225600" + "c2VjcmV0LWtleQ"))
+	want := hex.EncodeToString(mac.Sum(nil))
+
+	if got := SignURL("secret", "abc123", 1767225600, "c2VjcmV0LWtleQ"); got != want {
+		t.Errorf("SignURL() = %s, want HMAC-SHA256(resourceKey + expiresAt + encKeyBase64) %s", got, want)
+	}
+}
+
+func flipLastHexDigit(s string) string {
+	last := s[len(s)-1]
+	replacement := byte('0')
+	if last == '0' {
+		replacement = '1'
+	}
+	return s[:len(s)-1] + string(replacement)
+}
@@ -0,0 +1,26 @@
+package encryption
+
+import (
+	"github.com/miscreant/miscreant.go"
+)
+
+// sivKeySize is the AES-CMAC-SIV key size for AES-256 (two 256-bit keys)
+const sivKeySize = 64
+
+// sealSIV encrypts data deterministically with AES-256-SIV
+func sealSIV(key []byte, data []byte) ([]byte, error) {
+	c, err := miscreant.NewAESCMACSIV(key)
+	if err != nil {
+		return nil, err
+	}
+	return c.Seal(nil, data)
+}
+
+// openSIV decrypts and authenticates data sealed by sealSIV
+func openSIV(key []byte, ciphertext []byte) ([]byte, error) {
+	c, err := miscreant.NewAESCMACSIV(key)
+	if err != nil {
+		return nil, err
+	}
+	return c.Open(nil, ciphertext)
+}
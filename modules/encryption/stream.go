@@ -0,0 +1,319 @@
+package encryption
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Streamed data is AES-256-GCM in chunks of StreamChunkSize plaintext bytes:
+//
+//	nonce prefix (7 bytes) || frame...
+//	frame = sealed chunk length (4 bytes, big endian) || sealed chunk
+//
+// The nonce of chunk i is prefix || i (4 bytes, big endian) || final flag (1 byte),
+// so chunks cannot be reordered, dropped or cut off without failing authentication.
+// All chunks but the last are full; empty data is one empty final chunk.
+
+const (
+	// StreamChunkSize is the plaintext size of every chunk but the last
+	StreamChunkSize = 64 << 10
+
+	streamPrefixSize = 7
+	streamFrameSize  = 4  // length of a sealed chunk
+	streamTagSize    = 16 // GCM tag of each chunk
+)
+
+const (
+	// SaltModeFastStream is the whole salt of data sealed with FastEncryptStream,
+	// like SaltModeFast for FastEncrypt
+	SaltModeFastStream byte = 0x03
+
+	// SaltModeStream starts salts of data sealed with EncryptStream, followed by
+	// the salt of the key derivation as made for Encrypt
+	SaltModeStream byte = 0x04
+)
+
+// ErrStreamCorrupted is returned while reading streamed data that was cut off,
+// reordered or altered, or decrypted with a wrong key
+var ErrStreamCorrupted = errors.New("encrypted stream is corrupted")
+
+// FastStreamSalt returns the salt value recorded for FastEncryptStream data
+func FastStreamSalt() []byte {
+	return []byte{SaltModeFastStream}
+}
+
+// IsFastStreamSalt reports whether salt marks data sealed with FastEncryptStream
+func IsFastStreamSalt(salt []byte) bool {
+	return len(salt) == 1 && salt[0] == SaltModeFastStream
+}
+
+// IsStreamSalt reports whether salt marks data sealed with EncryptStream
+func IsStreamSalt(salt []byte) bool {
+	return len(salt) > 1 && salt[0] == SaltModeStream
+}
+
+// StreamEncryptedSize returns the length of size plaintext bytes once streamed
+func StreamEncryptedSize(size int64) int64 {
+	chunks := (size + StreamChunkSize - 1) / StreamChunkSize
+	if chunks == 0 {
+		chunks = 1
+	}
+	return streamPrefixSize + chunks*(streamFrameSize+streamTagSize) + size
+}
+
+// StreamPlaintextSize reverses StreamEncryptedSize, failing with ErrStreamCorrupted
+// when no plaintext size streams to encryptedSize bytes
+func StreamPlaintextSize(encryptedSize int64) (int64, error) {
+	const frameOverhead = streamFrameSize + streamTagSize
+	body := encryptedSize - streamPrefixSize
+	if body < frameOverhead {
+		return 0, ErrStreamCorrupted
+	}
+	chunks := (body + StreamChunkSize + frameOverhead - 1) / (StreamChunkSize + frameOverhead)
+	size := body - chunks*frameOverhead
+	if size < 0 || StreamEncryptedSize(size) != encryptedSize {
+		return 0, ErrStreamCorrupted
+	}
+	return size, nil
+}
+
+// EncryptStream encrypts r chunk by chunk under a key derived from password like
+// Encrypt, always with AES-GCM whatever Config.Cipher is. It returns the reader
+// of the encrypted data and the salt, which marks streamed data for DecryptStream.
+// Close closes r when it is an io.Closer.
+func (e *encryptionImpl) EncryptStream(r io.Reader, password string) (io.ReadCloser, []byte, error) {
+	salt, err := e.newSalt()
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := e.deriveKey(password, salt, 32)
+	if err != nil {
+		return nil, nil, err
+	}
+	sealer, err := newStreamSealer(r, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sealer, append([]byte{SaltModeStream}, salt...), nil
+}
+
+// DecryptStream decrypts data sealed by EncryptStream as it is read, failing with
+// ErrStreamCorrupted on a wrong password. Close closes r when it is an io.Closer.
+func (e *encryptionImpl) DecryptStream(r io.Reader, salt []byte, password string) (io.ReadCloser, error) {
+	if !IsStreamSalt(salt) {
+		return nil, errors.New("salt does not mark streamed data")
+	}
+	key, err := e.deriveKey(password, salt[1:], 32)
+	if err != nil {
+		return nil, err
+	}
+	return newStreamOpener(r, key)
+}
+
+// FastEncryptStream is EncryptStream keyed like FastEncrypt with AES-GCM, its
+// salt is FastStreamSalt
+func (e *encryptionImpl) FastEncryptStream(r io.Reader, key []byte) (io.ReadCloser, error) {
+	sum := sha256.Sum256(key)
+	return newStreamSealer(r, sum[:])
+}
+
+// FastDecryptStream decrypts data sealed by FastEncryptStream as it is read
+func (e *encryptionImpl) FastDecryptStream(r io.Reader, key []byte) (io.ReadCloser, error) {
+	sum := sha256.Sum256(key)
+	return newStreamOpener(r, sum[:])
+}
+
+// streamNonce returns the nonce of chunk counter
+func streamNonce(prefix []byte, counter uint32, final bool) []byte {
+	nonce := make([]byte, streamPrefixSize+5)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[streamPrefixSize:], counter)
+	if final {
+		nonce[len(nonce)-1] = 1
+	}
+	return nonce
+}
+
+// streamReader holds what sealing and opening readers share: the source, buffered
+// to look ahead for its end, and the frames or chunks ready to be read
+type streamReader struct {
+	src     io.Reader
+	in      *bufio.Reader
+	gcm     cipher.AEAD
+	prefix  []byte
+	counter uint32
+	done    bool   // the final chunk was handled
+	out     []byte // ready bytes not read yet
+	err     error  // sticky error, returned once out is drained
+}
+
+func newStreamReader(r io.Reader, key []byte) (*streamReader, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &streamReader{src: r, in: bufio.NewReaderSize(r, StreamChunkSize+streamFrameSize+streamTagSize), gcm: gcm}, nil
+}
+
+// atEOF reports whether the source has no more bytes
+func (s *streamReader) atEOF() (bool, error) {
+	_, err := s.in.Peek(1)
+	if err == io.EOF {
+		return true, nil
+	}
+	return false, err
+}
+
+// read drains out, calling next to refill it until the final chunk or an error
+func (s *streamReader) read(p []byte, next func() error) (int, error) {
+	for len(s.out) == 0 {
+		if s.err != nil {
+			return 0, s.err
+		}
+		if s.done {
+			return 0, io.EOF
+		}
+		if s.counter == math.MaxUint32 {
+			s.err = errors.New("encrypted stream is too long")
+			continue
+		}
+		s.err = next()
+	}
+	n := copy(p, s.out)
+	s.out = s.out[n:]
+	return n, nil
+}
+
+// Close closes the source when it is an io.Closer
+func (s *streamReader) Close() error {
+	if closer, ok := s.src.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// streamSealer encrypts its source as it is read
+type streamSealer struct {
+	*streamReader
+	chunk []byte
+	frame []byte // reused for every frame, out is drained before the next one
+}
+
+func newStreamSealer(r io.Reader, key []byte) (*streamSealer, error) {
+	sr, err := newStreamReader(r, key)
+	if err != nil {
+		return nil, err
+	}
+	sr.prefix = make([]byte, streamPrefixSize)
+	if _, err := io.ReadFull(rand.Reader, sr.prefix); err != nil {
+		return nil, err
+	}
+	sr.out = append([]byte(nil), sr.prefix...)
+	return &streamSealer{
+		streamReader: sr,
+		chunk:        make([]byte, StreamChunkSize),
+		frame:        make([]byte, 0, streamFrameSize+StreamChunkSize+streamTagSize),
+	}, nil
+}
+
+func (s *streamSealer) Read(p []byte) (int, error) {
+	return s.read(p, s.sealNext)
+}
+
+// sealNext seals the next chunk into a frame, it is final when the source ends with it
+func (s *streamSealer) sealNext() error {
+	n, err := io.ReadFull(s.in, s.chunk)
+	final := err == io.EOF || err == io.ErrUnexpectedEOF
+	if err != nil && !final {
+		return err
+	}
+	if !final {
+		if final, err = s.atEOF(); err != nil {
+			return err
+		}
+	}
+
+	frame := s.frame[:streamFrameSize]
+	binary.BigEndian.PutUint32(frame, uint32(n+streamTagSize))
+	s.out = s.gcm.Seal(frame, streamNonce(s.prefix, s.counter, final), s.chunk[:n], nil)
+	s.counter++
+	s.done = final
+	return nil
+}
+
+// streamOpener decrypts and authenticates its source as it is read
+type streamOpener struct {
+	*streamReader
+	frame []byte
+}
+
+func newStreamOpener(r io.Reader, key []byte) (*streamOpener, error) {
+	sr, err := newStreamReader(r, key)
+	if err != nil {
+		return nil, err
+	}
+	return &streamOpener{streamReader: sr, frame: make([]byte, StreamChunkSize+streamTagSize)}, nil
+}
+
+func (s *streamOpener) Read(p []byte) (int, error) {
+	return s.read(p, s.openNext)
+}
+
+// openNext opens the next frame, it must be final exactly when the source ends with it
+func (s *streamOpener) openNext() error {
+	if s.prefix == nil {
+		s.prefix = make([]byte, streamPrefixSize)
+		if _, err := io.ReadFull(s.in, s.prefix); err != nil {
+			return streamReadError(err)
+		}
+	}
+
+	var length [streamFrameSize]byte
+	if _, err := io.ReadFull(s.in, length[:]); err != nil {
+		return streamReadError(err)
+	}
+	size := int(binary.BigEndian.Uint32(length[:]))
+	if size < streamTagSize || size > len(s.frame) {
+		return ErrStreamCorrupted
+	}
+	if _, err := io.ReadFull(s.in, s.frame[:size]); err != nil {
+		return streamReadError(err)
+	}
+	final, err := s.atEOF()
+	if err != nil {
+		return err
+	}
+	// Only the last chunk may be short
+	if !final && size != len(s.frame) {
+		return ErrStreamCorrupted
+	}
+
+	plaintext, err := s.gcm.Open(s.frame[:0], streamNonce(s.prefix, s.counter, final), s.frame[:size], nil)
+	if err != nil {
+		return ErrStreamCorrupted
+	}
+	s.out = plaintext
+	s.counter++
+	s.done = final
+	return nil
+}
+
+// streamReadError reports a source ending inside the stream as corruption
+func streamReadError(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("%w: unexpected end of data", ErrStreamCorrupted)
+	}
+	return err
+}
@@ -0,0 +1,220 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"slices"
+	"strconv"
+	"testing"
+	"testing/iotest"
+)
+
+// streamAll seals plaintext with FastEncryptStream
+func streamAll(t *testing.T, e Encryption, plaintext, key []byte) []byte {
+	t.Helper()
+	sealer, err := e.FastEncryptStream(bytes.NewReader(plaintext), key)
+	if err != nil {
+		t.Fatalf("FastEncryptStream: %v", err)
+	}
+	sealed, err := io.ReadAll(sealer)
+	if err != nil {
+		t.Fatalf("read sealed stream: %v", err)
+	}
+	return sealed
+}
+
+// openAll reads everything FastDecryptStream opens from sealed
+func openAll(e Encryption, sealed, key []byte) ([]byte, error) {
+	opener, err := e.FastDecryptStream(bytes.NewReader(sealed), key)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(opener)
+}
+
+// streamFrames splits sealed into its nonce prefix and frames
+func streamFrames(t *testing.T, sealed []byte) ([]byte, [][]byte) {
+	t.Helper()
+	prefix, rest := sealed[:streamPrefixSize], sealed[streamPrefixSize:]
+	var frames [][]byte
+	for len(rest) > 0 {
+		size := streamFrameSize + int(binary.BigEndian.Uint32(rest))
+		if size > len(rest) {
+			t.Fatalf("frame of %d bytes in %d remaining", size, len(rest))
+		}
+		frames, rest = append(frames, rest[:size]), rest[size:]
+	}
+	return prefix, frames
+}
+
+func TestStreamRoundTrip(t *testing.T) {
+	e := newTestEncryption(CipherAESGCM)
+	key := sha256.Sum256([]byte("url key"))
+
+	for _, size := range []int{0, 1, StreamChunkSize - 1, StreamChunkSize, StreamChunkSize + 1, 3*StreamChunkSize + 5} {
+		plaintext := make([]byte, size)
+		if _, err := rand.Read(plaintext); err != nil {
+			t.Fatalf("rand.Read: %v", err)
+		}
+
+		t.Run("fast "+strconv.Itoa(size), func(t *testing.T) {
+			sealed := streamAll(t, e, plaintext, key[:])
+			if int64(len(sealed)) != StreamEncryptedSize(int64(size)) {
+				t.Errorf("sealed %d bytes into %d, StreamEncryptedSize = %d", size, len(sealed), StreamEncryptedSize(int64(size)))
+			}
+			got, err := openAll(e, sealed, key[:])
+			if err != nil || !bytes.Equal(got, plaintext) {
+				t.Fatalf("FastDecryptStream = %d bytes, %v, want %d bytes", len(got), err, size)
+			}
+		})
+
+		t.Run("password "+strconv.Itoa(size), func(t *testing.T) {
+			// One byte reads exercise the buffering of both ends
+			sealer, salt, err := e.EncryptStream(iotest.OneByteReader(bytes.NewReader(plaintext)), "password")
+			if err != nil {
+				t.Fatalf("EncryptStream: %v", err)
+			}
+			if !IsStreamSalt(salt) {
+				t.Errorf("salt %x does not mark streamed data", salt)
+			}
+			sealed, err := io.ReadAll(sealer)
+			if err != nil {
+				t.Fatalf("read sealed stream: %v", err)
+			}
+			opener, err := e.DecryptStream(iotest.OneByteReader(bytes.NewReader(sealed)), salt, "password")
+			if err != nil {
+				t.Fatalf("DecryptStream: %v", err)
+			}
+			got, err := io.ReadAll(opener)
+			if err != nil || !bytes.Equal(got, plaintext) {
+				t.Fatalf("DecryptStream = %d bytes, %v, want %d bytes", len(got), err, size)
+			}
+
+			opener, err = e.DecryptStream(bytes.NewReader(sealed), salt, "wrong")
+			if err != nil {
+				t.Fatalf("DecryptStream: %v", err)
+			}
+			if _, err := io.ReadAll(opener); !errors.Is(err, ErrStreamCorrupted) {
+				t.Errorf("DecryptStream with a wrong password = %v, want %v", err, ErrStreamCorrupted)
+			}
+		})
+	}
+}
+
+func TestStreamDetectsCorruption(t *testing.T) {
+	e := newTestEncryption(CipherAESGCM)
+	key := sha256.Sum256([]byte("url key"))
+	plaintext := make([]byte, 2*StreamChunkSize+100)
+	sealed := streamAll(t, e, plaintext, key[:])
+	prefix, frames := streamFrames(t, sealed)
+	if len(frames) != 3 {
+		t.Fatalf("sealed into %d frames, want 3", len(frames))
+	}
+	join := func(frames ...[]byte) []byte {
+		return slices.Concat(append([][]byte{prefix}, frames...)...)
+	}
+	flipped := bytes.Clone(sealed)
+	flipped[len(flipped)/2] ^= 1
+	otherKey := sha256.Sum256([]byte("other key"))
+
+	tests := []struct {
+		name   string
+		sealed []byte
+		key    []byte
+	}{
+		{"wrong key", sealed, otherKey[:]},
+		{"flipped byte", flipped, key[:]},
+		{"last chunk dropped", join(frames[0], frames[1]), key[:]},
+		{"middle chunk dropped", join(frames[0], frames[2]), key[:]},
+		{"chunks reordered", join(frames[1], frames[0], frames[2]), key[:]},
+		{"chunk repeated", join(frames[0], frames[0], frames[1], frames[2]), key[:]},
+		{"cut inside a chunk", sealed[:len(sealed)-1], key[:]},
+		{"cut inside a length", join(frames[0], frames[1][:2]), key[:]},
+		{"cut inside the prefix", sealed[:streamPrefixSize-1], key[:]},
+		{"bytes appended", append(bytes.Clone(sealed), 0), key[:]},
+		{"other prefix", slices.Concat(make([]byte, streamPrefixSize), sealed[streamPrefixSize:]), key[:]},
+		{"empty", nil, key[:]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := openAll(e, tt.sealed, tt.key); !errors.Is(err, ErrStreamCorrupted) {
+				t.Errorf("FastDecryptStream error = %v, want %v", err, ErrStreamCorrupted)
+			}
+		})
+	}
+}
+
+func TestStreamPlaintextSize(t *testing.T) {
+	for _, size := range []int64{0, 1, StreamChunkSize - 1, StreamChunkSize, StreamChunkSize + 1, 10*StreamChunkSize + 7, 1 << 40} {
+		got, err := StreamPlaintextSize(StreamEncryptedSize(size))
+		if err != nil || got != size {
+			t.Errorf("StreamPlaintextSize(StreamEncryptedSize(%d)) = %d, %v", size, got, err)
+		}
+	}
+
+	tests := []struct {
+		name          string
+		encryptedSize int64
+	}{
+		{"zero", 0},
+		{"negative", -1},
+		{"prefix only", streamPrefixSize},
+		{"short of an empty chunk", StreamEncryptedSize(0) - 1},
+		{"byte after a full chunk", StreamEncryptedSize(StreamChunkSize) + 1},
+		{"second chunk shorter than its overhead", StreamEncryptedSize(StreamChunkSize) + streamFrameSize + streamTagSize - 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := StreamPlaintextSize(tt.encryptedSize); !errors.Is(err, ErrStreamCorrupted) {
+				t.Errorf("StreamPlaintextSize(%d) = %d, %v, want %v", tt.encryptedSize, got, err, ErrStreamCorrupted)
+			}
+		})
+	}
+}
+
+// closeRecorder records whether it was closed
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (r *closeRecorder) Close() error {
+	r.closed = true
+	return nil
+}
+
+func TestStreamClosesSource(t *testing.T) {
+	e := newTestEncryption(CipherAESGCM)
+	key := sha256.Sum256([]byte("url key"))
+
+	src := &closeRecorder{Reader: bytes.NewReader([]byte("data"))}
+	sealer, err := e.FastEncryptStream(src, key[:])
+	if err != nil {
+		t.Fatalf("FastEncryptStream: %v", err)
+	}
+	if err := sealer.Close(); err != nil || !src.closed {
+		t.Errorf("Close = %v, source closed %v", err, src.closed)
+	}
+
+	src = &closeRecorder{Reader: bytes.NewReader(streamAll(t, e, []byte("data"), key[:]))}
+	opener, err := e.FastDecryptStream(src, key[:])
+	if err != nil {
+		t.Fatalf("FastDecryptStream: %v", err)
+	}
+	if err := opener.Close(); err != nil || !src.closed {
+		t.Errorf("Close = %v, source closed %v", err, src.closed)
+	}
+}
+
+func TestDecryptStreamRejectsOtherSalts(t *testing.T) {
+	e := newTestEncryption(CipherAESGCM)
+	for _, salt := range [][]byte{nil, FastSalt(), FastStreamSalt(), {SaltModeStream}, {0x02, 0x01}} {
+		if _, err := e.DecryptStream(bytes.NewReader(nil), salt, "password"); err == nil {
+			t.Errorf("DecryptStream accepted salt %x", salt)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package geoip
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/oschwald/geoip2-golang"
+)
+
+// GeoIP interface for dependency injection
+type GeoIP interface {
+	// LookupCountry returns the ISO 3166-1 alpha-2 country code of ip.
+	// Empty, private and loopback addresses return "" without an error,
+	// public addresses missing from the database return ErrUnknownCountry.
+	LookupCountry(ip string) (string, error)
+	// Reload reopens the database file, e.g. after it was updated on disk
+	Reload() error
+	Close() error
+}
+
+// Config holds GeoIP configuration
+type Config struct {
+	DBPath string // path to a GeoLite2-Country or GeoLite2-City .mmdb file
+}
+
+type geoipImpl struct {
+	path string
+
+	mu     sync.RWMutex
+	reader *geoip2.Reader
+}
+
+// Init opens the MaxMind database
+func Init(cfg Config) (GeoIP, error) {
+	reader, err := geoip2.Open(cfg.DBPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open geoip database: %w", err)
+	}
+	return &geoipImpl{path: cfg.DBPath, reader: reader}, nil
+}
+
+func (g *geoipImpl) LookupCountry(ip string) (string, error) {
+	addr, skip, err := parseIP(ip)
+	if skip || err != nil {
+		return "", err
+	}
+
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	record, err := g.reader.Country(addr)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up country: %w", err)
+	}
+	if record.Country.IsoCode == "" {
+		return "", ErrUnknownCountry
+	}
+	return record.Country.IsoCode, nil
+}
+
+func (g *geoipImpl) Reload() error {
+	// Open the new file first, a broken update keeps the old database in use
+	reader, err := geoip2.Open(g.path)
+	if err != nil {
+		return fmt.Errorf("failed to reopen geoip database: %w", err)
+	}
+
+	g.mu.Lock()
+	old := g.reader
+	g.reader = reader
+	g.mu.Unlock()
+
+	return old.Close()
+}
+
+func (g *geoipImpl) Close() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.reader.Close()
+}
+
+// parseIP parses ip, reporting skip for addresses without a meaningful location
+func parseIP(ip string) (net.IP, bool, error) {
+	if ip == "" {
+		return nil, true, nil
+	}
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return nil, false, ErrInvalidIP
+	}
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsUnspecified() {
+		return nil, true, nil
+	}
+	return addr, false, nil
+}
+
+var (
+	ErrInvalidIP      = errors.New("invalid IP address")
+	ErrUnknownCountry = errors.New("country of IP address is unknown")
+)
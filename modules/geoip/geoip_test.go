@@ -0,0 +1,67 @@
+package geoip
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestMockLookupCountry(t *testing.T) {
+	g := NewMockGeoIP(map[string]string{"8.8.8.8": "US", "2a00:1450::1": "DE"})
+
+	tests := []struct {
+		name    string
+		ip      string
+		want    string
+		wantErr error
+	}{
+		{"known", "8.8.8.8", "US", nil},
+		{"known IPv6", "2a00:1450:0:0::1", "DE", nil},
+		{"unknown public", "1.1.1.1", "", ErrUnknownCountry},
+		{"empty", "", "", nil},
+		{"loopback", "127.0.0.1", "", nil},
+		{"loopback IPv6", "::1", "", nil},
+		{"private", "192.168.1.10", "", nil},
+		{"private 10/8", "10.0.0.1", "", nil},
+		{"link local", "169.254.0.1", "", nil},
+		{"unspecified", "0.0.0.0", "", nil},
+		{"invalid", "not an ip", "", ErrInvalidIP},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := g.LookupCountry(tt.ip)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("LookupCountry(%q) error = %v, want %v", tt.ip, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("LookupCountry(%q) = %q, want %q", tt.ip, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMockReload(t *testing.T) {
+	g := NewMockGeoIP(nil)
+	if _, err := g.LookupCountry("8.8.8.8"); !errors.Is(err, ErrUnknownCountry) {
+		t.Fatalf("LookupCountry before Set error = %v, want ErrUnknownCountry", err)
+	}
+	g.Set("8.8.8.8", "US")
+	if got, err := g.LookupCountry("8.8.8.8"); err != nil || got != "US" {
+		t.Errorf("LookupCountry after Set = %q, %v, want US", got, err)
+	}
+
+	for range 2 {
+		if err := g.Reload(); err != nil {
+			t.Fatalf("Reload: %v", err)
+		}
+	}
+	if g.Reloads != 2 {
+		t.Errorf("Reloads = %d, want 2", g.Reloads)
+	}
+}
+
+func TestInitMissingDatabase(t *testing.T) {
+	if _, err := Init(Config{DBPath: filepath.Join(t.TempDir(), "missing.mmdb")}); err == nil {
+		t.Error("Init with a missing database succeeded")
+	}
+}
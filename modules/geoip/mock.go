@@ -0,0 +1,53 @@
+package geoip
+
+import "sync"
+
+// MockGeoIP resolves countries from a fixed IP -> country code table
+type MockGeoIP struct {
+	mu        sync.RWMutex
+	Countries map[string]string // IP -> ISO country code
+	Reloads   int
+}
+
+var _ GeoIP = (*MockGeoIP)(nil)
+
+// NewMockGeoIP creates a MockGeoIP with the given table
+func NewMockGeoIP(countries map[string]string) *MockGeoIP {
+	if countries == nil {
+		countries = make(map[string]string)
+	}
+	return &MockGeoIP{Countries: countries}
+}
+
+// Set maps ip to country
+func (m *MockGeoIP) Set(ip, country string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Countries[ip] = country
+}
+
+func (m *MockGeoIP) LookupCountry(ip string) (string, error) {
+	addr, skip, err := parseIP(ip)
+	if skip || err != nil {
+		return "", err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	country, ok := m.Countries[addr.String()]
+	if !ok {
+		return "", ErrUnknownCountry
+	}
+	return country, nil
+}
+
+func (m *MockGeoIP) Reload() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Reloads++
+	return nil
+}
+
+func (m *MockGeoIP) Close() error {
+	return nil
+}
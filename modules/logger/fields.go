@@ -0,0 +1,72 @@
+package logger
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+)
+
+var (
+	// globalFields holds the fields added to every entry, key -> value
+	globalFields sync.Map
+
+	// globalSnapshot is globalFields as fields sorted by key, rebuilt on every change
+	// so that logging reads a consistent set without locking
+	globalSnapshot atomic.Pointer[[]zap.Field]
+	globalMu       sync.Mutex // serializes changes of globalFields and globalSnapshot
+)
+
+// RegisterGlobalField adds a field to every entry of every logger, replacing the
+// value of a field registered under the same key, including the defaults set by
+// Init. A field passed to a single call takes precedence over a global one.
+func RegisterGlobalField(key string, value interface{}) {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	globalFields.Store(key, value)
+	rebuildGlobalSnapshot()
+}
+
+// UnregisterGlobalField removes a field added with RegisterGlobalField
+func UnregisterGlobalField(key string) {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	globalFields.Delete(key)
+	rebuildGlobalSnapshot()
+}
+
+func rebuildGlobalSnapshot() {
+	var fields []zap.Field
+	globalFields.Range(func(key, value any) bool {
+		fields = append(fields, zap.Any(key.(string), value))
+		return true
+	})
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Key < fields[j].Key })
+	globalSnapshot.Store(&fields)
+}
+
+// withGlobalFields prepends the global fields whose keys fields does not set
+func withGlobalFields(fields []zap.Field) []zap.Field {
+	snapshot := globalSnapshot.Load()
+	if snapshot == nil || len(*snapshot) == 0 {
+		return fields
+	}
+
+	merged := make([]zap.Field, 0, len(*snapshot)+len(fields))
+	for _, global := range *snapshot {
+		if !hasField(fields, global.Key) {
+			merged = append(merged, global)
+		}
+	}
+	return append(merged, fields...)
+}
+
+func hasField(fields []zap.Field, key string) bool {
+	for _, field := range fields {
+		if field.Key == key {
+			return true
+		}
+	}
+	return false
+}
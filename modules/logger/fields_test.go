@@ -0,0 +1,147 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// registerGlobalField registers a global field for the duration of a test
+func registerGlobalField(t *testing.T, key string, value interface{}) {
+	t.Helper()
+	RegisterGlobalField(key, value)
+	t.Cleanup(func() { UnregisterGlobalField(key) })
+}
+
+// countField returns how many times key is set on an entry
+func countField(entry observer.LoggedEntry, key string) int {
+	n := 0
+	for _, field := range entry.Context {
+		if field.Key == key {
+			n++
+		}
+	}
+	return n
+}
+
+func TestGlobalFields(t *testing.T) {
+	registerGlobalField(t, "region", "eu-central")
+
+	tests := []struct {
+		name string
+		log  func(l Logger)
+	}{
+		{"Info", func(l Logger) { l.Info("msg") }},
+		{"Warn", func(l Logger) { l.Warn("msg", zap.String("key", "value")) }},
+		{"ErrorCtx", func(l Logger) { l.ErrorCtx(context.WithValue(context.Background(), TraceIDKey, "trace-1"), "msg") }},
+		{"child", func(l Logger) { l.Child("api").Info("msg") }},
+		{"sampled", func(l Logger) { NewSampledLogger(l, 10, 10).Info("msg") }},
+		{"tee", func(l Logger) { NewTeeLogger(l).Info("msg") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, logs := newObservedLogger(t)
+			tt.log(l)
+
+			entries := logs.All()
+			if len(entries) != 1 {
+				t.Fatalf("logged %d entries, want 1", len(entries))
+			}
+			if got, _ := fieldValue(t, entries[0], "region"); got != "eu-central" {
+				t.Errorf("region = %v, want eu-central", got)
+			}
+			if n := countField(entries[0], "region"); n != 1 {
+				t.Errorf("region set %d times, want once", n)
+			}
+		})
+	}
+}
+
+func TestUnregisterGlobalField(t *testing.T) {
+	RegisterGlobalField("temporary", 1)
+	UnregisterGlobalField("temporary")
+
+	l, logs := newObservedLogger(t)
+	l.Info("msg")
+	if _, ok := fieldValue(t, logs.All()[0], "temporary"); ok {
+		t.Error("unregistered field is still logged")
+	}
+}
+
+func TestGlobalFieldPrecedence(t *testing.T) {
+	registerGlobalField(t, "key", "global")
+	registerGlobalField(t, "replaced", "first")
+	RegisterGlobalField("replaced", "second")
+
+	l, logs := newObservedLogger(t)
+	l.Info("msg", zap.String("key", "call"))
+
+	entry := logs.All()[0]
+	if got, _ := fieldValue(t, entry, "key"); got != "call" || countField(entry, "key") != 1 {
+		t.Errorf("key = %v set %d times, want the call's value once", got, countField(entry, "key"))
+	}
+	if got, _ := fieldValue(t, entry, "replaced"); got != "second" {
+		t.Errorf("replaced = %v, want the last registered value", got)
+	}
+}
+
+func TestRegisterDefaultFields(t *testing.T) {
+	t.Cleanup(func() { UnregisterGlobalField("app_version") })
+	registerGlobalField(t, "hostname", "overridden")
+
+	registerDefaultFields(Config{Version: "1.2.3"})
+
+	l, logs := newObservedLogger(t)
+	l.Info("msg")
+	entry := logs.All()[0]
+	if got, _ := fieldValue(t, entry, "pid"); got != int64(os.Getpid()) {
+		t.Errorf("pid = %v (%T), want %d", got, got, os.Getpid())
+	}
+	if got, _ := fieldValue(t, entry, "app_version"); got != "1.2.3" {
+		t.Errorf("app_version = %v, want 1.2.3", got)
+	}
+	// Fields registered before Init are kept
+	if got, _ := fieldValue(t, entry, "hostname"); got != "overridden" {
+		t.Errorf("hostname = %v, want the field registered before", got)
+	}
+}
+
+func TestGlobalFieldsConcurrent(t *testing.T) {
+	l, logs := newObservedLogger(t)
+
+	var wg sync.WaitGroup
+	for i := range 4 {
+		key := fmt.Sprintf("concurrent_%d", i)
+		t.Cleanup(func() { UnregisterGlobalField(key) })
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := range 100 {
+				RegisterGlobalField(key, j)
+				if j%2 == 0 {
+					UnregisterGlobalField(key)
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				l.Info("concurrent")
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, entry := range logs.FilterMessage("concurrent").All() {
+		for i := range 4 {
+			if n := countField(entry, fmt.Sprintf("concurrent_%d", i)); n > 1 {
+				t.Fatalf("field set %d times on one entry", n)
+			}
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// DefaultFileMaxSizeMB is the size a log file grows to before it is rotated when FileMaxSizeMB is unset
+const DefaultFileMaxSizeMB = 100
+
+// backupTimeFormat names rotated files, it sorts in time order
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// FileLogger returns a JSON logger writing to the file at path, which is rotated
+// after FileMaxSizeMB megabytes. Rotated files are named after the time of the
+// rotation, e.g. lovebin-2026-10-14T18-30-00.000.log, and removed when there are
+// more than FileMaxBackups of them or they are older than FileMaxAgeDays.
+// Level and sampling follow cfg like Init.
+func FileLogger(path string, cfg Config) (Logger, error) {
+	file, err := newRotatingFile(path, cfg.FileMaxSizeMB, cfg.FileMaxBackups, cfg.FileMaxAgeDays)
+	if err != nil {
+		return nil, err
+	}
+
+	level := cfg.Level
+	if level == "" {
+		level = getDefaultLevel()
+	}
+	var zapLevel zapcore.Level
+	if err := zapLevel.UnmarshalText([]byte(level)); err != nil {
+		zapLevel = zapcore.InfoLevel
+	}
+
+	encoderConfig := zap.NewProductionEncoderConfig()
+	encoderConfig.TimeKey = "timestamp"
+	encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	encoderConfig.MessageKey = "message"
+	encoderConfig.LevelKey = "level"
+	encoderConfig.CallerKey = "caller"
+	encoderConfig.EncodeCaller = zapcore.ShortCallerEncoder
+
+	core := zapcore.NewCore(zapcore.NewJSONEncoder(encoderConfig), zapcore.AddSync(file), zapLevel)
+	logger := zap.New(core, zap.AddCaller(), zap.AddCallerSkip(1), zap.ErrorOutput(zapcore.Lock(os.Stderr)))
+	if cfg.SamplingEnabled {
+		every := cfg.SamplingEvery
+		if every == 0 {
+			every = defaultSamplingEvery
+		}
+		logger = sampled(logger, every, every)
+	}
+	return &loggerImpl{logger: logger}, nil
+}
+
+// rotatingFile is an io.Writer appending to a file and rotating it once it would exceed maxSize
+type rotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int           // 0 keeps all
+	maxAge     time.Duration // 0 keeps all
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+func newRotatingFile(path string, maxSizeMB, maxBackups, maxAgeDays int) (*rotatingFile, error) {
+	if maxSizeMB <= 0 {
+		maxSizeMB = DefaultFileMaxSizeMB
+	}
+	r := &rotatingFile{
+		path:       path,
+		maxSize:    int64(maxSizeMB) << 20,
+		maxBackups: max(maxBackups, 0),
+		maxAge:     time.Duration(max(maxAgeDays, 0)) * 24 * time.Hour,
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create log directory: %w", err)
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// open opens the file for appending, continuing an existing one
+func (r *rotatingFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("stat log file: %w", err)
+	}
+	r.file = file
+	r.size = info.Size()
+	return nil
+}
+
+// Write appends p, rotating first when it would not fit. An entry larger than
+// maxSize is still written whole, to a file of its own.
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *rotatingFile) Sync() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.file.Sync()
+}
+
+// rotate renames the current file after the current time, opens a new one and removes old backups
+func (r *rotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return fmt.Errorf("close log file: %w", err)
+	}
+	prefix, ext := r.backupName()
+	if err := os.Rename(r.path, prefix+time.Now().Format(backupTimeFormat)+ext); err != nil {
+		return fmt.Errorf("rotate log file: %w", err)
+	}
+	if err := r.open(); err != nil {
+		return err
+	}
+	r.removeOldBackups()
+	return nil
+}
+
+// backupName returns what rotated files start and end with, e.g. "/var/log/lovebin-" and ".log"
+func (r *rotatingFile) backupName() (prefix, ext string) {
+	ext = filepath.Ext(r.path)
+	return strings.TrimSuffix(r.path, ext) + "-", ext
+}
+
+// removeOldBackups removes rotated files beyond maxBackups or older than maxAge.
+// Failures are ignored, the next rotation tries again.
+func (r *rotatingFile) removeOldBackups() {
+	if r.maxBackups == 0 && r.maxAge == 0 {
+		return
+	}
+
+	dir := filepath.Dir(r.path)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	prefix, ext := r.backupName()
+	prefix = filepath.Base(prefix)
+
+	type backup struct {
+		path string
+		at   time.Time
+	}
+	var backups []backup
+	for _, entry := range entries {
+		stamp, ok := strings.CutPrefix(entry.Name(), prefix)
+		if !ok || entry.IsDir() {
+			continue
+		}
+		at, err := time.ParseInLocation(backupTimeFormat, strings.TrimSuffix(stamp, ext), time.Local)
+		if err != nil {
+			continue // not one of ours
+		}
+		backups = append(backups, backup{path: filepath.Join(dir, entry.Name()), at: at})
+	}
+	// Newest first
+	slices.SortFunc(backups, func(a, b backup) int { return b.at.Compare(a.at) })
+
+	cutoff := time.Now().Add(-r.maxAge)
+	for i, b := range backups {
+		if (r.maxBackups > 0 && i >= r.maxBackups) || (r.maxAge > 0 && b.at.Before(cutoff)) {
+			os.Remove(b.path)
+		}
+	}
+}
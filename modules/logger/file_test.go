@@ -0,0 +1,223 @@
+package logger
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// readLogEntries decodes the JSON lines of a log file
+func readLogEntries(t *testing.T, path string) []map[string]any {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open log: %v", err)
+	}
+	defer file.Close()
+
+	var entries []map[string]any
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("log line %q is not JSON: %v", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestFileLogger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "lovebin.log")
+	l, err := FileLogger(path, Config{Level: "warn"})
+	if err != nil {
+		t.Fatalf("FileLogger: %v", err)
+	}
+	l.Info("dropped")
+	l.Warn("kept", zap.String("key", "value"))
+	if err := l.Sync(); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+
+	entries := readLogEntries(t, path)
+	if len(entries) != 1 {
+		t.Fatalf("logged %d entries, want 1 at warn level", len(entries))
+	}
+	entry := entries[0]
+	if entry["message"] != "kept" || entry["level"] != "warn" || entry["key"] != "value" {
+		t.Errorf("entry = %v", entry)
+	}
+	if caller, _ := entry["caller"].(string); !strings.HasPrefix(caller, "logger/file_test.go:") {
+		t.Errorf("caller = %q, want this test", caller)
+	}
+	if _, err := time.Parse("2006-01-02T15:04:05.000Z0700", entry["timestamp"].(string)); err != nil {
+		t.Errorf("timestamp %v is not ISO 8601: %v", entry["timestamp"], err)
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "lovebin.log")
+	if err := os.WriteFile(path, []byte("0123456789\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := newRotatingFile(path, 1, 0, 0)
+	if err != nil {
+		t.Fatalf("newRotatingFile: %v", err)
+	}
+	r.maxSize = 32
+	if r.size != 11 {
+		t.Errorf("size = %d, want the existing file continued", r.size)
+	}
+
+	writes := []string{
+		"0123456789\n",                 // fits: 22 bytes
+		"0123456789\n",                 // would be 33, rotates
+		strings.Repeat("x", 40) + "\n", // larger than maxSize, rotates into a file of its own
+		"y\n",                          // rotates again
+	}
+	for _, w := range writes {
+		if _, err := r.Write([]byte(w)); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		time.Sleep(2 * time.Millisecond) // backup names have millisecond precision
+	}
+	if err := r.Sync(); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+
+	backups, _ := filepath.Glob(filepath.Join(dir, "lovebin-*.log"))
+	if len(backups) != 3 {
+		t.Fatalf("backups = %v, want 3", backups)
+	}
+	want := []string{"0123456789\n0123456789\n", "0123456789\n", strings.Repeat("x", 40) + "\n"}
+	for i, backup := range backups {
+		data, _ := os.ReadFile(backup)
+		if string(data) != want[i] {
+			t.Errorf("backup %d = %q, want %q", i, data, want[i])
+		}
+	}
+	if data, _ := os.ReadFile(path); string(data) != "y\n" {
+		t.Errorf("current file = %q, want the last write", data)
+	}
+}
+
+func TestRemoveOldBackups(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name       string
+		maxBackups int
+		maxAgeDays int
+		wantKept   []time.Duration // ages of the backups left
+	}{
+		{"keep all", 0, 0, []time.Duration{time.Hour, 2 * 24 * time.Hour, 10 * 24 * time.Hour}},
+		{"max backups", 2, 0, []time.Duration{time.Hour, 2 * 24 * time.Hour}},
+		{"max age", 0, 3, []time.Duration{time.Hour, 2 * 24 * time.Hour}},
+		{"both", 1, 3, []time.Duration{time.Hour}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "lovebin.log")
+			r, err := newRotatingFile(path, 1, tt.maxBackups, tt.maxAgeDays)
+			if err != nil {
+				t.Fatalf("newRotatingFile: %v", err)
+			}
+			defer r.file.Close()
+
+			backupPath := func(age time.Duration) string {
+				return filepath.Join(dir, "lovebin-"+now.Add(-age).Format(backupTimeFormat)+".log")
+			}
+			for _, age := range []time.Duration{10 * 24 * time.Hour, time.Hour, 2 * 24 * time.Hour} {
+				if err := os.WriteFile(backupPath(age), nil, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// Files that are not backups of this log are never removed
+			foreign := []string{"lovebin-notes.log", "other-2020-01-01T00-00-00.000.log"}
+			for _, name := range foreign {
+				os.WriteFile(filepath.Join(dir, name), nil, 0o644)
+			}
+
+			r.removeOldBackups()
+
+			backups, _ := filepath.Glob(filepath.Join(dir, "lovebin-2*.log"))
+			if len(backups) != len(tt.wantKept) {
+				t.Errorf("backups left = %v, want %d", backups, len(tt.wantKept))
+			}
+			for _, age := range tt.wantKept {
+				if _, err := os.Stat(backupPath(age)); err != nil {
+					t.Errorf("backup %v old was removed", age)
+				}
+			}
+			for _, name := range foreign {
+				if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+					t.Errorf("%s was removed", name)
+				}
+			}
+		})
+	}
+}
+
+func TestTeeLogger(t *testing.T) {
+	ctx := context.WithValue(context.Background(), TraceIDKey, "trace-1")
+	tests := []struct {
+		name    string
+		log     func(l Logger)
+		wantCtx bool
+	}{
+		{"Debug", func(l Logger) { l.Debug("msg") }, false},
+		{"Info", func(l Logger) { l.Info("msg") }, false},
+		{"Warn", func(l Logger) { l.Warn("msg") }, false},
+		{"Error", func(l Logger) { l.Error("msg") }, false},
+		{"DebugCtx", func(l Logger) { l.DebugCtx(ctx, "msg") }, true},
+		{"InfoCtx", func(l Logger) { l.InfoCtx(ctx, "msg") }, true},
+		{"WarnCtx", func(l Logger) { l.WarnCtx(ctx, "msg") }, true},
+		{"ErrorCtx", func(l Logger) { l.ErrorCtx(ctx, "msg") }, true},
+		{"With", func(l Logger) { l.With(zap.String("key", "value")).Info("msg") }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first, firstLogs := newObservedLogger(t)
+			second, secondLogs := newObservedLogger(t)
+			tt.log(NewTeeLogger(first, second))
+
+			for i, logs := range []*observer.ObservedLogs{firstLogs, secondLogs} {
+				entries := logs.All()
+				if len(entries) != 1 {
+					t.Fatalf("logger %d got %d entries, want 1", i, len(entries))
+				}
+				if _, ok := fieldValue(t, entries[0], "trace_id"); ok != tt.wantCtx {
+					t.Errorf("logger %d trace_id set = %v, want %v", i, ok, tt.wantCtx)
+				}
+			}
+		})
+	}
+}
+
+func TestTeeLoggerCaller(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lovebin.log")
+	file, err := FileLogger(path, Config{Level: "info"})
+	if err != nil {
+		t.Fatalf("FileLogger: %v", err)
+	}
+	tee := NewTeeLogger(file)
+	tee.Info("direct")
+	tee.InfoCtx(context.Background(), "with context")
+	tee.Sync()
+
+	for _, entry := range readLogEntries(t, path) {
+		if caller, _ := entry["caller"].(string); !strings.HasPrefix(caller, "logger/file_test.go:") {
+			t.Errorf("%v logged from %q, want this test", entry["message"], caller)
+		}
+	}
+}
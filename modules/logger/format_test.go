@@ -0,0 +1,93 @@
+package logger
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// captureStderr returns what the logger built by newLogger writes, zap opens os.Stderr when the logger is built
+func captureStderr(t *testing.T, format string, noColor bool, log func(Logger)) string {
+	t.Helper()
+	file, err := os.Create(filepath.Join(t.TempDir(), "stderr"))
+	if err != nil {
+		t.Fatalf("create file: %v", err)
+	}
+	defer file.Close()
+
+	stderr := os.Stderr
+	os.Stderr = file
+	zl, err := newLogger("info", format, noColor, false)
+	os.Stderr = stderr
+	if err != nil {
+		t.Fatalf("newLogger: %v", err)
+	}
+
+	log(&loggerImpl{logger: zl})
+	_ = zl.Sync()
+	out, err := os.ReadFile(file.Name())
+	if err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	return string(out)
+}
+
+func TestLogFormat(t *testing.T) {
+	tests := []struct {
+		name      string
+		format    string
+		noColor   bool
+		wantJSON  bool
+		wantColor bool
+	}{
+		{"default", "", false, true, false},
+		{"json", FormatJSON, false, true, false},
+		{"unknown", "xml", false, true, false},
+		{"console", FormatConsole, false, false, true},
+		{"console without color", FormatConsole, true, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := captureStderr(t, tt.format, tt.noColor, func(l Logger) { l.Info("hello console") })
+			line := strings.TrimSpace(out)
+			if !strings.Contains(line, "hello console") {
+				t.Fatalf("output %q lacks the message", out)
+			}
+
+			var entry map[string]any
+			isJSON := json.Unmarshal([]byte(line), &entry) == nil
+			if isJSON != tt.wantJSON {
+				t.Fatalf("output %q is JSON = %v, want %v", line, isJSON, tt.wantJSON)
+			}
+			if isJSON {
+				if entry["level"] != "info" || entry["message"] != "hello console" {
+					t.Errorf("entry = %v", entry)
+				}
+				return
+			}
+
+			if !strings.Contains(line, "INFO") || strings.Contains(line, `"INFO"`) {
+				t.Errorf("console output %q lacks the bare level name", line)
+			}
+			if hasColor := strings.Contains(line, "\x1b["); hasColor != tt.wantColor {
+				t.Errorf("output %q has color = %v, want %v", line, hasColor, tt.wantColor)
+			}
+		})
+	}
+}
+
+func TestInitKeepsTheFirstLogger(t *testing.T) {
+	first, err := Init(Config{Level: "fatal", Format: FormatConsole})
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	second, err := Init(Config{Level: "debug", Format: FormatJSON})
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	if first.(*loggerImpl).logger != second.(*loggerImpl).logger {
+		t.Error("a second Init replaced the global logger")
+	}
+}
@@ -1,8 +1,10 @@
 package logger
 
 import (
+	"context"
 	"os"
 	"sync"
+	"time"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -15,9 +17,29 @@ var (
 
 // Config holds logger configuration
 type Config struct {
-	Level string
+	Level           string
+	Format          string // "json" (default) or "console" for human-readable development output
+	NoColor         bool   // disable level colors of the console format
+	SamplingEnabled bool   // replace zap's default sampling with SamplingEvery per second
+	SamplingEvery   uint64 // identical messages logged per second before sampling, then every Nth
+	Version         string // logged as app_version with every entry (empty leaves the field out)
+
+	FilePath       string // also write JSON logs to this file, see FileLogger (empty writes to stderr only)
+	FileMaxSizeMB  int    // size of the log file before it is rotated (default 100)
+	FileMaxBackups int    // rotated files kept (0 keeps all)
+	FileMaxAgeDays int    // days rotated files are kept (0 keeps them regardless of age)
 }
 
+// contextKey is the type of the well-known context keys read by the *Ctx methods
+type contextKey string
+
+const (
+	// TraceIDKey is the context key holding the trace ID of the current request
+	TraceIDKey contextKey = "trace_id"
+	// RequestIDKey is the context key holding the request ID of the current request
+	RequestIDKey contextKey = "request_id"
+)
+
 // Logger interface for dependency injection
 type Logger interface {
 	Info(msg string, fields ...zap.Field)
@@ -25,40 +47,101 @@ type Logger interface {
 	Warn(msg string, fields ...zap.Field)
 	Debug(msg string, fields ...zap.Field)
 	Fatal(msg string, fields ...zap.Field)
+	InfoCtx(ctx context.Context, msg string, fields ...zap.Field)
+	ErrorCtx(ctx context.Context, msg string, fields ...zap.Field)
+	WarnCtx(ctx context.Context, msg string, fields ...zap.Field)
+	DebugCtx(ctx context.Context, msg string, fields ...zap.Field)
+	FatalCtx(ctx context.Context, msg string, fields ...zap.Field)
 	Sync() error
 	With(fields ...zap.Field) *zap.Logger
+	// Child returns a logger that adds a "component" field to every entry.
+	// Children of a child extend the name with a dot, e.g. "api.upload".
+	Child(component string) Logger
 }
 
 type loggerImpl struct {
-	logger *zap.Logger
+	logger    *zap.Logger
+	base      *zap.Logger // logger without the component field, nil for the root
+	component string
 }
 
 func (l *loggerImpl) Info(msg string, fields ...zap.Field) {
-	l.logger.Info(msg, fields...)
+	l.logger.Info(msg, withGlobalFields(fields)...)
 }
 
 func (l *loggerImpl) Error(msg string, fields ...zap.Field) {
-	l.logger.Error(msg, fields...)
+	l.logger.Error(msg, withGlobalFields(fields)...)
 }
 
 func (l *loggerImpl) Warn(msg string, fields ...zap.Field) {
-	l.logger.Warn(msg, fields...)
+	l.logger.Warn(msg, withGlobalFields(fields)...)
 }
 
 func (l *loggerImpl) Debug(msg string, fields ...zap.Field) {
-	l.logger.Debug(msg, fields...)
+	l.logger.Debug(msg, withGlobalFields(fields)...)
 }
 
 func (l *loggerImpl) Fatal(msg string, fields ...zap.Field) {
-	l.logger.Fatal(msg, fields...)
+	l.logger.Fatal(msg, withGlobalFields(fields)...)
+}
+
+func (l *loggerImpl) InfoCtx(ctx context.Context, msg string, fields ...zap.Field) {
+	l.logger.Info(msg, withGlobalFields(appendContextFields(ctx, fields))...)
+}
+
+func (l *loggerImpl) ErrorCtx(ctx context.Context, msg string, fields ...zap.Field) {
+	l.logger.Error(msg, withGlobalFields(appendContextFields(ctx, fields))...)
+}
+
+func (l *loggerImpl) WarnCtx(ctx context.Context, msg string, fields ...zap.Field) {
+	l.logger.Warn(msg, withGlobalFields(appendContextFields(ctx, fields))...)
+}
+
+func (l *loggerImpl) DebugCtx(ctx context.Context, msg string, fields ...zap.Field) {
+	l.logger.Debug(msg, withGlobalFields(appendContextFields(ctx, fields))...)
+}
+
+func (l *loggerImpl) FatalCtx(ctx context.Context, msg string, fields ...zap.Field) {
+	l.logger.Fatal(msg, withGlobalFields(appendContextFields(ctx, fields))...)
 }
 
 func (l *loggerImpl) Sync() error {
 	return l.logger.Sync()
 }
 
+// With returns a zap logger with fields. It carries the global fields registered
+// at the time of the call, later changes do not reach it.
 func (l *loggerImpl) With(fields ...zap.Field) *zap.Logger {
-	return l.logger.With(fields...)
+	return l.logger.With(withGlobalFields(fields)...)
+}
+
+func (l *loggerImpl) Child(component string) Logger {
+	base := l.base
+	if base == nil {
+		base = l.logger
+	}
+	if l.component != "" {
+		component = l.component + "." + component
+	}
+	return &loggerImpl{
+		logger:    base.With(zap.String("component", component)),
+		base:      base,
+		component: component,
+	}
+}
+
+// appendContextFields appends trace_id and request_id from ctx to fields
+func appendContextFields(ctx context.Context, fields []zap.Field) []zap.Field {
+	if ctx == nil {
+		return fields
+	}
+	if traceID, ok := ctx.Value(TraceIDKey).(string); ok && traceID != "" {
+		fields = append(fields, zap.String(string(TraceIDKey), traceID))
+	}
+	if requestID, ok := ctx.Value(RequestIDKey).(string); ok && requestID != "" {
+		fields = append(fields, zap.String(string(RequestIDKey), requestID))
+	}
+	return fields
 }
 
 // Init initializes the logger module
@@ -72,7 +155,14 @@ func Init(cfg Config) (Logger, error) {
 	var logger *zap.Logger
 
 	once.Do(func() {
-		logger, err = newLogger(level)
+		logger, err = newLogger(level, cfg.Format, cfg.NoColor, cfg.SamplingEnabled)
+		if err == nil && cfg.SamplingEnabled {
+			every := cfg.SamplingEvery
+			if every == 0 {
+				every = defaultSamplingEvery
+			}
+			logger = sampled(logger, every, every)
+		}
 		if err == nil {
 			globalLogger = logger
 		}
@@ -82,9 +172,29 @@ func Init(cfg Config) (Logger, error) {
 		return nil, err
 	}
 
+	registerDefaultFields(cfg)
 	return &loggerImpl{logger: globalLogger}, nil
 }
 
+// registerDefaultFields registers the global fields every entry starts with.
+// Fields registered before are kept, so they override the defaults.
+func registerDefaultFields(cfg Config) {
+	defaults := map[string]interface{}{"pid": os.Getpid()}
+	if hostname, err := os.Hostname(); err == nil {
+		defaults["hostname"] = hostname
+	}
+	if cfg.Version != "" {
+		defaults["app_version"] = cfg.Version
+	}
+
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	for key, value := range defaults {
+		globalFields.LoadOrStore(key, value)
+	}
+	rebuildGlobalSnapshot()
+}
+
 // Get returns the global logger instance
 func Get() Logger {
 	if globalLogger == nil {
@@ -95,12 +205,22 @@ func Get() Logger {
 	return &loggerImpl{logger: globalLogger}
 }
 
-func newLogger(level string) (*zap.Logger, error) {
+// Log output formats
+const (
+	FormatJSON    = "json"
+	FormatConsole = "console"
+)
+
+func newLogger(level, format string, noColor, customSampling bool) (*zap.Logger, error) {
 	var zapLevel zapcore.Level
 	if err := zapLevel.UnmarshalText([]byte(level)); err != nil {
 		zapLevel = zapcore.InfoLevel
 	}
 
+	if format == FormatConsole {
+		return newConsoleLogger(zapLevel, noColor)
+	}
+
 	config := zap.NewProductionConfig()
 	config.Level = zap.NewAtomicLevelAt(zapLevel)
 	config.EncoderConfig.TimeKey = "timestamp"
@@ -109,6 +229,10 @@ func newLogger(level string) (*zap.Logger, error) {
 	config.EncoderConfig.LevelKey = "level"
 	config.EncoderConfig.CallerKey = "caller"
 	config.EncoderConfig.EncodeCaller = zapcore.ShortCallerEncoder
+	if customSampling {
+		// Sampling is applied by Init, avoid sampling twice
+		config.Sampling = nil
+	}
 
 	logger, err := config.Build(zap.AddCaller(), zap.AddCallerSkip(1))
 	if err != nil {
@@ -118,6 +242,46 @@ func newLogger(level string) (*zap.Logger, error) {
 	return logger, nil
 }
 
+// newConsoleLogger builds a human-readable logger for reading logs in a terminal
+func newConsoleLogger(level zapcore.Level, noColor bool) (*zap.Logger, error) {
+	config := zap.NewDevelopmentConfig()
+	config.Level = zap.NewAtomicLevelAt(level)
+	config.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	config.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+	if noColor {
+		config.EncoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
+	}
+
+	return config.Build(zap.AddCaller(), zap.AddCallerSkip(1))
+}
+
+// defaultSamplingEvery matches zap's production sampling
+const defaultSamplingEvery = 100
+
+// NewSampledLogger wraps base so that identical messages are rate-limited per second:
+// the first every messages pass, then only every thereafter-th one.
+func NewSampledLogger(base Logger, every uint64, thereafter uint64) Logger {
+	if impl, ok := base.(*loggerImpl); ok {
+		if impl.base == nil {
+			// Use the zap logger as is, With would bake in the global fields the entries get anyway
+			return &loggerImpl{logger: sampled(impl.logger, every, thereafter)}
+		}
+		// Keep the component so children of the sampled logger do not repeat the field
+		return &loggerImpl{
+			logger:    sampled(impl.logger, every, thereafter),
+			base:      sampled(impl.base, every, thereafter),
+			component: impl.component,
+		}
+	}
+	return &loggerImpl{logger: sampled(base.With(), every, thereafter)}
+}
+
+func sampled(logger *zap.Logger, every uint64, thereafter uint64) *zap.Logger {
+	return logger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return zapcore.NewSamplerWithOptions(core, time.Second, int(every), int(thereafter))
+	}))
+}
+
 func getDefaultLevel() string {
 	if level := os.Getenv("LOG_LEVEL"); level != "" {
 		return level
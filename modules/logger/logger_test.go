@@ -0,0 +1,221 @@
+package logger
+
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// newObservedLogger returns a logger writing to an in-memory core, with no global fields
+func newObservedLogger(t *testing.T) (*loggerImpl, *observer.ObservedLogs) {
+	t.Helper()
+	core, logs := observer.New(zap.DebugLevel)
+	return &loggerImpl{logger: zap.New(core)}, logs
+}
+
+func fieldValue(t *testing.T, entry observer.LoggedEntry, key string) (any, bool) {
+	t.Helper()
+	value, ok := entry.ContextMap()[key]
+	return value, ok
+}
+
+func TestCtxMethodsAddTraceAndRequestID(t *testing.T) {
+	ctx := context.WithValue(context.Background(), TraceIDKey, "trace-1")
+	ctx = context.WithValue(ctx, RequestIDKey, "request-1")
+
+	tests := []struct {
+		name string
+		log  func(l Logger)
+	}{
+		{"InfoCtx", func(l Logger) { l.InfoCtx(ctx, "msg") }},
+		{"WarnCtx", func(l Logger) { l.WarnCtx(ctx, "msg") }},
+		{"ErrorCtx", func(l Logger) { l.ErrorCtx(ctx, "msg") }},
+		{"DebugCtx", func(l Logger) { l.DebugCtx(ctx, "msg") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, logs := newObservedLogger(t)
+			tt.log(l)
+
+			entries := logs.All()
+			if len(entries) != 1 {
+				t.Fatalf("got %d entries, want 1", len(entries))
+			}
+			if got, _ := fieldValue(t, entries[0], "trace_id"); got != "trace-1" {
+				t.Errorf("trace_id = %v, want trace-1", got)
+			}
+			if got, _ := fieldValue(t, entries[0], "request_id"); got != "request-1" {
+				t.Errorf("request_id = %v, want request-1", got)
+			}
+		})
+	}
+}
+
+func TestCtxMethodsWithoutIDs(t *testing.T) {
+	tests := []struct {
+		name string
+		ctx  context.Context
+	}{
+		{"background", context.Background()},
+		{"nil", nil},
+		{"empty trace ID", context.WithValue(context.Background(), TraceIDKey, "")},
+		{"wrong type", context.WithValue(context.Background(), TraceIDKey, 42)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, logs := newObservedLogger(t)
+			l.InfoCtx(tt.ctx, "msg")
+
+			entry := logs.All()[0]
+			if _, ok := fieldValue(t, entry, "trace_id"); ok {
+				t.Error("trace_id should not be logged")
+			}
+			if _, ok := fieldValue(t, entry, "request_id"); ok {
+				t.Error("request_id should not be logged")
+			}
+		})
+	}
+}
+
+func TestNonCtxMethodsKeepFields(t *testing.T) {
+	l, logs := newObservedLogger(t)
+	l.Info("msg", zap.String("key", "value"))
+
+	entry := logs.All()[0]
+	if got, _ := fieldValue(t, entry, "key"); got != "value" {
+		t.Errorf("key = %v, want value", got)
+	}
+	if _, ok := fieldValue(t, entry, "trace_id"); ok {
+		t.Error("trace_id should not be logged without a context")
+	}
+}
+
+func TestNewSampledLogger(t *testing.T) {
+	tests := []struct {
+		name       string
+		every      uint64
+		thereafter uint64
+		repeats    int
+		want       int
+	}{
+		// zap passes the first every messages in a second, then each thereafter-th
+		{"below the limit", 5, 5, 5, 5},
+		{"every second after the first two", 2, 2, 10, 6},
+		{"every third after the first two", 2, 3, 10, 4},
+		{"one then every tenth", 1, 10, 25, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base, logs := newObservedLogger(t)
+			l := NewSampledLogger(base, tt.every, tt.thereafter)
+			for range tt.repeats {
+				l.Info("repeated")
+			}
+			if got := logs.FilterMessage("repeated").Len(); got != tt.want {
+				t.Errorf("logged %d of %d identical messages, want %d", got, tt.repeats, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewSampledLoggerKeepsDistinctMessages(t *testing.T) {
+	base, logs := newObservedLogger(t)
+	l := NewSampledLogger(base, 1, 1000)
+	for range 3 {
+		l.Info("first")
+		l.Warn("second")
+	}
+	if got := logs.FilterMessage("first").Len(); got != 1 {
+		t.Errorf("first logged %d times, want 1", got)
+	}
+	if got := logs.FilterMessage("second").Len(); got != 1 {
+		t.Errorf("second logged %d times, want 1: messages are sampled separately", got)
+	}
+}
+
+func TestNewSampledLoggerChild(t *testing.T) {
+	base, logs := newObservedLogger(t)
+	l := NewSampledLogger(base.Child("api"), 1, 1000)
+	child := l.Child("uploads")
+	for range 3 {
+		child.Info("child message")
+	}
+
+	entries := logs.FilterMessage("child message").All()
+	if len(entries) != 1 {
+		t.Fatalf("child logged %d times, want 1: children keep the sampling", len(entries))
+	}
+	if got, _ := fieldValue(t, entries[0], "component"); got != "api.uploads" {
+		t.Errorf("component = %v, want api.uploads", got)
+	}
+}
+
+func TestChild(t *testing.T) {
+	tests := []struct {
+		name  string
+		child func(l Logger) Logger
+		want  string
+	}{
+		{"child", func(l Logger) Logger { return l.Child("api") }, "api"},
+		{"grandchild", func(l Logger) Logger { return l.Child("api").Child("uploads") }, "api.uploads"},
+		{"three levels", func(l Logger) Logger { return l.Child("media-service").Child("cleanup").Child("s3") }, "media-service.cleanup.s3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, logs := newObservedLogger(t)
+			tt.child(l).InfoCtx(context.WithValue(context.Background(), TraceIDKey, "trace-1"), "msg", zap.String("key", "value"))
+
+			entries := logs.All()
+			if len(entries) != 1 {
+				t.Fatalf("logged %d entries, want 1", len(entries))
+			}
+			// The component is set once, not repeated for every level
+			components := 0
+			for _, field := range entries[0].Context {
+				if field.Key == "component" {
+					components++
+				}
+			}
+			if components != 1 {
+				t.Errorf("entry has %d component fields, want 1", components)
+			}
+			if got, _ := fieldValue(t, entries[0], "component"); got != tt.want {
+				t.Errorf("component = %v, want %s", got, tt.want)
+			}
+			if got, _ := fieldValue(t, entries[0], "key"); got != "value" {
+				t.Errorf("key = %v, want value", got)
+			}
+			if got, _ := fieldValue(t, entries[0], "trace_id"); got != "trace-1" {
+				t.Errorf("trace_id = %v, want trace-1", got)
+			}
+		})
+	}
+}
+
+func TestChildLeavesParentUnchanged(t *testing.T) {
+	l, logs := newObservedLogger(t)
+	_ = l.Child("api")
+	l.Info("parent")
+
+	if _, ok := fieldValue(t, logs.All()[0], "component"); ok {
+		t.Error("parent logs a component after creating a child")
+	}
+}
+
+func TestTeeLoggerChild(t *testing.T) {
+	first, firstLogs := newObservedLogger(t)
+	second, secondLogs := newObservedLogger(t)
+	NewTeeLogger(first, second).Child("api").Info("msg")
+
+	for i, logs := range []*observer.ObservedLogs{firstLogs, secondLogs} {
+		entries := logs.All()
+		if len(entries) != 1 {
+			t.Fatalf("logger %d got %d entries, want 1", i, len(entries))
+		}
+		if got, _ := fieldValue(t, entries[0], "component"); got != "api" {
+			t.Errorf("logger %d component = %v, want api", i, got)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package logger
+
+import (
+	"context"
+	"errors"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// teeLogger writes every entry to all of its loggers
+type teeLogger struct {
+	loggers []Logger
+}
+
+// NewTeeLogger returns a logger writing every entry to all loggers, e.g. to stderr
+// and a file. Fatal writes the entry to all of them before exiting.
+func NewTeeLogger(loggers ...Logger) Logger {
+	tee := &teeLogger{loggers: make([]Logger, 0, len(loggers))}
+	for _, l := range loggers {
+		tee.loggers = append(tee.loggers, skipTeeFrame(l))
+	}
+	return tee
+}
+
+// skipTeeFrame makes loggers of this package report the caller of the tee instead of the tee itself
+func skipTeeFrame(l Logger) Logger {
+	impl, ok := l.(*loggerImpl)
+	if !ok {
+		return l
+	}
+	skipped := &loggerImpl{
+		logger:    impl.logger.WithOptions(zap.AddCallerSkip(1)),
+		component: impl.component,
+	}
+	if impl.base != nil {
+		skipped.base = impl.base.WithOptions(zap.AddCallerSkip(1))
+	}
+	return skipped
+}
+
+func (t *teeLogger) Info(msg string, fields ...zap.Field) {
+	for _, l := range t.loggers {
+		l.Info(msg, fields...)
+	}
+}
+
+func (t *teeLogger) Error(msg string, fields ...zap.Field) {
+	for _, l := range t.loggers {
+		l.Error(msg, fields...)
+	}
+}
+
+func (t *teeLogger) Warn(msg string, fields ...zap.Field) {
+	for _, l := range t.loggers {
+		l.Warn(msg, fields...)
+	}
+}
+
+func (t *teeLogger) Debug(msg string, fields ...zap.Field) {
+	for _, l := range t.loggers {
+		l.Debug(msg, fields...)
+	}
+}
+
+// Fatal goes through one zap logger over all cores, the first Fatal would exit before the others write
+func (t *teeLogger) Fatal(msg string, fields ...zap.Field) {
+	t.With().Fatal(msg, fields...)
+}
+
+func (t *teeLogger) InfoCtx(ctx context.Context, msg string, fields ...zap.Field) {
+	for _, l := range t.loggers {
+		l.InfoCtx(ctx, msg, fields...)
+	}
+}
+
+func (t *teeLogger) ErrorCtx(ctx context.Context, msg string, fields ...zap.Field) {
+	for _, l := range t.loggers {
+		l.ErrorCtx(ctx, msg, fields...)
+	}
+}
+
+func (t *teeLogger) WarnCtx(ctx context.Context, msg string, fields ...zap.Field) {
+	for _, l := range t.loggers {
+		l.WarnCtx(ctx, msg, fields...)
+	}
+}
+
+func (t *teeLogger) DebugCtx(ctx context.Context, msg string, fields ...zap.Field) {
+	for _, l := range t.loggers {
+		l.DebugCtx(ctx, msg, fields...)
+	}
+}
+
+func (t *teeLogger) FatalCtx(ctx context.Context, msg string, fields ...zap.Field) {
+	t.With().Fatal(msg, appendContextFields(ctx, fields)...)
+}
+
+// Sync flushes all loggers, also when one of them fails
+func (t *teeLogger) Sync() error {
+	var errs []error
+	for _, l := range t.loggers {
+		errs = append(errs, l.Sync())
+	}
+	return errors.Join(errs...)
+}
+
+// With returns a zap logger writing to the cores of all loggers
+func (t *teeLogger) With(fields ...zap.Field) *zap.Logger {
+	cores := make([]zapcore.Core, 0, len(t.loggers))
+	for _, l := range t.loggers {
+		cores = append(cores, l.With(fields...).Core())
+	}
+	return zap.New(zapcore.NewTee(cores...), zap.AddCaller(), zap.AddCallerSkip(1))
+}
+
+func (t *teeLogger) Child(component string) Logger {
+	children := make([]Logger, 0, len(t.loggers))
+	for _, l := range t.loggers {
+		children = append(children, l.Child(component))
+	}
+	return &teeLogger{loggers: children}
+}
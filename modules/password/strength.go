@@ -0,0 +1,154 @@
+package password
+
+import (
+	_ "embed"
+	"strings"
+	"unicode"
+)
+
+// MaxScore is the score of the strongest passwords
+const MaxScore = 4
+
+// Suggestions returned by Score
+const (
+	SuggestLonger        = "Use at least 8 characters"
+	SuggestEvenLonger    = "Use 12 or more characters"
+	SuggestLowercase     = "Add lowercase letters"
+	SuggestUppercase     = "Add uppercase letters"
+	SuggestDigits        = "Add digits"
+	SuggestSymbols       = "Add symbols"
+	SuggestAvoidCommon   = "Avoid common passwords"
+	SuggestAvoidPatterns = "Avoid common patterns"
+)
+
+const (
+	minLength    = 8
+	strongLength = 12
+	phraseLength = 16 // long passphrases get an extra point regardless of character classes
+	minRunLength = 4  // shortest repeated or sequential run treated as a pattern
+)
+
+//go:embed common.txt
+var commonList string
+
+var commonPasswords = parseWordlist(commonList)
+
+// keyboardRows are checked for sequential runs such as "qwer" or "asdf"
+var keyboardRows = []string{
+	"1234567890",
+	"qwertyuiop",
+	"asdfghjkl",
+	"zxcvbnm",
+	"abcdefghijklmnopqrstuvwxyz",
+}
+
+// Score rates a password from 0 (very weak) to MaxScore (strong) and returns
+// suggestions for making it stronger.
+func Score(password string) (int, []string) {
+	var suggestions []string
+	lower := strings.ToLower(password)
+	length := len([]rune(password))
+
+	if length < minLength {
+		suggestions = append(suggestions, SuggestLonger)
+	} else if length < strongLength {
+		suggestions = append(suggestions, SuggestEvenLonger)
+	}
+
+	classes := 0
+	for _, class := range []struct {
+		present    bool
+		suggestion string
+	}{
+		{strings.IndexFunc(password, unicode.IsLower) >= 0, SuggestLowercase},
+		{strings.IndexFunc(password, unicode.IsUpper) >= 0, SuggestUppercase},
+		{strings.IndexFunc(password, unicode.IsDigit) >= 0, SuggestDigits},
+		{strings.IndexFunc(password, isSymbol) >= 0, SuggestSymbols},
+	} {
+		if class.present {
+			classes++
+		} else {
+			suggestions = append(suggestions, class.suggestion)
+		}
+	}
+
+	common := commonPasswords[lower]
+	if common {
+		suggestions = append(suggestions, SuggestAvoidCommon)
+	}
+	pattern := hasPattern(lower)
+	if pattern {
+		suggestions = append(suggestions, SuggestAvoidPatterns)
+	}
+
+	if length < minLength || common {
+		return 0, suggestions
+	}
+
+	// One character class gives 0, each extra class and a long password add a point
+	score := classes - 1
+	if length >= strongLength {
+		score++
+	}
+	if length >= phraseLength {
+		score++
+	}
+	if pattern {
+		score--
+	}
+
+	return min(max(score, 0), MaxScore), suggestions
+}
+
+// hasPattern detects repeated characters ("aaaa") and keyboard or alphabet runs ("1234", "dcba")
+func hasPattern(lower string) bool {
+	runes := []rune(lower)
+
+	repeat := 1
+	for i := 1; i < len(runes); i++ {
+		if runes[i] == runes[i-1] {
+			repeat++
+			if repeat >= minRunLength {
+				return true
+			}
+		} else {
+			repeat = 1
+		}
+	}
+
+	for i := 0; i+minRunLength <= len(runes); i++ {
+		chunk := string(runes[i : i+minRunLength])
+		reversed := reverse(chunk)
+		for _, row := range keyboardRows {
+			if strings.Contains(row, chunk) || strings.Contains(row, reversed) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func isSymbol(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsSpace(r)
+}
+
+func reverse(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
+
+func parseWordlist(list string) map[string]bool {
+	words := make(map[string]bool)
+	for _, line := range strings.Split(list, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		words[line] = true
+	}
+	return words
+}
@@ -0,0 +1,77 @@
+package password
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		name            string
+		password        string
+		wantScore       int
+		wantSuggestions []string
+	}{
+		{"empty", "", 0, []string{SuggestLonger, SuggestLowercase, SuggestUppercase, SuggestDigits, SuggestSymbols}},
+		{"7 characters", "mzkqTW7", 0, []string{SuggestLonger, SuggestSymbols}},
+		{"8 characters", "mzkqTW7!", 3, []string{SuggestEvenLonger}},
+		{"11 characters", "mzkqTW7!pLx", 3, []string{SuggestEvenLonger}},
+		{"12 characters", "mzkqTW7!pLx9", 4, nil},
+		{"16 characters capped", "mzkqTW7!pLx9rGb!", 4, nil},
+
+		{"one class", "mzkqtwhv", 0, []string{SuggestEvenLonger, SuggestUppercase, SuggestDigits, SuggestSymbols}},
+		{"two classes", "mzkqTWhv", 1, []string{SuggestEvenLonger, SuggestDigits, SuggestSymbols}},
+		{"three classes", "mzkqTW7v", 2, []string{SuggestEvenLonger, SuggestSymbols}},
+		{"only uppercase", "MZKQTWHV", 0, []string{SuggestEvenLonger, SuggestLowercase, SuggestDigits, SuggestSymbols}},
+		{"only digits", "90817263", 0, []string{SuggestEvenLonger, SuggestLowercase, SuggestUppercase, SuggestSymbols}},
+		{"space is not a symbol", "mzkq TWhv", 1, []string{SuggestEvenLonger, SuggestDigits, SuggestSymbols}},
+
+		{"one class 15 characters", "mzkqtwhvplxrgbn", 1, []string{SuggestUppercase, SuggestDigits, SuggestSymbols}},
+		{"one class 16 characters", "mzkqtwhvplxrgbnd", 2, []string{SuggestUppercase, SuggestDigits, SuggestSymbols}},
+
+		{"common", "password", 0, []string{SuggestEvenLonger, SuggestUppercase, SuggestDigits, SuggestSymbols, SuggestAvoidCommon}},
+		{"common ignores case", "PassWord", 0, []string{SuggestEvenLonger, SuggestDigits, SuggestSymbols, SuggestAvoidCommon}},
+		{"common with pattern", "Qwerty123", 0, []string{SuggestEvenLonger, SuggestSymbols, SuggestAvoidCommon, SuggestAvoidPatterns}},
+		{"short common", "1234", 0, []string{SuggestLonger, SuggestLowercase, SuggestUppercase, SuggestSymbols, SuggestAvoidPatterns}},
+
+		{"repeated characters", "mzkqTW7!aaaa", 3, []string{SuggestAvoidPatterns}},
+		{"keyboard run", "Zq9!asdfGhTy", 3, []string{SuggestAvoidPatterns}},
+		{"reversed digit run", "Zq9!4321GhTy", 3, []string{SuggestAvoidPatterns}},
+		{"alphabet run", "Zq9!mnopGhTy", 3, []string{SuggestAvoidPatterns}},
+		{"pattern cannot go below zero", "aaaabbbb", 0, []string{SuggestEvenLonger, SuggestUppercase, SuggestDigits, SuggestSymbols, SuggestAvoidPatterns}},
+		{"three repeats are fine", "mzkqTW7!aaa", 3, []string{SuggestEvenLonger}},
+
+		{"length counts runes", "ÄÖÜäöü1!", 3, []string{SuggestEvenLonger}},
+		{"short in runes", "пароль1", 0, []string{SuggestLonger, SuggestUppercase, SuggestSymbols}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score, suggestions := Score(tt.password)
+			if score != tt.wantScore {
+				t.Errorf("Score(%q) = %d, want %d", tt.password, score, tt.wantScore)
+			}
+			if !reflect.DeepEqual(suggestions, tt.wantSuggestions) {
+				t.Errorf("Score(%q) suggestions = %q, want %q", tt.password, suggestions, tt.wantSuggestions)
+			}
+		})
+	}
+}
+
+func TestCommonPasswordsScoreZero(t *testing.T) {
+	if len(commonPasswords) == 0 {
+		t.Fatal("embedded wordlist is empty")
+	}
+	for word := range commonPasswords {
+		if score, _ := Score(word); score != 0 {
+			t.Errorf("Score(%q) = %d, want 0 for a common password", word, score)
+		}
+	}
+}
+
+func TestParseWordlist(t *testing.T) {
+	got := parseWordlist("# comment\nfirst\n\n  second  \n#second comment\n")
+	want := map[string]bool{"first": true, "second": true}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseWordlist() = %v, want %v", got, want)
+	}
+}
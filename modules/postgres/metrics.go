@@ -0,0 +1,102 @@
+package postgres
+
+import (
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultMetricsInterval is used when StartPoolMetricsCollector gets a non-positive interval
+const DefaultMetricsInterval = 15 * time.Second
+
+// poolStats is the subset of pgxpool.Stat used for metrics
+type poolStats interface {
+	AcquiredConns() int32
+	IdleConns() int32
+	TotalConns() int32
+	EmptyAcquireCount() int64
+}
+
+// poolMetrics holds Prometheus collectors for connection pool stats
+type poolMetrics struct {
+	acquired  prometheus.Gauge
+	idle      prometheus.Gauge
+	total     prometheus.Gauge
+	waitCount prometheus.Counter
+
+	lastWaitCount int64
+}
+
+func newPoolMetrics(registry prometheus.Registerer) (*poolMetrics, error) {
+	m := &poolMetrics{
+		acquired: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "lovebin_postgres_connections_acquired",
+			Help: "Number of currently acquired connections in the pool.",
+		}),
+		idle: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "lovebin_postgres_connections_idle",
+			Help: "Number of currently idle connections in the pool.",
+		}),
+		total: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "lovebin_postgres_connections_total",
+			Help: "Total number of connections currently in the pool.",
+		}),
+		waitCount: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "lovebin_postgres_wait_count_total",
+			Help: "Cumulative number of acquires that waited for a connection.",
+		}),
+	}
+
+	for _, collector := range []prometheus.Collector{m.acquired, m.idle, m.total, m.waitCount} {
+		if err := registry.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// update copies pool stats into the collectors
+func (m *poolMetrics) update(stat poolStats) {
+	m.acquired.Set(float64(stat.AcquiredConns()))
+	m.idle.Set(float64(stat.IdleConns()))
+	m.total.Set(float64(stat.TotalConns()))
+
+	// pgxpool reports a cumulative value, the counter only takes increments
+	waitCount := stat.EmptyAcquireCount()
+	if delta := waitCount - m.lastWaitCount; delta > 0 {
+		m.waitCount.Add(float64(delta))
+	}
+	m.lastWaitCount = waitCount
+}
+
+// StartPoolMetricsCollector registers pool gauges and refreshes them every interval.
+// The returned stop function ends the collector goroutine.
+func StartPoolMetricsCollector(pool *pgxpool.Pool, registry prometheus.Registerer, interval time.Duration) (stop func(), err error) {
+	if interval <= 0 {
+		interval = DefaultMetricsInterval
+	}
+
+	metrics, err := newPoolMetrics(registry)
+	if err != nil {
+		return nil, err
+	}
+	metrics.update(pool.Stat())
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				metrics.update(pool.Stat())
+			}
+		}
+	}()
+
+	return func() { close(done) }, nil
+}
@@ -0,0 +1,268 @@
+package postgres
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// gooseTable is the version table of goose, which the Makefile and deploy scripts migrate with
+const gooseTable = "goose_db_version"
+
+// migrationLockID is the advisory lock key held while Migrator.Up runs
+const migrationLockID = 0x6c6f7665626e // "lovebn"
+
+// Migration is a goose SQL migration
+type Migration struct {
+	Version     int64
+	Description string     // file name without version and extension, underscores as spaces
+	AppliedAt   *time.Time // nil while pending
+
+	file string
+}
+
+// MigrationStatus lists applied and pending migrations, both ordered by version
+type MigrationStatus struct {
+	Applied []Migration
+	Pending []Migration
+}
+
+// Migrator reports and applies the goose migrations of fsys ("<version>_<name>.sql" files)
+type Migrator struct {
+	pool *pgxpool.Pool
+	fsys fs.FS
+}
+
+// NewMigrator returns a Migrator for the migrations in fsys
+func NewMigrator(pool *pgxpool.Pool, fsys fs.FS) *Migrator {
+	return &Migrator{pool: pool, fsys: fsys}
+}
+
+// Status compares the migration files with the goose version table.
+// A database goose never ran on has every migration pending.
+func (m *Migrator) Status(ctx context.Context) (MigrationStatus, error) {
+	return m.status(ctx, m.pool)
+}
+
+// Up applies the pending migrations in version order and returns them. Each one runs in
+// its own transaction unless marked "-- +goose NO TRANSACTION". Concurrent calls, also
+// from other instances, wait for each other on an advisory lock.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	conn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", int64(migrationLockID)); err != nil {
+		return nil, fmt.Errorf("failed to lock migrations: %w", err)
+	}
+	defer func() {
+		_, _ = conn.Exec(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", int64(migrationLockID))
+	}()
+
+	if err := ensureGooseTable(ctx, conn); err != nil {
+		return nil, err
+	}
+	status, err := m.status(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := make([]Migration, 0, len(status.Pending))
+	for _, migration := range status.Pending {
+		if err := m.apply(ctx, conn, migration); err != nil {
+			return applied, fmt.Errorf("migration %d (%s): %w", migration.Version, migration.Description, err)
+		}
+		now := time.Now().UTC()
+		migration.AppliedAt = &now
+		applied = append(applied, migration)
+	}
+	return applied, nil
+}
+
+// querier is what status needs from a pool or a single connection
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+func (m *Migrator) status(ctx context.Context, db querier) (MigrationStatus, error) {
+	files, err := m.files()
+	if err != nil {
+		return MigrationStatus{}, err
+	}
+
+	appliedAt, err := appliedVersions(ctx, db)
+	if err != nil {
+		return MigrationStatus{}, err
+	}
+
+	var status MigrationStatus
+	for _, migration := range files {
+		if at, ok := appliedAt[migration.Version]; ok {
+			migration.AppliedAt = &at
+			status.Applied = append(status.Applied, migration)
+		} else {
+			status.Pending = append(status.Pending, migration)
+		}
+	}
+	return status, nil
+}
+
+// appliedVersions returns the applied versions from the goose table with the time they were applied
+func appliedVersions(ctx context.Context, db querier) (map[int64]time.Time, error) {
+	// The latest row of a version tells whether it is applied, goose appends one per up and down
+	rows, err := db.Query(ctx, `SELECT DISTINCT ON (version_id) version_id, is_applied, tstamp
+		FROM `+gooseTable+` ORDER BY version_id, id DESC`)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "42P01" { // undefined_table: goose never ran
+			return map[int64]time.Time{}, nil
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var (
+			version   int64
+			isApplied bool
+			tstamp    *time.Time
+		)
+		if err := rows.Scan(&version, &isApplied, &tstamp); err != nil {
+			return nil, err
+		}
+		if !isApplied {
+			continue
+		}
+		if tstamp != nil {
+			applied[version] = tstamp.UTC()
+		} else {
+			applied[version] = time.Time{}
+		}
+	}
+	return applied, rows.Err()
+}
+
+// files lists the migration files of fsys ordered by version
+func (m *Migrator) files() ([]Migration, error) {
+	names, err := fs.Glob(m.fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make([]Migration, 0, len(names))
+	for _, name := range names {
+		prefix, rest, _ := strings.Cut(strings.TrimSuffix(name, ".sql"), "_")
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil || version < 1 {
+			// goose skips files without a version prefix as well
+			continue
+		}
+		migrations = append(migrations, Migration{
+			Version:     version,
+			Description: strings.ReplaceAll(rest, "_", " "),
+			file:        name,
+		})
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// apply runs the Up section of a migration and records it in the goose table
+func (m *Migrator) apply(ctx context.Context, conn *pgxpool.Conn, migration Migration) error {
+	data, err := fs.ReadFile(m.fsys, migration.file)
+	if err != nil {
+		return err
+	}
+	up, noTx := parseGooseUp(string(data))
+	const record = "INSERT INTO " + gooseTable + " (version_id, is_applied) VALUES ($1, TRUE)"
+
+	if noTx {
+		// The simple protocol runs every statement of the section in one round trip
+		if err := conn.Conn().PgConn().Exec(ctx, up).Close(); err != nil {
+			return err
+		}
+		_, err := conn.Exec(ctx, record, migration.Version)
+		return err
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	if err := tx.Conn().PgConn().Exec(ctx, up).Close(); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, record, migration.Version); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// parseGooseUp returns the SQL between "-- +goose Up" and "-- +goose Down" and whether
+// the file is marked "-- +goose NO TRANSACTION". StatementBegin/End markers need no
+// handling since the whole section is sent at once.
+func parseGooseUp(sql string) (up string, noTx bool) {
+	var (
+		b    strings.Builder
+		inUp bool
+	)
+	scanner := bufio.NewScanner(strings.NewReader(sql))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(sql)+1)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if annotation, ok := strings.CutPrefix(strings.TrimSpace(line), "-- +goose "); ok {
+			switch strings.TrimSpace(annotation) {
+			case "Up":
+				inUp = true
+			case "Down":
+				inUp = false
+			case "NO TRANSACTION":
+				noTx = true
+			}
+			continue
+		}
+		if inUp {
+			b.WriteString(line)
+			b.WriteByte('\n')
+		}
+	}
+	return b.String(), noTx
+}
+
+// ensureGooseTable creates the goose version table like goose does on its first run
+func ensureGooseTable(ctx context.Context, conn *pgxpool.Conn) error {
+	var exists bool
+	if err := conn.QueryRow(ctx, "SELECT to_regclass($1) IS NOT NULL", gooseTable).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	_, err := conn.Exec(ctx, `CREATE TABLE `+gooseTable+` (
+		id serial NOT NULL,
+		version_id bigint NOT NULL,
+		is_applied boolean NOT NULL,
+		tstamp timestamp NULL DEFAULT now(),
+		PRIMARY KEY (id)
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", gooseTable, err)
+	}
+	_, err = conn.Exec(ctx, "INSERT INTO "+gooseTable+" (version_id, is_applied) VALUES (0, TRUE)")
+	return err
+}
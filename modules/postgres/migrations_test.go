@@ -0,0 +1,253 @@
+package postgres
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"lovebin/migrations"
+)
+
+func TestParseGooseUp(t *testing.T) {
+	tests := []struct {
+		name     string
+		sql      string
+		wantUp   string
+		wantNoTx bool
+	}{
+		{
+			name:   "up and down",
+			sql:    "-- +goose Up\nCREATE TABLE t (id int);\n\n-- +goose Down\nDROP TABLE t;\n",
+			wantUp: "CREATE TABLE t (id int);\n\n",
+		},
+		{
+			name:   "statement markers",
+			sql:    "-- +goose Up\n-- +goose StatementBegin\nSELECT 1;\n-- +goose StatementEnd\n-- +goose Down\n-- +goose StatementBegin\nSELECT 2;\n-- +goose StatementEnd\n",
+			wantUp: "SELECT 1;\n",
+		},
+		{
+			name:     "no transaction",
+			sql:      "-- +goose NO TRANSACTION\n-- +goose Up\nCREATE INDEX CONCURRENTLY i ON t (id);\n",
+			wantUp:   "CREATE INDEX CONCURRENTLY i ON t (id);\n",
+			wantNoTx: true,
+		},
+		{
+			name:   "indented annotations",
+			sql:    "  -- +goose Up  \nSELECT 1;\n\t-- +goose Down\nSELECT 2;\n",
+			wantUp: "SELECT 1;\n",
+		},
+		{
+			name:   "text before up",
+			sql:    "-- a comment\nSELECT 0;\n-- +goose Up\nSELECT 1;\n",
+			wantUp: "SELECT 1;\n",
+		},
+		{
+			name:   "down only",
+			sql:    "-- +goose Down\nDROP TABLE t;\n",
+			wantUp: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			up, noTx := parseGooseUp(tt.sql)
+			if up != tt.wantUp || noTx != tt.wantNoTx {
+				t.Errorf("parseGooseUp = %q, %v, want %q, %v", up, noTx, tt.wantUp, tt.wantNoTx)
+			}
+		})
+	}
+}
+
+func TestMigratorFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"20260102000000_second_step.sql": {Data: []byte("-- +goose Up\n")},
+		"20260101000000_first.sql":       {Data: []byte("-- +goose Up\n")},
+		"3_short_version.sql":            {Data: []byte("-- +goose Up\n")},
+		"README.md":                      {Data: []byte("not a migration")},
+		"notes.sql":                      {Data: []byte("no version")},
+		"0_zero.sql":                     {Data: []byte("version 0 is goose's own")},
+	}
+	files, err := NewMigrator(nil, fsys).files()
+	if err != nil {
+		t.Fatalf("files: %v", err)
+	}
+
+	type file struct {
+		version     int64
+		description string
+	}
+	var got []file
+	for _, migration := range files {
+		got = append(got, file{migration.Version, migration.Description})
+	}
+	want := []file{
+		{3, "short version"},
+		{20260101000000, "first"},
+		{20260102000000, "second step"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("files = %+v, want %+v", got, want)
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	files, err := NewMigrator(nil, migrations.FS).files()
+	if err != nil {
+		t.Fatalf("files: %v", err)
+	}
+	entries, _ := migrations.FS.ReadDir(".")
+	sqlFiles := 0
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), ".sql") {
+			sqlFiles++
+		}
+	}
+	if len(files) == 0 || len(files) != sqlFiles {
+		t.Fatalf("found %d migrations among %d embedded SQL files", len(files), sqlFiles)
+	}
+
+	for i, migration := range files {
+		if i > 0 && migration.Version == files[i-1].Version {
+			t.Errorf("version %d used twice", migration.Version)
+		}
+		data, err := migrations.FS.ReadFile(migration.file)
+		if err != nil {
+			t.Fatalf("read %s: %v", migration.file, err)
+		}
+		if up, _ := parseGooseUp(string(data)); strings.TrimSpace(up) == "" {
+			t.Errorf("%s has no Up section", migration.file)
+		}
+	}
+}
+
+// newMigrationPool returns a pool whose connections use a fresh schema, so the
+// goose table of the test database is left alone
+func newMigrationPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	ctx := context.Background()
+	admin, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		t.Fatalf("pgxpool.New: %v", err)
+	}
+	t.Cleanup(admin.Close)
+	if _, err := admin.Exec(ctx, `DROP SCHEMA IF EXISTS migrator_test CASCADE; CREATE SCHEMA migrator_test`); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() { _, _ = admin.Exec(context.Background(), `DROP SCHEMA IF EXISTS migrator_test CASCADE`) })
+
+	cfg, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		t.Fatalf("pgxpool.ParseConfig: %v", err)
+	}
+	cfg.ConnConfig.RuntimeParams["search_path"] = "migrator_test"
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		t.Fatalf("pgxpool.NewWithConfig: %v", err)
+	}
+	t.Cleanup(pool.Close)
+	return pool
+}
+
+func versions(migrations []Migration) []int64 {
+	var versions []int64
+	for _, migration := range migrations {
+		versions = append(versions, migration.Version)
+	}
+	return versions
+}
+
+func TestMigratorUp(t *testing.T) {
+	pool := newMigrationPool(t)
+	ctx := context.Background()
+	fsys := fstest.MapFS{
+		"1_create_items.sql": {Data: []byte("-- +goose Up\n-- +goose StatementBegin\nCREATE TABLE items (id int);\n-- +goose StatementEnd\n-- +goose Down\nDROP TABLE items;\n")},
+		"2_add_name.sql":     {Data: []byte("-- +goose Up\nALTER TABLE items ADD COLUMN name text;\nINSERT INTO items VALUES (1, 'one');\n-- +goose Down\nALTER TABLE items DROP COLUMN name;\n")},
+		"3_index.sql":        {Data: []byte("-- +goose NO TRANSACTION\n-- +goose Up\nCREATE INDEX CONCURRENTLY items_name ON items (name);\n")},
+	}
+	m := NewMigrator(pool, fsys)
+
+	// Before goose ever ran everything is pending
+	status, err := m.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if len(status.Applied) != 0 || !reflect.DeepEqual(versions(status.Pending), []int64{1, 2, 3}) {
+		t.Fatalf("Status = %+v, want every migration pending", status)
+	}
+
+	applied, err := m.Up(ctx)
+	if err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if !reflect.DeepEqual(versions(applied), []int64{1, 2, 3}) {
+		t.Errorf("Up applied %v, want 1, 2, 3", versions(applied))
+	}
+	var name string
+	if err := pool.QueryRow(ctx, `SELECT name FROM items WHERE id = 1`).Scan(&name); err != nil || name != "one" {
+		t.Errorf("items row = %q, %v, want the migrated data", name, err)
+	}
+
+	status, err = m.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if len(status.Pending) != 0 || !reflect.DeepEqual(versions(status.Applied), []int64{1, 2, 3}) {
+		t.Errorf("Status after Up = %+v, want every migration applied", status)
+	}
+	for _, migration := range status.Applied {
+		if migration.AppliedAt == nil || migration.AppliedAt.IsZero() {
+			t.Errorf("migration %d has no applied time", migration.Version)
+		}
+	}
+
+	// A second run has nothing to do
+	if applied, err := m.Up(ctx); err != nil || len(applied) != 0 {
+		t.Errorf("second Up = %v, %v, want nothing applied", versions(applied), err)
+	}
+
+	// goose appends a row when rolling back, the latest row decides
+	if _, err := pool.Exec(ctx, `INSERT INTO goose_db_version (version_id, is_applied) VALUES (3, FALSE)`); err != nil {
+		t.Fatalf("record rollback: %v", err)
+	}
+	status, err = m.Status(ctx)
+	if err != nil || !reflect.DeepEqual(versions(status.Pending), []int64{3}) {
+		t.Errorf("Status after a rollback = %+v, %v, want 3 pending", status, err)
+	}
+}
+
+func TestMigratorUpStopsAtAFailure(t *testing.T) {
+	pool := newMigrationPool(t)
+	ctx := context.Background()
+	fsys := fstest.MapFS{
+		"1_create_items.sql": {Data: []byte("-- +goose Up\nCREATE TABLE items (id int);\n")},
+		"2_broken.sql":       {Data: []byte("-- +goose Up\nCREATE TABLE half (id int);\nSELECT * FROM missing_table;\n")},
+		"3_never.sql":        {Data: []byte("-- +goose Up\nCREATE TABLE never (id int);\n")},
+	}
+	m := NewMigrator(pool, fsys)
+
+	applied, err := m.Up(ctx)
+	if err == nil || !strings.Contains(err.Error(), "migration 2 (broken)") {
+		t.Fatalf("Up error = %v, want the failed migration", err)
+	}
+	if !reflect.DeepEqual(versions(applied), []int64{1}) {
+		t.Errorf("Up applied %v before failing, want 1", versions(applied))
+	}
+
+	// The failed migration was rolled back as a whole
+	var exists bool
+	if err := pool.QueryRow(ctx, `SELECT to_regclass('half') IS NOT NULL`).Scan(&exists); err != nil || exists {
+		t.Errorf("table of the failed migration exists = %v, %v", exists, err)
+	}
+	status, err := m.Status(ctx)
+	if err != nil || !reflect.DeepEqual(versions(status.Pending), []int64{2, 3}) {
+		t.Errorf("Status = %+v, %v, want 2 and 3 pending", status, err)
+	}
+}
@@ -0,0 +1,139 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// fakeTB records AssertQuery failures instead of failing the test
+type fakeTB struct {
+	errors []string
+}
+
+func (f *fakeTB) Helper() {}
+
+func (f *fakeTB) Errorf(format string, args ...any) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+
+func TestMockPostgres(t *testing.T) {
+	m, err := NewMockPostgres(nil)
+	if err != nil {
+		t.Fatalf("NewMockPostgres: %v", err)
+	}
+	if m.GetPool() == nil {
+		t.Fatal("GetPool returned nil")
+	}
+
+	// Nothing listens on the mock DSN, queries fail fast instead of hanging
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	called := false
+	err = m.WithTx(ctx, func(pgx.Tx) error {
+		called = true
+		return nil
+	})
+	if err == nil || called {
+		t.Errorf("WithTx = %v, fn called %v, want a connection error before fn", err, called)
+	}
+	if ctx.Err() != nil {
+		t.Error("WithTx waited for the context deadline")
+	}
+
+	m.Close()
+	if err := m.GracefulClose(context.Background()); err != nil {
+		t.Errorf("GracefulClose after Close: %v", err)
+	}
+	if n := m.CloseCalls(); n != 2 {
+		t.Errorf("CloseCalls = %d, want 2", n)
+	}
+}
+
+func newTestRecorder(t *testing.T) *RecordingPostgres {
+	t.Helper()
+	inner, err := NewMockPostgres(nil)
+	if err != nil {
+		t.Fatalf("NewMockPostgres: %v", err)
+	}
+	r, err := NewRecordingPostgres(context.Background(), inner)
+	if err != nil {
+		t.Fatalf("NewRecordingPostgres: %v", err)
+	}
+	t.Cleanup(r.Close)
+	return r
+}
+
+func TestRecordingPostgresRecordsQueries(t *testing.T) {
+	r := newTestRecorder(t)
+	if r.GetPool() == nil || r.GetPool().Config().ConnConfig.Tracer != r {
+		t.Fatal("recording pool does not trace through the recorder")
+	}
+
+	ctx := context.Background()
+	args := []any{"key", 3}
+	before := time.Now()
+	r.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{SQL: "SELECT 1", Args: args})
+	r.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{SQL: "UPDATE media_resources SET viewed = true WHERE resource_key = $1"})
+	args[0] = "changed" // the recorder keeps its own copy of the arguments
+
+	queries := r.Queries()
+	if len(queries) != 2 {
+		t.Fatalf("recorded %d queries, want 2", len(queries))
+	}
+	if queries[0].SQL != "SELECT 1" || queries[0].Args[0] != "key" || queries[0].Args[1] != 3 {
+		t.Errorf("first query = %+v", queries[0])
+	}
+	if queries[0].At.Before(before) || queries[1].At.Before(queries[0].At) {
+		t.Errorf("query times %v, %v are not in execution order", queries[0].At, queries[1].At)
+	}
+
+	queries[0].SQL = "modified"
+	if r.Queries()[0].SQL != "SELECT 1" {
+		t.Error("Queries returned the recorder's own slice")
+	}
+
+	r.Reset()
+	if n := len(r.Queries()); n != 0 {
+		t.Errorf("%d queries left after Reset", n)
+	}
+}
+
+func TestRecordingPostgresAssertQuery(t *testing.T) {
+	r := newTestRecorder(t)
+	r.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: `
+		SELECT id, resource_key
+		FROM media_resources
+		WHERE resource_key = $1
+		FOR UPDATE`})
+
+	tests := []struct {
+		pattern string
+		want    bool
+	}{
+		{"FOR UPDATE", true},
+		{"for update", true},
+		{"FROM media_resources WHERE resource_key = $1", true},
+		{"from   media_resources\n\twhere", true},
+		{"DELETE FROM media_resources", false},
+		{"resource_key = $2", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			tb := &fakeTB{}
+			if got := r.AssertQuery(tb, tt.pattern); got != tt.want {
+				t.Errorf("AssertQuery(%q) = %v, want %v", tt.pattern, got, tt.want)
+			}
+			if failed := len(tb.errors) > 0; failed == tt.want {
+				t.Errorf("AssertQuery(%q) reported %q", tt.pattern, tb.errors)
+			}
+			if !tt.want && len(tb.errors) > 0 && !strings.Contains(tb.errors[0], "for update") {
+				t.Errorf("failure message %q does not list the recorded queries", tb.errors[0])
+			}
+		})
+	}
+}
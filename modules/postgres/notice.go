@@ -0,0 +1,42 @@
+package postgres
+
+import (
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap"
+
+	"lovebin/modules/logger"
+)
+
+// noticeHandler logs notices sent by the server, e.g. RAISE NOTICE in functions and
+// migrations, which pgx drops otherwise. The PostgreSQL severity picks the log level:
+// DEBUG is logged at debug, WARNING at warn and the others (LOG, INFO, NOTICE) at info.
+func noticeHandler(log logger.Logger) func(*pgconn.PgConn, *pgconn.Notice) {
+	return func(_ *pgconn.PgConn, notice *pgconn.Notice) {
+		// Translated by lc_messages, unknown names are logged at info
+		severity := notice.Severity
+
+		fields := []zap.Field{
+			zap.String("pg_severity", severity),
+			zap.String("pg_code", notice.Code),
+			zap.String("pg_message", notice.Message),
+		}
+		if notice.Detail != "" {
+			fields = append(fields, zap.String("pg_detail", notice.Detail))
+		}
+		if notice.Hint != "" {
+			fields = append(fields, zap.String("pg_hint", notice.Hint))
+		}
+		if notice.Where != "" {
+			fields = append(fields, zap.String("pg_where", notice.Where))
+		}
+
+		switch severity {
+		case "DEBUG":
+			log.Debug("postgres notice", fields...)
+		case "WARNING":
+			log.Warn("postgres notice", fields...)
+		default:
+			log.Info("postgres notice", fields...)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package postgres
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestNoticeHandler(t *testing.T) {
+	tests := []struct {
+		severity  string
+		wantLevel string
+	}{
+		{"DEBUG", "debug"},
+		{"LOG", "info"},
+		{"INFO", "info"},
+		{"NOTICE", "info"},
+		{"WARNING", "warn"},
+		// Translated by lc_messages
+		{"ПРЕДУПРЕЖДЕНИЕ", "info"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.severity, func(t *testing.T) {
+			log, entries := newFileLogger(t, "debug")
+			noticeHandler(log)(nil, &pgconn.Notice{Severity: tt.severity, Code: "00000", Message: "hello"})
+
+			logged := entries()
+			if len(logged) != 1 {
+				t.Fatalf("logged %+v, want one entry", logged)
+			}
+			entry := logged[0]
+			if entry.Level != tt.wantLevel || entry.Message != "postgres notice" {
+				t.Errorf("logged %q at %s, want postgres notice at %s", entry.Message, entry.Level, tt.wantLevel)
+			}
+			if entry.PGSeverity != tt.severity || entry.PGCode != "00000" || entry.PGMessage != "hello" {
+				t.Errorf("logged %+v, want the notice fields", entry)
+			}
+		})
+	}
+}
+
+func TestNoticeHandlerOptionalFields(t *testing.T) {
+	log, entries := newFileLogger(t, "debug")
+	handle := noticeHandler(log)
+	handle(nil, &pgconn.Notice{Severity: "NOTICE", Message: "bare"})
+	handle(nil, &pgconn.Notice{Severity: "NOTICE", Message: "full", Detail: "detail", Hint: "hint", Where: "PL/pgSQL function f() line 3"})
+
+	logged := entries()
+	if len(logged) != 2 {
+		t.Fatalf("logged %+v, want two entries", logged)
+	}
+	if bare := logged[0]; bare.PGDetail != nil || bare.PGHint != nil || bare.PGWhere != nil {
+		t.Errorf("bare notice logged empty fields: %+v", bare)
+	}
+	full := logged[1]
+	if full.PGDetail == nil || *full.PGDetail != "detail" || full.PGHint == nil || *full.PGHint != "hint" ||
+		full.PGWhere == nil || *full.PGWhere != "PL/pgSQL function f() line 3" {
+		t.Errorf("full notice = %+v, want detail, hint and where", full)
+	}
+}
+
+func TestInitLogsNotices(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	conn, err := pgconn.ParseConfig(dsn)
+	if err != nil {
+		t.Fatalf("pgconn.ParseConfig: %v", err)
+	}
+
+	log, entries := newFileLogger(t, "debug")
+	pg, err := Init(context.Background(), Config{
+		Host:         conn.Host,
+		Port:         strconv.Itoa(int(conn.Port)),
+		User:         conn.User,
+		Password:     conn.Password,
+		DBName:       conn.Database,
+		SSLMode:      "disable",
+		LogPGNotices: true,
+	}, log)
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	defer pg.Close()
+
+	_, err = pg.GetPool().Exec(context.Background(), `DO $$ BEGIN RAISE WARNING 'disk almost full' USING HINT = 'free some space'; END $$`)
+	if err != nil {
+		t.Fatalf("RAISE WARNING: %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		for _, entry := range entries() {
+			if entry.PGMessage == "disk almost full" {
+				if entry.Level != "warn" || entry.PGHint == nil || *entry.PGHint != "free some space" {
+					t.Errorf("logged %+v, want a warning with the hint", entry)
+				}
+				return
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the notice was not logged")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
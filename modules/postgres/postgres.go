@@ -3,14 +3,23 @@ package postgres
 import (
 	"context"
 	"fmt"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+
+	"lovebin/modules/logger"
 )
 
 // Postgres interface for dependency injection
 type Postgres interface {
 	GetPool() *pgxpool.Pool
 	Close()
+	// GracefulClose waits until no connection is acquired or ctx is done, then closes the pool.
+	// It returns ctx.Err() when queries were still running at the deadline.
+	GracefulClose(ctx context.Context) error
+	// WithTx runs fn in a transaction, committing if fn returns nil and rolling back otherwise
+	WithTx(ctx context.Context, fn func(tx pgx.Tx) error) error
 }
 
 type postgresImpl struct {
@@ -21,12 +30,43 @@ func (p *postgresImpl) GetPool() *pgxpool.Pool {
 	return p.pool
 }
 
+func (p *postgresImpl) WithTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	return withTx(ctx, p.pool, fn)
+}
+
 func (p *postgresImpl) Close() {
 	if p.pool != nil {
 		p.pool.Close()
 	}
 }
 
+func (p *postgresImpl) GracefulClose(ctx context.Context) error {
+	if p.pool == nil {
+		return nil
+	}
+	return gracefulClose(ctx, p.pool)
+}
+
+// drainPollInterval is how often gracefulClose checks for acquired connections
+const drainPollInterval = 10 * time.Millisecond
+
+// gracefulClose waits for in-flight queries and transactions to release their connections,
+// then closes the pool. The pool is closed even when ctx ends first.
+func gracefulClose(ctx context.Context, pool *pgxpool.Pool) error {
+	defer pool.Close()
+
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for pool.Stat().AcquiredConns() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
 // Config holds PostgreSQL configuration
 type Config struct {
 	Host     string
@@ -35,10 +75,55 @@ type Config struct {
 	Password string
 	DBName   string
 	SSLMode  string
+	// DrainTimeout bounds how long shutdown waits for in-flight queries (default 5s)
+	DrainTimeout time.Duration
+	// QueryExecMode selects how pgx sends queries, see the QueryExecMode* constants.
+	// Empty means QueryExecModeCacheStatement.
+	QueryExecMode string
+	// ValidateQueriesOnStartup prepares all queries on startup, see ValidateQueries
+	ValidateQueriesOnStartup bool
+	// PoolWatcher logs when the pool runs out of connections, see StartPoolWatcher
+	PoolWatcher PoolWatcherConfig
+	// LogPGNotices logs notices of the server (RAISE NOTICE, WARNING, ...) instead of dropping them
+	LogPGNotices bool
 }
 
-// Init initializes the PostgreSQL module
-func Init(ctx context.Context, cfg Config) (Postgres, error) {
+// DefaultDrainTimeout is used when Config.DrainTimeout is not set
+const DefaultDrainTimeout = 5 * time.Second
+
+// Supported values of Config.QueryExecMode
+const (
+	// QueryExecModeCacheStatement prepares each query once per connection and reuses
+	// the prepared statement. Fastest, needs a direct connection or PgBouncer in
+	// session mode.
+	QueryExecModeCacheStatement = "cache_statement"
+	// QueryExecModeSimpleProtocol sends queries as text with client-side parameter
+	// interpolation and no Parse/Describe. Use it behind PgBouncer in transaction
+	// mode, where prepared statements do not survive between transactions.
+	QueryExecModeSimpleProtocol = "simple_protocol"
+	// QueryExecModeDescribeExec describes every query with an unnamed statement before
+	// executing it. Uses the extended protocol without caching, so it also works behind
+	// transaction poolers, at the cost of an extra round trip per query.
+	QueryExecModeDescribeExec = "describe_exec"
+)
+
+// parseQueryExecMode maps a Config.QueryExecMode value to the pgx constant
+func parseQueryExecMode(mode string) (pgx.QueryExecMode, error) {
+	switch mode {
+	case "", QueryExecModeCacheStatement:
+		return pgx.QueryExecModeCacheStatement, nil
+	case QueryExecModeSimpleProtocol:
+		return pgx.QueryExecModeSimpleProtocol, nil
+	case QueryExecModeDescribeExec:
+		return pgx.QueryExecModeDescribeExec, nil
+	default:
+		return 0, fmt.Errorf("unknown query exec mode %q", mode)
+	}
+}
+
+// Init initializes the PostgreSQL module. Server notices are logged to log with
+// cfg.LogPGNotices (log may be nil otherwise).
+func Init(ctx context.Context, cfg Config, log logger.Logger) (Postgres, error) {
 	dsn := fmt.Sprintf(
 		"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
 		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.DBName, cfg.SSLMode,
@@ -49,6 +134,15 @@ func Init(ctx context.Context, cfg Config) (Postgres, error) {
 		return nil, fmt.Errorf("failed to parse database config: %w", err)
 	}
 
+	execMode, err := parseQueryExecMode(cfg.QueryExecMode)
+	if err != nil {
+		return nil, err
+	}
+	poolConfig.ConnConfig.DefaultQueryExecMode = execMode
+	if cfg.LogPGNotices && log != nil {
+		poolConfig.ConnConfig.OnNotice = noticeHandler(log.Child("postgres"))
+	}
+
 	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create connection pool: %w", err)
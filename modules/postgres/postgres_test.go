@@ -0,0 +1,107 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestParseQueryExecMode(t *testing.T) {
+	tests := []struct {
+		mode    string
+		want    pgx.QueryExecMode
+		wantErr bool
+	}{
+		{"", pgx.QueryExecModeCacheStatement, false},
+		{QueryExecModeCacheStatement, pgx.QueryExecModeCacheStatement, false},
+		{QueryExecModeSimpleProtocol, pgx.QueryExecModeSimpleProtocol, false},
+		{QueryExecModeDescribeExec, pgx.QueryExecModeDescribeExec, false},
+		{"cache_describe", 0, true},
+		{"SIMPLE_PROTOCOL", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			got, err := parseQueryExecMode(tt.mode)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseQueryExecMode(%q) error = %v, want error %v", tt.mode, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseQueryExecMode(%q) = %v, want %v", tt.mode, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInitRejectsUnknownQueryExecMode(t *testing.T) {
+	// The mode is checked before connecting, so no database is needed
+	_, err := Init(context.Background(), Config{Host: "127.0.0.1", Port: "1", QueryExecMode: "prepared"}, nil)
+	if err == nil || !strings.Contains(err.Error(), `unknown query exec mode "prepared"`) {
+		t.Errorf("Init error = %v, want the unknown mode", err)
+	}
+}
+
+func TestGracefulCloseWithoutConnections(t *testing.T) {
+	// Nothing listens here, but the pool connects lazily so no connection is acquired
+	pool, err := pgxpool.New(context.Background(), "postgres://user@127.0.0.1:1/db")
+	if err != nil {
+		t.Fatalf("pgxpool.New: %v", err)
+	}
+
+	start := time.Now()
+	if err := gracefulClose(context.Background(), pool); err != nil {
+		t.Errorf("gracefulClose: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("gracefulClose took %v with nothing to drain", elapsed)
+	}
+	if _, err := pool.Acquire(context.Background()); err == nil {
+		t.Error("pool still usable after gracefulClose")
+	}
+}
+
+func TestGracefulCloseWaitsForAcquiredConnections(t *testing.T) {
+	pool := newTestPool(t)
+	conn, err := pool.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- gracefulClose(context.Background(), pool) }()
+
+	select {
+	case err := <-done:
+		t.Fatalf("gracefulClose returned %v while a connection was acquired", err)
+	case <-time.After(5 * drainPollInterval):
+	}
+
+	conn.Release()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("gracefulClose: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("gracefulClose did not return after the connection was released")
+	}
+}
+
+func TestGracefulCloseDeadline(t *testing.T) {
+	pool := newTestPool(t)
+	conn, err := pool.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := gracefulClose(ctx, pool); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("gracefulClose error = %v, want context.DeadlineExceeded", err)
+	}
+}
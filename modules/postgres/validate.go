@@ -0,0 +1,88 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// QueryError is a query the database refused to prepare
+type QueryError struct {
+	Name string // sqlc name from the "-- name:" comment, or the position of the query
+	Err  error
+}
+
+func (e QueryError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Name, e.Err)
+}
+
+func (e QueryError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationErrors are all queries ValidateQueries found invalid
+type ValidationErrors []QueryError
+
+func (e ValidationErrors) Error() string {
+	lines := make([]string, 0, len(e)+1)
+	lines = append(lines, fmt.Sprintf("%d queries do not match the database schema:", len(e)))
+	for _, queryErr := range e {
+		lines = append(lines, "  "+queryErr.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (e ValidationErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, queryErr := range e {
+		errs[i] = queryErr
+	}
+	return errs
+}
+
+// validateStatementPrefix names the statements prepared by ValidateQueries
+const validateStatementPrefix = "lovebin_validate_"
+
+// ValidateQueries prepares every query on one connection, so queries referring to
+// missing tables, columns or functions fail on startup instead of at first use.
+// Statements are deallocated again. All invalid queries are returned together
+// as ValidationErrors; acquiring the connection fails with its own error.
+func ValidateQueries(ctx context.Context, pool *pgxpool.Pool, queries []string) error {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	var errs ValidationErrors
+	for i, sql := range queries {
+		name := fmt.Sprintf("%s%d", validateStatementPrefix, i)
+		if _, err := conn.Conn().Prepare(ctx, name, sql); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			errs = append(errs, QueryError{Name: queryName(sql, i), Err: err})
+			continue
+		}
+		if err := conn.Conn().Deallocate(ctx, name); err != nil {
+			return fmt.Errorf("deallocate %s: %w", queryName(sql, i), err)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// queryName returns the sqlc name of a query, e.g. GetMediaResourceByKey, or its position
+func queryName(sql string, i int) string {
+	firstLine, _, _ := strings.Cut(strings.TrimSpace(sql), "\n")
+	if rest, ok := strings.CutPrefix(firstLine, "-- name:"); ok {
+		if fields := strings.Fields(rest); len(fields) > 0 {
+			return fields[0]
+		}
+	}
+	return fmt.Sprintf("query %d", i+1)
+}
@@ -0,0 +1,88 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestQueryName(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want string
+	}{
+		{"sqlc", "-- name: GetMediaResourceByKey :one\nSELECT 1", "GetMediaResourceByKey"},
+		{"leading whitespace", "\n  -- name: DeleteMediaResource :exec\nDELETE FROM t", "DeleteMediaResource"},
+		{"no name", "SELECT 1", "query 3"},
+		{"empty name", "-- name:\nSELECT 1", "query 3"},
+		{"other comment", "-- fetch everything\nSELECT 1", "query 3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := queryName(tt.sql, 2); got != tt.want {
+				t.Errorf("queryName = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidationErrors(t *testing.T) {
+	missingTable := errors.New(`relation "gone" does not exist`)
+	missingColumn := errors.New(`column "nope" does not exist`)
+	var err error = ValidationErrors{
+		{Name: "GetGone", Err: missingTable},
+		{Name: "GetNope", Err: missingColumn},
+	}
+
+	want := "2 queries do not match the database schema:\n" +
+		"  GetGone: relation \"gone\" does not exist\n" +
+		"  GetNope: column \"nope\" does not exist"
+	if err.Error() != want {
+		t.Errorf("Error() =\n%s\nwant\n%s", err, want)
+	}
+	if !errors.Is(err, missingTable) || !errors.Is(err, missingColumn) {
+		t.Error("errors.Is does not reach the query errors")
+	}
+	var queryErr QueryError
+	if !errors.As(err, &queryErr) || queryErr.Name != "GetGone" {
+		t.Errorf("errors.As = %+v, want the first query error", queryErr)
+	}
+}
+
+func TestValidateQueries(t *testing.T) {
+	pool := newTestPool(t)
+	ctx := context.Background()
+
+	if err := ValidateQueries(ctx, pool, []string{
+		"-- name: ListValues :many\nSELECT value FROM tx_test",
+		"INSERT INTO tx_test (value) VALUES ($1)",
+	}); err != nil {
+		t.Fatalf("ValidateQueries of valid queries: %v", err)
+	}
+
+	err := ValidateQueries(ctx, pool, []string{
+		"-- name: ListValues :many\nSELECT value FROM tx_test",
+		"-- name: ListGone :many\nSELECT value FROM tx_test_gone",
+		"SELECT nope FROM tx_test",
+	})
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatalf("ValidateQueries = %v, want 2 invalid queries", err)
+	}
+	if errs[0].Name != "ListGone" || errs[1].Name != "query 3" {
+		t.Errorf("invalid queries = %s and %s, want ListGone and query 3", errs[0].Name, errs[1].Name)
+	}
+
+	// The statements are deallocated, validating again does not collide with them
+	if err := ValidateQueries(ctx, pool, []string{"SELECT value FROM tx_test"}); err != nil {
+		t.Errorf("second ValidateQueries: %v", err)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := ValidateQueries(cancelled, pool, []string{"SELECT 1"}); err == nil || strings.Contains(err.Error(), "do not match") {
+		t.Errorf("ValidateQueries with a cancelled context = %v, want the context error", err)
+	}
+}
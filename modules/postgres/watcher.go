@@ -0,0 +1,126 @@
+package postgres
+
+import (
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+
+	"lovebin/modules/logger"
+)
+
+// Defaults of PoolWatcherConfig
+const (
+	DefaultPoolWatcherInterval = 10 * time.Second
+	DefaultPoolWarnThreshold   = 0.8
+	DefaultPoolCritThreshold   = 0.95
+)
+
+// PoolWatcherConfig configures StartPoolWatcher
+type PoolWatcherConfig struct {
+	CheckInterval time.Duration // how often the pool is checked (default 10s)
+	WarnThreshold float64       // share of MaxConns acquired above which a warning is logged (default 0.8)
+	CritThreshold float64       // share of MaxConns acquired above which an error is logged (default 0.95)
+	// Registry exposes lovebin_postgres_pool_utilization when set
+	Registry prometheus.Registerer
+}
+
+// watchedStats is the subset of pgxpool.Stat checked by the pool watcher
+type watchedStats interface {
+	poolStats
+	MaxConns() int32
+	AcquireDuration() time.Duration
+}
+
+// poolWatcher logs and exports the pool utilization, see StartPoolWatcher
+type poolWatcher struct {
+	log         logger.Logger
+	cfg         PoolWatcherConfig
+	utilization prometheus.Gauge // nil without a registry
+}
+
+// StartPoolWatcher checks the pool every CheckInterval and logs a warning while more
+// than WarnThreshold of MaxConns is acquired, an error above CritThreshold. Requests
+// queue for connections once the pool is exhausted, the log shows when MaxConns is
+// too low. The returned stop function ends the watcher goroutine.
+func StartPoolWatcher(pool *pgxpool.Pool, log logger.Logger, cfg PoolWatcherConfig) (stop func()) {
+	if cfg.CheckInterval <= 0 {
+		cfg.CheckInterval = DefaultPoolWatcherInterval
+	}
+	if cfg.WarnThreshold <= 0 {
+		cfg.WarnThreshold = DefaultPoolWarnThreshold
+	}
+	if cfg.CritThreshold <= 0 {
+		cfg.CritThreshold = DefaultPoolCritThreshold
+	}
+
+	w := &poolWatcher{log: log.Child("postgres-pool"), cfg: cfg}
+	if cfg.Registry != nil {
+		utilization := prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "lovebin_postgres_pool_utilization",
+			Help: "Share of the maximum pool connections currently acquired, from 0 to 1.",
+		})
+		if err := cfg.Registry.Register(utilization); err != nil {
+			w.log.Warn("failed to register pool utilization metric", zap.Error(err))
+		} else {
+			w.utilization = utilization
+		}
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(cfg.CheckInterval)
+		defer ticker.Stop()
+
+		w.check(pool.Stat())
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				w.check(pool.Stat())
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+		})
+	}
+}
+
+// check logs stat when its utilization is above a threshold and returns the utilization
+func (w *poolWatcher) check(stat watchedStats) float64 {
+	if stat.MaxConns() <= 0 {
+		return 0
+	}
+	utilization := float64(stat.AcquiredConns()) / float64(stat.MaxConns())
+	if w.utilization != nil {
+		w.utilization.Set(utilization)
+	}
+
+	fields := []zap.Field{
+		zap.Float64("utilization", utilization),
+		zap.Int32("acquired", stat.AcquiredConns()),
+		zap.Int32("idle", stat.IdleConns()),
+		zap.Int32("total", stat.TotalConns()),
+		zap.Int32("max", stat.MaxConns()),
+		zap.Int64("empty_acquire_count", stat.EmptyAcquireCount()),
+		zap.Duration("acquire_duration", stat.AcquireDuration()),
+	}
+	switch {
+	case utilization > w.cfg.CritThreshold:
+		w.log.Error("postgres pool is exhausted, requests wait for connections", fields...)
+	case utilization > w.cfg.WarnThreshold:
+		w.log.Warn("postgres pool is nearly exhausted", fields...)
+	}
+	return utilization
+}
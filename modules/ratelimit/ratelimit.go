@@ -0,0 +1,87 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Algorithms selectable with New
+const (
+	AlgorithmTokenBucket   = "token_bucket"
+	AlgorithmSlidingWindow = "sliding_window"
+)
+
+// Decision is the outcome of one Limiter.Allow call
+type Decision struct {
+	Allowed   bool
+	Remaining int       // hits still allowed right now
+	Reset     time.Time // when Remaining grows next, now when it is at the limit
+}
+
+// Limiter allows at most limit hits of a key within window. Limits and windows
+// are given per call, so one limiter serves several routes; keys must then tell
+// the routes apart.
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit int, window time.Duration) (Decision, error)
+}
+
+// New returns the in-memory limiter of algorithm, empty picks the token bucket
+func New(algorithm string) (Limiter, error) {
+	switch algorithm {
+	case "", AlgorithmTokenBucket:
+		return NewTokenBucketLimiter(), nil
+	case AlgorithmSlidingWindow:
+		return NewSlidingWindowLimiter(), nil
+	default:
+		return nil, fmt.Errorf("unknown rate limit algorithm %q", algorithm)
+	}
+}
+
+// keyState is the per-key state of a limiter, guarded by its own mutex
+type keyState[T any] struct {
+	mu      sync.Mutex
+	state   T
+	expires time.Time // when the key no longer limits anything and can be dropped
+}
+
+// keyStore holds per-key limiter state and drops idle keys from time to time
+type keyStore[T any] struct {
+	states    sync.Map // key -> *keyState[T]
+	lastSweep atomic.Int64
+}
+
+// sweepInterval is the most often idle keys are looked for
+const sweepInterval = time.Minute
+
+// lock returns the locked state of key, the caller unlocks it
+func (s *keyStore[T]) lock(key string, now time.Time) *keyState[T] {
+	s.sweep(now)
+	value, ok := s.states.Load(key)
+	if !ok {
+		value, _ = s.states.LoadOrStore(key, &keyState[T]{})
+	}
+	state := value.(*keyState[T])
+	state.mu.Lock()
+	return state
+}
+
+// sweep drops keys that expired, at most once per sweepInterval
+func (s *keyStore[T]) sweep(now time.Time) {
+	last := s.lastSweep.Load()
+	if now.UnixNano()-last < int64(sweepInterval) || !s.lastSweep.CompareAndSwap(last, now.UnixNano()) {
+		return
+	}
+
+	s.states.Range(func(key, value any) bool {
+		state := value.(*keyState[T])
+		state.mu.Lock()
+		if !state.expires.After(now) {
+			s.states.Delete(key)
+		}
+		state.mu.Unlock()
+		return true
+	})
+}
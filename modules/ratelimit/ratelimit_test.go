@@ -0,0 +1,167 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// limiters are the algorithms every test runs against
+var limiters = []string{AlgorithmTokenBucket, AlgorithmSlidingWindow}
+
+func newLimiter(t *testing.T, algorithm string) Limiter {
+	t.Helper()
+	l, err := New(algorithm)
+	if err != nil {
+		t.Fatalf("New(%q): %v", algorithm, err)
+	}
+	return l
+}
+
+func allow(t *testing.T, l Limiter, key string, limit int, window time.Duration) Decision {
+	t.Helper()
+	decision, err := l.Allow(context.Background(), key, limit, window)
+	if err != nil {
+		t.Fatalf("Allow: %v", err)
+	}
+	return decision
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		algorithm string
+		want      Limiter
+	}{
+		{"", &TokenBucketLimiter{}},
+		{AlgorithmTokenBucket, &TokenBucketLimiter{}},
+		{AlgorithmSlidingWindow, &SlidingWindowLimiter{}},
+	}
+	for _, tt := range tests {
+		if got := newLimiter(t, tt.algorithm); fmt.Sprintf("%T", got) != fmt.Sprintf("%T", tt.want) {
+			t.Errorf("New(%q) = %T, want %T", tt.algorithm, got, tt.want)
+		}
+	}
+	if _, err := New("leaky_bucket"); err == nil {
+		t.Error("New of an unknown algorithm succeeded")
+	}
+}
+
+func TestAllowBurst(t *testing.T) {
+	for _, algorithm := range limiters {
+		t.Run(algorithm, func(t *testing.T) {
+			l := newLimiter(t, algorithm)
+			for i := range 3 {
+				decision := allow(t, l, "1.2.3.4", 3, time.Hour)
+				if !decision.Allowed || decision.Remaining != 2-i {
+					t.Fatalf("hit %d = %+v, want allowed with %d remaining", i+1, decision, 2-i)
+				}
+			}
+
+			decision := allow(t, l, "1.2.3.4", 3, time.Hour)
+			if decision.Allowed || decision.Remaining != 0 {
+				t.Errorf("hit over the limit = %+v, want rejected", decision)
+			}
+			if !decision.Reset.After(time.Now()) {
+				t.Errorf("reset %v is not in the future", decision.Reset)
+			}
+		})
+	}
+}
+
+func TestAllowKeysAreIndependent(t *testing.T) {
+	for _, algorithm := range limiters {
+		t.Run(algorithm, func(t *testing.T) {
+			l := newLimiter(t, algorithm)
+			for range 2 {
+				allow(t, l, "1.2.3.4", 2, time.Hour)
+			}
+			if allow(t, l, "1.2.3.4", 2, time.Hour).Allowed {
+				t.Fatal("first IP not limited")
+			}
+
+			for _, key := range []string{"5.6.7.8", "upload:1.2.3.4"} {
+				if decision := allow(t, l, key, 2, time.Hour); !decision.Allowed || decision.Remaining != 1 {
+					t.Errorf("%s = %+v, want its own full limit", key, decision)
+				}
+			}
+		})
+	}
+}
+
+func TestAllowRecoversAfterWindow(t *testing.T) {
+	const window = 50 * time.Millisecond
+	for _, algorithm := range limiters {
+		t.Run(algorithm, func(t *testing.T) {
+			l := newLimiter(t, algorithm)
+			for range 2 {
+				allow(t, l, "key", 2, window)
+			}
+			if allow(t, l, "key", 2, window).Allowed {
+				t.Fatal("limit not reached")
+			}
+
+			time.Sleep(window + 10*time.Millisecond)
+			if decision := allow(t, l, "key", 2, window); !decision.Allowed {
+				t.Errorf("hit after the window = %+v, want allowed", decision)
+			}
+		})
+	}
+}
+
+func TestSlidingWindowRejectedHitsAreNotRecorded(t *testing.T) {
+	const window = 100 * time.Millisecond
+	l := NewSlidingWindowLimiter()
+	allow(t, l, "key", 1, window)
+	time.Sleep(window / 2)
+	// Rejected hits in the second half do not push the window
+	allow(t, l, "key", 1, window)
+	time.Sleep(window/2 + 10*time.Millisecond)
+
+	allowed, remaining, err := l.SlidingWindowLog(context.Background(), "key", 1, window)
+	if err != nil || !allowed || remaining != 0 {
+		t.Errorf("SlidingWindowLog = %v, %d, %v, want allowed with none remaining", allowed, remaining, err)
+	}
+}
+
+func TestAllowConcurrent(t *testing.T) {
+	for _, algorithm := range limiters {
+		t.Run(algorithm, func(t *testing.T) {
+			l := newLimiter(t, algorithm)
+			var allowed atomic.Int32
+			var wg sync.WaitGroup
+			for range 50 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if decision, _ := l.Allow(context.Background(), "key", 10, time.Hour); decision.Allowed {
+						allowed.Add(1)
+					}
+				}()
+			}
+			wg.Wait()
+			if n := allowed.Load(); n != 10 {
+				t.Errorf("%d concurrent hits allowed, want 10", n)
+			}
+		})
+	}
+}
+
+func TestSweepDropsIdleKeys(t *testing.T) {
+	l := NewSlidingWindowLimiter()
+	allow(t, l, "idle", 1, time.Millisecond)
+	allow(t, l, "busy", 1, time.Hour)
+
+	time.Sleep(5 * time.Millisecond)
+	l.store.lastSweep.Store(0) // allow a sweep on the next call
+	allow(t, l, "other", 1, time.Hour)
+
+	if _, ok := l.store.states.Load("idle"); ok {
+		t.Error("idle key was not dropped")
+	}
+	if _, ok := l.store.states.Load("busy"); !ok {
+		t.Error("key still limiting was dropped")
+	}
+}
@@ -0,0 +1,57 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// SlidingWindowLimiter counts the hits of the last window from a log of their
+// times. Unlike a token bucket it never lets more than limit hits through in any
+// window, however they are spread.
+type SlidingWindowLimiter struct {
+	store keyStore[[]time.Time] // hit times in ascending order
+}
+
+var _ Limiter = (*SlidingWindowLimiter)(nil)
+
+// NewSlidingWindowLimiter returns an empty in-memory sliding window log
+func NewSlidingWindowLimiter() *SlidingWindowLimiter {
+	return &SlidingWindowLimiter{}
+}
+
+// Allow records a hit of key unless limit hits already happened within window.
+// Rejected hits are not recorded.
+func (l *SlidingWindowLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (Decision, error) {
+	now := time.Now()
+	state := l.store.lock(key, now)
+	defer state.mu.Unlock()
+
+	hits := pruneHits(state.state, now.Add(-window))
+	allowed := len(hits) < limit
+	if allowed {
+		hits = append(hits, now)
+		state.expires = now.Add(window)
+	}
+	state.state = hits
+
+	decision := Decision{Allowed: allowed, Remaining: max(limit-len(hits), 0), Reset: now}
+	if len(hits) > 0 {
+		decision.Reset = hits[0].Add(window)
+	}
+	return decision, nil
+}
+
+// SlidingWindowLog is Allow returning whether the hit is allowed and how many remain
+func (l *SlidingWindowLimiter) SlidingWindowLog(ctx context.Context, key string, limit int, window time.Duration) (bool, int, error) {
+	decision, err := l.Allow(ctx, key, limit, window)
+	return decision.Allowed, decision.Remaining, err
+}
+
+// pruneHits removes hits at or before cutoff, hits are in ascending order
+func pruneHits(hits []time.Time, cutoff time.Time) []time.Time {
+	i := 0
+	for i < len(hits) && !hits[i].After(cutoff) {
+		i++
+	}
+	return hits[i:]
+}
@@ -0,0 +1,61 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// bucket is the token bucket of one key
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// TokenBucketLimiter gives every key a bucket of limit tokens refilled evenly
+// over window. A full bucket lets a burst of limit hits through at once, and up
+// to twice the limit can pass within one window around it.
+type TokenBucketLimiter struct {
+	store keyStore[*bucket]
+}
+
+var _ Limiter = (*TokenBucketLimiter)(nil)
+
+// NewTokenBucketLimiter returns an empty in-memory token bucket limiter
+func NewTokenBucketLimiter() *TokenBucketLimiter {
+	return &TokenBucketLimiter{}
+}
+
+// Allow takes a token from the bucket of key when one is left
+func (l *TokenBucketLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (Decision, error) {
+	now := time.Now()
+	state := l.store.lock(key, now)
+	defer state.mu.Unlock()
+
+	capacity := float64(limit)
+	perToken := window / time.Duration(max(limit, 1))
+	b := state.state
+	if b == nil {
+		b = &bucket{tokens: capacity, updated: now}
+		state.state = b
+	} else if perToken > 0 {
+		b.tokens = min(capacity, b.tokens+float64(now.Sub(b.updated))/float64(perToken))
+		b.updated = now
+	}
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+
+	decision := Decision{Allowed: allowed, Remaining: int(b.tokens), Reset: now}
+	if missing := capacity - b.tokens; missing > 0 {
+		// The bucket is full again after refilling what is missing
+		state.expires = now.Add(time.Duration(missing * float64(perToken)))
+		// The next token arrives when the fraction left is filled up
+		fraction := b.tokens - float64(int(b.tokens))
+		decision.Reset = now.Add(time.Duration((1 - fraction) * float64(perToken)))
+	} else {
+		state.expires = now
+	}
+	return decision, nil
+}
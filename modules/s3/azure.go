@@ -0,0 +1,441 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/sas"
+)
+
+// copyPollInterval is how often a pending server-side copy is checked
+const copyPollInterval = 200 * time.Millisecond
+
+// ErrNotSupported is returned for operations the storage backend has no equivalent of
+var ErrNotSupported = errors.New("operation not supported by the storage backend")
+
+// azureImpl implements S3 on Azure Blob Storage. Buckets map to containers,
+// keys to block blob names.
+type azureImpl struct {
+	client    *azblob.Client
+	container string
+	transfer  TransferManagerConfig
+}
+
+// initAzure connects to the storage account with its shared key. Endpoint
+// overrides the account URL, e.g. http://127.0.0.1:10000/devstoreaccount1 for Azurite.
+func initAzure(ctx context.Context, cfg Config) (S3, error) {
+	if cfg.AzureAccountName == "" || cfg.AzureAccountKey == "" || cfg.AzureContainerName == "" {
+		return nil, errors.New("azure backend requires account name, account key and container name")
+	}
+
+	cred, err := azblob.NewSharedKeyCredential(cfg.AzureAccountName, cfg.AzureAccountKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid azure credentials: %w", err)
+	}
+
+	serviceURL := cfg.Endpoint
+	if serviceURL == "" {
+		serviceURL = fmt.Sprintf("https://%s.blob.core.windows.net/", cfg.AzureAccountName)
+	}
+
+	// MaxRetries counts attempts like RetryS3, the SDK counts retries and treats 0 as its default
+	maxRetries := cfg.MaxRetries
+	if maxRetries <= 0 {
+		maxRetries = defaultMaxRetries
+	}
+	retries := int32(maxRetries - 1)
+	if retries == 0 {
+		retries = -1
+	}
+	retryDelay := cfg.RetryBaseDelay
+	if retryDelay <= 0 {
+		retryDelay = defaultRetryBaseDelay
+	}
+
+	client, err := azblob.NewClientWithSharedKeyCredential(serviceURL, cred, &azblob.ClientOptions{
+		ClientOptions: azcore.ClientOptions{
+			Retry: policy.RetryOptions{
+				MaxRetries: retries,
+				RetryDelay: retryDelay,
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	impl := &azureImpl{
+		client:    client,
+		container: cfg.AzureContainerName,
+		transfer:  cfg.Transfer.withDefaults(),
+	}
+
+	if cfg.AutoCreateBucket {
+		if err := impl.EnsureBucket(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	return impl, nil
+}
+
+func (a *azureImpl) containerName(bucket string) string {
+	if bucket == "" {
+		return a.container
+	}
+	return bucket
+}
+
+func (a *azureImpl) blobClient(bucket, key string) *blob.Client {
+	return a.client.ServiceClient().NewContainerClient(a.containerName(bucket)).NewBlobClient(key)
+}
+
+// Upload buffers the body so the SDK can retry and split it into blocks
+func (a *azureImpl) Upload(ctx context.Context, bucket, key string, body io.Reader, contentType string) (string, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return "", err
+	}
+
+	var options *azblob.UploadBufferOptions
+	if contentType != "" {
+		options = &azblob.UploadBufferOptions{HTTPHeaders: &blob.HTTPHeaders{BlobContentType: to.Ptr(contentType)}}
+	}
+	if _, err := a.client.UploadBuffer(ctx, a.containerName(bucket), key, data, options); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// UploadWithProgress stages blocks of PartSize, ConcurrentParts at a time. The SDK
+// retries failed blocks itself, AbortOnError and PartRetries do not apply.
+func (a *azureImpl) UploadWithProgress(ctx context.Context, bucket, key string, body io.ReaderAt, size int64, contentType string, progress ProgressFunc) (string, error) {
+	data, err := io.ReadAll(io.NewSectionReader(body, 0, size))
+	if err != nil {
+		return "", err
+	}
+
+	options := &azblob.UploadBufferOptions{
+		BlockSize:   a.transfer.PartSize,
+		Concurrency: uint16(min(a.transfer.ConcurrentParts, math.MaxUint16)),
+	}
+	if contentType != "" {
+		options.HTTPHeaders = &blob.HTTPHeaders{BlobContentType: to.Ptr(contentType)}
+	}
+	if progress != nil {
+		var mu sync.Mutex
+		var reported int64
+		options.Progress = func(bytesTransferred int64) {
+			mu.Lock()
+			defer mu.Unlock()
+			if bytesTransferred > reported {
+				reported = bytesTransferred
+				progress(reported, size)
+			}
+		}
+	}
+	if _, err := a.client.UploadBuffer(ctx, a.containerName(bucket), key, data, options); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// UploadStream stages blocks of PartSize read one after another, ConcurrentParts at
+// a time. Progress counts the bytes read from body, the SDK reports no more.
+func (a *azureImpl) UploadStream(ctx context.Context, bucket, key string, body io.Reader, size int64, contentType string, progress ProgressFunc) (string, error) {
+	options := &azblob.UploadStreamOptions{
+		BlockSize:   a.transfer.PartSize,
+		Concurrency: a.transfer.ConcurrentParts,
+	}
+	if contentType != "" {
+		options.HTTPHeaders = &blob.HTTPHeaders{BlobContentType: to.Ptr(contentType)}
+	}
+	if size >= 0 {
+		body = io.LimitReader(body, size)
+	}
+	if progress != nil {
+		body = &progressReader{r: body, size: size, progress: progress}
+	}
+	if _, err := a.client.UploadStream(ctx, a.containerName(bucket), key, body, options); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// progressReader reports the bytes read from r to progress
+type progressReader struct {
+	r        io.Reader
+	read     int64
+	size     int64
+	progress ProgressFunc
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.read += int64(n)
+		p.progress(p.read, p.size)
+	}
+	return n, err
+}
+
+func (a *azureImpl) Download(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	resp, err := a.client.DownloadStream(ctx, a.containerName(bucket), key, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Delete treats a missing blob as deleted, like S3 DeleteObject
+func (a *azureImpl) Delete(ctx context.Context, bucket, key string) error {
+	_, err := a.client.DeleteBlob(ctx, a.containerName(bucket), key, nil)
+	if bloberror.HasCode(err, bloberror.BlobNotFound) {
+		return nil
+	}
+	return err
+}
+
+// CopyObject copies within the storage account, which the shared key authorizes.
+// Blobs are always encrypted at rest by the account, so there are no settings to carry over.
+func (a *azureImpl) CopyObject(ctx context.Context, sourceBucket, sourceKey, destBucket, destKey string) error {
+	source := a.blobClient(sourceBucket, sourceKey)
+	dest := a.blobClient(destBucket, destKey)
+
+	resp, err := dest.StartCopyFromURL(ctx, source.URL(), nil)
+	if err != nil {
+		return err
+	}
+
+	// Copies inside one account usually finish right away, larger ones are polled
+	status := resp.CopyStatus
+	for status != nil && *status == blob.CopyStatusTypePending {
+		timer := time.NewTimer(copyPollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		props, err := dest.GetProperties(ctx, nil)
+		if err != nil {
+			return err
+		}
+		status = props.CopyStatus
+		if status != nil && *status != blob.CopyStatusTypePending && *status != blob.CopyStatusTypeSuccess {
+			return fmt.Errorf("copy of %q %s: %s", sourceKey, *status, deref(props.CopyStatusDescription))
+		}
+	}
+	return nil
+}
+
+func (a *azureImpl) ObjectSize(ctx context.Context, bucket, key string) (int64, error) {
+	props, err := a.blobClient(bucket, key).GetProperties(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	return deref(props.ContentLength), nil
+}
+
+func (a *azureImpl) GetObjectMetadata(ctx context.Context, bucket, key string) (map[string]string, error) {
+	props, err := a.blobClient(bucket, key).GetProperties(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return objectMetadata(props.ContentType, props.ContentLength, (*string)(props.ETag), props.LastModified), nil
+}
+
+func (a *azureImpl) ListObjects(ctx context.Context, bucket, prefix string) ([]string, error) {
+	var keys []string
+	err := a.listBlobPages(ctx, bucket, prefix, func(page []string) error {
+		keys = append(keys, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (a *azureImpl) ListObjectsChan(ctx context.Context, bucket, prefix string) (<-chan string, <-chan error) {
+	return listPagesChan(ctx, func(fn func(page []string) error) error {
+		return a.listBlobPages(ctx, bucket, prefix, fn)
+	})
+}
+
+// listBlobPages calls fn with the blob names of each listing page until the listing is complete
+func (a *azureImpl) listBlobPages(ctx context.Context, bucket, prefix string, fn func(page []string) error) error {
+	return a.listBlobItemPages(ctx, bucket, prefix, func(items []*container.BlobItem) error {
+		page := make([]string, 0, len(items))
+		for _, item := range items {
+			page = append(page, deref(item.Name))
+		}
+		return fn(page)
+	})
+}
+
+// listBlobItemPages calls fn with the blobs of each listing page until the listing is complete
+func (a *azureImpl) listBlobItemPages(ctx context.Context, bucket, prefix string, fn func(items []*container.BlobItem) error) error {
+	pager := a.client.NewListBlobsFlatPager(a.containerName(bucket), &azblob.ListBlobsFlatOptions{
+		Prefix: to.Ptr(prefix),
+	})
+	for pager.More() {
+		resp, err := pager.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+
+		var items []*container.BlobItem
+		if resp.Segment != nil {
+			items = resp.Segment.BlobItems
+		}
+		if err := fn(items); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *azureImpl) GetStorageUsage(ctx context.Context, bucket, prefix string) (StorageUsage, error) {
+	var usage StorageUsage
+	err := a.listBlobItemPages(ctx, bucket, prefix, func(items []*container.BlobItem) error {
+		for _, item := range items {
+			if item.Properties != nil {
+				usage.add(deref(item.Properties.ContentLength))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return StorageUsage{}, err
+	}
+	return usage, nil
+}
+
+func (a *azureImpl) GetStorageByDay(ctx context.Context, bucket, prefix string, from, to time.Time) (map[string]int64, error) {
+	days := newStorageDays(from, to)
+	err := a.listBlobItemPages(ctx, bucket, prefix, func(items []*container.BlobItem) error {
+		for _, item := range items {
+			if item.Properties != nil {
+				days.add(deref(item.Properties.LastModified), deref(item.Properties.ContentLength))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return days.totals, nil
+}
+
+// HealthCheck reads the container properties. Shared keys do not expire, so
+// unlike the S3 backend there is nothing to reconnect.
+func (a *azureImpl) HealthCheck(ctx context.Context) error {
+	_, err := a.client.ServiceClient().NewContainerClient(a.container).GetProperties(ctx, nil)
+	return err
+}
+
+// EnsureBucket creates the configured container if it does not exist. Azure
+// lifecycle rules are managed per storage account, outside of the blob API,
+// so the auto-delete rule of the S3 backend is not set up.
+func (a *azureImpl) EnsureBucket(ctx context.Context) error {
+	containerClient := a.client.ServiceClient().NewContainerClient(a.container)
+
+	_, err := containerClient.GetProperties(ctx, nil)
+	if err == nil {
+		return nil
+	}
+	if !isContainerNotFound(err) {
+		return fmt.Errorf("failed to check container %q: %w", a.container, err)
+	}
+
+	if _, err := containerClient.Create(ctx, nil); err != nil && !bloberror.HasCode(err, bloberror.ContainerAlreadyExists) {
+		return fmt.Errorf("failed to create container %q: %w", a.container, err)
+	}
+	return nil
+}
+
+// isContainerNotFound reports whether a container request failed because the container is missing
+func isContainerNotFound(err error) bool {
+	if bloberror.HasCode(err, bloberror.ContainerNotFound) {
+		return true
+	}
+	// HEAD responses have no body to read the error code from
+	var respErr *azcore.ResponseError
+	return errors.As(err, &respErr) && respErr.StatusCode == http.StatusNotFound
+}
+
+// PresignGetURL returns a read-only SAS URL of the blob
+func (a *azureImpl) PresignGetURL(ctx context.Context, bucket, key string, ttl time.Duration) (string, error) {
+	return a.blobClient(bucket, key).GetSASURL(sas.BlobPermissions{Read: true}, time.Now().Add(ttl), nil)
+}
+
+// ListIncompleteUploads returns nothing: uncommitted blocks are not listed per
+// upload and Azure discards them on its own after a week
+func (a *azureImpl) ListIncompleteUploads(ctx context.Context, bucket string) ([]IncompleteUpload, error) {
+	return nil, nil
+}
+
+func (a *azureImpl) AbortMultipartUpload(ctx context.Context, bucket, key, uploadID string) error {
+	return ErrNotSupported
+}
+
+// deref returns the value p points to, or the zero value for nil
+func deref[T any](p *T) T {
+	var v T
+	if p != nil {
+		v = *p
+	}
+	return v
+}
+
+// GetObjectLockConfig returns the immutability policy of the blob. A locked policy
+// is reported as ObjectLockModeCompliance, others with the Azure mode name.
+func (a *azureImpl) GetObjectLockConfig(ctx context.Context, bucket, key string) (ObjectLockConfig, error) {
+	props, err := a.blobClient(bucket, key).GetProperties(ctx, nil)
+	if bloberror.HasCode(err, bloberror.BlobNotFound) {
+		return ObjectLockConfig{}, nil
+	}
+	if err != nil {
+		return ObjectLockConfig{}, err
+	}
+	if props.ImmutabilityPolicyExpiresOn == nil || props.ImmutabilityPolicyMode == nil {
+		return ObjectLockConfig{}, nil
+	}
+
+	mode := string(*props.ImmutabilityPolicyMode)
+	if *props.ImmutabilityPolicyMode == blob.ImmutabilityPolicyModeLocked {
+		mode = ObjectLockModeCompliance
+	}
+	return ObjectLockConfig{Mode: mode, RetainUntil: *props.ImmutabilityPolicyExpiresOn}, nil
+}
+
+// ExtendObjectLock sets a locked immutability policy, the Azure equivalent of compliance mode
+func (a *azureImpl) ExtendObjectLock(ctx context.Context, bucket, key string, newRetainUntil time.Time) error {
+	current, err := a.GetObjectLockConfig(ctx, bucket, key)
+	if err != nil {
+		return err
+	}
+	if newRetainUntil.Before(current.RetainUntil) {
+		return fmt.Errorf("%w: retained until %s", ErrRetentionShortened, current.RetainUntil.Format(time.RFC3339))
+	}
+
+	_, err = a.blobClient(bucket, key).SetImmutabilityPolicy(ctx, newRetainUntil, &blob.SetImmutabilityPolicyOptions{
+		Mode: to.Ptr(blob.ImmutabilityPolicySettingLocked),
+	})
+	return err
+}
@@ -0,0 +1,242 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+)
+
+// azuriteAccountKey is the well-known key of Azurite's devstoreaccount1
+const azuriteAccountKey = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+
+func azureConfig(endpoint string) Config {
+	return Config{
+		Backend:            BackendAzure,
+		Endpoint:           endpoint,
+		AzureAccountName:   "devstoreaccount1",
+		AzureAccountKey:    azuriteAccountKey,
+		AzureContainerName: "media",
+	}
+}
+
+func TestInitAzureConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(cfg *Config)
+		wantErr string
+	}{
+		{"valid", func(*Config) {}, ""},
+		{"missing account", func(cfg *Config) { cfg.AzureAccountName = "" }, "requires account name"},
+		{"missing key", func(cfg *Config) { cfg.AzureAccountKey = "" }, "requires account name"},
+		{"missing container", func(cfg *Config) { cfg.AzureContainerName = "" }, "requires account name"},
+		{"key not base64", func(cfg *Config) { cfg.AzureAccountKey = "not base64!" }, "invalid azure credentials"},
+		{"unknown backend", func(cfg *Config) { cfg.Backend = "gcs" }, `unknown storage backend "gcs"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Without AutoCreateBucket Init does not connect
+			cfg := azureConfig("http://127.0.0.1:1/devstoreaccount1")
+			tt.modify(&cfg)
+			storage, err := Init(context.Background(), cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Init: %v", err)
+				}
+				if _, ok := storage.(*azureImpl); !ok {
+					t.Errorf("Init returned %T, want the azure backend", storage)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Init error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAzurePresignGetURL(t *testing.T) {
+	storage, err := Init(context.Background(), azureConfig("http://127.0.0.1:10000/devstoreaccount1"))
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+
+	before := time.Now()
+	presigned, err := storage.PresignGetURL(context.Background(), "", "media/key", 10*time.Minute)
+	if err != nil {
+		t.Fatalf("PresignGetURL: %v", err)
+	}
+	u, err := url.Parse(presigned)
+	if err != nil {
+		t.Fatalf("presigned URL %q: %v", presigned, err)
+	}
+	if u.Host != "127.0.0.1:10000" || u.Path != "/devstoreaccount1/media/media/key" {
+		t.Errorf("presigned URL %q does not name the blob", presigned)
+	}
+	query := u.Query()
+	if got := query.Get("sp"); got != "r" {
+		t.Errorf("permissions = %q, want read only", got)
+	}
+	if query.Get("sig") == "" {
+		t.Error("presigned URL is not signed")
+	}
+	expiry, err := time.Parse(time.RFC3339, query.Get("se"))
+	if err != nil {
+		t.Fatalf("expiry %q: %v", query.Get("se"), err)
+	}
+	if expiry.Before(before.Add(10*time.Minute).Truncate(time.Second)) || expiry.After(time.Now().Add(10*time.Minute)) {
+		t.Errorf("expiry = %v, want 10 minutes from now", expiry)
+	}
+}
+
+func TestAzureMultipartUploads(t *testing.T) {
+	storage, err := Init(context.Background(), azureConfig("http://127.0.0.1:1/devstoreaccount1"))
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	// Neither call reaches the service
+	if uploads, err := storage.ListIncompleteUploads(context.Background(), ""); err != nil || len(uploads) != 0 {
+		t.Errorf("ListIncompleteUploads = %v, %v, want none", uploads, err)
+	}
+	if err := storage.AbortMultipartUpload(context.Background(), "", "key", "id"); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("AbortMultipartUpload error = %v, want ErrNotSupported", err)
+	}
+}
+
+func TestIsContainerNotFound(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"container not found", &azcore.ResponseError{ErrorCode: "ContainerNotFound", StatusCode: http.StatusNotFound}, true},
+		{"bare 404", &azcore.ResponseError{StatusCode: http.StatusNotFound}, true},
+		{"wrapped 404", fmt.Errorf("check: %w", &azcore.ResponseError{StatusCode: http.StatusNotFound}), true},
+		{"forbidden", &azcore.ResponseError{ErrorCode: "AuthorizationFailure", StatusCode: http.StatusForbidden}, false},
+		{"other error", errors.New("connection refused"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isContainerNotFound(tt.err); got != tt.want {
+				t.Errorf("isContainerNotFound = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProgressReader(t *testing.T) {
+	var reports [][2]int64
+	r := &progressReader{r: strings.NewReader("0123456789"), size: 10, progress: func(uploaded, total int64) {
+		reports = append(reports, [2]int64{uploaded, total})
+	}}
+	buf := make([]byte, 4)
+	for {
+		if _, err := r.Read(buf); err == io.EOF {
+			break
+		}
+	}
+	want := [][2]int64{{4, 10}, {8, 10}, {10, 10}}
+	if fmt.Sprint(reports) != fmt.Sprint(want) {
+		t.Errorf("progress reports = %v, want %v", reports, want)
+	}
+}
+
+// TestAzureRoundTrip runs against Azurite, e.g. AZURITE_ENDPOINT=http://127.0.0.1:10000/devstoreaccount1
+func TestAzureRoundTrip(t *testing.T) {
+	endpoint := os.Getenv("AZURITE_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("AZURITE_ENDPOINT is not set")
+	}
+	cfg := azureConfig(endpoint)
+	cfg.AzureContainerName = fmt.Sprintf("lovebin-test-%d", time.Now().UnixNano())
+	cfg.AutoCreateBucket = true
+	ctx := context.Background()
+	storage, err := Init(ctx, cfg)
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	t.Cleanup(func() {
+		impl := storage.(*azureImpl)
+		_, _ = impl.client.DeleteContainer(context.Background(), cfg.AzureContainerName, nil)
+	})
+	// A second EnsureBucket finds the container
+	if err := storage.EnsureBucket(ctx); err != nil {
+		t.Fatalf("EnsureBucket: %v", err)
+	}
+	if err := storage.HealthCheck(ctx); err != nil {
+		t.Fatalf("HealthCheck: %v", err)
+	}
+
+	content := []byte("encrypted blob")
+	if _, err := storage.Upload(ctx, "", "media/a", bytes.NewReader(content), "application/octet-stream"); err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	if _, err := storage.UploadStream(ctx, "", "media/b", bytes.NewReader(content), int64(len(content)), "", nil); err != nil {
+		t.Fatalf("UploadStream: %v", err)
+	}
+	if _, err := storage.UploadStream(ctx, "", "media/d", bytes.NewReader(content), -1, "", nil); err != nil {
+		t.Fatalf("UploadStream of unknown size: %v", err)
+	}
+	if err := storage.CopyObject(ctx, "", "media/a", "", "media/c"); err != nil {
+		t.Fatalf("CopyObject: %v", err)
+	}
+
+	for _, key := range []string{"media/a", "media/b", "media/c", "media/d"} {
+		body, err := storage.Download(ctx, "", key)
+		if err != nil {
+			t.Fatalf("Download %s: %v", key, err)
+		}
+		data, _ := io.ReadAll(body)
+		_ = body.Close()
+		if !bytes.Equal(data, content) {
+			t.Errorf("%s = %q, want %q", key, data, content)
+		}
+		if size, err := storage.ObjectSize(ctx, "", key); err != nil || size != int64(len(content)) {
+			t.Errorf("ObjectSize(%s) = %d, %v", key, size, err)
+		}
+	}
+
+	keys, err := storage.ListObjects(ctx, "", "media/")
+	if err != nil || len(keys) != 3 {
+		t.Errorf("ListObjects = %v, %v, want 3 keys", keys, err)
+	}
+	usage, err := storage.GetStorageUsage(ctx, "", "media/")
+	if err != nil || usage.TotalObjects != 3 || usage.TotalBytes != int64(3*len(content)) {
+		t.Errorf("GetStorageUsage = %+v, %v", usage, err)
+	}
+
+	presigned, err := storage.PresignGetURL(ctx, "", "media/a", time.Minute)
+	if err != nil {
+		t.Fatalf("PresignGetURL: %v", err)
+	}
+	resp, err := http.Get(presigned)
+	if err != nil {
+		t.Fatalf("GET presigned URL: %v", err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !bytes.Equal(data, content) {
+		t.Errorf("GET presigned URL = %d %q", resp.StatusCode, data)
+	}
+
+	if err := storage.Delete(ctx, "", "media/a"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	// Deleting a missing blob succeeds like on S3
+	if err := storage.Delete(ctx, "", "media/a"); err != nil {
+		t.Errorf("second Delete: %v", err)
+	}
+	if _, err := storage.Download(ctx, "", "media/a"); err == nil {
+		t.Error("Download of a deleted blob succeeded")
+	}
+}
@@ -0,0 +1,101 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+const (
+	// AutoDeleteTag marks objects the bucket lifecycle rule removes on its own
+	AutoDeleteTag = "auto_delete"
+
+	// S3 rejects 0 days for expiration, one day is the shortest rule it accepts
+	autoDeleteExpirationDays = 1
+)
+
+// EnsureBucket creates the configured bucket with an auto-delete lifecycle rule if it does not exist
+func (s *s3Impl) EnsureBucket(ctx context.Context) error {
+	_, err := s.client().HeadBucket(ctx, &s3.HeadBucketInput{
+		Bucket: aws.String(s.bucket),
+	})
+	if err == nil {
+		return nil
+	}
+	if !isBucketNotFound(err) {
+		return fmt.Errorf("failed to check bucket %q: %w", s.bucket, err)
+	}
+
+	input := &s3.CreateBucketInput{
+		Bucket: aws.String(s.bucket),
+	}
+	if s.cfg.EnableObjectLock {
+		// Object lock can only be turned on when the bucket is created, it also enables versioning
+		input.ObjectLockEnabledForBucket = aws.Bool(true)
+	}
+	// us-east-1 is the default location and must not be sent as a constraint
+	if s.region != "" && s.region != "us-east-1" {
+		input.CreateBucketConfiguration = &types.CreateBucketConfiguration{
+			LocationConstraint: types.BucketLocationConstraint(s.region),
+		}
+	}
+	if _, err := s.client().CreateBucket(ctx, input); err != nil {
+		var owned *types.BucketAlreadyOwnedByYou
+		if !errors.As(err, &owned) {
+			return fmt.Errorf("failed to create bucket %q: %w", s.bucket, err)
+		}
+	}
+
+	// Backup cleanup: objects tagged auto_delete=true expire without the app
+	_, err = s.client().PutBucketLifecycleConfiguration(ctx, &s3.PutBucketLifecycleConfigurationInput{
+		Bucket: aws.String(s.bucket),
+		LifecycleConfiguration: &types.BucketLifecycleConfiguration{
+			Rules: []types.LifecycleRule{
+				{
+					ID:     aws.String("lovebin-auto-delete"),
+					Status: types.ExpirationStatusEnabled,
+					Filter: &types.LifecycleRuleFilterMemberTag{
+						Value: types.Tag{
+							Key:   aws.String(AutoDeleteTag),
+							Value: aws.String("true"),
+						},
+					},
+					Expiration: &types.LifecycleExpiration{
+						Days: aws.Int32(autoDeleteExpirationDays),
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to configure lifecycle for bucket %q: %w", s.bucket, err)
+	}
+
+	return nil
+}
+
+// isBucketNotFound reports whether HeadBucket failed because the bucket is missing
+func isBucketNotFound(err error) bool {
+	var notFound *types.NotFound
+	if errors.As(err, &notFound) {
+		return true
+	}
+	var noSuchBucket *types.NoSuchBucket
+	if errors.As(err, &noSuchBucket) {
+		return true
+	}
+	// HeadBucket has no body, some S3-compatible services only report the status code
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "NotFound", "NoSuchBucket":
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+func TestEnsureBucketExisting(t *testing.T) {
+	server := newFakeS3Server(t, "media")
+	storage := newTestS3(t, server, Config{})
+
+	if err := storage.EnsureBucket(context.Background()); err != nil {
+		t.Fatalf("EnsureBucket: %v", err)
+	}
+	if n := server.count("CreateBucket"); n != 0 {
+		t.Errorf("CreateBucket called %d times for an existing bucket", n)
+	}
+	if n := server.count("PutBucketLifecycleConfiguration"); n != 0 {
+		t.Errorf("lifecycle configured %d times for an existing bucket", n)
+	}
+}
+
+func TestEnsureBucketCreates(t *testing.T) {
+	tests := []struct {
+		name           string
+		cfg            Config
+		wantConstraint string
+		wantObjectLock bool
+	}{
+		{"us-east-1", Config{Region: "us-east-1"}, "", false},
+		{"other region", Config{Region: "eu-central-1"}, "<LocationConstraint>eu-central-1</LocationConstraint>", false},
+		{"object lock", Config{Region: "us-east-1", EnableObjectLock: true}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeS3Server(t)
+			storage := newTestS3(t, server, tt.cfg)
+
+			if err := storage.EnsureBucket(context.Background()); err != nil {
+				t.Fatalf("EnsureBucket: %v", err)
+			}
+
+			create := server.last("CreateBucket")
+			if n := server.count("CreateBucket"); n != 1 {
+				t.Fatalf("CreateBucket called %d times, want 1", n)
+			}
+			if tt.wantConstraint == "" && strings.Contains(create.body, "LocationConstraint") {
+				t.Errorf("unexpected location constraint: %s", create.body)
+			}
+			if tt.wantConstraint != "" && !strings.Contains(create.body, tt.wantConstraint) {
+				t.Errorf("CreateBucket body = %q, want %s", create.body, tt.wantConstraint)
+			}
+			if got := create.header.Get("X-Amz-Bucket-Object-Lock-Enabled") == "true"; got != tt.wantObjectLock {
+				t.Errorf("object lock header = %v, want %v", got, tt.wantObjectLock)
+			}
+
+			lifecycle := server.last("PutBucketLifecycleConfiguration").body
+			for _, want := range []string{
+				"<ID>lovebin-auto-delete</ID>",
+				"<Key>" + AutoDeleteTag + "</Key>",
+				"<Value>true</Value>",
+				"<Days>1</Days>",
+				"<Status>Enabled</Status>",
+			} {
+				if !strings.Contains(lifecycle, want) {
+					t.Errorf("lifecycle configuration %q lacks %s", lifecycle, want)
+				}
+			}
+		})
+	}
+}
+
+func TestEnsureBucketAlreadyOwned(t *testing.T) {
+	// HeadBucket reports the bucket missing while another instance creates it
+	server := newFakeS3Server(t, "media")
+	server.failNext("HeadBucket", http.StatusNotFound)
+	storage := newTestS3(t, server, Config{})
+
+	if err := storage.EnsureBucket(context.Background()); err != nil {
+		t.Fatalf("EnsureBucket: %v", err)
+	}
+	if n := server.count("PutBucketLifecycleConfiguration"); n != 1 {
+		t.Errorf("lifecycle configured %d times, want 1", n)
+	}
+}
+
+func TestEnsureBucketErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		op         string
+		status     int
+		wantCreate int
+	}{
+		{"head forbidden", "HeadBucket", http.StatusForbidden, 0},
+		{"create fails", "CreateBucket", http.StatusForbidden, 1},
+		{"lifecycle fails", "PutBucketLifecycleConfiguration", http.StatusBadRequest, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeS3Server(t)
+			server.failNext(tt.op, tt.status)
+			storage := newTestS3(t, server, Config{})
+
+			if err := storage.EnsureBucket(context.Background()); err == nil {
+				t.Fatal("EnsureBucket succeeded, want an error")
+			}
+			if n := server.count("CreateBucket"); n != tt.wantCreate {
+				t.Errorf("CreateBucket called %d times, want %d", n, tt.wantCreate)
+			}
+		})
+	}
+}
+
+func TestIsBucketNotFound(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"not found", &types.NotFound{}, true},
+		{"no such bucket", &types.NoSuchBucket{}, true},
+		{"generic NotFound code", &smithy.GenericAPIError{Code: "NotFound"}, true},
+		{"generic NoSuchBucket code", &smithy.GenericAPIError{Code: "NoSuchBucket"}, true},
+		{"access denied", &smithy.GenericAPIError{Code: "AccessDenied"}, false},
+		{"wrapped", errors.Join(errors.New("head"), &types.NotFound{}), true},
+		{"plain error", errors.New("boom"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isBucketNotFound(tt.err); got != tt.want {
+				t.Errorf("isBucketNotFound(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
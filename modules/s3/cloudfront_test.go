@@ -0,0 +1,181 @@
+package s3
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testRSAKey is shared, generating RSA keys is slow
+var testRSAKey = func() *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}()
+
+func pemEncode(blockType string, der []byte) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}))
+}
+
+func TestNewCloudFrontSigner(t *testing.T) {
+	pkcs1 := pemEncode("RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(testRSAKey))
+	pkcs8DER, _ := x509.MarshalPKCS8PrivateKey(testRSAKey)
+	pkcs8 := pemEncode("PRIVATE KEY", pkcs8DER)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ecDER, _ := x509.MarshalPKCS8PrivateKey(ecKey)
+
+	tests := []struct {
+		name        string
+		cfg         CloudFrontConfig
+		wantBaseURL string // empty when an error is expected
+	}{
+		{"PKCS#1", CloudFrontConfig{KeyPairID: "K1", PrivateKeyPEM: pkcs1, DistributionDomain: "d1.cloudfront.net"}, "https://d1.cloudfront.net"},
+		{"PKCS#8", CloudFrontConfig{KeyPairID: "K1", PrivateKeyPEM: pkcs8, DistributionDomain: "d1.cloudfront.net"}, "https://d1.cloudfront.net"},
+		{"escaped newlines", CloudFrontConfig{KeyPairID: "K1", PrivateKeyPEM: strings.ReplaceAll(pkcs1, "\n", `\n`), DistributionDomain: "d1.cloudfront.net"}, "https://d1.cloudfront.net"},
+		{"domain with scheme", CloudFrontConfig{KeyPairID: "K1", PrivateKeyPEM: pkcs1, DistributionDomain: "http://cdn.example.com/"}, "http://cdn.example.com"},
+		{"no key pair id", CloudFrontConfig{PrivateKeyPEM: pkcs1, DistributionDomain: "d1.cloudfront.net"}, ""},
+		{"not PEM", CloudFrontConfig{KeyPairID: "K1", PrivateKeyPEM: "secret", DistributionDomain: "d1.cloudfront.net"}, ""},
+		{"garbage key", CloudFrontConfig{KeyPairID: "K1", PrivateKeyPEM: pemEncode("PRIVATE KEY", []byte("garbage")), DistributionDomain: "d1.cloudfront.net"}, ""},
+		{"ECDSA key", CloudFrontConfig{KeyPairID: "K1", PrivateKeyPEM: pemEncode("PRIVATE KEY", ecDER), DistributionDomain: "d1.cloudfront.net"}, ""},
+		{"invalid domain", CloudFrontConfig{KeyPairID: "K1", PrivateKeyPEM: pkcs1, DistributionDomain: "cdn example.com"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer, err := NewCloudFrontSigner(tt.cfg)
+			if tt.wantBaseURL == "" {
+				if err == nil {
+					t.Fatal("NewCloudFrontSigner succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewCloudFrontSigner: %v", err)
+			}
+			if signer.baseURL != tt.wantBaseURL {
+				t.Errorf("base URL = %q, want %q", signer.baseURL, tt.wantBaseURL)
+			}
+		})
+	}
+}
+
+func newTestCloudFrontSigner(t *testing.T) *CloudFrontSigner {
+	t.Helper()
+	signer, err := NewCloudFrontSigner(CloudFrontConfig{
+		KeyPairID:          "K2JCJMDEHXQW5F",
+		PrivateKeyPEM:      pemEncode("RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(testRSAKey)),
+		DistributionDomain: "d111111abcdef8.cloudfront.net",
+	})
+	if err != nil {
+		t.Fatalf("NewCloudFrontSigner: %v", err)
+	}
+	return signer
+}
+
+// verifyCloudFrontURL checks the parameters Sign added to resourceURL as CloudFront would
+func verifyCloudFrontURL(t *testing.T, signed, resourceURL string, expiry time.Time) {
+	t.Helper()
+	if !strings.HasPrefix(signed, resourceURL) {
+		t.Fatalf("signed URL %q does not start with %q", signed, resourceURL)
+	}
+	parsed, err := url.Parse(signed)
+	if err != nil {
+		t.Fatalf("parse signed URL: %v", err)
+	}
+	query := parsed.Query()
+	if got := query.Get("Expires"); got != strconv.FormatInt(expiry.Unix(), 10) {
+		t.Errorf("Expires = %q, want %d", got, expiry.Unix())
+	}
+	if got := query.Get("Key-Pair-Id"); got != "K2JCJMDEHXQW5F" {
+		t.Errorf("Key-Pair-Id = %q", got)
+	}
+
+	encoded := query.Get("Signature")
+	if strings.ContainsAny(encoded, "+=/") {
+		t.Errorf("signature %q is not in CloudFront's base64 alphabet", encoded)
+	}
+	signature, err := base64.StdEncoding.DecodeString(strings.NewReplacer("-", "+", "_", "=", "~", "/").Replace(encoded))
+	if err != nil {
+		t.Fatalf("decode signature: %v", err)
+	}
+	policy, _ := cannedPolicy(resourceURL, expiry.Unix())
+	digest := sha1.Sum(policy)
+	if err := rsa.VerifyPKCS1v15(&testRSAKey.PublicKey, crypto.SHA1, digest[:], signature); err != nil {
+		t.Errorf("signature does not verify over the canned policy: %v", err)
+	}
+}
+
+func TestCloudFrontSignerSign(t *testing.T) {
+	signer := newTestCloudFrontSigner(t)
+	expiry := time.Unix(1767225600, 0)
+
+	tests := []struct {
+		name          string
+		resourceURL   string
+		wantSeparator string
+	}{
+		{"object", signer.ObjectURL("media/abc"), "?"},
+		{"with query", signer.ObjectURL("media/abc") + "?response-content-disposition=attachment", "&"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signed, err := signer.Sign(tt.resourceURL, expiry)
+			if err != nil {
+				t.Fatalf("Sign: %v", err)
+			}
+			if !strings.HasPrefix(signed, tt.resourceURL+tt.wantSeparator+"Expires=") {
+				t.Errorf("Sign = %q, want the parameters after %q", signed, tt.wantSeparator)
+			}
+			verifyCloudFrontURL(t, signed, tt.resourceURL, expiry)
+		})
+	}
+}
+
+func TestCannedPolicy(t *testing.T) {
+	policy, err := cannedPolicy("https://d1.cloudfront.net/media/a?x=1&y=<2>", 1767225600)
+	if err != nil {
+		t.Fatalf("cannedPolicy: %v", err)
+	}
+	want := `{"Statement":[{"Resource":"https://d1.cloudfront.net/media/a?x=1&y=<2>","Condition":{"DateLessThan":{"AWS:EpochTime":1767225600}}}]}`
+	if string(policy) != want {
+		t.Errorf("cannedPolicy =\n%s\nwant\n%s", policy, want)
+	}
+}
+
+func TestCloudFrontObjectURL(t *testing.T) {
+	signer := newTestCloudFrontSigner(t)
+	if got, want := signer.ObjectURL("media/a b+c"), "https://d111111abcdef8.cloudfront.net/media/a%20b+c"; got != want {
+		t.Errorf("ObjectURL = %q, want %q", got, want)
+	}
+}
+
+func TestPresignGetURLCloudFront(t *testing.T) {
+	server := newFakeS3Server(t, "media")
+	storage := newTestS3(t, server, Config{})
+	storage.cloudFront = newTestCloudFrontSigner(t)
+
+	before := time.Now()
+	signed, err := storage.PresignGetURL(context.Background(), "", "media/abc", time.Hour)
+	if err != nil {
+		t.Fatalf("PresignGetURL: %v", err)
+	}
+	parsed, _ := url.Parse(signed)
+	expires, _ := strconv.ParseInt(parsed.Query().Get("Expires"), 10, 64)
+	if expires < before.Add(time.Hour).Unix() || expires > time.Now().Add(time.Hour).Unix() {
+		t.Errorf("Expires = %d, want an hour from now", expires)
+	}
+	verifyCloudFrontURL(t, signed, "https://d111111abcdef8.cloudfront.net/media/abc", time.Unix(expires, 0))
+}
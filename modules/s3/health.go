@@ -0,0 +1,164 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+	"go.uber.org/zap"
+
+	"lovebin/modules/logger"
+)
+
+const (
+	// DefaultHealthCheckInterval is how often the health monitor checks the bucket
+	DefaultHealthCheckInterval = 30 * time.Second
+
+	// healthCheckTimeout bounds a single background check
+	healthCheckTimeout = 10 * time.Second
+)
+
+// HealthCheck checks that the bucket is reachable with the current credentials.
+// When it fails on credentials, which expire with STS and instance roles, the
+// client is rebuilt from the default AWS config and the bucket checked once more.
+func (s *s3Impl) HealthCheck(ctx context.Context) error {
+	err := s.headBucket(ctx)
+	if err == nil || !isCredentialError(err) {
+		return err
+	}
+
+	if reconnectErr := s.reconnect(ctx); reconnectErr != nil {
+		return fmt.Errorf("%w (reconnect failed: %v)", err, reconnectErr)
+	}
+	return s.headBucket(ctx)
+}
+
+func (s *s3Impl) headBucket(ctx context.Context) error {
+	_, err := s.client().HeadBucket(ctx, &s3.HeadBucketInput{
+		Bucket: aws.String(s.bucket),
+	})
+	return err
+}
+
+// reconnect replaces the client with one built from freshly loaded config
+func (s *s3Impl) reconnect(ctx context.Context) error {
+	client, err := newClient(ctx, s.cfg)
+	if err != nil {
+		return err
+	}
+	s.current.Store(client)
+	return nil
+}
+
+// isCredentialError reports whether an S3 error comes from missing, expired or rejected credentials
+func isCredentialError(err error) bool {
+	var signErr *v4.SigningError
+	if errors.As(err, &signErr) {
+		return true
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "ExpiredToken", "InvalidAccessKeyId", "InvalidToken", "SignatureDoesNotMatch", "TokenRefreshRequired":
+			return true
+		}
+	}
+
+	// HeadBucket has no body, a rejected signature only shows as 403
+	var statusErr interface{ HTTPStatusCode() int }
+	return errors.As(err, &statusErr) && statusErr.HTTPStatusCode() == http.StatusForbidden
+}
+
+// HealthStatus is the result of the most recent background health check
+type HealthStatus struct {
+	Healthy   bool
+	CheckedAt time.Time // zero until the first check finished
+	Err       error     // nil when healthy
+}
+
+// HealthMonitor checks the storage in the background and keeps the latest result
+type HealthMonitor struct {
+	log    logger.Logger
+	client S3
+
+	mu     sync.RWMutex
+	status HealthStatus
+}
+
+// StartHealthMonitor checks client right away and then every interval, logging
+// when the storage becomes degraded and when it recovers. A non-positive interval
+// uses DefaultHealthCheckInterval. Call stop on shutdown.
+func StartHealthMonitor(log logger.Logger, client S3, interval time.Duration) (monitor *HealthMonitor, stop func()) {
+	if interval <= 0 {
+		interval = DefaultHealthCheckInterval
+	}
+
+	m := &HealthMonitor{
+		log:    log.Child("s3-health"),
+		client: client,
+		// Healthy until shown otherwise: Init already reached the storage
+		status: HealthStatus{Healthy: true},
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		m.check()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				m.check()
+			}
+		}
+	}()
+
+	var once sync.Once
+	return m, func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+		})
+	}
+}
+
+// Status returns the result of the most recent check
+func (m *HealthMonitor) Status() HealthStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.status
+}
+
+func (m *HealthMonitor) check() {
+	ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+	defer cancel()
+
+	err := m.client.HealthCheck(ctx)
+	status := HealthStatus{Healthy: err == nil, CheckedAt: time.Now(), Err: err}
+
+	m.mu.Lock()
+	wasHealthy := m.status.Healthy
+	m.status = status
+	m.mu.Unlock()
+
+	switch {
+	case wasHealthy && !status.Healthy:
+		m.log.Warn("storage degraded", zap.Error(err))
+	case !wasHealthy && status.Healthy:
+		m.log.Info("storage recovered")
+	}
+}
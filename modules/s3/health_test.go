@@ -0,0 +1,136 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/smithy-go"
+)
+
+func TestIsCredentialError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"signing error", &v4.SigningError{Err: errors.New("no credentials")}, true},
+		{"expired token", &smithy.GenericAPIError{Code: "ExpiredToken"}, true},
+		{"invalid access key", &smithy.GenericAPIError{Code: "InvalidAccessKeyId"}, true},
+		{"signature mismatch", &smithy.GenericAPIError{Code: "SignatureDoesNotMatch"}, true},
+		{"wrapped", fmt.Errorf("head bucket: %w", &smithy.GenericAPIError{Code: "InvalidToken"}), true},
+		{"bare 403", statusError(http.StatusForbidden), true},
+		{"missing bucket", &smithy.GenericAPIError{Code: "NoSuchBucket"}, false},
+		{"bare 404", statusError(http.StatusNotFound), false},
+		{"bare 503", statusError(http.StatusServiceUnavailable), false},
+		{"network", errors.New("connection refused"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isCredentialError(tt.err); got != tt.want {
+				t.Errorf("isCredentialError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	tests := []struct {
+		name          string
+		failures      []int
+		wantErr       bool
+		wantReconnect bool
+		wantRequests  int
+	}{
+		{"healthy", nil, false, false, 1},
+		{"credentials refreshed", []int{http.StatusForbidden}, false, true, 2},
+		{"credentials still rejected", []int{http.StatusForbidden, http.StatusForbidden}, true, true, 2},
+		{"outage", []int{http.StatusServiceUnavailable}, true, false, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeS3Server(t, "media")
+			server.failNext("HeadBucket", tt.failures...)
+			// Reconnecting loads the default config, which picks up these credentials
+			storage := newTestS3(t, server, Config{AccessKeyID: "test", SecretAccessKey: "test"})
+			original := storage.client()
+
+			err := storage.HealthCheck(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("HealthCheck error = %v, want error %v", err, tt.wantErr)
+			}
+			if reconnected := storage.client() != original; reconnected != tt.wantReconnect {
+				t.Errorf("reconnected = %v, want %v", reconnected, tt.wantReconnect)
+			}
+			if n := server.count("HeadBucket"); n != tt.wantRequests {
+				t.Errorf("HeadBucket sent %d times, want %d", n, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestHealthCheckMissingBucket(t *testing.T) {
+	server := newFakeS3Server(t)
+	storage := newTestS3(t, server, Config{})
+	original := storage.client()
+
+	if err := storage.HealthCheck(context.Background()); err == nil {
+		t.Error("HealthCheck of a missing bucket succeeded")
+	}
+	if storage.client() != original {
+		t.Error("a missing bucket made the client reconnect")
+	}
+}
+
+// waitForHealth polls the monitor until its status has the wanted health
+func waitForHealth(t *testing.T, m *HealthMonitor, healthy bool) HealthStatus {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status := m.Status()
+		if status.Healthy == healthy && !status.CheckedAt.IsZero() {
+			return status
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("health monitor status = %+v, want healthy %v", status, healthy)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestHealthMonitor(t *testing.T) {
+	mock := NewMockS3()
+	monitor, stop := StartHealthMonitor(newTestLogger(t), mock, 10*time.Millisecond)
+	defer stop()
+
+	waitForHealth(t, monitor, true)
+
+	outage := errors.New("storage unreachable")
+	mock.SetError("HealthCheck", outage)
+	if status := waitForHealth(t, monitor, false); !errors.Is(status.Err, outage) {
+		t.Errorf("degraded status error = %v, want %v", status.Err, outage)
+	}
+
+	mock.SetError("HealthCheck", nil)
+	if status := waitForHealth(t, monitor, true); status.Err != nil {
+		t.Errorf("recovered status error = %v, want nil", status.Err)
+	}
+}
+
+func TestHealthMonitorStop(t *testing.T) {
+	mock := NewMockS3()
+	_, stop := StartHealthMonitor(newTestLogger(t), mock, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	stop()
+	stop() // stopping twice is safe
+
+	calls := mock.Calls("HealthCheck")
+	time.Sleep(10 * time.Millisecond)
+	if got := mock.Calls("HealthCheck"); got != calls {
+		t.Errorf("%d checks after stop", got-calls)
+	}
+}
@@ -0,0 +1,124 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// putKeys stores count objects named media/0000, media/0001, ... and returns their keys
+func putKeys(f *fakeS3Server, count int) []string {
+	keys := make([]string, count)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("media/%04d", i)
+		f.put("media", keys[i], []byte("x"), time.Now())
+	}
+	return keys
+}
+
+// drain collects the keys of a ListObjectsChan listing and its error
+func drain(keys <-chan string, errs <-chan error) ([]string, error) {
+	var got []string
+	for key := range keys {
+		got = append(got, key)
+	}
+	return got, <-errs
+}
+
+func TestListObjectsPaginates(t *testing.T) {
+	tests := []struct {
+		name      string
+		objects   int
+		pageSize  int
+		wantPages int
+	}{
+		{"empty", 0, 3, 1},
+		{"one page", 3, 3, 1},
+		{"partial last page", 10, 3, 4},
+		{"exact pages", 9, 3, 3},
+		{"default page size", listPageSize + 1, 0, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeS3Server(t, "media")
+			server.pageSize = tt.pageSize
+			want := putKeys(server, tt.objects)
+			server.put("media", "manifests/other", []byte("x"), time.Now())
+			storage := newTestS3(t, server, Config{})
+
+			got, err := storage.ListObjects(context.Background(), "", "media/")
+			if err != nil {
+				t.Fatalf("ListObjects: %v", err)
+			}
+			if len(got) != len(want) || (len(want) > 0 && !reflect.DeepEqual(got, want)) {
+				t.Errorf("ListObjects returned %d keys, want %d", len(got), len(want))
+			}
+			if n := server.count("ListObjectsV2"); n != tt.wantPages {
+				t.Errorf("ListObjectsV2 called %d times, want %d", n, tt.wantPages)
+			}
+
+			got, err = drain(storage.ListObjectsChan(context.Background(), "", "media/"))
+			if err != nil {
+				t.Fatalf("ListObjectsChan: %v", err)
+			}
+			if len(got) != len(want) || (len(want) > 0 && !reflect.DeepEqual(got, want)) {
+				t.Errorf("ListObjectsChan emitted %d keys, want %d", len(got), len(want))
+			}
+		})
+	}
+}
+
+func TestListObjectsPageFails(t *testing.T) {
+	server := newFakeS3Server(t, "media")
+	server.pageSize = 3
+	putKeys(server, 10)
+	storage := newTestS3(t, server, Config{})
+
+	// The second page is refused
+	server.failNext("ListObjectsV2", 0, http.StatusForbidden)
+	if keys, err := storage.ListObjects(context.Background(), "", "media/"); err == nil {
+		t.Errorf("ListObjects = %d keys, want the error of the second page", len(keys))
+	}
+
+	server.failNext("ListObjectsV2", 0, http.StatusForbidden)
+	got, err := drain(storage.ListObjectsChan(context.Background(), "", "media/"))
+	if err == nil {
+		t.Error("ListObjectsChan ended without the error of the second page")
+	}
+	// Keys of the pages before the failure were already emitted
+	if len(got) != 3 {
+		t.Errorf("ListObjectsChan emitted %d keys before the error, want 3", len(got))
+	}
+}
+
+func TestListObjectsChanStopsOnCancel(t *testing.T) {
+	server := newFakeS3Server(t, "media")
+	server.pageSize = 2
+	putKeys(server, 10)
+	storage := newTestS3(t, server, Config{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	keys, errs := storage.ListObjectsChan(ctx, "", "media/")
+	<-keys
+	cancel()
+
+	done := make(chan error)
+	go func() {
+		_, err := drain(keys, errs)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("cancelled listing ended without an error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("listing did not stop after cancel")
+	}
+	if n := server.count("ListObjectsV2"); n >= 5 {
+		t.Errorf("ListObjectsV2 called %d times, the listing went on after cancel", n)
+	}
+}
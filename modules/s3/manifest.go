@@ -0,0 +1,74 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// ManifestPrefix is the key prefix of resource manifests, stored next to media/{resourceKey}
+const ManifestPrefix = "manifests/"
+
+// manifestContentType is stored with manifests, they are sealed and opaque to the backend
+const manifestContentType = "application/octet-stream"
+
+// ErrManifestNotFound is returned by DownloadManifest for resources without a manifest
+var ErrManifestNotFound = errors.New("resource manifest not found")
+
+// ManifestKey returns the object key of the manifest of resourceKey
+func ManifestKey(resourceKey string) string {
+	return ManifestPrefix + resourceKey
+}
+
+// UploadManifest stores the sealed manifest of resourceKey in the default bucket.
+// The manifest is encrypted by the caller, the backend only sees ciphertext.
+func UploadManifest(ctx context.Context, client S3, resourceKey string, sealed []byte) error {
+	_, err := client.Upload(ctx, "", ManifestKey(resourceKey), bytes.NewReader(sealed), manifestContentType)
+	return err
+}
+
+// DownloadManifest returns the sealed manifest of resourceKey, ErrManifestNotFound
+// when the resource has none, e.g. because it was uploaded before manifests existed
+func DownloadManifest(ctx context.Context, client S3, resourceKey string) ([]byte, error) {
+	body, err := client.Download(ctx, "", ManifestKey(resourceKey))
+	if isObjectNotFound(err) {
+		return nil, ErrManifestNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+// DeleteManifest removes the manifest of resourceKey, a missing manifest is not an error
+func DeleteManifest(ctx context.Context, client S3, resourceKey string) error {
+	return client.Delete(ctx, "", ManifestKey(resourceKey))
+}
+
+// isObjectNotFound reports whether a download failed because the object is missing, on any backend
+func isObjectNotFound(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrMockNotFound) || bloberror.HasCode(err, bloberror.BlobNotFound) {
+		return true
+	}
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return true
+	}
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "NoSuchKey", "NotFound":
+			return true
+		}
+	}
+	return false
+}
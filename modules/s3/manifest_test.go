@@ -0,0 +1,103 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+func TestIsObjectNotFound(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"mock", fmt.Errorf("download: %w", ErrMockNotFound), true},
+		{"azure blob", &azcore.ResponseError{ErrorCode: string(bloberror.BlobNotFound)}, true},
+		{"azure container", &azcore.ResponseError{ErrorCode: string(bloberror.ContainerNotFound)}, false},
+		{"no such key", &types.NoSuchKey{}, true},
+		{"no such key code", &smithy.GenericAPIError{Code: "NoSuchKey"}, true},
+		{"head not found", &smithy.GenericAPIError{Code: "NotFound"}, true},
+		{"missing bucket", &smithy.GenericAPIError{Code: "NoSuchBucket"}, false},
+		{"access denied", &smithy.GenericAPIError{Code: "AccessDenied"}, false},
+		{"network", errors.New("connection refused"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isObjectNotFound(tt.err); got != tt.want {
+				t.Errorf("isObjectNotFound(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestManifestRoundTrip(t *testing.T) {
+	server := newFakeS3Server(t, "media")
+	clients := []struct {
+		name   string
+		client S3
+	}{
+		{"mock", NewMockS3()},
+		{"s3", newTestS3(t, server, Config{})},
+	}
+	for _, tt := range clients {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			sealed := []byte("sealed manifest")
+
+			if _, err := DownloadManifest(ctx, tt.client, "resource"); !errors.Is(err, ErrManifestNotFound) {
+				t.Fatalf("DownloadManifest of a resource without a manifest = %v, want %v", err, ErrManifestNotFound)
+			}
+			if err := UploadManifest(ctx, tt.client, "resource", sealed); err != nil {
+				t.Fatalf("UploadManifest: %v", err)
+			}
+			got, err := DownloadManifest(ctx, tt.client, "resource")
+			if err != nil {
+				t.Fatalf("DownloadManifest: %v", err)
+			}
+			if !bytes.Equal(got, sealed) {
+				t.Errorf("DownloadManifest = %q, want %q", got, sealed)
+			}
+			if err := DeleteManifest(ctx, tt.client, "resource"); err != nil {
+				t.Fatalf("DeleteManifest: %v", err)
+			}
+			if _, err := DownloadManifest(ctx, tt.client, "resource"); !errors.Is(err, ErrManifestNotFound) {
+				t.Errorf("DownloadManifest after DeleteManifest = %v, want %v", err, ErrManifestNotFound)
+			}
+			if err := DeleteManifest(ctx, tt.client, "resource"); err != nil {
+				t.Errorf("DeleteManifest of a missing manifest: %v", err)
+			}
+		})
+	}
+
+	if _, ok := server.object("media", ManifestKey("resource")); ok {
+		t.Error("the manifest is still stored after DeleteManifest")
+	}
+}
+
+func TestDownloadManifestFailure(t *testing.T) {
+	server := newFakeS3Server(t, "media")
+	storage := newTestS3(t, server, Config{})
+	if err := UploadManifest(context.Background(), storage, "resource", []byte("sealed")); err != nil {
+		t.Fatalf("UploadManifest: %v", err)
+	}
+	if object, _ := server.object("media", ManifestKey("resource")); object.contentType != manifestContentType {
+		t.Errorf("manifest Content-Type = %q, want %q", object.contentType, manifestContentType)
+	}
+
+	// Only a missing manifest means the resource has none, other failures are reported
+	server.failNext("GetObject", http.StatusForbidden)
+	_, err := DownloadManifest(context.Background(), storage, "resource")
+	if err == nil || errors.Is(err, ErrManifestNotFound) {
+		t.Errorf("DownloadManifest with a denied request = %v, want the storage error", err)
+	}
+}
@@ -0,0 +1,61 @@
+package s3
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Fields of GetObjectMetadata, named like the HTTP headers they come from.
+// Fields the backend has no value for are left out of the map.
+const (
+	MetadataContentType   = "Content-Type"
+	MetadataContentLength = "Content-Length"
+	MetadataETag          = "ETag"
+	MetadataLastModified  = "Last-Modified" // in http.TimeFormat
+)
+
+// GetObjectMetadata reads the metadata of an object with HeadObject
+func (s *s3Impl) GetObjectMetadata(ctx context.Context, bucket, key string) (map[string]string, error) {
+	bucketName := bucket
+	if bucketName == "" {
+		bucketName = s.bucket
+	}
+
+	var result *s3.HeadObjectOutput
+	err := RetryS3(ctx, s.maxRetries, s.retryBaseDelay, func() error {
+		var headErr error
+		result, headErr = s.client().HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String(key),
+		})
+		return headErr
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return objectMetadata(result.ContentType, result.ContentLength, result.ETag, result.LastModified), nil
+}
+
+// objectMetadata builds the GetObjectMetadata map, skipping nil fields
+func objectMetadata(contentType *string, contentLength *int64, etag *string, lastModified *time.Time) map[string]string {
+	metadata := make(map[string]string, 4)
+	if contentType != nil && *contentType != "" {
+		metadata[MetadataContentType] = *contentType
+	}
+	if contentLength != nil {
+		metadata[MetadataContentLength] = strconv.FormatInt(*contentLength, 10)
+	}
+	if etag != nil && *etag != "" {
+		metadata[MetadataETag] = *etag
+	}
+	if lastModified != nil && !lastModified.IsZero() {
+		metadata[MetadataLastModified] = lastModified.UTC().Format(http.TimeFormat)
+	}
+	return metadata
+}
@@ -0,0 +1,81 @@
+package s3
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetObjectMetadata(t *testing.T) {
+	server := newFakeS3Server(t, "media")
+	modified := time.Date(2026, 10, 14, 18, 0, 0, 0, time.UTC)
+	server.put("media", "untyped", []byte("data"), modified)
+	storage := newTestS3(t, server, Config{})
+	ctx := context.Background()
+
+	if _, err := storage.Upload(ctx, "", "typed", strings.NewReader("twelve bytes"), "image/png"); err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	metadata, err := storage.GetObjectMetadata(ctx, "", "typed")
+	if err != nil {
+		t.Fatalf("GetObjectMetadata: %v", err)
+	}
+	if metadata[MetadataContentType] != "image/png" || metadata[MetadataContentLength] != "12" || metadata[MetadataETag] == "" {
+		t.Errorf("metadata = %v, want the stored type, length and ETag", metadata)
+	}
+	if server.count("GetObject") != 0 {
+		t.Error("GetObjectMetadata downloaded the object")
+	}
+
+	metadata, err = storage.GetObjectMetadata(ctx, "", "untyped")
+	if err != nil {
+		t.Fatalf("GetObjectMetadata: %v", err)
+	}
+	if got := metadata[MetadataLastModified]; got != modified.Format(http.TimeFormat) {
+		t.Errorf("Last-Modified = %q, want %q", got, modified.Format(http.TimeFormat))
+	}
+
+	if _, err := storage.GetObjectMetadata(ctx, "", "missing"); err == nil {
+		t.Error("GetObjectMetadata of a missing object succeeded")
+	}
+}
+
+func TestObjectMetadata(t *testing.T) {
+	contentType, empty := "text/csv", ""
+	length := int64(42)
+	etag := `"abc"`
+	modified := time.Date(2026, 10, 14, 21, 0, 0, 0, time.FixedZone("MSK", 3*3600))
+
+	tests := []struct {
+		name         string
+		contentType  *string
+		length       *int64
+		etag         *string
+		lastModified *time.Time
+		want         map[string]string
+	}{
+		{"all fields", &contentType, &length, &etag, &modified, map[string]string{
+			MetadataContentType:   "text/csv",
+			MetadataContentLength: "42",
+			MetadataETag:          `"abc"`,
+			MetadataLastModified:  "Wed, 14 Oct 2026 18:00:00 GMT",
+		}},
+		{"nil fields", nil, nil, nil, nil, map[string]string{}},
+		{"empty strings", &empty, &length, &empty, &time.Time{}, map[string]string{MetadataContentLength: "42"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := objectMetadata(tt.contentType, tt.length, tt.etag, tt.lastModified)
+			if len(got) != len(tt.want) {
+				t.Fatalf("objectMetadata = %v, want %v", got, tt.want)
+			}
+			for key, value := range tt.want {
+				if got[key] != value {
+					t.Errorf("%s = %q, want %q", key, got[key], value)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,453 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrMockNotFound is returned by MockS3.Download, CopyObject, ObjectSize, GetObjectMetadata and ExtendObjectLock for missing objects,
+// and by AbortMultipartUpload for unknown uploads
+var ErrMockNotFound = errors.New("mock s3: object not found")
+
+// MockS3 is an in-memory S3 implementation for tests and local development.
+// Objects are stored under "bucket/key"; an empty bucket maps to the mock's default bucket.
+type MockS3 struct {
+	Bucket string
+
+	// ObjectLockRetention locks objects stored by Upload, UploadWithProgress, UploadStream and
+	// CopyObject in compliance mode for this long, zero leaves them unlocked
+	ObjectLockRetention time.Duration
+
+	objects  sync.Map // "bucket/key" -> []byte
+	modified sync.Map // "bucket/key" -> time.Time of the last Upload or CopyObject
+	types    sync.Map // "bucket/key" -> content type given to Upload, if any
+	locks    sync.Map // "bucket/key" -> ObjectLockConfig
+	uploads  sync.Map // "bucket/key/uploadID" -> mockUpload
+
+	mu         sync.Mutex
+	CallCounts map[string]int   // method name -> number of calls
+	ForceError map[string]error // method name -> error returned instead of doing the call
+}
+
+var _ S3 = (*MockS3)(nil)
+
+// NewMockS3 creates an empty MockS3 with default bucket "mock"
+func NewMockS3() *MockS3 {
+	return &MockS3{
+		Bucket:     "mock",
+		CallCounts: make(map[string]int),
+		ForceError: make(map[string]error),
+	}
+}
+
+// mockUpload is an incomplete multipart upload of MockS3
+type mockUpload struct {
+	bucket string
+	IncompleteUpload
+}
+
+// Reset removes all objects, incomplete uploads, call counts and forced errors
+func (m *MockS3) Reset() {
+	m.objects.Range(func(key, _ any) bool {
+		m.objects.Delete(key)
+		m.modified.Delete(key)
+		m.types.Delete(key)
+		m.locks.Delete(key)
+		return true
+	})
+	m.uploads.Range(func(key, _ any) bool {
+		m.uploads.Delete(key)
+		return true
+	})
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.CallCounts = make(map[string]int)
+	m.ForceError = make(map[string]error)
+}
+
+// Calls returns how many times method was called
+func (m *MockS3) Calls(method string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.CallCounts[method]
+}
+
+// SetError makes method fail with err (nil clears it)
+func (m *MockS3) SetError(method string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err == nil {
+		delete(m.ForceError, method)
+		return
+	}
+	m.ForceError[method] = err
+}
+
+// Object returns a stored object without counting a call
+func (m *MockS3) Object(bucket, key string) ([]byte, bool) {
+	value, ok := m.objects.Load(m.objectKey(bucket, key))
+	if !ok {
+		return nil, false
+	}
+	return value.([]byte), true
+}
+
+func (m *MockS3) Upload(ctx context.Context, bucket, key string, body io.Reader, contentType string) (string, error) {
+	if err := m.call("Upload"); err != nil {
+		return "", err
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return "", err
+	}
+	objectKey := m.objectKey(bucket, key)
+	m.store(objectKey, data)
+	if contentType != "" {
+		m.types.Store(objectKey, contentType)
+	} else {
+		m.types.Delete(objectKey)
+	}
+	return key, nil
+}
+
+// UploadWithProgress stores body in one piece like Upload and reports it once stored
+func (m *MockS3) UploadWithProgress(ctx context.Context, bucket, key string, body io.ReaderAt, size int64, contentType string, progress ProgressFunc) (string, error) {
+	if err := m.call("UploadWithProgress"); err != nil {
+		return "", err
+	}
+
+	data, err := io.ReadAll(io.NewSectionReader(body, 0, size))
+	if err != nil {
+		return "", err
+	}
+	objectKey := m.objectKey(bucket, key)
+	m.store(objectKey, data)
+	if contentType != "" {
+		m.types.Store(objectKey, contentType)
+	} else {
+		m.types.Delete(objectKey)
+	}
+	if progress != nil {
+		progress(size, size)
+	}
+	return key, nil
+}
+
+// UploadStream stores the size bytes of body like UploadWithProgress, all of it when size is negative
+func (m *MockS3) UploadStream(ctx context.Context, bucket, key string, body io.Reader, size int64, contentType string, progress ProgressFunc) (string, error) {
+	if err := m.call("UploadStream"); err != nil {
+		return "", err
+	}
+
+	var data []byte
+	if size < 0 {
+		var err error
+		if data, err = io.ReadAll(body); err != nil {
+			return "", err
+		}
+		size = int64(len(data))
+	} else {
+		data = make([]byte, size)
+		if _, err := io.ReadFull(body, data); err != nil {
+			return "", err
+		}
+	}
+	objectKey := m.objectKey(bucket, key)
+	m.store(objectKey, data)
+	if contentType != "" {
+		m.types.Store(objectKey, contentType)
+	} else {
+		m.types.Delete(objectKey)
+	}
+	if progress != nil {
+		progress(size, size)
+	}
+	return key, nil
+}
+
+func (m *MockS3) Download(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	if err := m.call("Download"); err != nil {
+		return nil, err
+	}
+
+	data, ok := m.Object(bucket, key)
+	if !ok {
+		return nil, ErrMockNotFound
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (m *MockS3) Delete(ctx context.Context, bucket, key string) error {
+	if err := m.call("Delete"); err != nil {
+		return err
+	}
+
+	// Like S3, deleting a missing object is not an error
+	if lock, ok := m.locks.Load(m.objectKey(bucket, key)); ok && lock.(ObjectLockConfig).Locked(time.Now()) {
+		return ErrObjectLocked
+	}
+	m.objects.Delete(m.objectKey(bucket, key))
+	m.modified.Delete(m.objectKey(bucket, key))
+	m.types.Delete(m.objectKey(bucket, key))
+	m.locks.Delete(m.objectKey(bucket, key))
+	return nil
+}
+
+func (m *MockS3) ListObjects(ctx context.Context, bucket, prefix string) ([]string, error) {
+	if err := m.call("ListObjects"); err != nil {
+		return nil, err
+	}
+
+	// Like S3, keys are returned in lexicographic order
+	var keys []string
+	m.rangeObjects(bucket, prefix, func(key string, _ []byte, _ time.Time) {
+		keys = append(keys, key)
+	})
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (m *MockS3) ListObjectsChan(ctx context.Context, bucket, prefix string) (<-chan string, <-chan error) {
+	errs := make(chan error, 1)
+	keys, err := m.ListObjects(ctx, bucket, prefix)
+	if err != nil {
+		errs <- err
+	}
+	close(errs)
+
+	out := make(chan string, len(keys))
+	for _, key := range keys {
+		out <- key
+	}
+	close(out)
+	return out, errs
+}
+
+// CopyObject stores a copy of the source bytes, later changes to either object do not affect the other
+func (m *MockS3) CopyObject(ctx context.Context, sourceBucket, sourceKey, destBucket, destKey string) error {
+	if err := m.call("CopyObject"); err != nil {
+		return err
+	}
+
+	data, ok := m.Object(sourceBucket, sourceKey)
+	if !ok {
+		return ErrMockNotFound
+	}
+	destObjectKey := m.objectKey(destBucket, destKey)
+	m.store(destObjectKey, bytes.Clone(data))
+	if contentType, ok := m.types.Load(m.objectKey(sourceBucket, sourceKey)); ok {
+		m.types.Store(destObjectKey, contentType)
+	} else {
+		m.types.Delete(destObjectKey)
+	}
+	return nil
+}
+
+func (m *MockS3) ObjectSize(ctx context.Context, bucket, key string) (int64, error) {
+	if err := m.call("ObjectSize"); err != nil {
+		return 0, err
+	}
+
+	data, ok := m.Object(bucket, key)
+	if !ok {
+		return 0, ErrMockNotFound
+	}
+	return int64(len(data)), nil
+}
+
+// GetObjectMetadata returns the content type given to Upload, the size and the modification time
+func (m *MockS3) GetObjectMetadata(ctx context.Context, bucket, key string) (map[string]string, error) {
+	if err := m.call("GetObjectMetadata"); err != nil {
+		return nil, err
+	}
+
+	objectKey := m.objectKey(bucket, key)
+	data, ok := m.Object(bucket, key)
+	if !ok {
+		return nil, ErrMockNotFound
+	}
+
+	metadata := map[string]string{MetadataContentLength: strconv.Itoa(len(data))}
+	if contentType, ok := m.types.Load(objectKey); ok {
+		metadata[MetadataContentType] = contentType.(string)
+	}
+	if modified, ok := m.modified.Load(objectKey); ok {
+		metadata[MetadataLastModified] = modified.(time.Time).UTC().Format(http.TimeFormat)
+	}
+	return metadata, nil
+}
+
+// PresignGetURL returns a mock:// URL naming the object and its expiry, the object is not checked
+func (m *MockS3) PresignGetURL(ctx context.Context, bucket, key string, ttl time.Duration) (string, error) {
+	if err := m.call("PresignGetURL"); err != nil {
+		return "", err
+	}
+	expires := time.Now().Add(ttl).Unix()
+	return "mock://" + m.objectKey(bucket, key) + "?expires=" + strconv.FormatInt(expires, 10), nil
+}
+
+// AddIncompleteUpload records an incomplete multipart upload, as if an upload was interrupted
+func (m *MockS3) AddIncompleteUpload(bucket string, upload IncompleteUpload) {
+	m.uploads.Store(m.objectKey(bucket, upload.Key)+"/"+upload.UploadID, mockUpload{
+		bucket:           m.bucketName(bucket),
+		IncompleteUpload: upload,
+	})
+}
+
+func (m *MockS3) ListIncompleteUploads(ctx context.Context, bucket string) ([]IncompleteUpload, error) {
+	if err := m.call("ListIncompleteUploads"); err != nil {
+		return nil, err
+	}
+
+	var uploads []IncompleteUpload
+	m.uploads.Range(func(_, value any) bool {
+		if upload := value.(mockUpload); upload.bucket == m.bucketName(bucket) {
+			uploads = append(uploads, upload.IncompleteUpload)
+		}
+		return true
+	})
+	sort.Slice(uploads, func(i, j int) bool {
+		if uploads[i].Key != uploads[j].Key {
+			return uploads[i].Key < uploads[j].Key
+		}
+		return uploads[i].UploadID < uploads[j].UploadID
+	})
+	return uploads, nil
+}
+
+func (m *MockS3) AbortMultipartUpload(ctx context.Context, bucket, key, uploadID string) error {
+	if err := m.call("AbortMultipartUpload"); err != nil {
+		return err
+	}
+
+	if _, ok := m.uploads.LoadAndDelete(m.objectKey(bucket, key) + "/" + uploadID); !ok {
+		return ErrMockNotFound
+	}
+	return nil
+}
+
+func (m *MockS3) EnsureBucket(ctx context.Context) error {
+	return m.call("EnsureBucket")
+}
+
+// SetModified changes the last modified time of a stored object, e.g. to spread objects over days
+func (m *MockS3) SetModified(bucket, key string, modified time.Time) {
+	if _, ok := m.objects.Load(m.objectKey(bucket, key)); ok {
+		m.modified.Store(m.objectKey(bucket, key), modified)
+	}
+}
+
+func (m *MockS3) GetStorageUsage(ctx context.Context, bucket, prefix string) (StorageUsage, error) {
+	if err := m.call("GetStorageUsage"); err != nil {
+		return StorageUsage{}, err
+	}
+
+	var usage StorageUsage
+	m.rangeObjects(bucket, prefix, func(_ string, data []byte, _ time.Time) {
+		usage.add(int64(len(data)))
+	})
+	return usage, nil
+}
+
+func (m *MockS3) GetStorageByDay(ctx context.Context, bucket, prefix string, from, to time.Time) (map[string]int64, error) {
+	if err := m.call("GetStorageByDay"); err != nil {
+		return nil, err
+	}
+
+	days := newStorageDays(from, to)
+	m.rangeObjects(bucket, prefix, func(_ string, data []byte, modified time.Time) {
+		days.add(modified, int64(len(data)))
+	})
+	return days.totals, nil
+}
+
+// GetObjectLockConfig returns the retention given by ObjectLockRetention or ExtendObjectLock
+func (m *MockS3) GetObjectLockConfig(ctx context.Context, bucket, key string) (ObjectLockConfig, error) {
+	if err := m.call("GetObjectLockConfig"); err != nil {
+		return ObjectLockConfig{}, err
+	}
+
+	lock, ok := m.locks.Load(m.objectKey(bucket, key))
+	if !ok {
+		return ObjectLockConfig{}, nil
+	}
+	return lock.(ObjectLockConfig), nil
+}
+
+func (m *MockS3) ExtendObjectLock(ctx context.Context, bucket, key string, newRetainUntil time.Time) error {
+	if err := m.call("ExtendObjectLock"); err != nil {
+		return err
+	}
+
+	objectKey := m.objectKey(bucket, key)
+	if _, ok := m.objects.Load(objectKey); !ok {
+		return ErrMockNotFound
+	}
+	if lock, ok := m.locks.Load(objectKey); ok && newRetainUntil.Before(lock.(ObjectLockConfig).RetainUntil) {
+		return ErrRetentionShortened
+	}
+	m.locks.Store(objectKey, ObjectLockConfig{Mode: ObjectLockModeCompliance, RetainUntil: newRetainUntil})
+	return nil
+}
+
+func (m *MockS3) HealthCheck(ctx context.Context) error {
+	return m.call("HealthCheck")
+}
+
+// call records a method call and returns its forced error, if any
+func (m *MockS3) call(method string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.CallCounts == nil {
+		m.CallCounts = make(map[string]int)
+	}
+	m.CallCounts[method]++
+	return m.ForceError[method]
+}
+
+// store saves an object and stamps it as modified now
+func (m *MockS3) store(objectKey string, data []byte) {
+	now := time.Now()
+	m.objects.Store(objectKey, data)
+	m.modified.Store(objectKey, now)
+	if m.ObjectLockRetention > 0 {
+		m.locks.Store(objectKey, ObjectLockConfig{Mode: ObjectLockModeCompliance, RetainUntil: now.Add(m.ObjectLockRetention)})
+	}
+}
+
+// rangeObjects calls fn for every object of bucket whose key starts with prefix
+func (m *MockS3) rangeObjects(bucket, prefix string, fn func(key string, data []byte, modified time.Time)) {
+	bucketPrefix := m.objectKey(bucket, "")
+	m.objects.Range(func(key, value any) bool {
+		objectKey, ok := strings.CutPrefix(key.(string), bucketPrefix)
+		if ok && strings.HasPrefix(objectKey, prefix) {
+			var modified time.Time
+			if t, ok := m.modified.Load(key); ok {
+				modified = t.(time.Time)
+			}
+			fn(objectKey, value.([]byte), modified)
+		}
+		return true
+	})
+}
+
+func (m *MockS3) objectKey(bucket, key string) string {
+	return m.bucketName(bucket) + "/" + key
+}
+
+func (m *MockS3) bucketName(bucket string) string {
+	if bucket == "" {
+		return m.Bucket
+	}
+	return bucket
+}
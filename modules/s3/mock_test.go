@@ -0,0 +1,389 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMockS3UploadDownloadDelete(t *testing.T) {
+	ctx := context.Background()
+	m := NewMockS3()
+
+	if _, err := m.Download(ctx, "", "missing"); !errors.Is(err, ErrMockNotFound) {
+		t.Fatalf("Download(missing) error = %v, want ErrMockNotFound", err)
+	}
+
+	if _, err := m.Upload(ctx, "", "key", strings.NewReader("data"), "text/plain"); err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	body, err := m.Download(ctx, "", "key")
+	if err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+	data, _ := io.ReadAll(body)
+	if string(data) != "data" {
+		t.Errorf("Download = %q, want data", data)
+	}
+
+	// The empty bucket is the default bucket, other buckets are separate
+	if _, ok := m.Object("mock", "key"); !ok {
+		t.Error("object not stored in the default bucket")
+	}
+	if _, ok := m.Object("other", "key"); ok {
+		t.Error("object visible in another bucket")
+	}
+
+	if err := m.Delete(ctx, "", "key"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, ok := m.Object("", "key"); ok {
+		t.Error("object still stored after Delete")
+	}
+	// Like S3, deleting a missing object succeeds
+	if err := m.Delete(ctx, "", "key"); err != nil {
+		t.Errorf("Delete of a missing object: %v", err)
+	}
+}
+
+func TestMockS3UploadVariants(t *testing.T) {
+	ctx := context.Background()
+	content := []byte("0123456789")
+
+	tests := []struct {
+		name   string
+		upload func(m *MockS3, progress ProgressFunc) error
+		want   string
+	}{
+		{"UploadWithProgress", func(m *MockS3, progress ProgressFunc) error {
+			_, err := m.UploadWithProgress(ctx, "", "key", bytes.NewReader(content), 6, "", progress)
+			return err
+		}, "012345"},
+		{"UploadStream", func(m *MockS3, progress ProgressFunc) error {
+			_, err := m.UploadStream(ctx, "", "key", bytes.NewReader(content), 6, "", progress)
+			return err
+		}, "012345"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMockS3()
+			var reported []int64
+			progress := func(uploaded, total int64) { reported = append(reported, uploaded, total) }
+
+			if err := tt.upload(m, progress); err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			if got, _ := m.Object("", "key"); string(got) != tt.want {
+				t.Errorf("stored %q, want %q", got, tt.want)
+			}
+			if !reflect.DeepEqual(reported, []int64{6, 6}) {
+				t.Errorf("progress = %v, want [6 6]", reported)
+			}
+			if n := m.Calls(tt.name); n != 1 {
+				t.Errorf("Calls(%s) = %d, want 1", tt.name, n)
+			}
+		})
+	}
+
+	m := NewMockS3()
+	if _, err := m.UploadStream(ctx, "", "key", bytes.NewReader(content), 20, "", nil); err == nil {
+		t.Error("UploadStream of a short body succeeded")
+	}
+	if _, err := m.UploadStream(ctx, "", "key", bytes.NewReader(content), -1, "", nil); err != nil {
+		t.Fatalf("UploadStream of unknown size: %v", err)
+	}
+	if got, _ := m.Object("", "key"); !bytes.Equal(got, content) {
+		t.Errorf("UploadStream of unknown size stored %q, want %q", got, content)
+	}
+}
+
+func TestMockS3CopyObjectAndMetadata(t *testing.T) {
+	ctx := context.Background()
+	m := NewMockS3()
+
+	if err := m.CopyObject(ctx, "", "missing", "", "copy"); !errors.Is(err, ErrMockNotFound) {
+		t.Fatalf("CopyObject(missing) error = %v, want ErrMockNotFound", err)
+	}
+
+	_, _ = m.Upload(ctx, "", "key", strings.NewReader("original"), "image/png")
+	if err := m.CopyObject(ctx, "", "key", "backup", "copy"); err != nil {
+		t.Fatalf("CopyObject: %v", err)
+	}
+	_, _ = m.Upload(ctx, "", "key", strings.NewReader("changed"), "")
+
+	if got, _ := m.Object("backup", "copy"); string(got) != "original" {
+		t.Errorf("copy = %q, want original", got)
+	}
+	size, err := m.ObjectSize(ctx, "backup", "copy")
+	if err != nil || size != int64(len("original")) {
+		t.Errorf("ObjectSize = %d, %v, want %d", size, err, len("original"))
+	}
+
+	metadata, err := m.GetObjectMetadata(ctx, "backup", "copy")
+	if err != nil {
+		t.Fatalf("GetObjectMetadata: %v", err)
+	}
+	if metadata[MetadataContentType] != "image/png" || metadata[MetadataContentLength] != "8" || metadata[MetadataLastModified] == "" {
+		t.Errorf("metadata of the copy = %v", metadata)
+	}
+	metadata, _ = m.GetObjectMetadata(ctx, "", "key")
+	if _, ok := metadata[MetadataContentType]; ok {
+		t.Errorf("upload without content type kept %q", metadata[MetadataContentType])
+	}
+
+	for _, call := range []func() error{
+		func() error { _, err := m.ObjectSize(ctx, "", "missing"); return err },
+		func() error { _, err := m.GetObjectMetadata(ctx, "", "missing"); return err },
+	} {
+		if err := call(); !errors.Is(err, ErrMockNotFound) {
+			t.Errorf("missing object error = %v, want ErrMockNotFound", err)
+		}
+	}
+}
+
+func TestMockS3ListObjects(t *testing.T) {
+	ctx := context.Background()
+	m := NewMockS3()
+	for _, key := range []string{"b/2", "a/1", "b/1", "c"} {
+		_, _ = m.Upload(ctx, "", key, strings.NewReader(key), "")
+	}
+	_, _ = m.Upload(ctx, "other", "b/3", strings.NewReader("x"), "")
+
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"", []string{"a/1", "b/1", "b/2", "c"}},
+		{"b/", []string{"b/1", "b/2"}},
+		{"z", nil},
+	}
+	for _, tt := range tests {
+		keys, err := m.ListObjects(ctx, "", tt.prefix)
+		if err != nil || !reflect.DeepEqual(keys, tt.want) {
+			t.Errorf("ListObjects(%q) = %v, %v, want %v", tt.prefix, keys, err, tt.want)
+		}
+
+		keysChan, errs := m.ListObjectsChan(ctx, "", tt.prefix)
+		var streamed []string
+		for key := range keysChan {
+			streamed = append(streamed, key)
+		}
+		if err := <-errs; err != nil || !reflect.DeepEqual(streamed, tt.want) {
+			t.Errorf("ListObjectsChan(%q) = %v, %v, want %v", tt.prefix, streamed, err, tt.want)
+		}
+	}
+
+	usage, err := m.GetStorageUsage(ctx, "", "b/")
+	if err != nil || usage != (StorageUsage{TotalBytes: 6, TotalObjects: 2}) {
+		t.Errorf("GetStorageUsage = %+v, %v", usage, err)
+	}
+}
+
+func TestMockS3StorageByDay(t *testing.T) {
+	ctx := context.Background()
+	m := NewMockS3()
+	day := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	for i, size := range []int{1, 2, 4} {
+		key := string(rune('a' + i))
+		_, _ = m.Upload(ctx, "", key, strings.NewReader(strings.Repeat("x", size)), "")
+		m.SetModified("", key, day.AddDate(0, 0, i/2))
+	}
+
+	days, err := m.GetStorageByDay(ctx, "", "", day.AddDate(0, 0, -1), day.AddDate(0, 0, 5))
+	want := map[string]int64{"2026-03-10": 3, "2026-03-11": 4}
+	if err != nil || !reflect.DeepEqual(days, want) {
+		t.Errorf("GetStorageByDay = %v, %v, want %v", days, err, want)
+	}
+}
+
+func TestMockS3IncompleteUploads(t *testing.T) {
+	ctx := context.Background()
+	m := NewMockS3()
+	m.AddIncompleteUpload("", IncompleteUpload{Key: "b", UploadID: "2"})
+	m.AddIncompleteUpload("", IncompleteUpload{Key: "a", UploadID: "1"})
+	m.AddIncompleteUpload("other", IncompleteUpload{Key: "c", UploadID: "3"})
+
+	uploads, err := m.ListIncompleteUploads(ctx, "")
+	want := []IncompleteUpload{{Key: "a", UploadID: "1"}, {Key: "b", UploadID: "2"}}
+	if err != nil || !reflect.DeepEqual(uploads, want) {
+		t.Fatalf("ListIncompleteUploads = %v, %v, want %v", uploads, err, want)
+	}
+
+	if err := m.AbortMultipartUpload(ctx, "", "a", "1"); err != nil {
+		t.Fatalf("AbortMultipartUpload: %v", err)
+	}
+	if err := m.AbortMultipartUpload(ctx, "", "a", "1"); !errors.Is(err, ErrMockNotFound) {
+		t.Errorf("second abort error = %v, want ErrMockNotFound", err)
+	}
+	if uploads, _ := m.ListIncompleteUploads(ctx, ""); len(uploads) != 1 {
+		t.Errorf("%d uploads left after abort, want 1", len(uploads))
+	}
+}
+
+func TestMockS3ObjectLock(t *testing.T) {
+	ctx := context.Background()
+	m := NewMockS3()
+	m.ObjectLockRetention = time.Hour
+
+	_, _ = m.Upload(ctx, "", "key", strings.NewReader("data"), "")
+	lock, err := m.GetObjectLockConfig(ctx, "", "key")
+	if err != nil || !lock.Locked(time.Now()) || lock.Mode != ObjectLockModeCompliance {
+		t.Fatalf("GetObjectLockConfig = %+v, %v, want a compliance lock", lock, err)
+	}
+	if err := m.Delete(ctx, "", "key"); !errors.Is(err, ErrObjectLocked) {
+		t.Errorf("Delete of a locked object error = %v, want ErrObjectLocked", err)
+	}
+
+	if err := m.ExtendObjectLock(ctx, "", "key", lock.RetainUntil.Add(-time.Minute)); !errors.Is(err, ErrRetentionShortened) {
+		t.Errorf("shortening retention error = %v, want ErrRetentionShortened", err)
+	}
+	later := lock.RetainUntil.Add(time.Hour)
+	if err := m.ExtendObjectLock(ctx, "", "key", later); err != nil {
+		t.Fatalf("ExtendObjectLock: %v", err)
+	}
+	if lock, _ := m.GetObjectLockConfig(ctx, "", "key"); !lock.RetainUntil.Equal(later) {
+		t.Errorf("RetainUntil = %v, want %v", lock.RetainUntil, later)
+	}
+	if err := m.ExtendObjectLock(ctx, "", "missing", later); !errors.Is(err, ErrMockNotFound) {
+		t.Errorf("ExtendObjectLock(missing) error = %v, want ErrMockNotFound", err)
+	}
+
+	// An expired retention no longer blocks deletes
+	m.locks.Store(m.objectKey("", "key"), ObjectLockConfig{Mode: ObjectLockModeCompliance, RetainUntil: time.Now().Add(-time.Second)})
+	if err := m.Delete(ctx, "", "key"); err != nil {
+		t.Errorf("Delete after retention: %v", err)
+	}
+}
+
+func TestMockS3ForceError(t *testing.T) {
+	ctx := context.Background()
+	errForced := errors.New("forced")
+
+	// Every interface method must honour ForceError under its own name
+	calls := map[string]func(m *MockS3) error{
+		"Upload": func(m *MockS3) error {
+			_, err := m.Upload(ctx, "", "key", strings.NewReader("x"), "")
+			return err
+		},
+		"UploadWithProgress": func(m *MockS3) error {
+			_, err := m.UploadWithProgress(ctx, "", "key", strings.NewReader("x"), 1, "", nil)
+			return err
+		},
+		"UploadStream": func(m *MockS3) error {
+			_, err := m.UploadStream(ctx, "", "key", strings.NewReader("x"), 1, "", nil)
+			return err
+		},
+		"Download": func(m *MockS3) error {
+			_, err := m.Download(ctx, "", "key")
+			return err
+		},
+		"Delete": func(m *MockS3) error { return m.Delete(ctx, "", "key") },
+		"CopyObject": func(m *MockS3) error {
+			return m.CopyObject(ctx, "", "key", "", "copy")
+		},
+		"ObjectSize": func(m *MockS3) error {
+			_, err := m.ObjectSize(ctx, "", "key")
+			return err
+		},
+		"GetObjectMetadata": func(m *MockS3) error {
+			_, err := m.GetObjectMetadata(ctx, "", "key")
+			return err
+		},
+		"ListObjects": func(m *MockS3) error {
+			_, err := m.ListObjects(ctx, "", "")
+			return err
+		},
+		"EnsureBucket": func(m *MockS3) error { return m.EnsureBucket(ctx) },
+		"PresignGetURL": func(m *MockS3) error {
+			_, err := m.PresignGetURL(ctx, "", "key", time.Minute)
+			return err
+		},
+		"ListIncompleteUploads": func(m *MockS3) error {
+			_, err := m.ListIncompleteUploads(ctx, "")
+			return err
+		},
+		"AbortMultipartUpload": func(m *MockS3) error {
+			return m.AbortMultipartUpload(ctx, "", "key", "1")
+		},
+		"HealthCheck": func(m *MockS3) error { return m.HealthCheck(ctx) },
+		"GetStorageUsage": func(m *MockS3) error {
+			_, err := m.GetStorageUsage(ctx, "", "")
+			return err
+		},
+		"GetStorageByDay": func(m *MockS3) error {
+			_, err := m.GetStorageByDay(ctx, "", "", time.Time{}, time.Time{})
+			return err
+		},
+		"GetObjectLockConfig": func(m *MockS3) error {
+			_, err := m.GetObjectLockConfig(ctx, "", "key")
+			return err
+		},
+		"ExtendObjectLock": func(m *MockS3) error {
+			return m.ExtendObjectLock(ctx, "", "key", time.Now().Add(time.Hour))
+		},
+	}
+	for method, call := range calls {
+		t.Run(method, func(t *testing.T) {
+			m := NewMockS3()
+			m.store(m.objectKey("", "key"), []byte("x")) // not counted as a call
+			m.AddIncompleteUpload("", IncompleteUpload{Key: "key", UploadID: "1"})
+
+			m.ForceError[method] = errForced
+			if err := call(m); !errors.Is(err, errForced) {
+				t.Errorf("%s error = %v, want the forced error", method, err)
+			}
+			if method != "Upload" {
+				if got, ok := m.Object("", "key"); !ok || string(got) != "x" {
+					t.Errorf("failed %s changed the stored object", method)
+				}
+			}
+
+			m.SetError(method, nil)
+			if err := call(m); err != nil {
+				t.Errorf("%s after clearing the error: %v", method, err)
+			}
+			if n := m.Calls(method); n != 2 {
+				t.Errorf("Calls(%s) = %d, want 2", method, n)
+			}
+		})
+	}
+
+	// ListObjectsChan reports ListObjects errors on its error channel
+	m := NewMockS3()
+	m.SetError("ListObjects", errForced)
+	keys, errs := m.ListObjectsChan(ctx, "", "")
+	for range keys {
+		t.Error("ListObjectsChan returned keys despite the error")
+	}
+	if err := <-errs; !errors.Is(err, errForced) {
+		t.Errorf("ListObjectsChan error = %v, want the forced error", err)
+	}
+}
+
+func TestMockS3Reset(t *testing.T) {
+	ctx := context.Background()
+	m := NewMockS3()
+	_, _ = m.Upload(ctx, "", "key", strings.NewReader("x"), "")
+	m.AddIncompleteUpload("", IncompleteUpload{Key: "key", UploadID: "1"})
+	m.SetError("Download", errors.New("forced"))
+
+	m.Reset()
+
+	if _, ok := m.Object("", "key"); ok {
+		t.Error("object kept after Reset")
+	}
+	if n := m.Calls("Upload"); n != 0 {
+		t.Errorf("Calls(Upload) = %d after Reset", n)
+	}
+	if _, err := m.Download(ctx, "", "key"); !errors.Is(err, ErrMockNotFound) {
+		t.Errorf("Download error = %v, want ErrMockNotFound once the forced error is reset", err)
+	}
+	if uploads, _ := m.ListIncompleteUploads(ctx, ""); len(uploads) != 0 {
+		t.Errorf("%d incomplete uploads kept after Reset", len(uploads))
+	}
+}
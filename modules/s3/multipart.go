@@ -0,0 +1,125 @@
+package s3
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"go.uber.org/zap"
+
+	"lovebin/modules/logger"
+)
+
+// DefaultMultipartUploadTTL is how old an incomplete multipart upload gets before it is aborted
+const DefaultMultipartUploadTTL = 24 * time.Hour
+
+// IncompleteUpload is a multipart upload that was started but never completed or aborted
+type IncompleteUpload struct {
+	Key       string
+	UploadID  string
+	Initiated time.Time
+}
+
+func (s *s3Impl) ListIncompleteUploads(ctx context.Context, bucket string) ([]IncompleteUpload, error) {
+	bucketName := bucket
+	if bucketName == "" {
+		bucketName = s.bucket
+	}
+
+	var (
+		uploads        []IncompleteUpload
+		keyMarker      *string
+		uploadIDMarker *string
+	)
+	for {
+		result, err := s.client().ListMultipartUploads(ctx, &s3.ListMultipartUploadsInput{
+			Bucket:         aws.String(bucketName),
+			KeyMarker:      keyMarker,
+			UploadIdMarker: uploadIDMarker,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, upload := range result.Uploads {
+			uploads = append(uploads, IncompleteUpload{
+				Key:       aws.ToString(upload.Key),
+				UploadID:  aws.ToString(upload.UploadId),
+				Initiated: aws.ToTime(upload.Initiated),
+			})
+		}
+
+		if !aws.ToBool(result.IsTruncated) {
+			return uploads, nil
+		}
+		keyMarker, uploadIDMarker = result.NextKeyMarker, result.NextUploadIdMarker
+	}
+}
+
+func (s *s3Impl) AbortMultipartUpload(ctx context.Context, bucket, key, uploadID string) error {
+	bucketName := bucket
+	if bucketName == "" {
+		bucketName = s.bucket
+	}
+
+	return RetryS3(ctx, s.maxRetries, s.retryBaseDelay, func() error {
+		_, err := s.client().AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(bucketName),
+			Key:      aws.String(key),
+			UploadId: aws.String(uploadID),
+		})
+		return err
+	})
+}
+
+// MultipartUploadManager aborts multipart uploads left behind by interrupted
+// uploads, their parts are stored and billed until then
+type MultipartUploadManager struct {
+	logger logger.Logger
+	s3     S3
+	bucket string // empty uses the client's bucket
+	ttl    time.Duration
+}
+
+// NewMultipartUploadManager returns a manager aborting uploads older than ttl (DefaultMultipartUploadTTL when not positive)
+func NewMultipartUploadManager(log logger.Logger, client S3, bucket string, ttl time.Duration) *MultipartUploadManager {
+	if ttl <= 0 {
+		ttl = DefaultMultipartUploadTTL
+	}
+	return &MultipartUploadManager{
+		logger: log.Child("s3-multipart"),
+		s3:     client,
+		bucket: bucket,
+		ttl:    ttl,
+	}
+}
+
+// AbortStale aborts incomplete uploads initiated more than ttl ago and returns how many
+// were aborted. Failed aborts are logged and left for the next run.
+func (m *MultipartUploadManager) AbortStale(ctx context.Context) (int, error) {
+	uploads, err := m.s3.ListIncompleteUploads(ctx, m.bucket)
+	if err != nil {
+		return 0, err
+	}
+
+	cutoff := time.Now().Add(-m.ttl)
+	aborted := 0
+	for _, upload := range uploads {
+		if upload.Initiated.After(cutoff) {
+			continue // possibly still in progress
+		}
+		if err := m.s3.AbortMultipartUpload(ctx, m.bucket, upload.Key, upload.UploadID); err != nil {
+			if ctx.Err() != nil {
+				return aborted, ctx.Err()
+			}
+			m.logger.ErrorCtx(ctx, "failed to abort incomplete multipart upload", zap.Error(err),
+				zap.String("key", upload.Key), zap.String("upload_id", upload.UploadID))
+			continue
+		}
+		aborted++
+		m.logger.WarnCtx(ctx, "aborted incomplete multipart upload",
+			zap.String("key", upload.Key), zap.String("upload_id", upload.UploadID), zap.Time("initiated", upload.Initiated))
+	}
+	return aborted, nil
+}
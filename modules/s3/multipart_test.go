@@ -0,0 +1,148 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"lovebin/modules/logger"
+)
+
+func TestListIncompleteUploads(t *testing.T) {
+	server := newFakeS3Server(t, "media", "other")
+	server.pageSize = 2
+	initiated := time.Now().Add(-time.Hour).Truncate(time.Second)
+	var want []IncompleteUpload
+	for _, key := range []string{"media/a", "media/b", "media/b", "media/c", "media/d"} {
+		id := server.startUpload("media", key, initiated)
+		want = append(want, IncompleteUpload{Key: key, UploadID: id})
+	}
+	server.startUpload("other", "media/e", initiated)
+	storage := newTestS3(t, server, Config{})
+
+	uploads, err := storage.ListIncompleteUploads(context.Background(), "")
+	if err != nil {
+		t.Fatalf("ListIncompleteUploads: %v", err)
+	}
+	var got []IncompleteUpload
+	for _, upload := range uploads {
+		if !upload.Initiated.Equal(initiated) {
+			t.Errorf("upload %s initiated %v, want %v", upload.UploadID, upload.Initiated, initiated)
+		}
+		got = append(got, IncompleteUpload{Key: upload.Key, UploadID: upload.UploadID})
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ListIncompleteUploads = %v, want %v", got, want)
+	}
+	if n := server.count("ListMultipartUploads"); n != 3 {
+		t.Errorf("ListMultipartUploads called %d times, want 3 pages", n)
+	}
+}
+
+func TestAbortMultipartUpload(t *testing.T) {
+	server := newFakeS3Server(t, "media")
+	id := server.startUpload("media", "media/key", time.Now())
+	server.failNext("AbortMultipartUpload", http.StatusServiceUnavailable)
+	storage := newTestS3(t, server, Config{})
+
+	if err := storage.AbortMultipartUpload(context.Background(), "", "media/key", id); err != nil {
+		t.Fatalf("AbortMultipartUpload after a 503: %v", err)
+	}
+	if uploads, _ := storage.ListIncompleteUploads(context.Background(), ""); len(uploads) != 0 {
+		t.Errorf("uploads left after the abort: %v", uploads)
+	}
+}
+
+func newTestLogger(t *testing.T) logger.Logger {
+	t.Helper()
+	log, err := logger.Init(logger.Config{Level: "fatal"})
+	if err != nil {
+		t.Fatalf("logger.Init: %v", err)
+	}
+	return log
+}
+
+func TestAbortStale(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name        string
+		ttl         time.Duration
+		initiated   []time.Duration // ages of the uploads
+		wantAborted int
+		wantLeft    int
+	}{
+		{"none", time.Hour, nil, 0, 0},
+		{"all fresh", time.Hour, []time.Duration{time.Minute, 59 * time.Minute}, 0, 2},
+		{"all stale", time.Hour, []time.Duration{2 * time.Hour, 48 * time.Hour}, 2, 0},
+		{"mixed", time.Hour, []time.Duration{time.Minute, 2 * time.Hour, 3 * time.Hour}, 2, 1},
+		{"default TTL", 0, []time.Duration{23 * time.Hour, 25 * time.Hour}, 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMockS3()
+			for i, age := range tt.initiated {
+				m.AddIncompleteUpload("", IncompleteUpload{Key: "media/key", UploadID: string(rune('a' + i)), Initiated: now.Add(-age)})
+			}
+			manager := NewMultipartUploadManager(newTestLogger(t), m, "", tt.ttl)
+
+			aborted, err := manager.AbortStale(context.Background())
+			if err != nil || aborted != tt.wantAborted {
+				t.Errorf("AbortStale = %d, %v, want %d", aborted, err, tt.wantAborted)
+			}
+			if left, _ := m.ListIncompleteUploads(context.Background(), ""); len(left) != tt.wantLeft {
+				t.Errorf("%d uploads left, want %d", len(left), tt.wantLeft)
+			}
+		})
+	}
+}
+
+func TestAbortStaleErrors(t *testing.T) {
+	old := time.Now().Add(-48 * time.Hour)
+
+	t.Run("list fails", func(t *testing.T) {
+		m := NewMockS3()
+		listErr := errors.New("list failed")
+		m.SetError("ListIncompleteUploads", listErr)
+		manager := NewMultipartUploadManager(newTestLogger(t), m, "", time.Hour)
+		if _, err := manager.AbortStale(context.Background()); !errors.Is(err, listErr) {
+			t.Errorf("AbortStale error = %v, want %v", err, listErr)
+		}
+	})
+
+	t.Run("abort fails", func(t *testing.T) {
+		m := NewMockS3()
+		m.AddIncompleteUpload("", IncompleteUpload{Key: "a", UploadID: "1", Initiated: old})
+		m.AddIncompleteUpload("", IncompleteUpload{Key: "b", UploadID: "2", Initiated: old})
+		m.SetError("AbortMultipartUpload", errors.New("abort failed"))
+		manager := NewMultipartUploadManager(newTestLogger(t), m, "", time.Hour)
+
+		// Failed aborts are left for the next run, the others still go ahead
+		aborted, err := manager.AbortStale(context.Background())
+		if err != nil || aborted != 0 {
+			t.Errorf("AbortStale = %d, %v, want 0 without an error", aborted, err)
+		}
+		if n := m.Calls("AbortMultipartUpload"); n != 2 {
+			t.Errorf("AbortMultipartUpload called %d times, want 2", n)
+		}
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		m := NewMockS3()
+		m.AddIncompleteUpload("", IncompleteUpload{Key: "a", UploadID: "1", Initiated: old})
+		m.AddIncompleteUpload("", IncompleteUpload{Key: "b", UploadID: "2", Initiated: old})
+		m.SetError("AbortMultipartUpload", context.Canceled)
+		manager := NewMultipartUploadManager(newTestLogger(t), m, "", time.Hour)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := manager.AbortStale(ctx); !errors.Is(err, context.Canceled) {
+			t.Errorf("AbortStale error = %v, want context.Canceled", err)
+		}
+		if n := m.Calls("AbortMultipartUpload"); n != 1 {
+			t.Errorf("AbortMultipartUpload called %d times after the cancel, want 1", n)
+		}
+	})
+}
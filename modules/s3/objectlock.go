@@ -0,0 +1,127 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// ObjectLockModeCompliance is the retention mode of locked uploads. Until the
+// retention date passes no user, including the account root, can delete the
+// object or shorten its retention.
+const ObjectLockModeCompliance = string(types.ObjectLockModeCompliance)
+
+// ErrObjectLocked is returned by Delete for an object whose retention has not passed yet
+var ErrObjectLocked = errors.New("object is locked by its retention")
+
+// ErrRetentionShortened is returned by ExtendObjectLock for a date before the current retention
+var ErrRetentionShortened = errors.New("object lock retention can only be extended")
+
+// ObjectLockConfig is the retention of an object, zero when it has none
+type ObjectLockConfig struct {
+	Mode        string    // ObjectLockModeCompliance, or GOVERNANCE for objects locked outside the app
+	RetainUntil time.Time // the object cannot be deleted before this time
+}
+
+// Locked reports whether the retention is still in force at now
+func (c ObjectLockConfig) Locked(now time.Time) bool {
+	return c.Mode != "" && now.Before(c.RetainUntil)
+}
+
+// objectLockMode returns the lock mode of uploads and copies, empty without object lock
+func (s *s3Impl) objectLockMode() types.ObjectLockMode {
+	if !s.cfg.EnableObjectLock {
+		return ""
+	}
+	return types.ObjectLockModeCompliance
+}
+
+// retainUntilDate returns the retention date of an object stored now, nil without object lock
+func (s *s3Impl) retainUntilDate() *time.Time {
+	if !s.cfg.EnableObjectLock {
+		return nil
+	}
+	return aws.Time(time.Now().UTC().AddDate(0, 0, s.cfg.ObjectLockRetentionDays))
+}
+
+// GetObjectLockConfig returns the retention of an object. Objects without one,
+// missing objects and objects in buckets without object lock return a zero ObjectLockConfig.
+func (s *s3Impl) GetObjectLockConfig(ctx context.Context, bucket, key string) (ObjectLockConfig, error) {
+	bucketName := bucket
+	if bucketName == "" {
+		bucketName = s.bucket
+	}
+
+	var result *s3.GetObjectRetentionOutput
+	err := RetryS3(ctx, s.maxRetries, s.retryBaseDelay, func() error {
+		var getErr error
+		result, getErr = s.client().GetObjectRetention(ctx, &s3.GetObjectRetentionInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String(key),
+		})
+		return getErr
+	})
+	if isNoRetention(err) {
+		return ObjectLockConfig{}, nil
+	}
+	if err != nil {
+		return ObjectLockConfig{}, err
+	}
+	if result.Retention == nil {
+		return ObjectLockConfig{}, nil
+	}
+	return ObjectLockConfig{
+		Mode:        string(result.Retention.Mode),
+		RetainUntil: aws.ToTime(result.Retention.RetainUntilDate),
+	}, nil
+}
+
+// isNoRetention reports whether GetObjectRetention failed because the object, its
+// retention or the object lock of its bucket is missing; S3 answers InvalidRequest
+// for buckets without object lock
+func isNoRetention(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.ErrorCode() {
+	case "NoSuchKey", "NoSuchObjectLockConfiguration", "ObjectLockConfigurationNotFoundError", "InvalidRequest":
+		return true
+	}
+	return false
+}
+
+// ExtendObjectLock moves the retention of an object to newRetainUntil in compliance mode.
+// Compliance retention cannot be shortened, an earlier date returns ErrRetentionShortened.
+func (s *s3Impl) ExtendObjectLock(ctx context.Context, bucket, key string, newRetainUntil time.Time) error {
+	bucketName := bucket
+	if bucketName == "" {
+		bucketName = s.bucket
+	}
+
+	current, err := s.GetObjectLockConfig(ctx, bucketName, key)
+	if err != nil {
+		return err
+	}
+	if newRetainUntil.Before(current.RetainUntil) {
+		return fmt.Errorf("%w: retained until %s", ErrRetentionShortened, current.RetainUntil.Format(time.RFC3339))
+	}
+
+	return RetryS3(ctx, s.maxRetries, s.retryBaseDelay, func() error {
+		_, putErr := s.client().PutObjectRetention(ctx, &s3.PutObjectRetentionInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String(key),
+			Retention: &types.ObjectLockRetention{
+				Mode:            types.ObjectLockRetentionModeCompliance,
+				RetainUntilDate: aws.Time(newRetainUntil.UTC()),
+			},
+		})
+		return putErr
+	})
+}
@@ -0,0 +1,171 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestObjectLockConfigLocked(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name string
+		lock ObjectLockConfig
+		want bool
+	}{
+		{"none", ObjectLockConfig{}, false},
+		{"in force", ObjectLockConfig{Mode: ObjectLockModeCompliance, RetainUntil: now.Add(time.Hour)}, true},
+		{"passed", ObjectLockConfig{Mode: ObjectLockModeCompliance, RetainUntil: now.Add(-time.Hour)}, false},
+		{"until now", ObjectLockConfig{Mode: ObjectLockModeCompliance, RetainUntil: now}, false},
+		{"date without mode", ObjectLockConfig{RetainUntil: now.Add(time.Hour)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.lock.Locked(now); got != tt.want {
+				t.Errorf("Locked = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// newLockedS3 returns storage locking objects for 30 days, storing multipart above one minimal part
+func newLockedS3(t *testing.T, server *fakeS3Server) *s3Impl {
+	t.Helper()
+	return newTestS3(t, server, Config{
+		EnableObjectLock:        true,
+		ObjectLockRetentionDays: 30,
+		Transfer:                TransferManagerConfig{MultipartThreshold: minPartSize, PartSize: minPartSize},
+	})
+}
+
+func TestObjectLockOnStore(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name    string
+		store   func(storage *s3Impl, server *fakeS3Server) error
+		wantMD5 string // operation that must carry Content-MD5
+	}{
+		{"upload", func(storage *s3Impl, _ *fakeS3Server) error {
+			_, err := storage.Upload(ctx, "", "media/key", bytes.NewReader([]byte("data")), "")
+			return err
+		}, "PutObject"},
+		{"multipart", func(storage *s3Impl, _ *fakeS3Server) error {
+			data := make([]byte, minPartSize+1)
+			_, err := storage.UploadWithProgress(ctx, "", "media/key", bytes.NewReader(data), int64(len(data)), "", nil)
+			return err
+		}, "UploadPart"},
+		{"copy", func(storage *s3Impl, server *fakeS3Server) error {
+			server.put("media", "media/source", []byte("data"), time.Now())
+			return storage.CopyObject(ctx, "", "media/source", "", "media/key")
+		}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeS3Server(t, "media")
+			storage := newLockedS3(t, server)
+			before := time.Now()
+			if err := tt.store(storage, server); err != nil {
+				t.Fatalf("store: %v", err)
+			}
+
+			object, ok := server.object("media", "media/key")
+			if !ok {
+				t.Fatal("object not stored")
+			}
+			if object.lockMode != ObjectLockModeCompliance {
+				t.Errorf("lock mode = %q, want %s", object.lockMode, ObjectLockModeCompliance)
+			}
+			want := before.AddDate(0, 0, 30)
+			if object.retainUntil.Before(want.Add(-time.Second)) || object.retainUntil.After(want.Add(time.Minute)) {
+				t.Errorf("retained until %v, want 30 days from now", object.retainUntil)
+			}
+			if tt.wantMD5 != "" && server.last(tt.wantMD5).header.Get("Content-MD5") == "" {
+				t.Errorf("%s sent without Content-MD5", tt.wantMD5)
+			}
+		})
+	}
+}
+
+func TestGetObjectLockConfig(t *testing.T) {
+	server := newFakeS3Server(t, "media")
+	storage := newLockedS3(t, server)
+	ctx := context.Background()
+	server.put("media", "media/unlocked", []byte("data"), time.Now())
+	if _, err := storage.Upload(ctx, "", "media/locked", bytes.NewReader([]byte("data")), ""); err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+
+	for _, key := range []string{"media/unlocked", "media/missing"} {
+		if lock, err := storage.GetObjectLockConfig(ctx, "", key); err != nil || lock != (ObjectLockConfig{}) {
+			t.Errorf("GetObjectLockConfig(%s) = %+v, %v, want none", key, lock, err)
+		}
+	}
+	lock, err := storage.GetObjectLockConfig(ctx, "", "media/locked")
+	if err != nil || !lock.Locked(time.Now()) || lock.Mode != ObjectLockModeCompliance {
+		t.Errorf("GetObjectLockConfig = %+v, %v, want a compliance lock", lock, err)
+	}
+}
+
+func TestExtendObjectLock(t *testing.T) {
+	server := newFakeS3Server(t, "media")
+	storage := newLockedS3(t, server)
+	ctx := context.Background()
+	if _, err := storage.Upload(ctx, "", "media/key", bytes.NewReader([]byte("data")), ""); err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	lock, _ := storage.GetObjectLockConfig(ctx, "", "media/key")
+
+	if err := storage.ExtendObjectLock(ctx, "", "media/key", lock.RetainUntil.Add(-time.Hour)); !errors.Is(err, ErrRetentionShortened) {
+		t.Errorf("ExtendObjectLock to an earlier date = %v, want %v", err, ErrRetentionShortened)
+	}
+	if n := server.count("PutObjectRetention"); n != 0 {
+		t.Errorf("PutObjectRetention sent %d times for a shortened retention", n)
+	}
+
+	later := lock.RetainUntil.Add(24 * time.Hour).Truncate(time.Second)
+	if err := storage.ExtendObjectLock(ctx, "", "media/key", later); err != nil {
+		t.Fatalf("ExtendObjectLock: %v", err)
+	}
+	if extended, _ := storage.GetObjectLockConfig(ctx, "", "media/key"); !extended.RetainUntil.Equal(later) || extended.Mode != ObjectLockModeCompliance {
+		t.Errorf("retention after extending = %+v, want compliance until %v", extended, later)
+	}
+}
+
+func TestDeleteLockedObject(t *testing.T) {
+	server := newFakeS3Server(t, "media")
+	storage := newLockedS3(t, server)
+	ctx := context.Background()
+	if _, err := storage.Upload(ctx, "", "media/locked", bytes.NewReader([]byte("data")), ""); err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	server.put("media", "media/unlocked", []byte("data"), time.Now())
+
+	if err := storage.Delete(ctx, "", "media/locked"); !errors.Is(err, ErrObjectLocked) {
+		t.Errorf("Delete of a locked object = %v, want %v", err, ErrObjectLocked)
+	}
+	if _, ok := server.object("media", "media/locked"); !ok {
+		t.Error("locked object was deleted")
+	}
+	if n := server.count("DeleteObject"); n != 0 {
+		t.Errorf("DeleteObject sent %d times for a locked object", n)
+	}
+
+	if err := storage.Delete(ctx, "", "media/unlocked"); err != nil {
+		t.Errorf("Delete of an unlocked object: %v", err)
+	}
+	if _, ok := server.object("media", "media/unlocked"); ok {
+		t.Error("unlocked object was kept")
+	}
+
+	// Without object lock the retention is not looked up
+	plain := newTestS3(t, server, Config{})
+	before := server.count("GetObjectRetention")
+	if err := plain.Delete(ctx, "", "media/other"); err != nil {
+		t.Errorf("Delete: %v", err)
+	}
+	if server.count("GetObjectRetention") != before {
+		t.Error("Delete looked up the retention without object lock")
+	}
+}
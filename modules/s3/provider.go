@@ -0,0 +1,63 @@
+package s3
+
+import (
+	"errors"
+	"fmt"
+)
+
+// S3-compatible providers selectable with Config.Provider on the s3 backend
+const (
+	ProviderAWS    = "aws"
+	ProviderR2     = "r2"     // Cloudflare R2
+	ProviderB2     = "b2"     // Backblaze B2
+	ProviderWasabi = "wasabi" // Wasabi
+)
+
+// Default regions of the providers. R2 has a single "auto" region, B2 has no default:
+// its endpoint depends on the region of the bucket.
+const (
+	defaultAWSRegion    = "us-east-1"
+	defaultR2Region     = "auto"
+	defaultWasabiRegion = "us-east-1"
+)
+
+// withProviderDefaults fills Region and, unless set, Endpoint for cfg.Provider.
+// An explicit Endpoint always wins, it is only checked that the provider is known.
+func withProviderDefaults(cfg Config) (Config, error) {
+	switch cfg.Provider {
+	case "", ProviderAWS:
+		if cfg.Region == "" {
+			cfg.Region = defaultAWSRegion
+		}
+		return cfg, nil
+	case ProviderR2:
+		if cfg.Region == "" {
+			cfg.Region = defaultR2Region
+		}
+		if cfg.Endpoint == "" {
+			if cfg.R2AccountID == "" {
+				return cfg, errors.New("s3 provider r2 needs an account id or an endpoint")
+			}
+			cfg.Endpoint = "https://" + cfg.R2AccountID + ".r2.cloudflarestorage.com"
+		}
+		return cfg, nil
+	case ProviderB2:
+		if cfg.Endpoint == "" {
+			if cfg.Region == "" {
+				return cfg, errors.New("s3 provider b2 needs the region of the bucket, e.g. us-west-004")
+			}
+			cfg.Endpoint = "https://s3." + cfg.Region + ".backblazeb2.com"
+		}
+		return cfg, nil
+	case ProviderWasabi:
+		if cfg.Region == "" {
+			cfg.Region = defaultWasabiRegion
+		}
+		if cfg.Endpoint == "" {
+			cfg.Endpoint = "https://s3." + cfg.Region + ".wasabisys.com"
+		}
+		return cfg, nil
+	default:
+		return cfg, fmt.Errorf("unknown s3 provider %q", cfg.Provider)
+	}
+}
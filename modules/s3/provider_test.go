@@ -0,0 +1,58 @@
+package s3
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestWithProviderDefaults(t *testing.T) {
+	tests := []struct {
+		name         string
+		cfg          Config
+		wantRegion   string
+		wantEndpoint string
+		wantErr      string // part of the error, empty when cfg is valid
+	}{
+		{"default is aws", Config{}, "us-east-1", "", ""},
+		{"aws region", Config{Provider: ProviderAWS, Region: "eu-central-1"}, "eu-central-1", "", ""},
+		{"aws endpoint", Config{Provider: ProviderAWS, Endpoint: "http://localhost:9000"}, "us-east-1", "http://localhost:9000", ""},
+		{"r2 account", Config{Provider: ProviderR2, R2AccountID: "abc123"}, "auto", "https://abc123.r2.cloudflarestorage.com", ""},
+		{"r2 endpoint", Config{Provider: ProviderR2, Endpoint: "https://r2.example"}, "auto", "https://r2.example", ""},
+		{"r2 region", Config{Provider: ProviderR2, R2AccountID: "abc123", Region: "eu"}, "eu", "https://abc123.r2.cloudflarestorage.com", ""},
+		{"r2 without account", Config{Provider: ProviderR2}, "", "", "account id"},
+		{"b2 region", Config{Provider: ProviderB2, Region: "us-west-004"}, "us-west-004", "https://s3.us-west-004.backblazeb2.com", ""},
+		{"b2 endpoint", Config{Provider: ProviderB2, Endpoint: "https://b2.example"}, "", "https://b2.example", ""},
+		{"b2 without region", Config{Provider: ProviderB2}, "", "", "region"},
+		{"wasabi", Config{Provider: ProviderWasabi}, "us-east-1", "https://s3.us-east-1.wasabisys.com", ""},
+		{"wasabi region", Config{Provider: ProviderWasabi, Region: "eu-central-2"}, "eu-central-2", "https://s3.eu-central-2.wasabisys.com", ""},
+		{"wasabi endpoint", Config{Provider: ProviderWasabi, Endpoint: "https://wasabi.example"}, "us-east-1", "https://wasabi.example", ""},
+		{"unknown", Config{Provider: "minio"}, "", "", `unknown s3 provider "minio"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := withProviderDefaults(tt.cfg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("withProviderDefaults error = %v, want it to mention %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("withProviderDefaults: %v", err)
+			}
+			if got.Region != tt.wantRegion || got.Endpoint != tt.wantEndpoint {
+				t.Errorf("region, endpoint = %q, %q, want %q, %q", got.Region, got.Endpoint, tt.wantRegion, tt.wantEndpoint)
+			}
+		})
+	}
+}
+
+func TestInitUnknownProvider(t *testing.T) {
+	if _, err := Init(context.Background(), Config{Provider: "minio", Bucket: "media"}); err == nil {
+		t.Error("Init accepted an unknown provider")
+	}
+	if _, err := Init(context.Background(), Config{Provider: ProviderB2, Bucket: "media"}); err == nil {
+		t.Error("Init accepted b2 without a region or endpoint")
+	}
+}
@@ -0,0 +1,62 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"time"
+)
+
+// Retry defaults used when the config leaves them unset
+const (
+	defaultMaxRetries     = 3
+	defaultRetryBaseDelay = 100 * time.Millisecond
+)
+
+// RetryS3 runs op up to maxAttempts times while it fails with a transient error
+// (5xx response or network error). The delay doubles after every attempt,
+// starting at baseDelay, with random jitter. 4xx errors and context
+// cancellation are returned immediately.
+func RetryS3(ctx context.Context, maxAttempts int, baseDelay time.Duration, op func() error) error {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = op(); err == nil || attempt >= maxAttempts || !isTransient(err) {
+			return err
+		}
+
+		delay := baseDelay << (attempt - 1)
+		if delay > 0 {
+			// Full delay halved plus up to the other half at random
+			delay = delay/2 + rand.N(delay/2+1)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// isTransient reports whether an S3 error is worth retrying
+func isTransient(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var statusErr interface{ HTTPStatusCode() int }
+	if errors.As(err, &statusErr) {
+		return statusErr.HTTPStatusCode() >= 500
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
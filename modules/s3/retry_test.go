@@ -0,0 +1,156 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// statusError is an error carrying an HTTP status, like the SDK's response errors
+type statusError int
+
+func (e statusError) Error() string       { return fmt.Sprintf("status %d", int(e)) }
+func (e statusError) HTTPStatusCode() int { return int(e) }
+
+func TestRetryS3(t *testing.T) {
+	timeout := &net.OpError{Op: "read", Err: errors.New("i/o timeout")}
+	invalid := errors.New("invalid argument")
+
+	tests := []struct {
+		name        string
+		maxAttempts int
+		errs        []error // returned by the attempts in order, nil once exhausted
+		wantCalls   int
+		wantErr     error
+	}{
+		{"success", 3, nil, 1, nil},
+		{"503 twice", 3, []error{statusError(503), statusError(503)}, 3, nil},
+		{"503 every time", 3, []error{statusError(503), statusError(503), statusError(503), statusError(503)}, 3, statusError(503)},
+		{"500 then success", 3, []error{statusError(500)}, 2, nil},
+		{"404", 3, []error{statusError(404)}, 1, statusError(404)},
+		{"403 after 503", 3, []error{statusError(503), statusError(403)}, 2, statusError(403)},
+		{"network error", 3, []error{timeout}, 2, nil},
+		{"unexpected EOF", 3, []error{io.ErrUnexpectedEOF}, 2, nil},
+		{"canceled", 3, []error{context.Canceled}, 1, context.Canceled},
+		{"deadline", 3, []error{fmt.Errorf("get: %w", context.DeadlineExceeded)}, 1, context.DeadlineExceeded},
+		{"other error", 3, []error{invalid}, 1, invalid},
+		{"no attempts configured", 0, []error{statusError(503)}, 1, statusError(503)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := RetryS3(context.Background(), tt.maxAttempts, time.Millisecond, func() error {
+				calls++
+				if calls <= len(tt.errs) {
+					return tt.errs[calls-1]
+				}
+				return nil
+			})
+			if calls != tt.wantCalls {
+				t.Errorf("op called %d times, want %d", calls, tt.wantCalls)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("RetryS3 = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRetryS3StopsWhenCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	start := time.Now()
+	err := RetryS3(ctx, 5, time.Hour, func() error {
+		calls++
+		cancel()
+		return statusError(503)
+	})
+	if calls != 1 || !errors.Is(err, statusError(503)) {
+		t.Errorf("RetryS3 = %v after %d calls, want the last error after 1 call", err, calls)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("RetryS3 waited %v after cancellation", elapsed)
+	}
+}
+
+func TestRetryS3Backoff(t *testing.T) {
+	var times []time.Time
+	_ = RetryS3(context.Background(), 4, 20*time.Millisecond, func() error {
+		times = append(times, time.Now())
+		return statusError(503)
+	})
+	if len(times) != 4 {
+		t.Fatalf("op called %d times, want 4", len(times))
+	}
+	// Delays are the doubled base delay with jitter of up to half of it
+	for i := 1; i < len(times); i++ {
+		minDelay := (20 * time.Millisecond << (i - 1)) / 2
+		if gap := times[i].Sub(times[i-1]); gap < minDelay {
+			t.Errorf("delay before attempt %d = %v, want at least %v", i+1, gap, minDelay)
+		}
+	}
+}
+
+func TestDownloadRetriesTransientErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		statuses  []int
+		wantCalls int
+		wantErr   bool
+	}{
+		{"503 twice", []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable}, 3, false},
+		{"503 every time", []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable}, 3, true},
+		{"404", []int{http.StatusNotFound}, 1, true},
+		{"403", []int{http.StatusForbidden}, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeS3Server(t, "media")
+			server.put("media", "key", []byte("data"), time.Now())
+			server.failNext("GetObject", tt.statuses...)
+			storage := newTestS3(t, server, Config{MaxRetries: 3})
+
+			body, err := storage.Download(context.Background(), "", "key")
+			if n := server.count("GetObject"); n != tt.wantCalls {
+				t.Errorf("GetObject called %d times, want %d", n, tt.wantCalls)
+			}
+			if tt.wantErr {
+				if err == nil {
+					body.Close()
+					t.Fatal("Download succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Download: %v", err)
+			}
+			defer body.Close()
+			if data, _ := io.ReadAll(body); string(data) != "data" {
+				t.Errorf("Download = %q, want the object", data)
+			}
+		})
+	}
+}
+
+func TestUploadRetriesTransientErrors(t *testing.T) {
+	server := newFakeS3Server(t, "media")
+	server.failNext("PutObject", http.StatusServiceUnavailable, http.StatusInternalServerError)
+	storage := newTestS3(t, server, Config{MaxRetries: 3})
+
+	if _, err := storage.Upload(context.Background(), "", "key", strings.NewReader("data"), ""); err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	if n := server.count("PutObject"); n != 3 {
+		t.Errorf("PutObject called %d times, want 3", n)
+	}
+	// The body is sent again in full on every attempt
+	if object, ok := server.object("media", "key"); !ok || string(object.data) != "data" {
+		t.Errorf("stored object = %q, %v, want the whole body", object.data, ok)
+	}
+}
@@ -1,38 +1,177 @@
 package s3
 
 import (
+	"bytes"
 	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"io"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
 // S3 interface for dependency injection
 type S3 interface {
-	Upload(ctx context.Context, bucket, key string, body io.Reader) (string, error)
+	// Upload stores body under key. A non-empty contentType is stored as the object's Content-Type.
+	Upload(ctx context.Context, bucket, key string, body io.Reader, contentType string) (string, error)
+	// UploadWithProgress stores the size bytes of body like Upload, calling progress (may be nil)
+	// as they are stored. Large bodies are sent as multipart uploads where the backend supports them.
+	UploadWithProgress(ctx context.Context, bucket, key string, body io.ReaderAt, size int64, contentType string, progress ProgressFunc) (string, error)
+	// UploadStream is UploadWithProgress for a body of size bytes that can only be read once,
+	// e.g. data encrypted as it is read; a negative size reads body to its end. It holds
+	// a bounded number of parts in memory.
+	UploadStream(ctx context.Context, bucket, key string, body io.Reader, size int64, contentType string, progress ProgressFunc) (string, error)
 	Download(ctx context.Context, bucket, key string) (io.ReadCloser, error)
 	Delete(ctx context.Context, bucket, key string) error
+	// CopyObject copies an object server-side, keeping its server-side encryption
+	CopyObject(ctx context.Context, sourceBucket, sourceKey, destBucket, destKey string) error
+	// ObjectSize returns the stored size of an object in bytes without downloading it
+	ObjectSize(ctx context.Context, bucket, key string) (int64, error)
+	// GetObjectMetadata returns the Metadata* fields stored with an object without downloading it
+	GetObjectMetadata(ctx context.Context, bucket, key string) (map[string]string, error)
+	ListObjects(ctx context.Context, bucket, prefix string) ([]string, error)
+	ListObjectsChan(ctx context.Context, bucket, prefix string) (<-chan string, <-chan error)
+	EnsureBucket(ctx context.Context) error
+	// PresignGetURL returns a URL that downloads the object without credentials until ttl passes
+	PresignGetURL(ctx context.Context, bucket, key string, ttl time.Duration) (string, error)
+	// ListIncompleteUploads returns multipart uploads that were neither completed nor aborted
+	ListIncompleteUploads(ctx context.Context, bucket string) ([]IncompleteUpload, error)
+	// AbortMultipartUpload aborts a multipart upload and frees its stored parts
+	AbortMultipartUpload(ctx context.Context, bucket, key, uploadID string) error
+	// HealthCheck checks that the configured bucket is reachable
+	HealthCheck(ctx context.Context) error
+	// GetStorageUsage returns the total size and number of objects under prefix
+	GetStorageUsage(ctx context.Context, bucket, prefix string) (StorageUsage, error)
+	// GetStorageByDay returns the bytes of objects under prefix per day they were last modified in [from, to)
+	GetStorageByDay(ctx context.Context, bucket, prefix string, from, to time.Time) (map[string]int64, error)
+	// GetObjectLockConfig returns the retention of an object, zero when it has none
+	GetObjectLockConfig(ctx context.Context, bucket, key string) (ObjectLockConfig, error)
+	// ExtendObjectLock moves the compliance retention of an object to a later date
+	ExtendObjectLock(ctx context.Context, bucket, key string, newRetainUntil time.Time) error
 }
 
 type s3Impl struct {
-	client *s3.Client
-	bucket string
+	current        atomic.Pointer[s3.Client] // replaced by reconnect
+	cfg            Config
+	bucket         string
+	region         string
+	verifyMD5      bool
+	maxRetries     int
+	retryBaseDelay time.Duration
+	timeouts       OperationTimeouts
+
+	cloudFront *CloudFrontSigner // signs PresignGetURL URLs when a distribution is configured
 }
 
+// Storage backends selectable with Config.Backend
+const (
+	BackendS3    = "s3"
+	BackendAzure = "azure"
+)
+
 // Config holds S3 configuration
 type Config struct {
-	Region          string
-	Bucket          string
-	Endpoint        string // Optional, for local S3-compatible services
-	AccessKeyID     string
-	SecretAccessKey string
+	Backend          string // BackendS3 (default) or BackendAzure
+	Provider         string // ProviderAWS (default), ProviderR2, ProviderB2 or ProviderWasabi, fills Endpoint and Region
+	Region           string
+	Bucket           string
+	Endpoint         string // Optional, for local S3-compatible services or Azurite
+	R2AccountID      string // Cloudflare account of ProviderR2, used in its endpoint
+	AccessKeyID      string
+	SecretAccessKey  string
+	AutoCreateBucket bool          // create the bucket on startup if it does not exist
+	VerifyMD5        bool          // send Content-MD5 and check the returned ETag (enabled by default in main)
+	MaxRetries       int           // attempts of Upload and Download on transient errors (default 3)
+	RetryBaseDelay   time.Duration // delay before the first retry, doubled after each one (default 100ms)
+
+	Timeouts OperationTimeouts // per operation, unset ones use the Default*Timeout values
+
+	MultipartUploadTTL  time.Duration // incomplete multipart uploads older than this are aborted on startup (default 24h)
+	HealthCheckInterval time.Duration // how often the health monitor checks the bucket (default 30s)
+
+	Transfer TransferManagerConfig // multipart uploads of UploadWithProgress
+
+	CloudFront CloudFrontConfig // with a distribution domain PresignGetURL returns signed CloudFront URLs
+
+	// EnableObjectLock stores objects in compliance mode (WORM) for ObjectLockRetentionDays.
+	// The bucket needs object lock enabled, AutoCreateBucket creates it that way.
+	EnableObjectLock        bool
+	ObjectLockRetentionDays int // days an object cannot be deleted after it is stored
+
+	AzureAccountName   string // storage account of the azure backend
+	AzureAccountKey    string // shared key of the storage account
+	AzureContainerName string // container used where other backends use Bucket
 }
 
-// Init initializes the S3 module
+// Init initializes the S3 module with the configured backend
 func Init(ctx context.Context, cfg Config) (S3, error) {
+	switch cfg.Backend {
+	case "", BackendS3:
+	case BackendAzure:
+		return initAzure(ctx, cfg)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.Backend)
+	}
+
+	cfg, err := withProviderDefaults(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := newClient(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	maxRetries := cfg.MaxRetries
+	if maxRetries <= 0 {
+		maxRetries = defaultMaxRetries
+	}
+	retryBaseDelay := cfg.RetryBaseDelay
+	if retryBaseDelay <= 0 {
+		retryBaseDelay = defaultRetryBaseDelay
+	}
+
+	impl := &s3Impl{
+		cfg:            cfg,
+		bucket:         cfg.Bucket,
+		region:         cfg.Region,
+		verifyMD5:      cfg.VerifyMD5,
+		maxRetries:     maxRetries,
+		retryBaseDelay: retryBaseDelay,
+		timeouts:       cfg.Timeouts.withDefaults(),
+	}
+	impl.current.Store(client)
+
+	if cfg.CloudFront.DistributionDomain != "" {
+		impl.cloudFront, err = NewCloudFrontSigner(cfg.CloudFront)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.AutoCreateBucket {
+		if err := impl.EnsureBucket(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	return impl, nil
+}
+
+// newClient builds an S3 client, loading the default AWS config (env, shared files, instance roles)
+func newClient(ctx context.Context, cfg Config) (*s3.Client, error) {
 	opts := []func(*config.LoadOptions) error{
 		config.WithRegion(cfg.Region),
 	}
@@ -59,24 +198,53 @@ func Init(ctx context.Context, cfg Config) (S3, error) {
 		})
 	}
 
-	client := s3.NewFromConfig(awsCfg, clientOpts...)
+	return s3.NewFromConfig(awsCfg, clientOpts...), nil
+}
 
-	return &s3Impl{
-		client: client,
-		bucket: cfg.Bucket,
-	}, nil
+// client returns the current S3 client
+func (s *s3Impl) client() *s3.Client {
+	return s.current.Load()
 }
 
-func (s *s3Impl) Upload(ctx context.Context, bucket, key string, body io.Reader) (string, error) {
+func (s *s3Impl) Upload(ctx context.Context, bucket, key string, body io.Reader, contentType string) (string, error) {
 	bucketName := bucket
 	if bucketName == "" {
 		bucketName = s.bucket
 	}
 
-	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
-		Bucket: aws.String(bucketName),
-		Key:    aws.String(key),
-		Body:   body,
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.Upload)
+	defer cancel()
+
+	// S3 requires Content-MD5 on uploads with a retention
+	if s.verifyMD5 || s.cfg.EnableObjectLock {
+		return s.uploadVerified(ctx, bucketName, key, body, contentType)
+	}
+
+	// A retry has to resend the body from the start, streams that cannot seek are sent once
+	attempts := s.maxRetries
+	seeker, seekable := body.(io.Seeker)
+	if !seekable {
+		attempts = 1
+	}
+
+	first := true
+	err := RetryS3(ctx, attempts, s.retryBaseDelay, func() error {
+		if !first {
+			if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+		}
+		first = false
+
+		_, err := s.client().PutObject(ctx, &s3.PutObjectInput{
+			Bucket:                    aws.String(bucketName),
+			Key:                       aws.String(key),
+			Body:                      body,
+			ContentType:               optionalString(contentType),
+			ObjectLockMode:            s.objectLockMode(),
+			ObjectLockRetainUntilDate: s.retainUntilDate(),
+		})
+		return err
 	})
 	if err != nil {
 		return "", err
@@ -85,32 +253,298 @@ func (s *s3Impl) Upload(ctx context.Context, bucket, key string, body io.Reader)
 	return key, nil
 }
 
+// uploadVerified uploads with Content-MD5 and checks the returned ETag.
+// A corrupted object is deleted and ErrUploadCorrupted is returned.
+func (s *s3Impl) uploadVerified(ctx context.Context, bucketName, key string, body io.Reader, contentType string) (string, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return "", err
+	}
+	sum := md5.Sum(data)
+
+	var result *s3.PutObjectOutput
+	err = RetryS3(ctx, s.maxRetries, s.retryBaseDelay, func() error {
+		var putErr error
+		result, putErr = s.client().PutObject(ctx, &s3.PutObjectInput{
+			Bucket:                    aws.String(bucketName),
+			Key:                       aws.String(key),
+			Body:                      bytes.NewReader(data),
+			ContentMD5:                aws.String(base64.StdEncoding.EncodeToString(sum[:])),
+			ContentType:               optionalString(contentType),
+			ObjectLockMode:            s.objectLockMode(),
+			ObjectLockRetainUntilDate: s.retainUntilDate(),
+		})
+		return putErr
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if !etagMatches(aws.ToString(result.ETag), sum[:]) {
+		_, _ = s.client().DeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String(key),
+		})
+		return "", ErrUploadCorrupted
+	}
+
+	return key, nil
+}
+
+// etagMatches compares a single-part upload ETag with the expected MD5.
+// Multipart ETags ("<hash>-<parts>") are not plain MD5s and are accepted as is.
+func etagMatches(etag string, sum []byte) bool {
+	etag = strings.Trim(etag, `"`)
+	if etag == "" || strings.Contains(etag, "-") {
+		return true
+	}
+	return strings.EqualFold(etag, hex.EncodeToString(sum))
+}
+
+// optionalString returns nil for an empty value, so the field is left out of the request
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return aws.String(value)
+}
+
+// ErrUploadCorrupted is returned when the stored object does not match the uploaded bytes
+var ErrUploadCorrupted = errors.New("uploaded object checksum mismatch")
+
 func (s *s3Impl) Download(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
 	bucketName := bucket
 	if bucketName == "" {
 		bucketName = s.bucket
 	}
 
-	result, err := s.client.GetObject(ctx, &s3.GetObjectInput{
-		Bucket: aws.String(bucketName),
-		Key:    aws.String(key),
+	// The timeout also bounds reading the body, it is released when the body is closed
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.Download)
+	var result *s3.GetObjectOutput
+	err := RetryS3(ctx, s.maxRetries, s.retryBaseDelay, func() error {
+		var getErr error
+		result, getErr = s.client().GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String(key),
+		})
+		return getErr
 	})
 	if err != nil {
+		cancel()
 		return nil, err
 	}
 
-	return result.Body, nil
+	return cancelOnClose{ReadCloser: result.Body, cancel: cancel}, nil
 }
 
+// Delete removes an object. With object lock a delete would only hide a locked
+// object behind a delete marker, so its retention is checked first and
+// ErrObjectLocked returned while it is in force.
 func (s *s3Impl) Delete(ctx context.Context, bucket, key string) error {
 	bucketName := bucket
 	if bucketName == "" {
 		bucketName = s.bucket
 	}
 
-	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.Delete)
+	defer cancel()
+
+	if s.cfg.EnableObjectLock {
+		lock, err := s.GetObjectLockConfig(ctx, bucketName, key)
+		if err != nil {
+			return err
+		}
+		if lock.Locked(time.Now()) {
+			return fmt.Errorf("%w until %s", ErrObjectLocked, lock.RetainUntil.Format(time.RFC3339))
+		}
+	}
+
+	_, err := s.client().DeleteObject(ctx, &s3.DeleteObjectInput{
 		Bucket: aws.String(bucketName),
 		Key:    aws.String(key),
 	})
 	return err
 }
+
+func (s *s3Impl) CopyObject(ctx context.Context, sourceBucket, sourceKey, destBucket, destKey string) error {
+	if sourceBucket == "" {
+		sourceBucket = s.bucket
+	}
+	if destBucket == "" {
+		destBucket = s.bucket
+	}
+
+	// A copy is not encrypted like its source unless asked to, so carry the source settings over
+	var head *s3.HeadObjectOutput
+	err := RetryS3(ctx, s.maxRetries, s.retryBaseDelay, func() error {
+		var headErr error
+		head, headErr = s.client().HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(sourceBucket),
+			Key:    aws.String(sourceKey),
+		})
+		return headErr
+	})
+	if err != nil {
+		return err
+	}
+
+	input := &s3.CopyObjectInput{
+		Bucket:               aws.String(destBucket),
+		Key:                  aws.String(destKey),
+		CopySource:           aws.String(copySource(sourceBucket, sourceKey)),
+		ServerSideEncryption: head.ServerSideEncryption,
+		SSEKMSKeyId:          head.SSEKMSKeyId,
+		BucketKeyEnabled:     head.BucketKeyEnabled,
+
+		ObjectLockMode:            s.objectLockMode(),
+		ObjectLockRetainUntilDate: s.retainUntilDate(),
+	}
+	return RetryS3(ctx, s.maxRetries, s.retryBaseDelay, func() error {
+		_, copyErr := s.client().CopyObject(ctx, input)
+		return copyErr
+	})
+}
+
+// copySource builds the URL-encoded "bucket/key" CopyObject expects
+func copySource(bucket, key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return url.PathEscape(bucket) + "/" + strings.Join(segments, "/")
+}
+
+func (s *s3Impl) ObjectSize(ctx context.Context, bucket, key string) (int64, error) {
+	bucketName := bucket
+	if bucketName == "" {
+		bucketName = s.bucket
+	}
+
+	var result *s3.HeadObjectOutput
+	err := RetryS3(ctx, s.maxRetries, s.retryBaseDelay, func() error {
+		var headErr error
+		result, headErr = s.client().HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String(key),
+		})
+		return headErr
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return aws.ToInt64(result.ContentLength), nil
+}
+
+// PresignGetURL returns a presigned S3 URL, or a signed URL of the CloudFront
+// distribution serving the bucket when one is configured
+func (s *s3Impl) PresignGetURL(ctx context.Context, bucket, key string, ttl time.Duration) (string, error) {
+	if s.cloudFront != nil {
+		return s.cloudFront.Sign(s.cloudFront.ObjectURL(key), time.Now().Add(ttl))
+	}
+
+	bucketName := bucket
+	if bucketName == "" {
+		bucketName = s.bucket
+	}
+
+	req, err := s3.NewPresignClient(s.client()).PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+	}, s3.WithPresignExpires(ttl))
+	if err != nil {
+		return "", err
+	}
+	return req.URL, nil
+}
+
+// ListObjects returns all keys under prefix, following ListObjectsV2 pages
+func (s *s3Impl) ListObjects(ctx context.Context, bucket, prefix string) ([]string, error) {
+	var keys []string
+	err := s.listObjectPages(ctx, bucket, prefix, func(page []string) error {
+		keys = append(keys, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// ListObjectsChan emits keys under prefix as pages arrive. The key channel is closed
+// when listing ends; the error channel then receives at most one error and is closed.
+func (s *s3Impl) ListObjectsChan(ctx context.Context, bucket, prefix string) (<-chan string, <-chan error) {
+	return listPagesChan(ctx, func(fn func(page []string) error) error {
+		return s.listObjectPages(ctx, bucket, prefix, fn)
+	})
+}
+
+// listPagesChan runs listPages in a goroutine and emits the keys of every page it passes to fn
+func listPagesChan(ctx context.Context, listPages func(fn func(page []string) error) error) (<-chan string, <-chan error) {
+	keys := make(chan string, listPageSize)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(errs)
+		defer close(keys)
+
+		err := listPages(func(page []string) error {
+			for _, key := range page {
+				select {
+				case keys <- key:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			errs <- err
+		}
+	}()
+
+	return keys, errs
+}
+
+// listPageSize is the maximum number of keys ListObjectsV2 returns per call
+const listPageSize = 1000
+
+// listObjectPages calls fn with the keys of each ListObjectsV2 page until the listing is complete
+func (s *s3Impl) listObjectPages(ctx context.Context, bucket, prefix string, fn func(page []string) error) error {
+	return s.listObjectInfoPages(ctx, bucket, prefix, func(objects []types.Object) error {
+		page := make([]string, 0, len(objects))
+		for _, object := range objects {
+			page = append(page, aws.ToString(object.Key))
+		}
+		return fn(page)
+	})
+}
+
+// listObjectInfoPages calls fn with the objects of each ListObjectsV2 page until the listing is complete
+func (s *s3Impl) listObjectInfoPages(ctx context.Context, bucket, prefix string, fn func(objects []types.Object) error) error {
+	bucketName := bucket
+	if bucketName == "" {
+		bucketName = s.bucket
+	}
+
+	var continuationToken *string
+	for {
+		result, err := s.client().ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+			Bucket:            aws.String(bucketName),
+			Prefix:            aws.String(prefix),
+			ContinuationToken: continuationToken,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := fn(result.Contents); err != nil {
+			return err
+		}
+
+		if !aws.ToBool(result.IsTruncated) || result.NextContinuationToken == nil {
+			return nil
+		}
+		continuationToken = result.NextContinuationToken
+	}
+}
@@ -0,0 +1,265 @@
+package s3
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEtagMatches(t *testing.T) {
+	sum := md5.Sum([]byte("data"))
+	hexSum := hex.EncodeToString(sum[:])
+
+	tests := []struct {
+		name string
+		etag string
+		want bool
+	}{
+		{"quoted", `"` + hexSum + `"`, true},
+		{"unquoted", hexSum, true},
+		{"uppercase", strings.ToUpper(hexSum), true},
+		{"other content", `"` + strings.Repeat("0", 32) + `"`, false},
+		{"truncated", hexSum[:30], false},
+		{"multipart", `"` + hexSum + `-3"`, true},
+		{"missing", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := etagMatches(tt.etag, sum[:]); got != tt.want {
+				t.Errorf("etagMatches(%q) = %v, want %v", tt.etag, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUploadVerifiedSendsContentMD5(t *testing.T) {
+	server := newFakeS3Server(t, "media")
+	storage := newTestS3(t, server, Config{VerifyMD5: true})
+
+	if _, err := storage.Upload(context.Background(), "", "key", strings.NewReader("data"), "text/plain"); err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+
+	sum := md5.Sum([]byte("data"))
+	if got := server.last("PutObject").header.Get("Content-MD5"); got != base64.StdEncoding.EncodeToString(sum[:]) {
+		t.Errorf("Content-MD5 = %q, want the MD5 of the body", got)
+	}
+	if object, ok := server.object("media", "key"); !ok || string(object.data) != "data" || object.contentType != "text/plain" {
+		t.Errorf("stored object = %+v, %v", object, ok)
+	}
+}
+
+func TestUploadWithoutVerification(t *testing.T) {
+	server := newFakeS3Server(t, "media")
+	storage := newTestS3(t, server, Config{})
+
+	if _, err := storage.Upload(context.Background(), "", "key", strings.NewReader("data"), ""); err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	if got := server.last("PutObject").header.Get("Content-MD5"); got != "" {
+		t.Errorf("Content-MD5 = %q sent without VerifyMD5", got)
+	}
+}
+
+func TestUploadVerifiedDetectsCorruption(t *testing.T) {
+	server := newFakeS3Server(t, "media")
+	server.corruptETag = true
+	storage := newTestS3(t, server, Config{VerifyMD5: true})
+
+	_, err := storage.Upload(context.Background(), "", "key", strings.NewReader("data"), "")
+	if !errors.Is(err, ErrUploadCorrupted) {
+		t.Fatalf("Upload error = %v, want ErrUploadCorrupted", err)
+	}
+	if n := server.count("DeleteObject"); n != 1 {
+		t.Errorf("DeleteObject called %d times, want 1", n)
+	}
+	if _, ok := server.object("media", "key"); ok {
+		t.Error("corrupted object was kept")
+	}
+}
+
+func TestUploadVerifiedBadDigestIsNotRetried(t *testing.T) {
+	server := newFakeS3Server(t, "media")
+	server.failNext("PutObject", http.StatusBadRequest)
+	storage := newTestS3(t, server, Config{VerifyMD5: true})
+
+	if _, err := storage.Upload(context.Background(), "", "key", strings.NewReader("data"), ""); err == nil {
+		t.Fatal("Upload succeeded, want the rejected digest")
+	}
+	if n := server.count("PutObject"); n != 1 {
+		t.Errorf("PutObject called %d times, want 1: client errors are not retried", n)
+	}
+}
+
+func TestPresignGetURL(t *testing.T) {
+	server := newFakeS3Server(t, "media")
+	server.put("media", "media/key", []byte("encrypted blob"), time.Now())
+	storage := newTestS3(t, server, Config{})
+
+	presigned, err := storage.PresignGetURL(context.Background(), "", "media/key", time.Minute)
+	if err != nil {
+		t.Fatalf("PresignGetURL: %v", err)
+	}
+	u, err := url.Parse(presigned)
+	if err != nil {
+		t.Fatalf("presigned URL %q: %v", presigned, err)
+	}
+	if !strings.HasPrefix(presigned, server.URL+"/media/media/key?") {
+		t.Errorf("presigned URL %q does not name the object", presigned)
+	}
+	query := u.Query()
+	if got := query.Get("X-Amz-Expires"); got != "60" {
+		t.Errorf("X-Amz-Expires = %q, want 60", got)
+	}
+	for _, param := range []string{"X-Amz-Signature", "X-Amz-Credential", "X-Amz-Date"} {
+		if query.Get(param) == "" {
+			t.Errorf("presigned URL lacks %s", param)
+		}
+	}
+
+	// The URL works without credentials
+	resp, err := http.Get(presigned)
+	if err != nil {
+		t.Fatalf("GET presigned URL: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "encrypted blob" {
+		t.Errorf("GET presigned URL = %d %q, want the object", resp.StatusCode, body)
+	}
+}
+
+func TestPresignGetURLOtherBucket(t *testing.T) {
+	server := newFakeS3Server(t, "media", "archive")
+	storage := newTestS3(t, server, Config{})
+
+	presigned, err := storage.PresignGetURL(context.Background(), "archive", "key", 5*time.Minute)
+	if err != nil {
+		t.Fatalf("PresignGetURL: %v", err)
+	}
+	if !strings.HasPrefix(presigned, server.URL+"/archive/key?") || !strings.Contains(presigned, "X-Amz-Expires=300") {
+		t.Errorf("presigned URL = %q, want the archive object for 300 seconds", presigned)
+	}
+}
+
+func TestObjectSize(t *testing.T) {
+	server := newFakeS3Server(t, "media")
+	server.put("media", "key", []byte("twelve bytes"), time.Now())
+	storage := newTestS3(t, server, Config{})
+	ctx := context.Background()
+
+	size, err := storage.ObjectSize(ctx, "", "key")
+	if err != nil || size != 12 {
+		t.Errorf("ObjectSize = %d, %v, want 12", size, err)
+	}
+	if server.count("HeadObject") != 1 || server.count("GetObject") != 0 {
+		t.Errorf("ObjectSize sent %d HEAD and %d GET requests, want a single HEAD", server.count("HeadObject"), server.count("GetObject"))
+	}
+
+	if _, err := storage.ObjectSize(ctx, "", "missing"); err == nil {
+		t.Error("ObjectSize of a missing object succeeded")
+	}
+
+	server.failNext("HeadObject", http.StatusServiceUnavailable)
+	if size, err := storage.ObjectSize(ctx, "", "key"); err != nil || size != 12 {
+		t.Errorf("ObjectSize after a 503 = %d, %v, want a retry", size, err)
+	}
+}
+
+func TestCopySource(t *testing.T) {
+	tests := []struct {
+		bucket, key string
+		want        string
+	}{
+		{"media", "media/key", "media/media/key"},
+		{"media", "photo 1.png", "media/photo%201.png"},
+		{"media", "a+b/c?d", "media/a+b/c%3Fd"},
+		{"media", "отчет", "media/%D0%BE%D1%82%D1%87%D0%B5%D1%82"},
+	}
+	for _, tt := range tests {
+		if got := copySource(tt.bucket, tt.key); got != tt.want {
+			t.Errorf("copySource(%q, %q) = %q, want %q", tt.bucket, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestCopyObject(t *testing.T) {
+	tests := []struct {
+		name                  string
+		sourceKey, destBucket string
+		sse, kmsKeyID         string
+	}{
+		{"same bucket", "media/key", "", "", ""},
+		{"other bucket", "media/key", "archive", "", ""},
+		{"escaped key", "media/photo 1?.png", "", "", ""},
+		{"SSE-S3", "media/key", "", "AES256", ""},
+		{"SSE-KMS", "media/key", "archive", "aws:kms", "arn:aws:kms:us-east-1:123456789012:key/test"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeS3Server(t, "media", "archive")
+			server.put("media", tt.sourceKey, []byte("encrypted blob"), time.Now())
+			server.mu.Lock()
+			source := server.objects["media/"+tt.sourceKey]
+			source.sse, source.kmsKeyID = tt.sse, tt.kmsKeyID
+			server.objects["media/"+tt.sourceKey] = source
+			server.mu.Unlock()
+			storage := newTestS3(t, server, Config{})
+
+			if err := storage.CopyObject(context.Background(), "", tt.sourceKey, tt.destBucket, "copy"); err != nil {
+				t.Fatalf("CopyObject: %v", err)
+			}
+			destBucket := tt.destBucket
+			if destBucket == "" {
+				destBucket = "media"
+			}
+			copied, ok := server.object(destBucket, "copy")
+			if !ok || string(copied.data) != "encrypted blob" {
+				t.Fatalf("copy = %q, %v, want the source data", copied.data, ok)
+			}
+			if copied.sse != tt.sse || copied.kmsKeyID != tt.kmsKeyID {
+				t.Errorf("copy encryption = %q, %q, want the source's %q, %q", copied.sse, copied.kmsKeyID, tt.sse, tt.kmsKeyID)
+			}
+			if _, ok := server.object("media", tt.sourceKey); !ok {
+				t.Error("source object removed by the copy")
+			}
+			if n := server.count("GetObject") + server.count("PutObject"); n != 0 {
+				t.Errorf("copy sent %d GET and PUT requests, want a server-side copy", n)
+			}
+		})
+	}
+}
+
+func TestCopyObjectErrors(t *testing.T) {
+	t.Run("missing source", func(t *testing.T) {
+		server := newFakeS3Server(t, "media")
+		storage := newTestS3(t, server, Config{})
+		if err := storage.CopyObject(context.Background(), "", "missing", "", "copy"); err == nil {
+			t.Fatal("CopyObject of a missing object succeeded")
+		}
+		if n := server.count("CopyObject"); n != 0 {
+			t.Errorf("CopyObject sent %d copy requests for a missing source", n)
+		}
+	})
+
+	t.Run("transient copy error", func(t *testing.T) {
+		server := newFakeS3Server(t, "media")
+		server.put("media", "key", []byte("data"), time.Now())
+		server.failNext("CopyObject", http.StatusServiceUnavailable)
+		storage := newTestS3(t, server, Config{})
+		if err := storage.CopyObject(context.Background(), "", "key", "", "copy"); err != nil {
+			t.Fatalf("CopyObject after a 503: %v", err)
+		}
+		if n := server.count("CopyObject"); n != 2 {
+			t.Errorf("CopyObject sent %d copy requests, want 2", n)
+		}
+	})
+}
@@ -0,0 +1,543 @@
+package s3
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// fakeS3Server is a minimal path-style S3 endpoint, so s3Impl can be tested
+// over real HTTP with the SDK's request and response handling
+type fakeS3Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	buckets   map[string]bool
+	objects   map[string]fakeObject // "bucket/key"
+	lifecycle map[string]string     // lifecycle configuration XML per bucket
+	uploads   map[string]*fakeMultipartUpload
+	nextID    int
+	requests  []string               // operation names in order
+	received  map[string]fakeRequest // last request of each operation
+	failures  map[string][]int       // statuses returned by the next requests of an operation
+	delays    map[string]time.Duration
+
+	pageSize    int  // ListObjectsV2 page size, listPageSize when 0
+	corruptETag bool // answer PutObject with an ETag that does not match the body
+}
+
+type fakeRequest struct {
+	header http.Header
+	body   string
+}
+
+type fakeObject struct {
+	data        []byte
+	contentType string
+	metadata    map[string]string
+	modified    time.Time
+	sse         string // server-side encryption algorithm, empty when unencrypted
+	kmsKeyID    string
+	lockMode    string // object lock retention mode, empty without a retention
+	retainUntil time.Time
+}
+
+type fakeMultipartUpload struct {
+	bucket, key string
+	contentType string
+	lockMode    string
+	retainUntil time.Time
+	initiated   time.Time
+	parts       map[int][]byte
+}
+
+func newFakeS3Server(t *testing.T, buckets ...string) *fakeS3Server {
+	t.Helper()
+	f := &fakeS3Server{
+		buckets:   make(map[string]bool),
+		objects:   make(map[string]fakeObject),
+		lifecycle: make(map[string]string),
+		uploads:   make(map[string]*fakeMultipartUpload),
+		received:  make(map[string]fakeRequest),
+		failures:  make(map[string][]int),
+		delays:    make(map[string]time.Duration),
+	}
+	for _, bucket := range buckets {
+		f.buckets[bucket] = true
+	}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.Close)
+	return f
+}
+
+// newTestS3 returns an s3Impl for bucket on the fake server. SDK retries are off,
+// so every attempt of RetryS3 is one request.
+func newTestS3(t *testing.T, f *fakeS3Server, cfg Config) *s3Impl {
+	t.Helper()
+	if cfg.Bucket == "" {
+		cfg.Bucket = "media"
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	cfg.Endpoint = f.URL
+	if cfg.MaxRetries <= 0 {
+		cfg.MaxRetries = defaultMaxRetries
+	}
+	if cfg.RetryBaseDelay <= 0 {
+		cfg.RetryBaseDelay = time.Millisecond
+	}
+
+	client := s3.New(s3.Options{
+		Region:           cfg.Region,
+		BaseEndpoint:     aws.String(f.URL),
+		UsePathStyle:     true,
+		Credentials:      credentials.NewStaticCredentialsProvider("test", "test", ""),
+		RetryMaxAttempts: 1,
+	})
+	impl := &s3Impl{
+		cfg:            cfg,
+		bucket:         cfg.Bucket,
+		region:         cfg.Region,
+		verifyMD5:      cfg.VerifyMD5,
+		maxRetries:     cfg.MaxRetries,
+		retryBaseDelay: cfg.RetryBaseDelay,
+		timeouts:       cfg.Timeouts.withDefaults(),
+	}
+	impl.current.Store(client)
+	return impl
+}
+
+// failNext makes the next requests of op fail with statuses, in order
+func (f *fakeS3Server) failNext(op string, statuses ...int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures[op] = append(f.failures[op], statuses...)
+}
+
+// delay holds every request of op for d before answering it
+func (f *fakeS3Server) delay(op string, d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.delays[op] = d
+}
+
+// count returns how many requests of op were received
+func (f *fakeS3Server) count(op string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, request := range f.requests {
+		if request == op {
+			n++
+		}
+	}
+	return n
+}
+
+// last returns the last request of op
+func (f *fakeS3Server) last(op string) fakeRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.received[op]
+}
+
+// put stores an object directly
+func (f *fakeS3Server) put(bucket, key string, data []byte, modified time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.buckets[bucket] = true
+	f.objects[bucket+"/"+key] = fakeObject{data: data, modified: modified}
+}
+
+// startUpload records an incomplete multipart upload initiated at the given time and returns its ID
+func (f *fakeS3Server) startUpload(bucket, key string, initiated time.Time) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nextID++
+	id := "upload-" + strconv.Itoa(f.nextID)
+	f.uploads[id] = &fakeMultipartUpload{bucket: bucket, key: key, initiated: initiated, parts: make(map[int][]byte)}
+	return id
+}
+
+// object returns a stored object
+func (f *fakeS3Server) object(bucket, key string) (fakeObject, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	object, ok := f.objects[bucket+"/"+key]
+	return object, ok
+}
+
+func (f *fakeS3Server) serve(w http.ResponseWriter, r *http.Request) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	query := r.URL.Query()
+	op := fakeOperation(r.Method, key, query, r.Header)
+
+	f.mu.Lock()
+	f.requests = append(f.requests, op)
+	status := 0
+	if queue := f.failures[op]; len(queue) > 0 {
+		status, f.failures[op] = queue[0], queue[1:]
+	}
+	delay := f.delays[op]
+	f.mu.Unlock()
+
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if status != 0 {
+		writeS3Error(w, r, status, "InjectedFailure")
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeS3Error(w, r, http.StatusBadRequest, "IncompleteBody")
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.received[op] = fakeRequest{header: r.Header.Clone(), body: string(body)}
+	if op != "CreateBucket" && !f.buckets[bucket] {
+		writeS3Error(w, r, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+
+	switch op {
+	case "HeadBucket":
+		w.WriteHeader(http.StatusOK)
+	case "CreateBucket":
+		if f.buckets[bucket] {
+			writeS3Error(w, r, http.StatusConflict, "BucketAlreadyOwnedByYou")
+			return
+		}
+		f.buckets[bucket] = true
+		w.WriteHeader(http.StatusOK)
+	case "PutBucketLifecycleConfiguration":
+		f.lifecycle[bucket] = string(body)
+		w.WriteHeader(http.StatusOK)
+	case "ListObjectsV2":
+		f.listObjects(w, bucket, query)
+	case "ListMultipartUploads":
+		f.listUploads(w, bucket, query)
+	case "PutObject":
+		if md5Header := r.Header.Get("Content-MD5"); md5Header != "" && !contentMD5Matches(md5Header, body) {
+			writeS3Error(w, r, http.StatusBadRequest, "BadDigest")
+			return
+		}
+		lockMode, retainUntil := objectLock(r.Header)
+		f.objects[bucket+"/"+key] = fakeObject{data: body, contentType: r.Header.Get("Content-Type"), metadata: amzMetadata(r.Header), modified: time.Now(),
+			lockMode: lockMode, retainUntil: retainUntil}
+		sum := md5.Sum(body)
+		if f.corruptETag {
+			sum[0] ^= 0xff
+		}
+		w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+		w.WriteHeader(http.StatusOK)
+	case "CopyObject":
+		source, _ := url.PathUnescape(strings.TrimPrefix(r.Header.Get("X-Amz-Copy-Source"), "/"))
+		object, ok := f.objects[source]
+		if !ok {
+			writeS3Error(w, r, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		object.modified = time.Now()
+		// Like S3, the copy is encrypted as the request asks, not like its source
+		object.sse = r.Header.Get("X-Amz-Server-Side-Encryption")
+		object.kmsKeyID = r.Header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id")
+		object.lockMode, object.retainUntil = objectLock(r.Header)
+		f.objects[bucket+"/"+key] = object
+		writeXML(w, fmt.Sprintf(`<CopyObjectResult><ETag>"%x"</ETag><LastModified>%s</LastModified></CopyObjectResult>`,
+			md5.Sum(object.data), object.modified.UTC().Format(time.RFC3339)))
+	case "GetObject", "HeadObject":
+		object, ok := f.objects[bucket+"/"+key]
+		if !ok {
+			writeS3Error(w, r, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		header := w.Header()
+		header.Set("Content-Length", strconv.Itoa(len(object.data)))
+		header.Set("ETag", fmt.Sprintf(`"%x"`, md5.Sum(object.data)))
+		header.Set("Last-Modified", object.modified.UTC().Format(http.TimeFormat))
+		if object.contentType != "" {
+			header.Set("Content-Type", object.contentType)
+		}
+		for name, value := range object.metadata {
+			header.Set("X-Amz-Meta-"+name, value)
+		}
+		if object.sse != "" {
+			header.Set("X-Amz-Server-Side-Encryption", object.sse)
+		}
+		if object.kmsKeyID != "" {
+			header.Set("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", object.kmsKeyID)
+		}
+		w.WriteHeader(http.StatusOK)
+		if op == "GetObject" {
+			_, _ = w.Write(object.data)
+		}
+	case "GetObjectRetention":
+		object, ok := f.objects[bucket+"/"+key]
+		if !ok {
+			writeS3Error(w, r, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		if object.lockMode == "" {
+			writeS3Error(w, r, http.StatusNotFound, "NoSuchObjectLockConfiguration")
+			return
+		}
+		writeXML(w, fmt.Sprintf(`<Retention><Mode>%s</Mode><RetainUntilDate>%s</RetainUntilDate></Retention>`,
+			object.lockMode, object.retainUntil.UTC().Format(time.RFC3339Nano)))
+	case "PutObjectRetention":
+		object, ok := f.objects[bucket+"/"+key]
+		if !ok {
+			writeS3Error(w, r, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		var retention struct {
+			Mode            string
+			RetainUntilDate time.Time
+		}
+		if err := xml.Unmarshal(body, &retention); err != nil {
+			writeS3Error(w, r, http.StatusBadRequest, "MalformedXML")
+			return
+		}
+		object.lockMode, object.retainUntil = retention.Mode, retention.RetainUntilDate
+		f.objects[bucket+"/"+key] = object
+		w.WriteHeader(http.StatusOK)
+	case "DeleteObject":
+		delete(f.objects, bucket+"/"+key)
+		w.WriteHeader(http.StatusNoContent)
+	case "CreateMultipartUpload":
+		f.nextID++
+		id := "upload-" + strconv.Itoa(f.nextID)
+		lockMode, retainUntil := objectLock(r.Header)
+		f.uploads[id] = &fakeMultipartUpload{bucket: bucket, key: key, contentType: r.Header.Get("Content-Type"),
+			lockMode: lockMode, retainUntil: retainUntil, initiated: time.Now(), parts: make(map[int][]byte)}
+		writeXML(w, fmt.Sprintf(`<InitiateMultipartUploadResult><Bucket>%s</Bucket><Key>%s</Key><UploadId>%s</UploadId></InitiateMultipartUploadResult>`, bucket, key, id))
+	case "UploadPart":
+		upload, ok := f.uploads[query.Get("uploadId")]
+		if !ok {
+			writeS3Error(w, r, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		number, _ := strconv.Atoi(query.Get("partNumber"))
+		upload.parts[number] = body
+		w.Header().Set("ETag", fmt.Sprintf(`"%x"`, md5.Sum(body)))
+		w.WriteHeader(http.StatusOK)
+	case "CompleteMultipartUpload":
+		id := query.Get("uploadId")
+		upload, ok := f.uploads[id]
+		if !ok {
+			writeS3Error(w, r, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		numbers := make([]int, 0, len(upload.parts))
+		for number := range upload.parts {
+			numbers = append(numbers, number)
+		}
+		sort.Ints(numbers)
+		var data bytes.Buffer
+		for _, number := range numbers {
+			data.Write(upload.parts[number])
+		}
+		delete(f.uploads, id)
+		f.objects[bucket+"/"+key] = fakeObject{data: data.Bytes(), contentType: upload.contentType, modified: time.Now(),
+			lockMode: upload.lockMode, retainUntil: upload.retainUntil}
+		writeXML(w, fmt.Sprintf(`<CompleteMultipartUploadResult><Bucket>%s</Bucket><Key>%s</Key><ETag>"%x-%d"</ETag></CompleteMultipartUploadResult>`,
+			bucket, key, md5.Sum(data.Bytes()), len(numbers)))
+	case "AbortMultipartUpload":
+		delete(f.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeS3Error(w, r, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+// objectLock returns the object lock retention requested by an upload or copy
+func objectLock(header http.Header) (mode string, retainUntil time.Time) {
+	retainUntil, _ = time.Parse(time.RFC3339Nano, header.Get("X-Amz-Object-Lock-Retain-Until-Date"))
+	return header.Get("X-Amz-Object-Lock-Mode"), retainUntil
+}
+
+// fakeOperation names the S3 operation of a request
+func fakeOperation(method, key string, query url.Values, header http.Header) string {
+	_, uploadID := query["uploadId"]
+	switch {
+	case key == "" && method == http.MethodHead:
+		return "HeadBucket"
+	case key == "" && method == http.MethodPut && query.Has("lifecycle"):
+		return "PutBucketLifecycleConfiguration"
+	case key == "" && method == http.MethodPut:
+		return "CreateBucket"
+	case key == "" && method == http.MethodGet && query.Has("uploads"):
+		return "ListMultipartUploads"
+	case key == "" && method == http.MethodGet:
+		return "ListObjectsV2"
+	case method == http.MethodPost && query.Has("uploads"):
+		return "CreateMultipartUpload"
+	case method == http.MethodPut && uploadID:
+		return "UploadPart"
+	case method == http.MethodPost && uploadID:
+		return "CompleteMultipartUpload"
+	case method == http.MethodDelete && uploadID:
+		return "AbortMultipartUpload"
+	case method == http.MethodGet && query.Has("retention"):
+		return "GetObjectRetention"
+	case method == http.MethodPut && query.Has("retention"):
+		return "PutObjectRetention"
+	case method == http.MethodPut && header.Get("X-Amz-Copy-Source") != "":
+		return "CopyObject"
+	case method == http.MethodPut:
+		return "PutObject"
+	case method == http.MethodGet:
+		return "GetObject"
+	case method == http.MethodHead:
+		return "HeadObject"
+	case method == http.MethodDelete:
+		return "DeleteObject"
+	}
+	return method
+}
+
+func (f *fakeS3Server) listObjects(w http.ResponseWriter, bucket string, query url.Values) {
+	prefix := query.Get("prefix")
+	after := query.Get("continuation-token")
+	pageSize := f.pageSize
+	if pageSize <= 0 {
+		pageSize = listPageSize
+	}
+
+	var keys []string
+	for name := range f.objects {
+		objectBucket, key, _ := strings.Cut(name, "/")
+		if objectBucket == bucket && strings.HasPrefix(key, prefix) && key > after {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	truncated := len(keys) > pageSize
+	if truncated {
+		keys = keys[:pageSize]
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, `<ListBucketResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Name>%s</Name><Prefix>%s</Prefix><KeyCount>%d</KeyCount><MaxKeys>%d</MaxKeys><IsTruncated>%t</IsTruncated>`,
+		bucket, prefix, len(keys), pageSize, truncated)
+	if truncated {
+		fmt.Fprintf(&b, `<NextContinuationToken>%s</NextContinuationToken>`, xmlEscape(keys[len(keys)-1]))
+	}
+	for _, key := range keys {
+		object := f.objects[bucket+"/"+key]
+		fmt.Fprintf(&b, `<Contents><Key>%s</Key><LastModified>%s</LastModified><ETag>"%x"</ETag><Size>%d</Size><StorageClass>STANDARD</StorageClass></Contents>`,
+			xmlEscape(key), object.modified.UTC().Format(time.RFC3339), md5.Sum(object.data), len(object.data))
+	}
+	b.WriteString(`</ListBucketResult>`)
+	writeXML(w, b.String())
+}
+
+func (f *fakeS3Server) listUploads(w http.ResponseWriter, bucket string, query url.Values) {
+	ids := make([]string, 0, len(f.uploads))
+	for id, upload := range f.uploads {
+		if upload.bucket == bucket {
+			ids = append(ids, id)
+		}
+	}
+	// S3 lists uploads by key, then by upload ID, and continues after both markers
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := f.uploads[ids[i]], f.uploads[ids[j]]
+		if a.key != b.key {
+			return a.key < b.key
+		}
+		return ids[i] < ids[j]
+	})
+	keyMarker, idMarker := query.Get("key-marker"), query.Get("upload-id-marker")
+	if keyMarker != "" {
+		start := sort.Search(len(ids), func(i int) bool {
+			upload := f.uploads[ids[i]]
+			return upload.key > keyMarker || (upload.key == keyMarker && ids[i] > idMarker)
+		})
+		ids = ids[start:]
+	}
+	pageSize := f.pageSize
+	if pageSize <= 0 {
+		pageSize = listPageSize
+	}
+	truncated := len(ids) > pageSize
+	if truncated {
+		ids = ids[:pageSize]
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<ListMultipartUploadsResult><Bucket>%s</Bucket><IsTruncated>%t</IsTruncated>`, bucket, truncated)
+	if truncated {
+		last := ids[len(ids)-1]
+		fmt.Fprintf(&b, `<NextKeyMarker>%s</NextKeyMarker><NextUploadIdMarker>%s</NextUploadIdMarker>`, xmlEscape(f.uploads[last].key), last)
+	}
+	for _, id := range ids {
+		upload := f.uploads[id]
+		fmt.Fprintf(&b, `<Upload><Key>%s</Key><UploadId>%s</UploadId><Initiated>%s</Initiated></Upload>`,
+			xmlEscape(upload.key), id, upload.initiated.UTC().Format(time.RFC3339))
+	}
+	b.WriteString(`</ListMultipartUploadsResult>`)
+	writeXML(w, b.String())
+}
+
+func writeXML(w http.ResponseWriter, body string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	_, _ = io.WriteString(w, xml.Header+body)
+}
+
+// writeS3Error answers with an S3 error document, HEAD responses carry the status only
+func writeS3Error(w http.ResponseWriter, r *http.Request, status int, code string) {
+	if r.Method == http.MethodHead {
+		w.WriteHeader(status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_, _ = fmt.Fprintf(w, `%s<Error><Code>%s</Code><Message>%s</Message></Error>`, xml.Header, code, code)
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+func amzMetadata(header http.Header) map[string]string {
+	metadata := make(map[string]string)
+	for name, values := range header {
+		if key, ok := strings.CutPrefix(strings.ToLower(name), "x-amz-meta-"); ok && len(values) > 0 {
+			metadata[key] = values[0]
+		}
+	}
+	return metadata
+}
+
+func contentMD5Matches(header string, body []byte) bool {
+	sum := md5.Sum(body)
+	return header == base64.StdEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,49 @@
+package s3
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// Defaults of OperationTimeouts
+const (
+	DefaultUploadTimeout   = 5 * time.Minute
+	DefaultDownloadTimeout = 2 * time.Minute
+	DefaultDeleteTimeout   = 10 * time.Second
+)
+
+// OperationTimeouts bound single storage operations including their retries,
+// independent of the HTTP server timeouts: large uploads legitimately take longer
+// than downloads
+type OperationTimeouts struct {
+	Upload   time.Duration // Upload and UploadWithProgress (default 5m)
+	Download time.Duration // Download, until its body is closed (default 2m)
+	Delete   time.Duration // Delete (default 10s)
+}
+
+// withDefaults fills unset timeouts with the Default*Timeout values
+func (t OperationTimeouts) withDefaults() OperationTimeouts {
+	if t.Upload <= 0 {
+		t.Upload = DefaultUploadTimeout
+	}
+	if t.Download <= 0 {
+		t.Download = DefaultDownloadTimeout
+	}
+	if t.Delete <= 0 {
+		t.Delete = DefaultDeleteTimeout
+	}
+	return t
+}
+
+// cancelOnClose releases the timeout of a download once its body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,113 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestOperationTimeoutsWithDefaults(t *testing.T) {
+	tests := []struct {
+		name string
+		in   OperationTimeouts
+		want OperationTimeouts
+	}{
+		{"unset", OperationTimeouts{}, OperationTimeouts{DefaultUploadTimeout, DefaultDownloadTimeout, DefaultDeleteTimeout}},
+		{"negative", OperationTimeouts{Upload: -time.Second}, OperationTimeouts{DefaultUploadTimeout, DefaultDownloadTimeout, DefaultDeleteTimeout}},
+		{"set", OperationTimeouts{time.Hour, time.Minute, time.Second}, OperationTimeouts{time.Hour, time.Minute, time.Second}},
+		{"partly set", OperationTimeouts{Delete: time.Second}, OperationTimeouts{DefaultUploadTimeout, DefaultDownloadTimeout, time.Second}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.in.withDefaults(); got != tt.want {
+				t.Errorf("withDefaults = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOperationTimeouts(t *testing.T) {
+	const timeout = 50 * time.Millisecond
+
+	tests := []struct {
+		name     string
+		op       string // fake server operation that is delayed
+		timeouts OperationTimeouts
+		call     func(ctx context.Context, storage *s3Impl) error
+	}{
+		{"upload", "PutObject", OperationTimeouts{Upload: timeout}, func(ctx context.Context, storage *s3Impl) error {
+			_, err := storage.Upload(ctx, "", "media/a", bytes.NewReader([]byte("data")), "text/plain")
+			return err
+		}},
+		{"upload with progress", "PutObject", OperationTimeouts{Upload: timeout}, func(ctx context.Context, storage *s3Impl) error {
+			_, err := storage.UploadWithProgress(ctx, "", "media/a", bytes.NewReader([]byte("data")), 4, "text/plain", nil)
+			return err
+		}},
+		{"download", "GetObject", OperationTimeouts{Download: timeout}, func(ctx context.Context, storage *s3Impl) error {
+			body, err := storage.Download(ctx, "", "media/existing")
+			if err == nil {
+				body.Close()
+			}
+			return err
+		}},
+		{"delete", "DeleteObject", OperationTimeouts{Delete: timeout}, func(ctx context.Context, storage *s3Impl) error {
+			return storage.Delete(ctx, "", "media/existing")
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeS3Server(t, "media")
+			server.put("media", "media/existing", []byte("data"), time.Now())
+			storage := newTestS3(t, server, Config{Timeouts: tt.timeouts})
+
+			server.delay(tt.op, 500*time.Millisecond)
+			start := time.Now()
+			err := tt.call(context.Background(), storage)
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("error = %v, want %v", err, context.DeadlineExceeded)
+			}
+			if elapsed := time.Since(start); elapsed > 400*time.Millisecond {
+				t.Errorf("took %v, want the operation timeout including retries", elapsed)
+			}
+
+			// Only the operation's own timeout applies, a fast response is unaffected
+			server.delay(tt.op, 0)
+			if err := tt.call(context.Background(), storage); err != nil {
+				t.Errorf("without a delay: %v", err)
+			}
+		})
+	}
+}
+
+func TestOperationTimeoutsAreIndependent(t *testing.T) {
+	server := newFakeS3Server(t, "media")
+	server.put("media", "media/existing", []byte("data"), time.Now())
+	storage := newTestS3(t, server, Config{Timeouts: OperationTimeouts{Upload: 2 * time.Second, Delete: 50 * time.Millisecond}})
+
+	// A slower upload is fine within its longer timeout
+	server.delay("PutObject", 200*time.Millisecond)
+	if _, err := storage.Upload(context.Background(), "", "media/a", bytes.NewReader([]byte("data")), "text/plain"); err != nil {
+		t.Errorf("Upload within its timeout: %v", err)
+	}
+	server.delay("DeleteObject", 200*time.Millisecond)
+	if err := storage.Delete(context.Background(), "", "media/existing"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Delete = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestCancelOnClose(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	body := cancelOnClose{ReadCloser: io.NopCloser(bytes.NewReader(nil)), cancel: cancel}
+	if ctx.Err() != nil {
+		t.Fatal("context canceled before Close")
+	}
+	if err := body.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if ctx.Err() == nil {
+		t.Error("Close did not release the timeout")
+	}
+}
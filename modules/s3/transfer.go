@@ -0,0 +1,396 @@
+package s3
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// Transfer defaults used when TransferManagerConfig leaves them unset
+const (
+	DefaultMultipartThreshold = 64 << 20
+	DefaultPartSize           = 8 << 20
+	DefaultConcurrentParts    = 4
+	DefaultPartRetries        = 3
+
+	// minPartSize and maxParts are S3 limits of multipart uploads
+	minPartSize = 5 << 20
+	maxParts    = 10000
+)
+
+// ProgressFunc receives the bytes stored so far and the total size of an upload,
+// negative while it is unknown. Calls of one upload never overlap and uploadedBytes only grows.
+type ProgressFunc func(uploadedBytes, totalBytes int64)
+
+// TransferManagerConfig configures multipart uploads of UploadWithProgress
+type TransferManagerConfig struct {
+	MultipartThreshold int64 // bodies of at least this many bytes are uploaded in parts (default 64 MiB)
+	PartSize           int64 // size of every part but the last, at least 5 MiB (default 8 MiB)
+	ConcurrentParts    int   // parts uploaded at the same time (default 4)
+	AbortOnError       bool  // abort the upload on the first failed part instead of retrying it
+	PartRetries        int   // retries of a part failing with a transient error, unless AbortOnError (default 3, negative for none)
+}
+
+// withDefaults fills unset fields with the defaults
+func (c TransferManagerConfig) withDefaults() TransferManagerConfig {
+	if c.MultipartThreshold <= 0 {
+		c.MultipartThreshold = DefaultMultipartThreshold
+	}
+	if c.PartSize <= 0 {
+		c.PartSize = DefaultPartSize
+	}
+	c.PartSize = max(c.PartSize, minPartSize)
+	if c.ConcurrentParts <= 0 {
+		c.ConcurrentParts = DefaultConcurrentParts
+	}
+	if c.PartRetries < 0 {
+		c.PartRetries = 0
+	} else if c.PartRetries == 0 {
+		c.PartRetries = DefaultPartRetries
+	}
+	return c
+}
+
+// TransferManager uploads an object as a multipart upload, sending its parts concurrently
+type TransferManager struct {
+	client         *s3.Client
+	cfg            TransferManagerConfig
+	verifyMD5      bool
+	retryBaseDelay time.Duration
+	lockMode       types.ObjectLockMode // empty without object lock
+	retainUntil    func() *time.Time    // retention date of an upload started now, nil without object lock
+}
+
+// NewTransferManager returns a manager uploading through client
+func NewTransferManager(client *s3.Client, cfg TransferManagerConfig) *TransferManager {
+	return &TransferManager{
+		client:         client,
+		cfg:            cfg.withDefaults(),
+		retryBaseDelay: defaultRetryBaseDelay,
+	}
+}
+
+// transferManager returns a manager on the current client with the checks and retry delay of s
+func (s *s3Impl) transferManager() *TransferManager {
+	manager := NewTransferManager(s.client(), s.cfg.Transfer)
+	manager.verifyMD5 = s.verifyMD5 || s.cfg.EnableObjectLock // S3 requires Content-MD5 on parts of uploads with a retention
+	manager.lockMode = s.objectLockMode()
+	manager.retainUntil = s.retainUntilDate
+	manager.retryBaseDelay = s.retryBaseDelay
+	return manager
+}
+
+// Upload stores the size bytes of body under key in parts of PartSize, reporting
+// progress after every finished part; progress may be nil. When a part fails, after
+// its retries unless AbortOnError, the other parts are stopped and the multipart
+// upload is aborted so none of its parts are left stored.
+func (t *TransferManager) Upload(ctx context.Context, bucket, key string, body io.ReaderAt, size int64, contentType string, progress ProgressFunc) error {
+	partSize, partCount := t.parts(size)
+	return t.upload(ctx, bucket, key, size, contentType, progress, func(ctx context.Context, jobs chan<- partJob) error {
+		for number := int32(1); number <= int32(partCount); number++ {
+			offset := int64(number-1) * partSize
+			job := partJob{number: number, body: io.NewSectionReader(body, offset, min(partSize, size-offset))}
+			select {
+			case jobs <- job:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+		return nil
+	})
+}
+
+// errTooManyParts fails uploads of unknown size that do not fit maxParts parts
+var errTooManyParts = fmt.Errorf("upload exceeds %d parts", maxParts)
+
+// UploadStream is Upload for a body read once from start to end, a negative size
+// reads it to its end. Parts are read into ConcurrentParts+1 buffers of the part
+// size, which bound the memory used.
+func (t *TransferManager) UploadStream(ctx context.Context, bucket, key string, body io.Reader, size int64, contentType string, progress ProgressFunc) error {
+	partSize, partCount := t.parts(size)
+	buffers := make(chan []byte, min(t.cfg.ConcurrentParts, partCount)+1)
+	for range cap(buffers) {
+		buffers <- make([]byte, partSize)
+	}
+
+	return t.upload(ctx, bucket, key, size, contentType, progress, func(ctx context.Context, jobs chan<- partJob) error {
+		for number := int32(1); number <= int32(partCount); number++ {
+			var buf []byte
+			select {
+			case buf = <-buffers:
+			case <-ctx.Done():
+				return nil
+			}
+
+			part := buf
+			if size >= 0 {
+				part = buf[:min(partSize, size-int64(number-1)*partSize)]
+			}
+			n, err := io.ReadFull(body, part)
+			last := size < 0 && (err == io.EOF || err == io.ErrUnexpectedEOF)
+			if last {
+				// Only the first part of an empty body is sent empty
+				if n == 0 && number > 1 {
+					return nil
+				}
+				part = part[:n]
+			} else if err != nil {
+				return fmt.Errorf("read part %d: %w", number, err)
+			}
+			job := partJob{
+				number: number,
+				body:   io.NewSectionReader(bytes.NewReader(part), 0, int64(len(part))),
+				done:   func() { buffers <- buf },
+			}
+			select {
+			case jobs <- job:
+			case <-ctx.Done():
+				return nil
+			}
+			if last {
+				return nil
+			}
+		}
+		if size < 0 {
+			if n, _ := io.ReadFull(body, make([]byte, 1)); n > 0 {
+				return errTooManyParts
+			}
+		}
+		return nil
+	})
+}
+
+// parts returns the part size and count of a size-byte upload, objects too large
+// for maxParts parts use larger parts. An unknown, negative, size allows maxParts
+// parts of PartSize.
+func (t *TransferManager) parts(size int64) (partSize int64, partCount int) {
+	if size < 0 {
+		return t.cfg.PartSize, maxParts
+	}
+	partSize = max(t.cfg.PartSize, (size+maxParts-1)/maxParts)
+	partCount = max(int((size+partSize-1)/partSize), 1)
+	return partSize, partCount
+}
+
+// partJob is one part handed to the upload workers, done (may be nil) is called once it is sent
+type partJob struct {
+	number int32
+	body   *io.SectionReader
+	done   func()
+}
+
+// upload runs a multipart upload of the parts sent by feed, which stops when its
+// context is cancelled. Workers upload ConcurrentParts parts at a time; a failed
+// part or feed error aborts the upload. A negative size reports progress against
+// an unknown total until the last part is stored.
+func (t *TransferManager) upload(ctx context.Context, bucket, key string, size int64, contentType string, progress ProgressFunc, feed func(context.Context, chan<- partJob) error) error {
+	_, partCount := t.parts(size)
+
+	input := &s3.CreateMultipartUploadInput{
+		Bucket:         aws.String(bucket),
+		Key:            aws.String(key),
+		ContentType:    optionalString(contentType),
+		ObjectLockMode: t.lockMode,
+	}
+	if t.retainUntil != nil {
+		input.ObjectLockRetainUntilDate = t.retainUntil()
+	}
+	created, err := t.client.CreateMultipartUpload(ctx, input)
+	if err != nil {
+		return err
+	}
+	uploadID := created.UploadId
+
+	partCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		parts    []types.CompletedPart // in the order they finished
+		jobs     = make(chan partJob)
+		errOnce  sync.Once
+		partErr  error
+		mu       sync.Mutex // serializes parts and progress
+		uploaded int64
+		wg       sync.WaitGroup
+	)
+	fail := func(err error) {
+		errOnce.Do(func() {
+			partErr = err
+			cancel()
+		})
+	}
+	for range min(t.cfg.ConcurrentParts, partCount) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				length := job.body.Size()
+				etag, err := t.uploadPart(partCtx, bucket, key, uploadID, job.number, job.body)
+				if job.done != nil {
+					job.done()
+				}
+				if err != nil {
+					fail(fmt.Errorf("part %d: %w", job.number, err))
+					continue
+				}
+				mu.Lock()
+				parts = append(parts, types.CompletedPart{ETag: etag, PartNumber: aws.Int32(job.number)})
+				uploaded += length
+				if progress != nil {
+					progress(uploaded, size)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	if err := feed(partCtx, jobs); err != nil {
+		fail(err)
+	}
+	close(jobs)
+	wg.Wait()
+
+	if partErr == nil && ctx.Err() != nil {
+		partErr = ctx.Err()
+	}
+	if partErr == nil {
+		slices.SortFunc(parts, func(a, b types.CompletedPart) int {
+			return cmp.Compare(*a.PartNumber, *b.PartNumber)
+		})
+		_, partErr = t.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+			Bucket:          aws.String(bucket),
+			Key:             aws.String(key),
+			UploadId:        uploadID,
+			MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+		})
+	}
+	if partErr != nil {
+		// Also when the caller went away, the stored parts are billed until aborted
+		_, _ = t.client.AbortMultipartUpload(context.WithoutCancel(ctx), &s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(bucket),
+			Key:      aws.String(key),
+			UploadId: uploadID,
+		})
+		return partErr
+	}
+	if size < 0 && progress != nil {
+		progress(uploaded, uploaded)
+	}
+	return nil
+}
+
+// uploadPart sends one part, retrying transient errors unless AbortOnError, and returns its ETag
+func (t *TransferManager) uploadPart(ctx context.Context, bucket, key string, uploadID *string, number int32, part *io.SectionReader) (*string, error) {
+	var contentMD5 *string
+	if t.verifyMD5 {
+		hash := md5.New()
+		if _, err := io.Copy(hash, part); err != nil {
+			return nil, err
+		}
+		contentMD5 = aws.String(base64.StdEncoding.EncodeToString(hash.Sum(nil)))
+	}
+
+	attempts := t.cfg.PartRetries + 1
+	if t.cfg.AbortOnError {
+		attempts = 1
+	}
+
+	var etag *string
+	err := RetryS3(ctx, attempts, t.retryBaseDelay, func() error {
+		if _, err := part.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		result, err := t.client.UploadPart(ctx, &s3.UploadPartInput{
+			Bucket:     aws.String(bucket),
+			Key:        aws.String(key),
+			UploadId:   uploadID,
+			PartNumber: aws.Int32(number),
+			Body:       part,
+			ContentMD5: contentMD5,
+		}, withoutSDKRetries)
+		if err != nil {
+			return err
+		}
+		etag = result.ETag
+		return nil
+	})
+	return etag, err
+}
+
+// withoutSDKRetries leaves retrying parts to PartRetries and AbortOnError
+func withoutSDKRetries(o *s3.Options) {
+	o.Retryer = aws.NopRetryer{}
+}
+
+// UploadWithProgress uploads bodies of at least MultipartThreshold bytes with the
+// transfer manager and smaller ones with Upload, reporting once when done
+func (s *s3Impl) UploadWithProgress(ctx context.Context, bucket, key string, body io.ReaderAt, size int64, contentType string, progress ProgressFunc) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.Upload)
+	defer cancel()
+
+	manager := s.transferManager()
+	if size < manager.cfg.MultipartThreshold {
+		if _, err := s.Upload(ctx, bucket, key, io.NewSectionReader(body, 0, size), contentType); err != nil {
+			return "", err
+		}
+		if progress != nil {
+			progress(size, size)
+		}
+		return key, nil
+	}
+
+	bucketName := bucket
+	if bucketName == "" {
+		bucketName = s.bucket
+	}
+	if err := manager.Upload(ctx, bucketName, key, body, size, contentType, progress); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// UploadStream uploads bodies of at least MultipartThreshold bytes in parts read one
+// after another, and reads smaller ones whole so Upload can retry them. Bodies of
+// unknown size are read up to the threshold to tell which they are.
+func (s *s3Impl) UploadStream(ctx context.Context, bucket, key string, body io.Reader, size int64, contentType string, progress ProgressFunc) (string, error) {
+	manager := s.transferManager()
+	if size < 0 {
+		head, err := io.ReadAll(io.LimitReader(body, manager.cfg.MultipartThreshold))
+		if err != nil {
+			return "", err
+		}
+		if int64(len(head)) < manager.cfg.MultipartThreshold {
+			return s.UploadWithProgress(ctx, bucket, key, bytes.NewReader(head), int64(len(head)), contentType, progress)
+		}
+		body = io.MultiReader(bytes.NewReader(head), body)
+	} else if size < manager.cfg.MultipartThreshold {
+		data := make([]byte, size)
+		if _, err := io.ReadFull(body, data); err != nil {
+			return "", err
+		}
+		return s.UploadWithProgress(ctx, bucket, key, bytes.NewReader(data), size, contentType, progress)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.Upload)
+	defer cancel()
+
+	bucketName := bucket
+	if bucketName == "" {
+		bucketName = s.bucket
+	}
+	if err := manager.UploadStream(ctx, bucketName, key, body, size, contentType, progress); err != nil {
+		return "", err
+	}
+	return key, nil
+}
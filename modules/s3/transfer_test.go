@@ -0,0 +1,227 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+// progressRecorder collects the reports of a ProgressFunc
+type progressRecorder struct {
+	mu      sync.Mutex
+	reports []int64
+	total   int64
+}
+
+func (p *progressRecorder) report(uploaded, total int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.reports = append(p.reports, uploaded)
+	p.total = total
+}
+
+// check fails the test unless uploaded never shrank from one report to the next and ended at size
+func (p *progressRecorder) check(t *testing.T, size int64, wantReports int) {
+	t.Helper()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.reports) != wantReports {
+		t.Errorf("progress reported %d times, want %d", len(p.reports), wantReports)
+	}
+	for i := 1; i < len(p.reports); i++ {
+		if p.reports[i] < p.reports[i-1] {
+			t.Errorf("progress went from %d to %d", p.reports[i-1], p.reports[i])
+		}
+	}
+	if len(p.reports) > 0 && (p.reports[len(p.reports)-1] != size || p.total != size) {
+		t.Errorf("last progress = %d of %d, want %d", p.reports[len(p.reports)-1], p.total, size)
+	}
+}
+
+// newTransferS3 returns storage uploading bodies of at least one minimal part in parts of that size
+func newTransferS3(t *testing.T, server *fakeS3Server, cfg TransferManagerConfig) *s3Impl {
+	t.Helper()
+	cfg.MultipartThreshold, cfg.PartSize = minPartSize, minPartSize
+	return newTestS3(t, server, Config{Transfer: cfg})
+}
+
+func randomBytes(t *testing.T, n int) []byte {
+	t.Helper()
+	data := make([]byte, n)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestUploadWithProgress(t *testing.T) {
+	tests := []struct {
+		name        string
+		size        int
+		stream      bool
+		unknownSize bool // stream with a negative size
+		wantParts   int  // 0 for a single PutObject
+		wantReports int
+	}{
+		{"below threshold", minPartSize - 1, false, false, 0, 1},
+		{"exact parts", 2 * minPartSize, false, false, 2, 2},
+		{"last part shorter", 2*minPartSize + 1, false, false, 3, 3},
+		{"stream below threshold", 1024, true, false, 0, 1},
+		{"stream", 2*minPartSize + 1, true, false, 3, 3},
+		// Parts of unknown size are reported against no total, then once more when done
+		{"unknown size below threshold", 1024, true, true, 0, 1},
+		{"unknown size", 2*minPartSize + 1, true, true, 3, 4},
+		{"unknown size of exact parts", 2 * minPartSize, true, true, 2, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeS3Server(t, "media")
+			storage := newTransferS3(t, server, TransferManagerConfig{})
+			data := randomBytes(t, tt.size)
+			var progress progressRecorder
+
+			var err error
+			if tt.stream {
+				size := int64(len(data))
+				if tt.unknownSize {
+					size = -1
+				}
+				_, err = storage.UploadStream(context.Background(), "", "media/key", bytes.NewBuffer(data), size, "image/png", progress.report)
+			} else {
+				_, err = storage.UploadWithProgress(context.Background(), "", "media/key", bytes.NewReader(data), int64(len(data)), "image/png", progress.report)
+			}
+			if err != nil {
+				t.Fatalf("upload: %v", err)
+			}
+
+			object, ok := server.object("media", "media/key")
+			if !ok || !bytes.Equal(object.data, data) {
+				t.Fatal("stored object differs from the upload")
+			}
+			if object.contentType != "image/png" {
+				t.Errorf("content type = %q, want image/png", object.contentType)
+			}
+			if n := server.count("UploadPart"); n != tt.wantParts {
+				t.Errorf("UploadPart sent %d times, want %d", n, tt.wantParts)
+			}
+			progress.check(t, int64(tt.size), tt.wantReports)
+		})
+	}
+}
+
+func TestTransferManagerPartErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		cfg          TransferManagerConfig
+		failures     []int
+		wantErr      bool
+		wantRequests int
+	}{
+		{"transient error retried", TransferManagerConfig{}, []int{http.StatusServiceUnavailable}, false, 4},
+		{"retries exhausted", TransferManagerConfig{PartRetries: 1, ConcurrentParts: 1}, []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable}, true, 2},
+		{"abort on error", TransferManagerConfig{AbortOnError: true, ConcurrentParts: 1}, []int{http.StatusServiceUnavailable}, true, 1},
+		{"permanent error", TransferManagerConfig{ConcurrentParts: 1}, []int{http.StatusForbidden}, true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeS3Server(t, "media")
+			server.failNext("UploadPart", tt.failures...)
+			storage := newTransferS3(t, server, tt.cfg)
+			data := randomBytes(t, 3*minPartSize)
+
+			_, err := storage.UploadWithProgress(context.Background(), "", "media/key", bytes.NewReader(data), int64(len(data)), "", nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UploadWithProgress error = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr {
+				if n := server.count("UploadPart"); n != tt.wantRequests {
+					t.Errorf("UploadPart sent %d times, want %d", n, tt.wantRequests)
+				}
+				return
+			}
+			// Parts after the failed one may or may not have been sent, the failed one never again
+			if n := server.count("UploadPart"); n < tt.wantRequests || n > tt.wantRequests+2 {
+				t.Errorf("UploadPart sent %d times, want %d for the failed part", n, tt.wantRequests)
+			}
+			if n := server.count("AbortMultipartUpload"); n != 1 {
+				t.Errorf("AbortMultipartUpload sent %d times, want 1", n)
+			}
+			if _, ok := server.object("media", "media/key"); ok {
+				t.Error("a failed upload stored the object")
+			}
+			if uploads, _ := storage.ListIncompleteUploads(context.Background(), ""); len(uploads) != 0 {
+				t.Errorf("uploads left after the abort: %v", uploads)
+			}
+		})
+	}
+}
+
+func TestUploadStreamReadError(t *testing.T) {
+	server := newFakeS3Server(t, "media")
+	storage := newTransferS3(t, server, TransferManagerConfig{})
+	readErr := errors.New("client went away")
+	body := io.MultiReader(bytes.NewReader(randomBytes(t, minPartSize+10)), &failingReader{err: readErr})
+
+	_, err := storage.UploadStream(context.Background(), "", "media/key", body, 3*minPartSize, "", nil)
+	if !errors.Is(err, readErr) {
+		t.Fatalf("UploadStream error = %v, want %v", err, readErr)
+	}
+	if n := server.count("AbortMultipartUpload"); n != 1 {
+		t.Errorf("AbortMultipartUpload sent %d times, want 1", n)
+	}
+	if n := server.count("CompleteMultipartUpload"); n != 0 {
+		t.Errorf("CompleteMultipartUpload sent %d times, want 0", n)
+	}
+}
+
+// failingReader fails every read with err
+type failingReader struct{ err error }
+
+func (r *failingReader) Read([]byte) (int, error) { return 0, r.err }
+
+func TestUploadWithProgressVerifiesPartMD5(t *testing.T) {
+	server := newFakeS3Server(t, "media")
+	storage := newTestS3(t, server, Config{VerifyMD5: true, Transfer: TransferManagerConfig{MultipartThreshold: minPartSize, PartSize: minPartSize}})
+	data := randomBytes(t, minPartSize+1)
+
+	if _, err := storage.UploadWithProgress(context.Background(), "", "media/key", bytes.NewReader(data), int64(len(data)), "", nil); err != nil {
+		t.Fatalf("UploadWithProgress: %v", err)
+	}
+	// The last part sent is the short one
+	last := server.last("UploadPart")
+	if got := last.header.Get("Content-MD5"); !contentMD5Matches(got, []byte(last.body)) {
+		t.Errorf("Content-MD5 %q does not match the part", got)
+	}
+}
+
+func TestTransferManagerParts(t *testing.T) {
+	tests := []struct {
+		name         string
+		partSize     int64
+		size         int64
+		wantPartSize int64
+		wantCount    int
+	}{
+		{"empty", 8 << 20, 0, 8 << 20, 1},
+		{"one part", 8 << 20, 1, 8 << 20, 1},
+		{"exact", 8 << 20, 16 << 20, 8 << 20, 2},
+		{"remainder", 8 << 20, 16<<20 + 1, 8 << 20, 3},
+		{"part size raised to the minimum", 1, 10 << 20, minPartSize, 2},
+		{"too many parts", minPartSize, maxParts*minPartSize + 1, minPartSize + 1, maxParts},
+		{"unknown size", 8 << 20, -1, 8 << 20, maxParts},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := NewTransferManager(nil, TransferManagerConfig{PartSize: tt.partSize})
+			partSize, count := manager.parts(tt.size)
+			if partSize != tt.wantPartSize || count != tt.wantCount {
+				t.Errorf("parts(%d) = %d, %d, want %d, %d", tt.size, partSize, count, tt.wantPartSize, tt.wantCount)
+			}
+		})
+	}
+}
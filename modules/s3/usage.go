@@ -0,0 +1,77 @@
+package s3
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// StorageUsage is the space taken by the objects under a prefix
+type StorageUsage struct {
+	TotalBytes   int64
+	TotalObjects int64
+}
+
+// add counts one object of size bytes
+func (u *StorageUsage) add(size int64) {
+	u.TotalBytes += size
+	u.TotalObjects++
+}
+
+// StorageDayLayout formats the day keys of GetStorageByDay
+const StorageDayLayout = "2006-01-02"
+
+// storageDays accumulates object sizes per UTC day of their last modification
+type storageDays struct {
+	from, to time.Time // zero leaves that side unbounded
+	totals   map[string]int64
+}
+
+func newStorageDays(from, to time.Time) *storageDays {
+	return &storageDays{from: from, to: to, totals: make(map[string]int64)}
+}
+
+// add counts an object modified at modified unless it lies outside [from, to)
+func (d *storageDays) add(modified time.Time, size int64) {
+	if !d.from.IsZero() && modified.Before(d.from) {
+		return
+	}
+	if !d.to.IsZero() && !modified.Before(d.to) {
+		return
+	}
+	d.totals[modified.UTC().Format(StorageDayLayout)] += size
+}
+
+// GetStorageUsage sums the sizes of all objects under prefix, one ListObjectsV2 page at a time
+func (s *s3Impl) GetStorageUsage(ctx context.Context, bucket, prefix string) (StorageUsage, error) {
+	var usage StorageUsage
+	err := s.listObjectInfoPages(ctx, bucket, prefix, func(objects []types.Object) error {
+		for _, object := range objects {
+			usage.add(aws.ToInt64(object.Size))
+		}
+		return nil
+	})
+	if err != nil {
+		return StorageUsage{}, err
+	}
+	return usage, nil
+}
+
+// GetStorageByDay sums the sizes of objects under prefix per UTC day (StorageDayLayout)
+// of their LastModified time. Objects modified outside [from, to) are skipped, a zero
+// from or to leaves that side open. Days without objects are missing from the map.
+func (s *s3Impl) GetStorageByDay(ctx context.Context, bucket, prefix string, from, to time.Time) (map[string]int64, error) {
+	days := newStorageDays(from, to)
+	err := s.listObjectInfoPages(ctx, bucket, prefix, func(objects []types.Object) error {
+		for _, object := range objects {
+			days.add(aws.ToTime(object.LastModified), aws.ToInt64(object.Size))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return days.totals, nil
+}
@@ -0,0 +1,92 @@
+package s3
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestGetStorageUsage(t *testing.T) {
+	server := newFakeS3Server(t, "media")
+	server.pageSize = 2
+	now := time.Now()
+	server.put("media", "media/a", []byte("12345"), now)
+	server.put("media", "media/b", []byte("123"), now)
+	server.put("media", "media/c", []byte("1234567"), now)
+	server.put("media", "media/d", nil, now)
+	server.put("media", "manifests/a", []byte("not media"), now)
+	storage := newTestS3(t, server, Config{})
+
+	usage, err := storage.GetStorageUsage(context.Background(), "", "media/")
+	if err != nil {
+		t.Fatalf("GetStorageUsage: %v", err)
+	}
+	if usage != (StorageUsage{TotalBytes: 15, TotalObjects: 4}) {
+		t.Errorf("GetStorageUsage = %+v, want 15 bytes in 4 objects", usage)
+	}
+	if n := server.count("ListObjectsV2"); n != 2 {
+		t.Errorf("ListObjectsV2 sent %d times, want a request per page", n)
+	}
+
+	if usage, err := storage.GetStorageUsage(context.Background(), "", "empty/"); err != nil || usage != (StorageUsage{}) {
+		t.Errorf("GetStorageUsage of an empty prefix = %+v, %v", usage, err)
+	}
+
+	server.failNext("ListObjectsV2", http.StatusForbidden)
+	if _, err := storage.GetStorageUsage(context.Background(), "", "media/"); err == nil {
+		t.Error("GetStorageUsage succeeded with a failed listing")
+	}
+}
+
+func TestGetStorageByDay(t *testing.T) {
+	day := func(s string) time.Time {
+		t.Helper()
+		d, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+
+	server := newFakeS3Server(t, "media")
+	server.pageSize = 2
+	server.put("media", "media/a", []byte("1"), day("2026-10-01T00:00:00Z"))
+	server.put("media", "media/b", []byte("22"), day("2026-10-01T23:59:59Z"))
+	server.put("media", "media/c", []byte("333"), day("2026-10-02T12:00:00Z"))
+	// The day is the UTC day, not the local one of the timestamp
+	server.put("media", "media/d", []byte("4444"), day("2026-10-03T01:00:00+03:00"))
+	server.put("media", "other/e", []byte("55555"), day("2026-10-02T12:00:00Z"))
+	storage := newTestS3(t, server, Config{})
+
+	tests := []struct {
+		name     string
+		from, to string
+		want     map[string]int64
+	}{
+		{"unbounded", "", "", map[string]int64{"2026-10-01": 3, "2026-10-02": 7}},
+		{"from", "2026-10-02T00:00:00Z", "", map[string]int64{"2026-10-02": 7}},
+		{"to is exclusive", "", "2026-10-01T23:59:59Z", map[string]int64{"2026-10-01": 1}},
+		{"from is inclusive", "2026-10-01T23:59:59Z", "2026-10-02T00:00:00Z", map[string]int64{"2026-10-01": 2}},
+		{"nothing in range", "2026-11-01T00:00:00Z", "2026-12-01T00:00:00Z", map[string]int64{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var from, to time.Time
+			if tt.from != "" {
+				from = day(tt.from)
+			}
+			if tt.to != "" {
+				to = day(tt.to)
+			}
+			got, err := storage.GetStorageByDay(context.Background(), "", "media/", from, to)
+			if err != nil {
+				t.Fatalf("GetStorageByDay: %v", err)
+			}
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("GetStorageByDay = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
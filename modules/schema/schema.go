@@ -0,0 +1,213 @@
+package schema
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Draft is the JSON Schema dialect of generated schemas
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is a JSON Schema object, only with the keywords Generator produces
+type Schema struct {
+	SchemaURI   string             `json:"$schema,omitempty"`
+	Type        string             `json:"type,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Format      string             `json:"format,omitempty"`
+	Enum        []any              `json:"enum,omitempty"`
+	Minimum     *float64           `json:"minimum,omitempty"`
+	Maximum     *float64           `json:"maximum,omitempty"`
+	MinLength   *int               `json:"minLength,omitempty"`
+	MaxLength   *int               `json:"maxLength,omitempty"`
+	MinItems    *int               `json:"minItems,omitempty"`
+	MaxItems    *int               `json:"maxItems,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+
+	AdditionalProperties *bool `json:"additionalProperties,omitempty"`
+}
+
+// Generator builds JSON Schemas of structs from their struct tags:
+//
+//   - json names the property, "-" leaves the field out
+//   - desc is the description
+//   - validate holds comma separated rules: required, min=N and max=N (length of
+//     strings, number of items of slices, value of numbers), oneof=a b c and format=F
+//
+// Schemas are cached per type, a Generator is safe for concurrent use.
+type Generator struct {
+	cache sync.Map // reflect.Type -> *Schema
+}
+
+// NewGenerator returns a Generator with an empty cache
+func NewGenerator() *Generator {
+	return &Generator{}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Generate returns the schema of the struct (or pointer to struct) v. Additional
+// properties are not allowed. The result is shared, callers must not modify it.
+func (g *Generator) Generate(v any) (*Schema, error) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("schema: %T is not a struct", v)
+	}
+
+	if cached, ok := g.cache.Load(t); ok {
+		return cached.(*Schema), nil
+	}
+
+	s, err := structSchema(t)
+	if err != nil {
+		return nil, err
+	}
+	s.SchemaURI = Draft
+	cached, _ := g.cache.LoadOrStore(t, s)
+	return cached.(*Schema), nil
+}
+
+func structSchema(t reflect.Type) (*Schema, error) {
+	noAdditional := false
+	s := &Schema{
+		Type:                 "object",
+		Properties:           make(map[string]*Schema),
+		AdditionalProperties: &noAdditional,
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		prop, err := typeSchema(field.Type)
+		if err != nil {
+			return nil, fmt.Errorf("schema: field %s: %w", field.Name, err)
+		}
+		prop.Description = field.Tag.Get("desc")
+
+		required, err := applyRules(prop, field.Tag.Get("validate"))
+		if err != nil {
+			return nil, fmt.Errorf("schema: field %s: %w", field.Name, err)
+		}
+		if required {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = prop
+	}
+	return s, nil
+}
+
+// typeSchema maps a Go type to the type of its schema
+func typeSchema(t reflect.Type) (*Schema, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}, nil
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}, nil
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// encoding/json sends []byte as base64
+			return &Schema{Type: "string", Format: "byte"}, nil
+		}
+		items, err := typeSchema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "array", Items: items}, nil
+	case reflect.Struct:
+		return structSchema(t)
+	default:
+		return nil, fmt.Errorf("unsupported type %s", t)
+	}
+}
+
+// applyRules adds the validate rules to s and reports whether the field is required
+func applyRules(s *Schema, rules string) (required bool, err error) {
+	if rules == "" {
+		return false, nil
+	}
+
+	for _, rule := range strings.Split(rules, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		switch name {
+		case "required":
+			required = true
+		case "min", "max":
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return false, fmt.Errorf("invalid %s rule %q", name, value)
+			}
+			if err := applyBound(s, name == "min", n); err != nil {
+				return false, err
+			}
+		case "oneof":
+			for _, option := range strings.Fields(value) {
+				s.Enum = append(s.Enum, option)
+			}
+		case "format":
+			s.Format = value
+		default:
+			return false, fmt.Errorf("unknown validate rule %q", name)
+		}
+	}
+	return required, nil
+}
+
+// applyBound sets the lower or upper bound matching the type of s
+func applyBound(s *Schema, lower bool, n float64) error {
+	switch s.Type {
+	case "integer", "number":
+		if lower {
+			s.Minimum = &n
+		} else {
+			s.Maximum = &n
+		}
+	case "string", "array":
+		if n != float64(int(n)) || n < 0 {
+			return fmt.Errorf("length bound %v is not a count", n)
+		}
+		count := int(n)
+		switch {
+		case s.Type == "string" && lower:
+			s.MinLength = &count
+		case s.Type == "string":
+			s.MaxLength = &count
+		case lower:
+			s.MinItems = &count
+		default:
+			s.MaxItems = &count
+		}
+	default:
+		return fmt.Errorf("min and max do not apply to %s", s.Type)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type nested struct {
+	Name string `json:"name" validate:"required"`
+}
+
+type example struct {
+	ID        int64     `json:"id" validate:"required,min=1" desc:"Identifier"`
+	Ratio     float64   `json:"ratio" validate:"min=0,max=1"`
+	Name      string    `json:"name,omitempty" validate:"min=3,max=10"`
+	Mode      string    `json:"mode" validate:"oneof=fast slow"`
+	Email     string    `json:"email" validate:"format=email"`
+	Enabled   *bool     `json:"enabled"`
+	Tags      []string  `json:"tags" validate:"max=5"`
+	Data      []byte    `json:"data"`
+	Created   time.Time `json:"created"`
+	Child     nested    `json:"child"`
+	Untagged  string
+	Skipped   string `json:"-"`
+	unexposed string
+}
+
+func TestGenerate(t *testing.T) {
+	s, err := NewGenerator().Generate(&example{})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if s.SchemaURI != Draft || s.Type != "object" || s.AdditionalProperties == nil || *s.AdditionalProperties {
+		t.Errorf("root = %+v, want a closed object of the draft", s)
+	}
+	if want := []string{"id"}; !reflect.DeepEqual(s.Required, want) {
+		t.Errorf("required = %v, want %v", s.Required, want)
+	}
+
+	tests := []struct {
+		property string
+		want     string // JSON of the property schema
+	}{
+		{"id", `{"type":"integer","description":"Identifier","minimum":1}`},
+		{"ratio", `{"type":"number","minimum":0,"maximum":1}`},
+		{"name", `{"type":"string","minLength":3,"maxLength":10}`},
+		{"mode", `{"type":"string","enum":["fast","slow"]}`},
+		{"email", `{"type":"string","format":"email"}`},
+		{"enabled", `{"type":"boolean"}`},
+		{"tags", `{"type":"array","maxItems":5,"items":{"type":"string"}}`},
+		{"data", `{"type":"string","format":"byte"}`},
+		{"created", `{"type":"string","format":"date-time"}`},
+		{"child", `{"type":"object","properties":{"name":{"type":"string"}},"required":["name"],"additionalProperties":false}`},
+		{"Untagged", `{"type":"string"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.property, func(t *testing.T) {
+			prop, ok := s.Properties[tt.property]
+			if !ok {
+				t.Fatalf("property %s missing", tt.property)
+			}
+			got, _ := json.Marshal(prop)
+			if string(got) != tt.want {
+				t.Errorf("schema = %s, want %s", got, tt.want)
+			}
+		})
+	}
+	if len(s.Properties) != len(tests) {
+		t.Errorf("%d properties, want %d", len(s.Properties), len(tests))
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		v    any
+		want string
+	}{
+		{"not a struct", 42, "is not a struct"},
+		{"nil", nil, "is not a struct"},
+		{"unsupported type", struct {
+			M map[string]int `json:"m"`
+		}{}, "unsupported type"},
+		{"unknown rule", struct {
+			S string `validate:"pattern=x"`
+		}{}, "unknown validate rule"},
+		{"bound not a number", struct {
+			S string `validate:"min=a"`
+		}{}, "invalid min rule"},
+		{"fractional length", struct {
+			S string `validate:"max=1.5"`
+		}{}, "is not a count"},
+		{"bound on bool", struct {
+			B bool `validate:"min=1"`
+		}{}, "do not apply to boolean"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewGenerator().Generate(tt.v)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Generate error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateCache(t *testing.T) {
+	g := NewGenerator()
+	first, err := g.Generate(example{})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if s, _ := g.Generate(&example{}); s != first {
+				t.Error("a pointer to the type got a new schema")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if s, _ := NewGenerator().Generate(example{}); s == first {
+		t.Error("generators share their cache")
+	}
+}
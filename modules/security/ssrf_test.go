@@ -0,0 +1,60 @@
+package security
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestIsSafeURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		safe bool
+	}{
+		{"https://93.184.216.34/hook", true},
+		{"http://8.8.8.8:8080/hook?x=1", true},
+		{"https://[2606:4700:4700::1111]/hook", true},
+		{"https://172.32.0.1/", true}, // just outside 172.16.0.0/12
+
+		{"ftp://93.184.216.34/", false},
+		{"file:///etc/passwd", false},
+		{"gopher://93.184.216.34/", false},
+		{"//93.184.216.34/", false},
+		{"https://", false},
+		{"://bad", false},
+
+		{"http://localhost/", false},
+		{"http://LOCALHOST./", false},
+		{"http://api.localhost:8080/", false},
+
+		{"http://0.0.0.0/", false},
+		{"http://127.0.0.1/", false},
+		{"http://127.255.255.254/", false},
+		{"http://10.1.2.3/", false},
+		{"http://172.16.0.1/", false},
+		{"http://172.31.255.255/", false},
+		{"http://192.168.1.1/", false},
+		{"http://169.254.169.254/latest/meta-data/", false},
+		{"http://[::1]/", false},
+		{"http://[::]/", false},
+		{"http://[fd12:3456::1]/", false},
+		{"http://[fe80::1%25eth0]/", false},
+		{"http://[::ffff:127.0.0.1]/", false},
+		{"http://[::ffff:169.254.169.254]/", false},
+
+		{"http://host.invalid/", false}, // does not resolve
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			err := IsSafeURL(tt.url)
+			if tt.safe {
+				if err != nil {
+					t.Errorf("IsSafeURL(%q) = %v, want nil", tt.url, err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrSSRFAttempt) {
+				t.Errorf("IsSafeURL(%q) = %v, want ErrSSRFAttempt", tt.url, err)
+			}
+		})
+	}
+}
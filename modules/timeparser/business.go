@@ -0,0 +1,126 @@
+package timeparser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HolidayCalendar определяет нерабочие дни помимо выходных
+type HolidayCalendar interface {
+	IsHoliday(t time.Time) bool
+}
+
+// WeekendOnlyCalendar — календарь без праздников, нерабочими считаются только суббота и воскресенье
+type WeekendOnlyCalendar struct{}
+
+// IsHoliday всегда возвращает false: выходные обрабатываются отдельно
+func (WeekendOnlyCalendar) IsHoliday(time.Time) bool {
+	return false
+}
+
+// businessDaySuffix — суффикс рабочих дней в строках вида "5bd"
+const businessDaySuffix = "bd"
+
+// maxBusinessDays ограничивает "Nbd": AddBusinessDays идет по дням, и огромное N зависало бы
+const maxBusinessDays = 3650
+
+// BusinessDaysUntil возвращает количество рабочих дней от ut до t2 в указанной таймзоне.
+// Считаются календарные дни в (ut, t2]; если t2 раньше ut, результат отрицательный.
+func (ut UniversalTime) BusinessDaysUntil(t2 UniversalTime, timezone string) int {
+	return ut.BusinessDaysUntilCalendar(t2, timezone, WeekendOnlyCalendar{})
+}
+
+// BusinessDaysUntilCalendar аналогичен BusinessDaysUntil, но учитывает праздники из cal
+func (ut UniversalTime) BusinessDaysUntilCalendar(t2 UniversalTime, timezone string, cal HolidayCalendar) int {
+	loc := loadLocation(timezone)
+	from := startOfDay(ut.Time.In(loc))
+	to := startOfDay(t2.Time.In(loc))
+
+	sign := 1
+	if to.Before(from) {
+		from, to = to, from
+		sign = -1
+	}
+
+	count := 0
+	for day := from.AddDate(0, 0, 1); !day.After(to); day = day.AddDate(0, 0, 1) {
+		if isBusinessDay(day, cal) {
+			count++
+		}
+	}
+	return sign * count
+}
+
+// AddBusinessDays прибавляет n рабочих дней в указанной таймзоне, сохраняя локальное время суток
+func (ut UniversalTime) AddBusinessDays(n int, timezone string) UniversalTime {
+	return ut.AddBusinessDaysCalendar(n, timezone, WeekendOnlyCalendar{})
+}
+
+// AddBusinessDaysCalendar аналогичен AddBusinessDays, но учитывает праздники из cal
+func (ut UniversalTime) AddBusinessDaysCalendar(n int, timezone string, cal HolidayCalendar) UniversalTime {
+	loc := loadLocation(timezone)
+	t := ut.Time.In(loc)
+
+	step := 1
+	if n < 0 {
+		step = -1
+		n = -n
+	}
+
+	// AddDate работает с календарными датами, поэтому переходы DST не сдвигают время суток
+	for n > 0 {
+		t = t.AddDate(0, 0, step)
+		if isBusinessDay(t, cal) {
+			n--
+		}
+	}
+
+	return NewUniversalTime(t)
+}
+
+// parseBusinessDays парсит строки вида "5bd" (рабочие дни от текущего момента в loc)
+func parseBusinessDays(s string, loc *time.Location) (UniversalTime, bool, error) {
+	numStr, ok := strings.CutSuffix(strings.ToLower(s), businessDaySuffix)
+	if !ok || numStr == "" {
+		return UniversalTime{}, false, nil
+	}
+
+	n, err := strconv.Atoi(numStr)
+	if err != nil {
+		return UniversalTime{}, false, nil
+	}
+	if n <= 0 {
+		return UniversalTime{}, true, fmt.Errorf("business days must be positive: %s", s)
+	}
+	if n > maxBusinessDays {
+		return UniversalTime{}, true, fmt.Errorf("business days must not exceed %d: %s", maxBusinessDays, s)
+	}
+
+	return NewUniversalTimeNow().AddBusinessDays(n, loc.String()), true, nil
+}
+
+func isBusinessDay(t time.Time, cal HolidayCalendar) bool {
+	switch t.Weekday() {
+	case time.Saturday, time.Sunday:
+		return false
+	}
+	return cal == nil || !cal.IsHoliday(t)
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// loadLocation загружает таймзону, при ошибке возвращает UTC
+func loadLocation(timezone string) *time.Location {
+	if timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
@@ -0,0 +1,125 @@
+package timeparser
+
+import (
+	"testing"
+	"time"
+)
+
+// holidays — календарь праздников для тестов, ключ в формате 2006-01-02
+type holidays map[string]bool
+
+func (h holidays) IsHoliday(t time.Time) bool {
+	return h[t.Format(time.DateOnly)]
+}
+
+func utc(s string) UniversalTime {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		panic(err)
+	}
+	return NewUniversalTime(t)
+}
+
+func TestBusinessDaysUntil(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to string
+		timezone string
+		cal      HolidayCalendar
+		want     int
+	}{
+		{"понедельник — пятница", "2026-10-12T10:00:00Z", "2026-10-16T10:00:00Z", "", nil, 4},
+		{"через выходные", "2026-10-12T10:00:00Z", "2026-10-19T10:00:00Z", "", nil, 5},
+		{"пятница — понедельник", "2026-10-16T10:00:00Z", "2026-10-19T08:00:00Z", "", nil, 1},
+		{"пятница — воскресенье", "2026-10-16T10:00:00Z", "2026-10-18T23:00:00Z", "", nil, 0},
+		{"тот же день", "2026-10-12T08:00:00Z", "2026-10-12T20:00:00Z", "", nil, 0},
+		{"в прошлое", "2026-10-19T10:00:00Z", "2026-10-12T10:00:00Z", "", nil, -5},
+		// В Москве 2026-10-18T22:00Z — уже понедельник
+		{"таймзона", "2026-10-16T10:00:00Z", "2026-10-18T22:00:00Z", "Europe/Moscow", nil, 1},
+		{"таймзона UTC", "2026-10-16T10:00:00Z", "2026-10-18T22:00:00Z", "UTC", nil, 0},
+		{"неизвестная таймзона как UTC", "2026-10-16T10:00:00Z", "2026-10-18T22:00:00Z", "Mars/Olympus", nil, 0},
+		{"праздник", "2026-10-12T10:00:00Z", "2026-10-16T10:00:00Z", "", holidays{"2026-10-14": true}, 3},
+		{"праздник в выходной", "2026-10-12T10:00:00Z", "2026-10-19T10:00:00Z", "", holidays{"2026-10-17": true}, 5},
+		{"новый год", "2026-12-31T10:00:00Z", "2027-01-01T10:00:00Z", "", holidays{"2027-01-01": true}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got int
+			if tt.cal == nil {
+				got = utc(tt.from).BusinessDaysUntil(utc(tt.to), tt.timezone)
+			} else {
+				got = utc(tt.from).BusinessDaysUntilCalendar(utc(tt.to), tt.timezone, tt.cal)
+			}
+			if got != tt.want {
+				t.Errorf("BusinessDaysUntil(%s, %s, %q) = %d, want %d", tt.from, tt.to, tt.timezone, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddBusinessDays(t *testing.T) {
+	tests := []struct {
+		name     string
+		from     string
+		n        int
+		timezone string
+		cal      HolidayCalendar
+		want     string
+	}{
+		{"следующий день", "2026-10-12T10:00:00Z", 1, "", nil, "2026-10-13T10:00:00Z"},
+		{"с пятницы", "2026-10-16T10:00:00Z", 1, "", nil, "2026-10-19T10:00:00Z"},
+		{"неделя", "2026-10-12T10:00:00Z", 5, "", nil, "2026-10-19T10:00:00Z"},
+		{"назад", "2026-10-19T10:00:00Z", -1, "", nil, "2026-10-16T10:00:00Z"},
+		{"ноль", "2026-10-17T10:00:00Z", 0, "", nil, "2026-10-17T10:00:00Z"},
+		{"праздник", "2026-10-12T10:00:00Z", 2, "", holidays{"2026-10-13": true}, "2026-10-15T10:00:00Z"},
+		// В ночь на 25 октября Берлин переходит на зимнее время, 9:00 по местному времени сохраняется
+		{"переход DST", "2026-10-23T07:00:00Z", 1, "Europe/Berlin", nil, "2026-10-26T08:00:00Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got UniversalTime
+			if tt.cal == nil {
+				got = utc(tt.from).AddBusinessDays(tt.n, tt.timezone)
+			} else {
+				got = utc(tt.from).AddBusinessDaysCalendar(tt.n, tt.timezone, tt.cal)
+			}
+			if want := utc(tt.want); !got.Time.Equal(want.Time) {
+				t.Errorf("AddBusinessDays(%s, %d) = %s, want %s", tt.from, tt.n, got.Time.UTC(), want.Time)
+			}
+		})
+	}
+}
+
+func TestParseBusinessDays(t *testing.T) {
+	tests := []struct {
+		input   string
+		wantErr bool
+	}{
+		{"1bd", false},
+		{"5BD", false},
+		{"3650bd", false},
+		{"0bd", true},
+		{"-2bd", true},
+		{"3651bd", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseWithLocation(tt.input, time.UTC)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseWithLocation(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if !tt.wantErr && !got.Time.After(time.Now()) {
+				t.Errorf("ParseWithLocation(%q) = %s, want a future time", tt.input, got)
+			}
+		})
+	}
+
+	// Результат — рабочий день
+	got, err := ParseWithLocation("1bd", time.UTC)
+	if err != nil {
+		t.Fatalf("ParseWithLocation: %v", err)
+	}
+	if day := got.Time.UTC().Weekday(); day == time.Saturday || day == time.Sunday {
+		t.Errorf("1bd falls on %s", day)
+	}
+}
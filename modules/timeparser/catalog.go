@@ -0,0 +1,65 @@
+package timeparser
+
+import (
+	"golang.org/x/text/feature/plural"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message/catalog"
+)
+
+// localizeCatalog содержит переводы Localize. Формы чисел выбираются по правилам CLDR:
+// в русском one (1, 21), few (2–4, 22) и many (5–20, 11), в английском и немецком one и other.
+var localizeCatalog = newLocalizeCatalog()
+
+func newLocalizeCatalog() *catalog.Builder {
+	b := catalog.NewBuilder(catalog.Fallback(language.English))
+
+	set := func(lang language.Tag, key string, msg ...catalog.Message) {
+		if err := b.Set(lang, key, msg...); err != nil {
+			panic("timeparser: invalid catalog message " + key + ": " + err.Error())
+		}
+	}
+
+	set(language.English, msgNoExpiry, catalog.String("no expiry"))
+	set(language.English, msgExpired, catalog.String("expired"))
+	set(language.English, msgInMinutes, plural.Selectf(1, "%d",
+		plural.One, "in %d minute",
+		plural.Other, "in %d minutes"))
+	set(language.English, msgInHours, plural.Selectf(1, "%d",
+		plural.One, "in %d hour",
+		plural.Other, "in %d hours"))
+	set(language.English, msgInDays, plural.Selectf(1, "%d",
+		plural.One, "in %d day",
+		plural.Other, "in %d days"))
+
+	set(language.Russian, msgNoExpiry, catalog.String("без срока действия"))
+	set(language.Russian, msgExpired, catalog.String("срок истек"))
+	set(language.Russian, msgInMinutes, plural.Selectf(1, "%d",
+		plural.One, "через %d минуту",
+		plural.Few, "через %d минуты",
+		plural.Many, "через %d минут",
+		plural.Other, "через %d минуты"))
+	set(language.Russian, msgInHours, plural.Selectf(1, "%d",
+		plural.One, "через %d час",
+		plural.Few, "через %d часа",
+		plural.Many, "через %d часов",
+		plural.Other, "через %d часа"))
+	set(language.Russian, msgInDays, plural.Selectf(1, "%d",
+		plural.One, "через %d день",
+		plural.Few, "через %d дня",
+		plural.Many, "через %d дней",
+		plural.Other, "через %d дня"))
+
+	set(language.German, msgNoExpiry, catalog.String("kein Ablaufdatum"))
+	set(language.German, msgExpired, catalog.String("abgelaufen"))
+	set(language.German, msgInMinutes, plural.Selectf(1, "%d",
+		plural.One, "in %d Minute",
+		plural.Other, "in %d Minuten"))
+	set(language.German, msgInHours, plural.Selectf(1, "%d",
+		plural.One, "in %d Stunde",
+		plural.Other, "in %d Stunden"))
+	set(language.German, msgInDays, plural.Selectf(1, "%d",
+		plural.One, "in %d Tag",
+		plural.Other, "in %d Tagen"))
+
+	return b
+}
@@ -0,0 +1,104 @@
+package timeparser
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Приблизительная длина календарных единиц в ParseISODuration
+const (
+	isoDay   = 24 * time.Hour
+	isoMonth = 30 * isoDay
+	isoYear  = 365 * isoDay
+)
+
+// ParseISODuration парсит длительность ISO 8601 P[nY][nM][nW][nD][T[nH][nM][nS]],
+// например "P1Y2M3DT4H5M6S", "P1D" или "PT2H". В отличие от ParseInterval годы и
+// месяцы не календарные: год считается равным 365 дням, месяц — 30 дням.
+// Знак "-" перед P задает отрицательную длительность.
+func ParseISODuration(s string) (time.Duration, error) {
+	orig := s
+	s = strings.TrimSpace(s)
+	s, negative := strings.CutPrefix(s, "-")
+
+	d, err := parseISODuration(s)
+	if err != nil {
+		return 0, err
+	}
+	total, ok := d.approximate()
+	if !ok {
+		return 0, fmt.Errorf("ISO 8601 duration out of range: %s", orig)
+	}
+	if negative {
+		total = -total
+	}
+	return total, nil
+}
+
+// approximate переводит длительность в time.Duration по длинам isoYear, isoMonth и isoDay.
+// false означает, что сумма не помещается в time.Duration.
+func (d isoDuration) approximate() (time.Duration, bool) {
+	total := d.clock
+	parts := []struct {
+		n    int
+		unit time.Duration
+	}{
+		{d.years, isoYear},
+		{d.months, isoMonth},
+		{d.days, isoDay},
+	}
+	for _, part := range parts {
+		if int64(part.n) > (math.MaxInt64-int64(total))/int64(part.unit) {
+			return 0, false
+		}
+		total += time.Duration(part.n) * part.unit
+	}
+	return total, true
+}
+
+// FormatISODuration форматирует d как длительность ISO 8601, которую принимает ParseISODuration:
+// дни и время суток, например 26h30m дает "P1DT2H30M". Годы и месяцы не используются,
+// их длина приблизительная. Нулевая длительность — "PT0S", отрицательная начинается с "-".
+func FormatISODuration(d time.Duration) string {
+	if d == 0 {
+		return "PT0S"
+	}
+
+	var b strings.Builder
+	// Беззнаковое значение, чтобы -math.MinInt64 не переполнялось
+	u := uint64(d)
+	if d < 0 {
+		b.WriteByte('-')
+		u = -u
+	}
+	b.WriteByte('P')
+
+	if days := u / uint64(isoDay); days > 0 {
+		b.WriteString(strconv.FormatUint(days, 10) + "D")
+		u %= uint64(isoDay)
+	}
+	if u == 0 {
+		return b.String()
+	}
+
+	b.WriteByte('T')
+	if hours := u / uint64(time.Hour); hours > 0 {
+		b.WriteString(strconv.FormatUint(hours, 10) + "H")
+		u %= uint64(time.Hour)
+	}
+	if minutes := u / uint64(time.Minute); minutes > 0 {
+		b.WriteString(strconv.FormatUint(minutes, 10) + "M")
+		u %= uint64(time.Minute)
+	}
+	if u > 0 {
+		b.WriteString(strconv.FormatUint(u/uint64(time.Second), 10))
+		if nsec := u % uint64(time.Second); nsec > 0 {
+			b.WriteString(strings.TrimRight(fmt.Sprintf(".%09d", nsec), "0"))
+		}
+		b.WriteByte('S')
+	}
+	return b.String()
+}
@@ -0,0 +1,145 @@
+package timeparser
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseISODuration(t *testing.T) {
+	const day = 24 * time.Hour
+
+	tests := []struct {
+		name string
+		in   string
+		want time.Duration
+	}{
+		{"дни", "P1D", day},
+		{"часы", "PT2H", 2 * time.Hour},
+		{"минуты", "PT90M", 90 * time.Minute},
+		{"секунды", "PT45S", 45 * time.Second},
+		{"дробные секунды", "PT1.5S", 1500 * time.Millisecond},
+		{"наносекунды", "PT0.000000001S", time.Nanosecond},
+		{"недели", "P2W", 14 * day},
+		{"год — 365 дней", "P1Y", 365 * day},
+		{"месяц — 30 дней", "P1M", 30 * day},
+		{"месяц и минуты", "P1MT1M", 30*day + time.Minute},
+		{"полная длительность", "P1Y2M3DT4H5M6S", 428*day + 4*time.Hour + 5*time.Minute + 6*time.Second},
+		{"ноль", "PT0S", 0},
+		{"отрицательная", "-P1DT12H", -36 * time.Hour},
+		{"строчные буквы", "p1dt1h", 25 * time.Hour},
+		{"пробелы", "  PT1H  ", time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseISODuration(tt.in)
+			if err != nil {
+				t.Fatalf("ParseISODuration(%q): %v", tt.in, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseISODuration(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseISODurationErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"пусто", "", "invalid ISO 8601 duration"},
+		{"только P", "P", "invalid ISO 8601 duration"},
+		{"только T", "PT", "invalid ISO 8601 duration"},
+		{"T без частей", "P1DT", "invalid ISO 8601 duration"},
+		{"буквы без чисел", "PXY", "invalid ISO 8601 duration"},
+		{"повтор части", "P1D1D", "invalid ISO 8601 duration"},
+		{"неверный порядок", "PT1S1H", "invalid ISO 8601 duration"},
+		{"без P", "1D", "invalid ISO 8601 duration"},
+		{"два знака", "--P1D", "invalid ISO 8601 duration"},
+		{"переполнение часов", "PT9999999999999H", "out of range"},
+		{"переполнение лет", "P999999999999Y", "out of range"},
+		{"переполнение суммы", "P292Y1000D", "out of range"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseISODuration(tt.in)
+			if err == nil {
+				t.Fatalf("ParseISODuration(%q) = %v, want an error", tt.in, got)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseISODuration(%q) error = %v, want %q", tt.in, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatISODuration(t *testing.T) {
+	tests := []struct {
+		name string
+		in   time.Duration
+		want string
+	}{
+		{"ноль", 0, "PT0S"},
+		{"дни и время", 26*time.Hour + 30*time.Minute, "P1DT2H30M"},
+		{"только дни", 48 * time.Hour, "P2D"},
+		{"только секунды", 5 * time.Second, "PT5S"},
+		{"дробные секунды", 1500 * time.Millisecond, "PT1.5S"},
+		{"наносекунды", time.Nanosecond, "PT0.000000001S"},
+		{"часы и секунды", time.Hour + time.Second, "PT1H1S"},
+		{"отрицательная", -90 * time.Minute, "-PT1H30M"},
+		{"минимальная", math.MinInt64, "-P106751DT23H47M16.854775808S"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FormatISODuration(tt.in); got != tt.want {
+				t.Errorf("FormatISODuration(%v) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseUniversalTimeISODuration(t *testing.T) {
+	before := time.Now()
+	got, err := ParseUniversalTime("P1DT2H")
+	if err != nil {
+		t.Fatalf("ParseUniversalTime: %v", err)
+	}
+	after := time.Now()
+
+	want := 26 * time.Hour
+	if got.Time.Before(before.Add(want)) || got.Time.After(after.Add(want)) {
+		t.Errorf("ParseUniversalTime(P1DT2H) = %v, want 26h from now", got)
+	}
+	if got.Time.Location() != time.UTC {
+		t.Errorf("result is in %v, want UTC", got.Time.Location())
+	}
+
+	if _, err := ParseUniversalTime("PT"); err == nil {
+		t.Error("ParseUniversalTime accepted a duration without parts")
+	}
+}
+
+func FuzzISODuration(f *testing.F) {
+	for _, seed := range []int64{0, 1, -1, int64(time.Second), int64(26*time.Hour + 30*time.Minute), math.MaxInt64, math.MinInt64 + 1} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, n int64) {
+		d := time.Duration(n)
+		if d == math.MinInt64 {
+			// Its magnitude does not fit in time.Duration before the sign is applied
+			return
+		}
+		s := FormatISODuration(d)
+		got, err := ParseISODuration(s)
+		if err != nil {
+			t.Fatalf("ParseISODuration(FormatISODuration(%d) = %q): %v", n, s, err)
+		}
+		if got != d {
+			t.Errorf("ParseISODuration(%q) = %d, want %d", s, got, n)
+		}
+	})
+}
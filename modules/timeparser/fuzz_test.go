@@ -0,0 +1,91 @@
+package timeparser
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func FuzzParseUniversalTime(f *testing.F) {
+	for _, seed := range []string{
+		"",
+		"   ",
+		"never",
+		"2025-06-01T12:00:00Z",
+		"2025-06-01T12:00:00.123456789+03:00",
+		"2025-06-01",
+		"31/02/2025",
+		"02.01.2025 15:04:05",
+		"Mon, 02 Jan 2006 15:04:05 MST",
+		"3:04PM",
+		"0",
+		"4102444800",
+		"4102444800000",
+		"9223372036854775807",
+		"-1",
+		"12abc",
+		"5bd",
+		"0bd",
+		"99999999999bd",
+		"P1D",
+		"PT2H30M",
+		"P",
+		"P999999999999Y",
+		"P1.5W",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, s string) {
+		ut, err := ParseUniversalTime(s)
+		if err != nil {
+			if !ut.IsZero() {
+				t.Errorf("ParseUniversalTime(%q) returned %v with error %v", s, ut, err)
+			}
+			return
+		}
+		if !ut.IsZero() && ut.Time.Location() != time.UTC {
+			t.Errorf("ParseUniversalTime(%q) = %v in %v, want UTC", s, ut, ut.Time.Location())
+		}
+
+		var text UniversalTime
+		textErr := text.UnmarshalText([]byte(s))
+		if textErr != nil {
+			t.Errorf("UnmarshalText(%q) = %v, ParseUniversalTime accepted it", s, textErr)
+		}
+		if !text.IsZero() && text.Time.Location() != time.UTC {
+			t.Errorf("UnmarshalText(%q) = %v in %v, want UTC", s, text, text.Time.Location())
+		}
+	})
+}
+
+func TestParseUniversalTimeRejectsMalformed(t *testing.T) {
+	tests := []string{
+		"12abc",
+		"never",
+		"31/02/2025",
+		"-1",
+		"9223372036854775807",
+		"0bd",
+		"99999bd",
+		"P",
+	}
+	for _, s := range tests {
+		t.Run(s, func(t *testing.T) {
+			if ut, err := ParseUniversalTime(s); err == nil {
+				t.Errorf("ParseUniversalTime(%q) = %v, want an error", s, ut)
+			}
+		})
+	}
+}
+
+func TestParseUniversalTimeLargeBusinessDaysIsFast(t *testing.T) {
+	start := time.Now()
+	_, err := ParseUniversalTime(strings.Repeat("9", 9) + "bd")
+	if err == nil {
+		t.Error("ParseUniversalTime accepted a billion business days")
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("ParseUniversalTime took %v", d)
+	}
+}
@@ -0,0 +1,249 @@
+package timeparser
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Interval — полуоткрытый промежуток времени [Start, End).
+// Нулевой Interval означает отсутствие ограничения.
+type Interval struct {
+	Start UniversalTime
+	End   UniversalTime
+}
+
+// IsZero проверяет, задан ли интервал
+func (i Interval) IsZero() bool {
+	return i.Start.IsZero() && i.End.IsZero()
+}
+
+// Duration возвращает длительность интервала
+func (i Interval) Duration() time.Duration {
+	return i.End.Sub(i.Start.Time)
+}
+
+// Contains проверяет, попадает ли t в интервал (начало включается, конец нет)
+func (i Interval) Contains(t UniversalTime) bool {
+	return !t.Before(i.Start.Time) && t.Before(i.End.Time)
+}
+
+// Overlaps проверяет, есть ли у интервалов общее время.
+// Интервалы, которые только касаются друг друга, не пересекаются.
+func (i Interval) Overlaps(other Interval) bool {
+	return i.Start.Before(other.End.Time) && other.Start.Before(i.End.Time)
+}
+
+// String возвращает интервал в нотации ISO 8601 "start/end"
+func (i Interval) String() string {
+	return i.Start.Format(time.RFC3339) + "/" + i.End.Format(time.RFC3339)
+}
+
+// ParseInterval парсит интервал в нотации ISO 8601:
+//   - "2025-01-01T00:00:00Z/2025-01-02T00:00:00Z" — начало и конец
+//   - "2025-01-01T00:00:00Z/P1D" — начало и длительность
+//   - "P1D/2025-01-02T00:00:00Z" — длительность и конец
+//   - "P1D" — длительность начиная с текущего момента
+//
+// Моменты времени принимаются в любом формате ParseUniversalTime. Длительность
+// задается как P[nY][nM][nW][nD][T[nH][nM][nS]], годы и месяцы календарные.
+func ParseInterval(s string) (Interval, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Interval{}, fmt.Errorf("empty interval")
+	}
+
+	first, second, hasSlash := strings.Cut(s, "/")
+	var start, end UniversalTime
+	switch {
+	case !hasSlash:
+		d, err := parseISODuration(first)
+		if err != nil {
+			return Interval{}, err
+		}
+		start = NewUniversalTimeNow()
+		end = NewUniversalTime(d.addTo(start.Time, 1))
+	case isISODuration(first):
+		d, err := parseISODuration(first)
+		if err != nil {
+			return Interval{}, err
+		}
+		if end, err = parseIntervalTime(second); err != nil {
+			return Interval{}, err
+		}
+		start = NewUniversalTime(d.addTo(end.Time, -1))
+	default:
+		var err error
+		if start, err = parseIntervalTime(first); err != nil {
+			return Interval{}, err
+		}
+		if isISODuration(second) {
+			d, err := parseISODuration(second)
+			if err != nil {
+				return Interval{}, err
+			}
+			end = NewUniversalTime(d.addTo(start.Time, 1))
+		} else if end, err = parseIntervalTime(second); err != nil {
+			return Interval{}, err
+		}
+	}
+
+	if !end.After(start.Time) {
+		return Interval{}, fmt.Errorf("interval end must be after its start: %s", s)
+	}
+	return Interval{Start: start, End: end}, nil
+}
+
+// parseIntervalTime парсит границу интервала, пустая граница — ошибка
+func parseIntervalTime(s string) (UniversalTime, error) {
+	if strings.TrimSpace(s) == "" {
+		return UniversalTime{}, fmt.Errorf("missing interval bound")
+	}
+	return ParseUniversalTime(s)
+}
+
+// isoDuration — длительность ISO 8601. Календарные части хранятся отдельно,
+// так как длина года и месяца зависит от даты, к которой они прибавляются.
+type isoDuration struct {
+	years, months, days int
+	clock               time.Duration
+}
+
+// addTo прибавляет длительность к t sign раз (1 или -1)
+func (d isoDuration) addTo(t time.Time, sign int) time.Time {
+	return t.AddDate(sign*d.years, sign*d.months, sign*d.days).Add(time.Duration(sign) * d.clock)
+}
+
+func isISODuration(s string) bool {
+	return strings.HasPrefix(strings.ToUpper(strings.TrimSpace(s)), "P")
+}
+
+// isoDateUnits и isoTimeUnits — обозначения частей длительности до и после T в порядке ISO 8601
+const (
+	isoDateUnits = "YMWD"
+	isoTimeUnits = "HMS"
+)
+
+// parseISODuration парсит P[nY][nM][nW][nD][T[nH][nM][nS]], секунды могут быть дробными.
+// Части идут в указанном порядке и не повторяются, после T должна быть хотя бы одна часть.
+func parseISODuration(s string) (isoDuration, error) {
+	orig := s
+	invalid := fmt.Errorf("invalid ISO 8601 duration: %s", orig)
+	outOfRange := fmt.Errorf("ISO 8601 duration out of range: %s", orig)
+
+	s = strings.ToUpper(strings.TrimSpace(s))
+	rest, ok := strings.CutPrefix(s, "P")
+	if !ok || rest == "" {
+		return isoDuration{}, invalid
+	}
+
+	var d isoDuration
+	units := isoDateUnits // еще допустимые обозначения
+	inTime := false
+	for rest != "" {
+		if rest[0] == 'T' {
+			if inTime || len(rest) == 1 {
+				return isoDuration{}, invalid
+			}
+			inTime = true
+			units = isoTimeUnits
+			rest = rest[1:]
+			continue
+		}
+
+		n := strings.IndexFunc(rest, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+		if n <= 0 {
+			return isoDuration{}, invalid
+		}
+		number, unit := rest[:n], rest[n]
+		rest = rest[n+1:]
+
+		i := strings.IndexByte(units, unit)
+		if i < 0 {
+			return isoDuration{}, invalid
+		}
+		units = units[i+1:]
+
+		// Дробными могут быть только секунды
+		if inTime && unit == 'S' {
+			seconds, err := parseISOSeconds(number)
+			if err != nil {
+				return isoDuration{}, invalid
+			}
+			if seconds > math.MaxInt64-d.clock {
+				return isoDuration{}, outOfRange
+			}
+			d.clock += seconds
+			continue
+		}
+
+		v, err := strconv.Atoi(number)
+		if err != nil {
+			if errors.Is(err, strconv.ErrRange) {
+				return isoDuration{}, outOfRange
+			}
+			return isoDuration{}, invalid
+		}
+		switch {
+		case !inTime && unit == 'Y':
+			d.years = v
+		case !inTime && unit == 'M':
+			d.months = v
+		case !inTime && unit == 'W':
+			if v > math.MaxInt/7 {
+				return isoDuration{}, outOfRange
+			}
+			d.days = 7 * v
+		case !inTime && unit == 'D':
+			if v > math.MaxInt-d.days {
+				return isoDuration{}, outOfRange
+			}
+			d.days += v
+		default: // часы и минуты, секунды разобраны выше
+			unitDuration := time.Hour
+			if unit == 'M' {
+				unitDuration = time.Minute
+			}
+			if int64(v) > (math.MaxInt64-int64(d.clock))/int64(unitDuration) {
+				return isoDuration{}, outOfRange
+			}
+			d.clock += time.Duration(v) * unitDuration
+		}
+	}
+	return d, nil
+}
+
+// parseISOSeconds парсит секунды вида "6" или "6.5" точно до наносекунды, более мелкие доли отбрасываются
+func parseISOSeconds(number string) (time.Duration, error) {
+	whole, frac, hasFrac := strings.Cut(number, ".")
+	if whole == "" || (hasFrac && frac == "") {
+		return 0, fmt.Errorf("invalid seconds: %s", number)
+	}
+
+	sec, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if sec > math.MaxInt64/int64(time.Second) {
+		return 0, strconv.ErrRange
+	}
+
+	var nsec int64
+	if hasFrac {
+		if len(frac) > 9 {
+			frac = frac[:9]
+		}
+		if nsec, err = strconv.ParseInt(frac+strings.Repeat("0", 9-len(frac)), 10, 64); err != nil {
+			return 0, err
+		}
+	}
+
+	d := time.Duration(sec) * time.Second
+	if time.Duration(nsec) > math.MaxInt64-d {
+		return 0, strconv.ErrRange
+	}
+	return d + time.Duration(nsec), nil
+}
@@ -0,0 +1,155 @@
+package timeparser
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseInterval(t *testing.T) {
+	tests := []struct {
+		name       string
+		in         string
+		start, end string
+	}{
+		{"начало и конец", "2025-01-01T00:00:00Z/2025-01-02T00:00:00Z", "2025-01-01T00:00:00Z", "2025-01-02T00:00:00Z"},
+		{"начало и длительность", "2025-01-01T00:00:00Z/P1D", "2025-01-01T00:00:00Z", "2025-01-02T00:00:00Z"},
+		{"длительность и конец", "P1D/2025-01-02T00:00:00Z", "2025-01-01T00:00:00Z", "2025-01-02T00:00:00Z"},
+		{"часы и минуты", "2025-01-01T00:00:00Z/PT1H30M", "2025-01-01T00:00:00Z", "2025-01-01T01:30:00Z"},
+		{"дробные секунды", "2025-01-01T00:00:00Z/PT1.5S", "2025-01-01T00:00:00Z", "2025-01-01T00:00:01.5Z"},
+		{"недели", "2025-01-01T00:00:00Z/P2W", "2025-01-01T00:00:00Z", "2025-01-15T00:00:00Z"},
+		{"недели и дни", "2025-01-01T00:00:00Z/P1W2D", "2025-01-01T00:00:00Z", "2025-01-10T00:00:00Z"},
+		{"календарный месяц", "2025-01-31T00:00:00Z/P1M", "2025-01-31T00:00:00Z", "2025-03-03T00:00:00Z"},
+		{"високосный год", "2024-02-29T00:00:00Z/P1Y", "2024-02-29T00:00:00Z", "2025-03-01T00:00:00Z"},
+		{"месяц назад от конца", "P1M/2025-03-31T00:00:00Z", "2025-03-03T00:00:00Z", "2025-03-31T00:00:00Z"},
+		{"полная длительность", "2025-01-01T00:00:00Z/P1Y2M3DT4H5M6S", "2025-01-01T00:00:00Z", "2026-03-04T04:05:06Z"},
+		{"строчные буквы", "2025-01-01T00:00:00Z/p1dt1h", "2025-01-01T00:00:00Z", "2025-01-02T01:00:00Z"},
+		{"пробелы", "  2025-01-01T00:00:00Z/PT1H  ", "2025-01-01T00:00:00Z", "2025-01-01T01:00:00Z"},
+		{"смещение", "2025-01-01T03:00:00+03:00/PT1H", "2025-01-01T00:00:00Z", "2025-01-01T01:00:00Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseInterval(tt.in)
+			if err != nil {
+				t.Fatalf("ParseInterval(%q): %v", tt.in, err)
+			}
+			if !got.Start.Equal(utc(tt.start).Time) || !got.End.Equal(utc(tt.end).Time) {
+				t.Errorf("ParseInterval(%q) = %s, want %s/%s", tt.in, got, tt.start, tt.end)
+			}
+		})
+	}
+}
+
+func TestParseIntervalFromNow(t *testing.T) {
+	before := time.Now()
+	got, err := ParseInterval("PT2H")
+	if err != nil {
+		t.Fatalf("ParseInterval: %v", err)
+	}
+	if got.Start.Before(before.Add(-time.Second)) || got.Start.After(time.Now()) {
+		t.Errorf("start = %s, want now", got.Start)
+	}
+	if got.Duration() != 2*time.Hour {
+		t.Errorf("duration = %v, want 2h", got.Duration())
+	}
+}
+
+func TestParseIntervalErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"пусто", "", "empty interval"},
+		{"только P", "P", "invalid ISO 8601 duration"},
+		{"T без частей", "2025-01-01T00:00:00Z/P1DT", "invalid ISO 8601 duration"},
+		{"две T", "2025-01-01T00:00:00Z/PT1HT1M", "invalid ISO 8601 duration"},
+		{"неверный порядок", "2025-01-01T00:00:00Z/P1D1Y", "invalid ISO 8601 duration"},
+		{"повтор части", "2025-01-01T00:00:00Z/P1D1D", "invalid ISO 8601 duration"},
+		{"дробные дни", "2025-01-01T00:00:00Z/P1.5D", "invalid ISO 8601 duration"},
+		{"секунды без T", "2025-01-01T00:00:00Z/P1S", "invalid ISO 8601 duration"},
+		{"неизвестная часть", "2025-01-01T00:00:00Z/P1X", "invalid ISO 8601 duration"},
+		{"число без части", "2025-01-01T00:00:00Z/P1", "invalid ISO 8601 duration"},
+		{"отрицательная", "2025-01-01T00:00:00Z/P-1D", "invalid ISO 8601 duration"},
+		{"точка без дроби", "2025-01-01T00:00:00Z/PT1.S", "invalid ISO 8601 duration"},
+		{"переполнение", "2025-01-01T00:00:00Z/PT9999999999999H", "out of range"},
+		{"переполнение недель", "2025-01-01T00:00:00Z/P9999999999999999999W", "out of range"},
+		{"нет начала", "/2025-01-02T00:00:00Z", "missing interval bound"},
+		{"нет конца", "2025-01-01T00:00:00Z/", "missing interval bound"},
+		{"конец до начала", "2025-01-02T00:00:00Z/2025-01-01T00:00:00Z", "end must be after its start"},
+		{"пустой интервал", "2025-01-01T00:00:00Z/2025-01-01T00:00:00Z", "end must be after its start"},
+		{"нулевая длительность", "2025-01-01T00:00:00Z/PT0S", "end must be after its start"},
+		{"неверное время", "вчера/P1D", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseInterval(tt.in)
+			if err == nil {
+				t.Fatalf("ParseInterval(%q) = %s, want an error", tt.in, got)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseInterval(%q) error = %v, want %q", tt.in, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestIntervalContainsAndOverlaps(t *testing.T) {
+	day := Interval{Start: utc("2025-01-01T00:00:00Z"), End: utc("2025-01-02T00:00:00Z")}
+
+	contains := []struct {
+		at   string
+		want bool
+	}{
+		{"2024-12-31T23:59:59Z", false},
+		{"2025-01-01T00:00:00Z", true},
+		{"2025-01-01T12:00:00Z", true},
+		{"2025-01-02T00:00:00Z", false},
+	}
+	for _, tt := range contains {
+		if got := day.Contains(utc(tt.at)); got != tt.want {
+			t.Errorf("Contains(%s) = %v, want %v", tt.at, got, tt.want)
+		}
+	}
+
+	overlaps := []struct {
+		name       string
+		start, end string
+		want       bool
+	}{
+		{"внутри", "2025-01-01T06:00:00Z", "2025-01-01T18:00:00Z", true},
+		{"снаружи", "2024-12-31T00:00:00Z", "2025-01-03T00:00:00Z", true},
+		{"пересекает начало", "2024-12-31T12:00:00Z", "2025-01-01T12:00:00Z", true},
+		{"касается конца", "2025-01-02T00:00:00Z", "2025-01-03T00:00:00Z", false},
+		{"касается начала", "2024-12-31T00:00:00Z", "2025-01-01T00:00:00Z", false},
+		{"раньше", "2024-12-30T00:00:00Z", "2024-12-31T00:00:00Z", false},
+	}
+	for _, tt := range overlaps {
+		t.Run(tt.name, func(t *testing.T) {
+			other := Interval{Start: utc(tt.start), End: utc(tt.end)}
+			if got := day.Overlaps(other); got != tt.want {
+				t.Errorf("Overlaps(%s) = %v, want %v", other, got, tt.want)
+			}
+			if got := other.Overlaps(day); got != tt.want {
+				t.Errorf("Overlaps is not symmetric for %s", other)
+			}
+		})
+	}
+}
+
+func TestIntervalString(t *testing.T) {
+	in := "2025-01-01T00:00:00Z/2025-01-02T12:00:00Z"
+	interval, err := ParseInterval(in)
+	if err != nil {
+		t.Fatalf("ParseInterval: %v", err)
+	}
+	if got := interval.String(); got != in {
+		t.Errorf("String() = %q, want %q", got, in)
+	}
+	if interval.Duration() != 36*time.Hour || interval.IsZero() {
+		t.Errorf("Duration() = %v, IsZero() = %v, want 36h and a set interval", interval.Duration(), interval.IsZero())
+	}
+	if !(Interval{}).IsZero() {
+		t.Error("zero Interval is not IsZero")
+	}
+}
@@ -0,0 +1,47 @@
+package timeparser
+
+import (
+	"time"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// Ключи сообщений Localize, переводы лежат в catalog.go
+const (
+	msgNoExpiry  = "no expiry"
+	msgExpired   = "expired"
+	msgInMinutes = "in %d minutes"
+	msgInHours   = "in %d hours"
+	msgInDays    = "in %d days"
+)
+
+// localizeLanguages — языки каталога, первый используется для остальных
+var localizeLanguages = []language.Tag{language.English, language.Russian, language.German}
+
+var localizeMatcher = language.NewMatcher(localizeLanguages)
+
+// Localize возвращает оставшееся до t время на языке lang, например "через 5 часов".
+// Берется крупнейшая целая единица: дни от суток, часы от часа, иначе минуты
+// (не меньше одной). Нулевое время означает бессрочный ресурс, прошедшее — истекший.
+// Языки без перевода получают английский текст.
+func Localize(t UniversalTime, lang language.Tag) string {
+	// Printer без перевода печатал бы сами ключи, поэтому язык сначала сводится к одному из каталога
+	_, index, _ := localizeMatcher.Match(lang)
+	p := message.NewPrinter(localizeLanguages[index], message.Catalog(localizeCatalog))
+	if t.IsZero() {
+		return p.Sprintf(msgNoExpiry)
+	}
+
+	remaining := time.Until(t.Time)
+	switch {
+	case remaining <= 0:
+		return p.Sprintf(msgExpired)
+	case remaining >= 24*time.Hour:
+		return p.Sprintf(msgInDays, int(remaining/(24*time.Hour)))
+	case remaining >= time.Hour:
+		return p.Sprintf(msgInHours, int(remaining/time.Hour))
+	default:
+		return p.Sprintf(msgInMinutes, max(int(remaining/time.Minute), 1))
+	}
+}
@@ -0,0 +1,70 @@
+package timeparser
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/text/language"
+)
+
+func TestLocalize(t *testing.T) {
+	// A little extra keeps the unit count while the test runs
+	in := func(d time.Duration) UniversalTime {
+		return NewUniversalTime(time.Now().Add(d + 30*time.Second))
+	}
+
+	tests := []struct {
+		name string
+		t    UniversalTime
+		lang language.Tag
+		want string
+	}{
+		{"no expiry", UniversalTime{}, language.English, "no expiry"},
+		{"expired", NewUniversalTime(time.Now().Add(-time.Minute)), language.English, "expired"},
+		{"under a minute", NewUniversalTime(time.Now().Add(10 * time.Second)), language.English, "in 1 minute"},
+		{"minutes", in(5 * time.Minute), language.English, "in 5 minutes"},
+		{"one hour", in(time.Hour), language.English, "in 1 hour"},
+		{"hours", in(23 * time.Hour), language.English, "in 23 hours"},
+		{"one day", in(24 * time.Hour), language.English, "in 1 day"},
+		{"days", in(3*24*time.Hour + 5*time.Hour), language.English, "in 3 days"},
+
+		{"ru no expiry", UniversalTime{}, language.Russian, "без срока действия"},
+		{"ru expired", NewUniversalTime(time.Now().Add(-time.Minute)), language.Russian, "срок истек"},
+		{"ru 1 minute", in(time.Minute), language.Russian, "через 1 минуту"},
+		{"ru 2 minutes", in(2 * time.Minute), language.Russian, "через 2 минуты"},
+		{"ru 5 minutes", in(5 * time.Minute), language.Russian, "через 5 минут"},
+		{"ru 11 minutes", in(11 * time.Minute), language.Russian, "через 11 минут"},
+		{"ru 21 minutes", in(21 * time.Minute), language.Russian, "через 21 минуту"},
+		{"ru 1 hour", in(time.Hour), language.Russian, "через 1 час"},
+		{"ru 2 hours", in(2 * time.Hour), language.Russian, "через 2 часа"},
+		{"ru 5 hours", in(5 * time.Hour), language.Russian, "через 5 часов"},
+		{"ru 11 hours", in(11 * time.Hour), language.Russian, "через 11 часов"},
+		{"ru 21 hours", in(21 * time.Hour), language.Russian, "через 21 час"},
+		{"ru 1 day", in(24 * time.Hour), language.Russian, "через 1 день"},
+		{"ru 2 days", in(2 * 24 * time.Hour), language.Russian, "через 2 дня"},
+		{"ru 5 days", in(5 * 24 * time.Hour), language.Russian, "через 5 дней"},
+		{"ru 11 days", in(11 * 24 * time.Hour), language.Russian, "через 11 дней"},
+		{"ru 21 days", in(21 * 24 * time.Hour), language.Russian, "через 21 день"},
+
+		{"de no expiry", UniversalTime{}, language.German, "kein Ablaufdatum"},
+		{"de expired", NewUniversalTime(time.Now().Add(-time.Minute)), language.German, "abgelaufen"},
+		{"de 1 minute", in(time.Minute), language.German, "in 1 Minute"},
+		{"de minutes", in(5 * time.Minute), language.German, "in 5 Minuten"},
+		{"de 1 hour", in(time.Hour), language.German, "in 1 Stunde"},
+		{"de hours", in(11 * time.Hour), language.German, "in 11 Stunden"},
+		{"de 1 day", in(24 * time.Hour), language.German, "in 1 Tag"},
+		{"de days", in(21 * 24 * time.Hour), language.German, "in 21 Tagen"},
+
+		{"regional Russian", in(5 * time.Hour), language.MustParse("ru-UA"), "через 5 часов"},
+		{"Austrian German", in(2 * time.Hour), language.MustParse("de-AT"), "in 2 Stunden"},
+		{"unsupported language", in(2 * time.Hour), language.French, "in 2 hours"},
+		{"undefined language", in(2 * time.Hour), language.Und, "in 2 hours"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Localize(tt.t, tt.lang); got != tt.want {
+				t.Errorf("Localize = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
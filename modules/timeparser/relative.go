@@ -0,0 +1,35 @@
+package timeparser
+
+import (
+	"fmt"
+	"time"
+)
+
+// ToRelative возвращает время относительно base для отображения в шаблонах:
+// "3 hours from now", "5 days from now" для будущего, "10 minutes ago" для прошлого.
+// Как и в Localize, берется крупнейшая целая единица (дни, часы, минуты),
+// разница меньше минуты дает "just now".
+func (ut UniversalTime) ToRelative(base UniversalTime) string {
+	diff := ut.Sub(base.Time)
+	suffix := "from now"
+	if diff < 0 {
+		diff, suffix = -diff, "ago"
+	}
+
+	var n int
+	var unit string
+	switch {
+	case diff < time.Minute:
+		return "just now"
+	case diff >= 24*time.Hour:
+		n, unit = int(diff/(24*time.Hour)), "day"
+	case diff >= time.Hour:
+		n, unit = int(diff/time.Hour), "hour"
+	default:
+		n, unit = int(diff/time.Minute), "minute"
+	}
+	if n != 1 {
+		unit += "s"
+	}
+	return fmt.Sprintf("%d %s %s", n, unit, suffix)
+}
@@ -0,0 +1,38 @@
+package timeparser
+
+import (
+	"testing"
+	"time"
+)
+
+func TestToRelative(t *testing.T) {
+	base := NewUniversalTime(time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC))
+	tests := []struct {
+		name string
+		diff time.Duration
+		want string
+	}{
+		{"same time", 0, "just now"},
+		{"seconds ahead", 59 * time.Second, "just now"},
+		{"seconds ago", -59 * time.Second, "just now"},
+		{"one minute", time.Minute, "1 minute from now"},
+		{"minutes", 10*time.Minute + 30*time.Second, "10 minutes from now"},
+		{"minutes ago", -10 * time.Minute, "10 minutes ago"},
+		{"just under an hour", 59*time.Minute + 59*time.Second, "59 minutes from now"},
+		{"one hour", time.Hour, "1 hour from now"},
+		{"hours", 3*time.Hour + 59*time.Minute, "3 hours from now"},
+		{"one hour ago", -time.Hour, "1 hour ago"},
+		{"just under a day", 23*time.Hour + 59*time.Minute, "23 hours from now"},
+		{"one day", 24 * time.Hour, "1 day from now"},
+		{"days", 5*24*time.Hour + 23*time.Hour, "5 days from now"},
+		{"days ago", -2 * 24 * time.Hour, "2 days ago"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ut := NewUniversalTime(base.Add(tt.diff))
+			if got := ut.ToRelative(base); got != tt.want {
+				t.Errorf("ToRelative = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package timeparser
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -103,14 +104,54 @@ func (ut UniversalTime) IsZero() bool {
 }
 
 // ParseUniversalTime парсит строку в UniversalTime
-// Поддерживает все форматы дат/времени и приводит к UTC
+// Поддерживает все форматы дат/времени и приводит к UTC.
+// Строки, начинающиеся с "P", — длительность ISO 8601 от текущего момента (см. ParseISODuration).
 func ParseUniversalTime(s string) (UniversalTime, error) {
+	return ParseWithLocation(s, time.UTC)
+}
+
+// Parser парсит время в заданной таймзоне
+type Parser struct {
+	loc *time.Location
+}
+
+// ParserWithLocation создает Parser для таймзоны loc (nil означает UTC)
+func ParserWithLocation(loc *time.Location) Parser {
+	return Parser{loc: loc}
+}
+
+// Parse парсит строку как ParseWithLocation в таймзоне парсера
+func (p Parser) Parse(s string) (UniversalTime, error) {
+	return ParseWithLocation(s, p.loc)
+}
+
+// ParseWithLocation парсит строку как ParseUniversalTime, но время без указания
+// таймзоны (например "2006-01-02" или "2006-01-02 15:04:05") считается временем в loc.
+// Результат всегда в UTC; nil loc означает UTC.
+func ParseWithLocation(s string, loc *time.Location) (UniversalTime, error) {
 	if s == "" {
 		return UniversalTime{}, nil
 	}
+	if loc == nil {
+		loc = time.UTC
+	}
 
 	s = strings.TrimSpace(s)
 
+	// Рабочие дни: "5bd"
+	if ut, ok, err := parseBusinessDays(s, loc); ok {
+		return ut, err
+	}
+
+	// Длительность ISO 8601 от текущего момента: "P1D", "PT2H"
+	if isISODuration(s) {
+		d, err := ParseISODuration(s)
+		if err != nil {
+			return UniversalTime{}, err
+		}
+		return NewUniversalTime(time.Now().Add(d)), nil
+	}
+
 	// Список форматов для парсинга
 	formats := []string{
 		time.RFC3339,
@@ -143,9 +184,9 @@ func ParseUniversalTime(s string) (UniversalTime, error) {
 		"2006-01-02 15:04:05.000000000",  // With nanoseconds
 	}
 
-	// Пробуем каждый формат
+	// Пробуем каждый формат; для форматов со смещением loc не влияет на результат
 	for _, format := range formats {
-		if t, err := time.Parse(format, s); err == nil {
+		if t, err := time.ParseInLocation(format, s, loc); err == nil {
 			return UniversalTime{Time: t.UTC()}, nil
 		}
 	}
@@ -165,8 +206,9 @@ func ParseUniversalTime(s string) (UniversalTime, error) {
 
 // parseUnixTimestamp парсит Unix timestamp в секундах
 func parseUnixTimestamp(s string) (time.Time, error) {
-	var sec int64
-	if _, err := fmt.Sscanf(s, "%d", &sec); err != nil {
+	// strconv в отличие от Sscanf не принимает префиксы вроде "12abc"
+	sec, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
 		return time.Time{}, err
 	}
 
@@ -180,8 +222,8 @@ func parseUnixTimestamp(s string) (time.Time, error) {
 
 // parseUnixTimestampMillis парсит Unix timestamp в миллисекундах
 func parseUnixTimestampMillis(s string) (time.Time, error) {
-	var millis int64
-	if _, err := fmt.Sscanf(s, "%d", &millis); err != nil {
+	millis, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
 		return time.Time{}, err
 	}
 
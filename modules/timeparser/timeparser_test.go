@@ -0,0 +1,69 @@
+package timeparser
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseWithLocation(t *testing.T) {
+	moscow := time.FixedZone("MSK", 3*60*60)
+	newYork := time.FixedZone("EST", -5*60*60)
+
+	tests := []struct {
+		name  string
+		input string
+		loc   *time.Location
+		want  string // RFC3339 in UTC
+	}{
+		{"date only", "2026-05-01", moscow, "2026-04-30T21:00:00Z"},
+		{"date only west", "2026-05-01", newYork, "2026-05-01T05:00:00Z"},
+		{"date and time", "2026-05-01 10:30:00", moscow, "2026-05-01T07:30:00Z"},
+		{"european format", "01.05.2026 10:30:00", moscow, "2026-05-01T07:30:00Z"},
+		{"us format", "05/01/2026 10:30:00", newYork, "2026-05-01T15:30:00Z"},
+		{"nil location is UTC", "2026-05-01", nil, "2026-05-01T00:00:00Z"},
+		// An explicit offset wins over the location
+		{"rfc3339 offset", "2026-05-01T10:30:00+02:00", moscow, "2026-05-01T08:30:00Z"},
+		{"rfc3339 utc", "2026-05-01T10:30:00Z", newYork, "2026-05-01T10:30:00Z"},
+		// Timestamps are absolute
+		{"unix seconds", "1777631400", moscow, "2026-05-01T10:30:00Z"},
+		{"surrounding space", "  2026-05-01  ", moscow, "2026-04-30T21:00:00Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseWithLocation(tt.input, tt.loc)
+			if err != nil {
+				t.Fatalf("ParseWithLocation(%q): %v", tt.input, err)
+			}
+			if got.Time.Location() != time.UTC {
+				t.Errorf("result is in %v, want UTC", got.Time.Location())
+			}
+			if s := got.Time.Format(time.RFC3339); s != tt.want {
+				t.Errorf("ParseWithLocation(%q) = %s, want %s", tt.input, s, tt.want)
+			}
+
+			// Parser is the same parse bound to a location
+			viaParser, err := ParserWithLocation(tt.loc).Parse(tt.input)
+			if err != nil || !viaParser.Time.Equal(got.Time) {
+				t.Errorf("Parser.Parse(%q) = %v, %v, want %v", tt.input, viaParser, err, got)
+			}
+		})
+	}
+}
+
+func TestParseUniversalTimeIsUTC(t *testing.T) {
+	for _, input := range []string{"2026-05-01", "2026-05-01 10:30:00"} {
+		got, err := ParseUniversalTime(input)
+		if err != nil {
+			t.Fatalf("ParseUniversalTime(%q): %v", input, err)
+		}
+		want, _ := ParseWithLocation(input, time.UTC)
+		if !got.Time.Equal(want.Time) {
+			t.Errorf("ParseUniversalTime(%q) = %v, want %v", input, got, want)
+		}
+	}
+
+	got, err := ParseWithLocation("", time.UTC)
+	if err != nil || !got.IsZero() {
+		t.Errorf("ParseWithLocation(\"\") = %v, %v, want the zero time", got, err)
+	}
+}